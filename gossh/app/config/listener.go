@@ -0,0 +1,86 @@
+package config
+
+import (
+	"gossh/toml"
+	"log/slog"
+	"maps"
+	"os"
+	"sync"
+)
+
+// Listener 配置变更后的回调,old 为变更前的配置
+type Listener func(old, conf AppConfig)
+
+var (
+	listenerMu sync.Mutex
+	listeners  []Listener
+)
+
+// Subscribe 注册配置变更的回调,配置通过 RewriteConfig 或 ReloadConfig 修改后按注册顺序调用
+func Subscribe(listener Listener) {
+	listenerMu.Lock()
+	defer listenerMu.Unlock()
+	listeners = append(listeners, listener)
+}
+
+func publish(old, conf AppConfig) {
+	listenerMu.Lock()
+	list := append([]Listener(nil), listeners...)
+	listenerMu.Unlock()
+	for _, listener := range list {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					slog.Error("config listener error:", "err_msg", err)
+				}
+			}()
+			listener(old, conf)
+		}()
+	}
+}
+
+// ReloadConfig 重新读取配置文件并通知监听者,用于手工修改配置文件后不重启生效
+func ReloadConfig() error {
+	data, err := os.ReadFile(confFileFullPath)
+	if err != nil {
+		return err
+	}
+	// 复制默认值,解析时不修改正在使用的配置
+	conf := DefaultConfig
+	conf.Rotation.Scripts = maps.Clone(conf.Rotation.Scripts)
+	conf.Tracing.Headers = maps.Clone(conf.Tracing.Headers)
+	if err := toml.Unmarshal(data, &conf); err != nil {
+		return err
+	}
+	old := DefaultConfig
+	DefaultConfig = conf
+	slog.Info("config reloaded", "path", confFileFullPath)
+	publish(old, conf)
+	return nil
+}
+
+// RestartFields 修改后需要重启才能生效的配置
+func RestartFields(old, conf AppConfig) []string {
+	var fields []string
+	if old.Address != conf.Address {
+		fields = append(fields, "address")
+	}
+	if old.Port != conf.Port || old.Network != conf.Network {
+		fields = append(fields, "port")
+	}
+	if old.Socket != conf.Socket || old.SocketMode != conf.SocketMode {
+		fields = append(fields, "socket")
+	}
+	// 请求体限制立即生效,不需要重启
+	if old.Http.Http2 != conf.Http.Http2 || old.Http.ReadHeaderTimeout != conf.Http.ReadHeaderTimeout ||
+		old.Http.IdleTimeout != conf.Http.IdleTimeout || old.Http.MaxHeaderBytes != conf.Http.MaxHeaderBytes {
+		fields = append(fields, "http")
+	}
+	if old.CertFile != conf.CertFile {
+		fields = append(fields, "cert_file")
+	}
+	if old.KeyFile != conf.KeyFile {
+		fields = append(fields, "key_file")
+	}
+	return fields
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gossh/app/config"
+	"gossh/gin"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestIdHeader 请求ID的请求头和响应头
+const RequestIdHeader = "X-Request-Id"
+
+// validRequestId 校验客户端传入的请求ID,只允许字母、数字、- 和 _
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) < 0
+}
+
+// RequestId 为每个请求生成请求ID,客户端传入合法的 X-Request-Id 时沿用
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIdHeader)
+		if !validRequestId(id) {
+			buf := make([]byte, 16)
+			_, _ = rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIdHeader, id)
+		c.Next()
+	}
+}
+
+// AccessLog 请求结束后记录结构化访问日志,不记录查询参数,避免泄露令牌
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		if !config.DefaultConfig.Log.AccessLog {
+			return
+		}
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("request_id", c.GetString("request_id")),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if uid := c.GetUint("uid"); uid != 0 {
+			attrs = append(attrs, slog.Uint64("uid", uint64(uid)))
+		}
+		if traceId := c.Writer.Header().Get("X-Trace-Id"); traceId != "" {
+			attrs = append(attrs, slog.String("trace_id", traceId))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("err_msg", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "access", attrs...)
+	}
+}
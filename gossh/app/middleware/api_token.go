@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ApiTokenPrefix 个人访问令牌前缀,用于和JWT区分
+const ApiTokenPrefix = "wst_"
+
+// GenerateApiToken 生成随机的个人访问令牌
+func GenerateApiToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return ApiTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashApiToken 数据库只保存令牌的哈希值
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ApiTokenScope 请求路径对应的权限范围,如 /api/ssh/exec => ssh
+func ApiTokenScope(path string) string {
+	path = strings.TrimPrefix(path, "/api/")
+	scope, _, _ := strings.Cut(path, "/")
+	return scope
+}
+
+// 检查令牌是否拥有权限范围,令牌管理接口不允许使用令牌访问
+func hasScope(scopes, scope string) bool {
+	if scope == "token" {
+		return false
+	}
+	for _, item := range strings.Split(scopes, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 校验个人访问令牌,成功返回用户ID
+func checkApiToken(token, path string) (uint, bool) {
+	var apiToken model.ApiToken
+	data, err := apiToken.FindByHash(HashApiToken(token))
+	if err != nil {
+		return 0, false
+	}
+	now := time.Now()
+	if data.IsRevoked == "Y" || data.ExpiryAt.ToTime().Before(now) {
+		return 0, false
+	}
+	if !hasScope(data.Scopes, ApiTokenScope(path)) {
+		return 0, false
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(data.Uid)
+	if err != nil || u.IsEnable == "N" || u.ExpiryAt.ToTime().Before(now) || !TenantEnabled(u.TenantId) {
+		return 0, false
+	}
+
+	// 最多每分钟记录一次使用时间
+	if data.LastUsedAt.ToTime().Add(time.Minute).Before(now) {
+		if err := apiToken.Touch(data.ID); err != nil {
+			slog.Error("ApiToken.Touch error:", "err_msg", err.Error())
+		}
+	}
+	return u.ID, true
+}
+
+// apiTokenAuth 使用个人访问令牌认证
+func apiTokenAuth(c *gin.Context, token string) {
+	uid, ok := checkApiToken(token, c.Request.URL.Path)
+	if !ok {
+		c.Abort()
+		c.JSON(401, gin.H{"code": 401, "msg": "令牌无效或无权访问"})
+		return
+	}
+	c.Set("uid", uid)
+	c.Set("auth_type", "token")
+	c.Next()
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// DbHealth 数据库熔断时直接返回 503,Retry-After 为距离下次探测的秒数
+func DbHealth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.DefaultConfig.IsInit {
+			c.Next()
+			return
+		}
+		if ok, retry := model.DbAvailable(); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"code": 503, "msg": "数据库暂时不可用,请稍后再试"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"gossh/app/utils"
+	"gossh/gin"
+	"strings"
+)
+
+// ForwardedFor 规范化前置代理传入的客户端地址,去掉端口、IPv6 地址的方括号和区域,无法解析的保持不变
+func ForwardedFor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, name := range []string{"X-Forwarded-For", "X-Real-IP"} {
+			values := c.Request.Header.Values(name)
+			if len(values) == 0 {
+				continue
+			}
+			items := strings.Split(strings.Join(values, ","), ",")
+			for i, item := range items {
+				if ip := utils.ParseHostIP(item); ip != nil {
+					items[i] = ip.String()
+				} else {
+					items[i] = strings.TrimSpace(item)
+				}
+			}
+			c.Request.Header.Set(name, strings.Join(items, ", "))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"gossh/app/utils"
+	"gossh/gin"
+	"strings"
+)
+
+// i18nWriter 按协商的语言翻译 JSON 响应中的 msg 字段
+type i18nWriter struct {
+	gin.ResponseWriter
+	lang string
+}
+
+func (w *i18nWriter) Write(data []byte) (int, error) {
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return w.ResponseWriter.Write(data)
+	}
+	var body map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return w.ResponseWriter.Write(data)
+	}
+	msg, ok := body["msg"].(string)
+	if !ok || utils.Translate(w.lang, msg) == msg {
+		return w.ResponseWriter.Write(data)
+	}
+	body["msg"] = utils.Translate(w.lang, msg)
+	translated, err := json.Marshal(body)
+	if err != nil {
+		return w.ResponseWriter.Write(data)
+	}
+	if _, err := w.ResponseWriter.Write(translated); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// I18n 根据 Accept-Language 协商语言,支持 zh-CN 和 en-US,未匹配时返回原始消息
+func I18n() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := utils.NegotiateLang(c.GetHeader("Accept-Language"))
+		c.Set("lang", lang)
+		if lang != "" {
+			c.Writer = &i18nWriter{ResponseWriter: c.Writer, lang: lang}
+		}
+		c.Next()
+	}
+}
@@ -3,8 +3,10 @@ package middleware
 import (
 	"errors"
 	"gossh/app/config"
+	"gossh/app/model"
 	"gossh/gin"
 	"gossh/gin/jwt"
+	"log/slog"
 	"strings"
 	"time"
 )
@@ -12,10 +14,25 @@ import (
 // JwtSecret 生成JWT签名的密钥
 var JwtSecret = []byte(config.DefaultConfig.JwtSecret)
 
+func init() {
+	// 修改密钥后立即使用新密钥,已签发的 Token 失效
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if old.JwtSecret != conf.JwtSecret {
+			JwtSecret = []byte(conf.JwtSecret)
+		}
+	})
+}
+
 type JwtClaims struct {
 	// 用户Id
 	Id uint
 
+	// 模拟登录的管理员Id,不为0时前端显示模拟登录提示
+	Impersonator uint `json:",omitempty"`
+
+	// 模拟登录审计记录Id
+	ImpersonateId uint `json:",omitempty"`
+
 	// 标准Claims结构体，可设置8个标准字段
 	jwt.RegisteredClaims
 }
@@ -32,6 +49,24 @@ func GenerateToken(id uint) (string, error) {
 			Issuer:    "go_web_ssh",
 		},
 	}
+	return signToken(claims)
+}
+
+// GenerateImpersonateToken 生成模拟登录的Token,过期后不续签
+func GenerateImpersonateToken(id, admin, logId uint, expiry time.Time) (string, error) {
+	claims := &JwtClaims{
+		Id:            id,
+		Impersonator:  admin,
+		ImpersonateId: logId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiry),
+			Issuer:    "go_web_ssh",
+		},
+	}
+	return signToken(claims)
+}
+
+func signToken(claims *JwtClaims) (string, error) {
 	// 生成Token，指定签名算法和claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
@@ -53,8 +88,8 @@ func ParseToken(tokenString string) (*JwtClaims, error) {
 }
 
 func RenewToken(claims *JwtClaims) (string, error) {
-	// 若token过期不超过10分钟则给它续签
-	if withinLimit(claims.ExpiresAt.Time.Unix(), 600) {
+	// 若token过期不超过10分钟则给它续签,模拟登录不续签
+	if claims.Impersonator == 0 && withinLimit(claims.ExpiresAt.Time.Unix(), 600) {
 		return GenerateToken(claims.Id)
 	}
 	return "", errors.New("登录已过期")
@@ -78,13 +113,19 @@ func JWTAuth() gin.HandlerFunc {
 			c.JSON(401, gin.H{"code": 401, "msg": "请添加Authorization请求头"})
 			return
 		}
+		auth = strings.TrimPrefix(auth, "Bearer ")
+		// 个人访问令牌
+		if strings.HasPrefix(auth, ApiTokenPrefix) {
+			apiTokenAuth(c, auth)
+			return
+		}
 		// 校验token
 		claims, err := ParseToken(auth)
 		if err != nil {
 			if strings.Contains(err.Error(), "token is expired") {
 				// 若过期，调用续签函数
 				newToken, _ := RenewToken(claims)
-				if newToken != "" {
+				if newToken != "" && userTenantEnabled(claims.Id) {
 					// 续签成功返回头设置一个NewToken字段
 					c.Header("NewToken", newToken)
 					//c.Request.Header.Set("Authorization", newToken)
@@ -98,8 +139,39 @@ func JWTAuth() gin.HandlerFunc {
 			c.JSON(401, gin.H{"code": 401, "msg": "未登录"})
 			return
 		}
+		if claims.Impersonator != 0 && !checkImpersonate(c, claims) {
+			c.Abort()
+			c.JSON(401, gin.H{"code": 401, "msg": "模拟登录已结束"})
+			return
+		}
+		if !userTenantEnabled(claims.Id) {
+			c.Abort()
+			c.JSON(401, gin.H{"code": 401, "msg": "租户已禁用"})
+			return
+		}
 		c.Set("uid", claims.Id)
 		// token未过期继续执行其他中间件
 		c.Next()
 	}
 }
+
+// userTenantEnabled 用户所属租户已启用,租户禁用后已签发的 Token 不能继续使用和续签
+func userTenantEnabled(uid uint) bool {
+	var user model.SshUser
+	u, err := user.FindByID(uid)
+	return err == nil && TenantEnabled(u.TenantId)
+}
+
+// checkImpersonate 校验模拟登录未结束,并记录模拟期间的每个请求
+func checkImpersonate(c *gin.Context, claims *JwtClaims) bool {
+	var impersonateLog model.ImpersonateLog
+	data, err := impersonateLog.FindByID(claims.ImpersonateId)
+	if err != nil || data.AdminId != claims.Impersonator || data.Uid != claims.Id || !data.IsActive(time.Now()) {
+		return false
+	}
+	c.Set("impersonator", claims.Impersonator)
+	c.Set("impersonate_id", claims.ImpersonateId)
+	slog.Info("impersonated request", "impersonate_id", claims.ImpersonateId, "admin_id", claims.Impersonator,
+		"uid", claims.Id, "method", c.Request.Method, "path", c.Request.URL.Path, "client_ip", c.ClientIP())
+	return true
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+// LocalRemoteAddr unix 域套接字的连接没有客户端地址,按本机连接处理
+// 前置代理传入的 X-Forwarded-For 仍然用于确定客户端IP
+func LocalRemoteAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := net.SplitHostPort(r.RemoteAddr); err != nil {
+			r.RemoteAddr = "127.0.0.1:0"
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -4,64 +4,159 @@ import (
 	"fmt"
 	"gossh/app/config"
 	"gossh/app/model"
+	"gossh/app/utils"
 	"gossh/gin"
 	"log/slog"
 	"net"
+	"os"
+	"strings"
 )
 
-func check(ip net.IP) bool {
+// loadGeoIp 加载离线IP归属地数据库,文件不存在时不查询归属地
+func loadGeoIp(file string) {
+	if _, err := os.Stat(file); err != nil {
+		utils.SetGeoProvider(nil)
+		return
+	}
+	provider, err := utils.NewCsvGeoProvider(file)
+	if err != nil {
+		slog.Error("NewCsvGeoProvider error:", "err_msg", err.Error())
+		return
+	}
+	utils.SetGeoProvider(provider)
+}
+
+func init() {
+	loadGeoIp(config.DefaultConfig.GeoIpFile)
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if old.GeoIpFile != conf.GeoIpFile {
+			loadGeoIp(conf.GeoIpFile)
+		}
+	})
+}
+
+// matchCidr 判断IP是否在 CIDR 中,不带前缀长度时按单个IP比较
+func matchCidr(cidr string, ip net.IP) (bool, error) {
+	if !strings.Contains(cidr, "/") {
+		rule := utils.ParseHostIP(cidr)
+		if rule == nil {
+			return false, fmt.Errorf("invalid ip: %s", cidr)
+		}
+		return rule.Equal(ip), nil
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+	return ipNet.Contains(ip), nil
+}
+
+// 判断IP是否命中规则,规则可以配置CIDR、单个IP、地址组或国家代码,命中任意一个即可
+func matchRule(item model.NetFilter, ip net.IP, groups map[uint]model.NetGroup, countryCode func() string) (bool, error) {
+	if item.Cidr != "" {
+		if matched, err := matchCidr(item.Cidr, ip); err != nil || matched {
+			return matched, err
+		}
+	}
+	if item.GroupId != 0 {
+		for _, entry := range groups[item.GroupId].Entries() {
+			if matched, err := matchCidr(entry, ip); err != nil || matched {
+				return matched, err
+			}
+		}
+	}
+	if item.CountryCode != "" && strings.EqualFold(item.CountryCode, countryCode()) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// ruleAllowed 命中规则后是否允许访问,auto 时白名单规则允许,黑名单规则拒绝
+func ruleAllowed(item model.NetFilter) bool {
+	switch item.Action {
+	case "allow":
+		return true
+	case "deny":
+		return false
+	}
+	return item.NetPolicy == "Y"
+}
+
+// defaultAllowed 没有命中规则时是否允许访问,auto 时白名单模式拒绝,黑名单模式允许
+func defaultAllowed(conf model.PolicyConf) bool {
+	switch conf.NetDefault {
+	case "allow":
+		return true
+	case "deny":
+		return false
+	}
+	return conf.NetPolicy != "Y"
+}
+
+// NetDecision 网络过滤的判断结果
+// Reason 为 rule 时由 Rule 决定,为 default 时使用默认策略,为 error 时读取配置或规则出错并拒绝访问
+type NetDecision struct {
+	Allowed   bool             `json:"allowed"`
+	NetPolicy string           `json:"net_policy"`
+	Reason    string           `json:"reason"`
+	Rule      *model.NetFilter `json:"rule"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// EvaluateNetFilter 按当前模式的规则从小到大依次匹配,第一条命中的规则决定是否允许访问
+func EvaluateNetFilter(ip net.IP) NetDecision {
 	var policyConf model.PolicyConf
 	conf, err := policyConf.FindByID(1)
 	if err != nil {
 		slog.Error("get policyConf:", "err_msg", err.Error())
-		return false
+		return NetDecision{Reason: "error", Error: err.Error()}
 	}
-	// 白名单检查
-	if conf.NetPolicy == "Y" {
-		// slog.Info("netFilterPolicy", "value", "Y")
-		var filter model.NetFilter
-		list, err := filter.FindAllPolicy("Y")
+	decision := NetDecision{NetPolicy: conf.NetPolicy}
+	// 按需查询一次归属地
+	var geo *utils.GeoInfo
+	countryCode := func() string {
+		if geo == nil {
+			info := utils.GeoLookup(ip)
+			geo = &info
+		}
+		return geo.CountryCode
+	}
+
+	var filter model.NetFilter
+	list, err := filter.FindAllPolicy(conf.NetPolicy)
+	if err != nil {
+		slog.Error("FindAllPolicy:", "err_msg", err.Error())
+	}
+	groups := map[uint]model.NetGroup{}
+	for _, item := range list {
+		if item.GroupId == 0 {
+			continue
+		}
+		var netGroup model.NetGroup
+		all, err := netGroup.FindAll()
 		if err != nil {
-			slog.Error("FindAllPolicy:", "err_msg", err.Error())
+			slog.Error("NetGroup.FindAll:", "err_msg", err.Error())
 		}
-		isOK := false
-		for _, item := range list {
-			_, ipNet, err := net.ParseCIDR(item.Cidr)
-			if err != nil {
-				slog.Error("net.ParseCIDR:", "err_msg", err.Error())
-				return false
-			}
-			if ipNet.Contains(ip) {
-				isOK = true
-				break
-			}
+		for _, group := range all {
+			groups[group.ID] = group
 		}
-		return isOK
+		break
 	}
 
-	// 黑名单检查
-	if conf.NetPolicy == "N" {
-		// slog.Info("netFilterPolicy", "value", "N")
-		var filter model.NetFilter
-		list, err := filter.FindAllPolicy("N")
+	for _, item := range list {
+		matched, err := matchRule(item, ip, groups, countryCode)
 		if err != nil {
-			slog.Error("FindAllPolicy:", "err_msg", err.Error())
+			slog.Error("net filter rule error:", "rule", item.Name, "err_msg", err.Error())
+			decision.Reason, decision.Rule, decision.Error = "error", &item, err.Error()
+			return decision
 		}
-		isOK := true
-		for _, item := range list {
-			_, ipNet, err := net.ParseCIDR(item.Cidr)
-			if err != nil {
-				slog.Error("net.ParseCIDR:", "err_msg", err.Error())
-				return false
-			}
-			if ipNet.Contains(ip) {
-				isOK = false
-				break
-			}
+		if matched {
+			decision.Allowed, decision.Reason, decision.Rule = ruleAllowed(item), "rule", &item
+			return decision
 		}
-		return isOK
 	}
-	return false
+	decision.Allowed, decision.Reason = defaultAllowed(conf), "default"
+	return decision
 }
 
 func NetFilter() gin.HandlerFunc {
@@ -72,8 +167,8 @@ func NetFilter() gin.HandlerFunc {
 			return
 		}
 
-		ip := net.ParseIP(c.RemoteIP())
-		if !check(ip) {
+		ip := utils.ParseHostIP(c.RemoteIP())
+		if !EvaluateNetFilter(ip).Allowed {
 			c.JSON(403, gin.H{"err_msg": fmt.Sprintf("Deny %s", ip.String())})
 			c.Abort()
 			return
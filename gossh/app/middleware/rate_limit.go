@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"gossh/gin"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateWindow 单个IP在当前时间窗口内的请求数
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit 按客户端IP限制每分钟的请求次数,未登录可访问的接口使用
+func RateLimit(perMinute int) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+	lastClean := time.Now()
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		ip := c.RemoteIP()
+
+		mu.Lock()
+		// 定期清理过期的时间窗口
+		if now.Sub(lastClean) > time.Minute {
+			for key, w := range windows {
+				if now.Sub(w.start) > time.Minute {
+					delete(windows, key)
+				}
+			}
+			lastClean = now
+		}
+		w, ok := windows[ip]
+		if !ok || now.Sub(w.start) > time.Minute {
+			w = &rateWindow{start: now}
+			windows[ip] = w
+		}
+		w.count++
+		allowed := w.count <= perMinute
+		mu.Unlock()
+
+		if !allowed {
+			c.Header("Retry-After", "60")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"code": 429, "msg": "请求过于频繁,请稍后再试"})
+			return
+		}
+		c.Next()
+	}
+}
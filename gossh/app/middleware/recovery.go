@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"gossh/app/utils"
+	"gossh/gin"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// PanicContext 请求中发生 panic 时的上下文,source 为发生位置
+func PanicContext(c *gin.Context, source string) utils.PanicContext {
+	return utils.PanicContext{
+		Source:    source,
+		RequestId: c.GetString("request_id"),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		ClientIp:  c.ClientIP(),
+		Uid:       c.GetUint("uid"),
+	}
+}
+
+// isBrokenPipe 客户端断开连接导致的写入错误,不需要记录堆栈
+func isBrokenPipe(err any) bool {
+	var ne *net.OpError
+	if e, ok := err.(error); !ok || !errors.As(e, &ne) {
+		return false
+	}
+	var se *os.SyscallError
+	if !errors.As(ne, &se) {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// Recovery 恢复请求处理中的 panic,记录堆栈、请求信息和最近日志,返回带请求ID的 500 响应
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				if isBrokenPipe(err) {
+					c.Abort()
+					return
+				}
+				report := utils.ReportPanic(err, PanicContext(c, c.FullPath()))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "服务器内部错误", "request_id": report.RequestId, "error_id": report.Id})
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gossh/app/config"
+	"gossh/gin"
+	"gossh/gin/binding"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// defaultBodyLimits 需要较大请求体的接口,0 表示由处理函数自行限制,可以通过 http.body_limits 覆盖
+var defaultBodyLimits = map[string]int64{
+	"/api/sftp/upload":              0,
+	"/api/sys/import":               64 * 1024 * 1024,
+	"/api/conn_conf/import":         16 * 1024 * 1024,
+	"/api/conn_conf/import/preview": 16 * 1024 * 1024,
+	"/api/conn_conf/bulk":           32 * 1024 * 1024,
+}
+
+// 允许的请求体类型
+var allowedContentTypes = map[string]bool{
+	binding.MIMEJSON:              true,
+	binding.MIMEMultipartPOSTForm: true,
+	binding.MIMEPOSTForm:          true,
+}
+
+func init() {
+	binding.EnableDecoderDisallowUnknownFields = config.DefaultConfig.Http.StrictJson
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if old.Http.StrictJson != conf.Http.StrictJson {
+			binding.EnableDecoderDisallowUnknownFields = conf.Http.StrictJson
+		}
+	})
+}
+
+// bodyLimit 路由的请求体大小限制,0 表示不限制
+func bodyLimit(route string) int64 {
+	conf := config.DefaultConfig.Http
+	if limit, ok := conf.BodyLimits[route]; ok {
+		return limit
+	}
+	if limit, ok := defaultBodyLimits[route]; ok {
+		return limit
+	}
+	return conf.MaxBodySize
+}
+
+// RequestLimit 校验 /api 接口的请求体,超过大小限制返回 413,不支持的类型返回 415,JSON 格式错误返回 400
+func RequestLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := c.Request
+		if !strings.HasPrefix(req.URL.Path, "/api/") || req.Body == nil || req.Body == http.NoBody || (req.ContentLength == 0 && len(req.TransferEncoding) == 0) {
+			c.Next()
+			return
+		}
+		reject := func(status int, msg string) {
+			slog.Warn("request rejected", "method", req.Method, "path", req.URL.Path, "status", status, "content_type", req.Header.Get("Content-Type"),
+				"content_length", req.ContentLength, "client_ip", c.ClientIP(), "request_id", c.GetString("request_id"))
+			c.AbortWithStatusJSON(status, gin.H{"code": status, "msg": msg})
+		}
+
+		limit := bodyLimit(c.FullPath())
+		if limit > 0 {
+			if req.ContentLength > limit {
+				reject(http.StatusRequestEntityTooLarge, "请求体超过大小限制")
+				return
+			}
+			req.Body = http.MaxBytesReader(c.Writer, req.Body, limit)
+		}
+
+		mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil || !allowedContentTypes[mediaType] {
+			reject(http.StatusUnsupportedMediaType, "不支持的请求体类型")
+			return
+		}
+
+		// 有大小限制的 JSON 请求先读取并校验格式,再交给处理函数
+		if mediaType == binding.MIMEJSON && limit > 0 {
+			data, err := io.ReadAll(req.Body)
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				reject(http.StatusRequestEntityTooLarge, "请求体超过大小限制")
+				return
+			}
+			if err != nil || !json.Valid(data) {
+				reject(http.StatusBadRequest, "JSON格式错误")
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(data))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"gossh/app/config"
+	"gossh/gin"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CspNonceKey 上下文中保存本次请求 CSP 随机数的键
+const CspNonceKey = "csp_nonce"
+
+var inlineTagRe = regexp.MustCompile(`<(script|style)(\s|>)`)
+
+// InjectNonce 为 HTML 中的 script 和 style 标签添加 nonce 属性
+func InjectNonce(html []byte, nonce string) []byte {
+	if nonce == "" {
+		return html
+	}
+	return inlineTagRe.ReplaceAll(html, []byte(`<$1 nonce="`+nonce+`"$2`))
+}
+
+// SecurityHeaders 按系统配置发送 CSP、HSTS 等安全响应头,每次请求读取配置,修改后立即生效
+func SecurityHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conf := config.DefaultConfig.Security
+		if conf.Csp != "" {
+			buf := make([]byte, 16)
+			_, _ = rand.Read(buf)
+			nonce := base64.StdEncoding.EncodeToString(buf)
+			c.Set(CspNonceKey, nonce)
+			header := "Content-Security-Policy"
+			if conf.CspReportOnly {
+				header = "Content-Security-Policy-Report-Only"
+			}
+			c.Header(header, strings.ReplaceAll(conf.Csp, "{nonce}", nonce))
+		}
+		if conf.HstsMaxAge > 0 {
+			value := "max-age=" + strconv.FormatInt(int64(conf.HstsMaxAge.Seconds()), 10)
+			if conf.HstsIncludeSubdomains {
+				value += "; includeSubDomains"
+			}
+			if conf.HstsPreload {
+				value += "; preload"
+			}
+			c.Header("Strict-Transport-Security", value)
+		}
+		if conf.FrameOptions != "" {
+			c.Header("X-Frame-Options", conf.FrameOptions)
+		}
+		if conf.ReferrerPolicy != "" {
+			c.Header("Referrer-Policy", conf.ReferrerPolicy)
+		}
+		if conf.NoSniff {
+			c.Header("X-Content-Type-Options", "nosniff")
+		}
+		c.Next()
+	}
+}
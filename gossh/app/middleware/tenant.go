@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 租户列表缓存时间,修改租户后调用 ReloadTenants 立即生效
+const tenantCacheTTL = time.Minute
+
+var (
+	tenantMu       sync.RWMutex
+	tenantCache    []model.Tenant
+	tenantLoadedAt time.Time
+)
+
+type tenantCtxKey struct{}
+
+// ReloadTenants 清空租户缓存
+func ReloadTenants() {
+	tenantMu.Lock()
+	defer tenantMu.Unlock()
+	tenantCache, tenantLoadedAt = nil, time.Time{}
+}
+
+// tenants 查询已启用的租户,数据库未初始化时返回空
+func tenants() []model.Tenant {
+	tenantMu.RLock()
+	if time.Since(tenantLoadedAt) < tenantCacheTTL {
+		defer tenantMu.RUnlock()
+		return tenantCache
+	}
+	tenantMu.RUnlock()
+
+	if model.Db == nil {
+		return nil
+	}
+	var tenant model.Tenant
+	list, err := tenant.FindAll()
+	if err != nil {
+		slog.Error("Tenant.FindAll error:", "err_msg", err.Error())
+		return nil
+	}
+	tenantMu.Lock()
+	defer tenantMu.Unlock()
+	tenantCache, tenantLoadedAt = list, time.Now()
+	return list
+}
+
+// TenantEnabled 租户是否已启用,默认租户总是启用
+func TenantEnabled(id uint) bool {
+	if id == 0 {
+		return true
+	}
+	for _, tenant := range tenants() {
+		if tenant.ID == id {
+			return tenant.IsEnable == "Y"
+		}
+	}
+	return false
+}
+
+// DefaultTenant 只允许默认租户的用户访问,用于所有租户共用的系统配置和访问控制
+func DefaultTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user model.SshUser
+		u, err := user.FindByID(c.GetUint("uid"))
+		if err != nil || u.TenantId != 0 {
+			c.Abort()
+			c.JSON(403, gin.H{"code": 403, "msg": "租户用户不能访问该功能"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// TenantPrefix 处理租户专用的访问路径前缀,去掉前缀后交给 next 处理,并记录匹配的租户
+func TenantPrefix(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, tenant := range tenants() {
+			prefix := tenant.WebBaseDir
+			if prefix == "" || (r.URL.Path != prefix && !strings.HasPrefix(r.URL.Path, prefix+"/")) {
+				continue
+			}
+			r2 := r.WithContext(context.WithValue(r.Context(), tenantCtxKey{}, tenant.ID))
+			u := *r.URL
+			u.Path = strings.TrimPrefix(r.URL.Path, prefix)
+			if u.Path == "" {
+				u.Path = "/"
+			}
+			u.RawPath = ""
+			r2.URL = &u
+			next.ServeHTTP(w, r2)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HostTenant 按访问路径前缀或域名确定租户,未配置专用入口时返回 false
+func HostTenant(r *http.Request) (uint, bool) {
+	if id, ok := r.Context().Value(tenantCtxKey{}).(uint); ok {
+		return id, true
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, tenant := range tenants() {
+		if tenant.Domain != "" && strings.EqualFold(tenant.Domain, host) {
+			return tenant.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"errors"
+	"gossh/app/utils"
+	"gossh/gin"
+	"net/http"
+)
+
+// Trace 为每个请求创建服务端 span,支持通过 traceparent 请求头接入上游追踪
+// 响应头 X-Trace-Id 返回追踪ID,便于在 Jaeger/Tempo 中查询
+func Trace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !utils.TracingEnabled() {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := utils.ContextWithTraceparent(c.Request.Context(), c.GetHeader("traceparent"))
+		ctx, span := utils.StartSpan(ctx, c.Request.Method+" "+route, utils.SpanKindServer)
+		span.SetAttr("http.request.method", c.Request.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("url.path", c.Request.URL.Path)
+		span.SetAttr("client.address", c.ClientIP())
+		span.SetAttr("http.request.id", c.GetString("request_id"))
+		span.SetAttr("user_agent.original", c.Request.UserAgent())
+		c.Request = c.Request.WithContext(ctx)
+		c.Header("X-Trace-Id", span.TraceId())
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttr("http.response.status_code", status)
+		if uid := c.GetUint("uid"); uid != 0 {
+			span.SetAttr("enduser.id", uid)
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetError(errors.New(http.StatusText(status)))
+		} else if len(c.Errors) > 0 {
+			span.SetError(c.Errors.Last())
+		}
+		span.End()
+	}
+}
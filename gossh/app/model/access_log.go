@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 访问日志类型
+const (
+	AccessConnect  = "connect"
+	AccessUpload   = "upload"
+	AccessDownload = "download"
+)
+
+// AccessLog 主机连接和文件传输记录,用于访问报表
+type AccessLog struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Kind      string   `gorm:"not null;size:16" json:"kind"`
+	Uid       uint     `gorm:"not null;default:0;index" json:"uid"`
+	UserName  string   `gorm:"not null;size:128;default:''" json:"user_name"`
+	TenantId  uint     `gorm:"not null;default:0;index" json:"tenant_id"`
+	SessionId string   `gorm:"not null;size:128;default:''" json:"session_id"`
+	ClientIp  string   `gorm:"not null;size:128;default:''" json:"client_ip"`
+	Address   string   `gorm:"not null;size:128;default:''" json:"address"`
+	Port      uint16   `gorm:"not null;default:22" json:"port"`
+	SshUser   string   `gorm:"not null;size:128;default:''" json:"ssh_user"`
+	Path      string   `gorm:"not null;size:1024;default:''" json:"path"`
+	Size      int64    `gorm:"not null;default:0" json:"size"`
+	OccurAt   DateTime `gorm:"not null;index" json:"occur_at"`
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c AccessLog) Create(log *AccessLog) error {
+	return Db.Create(log).Error
+}
+
+// 报表最多包含的行数
+const maxReportRows = 100000
+
+// ReportRow 访问报表的一行,Section 为 connect、upload、download、login_failed
+type ReportRow struct {
+	Section  string `json:"section"`
+	Time     string `json:"time"`
+	User     string `json:"user"`
+	ClientIp string `json:"client_ip"`
+	Target   string `json:"target"`
+	Detail   string `json:"detail"`
+}
+
+// ReportHeader 报表的列名,与 ReportRow.Values 顺序一致
+var ReportHeader = []string{"section", "time", "user", "client_ip", "target", "detail"}
+
+func (r ReportRow) Values() []string {
+	return []string{r.Section, r.Time, r.User, r.ClientIp, r.Target, r.Detail}
+}
+
+// AccessReport 查询时间段内的连接、文件传输和登录失败记录,按时间排序
+// allTenants 为 true 时包含所有租户,否则只包含 tenantId 租户
+func AccessReport(from, to time.Time, tenantId uint, allTenants bool) ([]ReportRow, error) {
+	var logs []AccessLog
+	db := ReadDb().Where("occur_at >= ? AND occur_at < ?", from, to)
+	if !allTenants {
+		db = db.Where("tenant_id = ?", tenantId)
+	}
+	if err := db.Order("occur_at").Limit(maxReportRows).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	var audits []LoginAudit
+	db = ReadDb().Where("is_success = ? AND occur_at >= ? AND occur_at < ?", "N", from, to)
+	if !allTenants {
+		db = db.Where("tenant_id = ?", tenantId)
+	}
+	if err := db.Order("occur_at").Limit(maxReportRows).Find(&audits).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]ReportRow, 0, len(logs)+len(audits))
+	for _, l := range logs {
+		row := ReportRow{
+			Section:  l.Kind,
+			Time:     time.Time(l.OccurAt).Format(TimeFormat),
+			User:     l.UserName,
+			ClientIp: l.ClientIp,
+			Target:   fmt.Sprintf("%s@%s:%d", l.SshUser, l.Address, l.Port),
+		}
+		if l.Kind != AccessConnect {
+			row.Detail = fmt.Sprintf("%s (%d bytes)", l.Path, l.Size)
+		}
+		rows = append(rows, row)
+	}
+	for _, a := range audits {
+		rows = append(rows, ReportRow{
+			Section:  "login_failed",
+			Time:     time.Time(a.OccurAt).Format(TimeFormat),
+			User:     a.Name,
+			ClientIp: a.ClientIp,
+			Detail:   a.ErrMsg,
+		})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Time < rows[j].Time })
+	if len(rows) > maxReportRows {
+		rows = rows[:maxReportRows]
+	}
+	return rows, nil
+}
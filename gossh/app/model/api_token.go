@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+type ApiToken struct {
+	ID         uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid        uint     `gorm:"not null;default:0;index" form:"uid" json:"uid"`
+	Name       string   `gorm:"not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
+	TokenHash  string   `gorm:"uniqueIndex;not null;size:64" form:"-" json:"-"`
+	Prefix     string   `gorm:"not null;size:16" form:"-" json:"prefix"`
+	Scopes     string   `gorm:"not null;size:512;default:'*'" form:"scopes" binding:"required,min=1,max=512" json:"scopes"`
+	IsRevoked  string   `gorm:"not null;size:64;default:'N'" form:"-" json:"is_revoked"`
+	ExpiryAt   DateTime `gorm:"expiry_at;not null" json:"expiry_at" form:"expiry_at" binding:"required"`
+	LastUsedAt DateTime `gorm:"last_used_at" json:"last_used_at" form:"-"`
+	CreatedAt  DateTime `gorm:"created_at" json:"created_at"`
+	UpdatedAt  DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c ApiToken) Create(token *ApiToken) error {
+	return Db.Create(token).Error
+}
+
+func (c ApiToken) FindByHash(hash string) (ApiToken, error) {
+	var token ApiToken
+	err := Db.First(&token, "token_hash = ?", hash).Error
+	return token, err
+}
+
+func (c ApiToken) FindAll(uid uint, offset, limit int) ([]ApiToken, error) {
+	var list []ApiToken
+	err := ReadDb().Where("uid = ?", uid).Offset(offset).Limit(limit).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c ApiToken) FindPage(q PageQuery, uid uint) ([]ApiToken, int64, error) {
+	return findPage[ApiToken](ReadDb().Where("uid = ?", uid), q, pageSpec{
+		Sorts: []string{"id", "name", "expiry_at", "last_used_at", "created_at"},
+		Filters: map[string]string{
+			"name":       "like",
+			"is_revoked": "eq",
+		},
+		DefaultSort: "id desc",
+	})
+}
+
+func (c ApiToken) Revoke(id, uid uint) error {
+	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Update("is_revoked", "Y").Error
+}
+
+func (c ApiToken) Touch(id uint) error {
+	return Db.Model(&c).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+type Approval struct {
+	ID         uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid        uint     `gorm:"not null;default:0" form:"uid" json:"uid"`
+	UserName   string   `gorm:"not null;size:64;default:''" form:"user_name" json:"user_name"`
+	SessionId  string   `gorm:"not null;size:128;default:''" form:"session_id" json:"session_id"`
+	ClientIp   string   `gorm:"size:128" form:"client_ip" json:"client_ip"`
+	Address    string   `gorm:"size:128" form:"address" json:"address"`
+	SshUser    string   `gorm:"size:128" form:"ssh_user" json:"ssh_user"`
+	Port       uint16   `gorm:"not null;default:22" form:"port" json:"port"`
+	Status     string   `gorm:"not null;size:32;default:'pending'" form:"status" json:"status"`
+	ApproverId uint     `gorm:"not null;default:0" form:"approver_id" json:"approver_id"`
+	Approver   string   `gorm:"not null;size:64;default:''" form:"approver" json:"approver"`
+	Comment    string   `gorm:"not null;size:512;default:''" form:"comment" json:"comment"`
+	ExpiryAt   DateTime `gorm:"expiry_at;not null" json:"expiry_at" form:"expiry_at"`
+	CreatedAt  DateTime `gorm:"created_at" json:"created_at"`
+	UpdatedAt  DateTime `gorm:"updated_at" json:"updated_at"`
+}
+
+func (c Approval) Create(approval *Approval) error {
+	return Db.Create(approval).Error
+}
+
+func (c Approval) FindByID(id uint) (Approval, error) {
+	var approval Approval
+	err := Db.First(&approval, "id = ?", id).Error
+	return approval, err
+}
+
+func (c Approval) FindAll(status string, uid uint, offset, limit int) ([]Approval, error) {
+	var list []Approval
+	var db = ReadDb()
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if uid != 0 {
+		db = db.Where("uid = ?", uid)
+	}
+	err := db.Offset(offset).Limit(limit).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c Approval) FindPage(q PageQuery, uid uint) ([]Approval, int64, error) {
+	var db = ReadDb()
+	if uid != 0 {
+		db = db.Where("uid = ?", uid)
+	}
+	return findPage[Approval](db, q, pageSpec{
+		Sorts: []string{"id", "user_name", "address", "status", "expiry_at", "created_at"},
+		Filters: map[string]string{
+			"user_name": "like",
+			"address":   "like",
+			"ssh_user":  "like",
+			"client_ip": "like",
+			"status":    "eq",
+		},
+		DefaultSort: "id desc",
+	})
+}
+
+// UpdateStatus 只能处理待审批且未过期的申请
+func (c Approval) UpdateStatus(id uint, approval *Approval) (int64, error) {
+	ret := Db.Model(&c).Where("id = ? AND status = ? AND expiry_at > ?", id, "pending", time.Now()).Updates(approval)
+	return ret.RowsAffected, ret.Error
+}
+
+// ExpirePending 将过期未处理的申请标记为过期
+func (c Approval) ExpirePending() error {
+	return Db.Model(&c).Where("status = ? AND expiry_at <= ?", "pending", time.Now()).Update("status", "expired").Error
+}
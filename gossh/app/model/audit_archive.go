@@ -0,0 +1,231 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"gossh/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuditTable 需要保留策略的审计表,TimeColumn 为按时间清理和分区的字段
+type AuditTable struct {
+	Name       string
+	TimeColumn string
+	Model      any
+}
+
+// AuditTables 执行保留策略的审计表,新增审计表时在这里登记
+var AuditTables = []AuditTable{
+	{Name: "login_audit", TimeColumn: "occur_at", Model: &LoginAudit{}},
+	{Name: "access_log", TimeColumn: "occur_at", Model: &AccessLog{}},
+	{Name: "session_event", TimeColumn: "occur_at", Model: &SessionEvent{}},
+}
+
+// AuditPartition 按月分区,包含 [From, To) 时间段的数据
+type AuditPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// partitionName 分区名为 p202601 格式
+func partitionName(month time.Time) string {
+	return "p" + month.Format("200601")
+}
+
+// monthStart 所在月份的第一天
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// FindBatch 按 ID 顺序查询 afterId 之后满足条件的一批数据,用于归档,返回这批数据的最大 ID
+func (t AuditTable) FindBatch(where string, args []any, afterId uint, limit int) ([]map[string]any, uint, error) {
+	var list []map[string]any
+	err := Db.Table(t.Name).Where(where, args...).Where("id > ?", afterId).Order("id").Limit(limit).Find(&list).Error
+	if err != nil || len(list) == 0 {
+		return list, afterId, err
+	}
+	var lastId uint
+	switch id := list[len(list)-1]["id"].(type) {
+	case int64:
+		lastId = uint(id)
+	case uint64:
+		lastId = uint(id)
+	case int32:
+		lastId = uint(id)
+	case uint32:
+		lastId = uint(id)
+	default:
+		return nil, afterId, fmt.Errorf("unexpected id type %T", id)
+	}
+	return list, lastId, nil
+}
+
+// DeleteUpTo 删除满足条件并且 ID 不大于 maxId 的数据,返回删除的行数
+func (t AuditTable) DeleteUpTo(where string, args []any, maxId uint) (int64, error) {
+	ret := Db.Table(t.Name).Where(where, args...).Where("id <= ?", maxId).Delete(t.Model)
+	return ret.RowsAffected, ret.Error
+}
+
+// RowsCutoffId 超出保留行数时返回需要删除的最大 ID,按 ID 从新到旧保留 keep 行
+func (t AuditTable) RowsCutoffId(keep int64) (uint, bool, error) {
+	var ids []uint
+	err := Db.Table(t.Name).Order("id desc").Offset(int(keep)).Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, false, err
+	}
+	return ids[0], true, nil
+}
+
+// IsPartitioned 表是否已经按时间分区
+func (t AuditTable) IsPartitioned() (bool, error) {
+	var count int64
+	var err error
+	switch Db.Dialector.Name() {
+	case "postgres":
+		err = Db.Raw("SELECT count(*) FROM pg_partitioned_table pt JOIN pg_class c ON c.oid = pt.partrelid WHERE c.relname = ?", t.Name).Scan(&count).Error
+	case "mysql":
+		err = Db.Raw("SELECT count(*) FROM information_schema.partitions WHERE table_schema = DATABASE() AND table_name = ? AND partition_name IS NOT NULL", t.Name).Scan(&count).Error
+	default:
+		return false, errors.New("数据库不支持分区")
+	}
+	return count > 0, err
+}
+
+// Partitions 按时间排序的分区列表,不包含 MySQL 的 pmax 分区
+func (t AuditTable) Partitions() ([]AuditPartition, error) {
+	var names []string
+	var err error
+	switch Db.Dialector.Name() {
+	case "postgres":
+		err = Db.Raw("SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid JOIN pg_class p ON p.oid = i.inhparent WHERE p.relname = ?", t.Name).Scan(&names).Error
+	case "mysql":
+		err = Db.Raw("SELECT partition_name FROM information_schema.partitions WHERE table_schema = DATABASE() AND table_name = ? AND partition_name IS NOT NULL", t.Name).Scan(&names).Error
+	default:
+		return nil, errors.New("数据库不支持分区")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []AuditPartition
+	for _, name := range names {
+		month, err := time.ParseInLocation("200601", strings.TrimPrefix(strings.TrimPrefix(name, t.Name+"_"), "p"), time.Local)
+		if err != nil {
+			continue
+		}
+		list = append(list, AuditPartition{Name: name, From: month, To: month.AddDate(0, 1, 0)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].From.Before(list[j].From) })
+	return list, nil
+}
+
+// Partition 将已有的表转换为按月分区的表,分区从最早的数据所在月份到 ahead 个月之后
+// MySQL 分区字段必须包含在主键中,主键改为 (id, 时间字段)
+// PostgreSQL 新建分区表并复制数据,自增序列转移到新表
+func (t AuditTable) Partition(now time.Time, ahead int) error {
+	var oldest []time.Time
+	if err := Db.Table(t.Name).Order(t.TimeColumn).Limit(1).Pluck(t.TimeColumn, &oldest).Error; err != nil {
+		return err
+	}
+	start := monthStart(now)
+	if len(oldest) > 0 && oldest[0].Before(start) {
+		start = monthStart(oldest[0].In(time.Local))
+	}
+	end := monthStart(now).AddDate(0, ahead+1, 0)
+
+	switch Db.Dialector.Name() {
+	case "mysql":
+		var parts []string
+		for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+			parts = append(parts, fmt.Sprintf("PARTITION %s VALUES LESS THAN ('%s')", partitionName(month), month.AddDate(0, 1, 0).Format(TimeFormat)))
+		}
+		parts = append(parts, "PARTITION pmax VALUES LESS THAN (MAXVALUE)")
+		if err := Db.Exec(fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY, ADD PRIMARY KEY (id, %s)", t.Name, t.TimeColumn)).Error; err != nil {
+			return err
+		}
+		return Db.Exec(fmt.Sprintf("ALTER TABLE %s PARTITION BY RANGE COLUMNS(%s) (%s)", t.Name, t.TimeColumn, strings.Join(parts, ", "))).Error
+	case "postgres":
+		err := Db.Transaction(func(tx *gorm.DB) error {
+			old := t.Name + "_old"
+			var seq string
+			stmts := []string{
+				fmt.Sprintf("ALTER TABLE %s RENAME TO %s", t.Name, old),
+				fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS) PARTITION BY RANGE (%s)", t.Name, old, t.TimeColumn),
+				fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id, %s)", t.Name, t.TimeColumn),
+			}
+			for _, stmt := range stmts {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+				if err := createPgPartition(tx, t.Name, month); err != nil {
+					return err
+				}
+			}
+			if err := tx.Raw("SELECT COALESCE(pg_get_serial_sequence(?, 'id'), '')", old).Scan(&seq).Error; err != nil {
+				return err
+			}
+			stmts = []string{fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", t.Name, old)}
+			if seq != "" {
+				stmts = append(stmts, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY NONE", seq))
+			}
+			stmts = append(stmts, fmt.Sprintf("DROP TABLE %s", old))
+			if seq != "" {
+				stmts = append(stmts, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.id", seq, t.Name))
+			}
+			for _, stmt := range stmts {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// 原表的索引随原表删除,重新创建
+		return Db.AutoMigrate(t.Model)
+	}
+	return errors.New("数据库不支持分区")
+}
+
+func createPgPartition(tx *gorm.DB, table string, month time.Time) error {
+	return tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_%s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		table, partitionName(month), table, month.Format(TimeFormat), month.AddDate(0, 1, 0).Format(TimeFormat))).Error
+}
+
+// EnsurePartitions 创建当前月份之后 ahead 个月的分区
+func (t AuditTable) EnsurePartitions(now time.Time, ahead int) error {
+	list, err := t.Partitions()
+	if err != nil {
+		return err
+	}
+	last := monthStart(now).AddDate(0, -1, 0)
+	if len(list) > 0 {
+		last = list[len(list)-1].From
+	}
+	for month := last.AddDate(0, 1, 0); !month.After(monthStart(now).AddDate(0, ahead, 0)); month = month.AddDate(0, 1, 0) {
+		switch Db.Dialector.Name() {
+		case "mysql":
+			err = Db.Exec(fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION pmax INTO (PARTITION %s VALUES LESS THAN ('%s'), PARTITION pmax VALUES LESS THAN (MAXVALUE))",
+				t.Name, partitionName(month), month.AddDate(0, 1, 0).Format(TimeFormat))).Error
+		case "postgres":
+			err = createPgPartition(Db, t.Name, month)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropPartition 删除分区及其中的数据
+func (t AuditTable) DropPartition(p AuditPartition) error {
+	if Db.Dialector.Name() == "mysql" {
+		return Db.Exec(fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", t.Name, p.Name)).Error
+	}
+	return Db.Exec("DROP TABLE " + p.Name).Error
+}
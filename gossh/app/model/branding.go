@@ -0,0 +1,42 @@
+package model
+
+// Branding 白标配置,只有一条记录,未保存时使用默认值
+// Logo 为图片地址或 data:image 格式的内嵌图片,FooterLinks 为页脚链接的 JSON 数组 [{"name":"","url":""}]
+type Branding struct {
+	ID           uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	ProductName  string   `gorm:"not null;size:64;default:''" form:"product_name" binding:"max=64" json:"product_name"`
+	Logo         string   `gorm:"type:text" form:"logo" binding:"max=262144" json:"logo"`
+	LoginMessage string   `gorm:"type:text" form:"login_message" binding:"max=4096" json:"login_message"`
+	FooterLinks  string   `gorm:"type:text" form:"footer_links" binding:"max=8192" json:"footer_links"`
+	CreatedAt    DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt    DateTime `gorm:"updated_at" json:"-"`
+}
+
+// Find 查询白标配置,没有保存过时返回空配置
+func (c Branding) Find() (Branding, error) {
+	var list []Branding
+	if err := Db.Order("id").Limit(1).Find(&list).Error; err != nil {
+		return Branding{}, err
+	}
+	if len(list) == 0 {
+		return Branding{}, nil
+	}
+	return list[0], nil
+}
+
+// Save 保存白标配置,不存在时创建
+func (c Branding) Save(branding *Branding) error {
+	old, err := c.Find()
+	if err != nil {
+		return err
+	}
+	if old.ID == 0 {
+		branding.ID = 0
+		return Db.Create(branding).Error
+	}
+	branding.ID = old.ID
+	// 指定字段更新,零值也会写入
+	return Db.Model(&Branding{}).Where("id = ?", old.ID).
+		Select("product_name", "logo", "login_message", "footer_links").
+		Updates(branding).Error
+}
@@ -0,0 +1,74 @@
+package model
+
+// CascadeStep 级联删除的一步,按条件彻底删除一张表中的关联数据
+type CascadeStep struct {
+	Name  string
+	Model any
+	Where string
+	Args  []any
+}
+
+// Run 执行删除,返回删除的行数
+func (s CascadeStep) Run() (int64, error) {
+	ret := Db.Unscoped().Where(s.Where, s.Args...).Delete(s.Model)
+	return ret.RowsAffected, ret.Error
+}
+
+// UserCascadeSteps 删除用户时需要清理的关联数据,会话录像需要先删除文件,不在其中
+func UserCascadeSteps(user SshUser) []CascadeStep {
+	return []CascadeStep{
+		{Name: "conn_conf", Model: &SshConf{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "cmd_note", Model: &CmdNote{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "shell_profile", Model: &ShellProfile{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "user_macro", Model: &UserMacro{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "pipeline", Model: &Pipeline{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "api_token", Model: &ApiToken{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "approval", Model: &Approval{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "cred_checkout", Model: &CredCheckout{}, Where: "uid = ? AND returned_at IS NOT NULL", Args: []any{user.ID}},
+		{Name: "secret_event", Model: &SecretEvent{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "login_audit", Model: &LoginAudit{}, Where: "name = ? AND tenant_id = ?", Args: []any{user.Name, user.TenantId}},
+		{Name: "access_log", Model: &AccessLog{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "session_event", Model: &SessionEvent{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "session_share", Model: &SessionShare{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "user_pref", Model: &UserPref{}, Where: "uid = ?", Args: []any{user.ID}},
+		{Name: "quota", Model: &Quota{}, Where: "scope = ? AND target_id = ?", Args: []any{QuotaScopeUser, user.ID}},
+		{Name: "user", Model: &SshUser{}, Where: "id = ? AND is_root = ?", Args: []any{user.ID, "N"}},
+	}
+}
+
+// ConfCascadeSteps 删除主机配置时需要清理的关联数据
+func ConfCascadeSteps(conf SshConf) []CascadeStep {
+	return []CascadeStep{
+		{Name: "approval", Model: &Approval{}, Where: "uid = ? AND address = ? AND port = ? AND ssh_user = ?", Args: []any{conf.Uid, conf.Address, conf.Port, conf.User}},
+		{Name: "cred_checkout", Model: &CredCheckout{}, Where: "conf_id = ? AND returned_at IS NOT NULL", Args: []any{conf.ID}},
+		{Name: "secret_event", Model: &SecretEvent{}, Where: "conf_id = ?", Args: []any{conf.ID}},
+		{Name: "conn_conf", Model: &SshConf{}, Where: "id = ? AND uid = ?", Args: []any{conf.ID, conf.Uid}},
+	}
+}
+
+// FindByUid 查询用户的全部录像,用于级联删除
+func (c SessionRecord) FindByUid(uid uint) ([]SessionRecord, error) {
+	var list []SessionRecord
+	err := Db.Where("uid = ? AND end_at IS NOT NULL", uid).Order("id asc").Find(&list).Error
+	return list, err
+}
+
+// FindByHost 查询用户连接某个主机账号的录像,用于级联删除
+func (c SessionRecord) FindByHost(uid uint, address string, port uint16, sshUser string) ([]SessionRecord, error) {
+	var list []SessionRecord
+	err := Db.Where("uid = ? AND address = ? AND port = ? AND ssh_user = ? AND end_at IS NOT NULL", uid, address, port, sshUser).Order("id asc").Find(&list).Error
+	return list, err
+}
+
+// FindByIDs 查询用户的多个配置,不属于该用户的忽略
+func (c SshConf) FindByIDs(ids []uint, uid uint) ([]SshConf, error) {
+	var list []SshConf
+	err := Db.Where("id IN ? AND uid = ?", ids, uid).Find(&list).Error
+	return list, err
+}
+
+// DeleteByIDs 将用户的多个配置放入回收站
+func (c SshConf) DeleteByIDs(ids []uint, uid uint) (int64, error) {
+	ret := Db.Delete(&c, "id IN ? AND uid = ?", ids, uid)
+	return ret.RowsAffected, ret.Error
+}
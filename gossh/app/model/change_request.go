@@ -0,0 +1,66 @@
+package model
+
+type ChangeRequest struct {
+	ID          uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Resource    string   `gorm:"not null;size:64" form:"resource" json:"resource"`
+	Action      string   `gorm:"not null;size:32" form:"action" json:"action"`
+	TargetId    uint     `gorm:"not null;default:0" form:"target_id" json:"target_id"`
+	Before      string   `gorm:"type:text" form:"before" json:"before"`
+	Payload     string   `gorm:"type:text" form:"payload" json:"payload"`
+	Status      string   `gorm:"not null;size:32;default:'pending'" form:"status" json:"status"`
+	RequesterId uint     `gorm:"not null;default:0" form:"requester_id" json:"requester_id"`
+	Requester   string   `gorm:"not null;size:64;default:''" form:"requester" json:"requester"`
+	ReviewerId  uint     `gorm:"not null;default:0" form:"reviewer_id" json:"reviewer_id"`
+	Reviewer    string   `gorm:"not null;size:64;default:''" form:"reviewer" json:"reviewer"`
+	Comment     string   `gorm:"not null;size:512;default:''" form:"comment" json:"comment"`
+	RequestId   string   `gorm:"not null;size:64;default:''" form:"request_id" json:"request_id"`
+	TenantId    uint     `gorm:"not null;default:0;index" form:"tenant_id" json:"tenant_id"`
+	CreatedAt   DateTime `gorm:"created_at" json:"created_at"`
+	UpdatedAt   DateTime `gorm:"updated_at" json:"updated_at"`
+}
+
+func (c ChangeRequest) Create(req *ChangeRequest) error {
+	return Db.Create(req).Error
+}
+
+func (c ChangeRequest) FindByID(id uint) (ChangeRequest, error) {
+	var req ChangeRequest
+	err := Db.First(&req, "id = ?", id).Error
+	return req, err
+}
+
+func (c ChangeRequest) FindAll(status string, offset, limit int) ([]ChangeRequest, error) {
+	var list []ChangeRequest
+	var db = ReadDb()
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	err := db.Offset(offset).Limit(limit).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询租户下的变更申请,返回当前页数据和总数
+func (c ChangeRequest) FindPage(q PageQuery, tenantId uint) ([]ChangeRequest, int64, error) {
+	return findPage[ChangeRequest](ReadDb().Where("tenant_id = ?", tenantId), q, pageSpec{
+		Sorts: []string{"id", "resource", "action", "status", "requester", "created_at", "updated_at"},
+		Filters: map[string]string{
+			"resource":  "eq",
+			"action":    "eq",
+			"status":    "eq",
+			"requester": "like",
+			"reviewer":  "like",
+		},
+		DefaultSort: "id desc",
+	})
+}
+
+// UpdateStatus 只能处理待审核的变更
+func (c ChangeRequest) UpdateStatus(id uint, req *ChangeRequest) (int64, error) {
+	ret := Db.Model(&c).Where("id = ? AND status = ?", id, "pending").Updates(req)
+	return ret.RowsAffected, ret.Error
+}
+
+// MarkFailed 审核通过但执行变更失败
+func (c ChangeRequest) MarkFailed(id uint, msg string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(&ChangeRequest{Status: "failed", Comment: msg}).Error
+}
@@ -0,0 +1,99 @@
+package model
+
+import "time"
+
+// ClusterLease 主节点租约,Holder 在 ExpiresAt 之前持有租约
+type ClusterLease struct {
+	Name      string   `gorm:"primaryKey;size:64" json:"name"`
+	Holder    string   `gorm:"not null;size:128;default:''" json:"holder"`
+	ExpiresAt DateTime `gorm:"expires_at;not null" json:"expires_at"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c ClusterLease) FindByName(name string) (ClusterLease, error) {
+	var list []ClusterLease
+	err := Db.Where("name = ?", name).Limit(1).Find(&list).Error
+	if err != nil || len(list) == 0 {
+		return ClusterLease{Name: name}, err
+	}
+	return list[0], nil
+}
+
+// Acquire 获取或续期租约,租约由其他节点持有且未过期时返回 false
+func (c ClusterLease) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	err := Db.Model(&c).Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]any{"holder": holder, "expires_at": now.Add(ttl)}).Error
+	if err != nil {
+		return false, err
+	}
+	// 时间精度为秒时续期可能没有修改任何行,以查询结果为准
+	lease, err := c.FindByName(name)
+	if err != nil {
+		return false, err
+	}
+	if lease.Holder != "" {
+		return lease.Holder == holder, nil
+	}
+	// 多个节点同时创建时主键冲突,由先创建的节点持有
+	if err := Db.Create(&ClusterLease{Name: name, Holder: holder, ExpiresAt: DateTime(now.Add(ttl))}).Error; err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Release 释放租约,其他节点可以立即接管
+func (c ClusterLease) Release(name, holder string) error {
+	return Db.Model(&c).Where("name = ? AND holder = ?", name, holder).
+		Update("expires_at", time.Now().Add(-time.Second)).Error
+}
+
+// ClusterSession 各节点登记的在线会话,节点定时刷新 HeartbeatAt
+// 节点失效后由主节点结束其会话录像并删除登记
+type ClusterSession struct {
+	SessionId   string   `gorm:"primaryKey;size:128" json:"session_id"`
+	NodeId      string   `gorm:"not null;size:128;index" json:"node_id"`
+	Uid         uint     `gorm:"not null;default:0" json:"uid"`
+	TenantId    uint     `gorm:"not null;default:0" json:"tenant_id"`
+	SshUser     string   `gorm:"size:128" json:"ssh_user"`
+	Address     string   `gorm:"size:128" json:"address"`
+	Port        uint16   `gorm:"not null;default:22" json:"port"`
+	ClientIp    string   `gorm:"size:128" json:"client_ip"`
+	StartAt     DateTime `gorm:"start_at;not null" json:"start_at"`
+	HeartbeatAt DateTime `gorm:"heartbeat_at;not null;index" json:"heartbeat_at"`
+}
+
+func (c ClusterSession) Create(session *ClusterSession) error {
+	return Db.Create(session).Error
+}
+
+func (c ClusterSession) FindAll() ([]ClusterSession, error) {
+	var list []ClusterSession
+	err := Db.Order("node_id asc, start_at asc").Find(&list).Error
+	return list, err
+}
+
+func (c ClusterSession) FindByNode(nodeId string) ([]ClusterSession, error) {
+	var list []ClusterSession
+	err := Db.Where("node_id = ?", nodeId).Find(&list).Error
+	return list, err
+}
+
+// FindStale 查询指定时间之后没有刷新的会话
+func (c ClusterSession) FindStale(before time.Time) ([]ClusterSession, error) {
+	var list []ClusterSession
+	err := Db.Where("heartbeat_at < ?", before).Find(&list).Error
+	return list, err
+}
+
+// Touch 刷新节点全部会话的心跳时间
+func (c ClusterSession) Touch(nodeId string) error {
+	return Db.Model(&c).Where("node_id = ?", nodeId).Update("heartbeat_at", time.Now()).Error
+}
+
+func (c ClusterSession) DeleteBySessionIds(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return Db.Where("session_id IN ?", ids).Delete(&ClusterSession{}).Error
+}
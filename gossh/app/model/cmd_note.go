@@ -5,6 +5,7 @@ type CmdNote struct {
 	Uid     uint   `gorm:"not null;default:0" form:"uid" json:"uid"`
 	CmdName string `gorm:"type:text" form:"cmd_name" binding:"required" json:"cmd_name"`
 	CmdData string `gorm:"type:text" form:"cmd_data" binding:"required" json:"cmd_data"`
+	Folder  string `gorm:"not null;size:128;default:''" form:"folder" binding:"max=128" json:"folder"`
 
 	CreatedAt DateTime `gorm:"created_at" json:"-"`
 	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
@@ -28,12 +29,33 @@ func (c CmdNote) FindByID(id uint, uid uint) (CmdNote, error) {
 
 func (c CmdNote) FindAll(offset, limit int, uid uint) ([]CmdNote, error) {
 	var list []CmdNote
-	err := Db.Where("uid = ?", uid).Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
+	err := ReadDb().Where("uid = ?", uid).Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
 	return list, err
 }
 
+// FindPage 分页查询,返回当前页数据和总数
+func (c CmdNote) FindPage(q PageQuery, uid uint) ([]CmdNote, int64, error) {
+	return findPage[CmdNote](ReadDb().Where("uid = ?", uid), q, pageSpec{
+		Sorts: []string{"id", "cmd_name", "folder", "updated_at"},
+		Filters: map[string]string{
+			"cmd_name": "like",
+			"cmd_data": "like",
+			"folder":   "eq",
+		},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+// FindFolders 用户的命令目录列表
+func (c CmdNote) FindFolders(uid uint) ([]string, error) {
+	var list []string
+	err := Db.Model(&CmdNote{}).Where("uid = ? AND folder <> ?", uid, "").Distinct("folder").Order("folder").Pluck("folder", &list).Error
+	return list, err
+}
+
+// UpdateById 更新命令,允许移出目录
 func (c CmdNote) UpdateById(id, uid uint, cmd *CmdNote) error {
-	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Updates(cmd).Error
+	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Select("cmd_name", "cmd_data", "folder").Updates(cmd).Error
 }
 
 func (c CmdNote) DeleteByID(id, uid uint) error {
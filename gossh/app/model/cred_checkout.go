@@ -0,0 +1,95 @@
+package model
+
+import "time"
+
+// CredCheckout 主机凭据签出记录,归还或到期后轮换密码
+// RotateStatus 为 pending 待轮换, ok 轮换成功, fail 轮换失败
+type CredCheckout struct {
+	ID           uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	ConfId       uint     `gorm:"not null;index" form:"conf_id" json:"conf_id"`
+	ConfName     string   `gorm:"not null;size:64;default:''" form:"conf_name" json:"conf_name"`
+	Address      string   `gorm:"not null;size:128;default:''" form:"address" json:"address"`
+	SshUser      string   `gorm:"not null;size:128;default:''" form:"ssh_user" json:"ssh_user"`
+	Uid          uint     `gorm:"not null;index" form:"uid" json:"uid"`
+	UserName     string   `gorm:"not null;size:64;default:''" form:"user_name" json:"user_name"`
+	Reason       string   `gorm:"not null;size:512;default:''" form:"reason" json:"reason"`
+	ClientIp     string   `gorm:"not null;size:128;default:''" form:"client_ip" json:"client_ip"`
+	CheckoutAt   DateTime `gorm:"checkout_at;not null" form:"checkout_at" json:"checkout_at"`
+	ExpiryAt     DateTime `gorm:"expiry_at;not null" form:"expiry_at" json:"expiry_at"`
+	ReturnedAt   DateTime `gorm:"returned_at" form:"returned_at" json:"returned_at"`
+	RotateStatus string   `gorm:"not null;size:32;default:'pending';index" form:"rotate_status" json:"rotate_status"`
+	RotateMsg    string   `gorm:"not null;size:1024;default:''" form:"rotate_msg" json:"rotate_msg"`
+	RotatedAt    DateTime `gorm:"rotated_at" form:"rotated_at" json:"rotated_at"`
+	CreatedAt    DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt    DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c CredCheckout) Create(checkout *CredCheckout) error {
+	return Db.Create(checkout).Error
+}
+
+func (c CredCheckout) FindByID(id uint) (CredCheckout, error) {
+	var checkout CredCheckout
+	err := Db.First(&checkout, "id = ?", id).Error
+	return checkout, err
+}
+
+// FindPage 分页查询,uid 不为0时只查询该用户的记录
+func (c CredCheckout) FindPage(q PageQuery, uid uint) ([]CredCheckout, int64, error) {
+	var db = ReadDb()
+	if uid != 0 {
+		db = db.Where("uid = ?", uid)
+	}
+	return findPage[CredCheckout](db, q, pageSpec{
+		Sorts: []string{"id", "conf_name", "user_name", "checkout_at", "expiry_at", "rotate_status"},
+		Filters: map[string]string{
+			"conf_id":       "eq",
+			"conf_name":     "like",
+			"address":       "like",
+			"user_name":     "like",
+			"rotate_status": "eq",
+		},
+		DefaultSort: "id desc",
+	})
+}
+
+// FindActive 查询配置当前未归还且未过期的签出
+func (c CredCheckout) FindActive(confId uint, t time.Time) (CredCheckout, error) {
+	var checkout CredCheckout
+	err := Db.Where("conf_id = ? AND returned_at IS NULL AND expiry_at > ?", confId, t).Order("id desc").Limit(1).Find(&checkout).Error
+	return checkout, err
+}
+
+// FindDue 查询已归还或已过期、等待轮换密码的签出
+func (c CredCheckout) FindDue(t time.Time) ([]CredCheckout, error) {
+	var list []CredCheckout
+	err := Db.Where("rotate_status = ? AND (returned_at IS NOT NULL OR expiry_at <= ?)", "pending", t).Order("id").Find(&list).Error
+	return list, err
+}
+
+// Return 归还凭据,已归还的记录不再更新
+func (c CredCheckout) Return(id uint, t time.Time) (int64, error) {
+	ret := Db.Model(&c).Where("id = ? AND returned_at IS NULL", id).Update("returned_at", DateTime(t))
+	return ret.RowsAffected, ret.Error
+}
+
+// UpdateRotate 更新密码轮换结果
+func (c CredCheckout) UpdateRotate(id uint, status, msg string, t time.Time) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"rotate_status": status,
+		"rotate_msg":    msg,
+		"rotated_at":    DateTime(t),
+	}).Error
+}
+
+// IsActive 未归还且未过期
+func (c CredCheckout) IsActive(t time.Time) bool {
+	return time.Time(c.ReturnedAt).IsZero() && t.Before(time.Time(c.ExpiryAt))
+}
+
+// HasPending 配置是否有等待轮换密码的签出
+func (c CredCheckout) HasPending(confId uint) (bool, error) {
+	var n int64
+	err := Db.Model(&c).Where("conf_id = ? AND rotate_status = ?", confId, "pending").Count(&n).Error
+	return n > 0, err
+}
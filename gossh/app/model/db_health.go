@@ -0,0 +1,132 @@
+package model
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"gossh/app/config"
+	"gossh/gorm"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// dbBreaker 数据库熔断器,连续失败达到阈值后断开,断开期间由探测恢复
+type dbBreaker struct {
+	mu        sync.Mutex
+	open      bool
+	failures  int
+	nextProbe time.Time
+	lastErr   string
+}
+
+var breaker = &dbBreaker{}
+
+// dbHealthConf 熔断配置,未配置时使用默认值
+func dbHealthConf() config.DbHealth {
+	conf := config.DefaultConfig.DbHealth
+	if conf.ProbeInterval <= 0 {
+		conf.ProbeInterval = time.Second * 5
+	}
+	if conf.ProbeTimeout <= 0 {
+		conf.ProbeTimeout = time.Second * 3
+	}
+	if conf.FailThreshold <= 0 {
+		conf.FailThreshold = 3
+	}
+	return conf
+}
+
+func (b *dbBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.open {
+		b.open, b.lastErr = false, ""
+		slog.Info("database available, circuit closed")
+	}
+}
+
+func (b *dbBreaker) failure(err error) {
+	conf := dbHealthConf()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastErr = err.Error()
+	if !b.open && b.failures >= conf.FailThreshold {
+		b.open = true
+		b.nextProbe = time.Now().Add(conf.ProbeInterval)
+		slog.Error("database unavailable, circuit open", "failures", b.failures, "err_msg", b.lastErr)
+	}
+}
+
+// DbAvailable 数据库是否可用,不可用时返回距离下次探测的时间
+func DbAvailable() (bool, time.Duration) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if !breaker.open && Db != nil {
+		return true, 0
+	}
+	return false, max(time.Until(breaker.nextProbe), time.Second)
+}
+
+// isConnError 连接失败、超时等说明数据库不可用的错误,SQL 错误不计入熔断
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
+
+// registerHealthCallbacks 数据库操作出现连接错误时计入熔断
+func registerHealthCallbacks(db *gorm.DB) {
+	after := func(tx *gorm.DB) {
+		if tx.Error != nil && isConnError(tx.Error) {
+			breaker.failure(tx.Error)
+		}
+	}
+	callbacks := db.Callback()
+	errs := []error{
+		callbacks.Create().After("gorm:create").Register("health:after_create", after),
+		callbacks.Query().After("gorm:query").Register("health:after_query", after),
+		callbacks.Update().After("gorm:update").Register("health:after_update", after),
+		callbacks.Delete().After("gorm:delete").Register("health:after_delete", after),
+		callbacks.Row().After("gorm:row").Register("health:after_row", after),
+		callbacks.Raw().After("gorm:raw").Register("health:after_raw", after),
+	}
+	for _, err := range errs {
+		if err != nil {
+			slog.Error("register health callback error:", "err_msg", err.Error())
+		}
+	}
+}
+
+// probeDb 探测数据库连接,启动时连接失败的会重新连接
+func probeDb() {
+	conf := dbHealthConf()
+	if Db == nil {
+		if err := DbMigrate(config.DefaultConfig.DbType, config.DefaultConfig.DbDsn); err != nil {
+			breaker.failure(err)
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ProbeTimeout)
+	defer cancel()
+	if err := DbPing(ctx); err != nil {
+		breaker.failure(err)
+		return
+	}
+	breaker.success()
+}
+
+func dbProbeLoop() {
+	for {
+		conf := dbHealthConf()
+		breaker.mu.Lock()
+		breaker.nextProbe = time.Now().Add(conf.ProbeInterval)
+		breaker.mu.Unlock()
+		time.Sleep(conf.ProbeInterval)
+		if config.DefaultConfig.IsInit {
+			probeDb()
+		}
+	}
+}
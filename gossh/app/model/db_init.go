@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"gossh/app/config"
 	"gossh/gorm"
@@ -14,6 +15,7 @@ import (
 var Db *gorm.DB
 
 func init() {
+	go dbProbeLoop()
 	if !config.DefaultConfig.IsInit {
 		slog.Warn("系统未初始化,跳过DbMigrate")
 		return
@@ -21,43 +23,63 @@ func init() {
 	err := DbMigrate(config.DefaultConfig.DbType, config.DefaultConfig.DbDsn)
 	if err != nil {
 		slog.Error("DbMigrate error", "err_msg", err.Error())
+		breaker.failure(err)
 	}
 }
 
-func DbMigrate(dbType, dsn string) error {
-	if dbType == "pgsql" {
-		db, err := gorm.Open(pgsql.Open(dsn), &gorm.Config{})
-		if err != nil {
-			return err
-		}
-		err = db.Exec("select 1=1;").Error
-		if err != nil {
-			return err
-		}
-		Db = db
-	}
-
-	if dbType == "mysql" {
-		db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-		if err != nil {
-			return err
-		}
-		err = db.Exec("select 1=1;").Error
-		if err != nil {
-			return err
-		}
-		Db = db
+// openDb 按数据库类型打开连接并检查是否可用
+func openDb(dbType, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch dbType {
+	case "pgsql":
+		dialector = pgsql.Open(dsn)
+	case "mysql":
+		dialector = mysql.Open(dsn)
+	default:
+		return nil, errors.New("请检查数据库链接")
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec("select 1=1;").Error; err != nil {
+		return nil, err
 	}
+	return db, nil
+}
 
-	if Db == nil {
-		return errors.New("请检查数据库链接")
+func DbMigrate(dbType, dsn string) error {
+	db, err := openDb(dbType, dsn)
+	if err != nil {
+		return err
 	}
+	Db = db
+	registerTraceCallbacks(Db)
+	registerHealthCallbacks(Db)
 
-	err := Db.AutoMigrate(SshConf{}, SshUser{}, CmdNote{}, NetFilter{}, PolicyConf{}, LoginAudit{})
+	err = Db.AutoMigrate(
+		SshConf{}, SshUser{}, CmdNote{}, NetFilter{}, PolicyConf{}, LoginAudit{},
+		DlpRule{}, Approval{}, SessionRecord{}, RecordRedaction{}, ChangeRequest{},
+		ApiToken{}, ShellProfile{}, SecretEvent{}, Maintenance{}, NotifyChannel{}, ImpersonateLog{}, UserPref{}, CredCheckout{}, InventorySource{}, Branding{}, Tenant{}, Quota{}, NetGroup{},
+		ClusterLease{}, ClusterSession{}, AccessLog{}, UserMacro{}, SessionEvent{}, Pipeline{}, SessionShare{},
+	)
 	if err != nil {
 		slog.Error("AutoMigrate error:", "err_msg", err.Error())
 		return err
 	}
+	openReplicas(dbType, config.DefaultConfig.Replica.Dsns)
 
 	return nil
 }
+
+// DbPing 检查数据库连接是否正常
+func DbPing(ctx context.Context) error {
+	if Db == nil {
+		return errors.New("数据库未连接")
+	}
+	sqlDb, err := Db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"gossh/app/config"
+	"gossh/gorm"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replica 只读副本的连接和最近一次检查的状态
+type replica struct {
+	db      *gorm.DB
+	healthy bool
+	lag     time.Duration
+}
+
+var (
+	replicaMu   sync.RWMutex
+	replicas    []*replica
+	replicaDsns []string
+	replicaNext atomic.Uint64
+)
+
+// ReadDb 只读查询使用的连接,轮询可用且延迟未超过 MaxLag 的副本,没有可用副本时使用主库
+func ReadDb() *gorm.DB {
+	maxLag := config.DefaultConfig.Replica.MaxLag
+	replicaMu.RLock()
+	defer replicaMu.RUnlock()
+	n := len(replicas)
+	start := int(replicaNext.Add(1) % uint64(max(n, 1)))
+	for i := 0; i < n; i++ {
+		r := replicas[(start+i)%n]
+		if r.healthy && (maxLag <= 0 || r.lag <= maxLag) {
+			return r.db
+		}
+	}
+	return Db
+}
+
+// openReplicas 按配置重新打开副本连接,连接串未变化时保留原连接
+func openReplicas(dbType string, dsns []string) {
+	replicaMu.Lock()
+	defer replicaMu.Unlock()
+	if slices.Equal(dsns, replicaDsns) {
+		return
+	}
+	for _, r := range replicas {
+		if sqlDb, err := r.db.DB(); err == nil {
+			_ = sqlDb.Close()
+		}
+	}
+	replicas, replicaDsns = nil, slices.Clone(dsns)
+	for i, dsn := range dsns {
+		db, err := openDb(dbType, dsn)
+		if err != nil {
+			slog.Error("open replica error:", "index", i, "err_msg", err.Error())
+			continue
+		}
+		registerTraceCallbacks(db)
+		replicas = append(replicas, &replica{db: db, healthy: true})
+	}
+	slog.Info("db replicas opened", "count", len(replicas))
+}
+
+// replicaLag 查询副本的复制延迟,复制已停止时返回错误
+func replicaLag(dbType string, db *gorm.DB) (time.Duration, error) {
+	if dbType == "pgsql" {
+		var seconds float64
+		err := db.Raw("SELECT CASE WHEN pg_is_in_recovery() THEN COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) ELSE 0 END").Scan(&seconds).Error
+		return time.Duration(seconds * float64(time.Second)), err
+	}
+
+	// MySQL 8.0.22 起使用 SHOW REPLICA STATUS,旧版本使用 SHOW SLAVE STATUS
+	rows, err := db.Raw("SHOW REPLICA STATUS").Rows()
+	if err != nil {
+		rows, err = db.Raw("SHOW SLAVE STATUS").Rows()
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		// 不是副本,没有复制延迟
+		return 0, rows.Err()
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, fmt.Errorf("replication stopped")
+		}
+		seconds, err := strconv.ParseInt(string(values[i]), 10, 64)
+		return time.Duration(seconds) * time.Second, err
+	}
+	return 0, nil
+}
+
+// checkReplicas 检查副本是否可用和复制延迟
+func checkReplicas() {
+	dbType := config.DefaultConfig.DbType
+	replicaMu.RLock()
+	list := slices.Clone(replicas)
+	replicaMu.RUnlock()
+	for _, r := range list {
+		lag, err := replicaLag(dbType, r.db)
+		replicaMu.Lock()
+		healthy := err == nil
+		if healthy != r.healthy {
+			slog.Warn("db replica state changed", "healthy", healthy, "lag", lag.String())
+		}
+		r.healthy, r.lag = healthy, lag
+		replicaMu.Unlock()
+		if err != nil {
+			slog.Error("check replica error:", "err_msg", err.Error())
+		}
+	}
+}
+
+func replicaCheckLoop() {
+	for {
+		interval := config.DefaultConfig.Replica.CheckInterval
+		if interval <= 0 {
+			interval = time.Second * 10
+		}
+		time.Sleep(interval)
+		if config.DefaultConfig.IsInit {
+			checkReplicas()
+		}
+	}
+}
+
+func init() {
+	go replicaCheckLoop()
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if conf.IsInit && !slices.Equal(old.Replica.Dsns, conf.Replica.Dsns) {
+			openReplicas(conf.DbType, conf.Replica.Dsns)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"gossh/app/utils"
+	"gossh/gorm"
+	"log/slog"
+	"time"
+)
+
+// 没有父 span 的数据库操作,超过该耗时才单独上报
+const dbSlowSpan = 200 * time.Millisecond
+
+const dbSpanKey = "gossh:trace_span"
+
+// registerTraceCallbacks 为数据库操作创建 span
+// 使用 Db.WithContext 传入请求上下文时作为请求追踪的子节点,否则只上报慢查询
+func registerTraceCallbacks(db *gorm.DB) {
+	before := func(op string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if !utils.TracingEnabled() {
+				return
+			}
+			_, span := utils.StartSpan(tx.Statement.Context, "db."+op, utils.SpanKindClient)
+			tx.InstanceSet(dbSpanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(dbSpanKey)
+		if !ok {
+			return
+		}
+		span, ok := value.(*utils.Span)
+		if !ok || span == nil {
+			return
+		}
+		if span.IsRoot() && span.Duration() < dbSlowSpan {
+			span.Drop()
+			return
+		}
+		span.SetAttr("db.system", tx.Dialector.Name())
+		span.SetAttr("db.collection.name", tx.Statement.Table)
+		span.SetAttr("db.query.text", utils.TruncateString(tx.Statement.SQL.String(), 2048))
+		span.SetAttr("db.response.rows_affected", tx.Statement.RowsAffected)
+		if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+			span.SetError(tx.Error)
+		}
+		span.End()
+	}
+
+	callbacks := db.Callback()
+	errs := []error{
+		callbacks.Create().Before("gorm:create").Register("trace:before_create", before("create")),
+		callbacks.Create().After("gorm:create").Register("trace:after_create", after),
+		callbacks.Query().Before("gorm:query").Register("trace:before_query", before("query")),
+		callbacks.Query().After("gorm:query").Register("trace:after_query", after),
+		callbacks.Update().Before("gorm:update").Register("trace:before_update", before("update")),
+		callbacks.Update().After("gorm:update").Register("trace:after_update", after),
+		callbacks.Delete().Before("gorm:delete").Register("trace:before_delete", before("delete")),
+		callbacks.Delete().After("gorm:delete").Register("trace:after_delete", after),
+		callbacks.Row().Before("gorm:row").Register("trace:before_row", before("row")),
+		callbacks.Row().After("gorm:row").Register("trace:after_row", after),
+		callbacks.Raw().Before("gorm:raw").Register("trace:before_raw", before("raw")),
+		callbacks.Raw().After("gorm:raw").Register("trace:after_raw", after),
+	}
+	for _, err := range errs {
+		if err != nil {
+			slog.Error("register trace callback error:", "err_msg", err.Error())
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+type DlpRule struct {
+	ID        uint     `gorm:"id;autoIncrement;primaryKey" form:"id" json:"id"`
+	Name      string   `gorm:"not null;size:128" form:"name" binding:"required,min=1,max=128" json:"name"`
+	Pattern   string   `gorm:"not null;size:512" form:"pattern" binding:"required,min=1,max=512" json:"pattern"`
+	Direction string   `gorm:"not null;size:32;default:'input'" form:"direction" binding:"required,oneof=input output" json:"direction"`
+	Action    string   `gorm:"not null;size:32;default:'flag'" form:"action" binding:"required,oneof=block flag" json:"action"`
+	IsEnable  string   `gorm:"not null;size:64;default:'Y'" form:"is_enable" binding:"required,min=1,max=64,oneof=Y N" json:"is_enable"`
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c DlpRule) Create(rule *DlpRule) error {
+	return Db.Create(rule).Error
+}
+
+func (c DlpRule) FindByID(id uint) (DlpRule, error) {
+	var rule DlpRule
+	err := Db.First(&rule, "id = ? ", id).Error
+	return rule, err
+}
+
+func (c DlpRule) FindAll(offset, limit int) ([]DlpRule, error) {
+	var list []DlpRule
+	err := ReadDb().Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c DlpRule) FindPage(q PageQuery) ([]DlpRule, int64, error) {
+	return findPage[DlpRule](Db, q, pageSpec{
+		Sorts: []string{"id", "name", "direction", "action", "updated_at"},
+		Filters: map[string]string{
+			"name":      "like",
+			"direction": "eq",
+			"action":    "eq",
+			"is_enable": "eq",
+		},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+func (c DlpRule) FindAllEnable() ([]DlpRule, error) {
+	var list []DlpRule
+	err := Db.Where("is_enable = ?", "Y").Order("id asc").Find(&list).Error
+	return list, err
+}
+
+func (c DlpRule) UpdateById(id uint, rule *DlpRule) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(rule).Error
+}
+
+func (c DlpRule) DeleteByID(id uint) error {
+	return Db.Unscoped().Delete(&c, "id = ?", id).Error
+}
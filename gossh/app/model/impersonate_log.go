@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// ImpersonateLog 管理员模拟其他用户的审计记录,EndAt 为空表示未主动结束
+type ImpersonateLog struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	AdminId   uint     `gorm:"not null;index" form:"admin_id" json:"admin_id"`
+	AdminName string   `gorm:"not null;size:64" form:"admin_name" json:"admin_name"`
+	Uid       uint     `gorm:"not null;index" form:"uid" json:"uid"`
+	UserName  string   `gorm:"not null;size:64" form:"user_name" json:"user_name"`
+	Reason    string   `gorm:"not null;size:512" form:"reason" json:"reason"`
+	ClientIp  string   `gorm:"not null;size:128" form:"client_ip" json:"client_ip"`
+	RequestId string   `gorm:"not null;size:64;default:''" form:"request_id" json:"request_id"`
+	StartAt   DateTime `gorm:"start_at;not null" form:"start_at" json:"start_at"`
+	ExpiryAt  DateTime `gorm:"expiry_at;not null" form:"expiry_at" json:"expiry_at"`
+	EndAt     DateTime `gorm:"end_at" form:"end_at" json:"end_at"`
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c ImpersonateLog) Create(log *ImpersonateLog) error {
+	return Db.Create(log).Error
+}
+
+func (c ImpersonateLog) FindByID(id uint) (ImpersonateLog, error) {
+	var log ImpersonateLog
+	err := Db.First(&log, "id = ?", id).Error
+	return log, err
+}
+
+func (c ImpersonateLog) FindAll(offset, limit int) ([]ImpersonateLog, error) {
+	var list []ImpersonateLog
+	err := ReadDb().Offset(offset).Limit(limit).Order("start_at desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c ImpersonateLog) FindPage(q PageQuery) ([]ImpersonateLog, int64, error) {
+	return findPage[ImpersonateLog](Db, q, pageSpec{
+		Sorts: []string{"id", "admin_name", "user_name", "start_at", "expiry_at", "end_at"},
+		Filters: map[string]string{
+			"admin_name": "like",
+			"user_name":  "like",
+			"client_ip":  "like",
+		},
+		DefaultSort: "start_at desc",
+	})
+}
+
+// End 结束模拟,已结束的记录不再更新
+func (c ImpersonateLog) End(id uint, t time.Time) error {
+	return Db.Model(&c).Where("id = ? AND end_at IS NULL", id).Update("end_at", DateTime(t)).Error
+}
+
+// IsActive 模拟未结束且未过期
+func (c ImpersonateLog) IsActive(t time.Time) bool {
+	return time.Time(c.EndAt).IsZero() && t.Before(time.Time(c.ExpiryAt))
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"gossh/gorm"
+	"time"
+)
+
+// InventorySource 云主机清单来源,定时将匹配过滤条件的实例同步为连接配置
+// Filter 为实例标签过滤条件,CredRules 为凭据映射规则(JSON),SyncInterval 为同步间隔(分钟),0 表示只手动同步
+type InventorySource struct {
+	ID           uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid          uint     `gorm:"not null;default:0;index" form:"-" json:"uid"`
+	Name         string   `gorm:"not null;size:64" form:"name" binding:"required,min=1,max=64" json:"name"`
+	Provider     string   `gorm:"not null;size:32" form:"provider" binding:"required,oneof=aws aliyun tencent" json:"provider"`
+	Region       string   `gorm:"not null;size:64" form:"region" binding:"required,min=1,max=64" json:"region"`
+	Endpoint     string   `gorm:"not null;size:256;default:''" form:"endpoint" binding:"max=256" json:"endpoint"`
+	AccessKey    string   `gorm:"not null;size:256" form:"access_key" binding:"required,min=1,max=256" json:"access_key"`
+	SecretKey    string   `gorm:"not null;size:256" form:"secret_key" binding:"required,min=1,max=256" json:"secret_key"`
+	Filter       string   `gorm:"type:text" form:"filter" binding:"max=4096" json:"filter"`
+	AddrType     string   `gorm:"not null;size:32;default:'private'" form:"addr_type" binding:"omitempty,oneof=private public" json:"addr_type"`
+	CredRules    string   `gorm:"type:text" form:"cred_rules" binding:"max=65535" json:"cred_rules"`
+	SyncInterval uint     `gorm:"not null;default:60" form:"sync_interval" binding:"lte=10080" json:"sync_interval"`
+	IsEnable     string   `gorm:"not null;size:64;default:'Y'" form:"is_enable" binding:"omitempty,oneof=Y N" json:"is_enable"`
+	LastSyncAt   DateTime `gorm:"last_sync_at" form:"-" json:"last_sync_at"`
+	LastStatus   string   `gorm:"not null;size:32;default:''" form:"-" json:"last_status"`
+	LastMsg      string   `gorm:"type:text" form:"-" json:"last_msg"`
+	CreatedAt    DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt    DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c InventorySource) Create(source *InventorySource) error {
+	return Db.Create(source).Error
+}
+
+func (c InventorySource) FindByID(id uint) (InventorySource, error) {
+	var source InventorySource
+	err := Db.First(&source, "id = ?", id).Error
+	return source, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c InventorySource) FindPage(q PageQuery) ([]InventorySource, int64, error) {
+	return findPage[InventorySource](Db, q, pageSpec{
+		Sorts: []string{"id", "name", "provider", "region", "last_sync_at", "updated_at"},
+		Filters: map[string]string{
+			"name":        "like",
+			"provider":    "eq",
+			"region":      "eq",
+			"is_enable":   "eq",
+			"last_status": "eq",
+		},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+// FindEnabled 查询启用了定时同步的来源
+func (c InventorySource) FindEnabled() ([]InventorySource, error) {
+	var list []InventorySource
+	err := Db.Where("is_enable = ? AND sync_interval > 0", "Y").Find(&list).Error
+	return list, err
+}
+
+// UpdateById 更新来源,允许清空过滤条件和凭据规则
+func (c InventorySource) UpdateById(id uint, source *InventorySource) error {
+	return Db.Model(&c).Where("id = ?", id).
+		Select("name", "provider", "region", "endpoint", "access_key", "secret_key", "filter", "addr_type", "cred_rules", "sync_interval", "is_enable").
+		Updates(source).Error
+}
+
+// UpdateSyncResult 记录同步结果
+func (c InventorySource) UpdateSyncResult(id uint, status, msg string, t time.Time) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"last_sync_at": DateTime(t),
+		"last_status":  status,
+		"last_msg":     msg,
+	}).Error
+}
+
+func (c InventorySource) DeleteByID(id uint) error {
+	return Db.Unscoped().Delete(&c, "id = ?", id).Error
+}
+
+// FindByInventory 查询来源同步的配置,包含回收站中的配置,用户删除的实例不再重新创建
+func (c SshConf) FindByInventory(inventoryId uint) ([]SshConf, error) {
+	var list []SshConf
+	err := Db.Unscoped().Where("inventory_id = ?", inventoryId).Find(&list).Error
+	return list, err
+}
+
+// UpdateInventory 更新清单同步的字段
+func (c SshConf) UpdateInventory(id uint, fields map[string]any) error {
+	fields["version"] = gorm.Expr("version + 1")
+	return Db.Model(&c).Where("id = ?", id).Updates(fields).Error
+}
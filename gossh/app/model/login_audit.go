@@ -9,6 +9,11 @@ type LoginAudit struct {
 	ErrMsg    string   `gorm:"size:64" form:"err_msg" binding:"required,min=1,max=64" json:"err_msg"`
 	IsSuccess string   `gorm:"not null;size:64;default:'N'" form:"is_success" binding:"required,min=1,max=64,oneof=Y N" json:"is_success"`
 	OccurAt   DateTime `gorm:"occur_at;not null"  json:"occur_at"  form:"occur_at" binding:"required"`
+	Country   string   `gorm:"not null;size:64;default:''" form:"country" json:"country"`
+	City      string   `gorm:"not null;size:64;default:''" form:"city" json:"city"`
+	Asn       string   `gorm:"not null;size:128;default:''" form:"asn" json:"asn"`
+	RequestId string   `gorm:"not null;size:64;default:'';index" form:"request_id" json:"request_id"`
+	TenantId  uint     `gorm:"not null;default:0;index" form:"tenant_id" json:"tenant_id"`
 
 	CreatedAt DateTime `gorm:"created_at" json:"-"`
 	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
@@ -19,11 +24,11 @@ func (c LoginAudit) Create(audit *LoginAudit) error {
 }
 
 func (c LoginAudit) Search(
-	isSuccess, name, clientIp string,
+	tenantId uint, isSuccess, name, clientIp string,
 	occurBegin, occurEnd DateTime, offset, limit int,
 ) ([]LoginAudit, int64, error) {
 	var list []LoginAudit
-	var db = Db
+	var db = ReadDb().Where("tenant_id = ?", tenantId)
 	if isSuccess != "" {
 		db = db.Where("is_success = ?", isSuccess)
 	}
@@ -54,3 +59,12 @@ func (c LoginAudit) FindByID(id uint) (LoginAudit, error) {
 func (c LoginAudit) DeleteByID(id uint) error {
 	return Db.Unscoped().Delete(&c, "id = ? AND is_root = ?", id, "N").Error
 }
+
+// HasSuccess 用户是否使用过该客户端成功登录
+func (c LoginAudit) HasSuccess(name, userAgent string) (bool, error) {
+	var count int64
+	err := Db.Model(&LoginAudit{}).
+		Where("name = ? AND user_agent = ? AND is_success = ?", name, userAgent, "Y").
+		Count(&count).Error
+	return count > 0, err
+}
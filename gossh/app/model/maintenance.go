@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Maintenance 计划维护公告,在状态页展示
+type Maintenance struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Title     string   `gorm:"not null;size:128" form:"title" binding:"required,min=1,max=128" json:"title"`
+	Content   string   `gorm:"type:text" form:"content" binding:"max=4096" json:"content"`
+	StartAt   DateTime `gorm:"start_at;not null" form:"start_at" binding:"required" json:"start_at"`
+	EndAt     DateTime `gorm:"end_at;not null" form:"end_at" binding:"required" json:"end_at"`
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c Maintenance) Create(maintenance *Maintenance) error {
+	return Db.Create(maintenance).Error
+}
+
+func (c Maintenance) FindByID(id uint) (Maintenance, error) {
+	var maintenance Maintenance
+	err := Db.First(&maintenance, "id = ?", id).Error
+	return maintenance, err
+}
+
+func (c Maintenance) FindAll(offset, limit int) ([]Maintenance, error) {
+	var list []Maintenance
+	err := ReadDb().Offset(offset).Limit(limit).Order("start_at desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c Maintenance) FindPage(q PageQuery) ([]Maintenance, int64, error) {
+	return findPage[Maintenance](Db, q, pageSpec{
+		Sorts: []string{"id", "title", "start_at", "end_at"},
+		Filters: map[string]string{
+			"title": "like",
+		},
+		DefaultSort: "start_at desc",
+	})
+}
+
+// FindUpcoming 查询进行中和未开始的维护
+func (c Maintenance) FindUpcoming(t time.Time) ([]Maintenance, error) {
+	var list []Maintenance
+	err := Db.Where("end_at > ?", t).Order("start_at asc").Find(&list).Error
+	return list, err
+}
+
+func (c Maintenance) UpdateById(id uint, maintenance *Maintenance) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(maintenance).Error
+}
+
+func (c Maintenance) DeleteByID(id uint) error {
+	return Db.Unscoped().Delete(&c, "id = ?", id).Error
+}
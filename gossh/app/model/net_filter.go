@@ -2,15 +2,21 @@ package model
 
 import "time"
 
+// NetFilter 网络过滤规则,按 PolicyNo 从小到大匹配,第一条命中的规则决定是否允许访问
+// 规则可以配置 CIDR、单个IP、地址组或国家代码,命中任意一个即可
+// Action 为 auto 时白名单规则允许访问,黑名单规则拒绝访问
 type NetFilter struct {
-	ID        uint     `gorm:"id;autoIncrement;primaryKey" form:"id" json:"id"`
-	Name      string   `gorm:"not null;name" form:"name" json:"name" binding:"required"`
-	Cidr      string   `gorm:"not null;cidr" form:"cidr" json:"cidr" binding:"required,cidr"`
-	NetPolicy string   `gorm:"not null;size:64;default:'Y'" form:"net_policy" binding:"required,min=1,max=64,oneof=Y N" json:"net_policy"`
-	PolicyNo  uint     `gorm:"not null;" form:"policy_no" json:"policy_no" binding:"required,gte=1,lte=65535"`
-	ExpiryAt  DateTime `gorm:"not null;expiry_at"  json:"expiry_at"  form:"expiry_at" binding:"required"`
-	CreatedAt DateTime `gorm:"created_at" json:"-"`
-	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+	ID          uint     `gorm:"id;autoIncrement;primaryKey" form:"id" json:"id"`
+	Name        string   `gorm:"not null;name" form:"name" json:"name" binding:"required"`
+	Cidr        string   `gorm:"not null;cidr" form:"cidr" json:"cidr" binding:"required_without_all=CountryCode GroupId,omitempty,cidr|ip"`
+	CountryCode string   `gorm:"not null;size:8;default:''" form:"country_code" json:"country_code" binding:"required_without_all=Cidr GroupId,omitempty,len=2,alpha"`
+	GroupId     uint     `gorm:"not null;default:0;index" form:"group_id" json:"group_id"`
+	Action      string   `gorm:"not null;size:16;default:'auto'" form:"action" binding:"omitempty,oneof=auto allow deny" json:"action"`
+	NetPolicy   string   `gorm:"not null;size:64;default:'Y'" form:"net_policy" binding:"required,min=1,max=64,oneof=Y N" json:"net_policy"`
+	PolicyNo    uint     `gorm:"not null;" form:"policy_no" json:"policy_no" binding:"required,gte=1,lte=65535"`
+	ExpiryAt    DateTime `gorm:"not null;expiry_at"  json:"expiry_at"  form:"expiry_at" binding:"required"`
+	CreatedAt   DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt   DateTime `gorm:"updated_at" json:"-"`
 }
 
 func (c NetFilter) Create(filter *NetFilter) error {
@@ -31,10 +37,26 @@ func (c NetFilter) FindByID(id uint) (NetFilter, error) {
 
 func (c NetFilter) FindAll(offset, limit int) ([]NetFilter, error) {
 	var list []NetFilter
-	err := Db.Offset(offset).Limit(limit).Order("policy_no asc, expiry_at, updated_at desc").Find(&list).Error
+	err := ReadDb().Offset(offset).Limit(limit).Order("policy_no asc, expiry_at, updated_at desc").Find(&list).Error
 	return list, err
 }
 
+// FindPage 分页查询,返回当前页数据和总数
+func (c NetFilter) FindPage(q PageQuery) ([]NetFilter, int64, error) {
+	return findPage[NetFilter](Db, q, pageSpec{
+		Sorts: []string{"id", "name", "cidr", "country_code", "policy_no", "expiry_at", "updated_at"},
+		Filters: map[string]string{
+			"name":         "like",
+			"cidr":         "like",
+			"country_code": "eq",
+			"net_policy":   "eq",
+			"group_id":     "eq",
+			"action":       "eq",
+		},
+		DefaultSort: "policy_no asc, expiry_at, updated_at desc",
+	})
+}
+
 func (c NetFilter) FindAllPolicy(policy string) ([]NetFilter, error) {
 	var list []NetFilter
 	err := Db.Where("net_policy = ? AND expiry_at > ?", policy, time.Now()).Order("policy_no asc, expiry_at, updated_at desc").Find(&list).Error
@@ -42,7 +64,16 @@ func (c NetFilter) FindAllPolicy(policy string) ([]NetFilter, error) {
 }
 
 func (c NetFilter) UpdateById(id uint, filter *NetFilter) error {
-	return Db.Model(&c).Where("id = ?", id).Updates(filter).Error
+	return Db.Model(&c).Where("id = ?", id).
+		Select("name", "cidr", "country_code", "group_id", "action", "net_policy", "policy_no", "expiry_at").
+		Updates(filter).Error
+}
+
+// CountByGroup 引用地址组的规则数量
+func (c NetFilter) CountByGroup(groupId uint) (int64, error) {
+	var count int64
+	err := Db.Model(&c).Where("group_id = ?", groupId).Count(&count).Error
+	return count, err
 }
 
 func (c NetFilter) DeleteByID(id uint) error {
@@ -0,0 +1,60 @@
+package model
+
+import "strings"
+
+// NetGroup 地址组,Cidrs 为按行或逗号分隔的 CIDR 或 IP,网络过滤规则可以引用地址组
+type NetGroup struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Name      string   `gorm:"not null;size:64;uniqueIndex" form:"name" binding:"required,min=1,max=64" json:"name"`
+	Cidrs     string   `gorm:"type:text" form:"cidrs" binding:"required,max=65535" json:"cidrs"`
+	DescInfo  string   `gorm:"not null;size:255;default:''" form:"desc_info" binding:"max=255" json:"desc_info"`
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+// Entries 地址组中的 CIDR 或 IP 列表
+func (c NetGroup) Entries() []string {
+	var list []string
+	for _, item := range strings.FieldsFunc(c.Cidrs, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (c NetGroup) Create(group *NetGroup) error {
+	return Db.Create(group).Error
+}
+
+func (c NetGroup) FindByID(id uint) (NetGroup, error) {
+	var group NetGroup
+	err := Db.First(&group, "id = ?", id).Error
+	return group, err
+}
+
+func (c NetGroup) FindAll() ([]NetGroup, error) {
+	var list []NetGroup
+	err := ReadDb().Order("name asc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c NetGroup) FindPage(q PageQuery) ([]NetGroup, int64, error) {
+	return findPage[NetGroup](Db, q, pageSpec{
+		Sorts: []string{"id", "name", "updated_at"},
+		Filters: map[string]string{
+			"name":  "like",
+			"cidrs": "like",
+		},
+		DefaultSort: "name asc",
+	})
+}
+
+func (c NetGroup) UpdateById(id uint, group *NetGroup) error {
+	return Db.Model(&c).Where("id = ?", id).Select("name", "cidrs", "desc_info").Updates(group).Error
+}
+
+func (c NetGroup) DeleteByID(id uint) error {
+	return Db.Unscoped().Delete(&c, "id = ?", id).Error
+}
@@ -0,0 +1,75 @@
+package model
+
+import "strings"
+
+// NotifyChannel 通知渠道,Events 为订阅的事件,多个事件用逗号分隔
+// Target 为 Webhook 地址,邮件渠道为收件人地址,多个收件人用逗号分隔
+type NotifyChannel struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Name      string   `gorm:"not null;size:128" form:"name" binding:"required,min=1,max=128" json:"name"`
+	Type      string   `gorm:"not null;size:64" form:"type" binding:"required,oneof=email slack dingtalk wecom" json:"type"`
+	Target    string   `gorm:"not null;size:1024" form:"target" binding:"required,min=1,max=1024" json:"target"`
+	Secret    string   `gorm:"not null;size:256;default:''" form:"secret" binding:"max=256" json:"secret"`
+	Events    string   `gorm:"not null;size:512;default:''" form:"events" binding:"max=512" json:"events"`
+	IsEnable  string   `gorm:"not null;size:64;default:'Y'" form:"is_enable" binding:"omitempty,oneof=Y N" json:"is_enable"`
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c NotifyChannel) Create(channel *NotifyChannel) error {
+	return Db.Create(channel).Error
+}
+
+func (c NotifyChannel) FindByID(id uint) (NotifyChannel, error) {
+	var channel NotifyChannel
+	err := Db.First(&channel, "id = ?", id).Error
+	return channel, err
+}
+
+func (c NotifyChannel) FindAll(offset, limit int) ([]NotifyChannel, error) {
+	var list []NotifyChannel
+	err := ReadDb().Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c NotifyChannel) FindPage(q PageQuery) ([]NotifyChannel, int64, error) {
+	return findPage[NotifyChannel](Db, q, pageSpec{
+		Sorts: []string{"id", "name", "type", "updated_at"},
+		Filters: map[string]string{
+			"name":      "like",
+			"type":      "eq",
+			"is_enable": "eq",
+		},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+// FindByEvent 查询订阅了事件的启用渠道
+func (c NotifyChannel) FindByEvent(event string) ([]NotifyChannel, error) {
+	var list []NotifyChannel
+	if err := Db.Where("is_enable = ?", "Y").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	var ret []NotifyChannel
+	for _, channel := range list {
+		for _, e := range strings.Split(channel.Events, ",") {
+			if strings.TrimSpace(e) == event {
+				ret = append(ret, channel)
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+// UpdateById 更新渠道,允许清空密钥和订阅事件
+func (c NotifyChannel) UpdateById(id uint, channel *NotifyChannel) error {
+	return Db.Model(&c).Where("id = ?", id).
+		Select("name", "type", "target", "secret", "events", "is_enable").
+		Updates(channel).Error
+}
+
+func (c NotifyChannel) DeleteByID(id uint) error {
+	return Db.Unscoped().Delete(&c, "id = ?", id).Error
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"gossh/gorm"
+	"sort"
+	"strings"
+)
+
+// PageQuery 列表查询的分页、排序和过滤条件
+// Sort 为排序字段,以 - 开头表示倒序,Filters 为字段过滤条件
+type PageQuery struct {
+	Offset  int
+	Limit   int
+	Sort    string
+	Filters map[string]string
+}
+
+// pageSpec 列表允许排序和过滤的字段
+// Filters 的值为 eq 表示精确匹配,like 表示包含匹配
+type pageSpec struct {
+	Sorts       []string
+	Filters     map[string]string
+	DefaultSort string
+}
+
+// findPage 按条件查询一页数据和总数,未允许的排序和过滤字段会被忽略
+func findPage[T any](db *gorm.DB, q PageQuery, spec pageSpec) ([]T, int64, error) {
+	var list []T
+	var model T
+	db = db.Model(&model)
+	names := make([]string, 0, len(q.Filters))
+	for name := range q.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch spec.Filters[name] {
+		case "eq":
+			db = db.Where(name+" = ?", q.Filters[name])
+		case "like":
+			db = db.Where(likeAny(name), likePattern(q.Filters[name]))
+		}
+	}
+
+	// 复用查询条件,统计总数和查询列表互不影响
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return list, total, err
+	}
+
+	order := spec.DefaultSort
+	column, desc := strings.TrimPrefix(q.Sort, "-"), strings.HasPrefix(q.Sort, "-")
+	for _, name := range spec.Sorts {
+		if name == column {
+			order = column + " asc"
+			if desc {
+				order = column + " desc"
+			}
+			break
+		}
+	}
+	if order != "" {
+		db = db.Order(order)
+	}
+	err := db.Offset(q.Offset).Limit(q.Limit).Find(&list).Error
+	return list, total, err
+}
@@ -0,0 +1,43 @@
+package model
+
+// Pipeline 用户定义的多步骤运维流程,Steps 为 JSON 格式的步骤列表,按顺序在每台主机上执行
+type Pipeline struct {
+	ID     uint   `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid    uint   `gorm:"not null;default:0;index" form:"uid" json:"uid"`
+	Name   string `gorm:"not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
+	Remark string `gorm:"not null;size:512;default:''" form:"remark" binding:"max=511" json:"remark"`
+	Steps  string `gorm:"type:text" form:"steps" binding:"required,max=1048576" json:"steps"`
+
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c Pipeline) Create(pipeline *Pipeline) error {
+	return Db.Create(pipeline).Error
+}
+
+func (c Pipeline) FindByID(id uint, uid uint) (Pipeline, error) {
+	var pipeline Pipeline
+	err := Db.First(&pipeline, "id = ? AND uid = ?", id, uid).Error
+	return pipeline, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c Pipeline) FindPage(q PageQuery, uid uint) ([]Pipeline, int64, error) {
+	return findPage[Pipeline](ReadDb().Where("uid = ?", uid), q, pageSpec{
+		Sorts: []string{"id", "name", "updated_at"},
+		Filters: map[string]string{
+			"name":   "like",
+			"remark": "like",
+		},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+func (c Pipeline) UpdateById(id, uid uint, pipeline *Pipeline) error {
+	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Select("name", "remark", "steps").Updates(pipeline).Error
+}
+
+func (c Pipeline) DeleteByID(id, uid uint) error {
+	return Db.Unscoped().Delete(&c, "id = ? AND uid = ?", id, uid).Error
+}
@@ -1,14 +1,48 @@
 package model
 
+import (
+	"gossh/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type PolicyConf struct {
-	ID        uint     `gorm:"id;autoIncrement;primaryKey" form:"id" json:"id"`
-	NetPolicy string   `gorm:"not null;size:64;default:'Y'" form:"net_policy" binding:"required,min=1,max=64,oneof=Y N" json:"net_policy"`
-	CreatedAt DateTime `gorm:"created_at" json:"-"`
-	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+	ID              uint     `gorm:"id;autoIncrement;primaryKey" form:"id" json:"id"`
+	NetPolicy       string   `gorm:"not null;size:64;default:'Y'" form:"net_policy" binding:"required,min=1,max=64,oneof=Y N" json:"net_policy"`
+	NetDefault      string   `gorm:"not null;size:16;default:'auto'" form:"net_default" binding:"omitempty,oneof=auto allow deny" json:"net_default"`
+	Watermark       string   `gorm:"not null;size:64;default:'N'" form:"watermark" binding:"omitempty,oneof=Y N" json:"watermark"`
+	NeedApproval    string   `gorm:"not null;size:64;default:'N'" form:"need_approval" binding:"omitempty,oneof=Y N" json:"need_approval"`
+	RecordSession   string   `gorm:"not null;size:64;default:'N'" form:"record_session" binding:"omitempty,oneof=Y N" json:"record_session"`
+	AccessWeekdays  string   `gorm:"not null;size:64;default:'0,1,2,3,4,5,6'" form:"access_weekdays" binding:"max=64" json:"access_weekdays"`
+	AccessTimeBegin string   `gorm:"not null;size:8;default:'00:00'" form:"access_time_begin" binding:"omitempty,datetime=15:04" json:"access_time_begin"`
+	AccessTimeEnd   string   `gorm:"not null;size:8;default:'00:00'" form:"access_time_end" binding:"omitempty,datetime=15:04" json:"access_time_end"`
+	TimeZone        string   `gorm:"not null;size:64;default:''" form:"time_zone" binding:"max=64" json:"time_zone"`
+	PeerReview      string   `gorm:"not null;size:64;default:'N'" form:"peer_review" binding:"omitempty,oneof=Y N" json:"peer_review"`
+	SecretScan      string   `gorm:"not null;size:64;default:'N'" form:"secret_scan" binding:"omitempty,oneof=Y N" json:"secret_scan"`
+	ProdConfirm     string   `gorm:"not null;size:64;default:'N'" form:"prod_confirm" binding:"omitempty,oneof=Y N" json:"prod_confirm"`
+	ExecTimeout     uint     `gorm:"not null;default:0" form:"exec_timeout" binding:"lte=86400" json:"exec_timeout"`
+	ExecMaxTimeout  uint     `gorm:"not null;default:0" form:"exec_max_timeout" binding:"lte=86400" json:"exec_max_timeout"`
+	ScrollbackSize  uint     `gorm:"not null;default:0" form:"scrollback_size" binding:"lte=10240" json:"scrollback_size"`
+	LongSession     uint     `gorm:"not null;default:0" form:"long_session" binding:"lte=10080" json:"long_session"`
+	LockIdle        uint     `gorm:"not null;default:0" form:"lock_idle" binding:"lte=1440" json:"lock_idle"`
+	SftpPaths       string   `gorm:"type:text" form:"sftp_paths" binding:"max=4096" json:"sftp_paths"`
+	SftpReadOnly    string   `gorm:"not null;size:64;default:'N'" form:"sftp_read_only" binding:"omitempty,oneof=Y N" json:"sftp_read_only"`
+	TargetTags      string   `gorm:"type:text" form:"target_tags" binding:"max=4096" json:"target_tags"`
+	ProdTags        string   `gorm:"type:text" form:"prod_tags" binding:"max=4096" json:"prod_tags"`
+	ProdBanner      string   `gorm:"not null;size:256;default:''" form:"prod_banner" binding:"max=255" json:"prod_banner"`
+	AlgoDeny        string   `gorm:"type:text" form:"algo_deny" binding:"max=4096" json:"algo_deny"`
+	TenantId        uint     `gorm:"not null;default:0;index" form:"tenant_id" json:"tenant_id"`
+	Version         uint     `gorm:"not null;default:0" form:"version" json:"version"`
+	CreatedAt       DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt       DateTime `gorm:"updated_at" json:"-"`
 }
 
+// policyDedicatedFields 只能通过专门接口设置的字段,通用的新增和修改不写入
+var policyDedicatedFields = []string{"sftp_paths", "sftp_read_only", "algo_deny", "prod_tags", "prod_banner", "prod_confirm", "target_tags"}
+
 func (c PolicyConf) Create(conf *PolicyConf) error {
-	return Db.Create(conf).Error
+	return Db.Omit(policyDedicatedFields...).Create(conf).Error
 }
 
 func (c PolicyConf) FindByID(id uint) (PolicyConf, error) {
@@ -19,14 +53,110 @@ func (c PolicyConf) FindByID(id uint) (PolicyConf, error) {
 
 func (c PolicyConf) FindAll(offset, limit int) ([]PolicyConf, error) {
 	var list []PolicyConf
-	err := Db.Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
+	err := ReadDb().Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
 	return list, err
 }
 
+// FindByTenant 查询租户生效的策略,租户没有自己的策略时使用默认策略(ID 为 1)
+func (c PolicyConf) FindByTenant(tenantId uint) (PolicyConf, error) {
+	if tenantId != 0 {
+		var list []PolicyConf
+		if err := Db.Where("tenant_id = ?", tenantId).Order("id").Limit(1).Find(&list).Error; err != nil {
+			return PolicyConf{}, err
+		}
+		if len(list) > 0 {
+			return list[0], nil
+		}
+	}
+	return c.FindByID(1)
+}
+
+// FindPage 分页查询租户下的策略,返回当前页数据和总数
+func (c PolicyConf) FindPage(q PageQuery, tenantId uint) ([]PolicyConf, int64, error) {
+	return findPage[PolicyConf](ReadDb().Where("tenant_id = ?", tenantId), q, pageSpec{
+		Sorts:       []string{"id", "updated_at"},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+// UpdateById 乐观锁更新,版本号不一致时返回 ErrVersionConflict
 func (c PolicyConf) UpdateById(id uint, conf *PolicyConf) error {
-	return Db.Model(&c).Where("id = ?", id).Updates(conf).Error
+	return updateVersioned(Db.Model(&c).Where("id = ?", id).Omit(policyDedicatedFields...), conf, &conf.Version)
+}
+
+// UpdateSftp 更新 SFTP 路径限制,允许清空路径
+func (c PolicyConf) UpdateSftp(id uint, paths, readOnly string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"sftp_paths":     paths,
+		"sftp_read_only": readOnly,
+		"version":        gorm.Expr("version + 1"),
+	}).Error
+}
+
+// UpdateTargetTags 设置主机策略生效的标签表达式,为空时对所有主机生效
+func (c PolicyConf) UpdateTargetTags(id uint, expr string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"target_tags": expr,
+		"version":     gorm.Expr("version + 1"),
+	}).Error
+}
+
+// UpdateProd 更新生产环境主机的标签表达式、提示内容和是否需要确认,允许清空
+func (c PolicyConf) UpdateProd(id uint, expr, banner, confirm string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"prod_tags":    expr,
+		"prod_banner":  banner,
+		"prod_confirm": confirm,
+		"version":      gorm.Expr("version + 1"),
+	}).Error
+}
+
+// UpdateAlgoDeny 设置禁用的连接算法,可以清空
+func (c PolicyConf) UpdateAlgoDeny(id uint, deny string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"algo_deny": deny,
+		"version":   gorm.Expr("version + 1"),
+	}).Error
 }
 
 func (c PolicyConf) DeleteByID(id uint) error {
 	return Db.Unscoped().Delete(&c, "id = ?", id).Error
 }
+
+// DeleteByTenant 删除租户的全部策略,默认策略不会被删除
+func (c PolicyConf) DeleteByTenant(tenantId uint) error {
+	return Db.Unscoped().Delete(&c, "tenant_id = ? AND tenant_id <> 0 AND id <> 1", tenantId).Error
+}
+
+// InAccessWindow 判断时间是否在允许访问的星期和时间段内,开始时间和结束时间相同表示全天
+func (c PolicyConf) InAccessWindow(t time.Time) bool {
+	if c.TimeZone != "" {
+		if loc, err := time.LoadLocation(c.TimeZone); err == nil {
+			t = t.In(loc)
+		}
+	}
+
+	if c.AccessWeekdays != "" {
+		weekday := strconv.Itoa(int(t.Weekday()))
+		allow := false
+		for _, day := range strings.Split(c.AccessWeekdays, ",") {
+			if strings.TrimSpace(day) == weekday {
+				allow = true
+				break
+			}
+		}
+		if !allow {
+			return false
+		}
+	}
+
+	if c.AccessTimeBegin == c.AccessTimeEnd {
+		return true
+	}
+	now := t.Format("15:04")
+	if c.AccessTimeBegin < c.AccessTimeEnd {
+		return now >= c.AccessTimeBegin && now < c.AccessTimeEnd
+	}
+	// 跨天的时间段,如 22:00-06:00
+	return now >= c.AccessTimeBegin || now < c.AccessTimeEnd
+}
@@ -0,0 +1,65 @@
+package model
+
+import "errors"
+
+// 配额的适用范围
+const (
+	QuotaScopeTenant = "tenant"
+	QuotaScopeUser   = "user"
+)
+
+// Quota 租户或用户的资源配额,每个租户或用户一条记录,0 表示不限制
+// MaxHosts 为主机配置数量,MaxSessions 为同时在线的会话数量,StorageQuota 为录像占用的存储空间(字节)
+type Quota struct {
+	ID           uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Scope        string   `gorm:"not null;size:16;uniqueIndex:idx_quota_target" form:"scope" binding:"required,oneof=tenant user" json:"scope"`
+	TargetId     uint     `gorm:"not null;uniqueIndex:idx_quota_target" form:"target_id" json:"target_id"`
+	MaxHosts     int      `gorm:"not null;default:0" form:"max_hosts" binding:"gte=0" json:"max_hosts"`
+	MaxSessions  int      `gorm:"not null;default:0" form:"max_sessions" binding:"gte=0" json:"max_sessions"`
+	StorageQuota int64    `gorm:"not null;default:0" form:"storage_quota" binding:"gte=0" json:"storage_quota"`
+	CreatedAt    DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt    DateTime `gorm:"updated_at" json:"-"`
+}
+
+// Find 查询配额,没有设置时返回不限制
+func (c Quota) Find(scope string, targetId uint) (Quota, error) {
+	var list []Quota
+	if err := Db.Where("scope = ? AND target_id = ?", scope, targetId).Limit(1).Find(&list).Error; err != nil {
+		return Quota{}, err
+	}
+	if len(list) == 0 {
+		return Quota{Scope: scope, TargetId: targetId}, nil
+	}
+	return list[0], nil
+}
+
+// FindByScope 查询某一范围的全部配额
+func (c Quota) FindByScope(scope string) ([]Quota, error) {
+	var list []Quota
+	err := Db.Where("scope = ?", scope).Order("target_id").Find(&list).Error
+	return list, err
+}
+
+// Save 保存配额,不存在时创建
+func (c Quota) Save(quota *Quota) error {
+	if quota.Scope == "" {
+		return errors.New("scope is empty")
+	}
+	var count int64
+	if err := Db.Model(&Quota{}).Where("scope = ? AND target_id = ?", quota.Scope, quota.TargetId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		quota.ID = 0
+		return Db.Create(quota).Error
+	}
+	// 指定字段更新,零值也会写入
+	return Db.Model(&Quota{}).Where("scope = ? AND target_id = ?", quota.Scope, quota.TargetId).
+		Select("max_hosts", "max_sessions", "storage_quota").
+		Updates(quota).Error
+}
+
+// Delete 删除配额,恢复为不限制
+func (c Quota) Delete(scope string, targetId uint) error {
+	return Db.Delete(&c, "scope = ? AND target_id = ?", scope, targetId).Error
+}
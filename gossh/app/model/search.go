@@ -0,0 +1,57 @@
+package model
+
+import "strings"
+
+// likePattern 生成包含匹配的 LIKE 条件,使用 ! 转义通配符,兼容 MySQL 和 PostgreSQL
+func likePattern(keyword string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return "%" + r.Replace(strings.ToLower(keyword)) + "%"
+}
+
+// likeAny 多个字段任一匹配,不区分大小写
+func likeAny(columns ...string) string {
+	conds := make([]string, len(columns))
+	for i, column := range columns {
+		conds[i] = "LOWER(" + column + ") LIKE ? ESCAPE '!'"
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
+func repeatArg(arg any, n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = arg
+	}
+	return args
+}
+
+// Search 搜索用户的主机配置
+func (c SshConf) Search(uid uint, keyword string, limit int) ([]SshConf, error) {
+	var list []SshConf
+	columns := []string{"name", "address", "group_name", "external_id", "tags"}
+	err := ReadDb().Select("id", "name", "address", "port", "user", "group_name", "environment", "tags", "updated_at").
+		Where("uid = ?", uid).
+		Where(likeAny(columns...), repeatArg(likePattern(keyword), len(columns))...).
+		Order("updated_at desc").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// Search 搜索用户的命令收藏
+func (c CmdNote) Search(uid uint, keyword string, limit int) ([]CmdNote, error) {
+	var list []CmdNote
+	columns := []string{"cmd_name", "cmd_data", "folder"}
+	err := ReadDb().Where("uid = ?", uid).
+		Where(likeAny(columns...), repeatArg(likePattern(keyword), len(columns))...).
+		Order("updated_at desc").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// SearchText 搜索租户的登录审计日志
+func (c LoginAudit) SearchText(tenantId uint, keyword string, limit int) ([]LoginAudit, error) {
+	var list []LoginAudit
+	columns := []string{"name", "client_ip", "user_agent", "err_msg", "country", "city"}
+	err := ReadDb().Omit("pwd").Where("tenant_id = ?", tenantId).
+		Where(likeAny(columns...), repeatArg(likePattern(keyword), len(columns))...).
+		Order("occur_at desc").Limit(limit).Find(&list).Error
+	return list, err
+}
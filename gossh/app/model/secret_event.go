@@ -0,0 +1,40 @@
+package model
+
+type SecretEvent struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid       uint     `gorm:"not null;default:0;index" json:"uid"`
+	UserName  string   `gorm:"not null;size:64;default:''" json:"user_name"`
+	ConfId    uint     `gorm:"not null;default:0" json:"conf_id"`
+	SessionId string   `gorm:"not null;size:128;default:''" json:"session_id"`
+	Host      string   `gorm:"not null;size:128;default:''" json:"host"`
+	ClientIp  string   `gorm:"not null;size:128;default:''" json:"client_ip"`
+	Kind      string   `gorm:"not null;size:64;default:''" json:"kind"`
+	Masked    string   `gorm:"not null;size:256;default:''" json:"masked"`
+	CreatedAt DateTime `gorm:"created_at" json:"created_at"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c SecretEvent) Create(event *SecretEvent) error {
+	return Db.Create(event).Error
+}
+
+func (c SecretEvent) FindAll(offset, limit int) ([]SecretEvent, error) {
+	var list []SecretEvent
+	err := ReadDb().Offset(offset).Limit(limit).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c SecretEvent) FindPage(q PageQuery) ([]SecretEvent, int64, error) {
+	return findPage[SecretEvent](Db, q, pageSpec{
+		Sorts: []string{"id", "user_name", "host", "kind", "created_at"},
+		Filters: map[string]string{
+			"user_name":  "like",
+			"host":       "like",
+			"client_ip":  "like",
+			"kind":       "eq",
+			"session_id": "eq",
+		},
+		DefaultSort: "id desc",
+	})
+}
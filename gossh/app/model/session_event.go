@@ -0,0 +1,39 @@
+package model
+
+// 会话时间线事件类型
+const (
+	SessionEventConnect    = "connect"
+	SessionEventResize     = "resize"
+	SessionEventTraffic    = "traffic"
+	SessionEventCommand    = "command"
+	SessionEventPrivilege  = "privilege"
+	SessionEventLock       = "lock"
+	SessionEventUnlock     = "unlock"
+	SessionEventDisconnect = "disconnect"
+	SessionEventShare      = "share"
+	SessionEventShareView  = "share_view"
+)
+
+// SessionEvent 会话时间线事件,traffic 事件为一分钟内的输入输出字节数,OccurAt 为该分钟的开始时间
+type SessionEvent struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	SessionId string   `gorm:"not null;size:128;index" json:"session_id"`
+	Uid       uint     `gorm:"not null;default:0;index" json:"uid"`
+	Kind      string   `gorm:"not null;size:16" json:"kind"`
+	Detail    string   `gorm:"not null;size:1024;default:''" json:"detail"`
+	BytesIn   int64    `gorm:"not null;default:0" json:"bytes_in"`
+	BytesOut  int64    `gorm:"not null;default:0" json:"bytes_out"`
+	OccurAt   DateTime `gorm:"not null;index" json:"occur_at"`
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+}
+
+func (c SessionEvent) Create(event *SessionEvent) error {
+	return Db.Create(event).Error
+}
+
+// FindBySessionId 会话的全部事件,按发生时间排序
+func (c SessionEvent) FindBySessionId(sessionId string) ([]SessionEvent, error) {
+	var list []SessionEvent
+	err := ReadDb().Where("session_id = ?", sessionId).Order("occur_at, id").Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,186 @@
+package model
+
+import "time"
+
+type SessionRecord struct {
+	ID         uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid        uint     `gorm:"not null;default:0" form:"uid" json:"uid"`
+	UserName   string   `gorm:"not null;size:64;default:''" form:"user_name" json:"user_name"`
+	SessionId  string   `gorm:"not null;size:128;index" form:"session_id" json:"session_id"`
+	ClientIp   string   `gorm:"size:128" form:"client_ip" json:"client_ip"`
+	Address    string   `gorm:"size:128" form:"address" json:"address"`
+	SshUser    string   `gorm:"size:128" form:"ssh_user" json:"ssh_user"`
+	Port       uint16   `gorm:"not null;default:22" form:"port" json:"port"`
+	FilePath   string   `gorm:"not null;size:1024" form:"file_path" json:"-"`
+	Storage    string   `gorm:"not null;size:32;default:'local'" form:"storage" json:"storage"`
+	Size       int64    `gorm:"not null;default:0" form:"size" json:"size"`
+	Redacted   string   `gorm:"not null;size:64;default:'N'" form:"redacted" json:"redacted"`
+	Privileged string   `gorm:"not null;size:64;default:'N'" form:"privileged" json:"privileged"`
+	PrivUsers  string   `gorm:"not null;size:255;default:''" form:"priv_users" json:"priv_users"`
+	Encrypted  string   `gorm:"not null;size:64;default:'N'" form:"encrypted" json:"encrypted"`
+	DataKey    string   `gorm:"not null;size:255;default:''" json:"-"`
+	KeyId      string   `gorm:"not null;size:64;default:''" json:"-"`
+	Digest     string   `gorm:"not null;size:64;default:''" json:"-"`
+	Events     int64    `gorm:"not null;default:0" json:"-"`
+	StartAt    DateTime `gorm:"start_at;not null" json:"start_at" form:"start_at"`
+	EndAt      DateTime `gorm:"end_at" json:"end_at" form:"end_at"`
+	CreatedAt  DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt  DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c SessionRecord) Create(record *SessionRecord) error {
+	return Db.Create(record).Error
+}
+
+func (c SessionRecord) FindByID(id uint) (SessionRecord, error) {
+	var record SessionRecord
+	err := Db.First(&record, "id = ?", id).Error
+	return record, err
+}
+
+func (c SessionRecord) FindAll(uid uint, offset, limit int) ([]SessionRecord, error) {
+	var list []SessionRecord
+	var db = ReadDb()
+	if uid != 0 {
+		db = db.Where("uid = ?", uid)
+	}
+	err := db.Offset(offset).Limit(limit).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c SessionRecord) FindPage(q PageQuery, uid uint) ([]SessionRecord, int64, error) {
+	var db = ReadDb()
+	if uid != 0 {
+		db = db.Where("uid = ?", uid)
+	}
+	return findPage[SessionRecord](db, q, pageSpec{
+		Sorts: []string{"id", "user_name", "address", "size", "start_at", "end_at"},
+		Filters: map[string]string{
+			"user_name":  "like",
+			"address":    "like",
+			"ssh_user":   "like",
+			"client_ip":  "like",
+			"session_id": "eq",
+			"privileged": "eq",
+			"priv_users": "like",
+		},
+		DefaultSort: "id desc",
+	})
+}
+
+func (c SessionRecord) UpdateById(id uint, record *SessionRecord) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(record).Error
+}
+
+func (c SessionRecord) Finish(id uint, size int64) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"end_at": time.Now(),
+		"size":   size,
+	}).Error
+}
+
+// UpdateDigest 更新录像的校验值和行数
+func (c SessionRecord) UpdateDigest(id uint, digest string, events int64) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"digest": digest,
+		"events": events,
+	}).Error
+}
+
+// FinishBySessionIds 结束失效节点上未正常结束的录像
+func (c SessionRecord) FinishBySessionIds(ids []string, endAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return Db.Model(&c).Where("session_id IN ? AND end_at IS NULL", ids).Update("end_at", endAt).Error
+}
+
+// UpdatePrivUsers 记录会话中提权后的用户
+func (c SessionRecord) UpdatePrivUsers(id uint, users string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"privileged": "Y",
+		"priv_users": users,
+	}).Error
+}
+
+// UpdateStorage 录像文件转存后更新存储位置
+func (c SessionRecord) UpdateStorage(id uint, storage, filePath string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"storage":   storage,
+		"file_path": filePath,
+	}).Error
+}
+
+// RecordUsage 用户录像占用的空间
+type RecordUsage struct {
+	Uid   uint  `json:"uid"`
+	Count int64 `json:"count"`
+	Size  int64 `json:"size"`
+}
+
+// UsageByUid 统计用户录像占用的空间
+func (c SessionRecord) UsageByUid(uid uint) (RecordUsage, error) {
+	usage := RecordUsage{Uid: uid}
+	err := Db.Model(&c).Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS size").Where("uid = ?", uid).Scan(&usage).Error
+	return usage, err
+}
+
+// UsageByTenant 统计租户下所有用户录像占用的空间
+func (c SessionRecord) UsageByTenant(tenantId uint) (RecordUsage, error) {
+	var usage RecordUsage
+	err := Db.Model(&c).Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS size").
+		Where("uid IN (?)", Db.Model(&SshUser{}).Select("id").Where("tenant_id = ?", tenantId)).Scan(&usage).Error
+	return usage, err
+}
+
+// UsageGroupByUid 统计所有用户录像占用的空间
+func (c SessionRecord) UsageGroupByUid() ([]RecordUsage, error) {
+	var list []RecordUsage
+	err := Db.Model(&c).Select("uid, COUNT(*) AS count, COALESCE(SUM(size), 0) AS size").Group("uid").Scan(&list).Error
+	return list, err
+}
+
+// FindFinishedBefore 查询指定时间之前结束的录像
+func (c SessionRecord) FindFinishedBefore(t time.Time) ([]SessionRecord, error) {
+	var list []SessionRecord
+	err := Db.Where("end_at IS NOT NULL AND end_at < ?", t).Order("id asc").Find(&list).Error
+	return list, err
+}
+
+// FindFinishedByUid 按时间顺序查询用户已结束的录像
+func (c SessionRecord) FindFinishedByUid(uid uint) ([]SessionRecord, error) {
+	var list []SessionRecord
+	err := Db.Where("uid = ? AND end_at IS NOT NULL AND size > 0", uid).Order("id asc").Find(&list).Error
+	return list, err
+}
+
+// DeleteByID 删除录像记录和脱敏记录
+func (c SessionRecord) DeleteByID(id uint) error {
+	if err := Db.Unscoped().Delete(&RecordRedaction{}, "record_id = ?", id).Error; err != nil {
+		return err
+	}
+	return Db.Unscoped().Delete(&c, "id = ?", id).Error
+}
+
+type RecordRedaction struct {
+	ID         uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	RecordId   uint     `gorm:"not null;index" form:"record_id" json:"record_id"`
+	StartSec   float64  `gorm:"not null;default:0" form:"start_sec" json:"start_sec"`
+	EndSec     float64  `gorm:"not null;default:0" form:"end_sec" json:"end_sec"`
+	Reason     string   `gorm:"not null;size:512;default:''" form:"reason" json:"reason"`
+	ApproverId uint     `gorm:"not null;default:0" form:"approver_id" json:"approver_id"`
+	Approver   string   `gorm:"not null;size:64;default:''" form:"approver" json:"approver"`
+	CreatedAt  DateTime `gorm:"created_at" json:"created_at"`
+	UpdatedAt  DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c RecordRedaction) Create(redaction *RecordRedaction) error {
+	return Db.Create(redaction).Error
+}
+
+func (c RecordRedaction) FindByRecordId(recordId uint) ([]RecordRedaction, error) {
+	var list []RecordRedaction
+	err := Db.Where("record_id = ?", recordId).Order("id asc").Find(&list).Error
+	return list, err
+}
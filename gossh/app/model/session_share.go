@@ -0,0 +1,50 @@
+package model
+
+import "gossh/gorm"
+
+// SessionShare 会话只读分享链接,链接中带有签名和过期时间,撤销后立即失效
+type SessionShare struct {
+	ID        uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid       uint     `gorm:"not null;default:0;index" form:"-" json:"uid"`
+	SessionId string   `gorm:"not null;size:128;index" form:"session_id" json:"session_id"`
+	ConfId    uint     `gorm:"not null;default:0" form:"-" json:"conf_id"`
+	Address   string   `gorm:"not null;size:256;default:''" form:"-" json:"address"`
+	Remark    string   `gorm:"not null;size:256;default:''" form:"remark" json:"remark"`
+	IsRevoked string   `gorm:"not null;size:64;default:'N'" form:"-" json:"is_revoked"`
+	ViewCount uint     `gorm:"not null;default:0" form:"-" json:"view_count"`
+	ExpiryAt  DateTime `gorm:"expiry_at;not null" json:"expiry_at" form:"-"`
+	CreatedAt DateTime `gorm:"created_at" json:"created_at"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c SessionShare) Create(share *SessionShare) error {
+	return Db.Create(share).Error
+}
+
+func (c SessionShare) FindByID(id uint) (SessionShare, error) {
+	var share SessionShare
+	err := Db.First(&share, "id = ?", id).Error
+	return share, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c SessionShare) FindPage(q PageQuery, uid uint) ([]SessionShare, int64, error) {
+	return findPage[SessionShare](ReadDb().Where("uid = ?", uid), q, pageSpec{
+		Sorts: []string{"id", "expiry_at", "view_count", "created_at"},
+		Filters: map[string]string{
+			"session_id": "eq",
+			"is_revoked": "eq",
+		},
+		DefaultSort: "id desc",
+	})
+}
+
+func (c SessionShare) Revoke(id, uid uint) (int64, error) {
+	result := Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Update("is_revoked", "Y")
+	return result.RowsAffected, result.Error
+}
+
+// AddView 访问次数加一
+func (c SessionShare) AddView(id uint) error {
+	return Db.Model(&c).Where("id = ?", id).Update("view_count", gorm.Expr("view_count + 1")).Error
+}
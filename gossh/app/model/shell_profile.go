@@ -0,0 +1,62 @@
+package model
+
+type ShellProfile struct {
+	ID        uint   `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid       uint   `gorm:"not null;default:0" form:"uid" json:"uid"`
+	Name      string `gorm:"not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
+	EnvVars   string `gorm:"type:text" form:"env_vars" json:"env_vars"`
+	Aliases   string `gorm:"type:text" form:"aliases" json:"aliases"`
+	Ps1       string `gorm:"not null;size:512;default:''" form:"ps1" binding:"max=512" json:"ps1"`
+	Script    string `gorm:"type:text" form:"script" json:"script"`
+	IsDefault string `gorm:"not null;size:64;default:'N'" form:"is_default" binding:"required,oneof=Y N" json:"is_default"`
+
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c ShellProfile) Create(profile *ShellProfile) error {
+	return Db.Create(profile).Error
+}
+
+func (c ShellProfile) FindByID(id uint, uid uint) (ShellProfile, error) {
+	var profile ShellProfile
+	err := Db.First(&profile, "id = ? AND uid = ?", id, uid).Error
+	return profile, err
+}
+
+func (c ShellProfile) FindDefault(uid uint) (ShellProfile, error) {
+	var profile ShellProfile
+	err := Db.First(&profile, "uid = ? AND is_default = ?", uid, "Y").Error
+	return profile, err
+}
+
+func (c ShellProfile) FindAll(offset, limit int, uid uint) ([]ShellProfile, error) {
+	var list []ShellProfile
+	err := ReadDb().Where("uid = ?", uid).Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c ShellProfile) FindPage(q PageQuery, uid uint) ([]ShellProfile, int64, error) {
+	return findPage[ShellProfile](ReadDb().Where("uid = ?", uid), q, pageSpec{
+		Sorts: []string{"id", "name", "updated_at"},
+		Filters: map[string]string{
+			"name":       "like",
+			"is_default": "eq",
+		},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+func (c ShellProfile) UpdateById(id, uid uint, profile *ShellProfile) error {
+	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Updates(profile).Error
+}
+
+// ClearDefault 每个用户只能有一个默认配置
+func (c ShellProfile) ClearDefault(uid uint) error {
+	return Db.Model(&c).Where("uid = ?", uid).Update("is_default", "N").Error
+}
+
+func (c ShellProfile) DeleteByID(id, uid uint) error {
+	return Db.Unscoped().Delete(&c, "id = ? AND uid = ?", id, uid).Error
+}
@@ -1,35 +1,91 @@
 package model
 
+import (
+	"encoding/json"
+	"fmt"
+	"gossh/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
 type SshConf struct {
-	ID          uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
-	Uid         uint     `gorm:"not null;default:0" form:"uid" json:"uid"`
-	Name        string   `gorm:"not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
-	Address     string   `gorm:"size:128" form:"address" binding:"required,min=1,max=128" json:"address"`
-	User        string   `gorm:"size:128" form:"user" binding:"required,min=1,max=128" json:"user"`
-	Pwd         string   `gorm:"not null;size:128;default:''" form:"pwd" binding:"max=128" json:"pwd"`
-	AuthType    string   `gorm:"not null;size:32;default:'pwd'" form:"auth_type" binding:"required,min=1,max=32,oneof=pwd cert" json:"auth_type"`
-	NetType     string   `gorm:"not null;size:32;default:'tcp4'" form:"net_type" binding:"required,min=1,max=32,oneof=tcp4 tcp6" json:"net_type"`
-	CertData    string   `gorm:"type:text" form:"cert_data" json:"cert_data"`
-	CertPwd     string   `gorm:"not null;size:128;default:''" form:"cert_pwd" binding:"max=128" json:"cert_pwd"`
-	Port        uint16   `gorm:"not null;default:22" form:"port" binding:"required,gte=1,lte=65535" json:"port"`
-	FontSize    uint16   `gorm:"not null;default:14" form:"font_size" binding:"required,gte=8,lte=48" json:"font_size"`
-	Background  string   `gorm:"not null;size:128;default:'#000000'" form:"background" binding:"required,hexcolor" json:"background"`
-	Foreground  string   `gorm:"not null;size:128;default:'#FFFFFF'" form:"foreground" binding:"required,hexcolor" json:"foreground"`
-	CursorColor string   `gorm:"not null;size:128;default:'#FFFFFF'" form:"cursor_color" binding:"required,hexcolor" json:"cursor_color"`
-	FontFamily  string   `gorm:"not null;size:128;default:'Courier'" form:"font_family" binding:"min=1,max=128" json:"font_family"`
-	CursorStyle string   `gorm:"not null;size:128;default:'block'" form:"cursor_style" binding:"min=1,max=128" json:"cursor_style"`
-	Shell       string   `gorm:"not null;size:64;default:'bash'" form:"shell" binding:"min=1,max=128" json:"shell"`
-	PtyType     string   `gorm:"not null;size:64;default:'xterm-256color'" form:"pty_type" binding:"min=1,max=128" json:"pty_type"`
-	InitCmd     string   `gorm:"type:text" form:"init_cmd" json:"init_cmd"`
-	InitBanner  string   `gorm:"type:text" form:"init_banner" json:"init_banner"`
-	CreatedAt   DateTime `gorm:"created_at" json:"-"`
-	UpdatedAt   DateTime `gorm:"updated_at" json:"-"`
+	ID             uint           `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid            uint           `gorm:"not null;default:0" form:"uid" json:"uid"`
+	Name           string         `gorm:"not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
+	Address        string         `gorm:"size:128" form:"address" binding:"required,min=1,max=128" json:"address"`
+	User           string         `gorm:"size:128" form:"user" binding:"required,min=1,max=128" json:"user"`
+	Pwd            string         `gorm:"not null;size:128;default:''" form:"pwd" binding:"max=128" json:"pwd"`
+	AuthType       string         `gorm:"not null;size:32;default:'pwd'" form:"auth_type" binding:"required,min=1,max=32,oneof=pwd cert interactive ssh_cert" json:"auth_type"`
+	NetType        string         `gorm:"not null;size:32;default:'tcp4'" form:"net_type" binding:"required,min=1,max=32,oneof=tcp4 tcp6" json:"net_type"`
+	CertData       string         `gorm:"type:text" form:"cert_data" json:"cert_data"`
+	UserCert       string         `gorm:"type:text" form:"user_cert" json:"user_cert"`
+	CertPwd        string         `gorm:"not null;size:128;default:''" form:"cert_pwd" binding:"max=128" json:"cert_pwd"`
+	Port           uint16         `gorm:"not null;default:22" form:"port" binding:"required,gte=1,lte=65535" json:"port"`
+	FontSize       uint16         `gorm:"not null;default:14" form:"font_size" binding:"required,gte=8,lte=48" json:"font_size"`
+	Background     string         `gorm:"not null;size:128;default:'#000000'" form:"background" binding:"required,hexcolor" json:"background"`
+	Foreground     string         `gorm:"not null;size:128;default:'#FFFFFF'" form:"foreground" binding:"required,hexcolor" json:"foreground"`
+	CursorColor    string         `gorm:"not null;size:128;default:'#FFFFFF'" form:"cursor_color" binding:"required,hexcolor" json:"cursor_color"`
+	FontFamily     string         `gorm:"not null;size:128;default:'Courier'" form:"font_family" binding:"min=1,max=128" json:"font_family"`
+	CursorStyle    string         `gorm:"not null;size:128;default:'block'" form:"cursor_style" binding:"min=1,max=128" json:"cursor_style"`
+	Shell          string         `gorm:"not null;size:64;default:'bash'" form:"shell" binding:"min=1,max=128" json:"shell"`
+	PtyType        string         `gorm:"not null;size:64;default:'xterm-256color'" form:"pty_type" binding:"min=1,max=128" json:"pty_type"`
+	Charset        string         `gorm:"not null;size:32;default:'utf-8'" form:"charset" binding:"omitempty,oneof=utf-8 gbk big5" json:"charset"`
+	TrueColor      string         `gorm:"not null;size:64;default:'Y'" form:"true_color" binding:"omitempty,oneof=Y N" json:"true_color"`
+	AllowOsc52     string         `gorm:"not null;size:64;default:'Y'" form:"allow_osc52" binding:"omitempty,oneof=Y N" json:"allow_osc52"`
+	InitCmd        string         `gorm:"type:text" form:"init_cmd" json:"init_cmd"`
+	InitBanner     string         `gorm:"type:text" form:"init_banner" json:"init_banner"`
+	SetEnv         string         `gorm:"type:text" form:"set_env" json:"set_env"`
+	NeedApproval   string         `gorm:"not null;size:64;default:'N'" form:"need_approval" binding:"omitempty,oneof=Y N" json:"need_approval"`
+	Environment    string         `gorm:"not null;size:32;default:'dev';index" form:"environment" binding:"omitempty,oneof=dev staging prod" json:"environment"`
+	GroupName      string         `gorm:"not null;size:64;default:''" form:"group_name" binding:"max=64" json:"group_name"`
+	ShellProfileId uint           `gorm:"not null;default:0" form:"shell_profile_id" json:"shell_profile_id"`
+	FallbackAddrs  string         `gorm:"type:text" form:"fallback_addrs" json:"fallback_addrs"`
+	FailoverMode   string         `gorm:"not null;size:32;default:'order'" form:"failover_mode" binding:"omitempty,oneof=order latency parallel" json:"failover_mode"`
+	AliveInterval  int            `gorm:"not null;default:0" form:"alive_interval" binding:"gte=-1,lte=3600" json:"alive_interval"`
+	AliveCountMax  uint           `gorm:"not null;default:0" form:"alive_count_max" binding:"lte=100" json:"alive_count_max"`
+	TcpKeepalive   int            `gorm:"not null;default:0" form:"tcp_keepalive" binding:"gte=-1,lte=3600" json:"tcp_keepalive"`
+	ConnectTimeout uint           `gorm:"not null;default:0" form:"connect_timeout" binding:"lte=300" json:"connect_timeout"`
+	RekeyLimit     uint           `gorm:"not null;default:0" form:"rekey_limit" binding:"lte=1048576" json:"rekey_limit"`
+	Proxy          string         `gorm:"not null;size:512;default:''" form:"proxy" binding:"max=512" json:"proxy"`
+	PreConnect     string         `gorm:"type:text" form:"pre_connect" binding:"max=8192" json:"pre_connect"`
+	PostDisconnect string         `gorm:"type:text" form:"post_disconnect" binding:"max=8192" json:"post_disconnect"`
+	X11Forward     string         `gorm:"not null;size:64;default:'N'" form:"x11_forward" binding:"omitempty,oneof=Y N" json:"x11_forward"`
+	WolMac         string         `gorm:"not null;size:32;default:''" form:"wol_mac" binding:"max=32" json:"wol_mac"`
+	WolBroadcast   string         `gorm:"not null;size:128;default:''" form:"wol_broadcast" binding:"max=128" json:"wol_broadcast"`
+	WolAuto        string         `gorm:"not null;size:64;default:'N'" form:"wol_auto" binding:"omitempty,oneof=Y N" json:"wol_auto"`
+	LastEndpoint   string         `gorm:"not null;size:256;default:''" form:"-" json:"last_endpoint"`
+	Trusted        string         `gorm:"not null;size:64;default:'N'" form:"trusted" binding:"omitempty,oneof=Y N" json:"trusted"`
+	ExternalId     string         `gorm:"not null;size:128;default:'';index" form:"external_id" binding:"max=128" json:"external_id"`
+	ExecTimeout    uint           `gorm:"not null;default:0" form:"exec_timeout" binding:"lte=86400" json:"exec_timeout"`
+	ExecMaxTimeout uint           `gorm:"not null;default:0" form:"exec_max_timeout" binding:"lte=86400" json:"exec_max_timeout"`
+	HealthCmd      string         `gorm:"type:text" form:"health_cmd" json:"health_cmd"`
+	HealthStatus   string         `gorm:"not null;size:32;default:''" form:"-" json:"health_status"`
+	HealthOutput   string         `gorm:"type:text" form:"-" json:"health_output"`
+	HealthCheckAt  DateTime       `gorm:"health_check_at" form:"-" json:"health_check_at"`
+	ProbeStatus    string         `gorm:"not null;size:32;default:''" form:"-" json:"probe_status"`
+	ProbeLatency   int64          `gorm:"not null;default:0" form:"-" json:"probe_latency"`
+	ProbeAt        DateTime       `gorm:"probe_at" form:"-" json:"probe_at"`
+	InventoryId    uint           `gorm:"not null;default:0;index" form:"-" json:"inventory_id"`
+	Stale          string         `gorm:"not null;size:64;default:'N'" form:"-" json:"stale"`
+	Tags           string         `gorm:"type:text" form:"tags" binding:"max=4096" json:"tags"`
+	RotatePwd      string         `gorm:"not null;size:64;default:'N'" form:"rotate_pwd" binding:"omitempty,oneof=Y N" json:"rotate_pwd"`
+	Facts          string         `gorm:"type:text" form:"-" json:"facts"`
+	FactsAt        DateTime       `gorm:"facts_at" form:"-" json:"facts_at"`
+	Version        uint           `gorm:"not null;default:0" form:"version" json:"version"`
+	CreatedAt      DateTime       `gorm:"created_at" json:"-"`
+	UpdatedAt      DateTime       `gorm:"updated_at" json:"-"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" form:"-" json:"deleted_at"`
 }
 
 func (c SshConf) Create(conf *SshConf) error {
 	return Db.Create(conf).Error
 }
 
+func (c SshConf) CreateBatch(list []SshConf) error {
+	return Db.CreateInBatches(list, 100).Error
+}
+
 func (c SshConf) FindByID(id uint, uid uint) (SshConf, error) {
 	var conf SshConf
 	err := Db.First(&conf, "id = ? AND uid = ?", id, uid).Error
@@ -38,14 +94,309 @@ func (c SshConf) FindByID(id uint, uid uint) (SshConf, error) {
 
 func (c SshConf) FindAll(offset, limit int, uid uint) ([]SshConf, error) {
 	var list []SshConf
-	err := Db.Where("uid = ?", uid).Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
+	err := ReadDb().Where("uid = ?", uid).Offset(offset).Limit(limit).Order("updated_at desc").Find(&list).Error
 	return list, err
 }
 
+// FindPage 分页查询,返回当前页数据和总数
+func (c SshConf) FindPage(q PageQuery, uid uint) ([]SshConf, int64, error) {
+	return findPage[SshConf](ReadDb().Where("uid = ?", uid), q, pageSpec{
+		Sorts: []string{"id", "name", "address", "user", "port", "environment", "group_name", "health_status", "probe_latency", "created_at", "updated_at"},
+		Filters: map[string]string{
+			"name":          "like",
+			"address":       "like",
+			"user":          "like",
+			"group_name":    "eq",
+			"environment":   "eq",
+			"auth_type":     "eq",
+			"health_status": "eq",
+			"probe_status":  "eq",
+			"tags":          "like",
+			"stale":         "eq",
+			"inventory_id":  "eq",
+		},
+		DefaultSort: "updated_at desc",
+	})
+}
+
+// UpdateById 乐观锁更新,版本号不一致时返回 ErrVersionConflict
 func (c SshConf) UpdateById(id, uid uint, conf *SshConf) error {
-	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Updates(conf).Error
+	return updateVersioned(Db.Model(&c).Where("id = ? AND uid = ?", id, uid), conf, &conf.Version)
+}
+
+// CountByUid 用户的主机配置数量,不包括回收站
+func (c SshConf) CountByUid(uid uint) (int64, error) {
+	var count int64
+	err := Db.Model(&c).Where("uid = ?", uid).Count(&count).Error
+	return count, err
+}
+
+// CountByTenant 租户下所有用户的主机配置数量,不包括回收站
+func (c SshConf) CountByTenant(tenantId uint) (int64, error) {
+	var count int64
+	err := Db.Model(&c).Where("uid IN (?)", Db.Model(&SshUser{}).Select("id").Where("tenant_id = ?", tenantId)).Count(&count).Error
+	return count, err
+}
+
+// FindByUid 查询用户的全部配置,用于按标签匹配主机
+func (c SshConf) FindByUid(uid uint) ([]SshConf, error) {
+	var list []SshConf
+	err := Db.Where("uid = ?", uid).Order("id").Find(&list).Error
+	return list, err
+}
+
+// UpdateTags 设置标签,标签为空时清空
+func (c SshConf) UpdateTags(id, uid uint, tags string) (int64, error) {
+	ret := Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Updates(map[string]any{
+		"tags":    tags,
+		"version": gorm.Expr("version + 1"),
+	})
+	return ret.RowsAffected, ret.Error
 }
 
 func (c SshConf) DeleteByID(id, uid uint) error {
-	return Db.Unscoped().Delete(&c, "id = ? AND uid = ?", id, uid).Error
+	return Db.Delete(&c, "id = ? AND uid = ?", id, uid).Error
+}
+
+// FindTrash 查询用户回收站中的配置
+func (c SshConf) FindTrash(uid uint) ([]SshConf, error) {
+	var list []SshConf
+	err := Db.Unscoped().Where("uid = ? AND deleted_at IS NOT NULL", uid).Order("deleted_at desc").Find(&list).Error
+	return list, err
+}
+
+// Restore 从回收站恢复配置
+func (c SshConf) Restore(id, uid uint) (int64, error) {
+	ret := Db.Unscoped().Model(&c).Where("id = ? AND uid = ? AND deleted_at IS NOT NULL", id, uid).Update("deleted_at", nil)
+	return ret.RowsAffected, ret.Error
+}
+
+// PurgeTrash 彻底删除在指定时间之前放入回收站的配置
+func (c SshConf) PurgeTrash(before time.Time) (int64, error) {
+	ret := Db.Unscoped().Delete(&c, "deleted_at < ?", before)
+	return ret.RowsAffected, ret.Error
+}
+
+// IsProdEndpoint 地址和端口是否被任一主机配置标记为生产环境
+func (c SshConf) IsProdEndpoint(address string, port uint16) (bool, error) {
+	var count int64
+	err := Db.Model(&c).Where("address = ? AND port = ? AND environment = ?", address, port, "prod").Count(&count).Error
+	return count > 0, err
+}
+
+// FindAllNeedApproval 查询标记为需要审批的主机配置,只返回地址相关字段
+func (c SshConf) FindAllNeedApproval() ([]SshConf, error) {
+	var list []SshConf
+	err := Db.Select("id", "address", "port", "net_type", "fallback_addrs").Where("need_approval = ?", "Y").Find(&list).Error
+	return list, err
+}
+
+// UpdateLastEndpoint 记录最近一次连接成功的地址
+func (c SshConf) UpdateLastEndpoint(id, uid uint, endpoint string) error {
+	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Update("last_endpoint", endpoint).Error
+}
+
+// FindAllHealthCmd 查询配置了健康检查命令的主机
+func (c SshConf) FindAllHealthCmd() ([]SshConf, error) {
+	var list []SshConf
+	err := Db.Where("health_cmd IS NOT NULL AND health_cmd <> ''").Find(&list).Error
+	return list, err
+}
+
+// UpdateHealth 记录健康检查结果
+func (c SshConf) UpdateHealth(id uint, status, output string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"health_status":   status,
+		"health_output":   output,
+		"health_check_at": time.Now(),
+	}).Error
+}
+
+// FindAllProbe 查询所有主机的连接地址,用于可达性探测
+func (c SshConf) FindAllProbe() ([]SshConf, error) {
+	var list []SshConf
+	err := Db.Select("id", "net_type", "address", "port").Find(&list).Error
+	return list, err
+}
+
+// UpdateProbe 记录可达性探测结果,latency 单位毫秒
+func (c SshConf) UpdateProbe(ids []uint, status string, latency int64) error {
+	return Db.Model(&c).Where("id IN ?", ids).Updates(map[string]any{
+		"probe_status":  status,
+		"probe_latency": latency,
+		"probe_at":      time.Now(),
+	}).Error
+}
+
+// UpdateFacts 缓存主机信息
+func (c SshConf) UpdateFacts(id uint, facts string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"facts":    facts,
+		"facts_at": time.Now(),
+	}).Error
+}
+
+// BulkResult 批量同步的变更汇总
+type BulkResult struct {
+	Created   []string            `json:"created"`
+	Updated   map[string][]string `json:"updated"`
+	Deleted   []string            `json:"deleted"`
+	Unchanged []string            `json:"unchanged"`
+}
+
+// 批量同步时不比较、不覆盖的字段
+var bulkIgnoreFields = map[string]bool{
+	"id":              true,
+	"uid":             true,
+	"external_id":     true,
+	"last_endpoint":   true,
+	"health_status":   true,
+	"health_output":   true,
+	"health_check_at": true,
+	"probe_status":    true,
+	"probe_latency":   true,
+	"probe_at":        true,
+	"facts":           true,
+	"facts_at":        true,
+	"inventory_id":    true,
+	"stale":           true,
+	"version":         true,
+	"deleted_at":      true,
+}
+
+// bulkChangedFields 对比配置,返回有变化的字段名
+func bulkChangedFields(before, after SshConf) []string {
+	var b, a map[string]any
+	data, _ := json.Marshal(before)
+	_ = json.Unmarshal(data, &b)
+	data, _ = json.Marshal(after)
+	_ = json.Unmarshal(data, &a)
+	var fields []string
+	for k, v := range a {
+		if bulkIgnoreFields[k] {
+			continue
+		}
+		if fmt.Sprint(b[k]) != fmt.Sprint(v) {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// bulkColumns 将 json 字段名转换为数据库列名,用于指定更新的列
+func bulkColumns(tx *gorm.DB, fields []string) ([]string, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(&SshConf{}); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(stmt.Schema.Fields))
+	for _, field := range stmt.Schema.Fields {
+		name, _, _ := strings.Cut(field.StructField.Tag.Get("json"), ",")
+		if name != "" && name != "-" && field.DBName != "" {
+			names[name] = field.DBName
+		}
+	}
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		column, ok := names[f]
+		if !ok {
+			return nil, fmt.Errorf("未知字段: %s", f)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// BulkUpsert 按 external_id 将用户的主机配置同步为期望状态,在一个事务中完成新增、修改和删除
+// 未设置 external_id 的配置不受影响,dryRun 为 true 时只返回变更汇总
+func (c SshConf) BulkUpsert(uid uint, list []SshConf, dryRun bool) (BulkResult, error) {
+	result := BulkResult{
+		Created:   []string{},
+		Updated:   map[string][]string{},
+		Deleted:   []string{},
+		Unchanged: []string{},
+	}
+	err := Db.Transaction(func(tx *gorm.DB) error {
+		var existing []SshConf
+		// 云主机清单同步的配置由清单维护,不参与批量同步
+		if err := tx.Where("uid = ? AND external_id <> '' AND inventory_id = 0", uid).Find(&existing).Error; err != nil {
+			return err
+		}
+		current := make(map[string]SshConf, len(existing))
+		for _, item := range existing {
+			current[item.ExternalId] = item
+		}
+
+		desired := make(map[string]bool, len(list))
+		for _, item := range list {
+			if desired[item.ExternalId] {
+				return fmt.Errorf("duplicate external_id: %s", item.ExternalId)
+			}
+			desired[item.ExternalId] = true
+			item.Uid = uid
+
+			before, ok := current[item.ExternalId]
+			if !ok {
+				item.ID = 0
+				item.LastEndpoint, item.HealthStatus, item.HealthOutput = "", "", ""
+				item.HealthCheckAt = DateTime{}
+				item.ProbeStatus, item.ProbeLatency, item.ProbeAt = "", 0, DateTime{}
+				item.Facts, item.FactsAt = "", DateTime{}
+				item.InventoryId, item.Stale = 0, "N"
+				result.Created = append(result.Created, item.ExternalId)
+				if !dryRun {
+					if err := tx.Create(&item).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			item.ID = before.ID
+			// 开启密码轮换的配置,密码由系统维护,不使用同步的密码
+			if before.RotatePwd == "Y" && item.RotatePwd == "Y" {
+				item.Pwd = before.Pwd
+			}
+			fields := bulkChangedFields(before, item)
+			if len(fields) == 0 {
+				result.Unchanged = append(result.Unchanged, item.ExternalId)
+				continue
+			}
+			result.Updated[item.ExternalId] = fields
+			if !dryRun {
+				// 指定字段更新,零值也会写入
+				item.Version = before.Version + 1
+				columns, err := bulkColumns(tx, fields)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&SshConf{}).Where("id = ?", before.ID).Select(append(columns, "version")).Updates(&item).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, item := range existing {
+			if desired[item.ExternalId] {
+				continue
+			}
+			result.Deleted = append(result.Deleted, item.ExternalId)
+			if !dryRun {
+				if err := tx.Delete(&SshConf{}, "id = ?", item.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// UpdatePwd 轮换后保存新密码
+func (c SshConf) UpdatePwd(id uint, pwd string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"pwd":     pwd,
+		"version": gorm.Expr("version + 1"),
+	}).Error
 }
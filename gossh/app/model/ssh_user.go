@@ -1,17 +1,29 @@
 package model
 
+import (
+	"gossh/gorm"
+	"time"
+)
+
 type SshUser struct {
-	ID       uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
-	Name     string   `gorm:"uniqueIndex;not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
-	Pwd      string   `gorm:"size:64" form:"pwd" binding:"required,min=1,max=64" json:"pwd"`
-	DescInfo string   `gorm:"size:64" form:"desc_info" binding:"required,min=1,max=64" json:"desc_info"`
-	IsAdmin  string   `gorm:"not null;size:64;default:'N'" form:"is_admin" binding:"required,min=1,max=64,oneof=Y N" json:"is_admin"`
-	IsEnable string   `gorm:"not null;size:64;default:'Y'" form:"is_enable" binding:"required,min=1,max=64,oneof=Y N" json:"is_enable"`
-	IsRoot   string   `gorm:"not null;size:64;default:'N'" form:"is_root"  json:"is_root"`
-	ExpiryAt DateTime `gorm:"expiry_at;not null"  json:"expiry_at"  form:"expiry_at" binding:"required"`
+	ID               uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Name             string   `gorm:"uniqueIndex;not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
+	Pwd              string   `gorm:"size:64" form:"pwd" binding:"required,min=1,max=64" json:"pwd"`
+	DescInfo         string   `gorm:"size:64" form:"desc_info" binding:"required,min=1,max=64" json:"desc_info"`
+	IsAdmin          string   `gorm:"not null;size:64;default:'N'" form:"is_admin" binding:"required,min=1,max=64,oneof=Y N" json:"is_admin"`
+	IsEnable         string   `gorm:"not null;size:64;default:'Y'" form:"is_enable" binding:"required,min=1,max=64,oneof=Y N" json:"is_enable"`
+	IsRoot           string   `gorm:"not null;size:64;default:'N'" form:"is_root"  json:"is_root"`
+	ProdAccess       string   `gorm:"not null;size:64;default:'N'" form:"prod_access" binding:"omitempty,oneof=Y N" json:"prod_access"`
+	Email            string   `gorm:"not null;size:128;default:''" form:"email" binding:"omitempty,email,max=128" json:"email"`
+	TranscriptNotify string   `gorm:"not null;size:64;default:'N'" form:"transcript_notify" binding:"omitempty,oneof=Y N" json:"transcript_notify"`
+	CanImpersonate   string   `gorm:"not null;size:64;default:'N'" form:"can_impersonate" binding:"omitempty,oneof=Y N" json:"can_impersonate"`
+	ExpiryAt         DateTime `gorm:"expiry_at;not null"  json:"expiry_at"  form:"expiry_at" binding:"required"`
+	TenantId         uint     `gorm:"not null;default:0;index" form:"tenant_id" json:"tenant_id"`
 
-	CreatedAt DateTime `gorm:"created_at" json:"-"`
-	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+	Version   uint           `gorm:"not null;default:0" form:"version" json:"version"`
+	CreatedAt DateTime       `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime       `gorm:"updated_at" json:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"index" form:"-" json:"deleted_at"`
 }
 
 func (c SshUser) Create(user *SshUser) error {
@@ -38,16 +50,32 @@ func (c SshUser) FindByID(id uint) (SshUser, error) {
 
 func (c SshUser) FindAll(limit, offset int) ([]SshUser, error) {
 	var list []SshUser
-	err := Db.Where("is_root = ?", "N").Limit(limit).Offset(offset).Find(&list).Error
+	err := ReadDb().Where("is_root = ?", "N").Limit(limit).Offset(offset).Find(&list).Error
 	return list, err
 }
 
+// FindPage 分页查询租户下的用户,返回当前页数据和总数
+func (c SshUser) FindPage(q PageQuery, tenantId uint) ([]SshUser, int64, error) {
+	return findPage[SshUser](ReadDb().Where("is_root = ? AND tenant_id = ?", "N", tenantId), q, pageSpec{
+		Sorts: []string{"id", "name", "is_admin", "is_enable", "expiry_at", "created_at", "updated_at"},
+		Filters: map[string]string{
+			"name":      "like",
+			"desc_info": "like",
+			"email":     "like",
+			"is_admin":  "eq",
+			"is_enable": "eq",
+		},
+		DefaultSort: "id asc",
+	})
+}
+
 func (c SshUser) UpdateByName(name string, user *SshUser) error {
 	return Db.Model(&c).Where("name = ?", name).Updates(user).Error
 }
 
+// UpdateById 乐观锁更新,版本号不一致时返回 ErrVersionConflict
 func (c SshUser) UpdateById(id uint, user *SshUser) error {
-	return Db.Model(&c).Where("id = ? AND is_root = ?", id, "N").Updates(user).Error
+	return updateVersioned(Db.Model(&c).Where("id = ? AND is_root = ?", id, "N"), user, &user.Version)
 }
 
 func (c SshUser) UpdatePassword(id uint, user *SshUser) error {
@@ -55,5 +83,45 @@ func (c SshUser) UpdatePassword(id uint, user *SshUser) error {
 }
 
 func (c SshUser) DeleteByID(id uint) error {
-	return Db.Unscoped().Delete(&c, "id = ? AND is_root = ?", id, "N").Error
+	return Db.Delete(&c, "id = ? AND is_root = ?", id, "N").Error
+}
+
+// NameExists 用户名是否已被使用,包括回收站中的用户
+func (c SshUser) NameExists(name string) (bool, error) {
+	var count int64
+	err := Db.Unscoped().Model(&c).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+// FindTrash 查询租户回收站中的用户
+func (c SshUser) FindTrash(tenantId uint) ([]SshUser, error) {
+	var list []SshUser
+	err := Db.Unscoped().Where("deleted_at IS NOT NULL AND tenant_id = ?", tenantId).Order("deleted_at desc").Find(&list).Error
+	return list, err
+}
+
+// FindTrashBefore 查询在指定时间之前放入回收站的用户
+func (c SshUser) FindTrashBefore(before time.Time) ([]SshUser, error) {
+	var list []SshUser
+	err := Db.Unscoped().Where("deleted_at < ?", before).Find(&list).Error
+	return list, err
+}
+
+// Restore 从回收站恢复租户下的用户
+func (c SshUser) Restore(id, tenantId uint) (int64, error) {
+	ret := Db.Unscoped().Model(&c).Where("id = ? AND tenant_id = ? AND deleted_at IS NOT NULL", id, tenantId).Update("deleted_at", nil)
+	return ret.RowsAffected, ret.Error
+}
+
+// Purge 彻底删除回收站中的用户
+func (c SshUser) Purge(id uint) error {
+	return Db.Unscoped().Delete(&c, "id = ? AND deleted_at IS NOT NULL", id).Error
+}
+
+// UpdateNotify 更新用户的通知设置,允许清空邮箱
+func (c SshUser) UpdateNotify(id uint, email, transcriptNotify string) error {
+	return Db.Model(&c).Where("id = ?", id).Updates(map[string]any{
+		"email":             email,
+		"transcript_notify": transcriptNotify,
+	}).Error
 }
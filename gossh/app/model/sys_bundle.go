@@ -0,0 +1,317 @@
+package model
+
+import (
+	"fmt"
+	"gossh/gorm"
+	"strconv"
+	"time"
+)
+
+// BundleVersion 配置导出包的数据格式版本
+const BundleVersion = 1
+
+// SysBundle 系统配置导出包
+type SysBundle struct {
+	Version       int            `json:"version"`
+	ExportedAt    string         `json:"exported_at"`
+	Users         []SshUser      `json:"users"`
+	SshConfs      []SshConf      `json:"conn_conf"`
+	ShellProfiles []ShellProfile `json:"shell_profiles"`
+	PolicyConf    *PolicyConf    `json:"policy_conf"`
+	NetGroups     []NetGroup     `json:"net_groups"`
+	NetFilters    []NetFilter    `json:"net_filters"`
+}
+
+// BundleResult 导入结果汇总
+type BundleResult struct {
+	Created map[string]int `json:"created"`
+	Updated map[string]int `json:"updated"`
+	Skipped map[string]int `json:"skipped"`
+}
+
+// ExportBundle 导出用户、主机配置、策略和网络过滤规则
+func ExportBundle() (SysBundle, error) {
+	bundle := SysBundle{
+		Version:    BundleVersion,
+		ExportedAt: time.Now().Format(TimeFormat),
+	}
+	if err := Db.Order("id asc").Find(&bundle.Users).Error; err != nil {
+		return bundle, err
+	}
+	if err := Db.Order("id asc").Find(&bundle.SshConfs).Error; err != nil {
+		return bundle, err
+	}
+	if err := Db.Order("id asc").Find(&bundle.ShellProfiles).Error; err != nil {
+		return bundle, err
+	}
+	if err := Db.Order("id asc").Find(&bundle.NetGroups).Error; err != nil {
+		return bundle, err
+	}
+	if err := Db.Order("id asc").Find(&bundle.NetFilters).Error; err != nil {
+		return bundle, err
+	}
+	var policy PolicyConf
+	if err := Db.Limit(1).Find(&policy, "id = ?", 1).Error; err != nil {
+		return bundle, err
+	}
+	if policy.ID != 0 {
+		bundle.PolicyConf = &policy
+	}
+	return bundle, nil
+}
+
+// ImportBundle 在一个事务中导入配置包
+// conflict 为已存在记录的处理方式: skip 跳过, overwrite 覆盖, fail 终止导入
+// 用户和地址组按名称匹配,主机配置和终端配置按所属用户和名称匹配,网络过滤规则按 cidr、国家代码和地址组匹配
+func ImportBundle(bundle SysBundle, conflict string) (BundleResult, error) {
+	result := BundleResult{Created: map[string]int{}, Updated: map[string]int{}, Skipped: map[string]int{}}
+	if bundle.Version < 1 || bundle.Version > BundleVersion {
+		return result, fmt.Errorf("unsupported bundle version: %d", bundle.Version)
+	}
+
+	// resolve 处理已存在的记录,返回是否需要覆盖
+	resolve := func(kind, key string, exists bool) (bool, error) {
+		if !exists {
+			return false, nil
+		}
+		switch conflict {
+		case "overwrite":
+			return true, nil
+		case "fail":
+			return false, fmt.Errorf("%s already exists: %s", kind, key)
+		default:
+			return false, nil
+		}
+	}
+
+	err := Db.Transaction(func(tx *gorm.DB) error {
+		// 导出包中的用户 ID 到当前用户 ID 的映射
+		uidMap := make(map[uint]uint, len(bundle.Users))
+		for _, item := range bundle.Users {
+			oldId := item.ID
+			var current SshUser
+			if item.IsRoot == "Y" {
+				// 超级管理员不导入,其主机配置归属当前的超级管理员
+				if err := tx.Limit(1).Find(&current, "is_root = ?", "Y").Error; err != nil {
+					return err
+				}
+				if current.ID != 0 {
+					uidMap[oldId] = current.ID
+				}
+				result.Skipped["users"]++
+				continue
+			}
+			if err := tx.Limit(1).Find(&current, "name = ?", item.Name).Error; err != nil {
+				return err
+			}
+			overwrite, err := resolve("user", item.Name, current.ID != 0)
+			if err != nil {
+				return err
+			}
+			switch {
+			case current.ID == 0:
+				item.ID = 0
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+				uidMap[oldId] = item.ID
+				result.Created["users"]++
+			case overwrite && current.IsRoot != "Y":
+				item.ID = current.ID
+				if err := tx.Model(&SshUser{}).Where("id = ?", current.ID).
+					Select("pwd", "desc_info", "is_admin", "is_enable", "email", "transcript_notify", "prod_access", "can_impersonate", "expiry_at").
+					Updates(&item).Error; err != nil {
+					return err
+				}
+				uidMap[oldId] = current.ID
+				result.Updated["users"]++
+			default:
+				uidMap[oldId] = current.ID
+				result.Skipped["users"]++
+			}
+		}
+
+		// 导出包中的终端配置 ID 到当前 ID 的映射
+		profileMap := make(map[uint]uint, len(bundle.ShellProfiles))
+		for _, item := range bundle.ShellProfiles {
+			oldId := item.ID
+			uid, ok := uidMap[item.Uid]
+			if !ok {
+				result.Skipped["shell_profiles"]++
+				continue
+			}
+			item.Uid = uid
+			var current ShellProfile
+			if err := tx.Limit(1).Find(&current, "uid = ? AND name = ?", uid, item.Name).Error; err != nil {
+				return err
+			}
+			overwrite, err := resolve("shell_profile", item.Name, current.ID != 0)
+			if err != nil {
+				return err
+			}
+			switch {
+			case current.ID == 0:
+				item.ID = 0
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+				profileMap[oldId] = item.ID
+				result.Created["shell_profiles"]++
+			case overwrite:
+				item.ID = current.ID
+				if err := tx.Model(&ShellProfile{}).Where("id = ?", current.ID).
+					Select("env_vars", "aliases", "ps1", "script", "is_default").
+					Updates(&item).Error; err != nil {
+					return err
+				}
+				profileMap[oldId] = current.ID
+				result.Updated["shell_profiles"]++
+			default:
+				profileMap[oldId] = current.ID
+				result.Skipped["shell_profiles"]++
+			}
+		}
+
+		for _, item := range bundle.SshConfs {
+			uid, ok := uidMap[item.Uid]
+			if !ok {
+				result.Skipped["conn_conf"]++
+				continue
+			}
+			item.Uid = uid
+			item.ShellProfileId = profileMap[item.ShellProfileId]
+			// 运行状态不导入
+			item.LastEndpoint, item.HealthStatus, item.HealthOutput = "", "", ""
+			item.HealthCheckAt = DateTime{}
+			item.ProbeStatus, item.ProbeLatency, item.ProbeAt = "", 0, DateTime{}
+			item.Facts, item.FactsAt = "", DateTime{}
+
+			var current SshConf
+			if err := tx.Limit(1).Find(&current, "uid = ? AND name = ?", uid, item.Name).Error; err != nil {
+				return err
+			}
+			overwrite, err := resolve("conn_conf", item.Name, current.ID != 0)
+			if err != nil {
+				return err
+			}
+			switch {
+			case current.ID == 0:
+				item.ID = 0
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+				result.Created["conn_conf"]++
+			case overwrite:
+				item.ID = current.ID
+				if err := tx.Model(&SshConf{}).Where("id = ?", current.ID).
+					Omit("id", "uid", "name", "created_at").Select("*").
+					Updates(&item).Error; err != nil {
+					return err
+				}
+				result.Updated["conn_conf"]++
+			default:
+				result.Skipped["conn_conf"]++
+			}
+		}
+
+		// 导出包中的地址组 ID 到当前地址组 ID 的映射
+		groupMap := make(map[uint]uint, len(bundle.NetGroups))
+		for _, item := range bundle.NetGroups {
+			oldId := item.ID
+			var current NetGroup
+			if err := tx.Limit(1).Find(&current, "name = ?", item.Name).Error; err != nil {
+				return err
+			}
+			overwrite, err := resolve("net_group", item.Name, current.ID != 0)
+			if err != nil {
+				return err
+			}
+			switch {
+			case current.ID == 0:
+				item.ID = 0
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+				groupMap[oldId] = item.ID
+				result.Created["net_groups"]++
+			case overwrite:
+				item.ID = current.ID
+				if err := tx.Model(&NetGroup{}).Where("id = ?", current.ID).
+					Select("cidrs", "desc_info").
+					Updates(&item).Error; err != nil {
+					return err
+				}
+				groupMap[oldId] = current.ID
+				result.Updated["net_groups"]++
+			default:
+				groupMap[oldId] = current.ID
+				result.Skipped["net_groups"]++
+			}
+		}
+
+		for _, item := range bundle.NetFilters {
+			// 引用的地址组不在导出包中时去掉引用
+			item.GroupId = groupMap[item.GroupId]
+			var current NetFilter
+			if err := tx.Limit(1).Find(&current, "cidr = ? AND country_code = ? AND group_id = ?", item.Cidr, item.CountryCode, item.GroupId).Error; err != nil {
+				return err
+			}
+			key := item.Cidr + item.CountryCode
+			if item.GroupId != 0 {
+				key += " group:" + strconv.FormatUint(uint64(item.GroupId), 10)
+			}
+			overwrite, err := resolve("net_filter", key, current.ID != 0)
+			if err != nil {
+				return err
+			}
+			switch {
+			case current.ID == 0:
+				item.ID = 0
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+				result.Created["net_filters"]++
+			case overwrite:
+				item.ID = current.ID
+				if err := tx.Model(&NetFilter{}).Where("id = ?", current.ID).
+					Select("name", "group_id", "action", "net_policy", "policy_no", "expiry_at").
+					Updates(&item).Error; err != nil {
+					return err
+				}
+				result.Updated["net_filters"]++
+			default:
+				result.Skipped["net_filters"]++
+			}
+		}
+
+		if policy := bundle.PolicyConf; policy != nil {
+			var current PolicyConf
+			if err := tx.Limit(1).Find(&current, "id = ?", 1).Error; err != nil {
+				return err
+			}
+			overwrite, err := resolve("policy_conf", "1", current.ID != 0)
+			if err != nil {
+				return err
+			}
+			policy.ID = 1
+			switch {
+			case current.ID == 0:
+				if err := tx.Create(policy).Error; err != nil {
+					return err
+				}
+				result.Created["policy_conf"]++
+			case overwrite:
+				if err := tx.Model(&PolicyConf{}).Where("id = ?", 1).
+					Omit("id", "created_at").Select("*").
+					Updates(policy).Error; err != nil {
+					return err
+				}
+				result.Updated["policy_conf"]++
+			default:
+				result.Skipped["policy_conf"]++
+			}
+		}
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,63 @@
+package model
+
+// Tenant 租户(组织),用户、主机策略、登录审计和变更申请按租户隔离
+// ID 为 0 的默认租户不保存在表中,Root 用户属于默认租户
+// Domain 为租户专用的访问域名,WebBaseDir 为租户专用的访问路径前缀(如 /acme),用于登录时确定租户
+type Tenant struct {
+	ID         uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Name       string   `gorm:"uniqueIndex;not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
+	DescInfo   string   `gorm:"not null;size:128;default:''" form:"desc_info" binding:"max=128" json:"desc_info"`
+	Domain     string   `gorm:"not null;size:255;default:'';index" form:"domain" binding:"max=255" json:"domain"`
+	WebBaseDir string   `gorm:"not null;size:128;default:''" form:"web_base_dir" binding:"max=128" json:"web_base_dir"`
+	IsEnable   string   `gorm:"not null;size:64;default:'Y'" form:"is_enable" binding:"required,oneof=Y N" json:"is_enable"`
+	CreatedAt  DateTime `gorm:"created_at" json:"created_at"`
+	UpdatedAt  DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c Tenant) Create(tenant *Tenant) error {
+	return Db.Create(tenant).Error
+}
+
+func (c Tenant) FindByID(id uint) (Tenant, error) {
+	var tenant Tenant
+	err := Db.First(&tenant, "id = ?", id).Error
+	return tenant, err
+}
+
+// FindAll 查询全部租户,用于按域名和路径匹配租户
+func (c Tenant) FindAll() ([]Tenant, error) {
+	var list []Tenant
+	err := ReadDb().Order("id").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c Tenant) FindPage(q PageQuery) ([]Tenant, int64, error) {
+	return findPage[Tenant](Db, q, pageSpec{
+		Sorts: []string{"id", "name", "domain", "is_enable", "created_at"},
+		Filters: map[string]string{
+			"name":      "like",
+			"domain":    "like",
+			"is_enable": "eq",
+		},
+		DefaultSort: "id asc",
+	})
+}
+
+// UpdateById 更新租户,允许清空域名和路径前缀
+func (c Tenant) UpdateById(id uint, tenant *Tenant) error {
+	return Db.Model(&c).Where("id = ?", id).
+		Select("name", "desc_info", "domain", "web_base_dir", "is_enable").
+		Updates(tenant).Error
+}
+
+func (c Tenant) DeleteByID(id uint) error {
+	return Db.Delete(&c, "id = ?", id).Error
+}
+
+// UserCount 租户下的用户数量,包括回收站中的用户
+func (c Tenant) UserCount(id uint) (int64, error) {
+	var count int64
+	err := Db.Unscoped().Model(&SshUser{}).Where("tenant_id = ?", id).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,59 @@
+package model
+
+// UserMacro 用户的按键宏,在终端中按下 KeyName 对应的按键时发送 Content
+type UserMacro struct {
+	ID      uint   `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid     uint   `gorm:"not null;default:0;index" form:"uid" json:"uid"`
+	Name    string `gorm:"not null;size:64" form:"name" binding:"required,min=1,max=63" json:"name"`
+	KeyName string `gorm:"not null;size:32" form:"key_name" binding:"required,min=1,max=32" json:"key_name"`
+	Content string `gorm:"type:text" form:"content" binding:"required,max=4096" json:"content"`
+	Enabled string `gorm:"not null;size:64;default:'Y'" form:"enabled" binding:"required,oneof=Y N" json:"enabled"`
+
+	CreatedAt DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt DateTime `gorm:"updated_at" json:"-"`
+}
+
+func (c UserMacro) Create(macro *UserMacro) error {
+	return Db.Create(macro).Error
+}
+
+func (c UserMacro) FindByID(id uint, uid uint) (UserMacro, error) {
+	var macro UserMacro
+	err := Db.First(&macro, "id = ? AND uid = ?", id, uid).Error
+	return macro, err
+}
+
+// FindByKey 查询用户使用该按键的宏,检查按键是否重复
+func (c UserMacro) FindByKey(uid uint, keyName string) (UserMacro, error) {
+	var macro UserMacro
+	err := Db.First(&macro, "uid = ? AND key_name = ?", uid, keyName).Error
+	return macro, err
+}
+
+// FindEnabled 用户启用的全部宏,会话开始时加载
+func (c UserMacro) FindEnabled(uid uint) ([]UserMacro, error) {
+	var list []UserMacro
+	err := Db.Where("uid = ? AND enabled = ?", uid, "Y").Order("id").Find(&list).Error
+	return list, err
+}
+
+// FindPage 分页查询,返回当前页数据和总数
+func (c UserMacro) FindPage(q PageQuery, uid uint) ([]UserMacro, int64, error) {
+	return findPage[UserMacro](ReadDb().Where("uid = ?", uid), q, pageSpec{
+		Sorts: []string{"id", "name", "key_name", "updated_at"},
+		Filters: map[string]string{
+			"name":     "like",
+			"key_name": "eq",
+			"enabled":  "eq",
+		},
+		DefaultSort: "key_name",
+	})
+}
+
+func (c UserMacro) UpdateById(id, uid uint, macro *UserMacro) error {
+	return Db.Model(&c).Where("id = ? AND uid = ?", id, uid).Select("name", "key_name", "content", "enabled").Updates(macro).Error
+}
+
+func (c UserMacro) DeleteByID(id, uid uint) error {
+	return Db.Unscoped().Delete(&c, "id = ? AND uid = ?", id, uid).Error
+}
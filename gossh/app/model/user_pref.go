@@ -0,0 +1,70 @@
+package model
+
+import "errors"
+
+// UserPref 用户的终端偏好设置,每个用户一条记录,在不同浏览器之间共享
+// Keymap 为自定义快捷键,JSON 格式由前端解析
+type UserPref struct {
+	ID          uint     `gorm:"primaryKey,autoIncrement" form:"id" json:"id"`
+	Uid         uint     `gorm:"not null;uniqueIndex" form:"uid" json:"uid"`
+	Theme       string   `gorm:"not null;size:64;default:'default'" form:"theme" binding:"required,min=1,max=64" json:"theme"`
+	FontSize    uint16   `gorm:"not null;default:14" form:"font_size" binding:"required,gte=8,lte=48" json:"font_size"`
+	FontFamily  string   `gorm:"not null;size:128;default:'Courier'" form:"font_family" binding:"required,min=1,max=128" json:"font_family"`
+	CursorStyle string   `gorm:"not null;size:64;default:'block'" form:"cursor_style" binding:"required,oneof=block underline bar" json:"cursor_style"`
+	CursorBlink string   `gorm:"not null;size:64;default:'Y'" form:"cursor_blink" binding:"required,oneof=Y N" json:"cursor_blink"`
+	Scrollback  uint     `gorm:"not null;default:1000" form:"scrollback" binding:"lte=100000" json:"scrollback"`
+	Bell        string   `gorm:"not null;size:64;default:'none'" form:"bell" binding:"required,oneof=none sound visual" json:"bell"`
+	Keymap      string   `gorm:"type:text" form:"keymap" binding:"max=65535" json:"keymap"`
+	CreatedAt   DateTime `gorm:"created_at" json:"-"`
+	UpdatedAt   DateTime `gorm:"updated_at" json:"-"`
+}
+
+// DefaultUserPref 用户未保存设置时使用的默认值
+func DefaultUserPref(uid uint) UserPref {
+	return UserPref{
+		Uid:         uid,
+		Theme:       "default",
+		FontSize:    14,
+		FontFamily:  "Courier",
+		CursorStyle: "block",
+		CursorBlink: "Y",
+		Scrollback:  1000,
+		Bell:        "none",
+	}
+}
+
+// FindByUid 查询用户的设置,没有保存过时返回默认值
+func (c UserPref) FindByUid(uid uint) (UserPref, error) {
+	var list []UserPref
+	if err := Db.Where("uid = ?", uid).Limit(1).Find(&list).Error; err != nil {
+		return UserPref{}, err
+	}
+	if len(list) == 0 {
+		return DefaultUserPref(uid), nil
+	}
+	return list[0], nil
+}
+
+// Save 保存用户的设置,不存在时创建
+func (c UserPref) Save(uid uint, pref *UserPref) error {
+	if uid == 0 {
+		return errors.New("uid is empty")
+	}
+	pref.Uid = uid
+	var count int64
+	if err := Db.Model(&UserPref{}).Where("uid = ?", uid).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		pref.ID = 0
+		return Db.Create(pref).Error
+	}
+	// 指定字段更新,零值也会写入
+	return Db.Model(&UserPref{}).Where("uid = ?", uid).
+		Select("theme", "font_size", "font_family", "cursor_style", "cursor_blink", "scrollback", "bell", "keymap").
+		Updates(pref).Error
+}
+
+func (c UserPref) DeleteByUid(uid uint) error {
+	return Db.Unscoped().Delete(&c, "uid = ?", uid).Error
+}
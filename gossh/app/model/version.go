@@ -0,0 +1,35 @@
+package model
+
+import (
+	"errors"
+	"gossh/gorm"
+)
+
+// ErrVersionConflict 记录已被其他人修改
+var ErrVersionConflict = errors.New("version conflict")
+
+// updateVersioned 乐观锁更新,只更新版本号与 version 一致的记录,并将版本号加1
+// version 为0时使用数据库中的当前版本号,兼容不提交版本号的客户端
+func updateVersioned(db *gorm.DB, value any, version *uint) error {
+	db = db.Session(&gorm.Session{})
+	if *version == 0 {
+		var current []uint
+		if err := db.Pluck("version", &current).Error; err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			return nil
+		}
+		*version = current[0]
+	}
+	expect := *version
+	*version = expect + 1
+	ret := db.Where("version = ?", expect).Updates(value)
+	if ret.Error != nil {
+		return ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
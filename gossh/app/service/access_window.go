@@ -0,0 +1,60 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"log/slog"
+	"time"
+)
+
+// checkAccessWindow 检查用户当前是否在策略允许的访问时间段内,管理员不受限制
+func checkAccessWindow(uid uint, policy model.PolicyConf, now time.Time) bool {
+	if policy.InAccessWindow(now) {
+		return true
+	}
+	var user model.SshUser
+	u, err := user.FindByID(uid)
+	if err != nil {
+		slog.Error("checkAccessWindow FindByID error:", "err_msg", err.Error())
+		return false
+	}
+	return u.IsAdmin == "Y"
+}
+
+// isAccessAllowed 使用当前策略检查用户是否可以连接主机
+func isAccessAllowed(uid uint) bool {
+	policy, err := userPolicy(uid)
+	if err != nil {
+		slog.Error("isAccessAllowed userPolicy error:", "err_msg", err.Error())
+		return true
+	}
+	return checkAccessWindow(uid, policy, time.Now())
+}
+
+// 断开超出允许访问时间段的会话,超出后保留 WindowGrace 宽限时长,返回断开的会话数量
+func cleanOutOfWindowSession() (count int) {
+	defer utils.RecoverPanic("cleanOutOfWindowSession")
+	now := time.Now()
+	grace := config.DefaultConfig.SessionClean.WindowGrace
+	policies := tenantPolicies()
+	OnlineClients.Range(func(key, value any) bool {
+		if conn, ok := value.(*SshConn); ok && conn.SshConf != nil {
+			if policy, ok := policies(conn.tenantId); ok && !checkAccessWindow(conn.Uid, policy, now) {
+				if conn.outOfWindowAt.IsZero() {
+					conn.outOfWindowAt = now
+				}
+				if now.Sub(conn.outOfWindowAt) >= grace {
+					slog.Info("clean out of access window session:", "sid", conn.SessionId)
+					conn.setCloseReason("outside access window")
+					DeleteOnlineClient(conn.SessionId)
+					count++
+				}
+			} else {
+				conn.outOfWindowAt = time.Time{}
+			}
+		}
+		return true
+	})
+	return count
+}
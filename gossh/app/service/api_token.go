@@ -0,0 +1,74 @@
+package service
+
+import (
+	"gossh/app/middleware"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+func ApiTokenCreate(c *gin.Context) {
+	var apiToken model.ApiToken
+	if err := c.ShouldBind(&apiToken); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if apiToken.ExpiryAt.ToTime().Before(time.Now()) {
+		c.JSON(200, gin.H{"code": 1, "msg": "过期时间必须晚于当前时间"})
+		return
+	}
+	if c.GetUint("impersonator") != 0 {
+		c.JSON(200, gin.H{"code": 2, "msg": "模拟登录时不能执行该操作"})
+		return
+	}
+
+	token, err := middleware.GenerateApiToken()
+	if err != nil {
+		slog.Error("GenerateApiToken error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": "生成令牌错误"})
+		return
+	}
+	apiToken.ID = 0
+	apiToken.Uid = c.GetUint("uid")
+	apiToken.TokenHash = middleware.HashApiToken(token)
+	apiToken.Prefix = token[:len(middleware.ApiTokenPrefix)+6]
+	apiToken.IsRevoked = "N"
+	if err := apiToken.Create(&apiToken); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	// 令牌明文只返回一次
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": apiToken, "token": token})
+}
+
+func ApiTokenFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var apiToken model.ApiToken
+	data, total, err := apiToken.FindPage(q, c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func ApiTokenRevoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var apiToken model.ApiToken
+	err = apiToken.Revoke(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	ApiTokenFindAll(c)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 审批状态轮询间隔
+const approvalPollInterval = time.Second * 2
+
+// 比较审批主机地址时解析域名的超时时间
+const approvalResolveTimeout = time.Second * 3
+
+// approvalEndpoints 主地址和备用地址,域名同时解析为 IP,通过别名或备用地址连接同一主机时也能匹配
+func approvalEndpoints(ctx context.Context, conf *model.SshConf) map[string]bool {
+	set := map[string]bool{}
+	for _, e := range sshEndpoints(conf) {
+		host, port, err := net.SplitHostPort(e.addr)
+		if err != nil {
+			continue
+		}
+		set[net.JoinHostPort(strings.ToLower(host), port)] = true
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			set[net.JoinHostPort(addr.IP.String(), port)] = true
+		}
+	}
+	return set
+}
+
+// endpointNeedApproval 连接的任一地址与需要审批的主机配置的任一地址相同,查询失败时按需要审批处理
+func endpointNeedApproval(conn *SshConn) bool {
+	var sshConf model.SshConf
+	list, err := sshConf.FindAllNeedApproval()
+	if err != nil {
+		slog.Error("FindAllNeedApproval error:", "err_msg", err.Error())
+		return true
+	}
+	if len(list) == 0 {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), approvalResolveTimeout)
+	defer cancel()
+	target := approvalEndpoints(ctx, conn.SshConf)
+	for i := range list {
+		for endpoint := range approvalEndpoints(ctx, &list[i]) {
+			if target[endpoint] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// needApproval 检查会话是否需要管理员审批,管理员本人不需要审批
+func needApproval(conn *SshConn) bool {
+	var user model.SshUser
+	u, err := user.FindByID(conn.Uid)
+	if err == nil && u.IsAdmin == "Y" {
+		return false
+	}
+
+	policy, err := userPolicy(conn.Uid)
+	if err == nil && policy.NeedApproval == "Y" && policyTargets(policy, conn) {
+		return true
+	}
+
+	// 任一主机配置要求审批的地址,临时填写的连接和通过别名、备用地址的连接同样需要审批
+	if endpointNeedApproval(conn) {
+		return true
+	}
+
+	// 已保存的主机以数据库中的配置为准,防止客户端绕过
+	var sshConf model.SshConf
+	if conn.ID != 0 {
+		conf, err := sshConf.FindByID(conn.ID, conn.Uid)
+		if err == nil {
+			return conf.NeedApproval == "Y"
+		}
+	}
+	return conn.NeedApproval == "Y"
+}
+
+// waitApproval 创建审批申请并等待管理员处理
+func waitApproval(conn *SshConn, ws *termWs) error {
+	var user model.SshUser
+	u, _ := user.FindByID(conn.Uid)
+
+	approval := model.Approval{
+		Uid:       conn.Uid,
+		UserName:  u.Name,
+		SessionId: conn.SessionId,
+		ClientIp:  conn.ClientIP,
+		Address:   conn.Address,
+		SshUser:   conn.User,
+		Port:      conn.Port,
+		Status:    "pending",
+		ExpiryAt:  model.DateTime(time.Now().Add(config.DefaultConfig.ApprovalExpire)),
+	}
+	if err := approval.Create(&approval); err != nil {
+		slog.Error("approval.Create error:", "err_msg", err.Error())
+		return err
+	}
+	slog.Info("approval pending", "id", approval.ID, "user", approval.UserName, "address", approval.Address, "client_ip", approval.ClientIp)
+	ws.Notice(fmt.Sprintf("等待管理员审批,申请编号:%d\r\n", approval.ID))
+	notifyEvent(EventApproval, "连接审批申请",
+		fmt.Sprintf("申请编号: %d\n用户: %s\n主机: %s@%s:%d\n客户端IP: %s",
+			approval.ID, approval.UserName, approval.SshUser, approval.Address, approval.Port, approval.ClientIp))
+
+	for {
+		time.Sleep(approvalPollInterval)
+		if _, ok := OnlineClients.Load(conn.SessionId); !ok {
+			return errors.New("会话已关闭")
+		}
+		// 等待审批期间保持会话活跃
+		conn.LastActiveTime = time.Now()
+
+		data, err := approval.FindByID(approval.ID)
+		if err != nil {
+			slog.Error("approval.FindByID error:", "err_msg", err.Error())
+			return err
+		}
+		switch data.Status {
+		case "approved":
+			ws.Notice(fmt.Sprintf("审批通过,审批人:%s\r\n", data.Approver))
+			return nil
+		case "rejected":
+			return fmt.Errorf("审批被拒绝:%s", data.Comment)
+		case "expired":
+			return errors.New("审批已过期")
+		}
+		if data.ExpiryAt.ToTime().Before(time.Now()) {
+			_ = approval.ExpirePending()
+			return errors.New("审批已过期")
+		}
+	}
+}
+
+func ApprovalFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+
+	// 非管理员只能查看自己的申请
+	uid := uint(0)
+	if !isPlatformAdmin(u) {
+		uid = u.ID
+	}
+
+	var approval model.Approval
+	_ = approval.ExpirePending()
+	if status := c.Query("status"); status != "" {
+		q.Filters["status"] = status
+	}
+	data, total, err := approval.FindPage(q, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func ApprovalFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	var approval model.Approval
+	data, err := approval.FindByID(uint(id))
+	if err != nil || (!isPlatformAdmin(u) && data.Uid != u.ID) {
+		c.JSON(200, gin.H{"code": 4, "msg": "获取审批信息错误"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+func ApprovalApprove(c *gin.Context) {
+	approvalDecide(c, "approved")
+}
+
+func ApprovalReject(c *gin.Context) {
+	approvalDecide(c, "rejected")
+}
+
+func approvalDecide(c *gin.Context, status string) {
+	type Param struct {
+		ID      uint   `form:"id" binding:"required,gte=1" json:"id"`
+		Comment string `form:"comment" binding:"max=512" json:"comment"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || !isPlatformAdmin(u) {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+
+	var approval model.Approval
+	rows, err := approval.UpdateStatus(param.ID, &model.Approval{
+		Status:     status,
+		ApproverId: u.ID,
+		Approver:   u.Name,
+		Comment:    param.Comment,
+	})
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if rows == 0 {
+		c.JSON(200, gin.H{"code": 4, "msg": "申请不存在、已处理或已过期"})
+		return
+	}
+	slog.Info("approval decided", "id", param.ID, "status", status, "approver", u.Name)
+
+	data, err := approval.FindByID(param.ID)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 5, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
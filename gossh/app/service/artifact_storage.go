@@ -0,0 +1,173 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+)
+
+// ArtifactStorage 会话录像等文件的存储,文件先写到本地,会话结束后转存
+type ArtifactStorage interface {
+	// Put 将本地文件保存到存储中的 filePath
+	Put(filePath, localPath string) error
+	Open(filePath string) (io.ReadCloser, error)
+	Remove(filePath string) error
+}
+
+// localStorage 文件保存在本地磁盘,路径为文件的绝对路径
+type localStorage struct{}
+
+func (s localStorage) Put(filePath, localPath string) error {
+	if filePath == localPath {
+		return nil
+	}
+	return os.Rename(localPath, filePath)
+}
+
+func (s localStorage) Open(filePath string) (io.ReadCloser, error) {
+	return os.Open(filePath)
+}
+
+func (s localStorage) Remove(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// s3Storage 文件保存在兼容 S3 协议的对象存储,路径为对象的 key
+type s3Storage struct {
+	client *utils.S3Client
+}
+
+func (s s3Storage) Put(filePath, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return s.client.PutObject(filePath, file, info.Size())
+}
+
+func (s s3Storage) Open(filePath string) (io.ReadCloser, error) {
+	return s.client.GetObject(filePath)
+}
+
+func (s s3Storage) Remove(filePath string) error {
+	return s.client.DeleteObject(filePath)
+}
+
+func newS3Client(conf config.S3Conf) *utils.S3Client {
+	return &utils.S3Client{
+		Endpoint:  conf.Endpoint,
+		Region:    conf.Region,
+		Bucket:    conf.Bucket,
+		AccessKey: conf.AccessKey,
+		SecretKey: conf.SecretKey,
+		PathStyle: conf.PathStyle,
+	}
+}
+
+// artifactStorage 按存储类型获取存储,已保存文件的存储类型以记录中的为准
+func artifactStorage(kind string) ArtifactStorage {
+	if kind == "s3" {
+		return s3Storage{client: newS3Client(config.DefaultConfig.Storage.S3)}
+	}
+	return localStorage{}
+}
+
+// storageType 新文件使用的存储类型
+func storageType() string {
+	if conf := config.DefaultConfig.Storage; conf.Type == "s3" && conf.S3.Bucket != "" {
+		return "s3"
+	}
+	return "local"
+}
+
+// storeArtifact 将本地文件转存到当前配置的存储,成功后删除本地文件
+func storeArtifact(name, localPath string) (string, string, error) {
+	kind := storageType()
+	if kind == "local" {
+		return kind, localPath, nil
+	}
+	filePath := config.DefaultConfig.Storage.S3.Prefix + name
+	if err := artifactStorage(kind).Put(filePath, localPath); err != nil {
+		return "", "", err
+	}
+	if err := os.Remove(localPath); err != nil {
+		slog.Error("remove local artifact error:", "path", localPath, "err_msg", err.Error())
+	}
+	return kind, filePath, nil
+}
+
+// fetchArtifact 将存储中的文件下载到本地临时文件,调用方负责删除
+func fetchArtifact(kind, filePath string) (string, error) {
+	if kind != "s3" {
+		return filePath, nil
+	}
+	reader, err := artifactStorage(kind).Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if err := os.MkdirAll(RecordDir, os.FileMode(0700)); err != nil {
+		return "", err
+	}
+	localPath := path.Join(RecordDir, path.Base(filePath)+".fetch")
+	file, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, reader)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(localPath)
+		return "", err
+	}
+	return localPath, nil
+}
+
+// SetStorageConf PUT 修改文件存储配置,只影响新保存的文件
+func SetStorageConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var storage config.Storage
+	if err := c.ShouldBindJSON(&storage); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if storage.Type == "" {
+		storage.Type = "local"
+	}
+	if storage.Type == "s3" && (storage.S3.Endpoint == "" || storage.S3.Bucket == "") {
+		c.JSON(200, gin.H{"code": 1, "msg": "对象存储需要配置 endpoint 和 bucket"})
+		return
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Storage = storage
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("storage config updated", "user", u.Name, "type", storage.Type)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Storage})
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"math"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// 分区提前创建的月数
+const auditPartitionAhead = 3
+
+// 每批归档的行数
+const auditArchiveBatch = 1000
+
+// 同一时间只允许一个审计日志清理任务
+var auditMu sync.Mutex
+
+// AuditRetentionResult 一张审计表的清理结果
+type AuditRetentionResult struct {
+	Table      string   `json:"table"`
+	Archived   int64    `json:"archived"`
+	Deleted    int64    `json:"deleted"`
+	Dropped    []string `json:"dropped"`
+	Archives   []string `json:"archives"`
+	Partitions int      `json:"partitions"`
+}
+
+func auditArchiveDir() string {
+	if dir := config.DefaultConfig.Audit.Dir; dir != "" {
+		return dir
+	}
+	return path.Join(config.WorkDir, "audit_archive")
+}
+
+func auditS3() *utils.S3Client {
+	if config.DefaultConfig.Audit.S3.Bucket == "" {
+		return nil
+	}
+	return newS3Client(config.DefaultConfig.Audit.S3)
+}
+
+// archiveAuditRows 将满足条件的数据导出为 gzip 压缩的 NDJSON 后删除,未开启归档时直接删除
+// 返回归档文件名,没有数据时为空
+func archiveAuditRows(table model.AuditTable, where string, args []any) (string, int64, error) {
+	if !config.DefaultConfig.Audit.Archive {
+		deleted, err := table.DeleteUpTo(where, args, math.MaxUint)
+		return "", deleted, err
+	}
+
+	if err := os.MkdirAll(auditArchiveDir(), os.FileMode(0700)); err != nil {
+		return "", 0, err
+	}
+	name := fmt.Sprintf("%s_%s.ndjson.gz", table.Name, time.Now().Format("20060102150405.000000"))
+	filePath := path.Join(auditArchiveDir(), name)
+	file, err := os.OpenFile(filePath+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return "", 0, err
+	}
+	gz := gzip.NewWriter(file)
+	encoder := json.NewEncoder(gz)
+	var lastId uint
+	var count int64
+	for {
+		list, batchLastId, e := table.FindBatch(where, args, lastId, auditArchiveBatch)
+		if e != nil {
+			err = e
+			break
+		}
+		for _, row := range list {
+			if e := encoder.Encode(row); e != nil {
+				err = e
+				break
+			}
+			count++
+		}
+		lastId = batchLastId
+		if err != nil || len(list) < auditArchiveBatch {
+			break
+		}
+	}
+	if e := gz.Close(); err == nil {
+		err = e
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil || count == 0 {
+		_ = os.Remove(filePath + ".tmp")
+		return "", 0, err
+	}
+	if err := os.Rename(filePath+".tmp", filePath); err != nil {
+		return "", 0, err
+	}
+	if s3 := auditS3(); s3 != nil {
+		if err := uploadBackup(s3, config.DefaultConfig.Audit.S3.Prefix+name, filePath); err != nil {
+			return "", 0, err
+		}
+		_ = os.Remove(filePath)
+	}
+
+	// 只删除已经归档的数据,归档期间新增的数据留到下次
+	deleted, err := table.DeleteUpTo(where, args, lastId)
+	return name, deleted, err
+}
+
+// applyAuditRetention 对一张审计表执行分区维护、按时间和按行数的清理
+func applyAuditRetention(table model.AuditTable, now time.Time) (AuditRetentionResult, error) {
+	conf := config.DefaultConfig.Audit
+	result := AuditRetentionResult{Table: table.Name, Dropped: []string{}, Archives: []string{}}
+	cutoff := now.Add(-conf.Retention)
+
+	collect := func(name string, deleted int64) {
+		if name != "" {
+			result.Archives = append(result.Archives, name)
+			result.Archived += deleted
+		}
+		result.Deleted += deleted
+	}
+
+	if conf.Partition {
+		partitioned, err := table.IsPartitioned()
+		if err != nil {
+			return result, err
+		}
+		if !partitioned {
+			slog.Info("audit table partitioning", "table", table.Name)
+			if err := table.Partition(now, auditPartitionAhead); err != nil {
+				return result, fmt.Errorf("partition %s: %w", table.Name, err)
+			}
+		}
+		if err := table.EnsurePartitions(now, auditPartitionAhead); err != nil {
+			return result, err
+		}
+		list, err := table.Partitions()
+		if err != nil {
+			return result, err
+		}
+		result.Partitions = len(list)
+		// 整个分区都超过保留时长时先归档,再删除分区
+		for _, p := range list {
+			if conf.Retention <= 0 || p.To.After(cutoff) {
+				continue
+			}
+			if conf.Archive {
+				name, deleted, err := archiveAuditRows(table, table.TimeColumn+" >= ? AND "+table.TimeColumn+" < ?", []any{p.From, p.To})
+				if err != nil {
+					return result, err
+				}
+				collect(name, deleted)
+			}
+			if err := table.DropPartition(p); err != nil {
+				return result, err
+			}
+			result.Dropped = append(result.Dropped, p.Name)
+			result.Partitions--
+		}
+	}
+
+	if conf.Retention > 0 {
+		name, deleted, err := archiveAuditRows(table, table.TimeColumn+" < ?", []any{cutoff})
+		if err != nil {
+			return result, err
+		}
+		collect(name, deleted)
+	}
+
+	if conf.MaxRows > 0 {
+		maxId, ok, err := table.RowsCutoffId(conf.MaxRows)
+		if err != nil {
+			return result, err
+		}
+		if ok {
+			name, deleted, err := archiveAuditRows(table, "id <= ?", []any{maxId})
+			if err != nil {
+				return result, err
+			}
+			collect(name, deleted)
+		}
+	}
+	return result, nil
+}
+
+// runAuditRetention 对所有审计表执行保留策略
+func runAuditRetention() ([]AuditRetentionResult, error) {
+	if !auditMu.TryLock() {
+		return nil, errors.New("审计日志清理任务正在进行中")
+	}
+	defer auditMu.Unlock()
+
+	now := time.Now()
+	var results []AuditRetentionResult
+	var errs []error
+	for _, table := range model.AuditTables {
+		result, err := applyAuditRetention(table, now)
+		results = append(results, result)
+		if err != nil {
+			slog.Error("audit retention error:", "table", table.Name, "err_msg", err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("audit retention finished", "table", table.Name, "archived", result.Archived, "deleted", result.Deleted, "dropped", result.Dropped)
+	}
+	return results, errors.Join(errs...)
+}
+
+func auditRetentionLoop() {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(next))
+		conf := config.DefaultConfig.Audit
+		if !backgroundEnabled() || conf.Cron == "" || (conf.Retention <= 0 && conf.MaxRows <= 0 && !conf.Partition) {
+			continue
+		}
+		schedule, err := utils.ParseCron(conf.Cron)
+		if err != nil {
+			slog.Error("audit cron error:", "err_msg", err.Error())
+			continue
+		}
+		if schedule.Match(next) {
+			_, _ = runAuditRetention()
+		}
+	}
+}
+
+// AuditRetentionRun POST 立即执行审计日志保留策略
+func AuditRetentionRun(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	results, err := runAuditRetention()
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error(), "data": results})
+		return
+	}
+	slog.Info("audit retention run", "operator", u.Name)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": results})
+}
+
+// GetAuditConf GET 审计日志保留配置和下次执行时间
+func GetAuditConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	conf := config.DefaultConfig.Audit
+	nextRun := ""
+	if schedule, err := utils.ParseCron(conf.Cron); err == nil {
+		if next := schedule.Next(time.Now()); !next.IsZero() {
+			nextRun = next.Format(model.TimeFormat)
+		}
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": conf, "next_run": nextRun})
+}
+
+// SetAuditConf PUT 修改审计日志保留配置,立即生效并写入配置文件
+func SetAuditConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var audit config.Audit
+	if err := c.ShouldBindJSON(&audit); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if audit.Cron != "" {
+		if _, err := utils.ParseCron(audit.Cron); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+			return
+		}
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Audit = audit
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("audit config updated", "user", u.Name, "cron", audit.Cron, "retention", audit.Retention, "max_rows", audit.MaxRows, "partition", audit.Partition)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Audit})
+}
+
+func init() {
+	go auditRetentionLoop()
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/sftp"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 后台连接主机的超时时间
+const batchDialTimeout = 30 * time.Second
+
+// checkBatchHost 后台连接前的访问检查,与打开终端时的检查一致,需要审批或用户输入的主机不支持后台连接
+func checkBatchHost(conn *SshConn, confirm string) error {
+	if !isAccessAllowed(conn.Uid) {
+		return errors.New("当前时间不在允许访问的时间段内")
+	}
+	if err := checkProdAccess(conn, confirm); err != nil {
+		return err
+	}
+	if err := checkCredCheckout(conn); err != nil {
+		return err
+	}
+	if needApproval(conn) {
+		return errors.New("需要审批的主机不支持批量操作")
+	}
+	if conn.AuthType == "interactive" || needPassphrase(conn.SshConf) {
+		return errors.New("需要输入认证信息的主机不支持批量操作")
+	}
+	return nil
+}
+
+// openBatchConn 检查访问权限后建立不带终端的连接,用于流水线、文件分发等批量操作,用完后调用 closeBatchConn
+// confirm 为用户已确认的生产环境主机地址
+func openBatchConn(conf model.SshConf, sessionId, clientIp string, confirm []string) (*SshConn, error) {
+	conn := &SshConn{SshConf: &conf, SessionId: sessionId, ClientIP: clientIp, StartTime: time.Now()}
+	var user model.SshUser
+	if u, err := user.FindByID(conf.Uid); err == nil {
+		conn.tenantId = u.TenantId
+	}
+	if err := checkBatchHost(conn, prodConfirmed(conn, confirm)); err != nil {
+		return nil, err
+	}
+	client, err := dialSshConf(&conf, batchDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.sshClient = client
+	logAccess(conn, model.AccessConnect, "", 0)
+	return conn, nil
+}
+
+func closeBatchConn(conn *SshConn) {
+	if conn.sftpClient != nil {
+		_ = conn.sftpClient.Close()
+	}
+	_ = conn.sshClient.Close()
+	runPostDisconnect(conn.SshConf)
+}
+
+// batchSftp 第一次读写文件时创建 sftp 客户端
+func batchSftp(conn *SshConn) error {
+	if conn.sftpClient != nil {
+		return nil
+	}
+	client, err := sftp.NewClient(conn.sshClient)
+	if err != nil {
+		return err
+	}
+	conn.sftpClient = client
+	return nil
+}
+
+// batchWriteFile 通过 sftp 写入文件,按策略校验路径,mode 不为 0 时修改文件权限
+// 文件内容需要调用方在写入前扫描
+func batchWriteFile(conn *SshConn, p string, r io.Reader, mode os.FileMode) (int64, error) {
+	if err := batchSftp(conn); err != nil {
+		return 0, err
+	}
+	if err := checkSftpPath(conn, p, true); err != nil {
+		return 0, err
+	}
+	file, err := conn.sftpClient.Create(p)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return size, err
+	}
+	if mode != 0 {
+		if err := conn.sftpClient.Chmod(p, mode); err != nil {
+			return size, err
+		}
+	}
+	logAccess(conn, model.AccessUpload, p, size)
+	return size, nil
+}
+
+// batchReadFile 通过 sftp 读取文件,按策略校验路径,文件超过 maxSize 时返回错误
+func batchReadFile(conn *SshConn, p string, maxSize int64) ([]byte, os.FileInfo, error) {
+	if err := batchSftp(conn); err != nil {
+		return nil, nil, err
+	}
+	if err := checkSftpPath(conn, p, false); err != nil {
+		return nil, nil, err
+	}
+	file, err := conn.sftpClient.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return nil, nil, errors.New("不能读取目录")
+	}
+	if info.Size() > maxSize {
+		return nil, nil, fmt.Errorf("文件超过 %d 字节", maxSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, nil, fmt.Errorf("文件超过 %d 字节", maxSize)
+	}
+	logAccess(conn, model.AccessDownload, p, int64(len(data)))
+	return data, info, nil
+}
+
+// parseFileMode 解析八进制的文件权限,为空时返回 0
+func parseFileMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || v > 0o7777 {
+		return 0, fmt.Errorf("文件权限错误: %s", mode)
+	}
+	return os.FileMode(v), nil
+}
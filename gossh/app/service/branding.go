@@ -0,0 +1,119 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 页脚链接的最大数量
+const brandingMaxLinks = 20
+
+var logoDataRe = regexp.MustCompile(`^data:image/(png|jpeg|gif|webp|svg\+xml|x-icon);base64,[A-Za-z0-9+/=]+$`)
+
+// FooterLink 页脚链接
+type FooterLink struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// validBrandUrl 只允许 http(s) 地址和站内路径,防止 javascript: 等协议
+func validBrandUrl(s string) bool {
+	if strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// checkBranding 校验 logo 和页脚链接
+func checkBranding(branding *model.Branding) error {
+	branding.ProductName = strings.TrimSpace(branding.ProductName)
+	branding.Logo = strings.TrimSpace(branding.Logo)
+	if branding.Logo != "" && !logoDataRe.MatchString(branding.Logo) && !validBrandUrl(branding.Logo) {
+		return errors.New("logo 必须是图片地址或 data:image 格式")
+	}
+	if strings.TrimSpace(branding.FooterLinks) == "" {
+		branding.FooterLinks = ""
+		return nil
+	}
+	var links []FooterLink
+	if err := json.Unmarshal([]byte(branding.FooterLinks), &links); err != nil {
+		return errors.New("页脚链接必须是 JSON 数组")
+	}
+	if len(links) > brandingMaxLinks {
+		return errors.New("页脚链接数量超过限制")
+	}
+	for i := range links {
+		links[i].Name = strings.TrimSpace(links[i].Name)
+		if links[i].Name == "" || len(links[i].Name) > 64 || !validBrandUrl(links[i].Url) {
+			return errors.New("页脚链接格式错误")
+		}
+	}
+	data, _ := json.Marshal(links)
+	branding.FooterLinks = string(data)
+	return nil
+}
+
+// loadBranding 查询白标配置,未初始化或未设置产品名称时使用系统名称
+func loadBranding() model.Branding {
+	var branding model.Branding
+	if config.DefaultConfig.IsInit && model.Db != nil {
+		data, err := branding.Find()
+		if err != nil {
+			slog.Error("Branding.Find error:", "err_msg", err.Error())
+		}
+		branding = data
+	}
+	if branding.ProductName == "" {
+		branding.ProductName = config.DefaultConfig.AppName
+	}
+	return branding
+}
+
+// BrandingGet GET 白标配置,登录页面使用,无需登录
+func BrandingGet(c *gin.Context) {
+	branding := loadBranding()
+	links := []FooterLink{}
+	if branding.FooterLinks != "" {
+		_ = json.Unmarshal([]byte(branding.FooterLinks), &links)
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"product_name":  branding.ProductName,
+		"logo":          branding.Logo,
+		"login_message": branding.LoginMessage,
+		"footer_links":  links,
+	}})
+}
+
+// BrandingSet PUT 设置白标配置
+func BrandingSet(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var branding model.Branding
+	if err := c.ShouldBind(&branding); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkBranding(&branding); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := branding.Save(&branding); err != nil {
+		slog.Error("Branding.Save error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("branding updated", "user", u.Name, "product_name", branding.ProductName)
+	BrandingGet(c)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/model"
+)
+
+// removeRecords 删除录像文件和记录,每个录像算一步
+func removeRecords(job *Job, list []model.SessionRecord) {
+	job.AddTotal(len(list))
+	for _, record := range list {
+		err := removeRecord(record)
+		var deleted int64
+		if err == nil {
+			deleted = 1
+		}
+		job.Progress("session_record", deleted, err)
+	}
+}
+
+// runCascadeSteps 依次删除关联数据,出错时继续执行后面的步骤
+func runCascadeSteps(job *Job, steps []model.CascadeStep) {
+	for _, step := range steps {
+		deleted, err := step.Run()
+		job.Progress(step.Name, deleted, err)
+	}
+}
+
+// startUserCascade 后台删除用户及其录像、主机配置、审批、登录日志等数据
+func startUserCascade(user model.SshUser, operator uint) *Job {
+	steps := model.UserCascadeSteps(user)
+	return startJob("user_cascade_delete", fmt.Sprintf("user:%d:%s", user.ID, user.Name), operator, len(steps)+1, func(job *Job) {
+		var record model.SessionRecord
+		list, err := record.FindByUid(user.ID)
+		job.Progress("find_session_record", 0, err)
+		if err != nil {
+			// 录像文件未删除时保留用户,避免录像成为无主数据
+			return
+		}
+		removeRecords(job, list)
+		runCascadeSteps(job, steps)
+	})
+}
+
+// startConfCascade 后台删除多个主机配置及其录像、审批、凭据借用记录
+func startConfCascade(list []model.SshConf, operator uint) *Job {
+	target := "conn_conf"
+	for i, conf := range list {
+		if i < 10 {
+			target += fmt.Sprintf(":%d", conf.ID)
+		}
+	}
+	return startJob("conf_cascade_delete", target, operator, 0, func(job *Job) {
+		for _, conf := range list {
+			steps := model.ConfCascadeSteps(conf)
+			job.AddTotal(len(steps) + 1)
+			var record model.SessionRecord
+			records, err := record.FindByHost(conf.Uid, conf.Address, conf.Port, conf.User)
+			job.Progress("find_session_record", 0, err)
+			if err != nil {
+				continue
+			}
+			removeRecords(job, records)
+			runCascadeSteps(job, steps)
+		}
+	})
+}
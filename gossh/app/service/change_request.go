@@ -0,0 +1,364 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"sort"
+	"strconv"
+)
+
+// changeApplier 需要同行评审的资源,负责读取当前数据和执行变更
+type changeApplier struct {
+	load  func(id uint) (any, error)
+	apply func(action string, id uint, payload []byte) error
+}
+
+var changeAppliers = map[string]changeApplier{
+	"policy_conf": {
+		load: func(id uint) (any, error) {
+			var conf model.PolicyConf
+			return conf.FindByID(id)
+		},
+		apply: func(action string, id uint, payload []byte) error {
+			var conf model.PolicyConf
+			if action == "delete" {
+				return conf.DeleteByID(id)
+			}
+			if err := json.Unmarshal(payload, &conf); err != nil {
+				return err
+			}
+			if action == "create" {
+				conf.ID = 0
+				return conf.Create(&conf)
+			}
+			return conf.UpdateById(id, &conf)
+		},
+	},
+	"policy_sftp": {
+		load: func(id uint) (any, error) {
+			var conf model.PolicyConf
+			data, err := conf.FindByID(id)
+			return policySftp{Id: data.ID, SftpPaths: data.SftpPaths, SftpReadOnly: data.SftpReadOnly}, err
+		},
+		apply: func(action string, id uint, payload []byte) error {
+			var param policySftp
+			if err := json.Unmarshal(payload, &param); err != nil {
+				return err
+			}
+			var conf model.PolicyConf
+			return conf.UpdateSftp(id, param.SftpPaths, param.SftpReadOnly)
+		},
+	},
+	"policy_target_tags": {
+		load: func(id uint) (any, error) {
+			var conf model.PolicyConf
+			data, err := conf.FindByID(id)
+			return policyTargetTags{Id: data.ID, TargetTags: data.TargetTags}, err
+		},
+		apply: func(action string, id uint, payload []byte) error {
+			var param policyTargetTags
+			if err := json.Unmarshal(payload, &param); err != nil {
+				return err
+			}
+			var conf model.PolicyConf
+			return conf.UpdateTargetTags(id, param.TargetTags)
+		},
+	},
+	"policy_prod": {
+		load: func(id uint) (any, error) {
+			var conf model.PolicyConf
+			data, err := conf.FindByID(id)
+			return policyProd{Id: data.ID, ProdTags: data.ProdTags, ProdBanner: data.ProdBanner, ProdConfirm: data.ProdConfirm}, err
+		},
+		apply: func(action string, id uint, payload []byte) error {
+			var param policyProd
+			if err := json.Unmarshal(payload, &param); err != nil {
+				return err
+			}
+			var conf model.PolicyConf
+			return conf.UpdateProd(id, param.ProdTags, param.ProdBanner, param.ProdConfirm)
+		},
+	},
+	"policy_algo": {
+		load: func(id uint) (any, error) {
+			var conf model.PolicyConf
+			data, err := conf.FindByID(id)
+			return policyAlgo{Id: data.ID, AlgoDeny: data.AlgoDeny}, err
+		},
+		apply: func(action string, id uint, payload []byte) error {
+			var param policyAlgo
+			if err := json.Unmarshal(payload, &param); err != nil {
+				return err
+			}
+			var conf model.PolicyConf
+			return conf.UpdateAlgoDeny(id, param.AlgoDeny)
+		},
+	},
+	"net_group": {
+		load: func(id uint) (any, error) {
+			var group model.NetGroup
+			return group.FindByID(id)
+		},
+		apply: func(action string, id uint, payload []byte) error {
+			var group model.NetGroup
+			if action == "delete" {
+				return group.DeleteByID(id)
+			}
+			if err := json.Unmarshal(payload, &group); err != nil {
+				return err
+			}
+			if action == "create" {
+				group.ID = 0
+				return group.Create(&group)
+			}
+			return group.UpdateById(id, &group)
+		},
+	},
+	"net_filter": {
+		load: func(id uint) (any, error) {
+			var filter model.NetFilter
+			return filter.FindByID(id)
+		},
+		apply: func(action string, id uint, payload []byte) error {
+			var filter model.NetFilter
+			if action == "delete" {
+				return filter.DeleteByID(id)
+			}
+			if err := json.Unmarshal(payload, &filter); err != nil {
+				return err
+			}
+			if action == "create" {
+				filter.ID = 0
+				return filter.Create(&filter)
+			}
+			return filter.UpdateById(id, &filter)
+		},
+	},
+}
+
+// peerReviewEnabled 租户是否开启了同行评审
+func peerReviewEnabled(tenantId uint) bool {
+	var policyConf model.PolicyConf
+	conf, err := policyConf.FindByTenant(tenantId)
+	return err == nil && conf.PeerReview == "Y"
+}
+
+// submitChange 开启同行评审时提交变更申请并返回响应,返回false时调用方直接执行变更
+func submitChange(c *gin.Context, resource, action string, targetId uint, payload any) bool {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return true
+	}
+	tenantId := tenantScope(c, u)
+	if !peerReviewEnabled(tenantId) {
+		return false
+	}
+	applier, ok := changeAppliers[resource]
+	if !ok {
+		return false
+	}
+
+	req := model.ChangeRequest{
+		Resource:    resource,
+		Action:      action,
+		TargetId:    targetId,
+		Status:      "pending",
+		RequesterId: u.ID,
+		Requester:   u.Name,
+		RequestId:   c.GetString("request_id"),
+		TenantId:    tenantId,
+	}
+	if action != "create" {
+		before, err := applier.load(targetId)
+		if err != nil {
+			c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+			return true
+		}
+		data, _ := json.Marshal(before)
+		req.Before = string(data)
+	}
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		req.Payload = string(data)
+	}
+	if err := req.Create(&req); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return true
+	}
+	slog.Info("change request submitted", "id", req.ID, "resource", resource, "action", action, "requester", u.Name)
+	c.JSON(200, gin.H{"code": 0, "msg": "变更已提交,等待其他管理员审核", "data": req, "pending": true})
+	return true
+}
+
+// changeDiff 对比变更前后的字段
+func changeDiff(req model.ChangeRequest) []map[string]any {
+	before := map[string]any{}
+	after := map[string]any{}
+	if req.Before != "" {
+		_ = json.Unmarshal([]byte(req.Before), &before)
+	}
+	if req.Payload != "" {
+		_ = json.Unmarshal([]byte(req.Payload), &after)
+	}
+
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	var fields []string
+	for k := range keys {
+		if k != "id" {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	var diff []map[string]any
+	for _, k := range fields {
+		b, a := before[k], after[k]
+		if req.Action == "delete" {
+			a = nil
+		}
+		if fmt.Sprint(b) == fmt.Sprint(a) {
+			continue
+		}
+		diff = append(diff, map[string]any{"field": k, "before": b, "after": a})
+	}
+	return diff
+}
+
+func ChangeRequestFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 3, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var req model.ChangeRequest
+	if status := c.Query("status"); status != "" {
+		q.Filters["status"] = status
+	}
+	data, total, err := req.FindPage(q, tenantScope(c, u))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func ChangeRequestFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 3, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var req model.ChangeRequest
+	data, err := req.FindByID(uint(id))
+	if err == nil && data.TenantId != tenantScope(c, u) {
+		err = errors.New("变更不存在")
+	}
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "diff": changeDiff(data)})
+}
+
+func ChangeRequestApprove(c *gin.Context) {
+	changeRequestReview(c, "approved")
+}
+
+func ChangeRequestReject(c *gin.Context) {
+	changeRequestReview(c, "rejected")
+}
+
+func changeRequestReview(c *gin.Context, status string) {
+	type Param struct {
+		ID      uint   `form:"id" binding:"required,gte=1" json:"id"`
+		Comment string `form:"comment" binding:"max=512" json:"comment"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+
+	var req model.ChangeRequest
+	data, err := req.FindByID(param.ID)
+	if err == nil && data.TenantId != tenantScope(c, u) {
+		err = errors.New("变更不存在")
+	}
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if data.RequesterId == u.ID {
+		c.JSON(200, gin.H{"code": 4, "msg": "不能审核自己提交的变更"})
+		return
+	}
+
+	rows, err := req.UpdateStatus(data.ID, &model.ChangeRequest{
+		Status:     status,
+		ReviewerId: u.ID,
+		Reviewer:   u.Name,
+		Comment:    param.Comment,
+	})
+	if err != nil {
+		c.JSON(200, gin.H{"code": 5, "msg": err.Error()})
+		return
+	}
+	if rows == 0 {
+		c.JSON(200, gin.H{"code": 6, "msg": "变更不存在或已处理"})
+		return
+	}
+
+	if status == "approved" {
+		err = applyChange(data)
+		if err != nil {
+			slog.Error("applyChange error:", "id", data.ID, "err_msg", err.Error())
+			_ = req.MarkFailed(data.ID, utils.TruncateString(err.Error(), 512))
+			c.JSON(200, gin.H{"code": 7, "msg": "执行变更错误:" + err.Error()})
+			return
+		}
+	}
+	slog.Info("change request reviewed", "id", data.ID, "status", status, "reviewer", u.Name)
+	data, _ = req.FindByID(data.ID)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+// applyChange 审核通过后执行变更
+func applyChange(req model.ChangeRequest) error {
+	applier, ok := changeAppliers[req.Resource]
+	if !ok {
+		return errors.New("不支持的资源类型:" + req.Resource)
+	}
+	return applier.apply(req.Action, req.TargetId, []byte(req.Payload))
+}
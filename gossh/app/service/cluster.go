@@ -0,0 +1,203 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// 主节点租约名称
+const leaderLease = "leader"
+
+// clusterLeader 当前节点是否持有主节点租约
+var clusterLeader atomic.Bool
+
+// localNodeId 当前节点标识,未配置时使用主机名和端口
+func localNodeId() string {
+	if id := config.DefaultConfig.Cluster.NodeId; id != "" {
+		return id
+	}
+	host, _ := os.Hostname()
+	return net.JoinHostPort(host, config.DefaultConfig.Port)
+}
+
+func leaseTtl() time.Duration {
+	if ttl := config.DefaultConfig.Cluster.LeaseTtl; ttl > 0 {
+		return ttl
+	}
+	return time.Second * 30
+}
+
+// isLeader 当前节点是否为主节点,只有主节点运行定时清理、探测、同步等后台任务
+func isLeader() bool {
+	return clusterLeader.Load()
+}
+
+// backgroundEnabled 系统已初始化并且当前节点为主节点
+func backgroundEnabled() bool {
+	return config.DefaultConfig.IsInit && isLeader()
+}
+
+// renewLeader 获取或续期主节点租约,数据库不可用时放弃主节点身份
+func renewLeader() {
+	var lease model.ClusterLease
+	ok, err := lease.Acquire(leaderLease, localNodeId(), leaseTtl())
+	if err != nil {
+		slog.Error("ClusterLease.Acquire error:", "err_msg", err.Error())
+	}
+	if clusterLeader.Swap(ok) != ok {
+		if ok {
+			slog.Warn("cluster leader elected", "node", localNodeId())
+		} else {
+			slog.Warn("cluster leader lost", "node", localNodeId())
+		}
+	}
+}
+
+// syncSessionRegistry 将本节点的在线会话同步到数据库并刷新心跳
+func syncSessionRegistry() {
+	var registry model.ClusterSession
+	nodeId := localNodeId()
+	list, err := registry.FindByNode(nodeId)
+	if err != nil {
+		slog.Error("ClusterSession.FindByNode error:", "err_msg", err.Error())
+		return
+	}
+	registered := make(map[string]bool, len(list))
+	for _, item := range list {
+		registered[item.SessionId] = true
+	}
+	now := model.DateTime(time.Now())
+	OnlineClients.Range(func(key, value any) bool {
+		conn, ok := value.(*SshConn)
+		if !ok || conn.SshConf == nil {
+			return true
+		}
+		if registered[conn.SessionId] {
+			delete(registered, conn.SessionId)
+			return true
+		}
+		err := registry.Create(&model.ClusterSession{
+			SessionId:   conn.SessionId,
+			NodeId:      nodeId,
+			Uid:         conn.Uid,
+			TenantId:    conn.tenantId,
+			SshUser:     conn.User,
+			Address:     conn.Address,
+			Port:        conn.Port,
+			ClientIp:    conn.ClientIP,
+			StartAt:     model.DateTime(conn.StartTime),
+			HeartbeatAt: now,
+		})
+		if err != nil {
+			slog.Error("ClusterSession.Create error:", "err_msg", err.Error())
+		}
+		return true
+	})
+	// 已经结束的会话
+	var closed []string
+	for sessionId := range registered {
+		closed = append(closed, sessionId)
+	}
+	if err := registry.DeleteBySessionIds(closed); err != nil {
+		slog.Error("ClusterSession.DeleteBySessionIds error:", "err_msg", err.Error())
+	}
+	if err := registry.Touch(nodeId); err != nil {
+		slog.Error("ClusterSession.Touch error:", "err_msg", err.Error())
+	}
+}
+
+// cleanOrphanSessions 主节点结束失效节点上遗留的会话录像并删除登记
+func cleanOrphanSessions(list []model.ClusterSession) {
+	if len(list) == 0 {
+		return
+	}
+	var sessionRecord model.SessionRecord
+	var registry model.ClusterSession
+	var ids []string
+	for _, item := range list {
+		ids = append(ids, item.SessionId)
+		slog.Warn("clean orphan session", "sid", item.SessionId, "node", item.NodeId, "uid", item.Uid, "host", item.Address)
+		if err := sessionRecord.FinishBySessionIds([]string{item.SessionId}, time.Time(item.HeartbeatAt)); err != nil {
+			slog.Error("SessionRecord.FinishBySessionIds error:", "err_msg", err.Error())
+		}
+	}
+	if err := registry.DeleteBySessionIds(ids); err != nil {
+		slog.Error("ClusterSession.DeleteBySessionIds error:", "err_msg", err.Error())
+	}
+}
+
+func clusterLoop() {
+	restarted := true
+	for {
+		if config.DefaultConfig.IsInit {
+			var registry model.ClusterSession
+			// 节点重启前的会话已经随进程结束
+			if restarted {
+				list, err := registry.FindByNode(localNodeId())
+				if err == nil {
+					cleanOrphanSessions(list)
+					restarted = false
+				}
+			}
+			renewLeader()
+			syncSessionRegistry()
+			if isLeader() {
+				list, err := registry.FindStale(time.Now().Add(-leaseTtl()))
+				if err != nil {
+					slog.Error("ClusterSession.FindStale error:", "err_msg", err.Error())
+				}
+				cleanOrphanSessions(list)
+			}
+		}
+		time.Sleep(leaseTtl() / 3)
+	}
+}
+
+// ReleaseLeader 进程退出前释放主节点租约,备用节点可以立即接管
+func ReleaseLeader() {
+	if !config.DefaultConfig.IsInit || !clusterLeader.Swap(false) {
+		return
+	}
+	var lease model.ClusterLease
+	if err := lease.Release(leaderLease, localNodeId()); err != nil {
+		slog.Error("ClusterLease.Release error:", "err_msg", err.Error())
+	}
+}
+
+// ClusterStatus GET 集群状态,包括主节点和各节点登记的在线会话
+func ClusterStatus(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var lease model.ClusterLease
+	leader, err := lease.FindByName(leaderLease)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	var registry model.ClusterSession
+	sessions, err := registry.FindAll()
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"node_id":   localNodeId(),
+		"is_leader": isLeader(),
+		"leader":    leader,
+		"sessions":  sessions,
+	}})
+}
+
+func init() {
+	go clusterLoop()
+}
@@ -9,7 +9,7 @@ import (
 func CmdNoteCreate(c *gin.Context) {
 	var cmd model.CmdNote
 	if err := c.ShouldBind(&cmd); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
 	cmd.Uid = c.GetUint("uid")
@@ -33,34 +33,37 @@ func CmdNoteFindByID(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "vars": snippetPromptVars(data.CmdData)})
 }
 
 func CmdNoteFindAll(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	q, err := parsePageQuery(c)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if folder, ok := c.GetQuery("folder"); ok {
+		q.Filters["folder"] = folder
+	}
+
+	var cmd model.CmdNote
+	data, total, err := cmd.FindPage(q, c.GetUint("uid"))
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-
-	var cmd model.CmdNote
-	data, err := cmd.FindAll(offset, limit, c.GetUint("uid"))
+	folders, err := cmd.FindFolders(c.GetUint("uid"))
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total, "folders": folders})
 }
 
 func CmdNoteUpdateById(c *gin.Context) {
 	var cmd model.CmdNote
 	if err := c.ShouldBind(&cmd); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
 	err := cmd.UpdateById(cmd.ID, c.GetUint("uid"), &cmd)
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 批量执行的最大主机数量和并发数
+const (
+	snippetRunMaxHosts    = 200
+	snippetRunConcurrency = 10
+)
+
+// 命令模板变量,例如 {{host}}
+var snippetVarRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// snippetBuiltinVars 由目标主机提供的变量
+func snippetBuiltinVars(conn *SshConn) map[string]string {
+	return map[string]string{
+		"host":  conn.Address,
+		"port":  strconv.Itoa(int(conn.Port)),
+		"user":  conn.User,
+		"name":  conn.Name,
+		"group": conn.GroupName,
+	}
+}
+
+// snippetPromptVars 需要在执行时输入的变量,不包含内置变量
+func snippetPromptVars(tpl string) []string {
+	builtin := snippetBuiltinVars(&SshConn{SshConf: &model.SshConf{}})
+	seen := map[string]bool{}
+	vars := []string{}
+	for _, m := range snippetVarRegexp.FindAllStringSubmatch(tpl, -1) {
+		name := m[1]
+		if _, ok := builtin[name]; ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		vars = append(vars, name)
+	}
+	return vars
+}
+
+// renderSnippet 替换模板变量,变量值原样替换,不做 shell 转义
+func renderSnippet(tpl string, conn *SshConn, vars map[string]string) (string, error) {
+	builtin := snippetBuiltinVars(conn)
+	var missing []string
+	out := snippetVarRegexp.ReplaceAllStringFunc(tpl, func(s string) string {
+		name := snippetVarRegexp.FindStringSubmatch(s)[1]
+		if value, ok := builtin[name]; ok {
+			return value
+		}
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return s
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing variables: %s", strings.Join(missing, ","))
+	}
+	return out, nil
+}
+
+// SnippetRunResult 单台主机的执行结果
+type SnippetRunResult struct {
+	SessionId string `json:"session_id"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	Code      int    `json:"code"`
+	Msg       string `json:"msg"`
+	Cmd       string `json:"cmd"`
+	Data      string `json:"data"`
+}
+
+// CmdNoteRun POST 在选中的在线会话上批量执行命令
+func CmdNoteRun(c *gin.Context) {
+	type Param struct {
+		Id         uint              `form:"id" binding:"required" json:"id"`
+		SessionIds []string          `form:"session_ids" binding:"required,min=1" json:"session_ids"`
+		Vars       map[string]string `form:"vars" json:"vars"`
+		Timeout    uint              `form:"timeout" binding:"lte=86400" json:"timeout"`
+		Confirm    []string          `form:"confirm" json:"confirm"` // 已确认的生产环境主机地址
+	}
+	var param Param
+	if err := c.ShouldBindJSON(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if len(param.SessionIds) > snippetRunMaxHosts {
+		c.JSON(200, gin.H{"code": 1, "msg": fmt.Sprintf("最多选择 %d 台主机", snippetRunMaxHosts)})
+		return
+	}
+	uid := c.GetUint("uid")
+	var cmdNote model.CmdNote
+	note, err := cmdNote.FindByID(param.Id, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if vars := snippetPromptVars(note.CmdData); len(vars) > 0 {
+		var missing []string
+		for _, name := range vars {
+			if _, ok := param.Vars[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			c.JSON(200, gin.H{"code": 3, "msg": "missing variables: " + strings.Join(missing, ","), "vars": missing})
+			return
+		}
+	}
+
+	results := make([]SnippetRunResult, len(param.SessionIds))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, snippetRunConcurrency)
+	for i, sessionId := range param.SessionIds {
+		wg.Add(1)
+		go func(i int, sessionId string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runSnippet(note, sessionId, uid, c.RemoteIP(), param.Vars, param.Timeout, param.Confirm)
+		}(i, sessionId)
+	}
+	wg.Wait()
+	slog.Info("snippet run", "uid", uid, "note_id", note.ID, "hosts", len(param.SessionIds))
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": results})
+}
+
+// runSnippet 在单个会话上执行命令,返回码和 ExecCommand 保持一致
+func runSnippet(note model.CmdNote, sessionId string, uid uint, clientIp string, vars map[string]string, timeout uint, confirm []string) SnippetRunResult {
+	result := SnippetRunResult{SessionId: sessionId}
+	cli, ok := OnlineClients.Load(sessionId)
+	if !ok || cli == nil {
+		result.Code, result.Msg = 3, "session not exists"
+		return result
+	}
+	conn, ok := cli.(*SshConn)
+	if !ok || conn == nil || conn.SshConf == nil || conn.sshClient == nil {
+		result.Code, result.Msg = 4, "conn not exists"
+		return result
+	}
+	result.Name, result.Address = conn.Name, conn.Address
+	if err := checkSessionOwner(conn, uid, clientIp); err != nil {
+		result.Code, result.Msg = 4, err.Error()
+		return result
+	}
+	if err := checkProdAccess(conn, prodConfirmed(conn, confirm)); err != nil {
+		result.Code, result.Msg = 8, err.Error()
+		return result
+	}
+	cmd, err := renderSnippet(note.CmdData, conn, vars)
+	if err != nil {
+		result.Code, result.Msg = 2, err.Error()
+		return result
+	}
+	result.Cmd = cmd
+
+	out, err := execOnConn(conn, cmd, timeout)
+	result.Data = out
+	var timeoutErr *ExecTimeoutError
+	switch {
+	case errors.Is(err, errExecSession):
+		result.Code, result.Msg = 5, "create session error"
+	case errors.As(err, &timeoutErr):
+		result.Code, result.Msg = 7, "exec cmd timeout"
+	case err != nil:
+		result.Code, result.Msg = 6, "exec cmd error"
+	default:
+		result.Msg = "ok"
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"gossh/app/model"
+	"gossh/crypto/ssh"
+	"gossh/gin"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 采集主机信息的命令,只读且不依赖特权
+const factsCmd = "uname -s; uname -n; uname -r; uname -m; echo '---'; cat /etc/os-release 2>/dev/null; echo '---'; uptime"
+
+// 采集主机信息超时时间
+const factsTimeout = 15 * time.Second
+
+// HostFacts 主机基本信息
+type HostFacts struct {
+	System   string `json:"system"`
+	Hostname string `json:"hostname"`
+	Kernel   string `json:"kernel"`
+	Arch     string `json:"arch"`
+	Os       string `json:"os"`
+	Uptime   string `json:"uptime"`
+}
+
+// parseFacts 解析 factsCmd 的输出
+func parseFacts(out string) HostFacts {
+	var facts HostFacts
+	parts := strings.SplitN(out, "---\n", 3)
+	uname := strings.Split(strings.TrimSpace(parts[0]), "\n")
+	fields := []*string{&facts.System, &facts.Hostname, &facts.Kernel, &facts.Arch}
+	for i := range fields {
+		if i < len(uname) {
+			*fields[i] = strings.TrimSpace(uname[i])
+		}
+	}
+	if len(parts) > 1 {
+		release := map[string]string{}
+		scanner := bufio.NewScanner(strings.NewReader(parts[1]))
+		for scanner.Scan() {
+			k, v, ok := strings.Cut(scanner.Text(), "=")
+			if ok {
+				release[k] = strings.Trim(v, `"'`)
+			}
+		}
+		facts.Os = release["PRETTY_NAME"]
+		if facts.Os == "" {
+			facts.Os = strings.TrimSpace(release["NAME"] + " " + release["VERSION"])
+		}
+	}
+	if len(parts) > 2 {
+		facts.Uptime = strings.TrimSpace(parts[2])
+	}
+	return facts
+}
+
+// collectFacts 在已建立的连接上采集主机信息并缓存到配置中
+func collectFacts(client *ssh.Client, confId uint) (HostFacts, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return HostFacts{}, err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+
+	timer := time.AfterFunc(factsTimeout, func() {
+		_ = session.Close()
+	})
+	defer timer.Stop()
+
+	out, err := session.Output(factsCmd)
+	if err != nil && len(out) == 0 {
+		return HostFacts{}, err
+	}
+	facts := parseFacts(string(out))
+	data, _ := json.Marshal(facts)
+	var sshConf model.SshConf
+	if err := sshConf.UpdateFacts(confId, string(data)); err != nil {
+		return facts, err
+	}
+	return facts, nil
+}
+
+// ConfFacts GET 查询主机信息,没有缓存或 refresh=Y 时重新采集
+func ConfFacts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var sshConf model.SshConf
+	conf, err := sshConf.FindByID(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+
+	if conf.Facts != "" && c.Query("refresh") != "Y" {
+		var facts HostFacts
+		_ = json.Unmarshal([]byte(conf.Facts), &facts)
+		c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": facts, "facts_at": conf.FactsAt})
+		return
+	}
+
+	client, err := dialSshConf(&conf, factsTimeout)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	facts, err := collectFacts(client, conf.ID)
+	if err != nil {
+		slog.Error("collectFacts error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": facts, "facts_at": time.Now().Format(model.TimeFormat)})
+}
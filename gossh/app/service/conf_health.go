@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 健康检查命令执行超时时间
+	healthCmdTimeout = 30 * time.Second
+	// 同时执行健康检查的主机数量
+	healthConcurrency = 8
+	// 保存的命令输出最大长度
+	healthOutputMax = 4096
+	// 可达性探测超时时间
+	probeTimeout = 5 * time.Second
+)
+
+// runHealthCmd 登录主机执行健康检查命令,命令退出码为0表示健康
+func runHealthCmd(conf *model.SshConf) (string, string) {
+	client, err := dialSshConf(conf, healthCmdTimeout)
+	if err != nil {
+		return "fail", err.Error()
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "fail", err.Error()
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+
+	// 命令超时后关闭连接,结束阻塞的命令
+	timer := time.AfterFunc(healthCmdTimeout, func() {
+		_ = client.Close()
+	})
+	defer timer.Stop()
+
+	out, err := session.CombinedOutput(conf.HealthCmd)
+	output := utils.TruncateString(string(out), healthOutputMax)
+	if err != nil {
+		if output == "" {
+			output = err.Error()
+		}
+		return "fail", output
+	}
+	return "ok", output
+}
+
+// healthAlert 健康状态变化时发出告警
+func healthAlert(conf *model.SshConf, status, output string) {
+	if status == "fail" {
+		slog.Warn("host health check failed", "id", conf.ID, "name", conf.Name, "address", conf.Address, "output", output)
+	} else {
+		slog.Info("host health check recovered", "id", conf.ID, "name", conf.Name, "address", conf.Address)
+	}
+
+	alertUrl := config.DefaultConfig.HealthAlertUrl
+	if alertUrl == "" {
+		return
+	}
+	data, _ := json.Marshal(map[string]any{
+		"id":      conf.ID,
+		"uid":     conf.Uid,
+		"name":    conf.Name,
+		"address": conf.Address,
+		"cmd":     conf.HealthCmd,
+		"status":  status,
+		"output":  output,
+		"time":    time.Now().Format(time.DateTime),
+	})
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(alertUrl, "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Error("healthAlert error:", "err_msg", err.Error())
+		return
+	}
+	_ = resp.Body.Close()
+}
+
+// checkHostHealth 执行所有主机的健康检查命令并记录结果
+func checkHostHealth() {
+	defer utils.RecoverPanic("checkHostHealth")
+	var sshConf model.SshConf
+	list, err := sshConf.FindAllHealthCmd()
+	if err != nil {
+		slog.Error("FindAllHealthCmd error:", "err_msg", err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, healthConcurrency)
+	for i := range list {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(conf *model.SshConf) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			status, output := runHealthCmd(conf)
+			if err := sshConf.UpdateHealth(conf.ID, status, output); err != nil {
+				slog.Error("UpdateHealth error:", "err_msg", err.Error())
+			}
+			// 首次失败或状态变化时告警
+			if status != conf.HealthStatus && (status == "fail" || conf.HealthStatus != "") {
+				healthAlert(conf, status, output)
+			}
+		}(&list[i])
+	}
+	wg.Wait()
+}
+
+// probeSsh 建立TCP连接并读取ssh服务版本标识,返回耗时
+func probeSsh(network, addr string) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout(network, addr, probeTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(probeTimeout))
+	// 服务端可能在版本标识前发送其他行,只检查前几行
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 5; i++ {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "SSH-") {
+			return time.Since(start), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, errors.New("not ssh server")
+}
+
+// probeAllHost 探测所有主机的可达性,相同地址只探测一次
+func probeAllHost() {
+	defer utils.RecoverPanic("probeAllHost")
+	var sshConf model.SshConf
+	list, err := sshConf.FindAllProbe()
+	if err != nil {
+		slog.Error("FindAllProbe error:", "err_msg", err.Error())
+		return
+	}
+
+	targets := map[[2]string][]uint{}
+	for _, conf := range list {
+		key := [2]string{conf.NetType, net.JoinHostPort(conf.Address, strconv.Itoa(int(conf.Port)))}
+		targets[key] = append(targets[key], conf.ID)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, healthConcurrency*4)
+	for key, ids := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(network, addr string, ids []uint) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			status := "up"
+			latency, err := probeSsh(network, addr)
+			if err != nil {
+				status = "down"
+			}
+			if err := sshConf.UpdateProbe(ids, status, latency.Milliseconds()); err != nil {
+				slog.Error("UpdateProbe error:", "err_msg", err.Error())
+			}
+		}(key[0], key[1], ids)
+	}
+	wg.Wait()
+}
+
+// probeLoop 定时探测主机可达性,probe_check 为0时不探测
+func probeLoop() {
+	for {
+		interval := config.DefaultConfig.ProbeCheck
+		if interval <= 0 {
+			time.Sleep(time.Minute)
+			continue
+		}
+		if backgroundEnabled() {
+			probeAllHost()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// ConfHealth GET 当前用户主机的可达性和健康检查状态
+func ConfHealth(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var sshConf model.SshConf
+	list, err := sshConf.FindAll(offset, limit, c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	data := make([]gin.H, 0, len(list))
+	for _, conf := range list {
+		data = append(data, gin.H{
+			"id":              conf.ID,
+			"name":            conf.Name,
+			"address":         conf.Address,
+			"port":            conf.Port,
+			"group_name":      conf.GroupName,
+			"probe_status":    conf.ProbeStatus,
+			"probe_latency":   conf.ProbeLatency,
+			"probe_at":        conf.ProbeAt,
+			"health_status":   conf.HealthStatus,
+			"health_check_at": conf.HealthCheckAt,
+		})
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+// healthCheckLoop 定时执行健康检查,health_check 为0时不检查
+func healthCheckLoop() {
+	for {
+		interval := config.DefaultConfig.HealthCheck
+		if interval <= 0 {
+			time.Sleep(time.Minute)
+			continue
+		}
+		time.Sleep(interval)
+		if backgroundEnabled() {
+			checkHostHealth()
+		}
+	}
+}
+
+func init() {
+	go healthCheckLoop()
+	go probeLoop()
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 单次导入的最大主机数量
+const importMaxCount = 1000
+
+// newImportConf 使用默认终端样式创建主机配置
+func newImportConf(name, address, user string, port uint16, group string) model.SshConf {
+	if port == 0 {
+		port = 22
+	}
+	netType := "tcp4"
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		netType = "tcp6"
+	}
+	if name == "" {
+		name = address
+	}
+	return model.SshConf{
+		Name:         utils.TruncateString(name, 63),
+		Address:      address,
+		User:         user,
+		AuthType:     "pwd",
+		NetType:      netType,
+		Port:         port,
+		FontSize:     14,
+		Background:   "#000000",
+		Foreground:   "#FFFFFF",
+		CursorColor:  "#FFFFFF",
+		FontFamily:   "Courier",
+		CursorStyle:  "block",
+		Shell:        "bash",
+		PtyType:      "xterm-256color",
+		Charset:      "utf-8",
+		TrueColor:    "Y",
+		AllowOsc52:   "Y",
+		NeedApproval: "N",
+		GroupName:    utils.TruncateString(group, 64),
+	}
+}
+
+// decodeText 处理 UTF-16(Xshell 默认编码) 和 UTF-8 BOM
+func decodeText(data []byte) string {
+	if len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe {
+		u16 := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			u16 = append(u16, uint16(data[i])|uint16(data[i+1])<<8)
+		}
+		return string(utf16.Decode(u16))
+	}
+	return string(bytes.TrimPrefix(data, []byte{0xef, 0xbb, 0xbf}))
+}
+
+// parseXshell 解析 Xshell .xsh 会话文件(INI格式)
+func parseXshell(fileName string, data []byte) ([]model.SshConf, error) {
+	section := ""
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(decodeText(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToUpper(line[1 : len(line)-1])
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[section+"."+strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	host := values["CONNECTION.Host"]
+	if host == "" {
+		return nil, errors.New("Xshell 会话文件缺少 Host")
+	}
+	if protocol := values["CONNECTION.Protocol"]; protocol != "" && !strings.EqualFold(protocol, "SSH") {
+		return nil, errors.New("仅支持 SSH 协议的 Xshell 会话")
+	}
+	port, _ := strconv.Atoi(values["CONNECTION.Port"])
+
+	// Xshell 以目录作为分组,上传时文件名中可能包含目录
+	name := strings.TrimSuffix(path.Base(fileName), path.Ext(fileName))
+	group := path.Dir(strings.ReplaceAll(fileName, `\`, "/"))
+	if group == "." {
+		group = ""
+	}
+	conf := newImportConf(name, host, values["CONNECTION:AUTHENTICATION.UserName"], uint16(port), group)
+	return []model.SshConf{conf}, nil
+}
+
+// secureCrtKey SecureCRT XML 导出文件节点
+type secureCrtKey struct {
+	Name    string         `xml:"name,attr"`
+	Keys    []secureCrtKey `xml:"key"`
+	Strings []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"string"`
+	Dwords []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"dword"`
+}
+
+// parseSecureCrt 解析 SecureCRT 导出的 XML 文件,文件夹映射为分组
+func parseSecureCrt(data []byte) ([]model.SshConf, error) {
+	var root struct {
+		Keys []secureCrtKey `xml:"key"`
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var list []model.SshConf
+	var walk func(key secureCrtKey, group string)
+	walk = func(key secureCrtKey, group string) {
+		values := map[string]string{}
+		for _, s := range key.Strings {
+			values[s.Name] = strings.TrimSpace(s.Value)
+		}
+		for _, d := range key.Dwords {
+			values[d.Name] = strings.TrimSpace(d.Value)
+		}
+		if host := values["Hostname"]; host != "" {
+			protocol := values["Protocol Name"]
+			if protocol == "" || strings.HasPrefix(strings.ToUpper(protocol), "SSH") {
+				port, _ := strconv.Atoi(values["[SSH2] Port"])
+				list = append(list, newImportConf(key.Name, host, values["Username"], uint16(port), group))
+			}
+			return
+		}
+		child := path.Join(group, key.Name)
+		for _, k := range key.Keys {
+			walk(k, child)
+		}
+	}
+
+	for _, key := range root.Keys {
+		if key.Name == "Sessions" {
+			for _, k := range key.Keys {
+				walk(k, "")
+			}
+		}
+	}
+	return list, nil
+}
+
+// parseSshConfig 解析 OpenSSH ~/.ssh/config,跳过通配符主机
+func parseSshConfig(data []byte, group string) ([]model.SshConf, error) {
+	type block struct {
+		names  []string
+		values map[string]string
+	}
+	var blocks []*block
+	var current *block
+	scanner := bufio.NewScanner(strings.NewReader(decodeText(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.Replace(line, "=", " ", 1))
+		if len(fields) < 2 {
+			continue
+		}
+		key := strings.ToLower(fields[0])
+		switch key {
+		case "host":
+			current = &block{names: fields[1:], values: map[string]string{}}
+			blocks = append(blocks, current)
+		case "match":
+			current = nil
+		default:
+			if current != nil {
+				if _, ok := current.values[key]; !ok {
+					current.values[key] = fields[1]
+				}
+			}
+		}
+	}
+
+	var list []model.SshConf
+	for _, b := range blocks {
+		for _, name := range b.names {
+			if strings.ContainsAny(name, "*?!") {
+				continue
+			}
+			address := b.values["hostname"]
+			if address == "" {
+				address = name
+			}
+			port, _ := strconv.Atoi(b.values["port"])
+			list = append(list, newImportConf(name, address, b.values["user"], uint16(port), group))
+		}
+	}
+	return list, nil
+}
+
+// parseImportFile 根据格式或文件名解析上传的文件
+func parseImportFile(format string, file *multipart.FileHeader, defaultGroup string) ([]model.SshConf, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(src, 8*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" || format == "auto" {
+		switch {
+		case strings.HasSuffix(strings.ToLower(file.Filename), ".xsh"):
+			format = "xshell"
+		case strings.HasSuffix(strings.ToLower(file.Filename), ".xml"):
+			format = "securecrt"
+		default:
+			format = "ssh_config"
+		}
+	}
+
+	var list []model.SshConf
+	switch format {
+	case "xshell":
+		list, err = parseXshell(file.Filename, data)
+	case "securecrt":
+		list, err = parseSecureCrt(data)
+	case "ssh_config":
+		list, err = parseSshConfig(data, defaultGroup)
+	default:
+		return nil, errors.New("不支持的导入格式:" + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].GroupName == "" {
+			list[i].GroupName = defaultGroup
+		}
+	}
+	return list, nil
+}
+
+// ConfImportPreview POST 解析上传的会话文件,返回预览结果,不保存
+func ConfImportPreview(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		slog.Error("获取form数据错误", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 1, "msg": "获取form数据错误"})
+		return
+	}
+
+	// 分组映射,源分组名 => 目标分组名
+	groupMap := map[string]string{}
+	if raw := c.PostForm("group_map"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &groupMap); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": "group_map 格式错误"})
+			return
+		}
+	}
+	format := c.PostForm("format")
+	defaultGroup := c.PostForm("default_group")
+
+	var list []model.SshConf
+	var errs []string
+	for _, file := range form.File["files"] {
+		items, err := parseImportFile(format, file, defaultGroup)
+		if err != nil {
+			errs = append(errs, file.Filename+":"+err.Error())
+			continue
+		}
+		list = append(list, items...)
+	}
+	for i := range list {
+		if group, ok := groupMap[list[i].GroupName]; ok {
+			list[i].GroupName = group
+		}
+	}
+	if len(list) > importMaxCount {
+		c.JSON(200, gin.H{"code": 2, "msg": "单次导入主机数量超过限制"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list, "errors": errs})
+}
+
+// ConfImport POST 保存预览确认后的主机配置
+func ConfImport(c *gin.Context) {
+	type Param struct {
+		List []model.SshConf `json:"list" binding:"required,min=1,max=1000,dive"`
+	}
+	var param Param
+	if err := c.ShouldBindJSON(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	uid := c.GetUint("uid")
+	for i, item := range param.List {
+		if err := checkSecretRefs(uid, item.Pwd, item.CertData, item.CertPwd); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": item.Name + ":" + err.Error()})
+			return
+		}
+		param.List[i].ID = 0
+		param.List[i].Uid = uid
+	}
+	if err := checkHostQuota(uid, len(param.List)); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var config model.SshConf
+	if err := config.CreateBatch(param.List); err != nil {
+		slog.Error("导入主机配置错误", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	ConfFindAll(c)
+}
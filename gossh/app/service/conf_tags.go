@@ -0,0 +1,314 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// 批量执行最多匹配的主机数量
+	batchExecMaxHosts = 200
+	// 批量执行同时连接的主机数量
+	batchExecWorkers = 10
+	// 批量执行连接单台主机的超时时间
+	batchExecDialTimeout = 10 * time.Second
+)
+
+// normalizeTags 校验标签并按标签名排序
+func normalizeTags(tags string) (string, error) {
+	parsed, err := utils.ParseTags(tags)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// confTags 解析配置的标签,已保存的标签都经过校验,解析失败时按无标签处理
+func confTags(conf model.SshConf) utils.Tags {
+	tags, err := utils.ParseTags(conf.Tags)
+	if err != nil {
+		return utils.Tags{}
+	}
+	return tags
+}
+
+// matchConfs 查询用户的配置中匹配标签表达式的主机
+func matchConfs(uid uint, expr string) ([]model.SshConf, error) {
+	tagExpr, err := utils.ParseTagExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	var sshConf model.SshConf
+	list, err := sshConf.FindByUid(uid)
+	if err != nil {
+		return nil, err
+	}
+	var matched []model.SshConf
+	for _, conf := range list {
+		if tagExpr.Match(confTags(conf)) {
+			matched = append(matched, conf)
+		}
+	}
+	return matched, nil
+}
+
+// policyTargets 主机策略是否对连接的主机生效
+// 标签表达式为空时对所有主机生效,未保存的临时连接无法确定标签,按生效处理
+func policyTargets(policy model.PolicyConf, conn *SshConn) bool {
+	if policy.TargetTags == "" || conn.ID == 0 {
+		return true
+	}
+	tagExpr, err := utils.ParseTagExpr(policy.TargetTags)
+	if err != nil {
+		slog.Error("policy target_tags error:", "err_msg", err.Error())
+		return true
+	}
+	var sshConf model.SshConf
+	conf, err := sshConf.FindByID(conn.ID, conn.Uid)
+	if err != nil {
+		return true
+	}
+	return tagExpr.Match(confTags(conf))
+}
+
+// ConfTagsSet PUT 设置主机标签,标签为空时清空
+func ConfTagsSet(c *gin.Context) {
+	type Param struct {
+		Id   uint   `form:"id" binding:"required" json:"id"`
+		Tags string `form:"tags" binding:"max=4096" json:"tags"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	tags, err := normalizeTags(param.Tags)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var sshConf model.SshConf
+	n, err := sshConf.UpdateTags(param.Id, c.GetUint("uid"), tags)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if n == 0 {
+		c.JSON(200, gin.H{"code": 3, "msg": "配置不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": tags})
+}
+
+// ConfTagList GET 用户使用过的标签及主机数量
+func ConfTagList(c *gin.Context) {
+	var sshConf model.SshConf
+	list, err := sshConf.FindByUid(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	counts := map[string]int{}
+	for _, conf := range list {
+		for key, values := range confTags(conf) {
+			for _, value := range values {
+				tag := key
+				if value != "" {
+					tag = key + "=" + value
+				}
+				counts[tag]++
+			}
+		}
+	}
+	data := make([]gin.H, 0, len(counts))
+	for tag, count := range counts {
+		data = append(data, gin.H{"tag": tag, "count": count})
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i]["tag"].(string) < data[j]["tag"].(string)
+	})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+// ConfTagMatch GET 按标签表达式查询主机,例如 env=prod AND role=db
+func ConfTagMatch(c *gin.Context) {
+	list, err := matchConfs(c.GetUint("uid"), c.Query("expr"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	data := make([]gin.H, 0, len(list))
+	for _, conf := range list {
+		data = append(data, gin.H{
+			"id":          conf.ID,
+			"name":        conf.Name,
+			"address":     conf.Address,
+			"port":        conf.Port,
+			"user":        conf.User,
+			"group_name":  conf.GroupName,
+			"environment": conf.Environment,
+			"tags":        conf.Tags,
+		})
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": len(data)})
+}
+
+// policyTargetTags 主机策略生效范围设置
+type policyTargetTags struct {
+	Id         uint   `form:"id" binding:"required" json:"id"`
+	TargetTags string `form:"target_tags" binding:"max=4096" json:"target_tags"`
+}
+
+// PolicyTargetTagsSet PUT 设置审批、录像和 SFTP 限制生效的主机标签表达式,为空时对所有主机生效
+func PolicyTargetTagsSet(c *gin.Context) {
+	var param policyTargetTags
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if param.TargetTags != "" {
+		if _, err := utils.ParseTagExpr(param.TargetTags); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+			return
+		}
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if _, ok := findTenantPolicy(c, param.Id); !ok {
+		return
+	}
+	if submitChange(c, "policy_target_tags", "update", param.Id, param) {
+		return
+	}
+	var conf model.PolicyConf
+	if err := conf.UpdateTargetTags(param.Id, param.TargetTags); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	data, err := conf.FindByID(param.Id)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+// BatchExecResult 批量执行单台主机的结果
+type BatchExecResult struct {
+	Id      uint   `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Output  string `json:"output"`
+}
+
+// checkBatchExec 批量执行前按终端连接的规则检查主机,需要审批或人工认证的主机不支持批量执行
+func checkBatchExec(conn *SshConn, confirm string) error {
+	if conn.AuthType == "interactive" {
+		return errors.New("keyboard-interactive 认证的主机不支持批量执行")
+	}
+	if err := checkProdAccess(conn, confirm); err != nil {
+		return err
+	}
+	if needApproval(conn) {
+		return errors.New("需要审批的主机不支持批量执行")
+	}
+	return checkCredCheckout(conn)
+}
+
+// batchExecOne 连接一台主机执行命令
+func batchExecOne(conf model.SshConf, uid uint, cmd, confirm string, timeout uint) BatchExecResult {
+	result := BatchExecResult{Id: conf.ID, Name: conf.Name, Address: conf.Address}
+	conn := &SshConn{SshConf: &conf, SessionId: "batch_exec"}
+	conn.Uid = uid
+	if err := checkBatchExec(conn, confirm); err != nil {
+		result.Code, result.Msg = 1, err.Error()
+		return result
+	}
+	client, err := dialSshConf(&conf, batchExecDialTimeout)
+	if err != nil {
+		result.Code, result.Msg = 2, err.Error()
+		return result
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	session, err := client.NewSession()
+	if err != nil {
+		result.Code, result.Msg = 2, err.Error()
+		return result
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	out, err := runExec(client, session, cmd, execTimeout(&conf, timeout))
+	result.Output = out
+	if err != nil {
+		result.Code, result.Msg = 3, err.Error()
+		return result
+	}
+	result.Msg = "ok"
+	return result
+}
+
+// BatchExec POST 在匹配标签表达式的主机上执行命令
+func BatchExec(c *gin.Context) {
+	type Param struct {
+		Tags    string `form:"tags" binding:"required,max=4096" json:"tags"`
+		Cmd     string `form:"cmd" binding:"required,min=1" json:"cmd"`
+		Timeout uint   `form:"timeout" binding:"lte=86400" json:"timeout"`
+		Confirm string `form:"confirm" json:"confirm"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	uid := c.GetUint("uid")
+	if !isAccessAllowed(uid) {
+		c.JSON(200, gin.H{"code": 1, "msg": "当前时间不在允许访问的时间段内"})
+		return
+	}
+	list, err := matchConfs(uid, param.Tags)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if len(list) == 0 {
+		c.JSON(200, gin.H{"code": 2, "msg": "没有匹配的主机"})
+		return
+	}
+	if len(list) > batchExecMaxHosts {
+		c.JSON(200, gin.H{"code": 2, "msg": "匹配的主机数量超过限制"})
+		return
+	}
+	slog.Info("batch exec", "uid", uid, "client_ip", c.RemoteIP(), "tags", param.Tags, "hosts", len(list), "cmd", utils.TruncateString(param.Cmd, 1024))
+
+	results := make([]BatchExecResult, len(list))
+	sem := make(chan struct{}, batchExecWorkers)
+	var wg sync.WaitGroup
+	for i, conf := range list {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, conf model.SshConf) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = batchExecOne(conf, uid, param.Cmd, param.Confirm, param.Timeout)
+		}(i, conf)
+	}
+	wg.Wait()
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": results})
+}
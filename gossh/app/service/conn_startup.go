@@ -0,0 +1,41 @@
+package service
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// applySetEnv 通过 SSH env 请求设置环境变量,每行一个 KEY=VALUE
+// 服务端 sshd 需要在 AcceptEnv 中允许对应的变量,被拒绝时只记录日志
+func applySetEnv(conn *SshConn) {
+	for _, line := range strings.Split(conn.SetEnv, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNameRegexp.MatchString(name) {
+			continue
+		}
+		if err := conn.sshSession.Setenv(name, strings.TrimSpace(value)); err != nil {
+			slog.Warn("sshSession.Setenv rejected", "sid", conn.SessionId, "name", name, "err_msg", err.Error())
+		}
+	}
+}
+
+// sendInitBanner 连接主机前在终端显示提示信息
+func sendInitBanner(conn *SshConn, ws *termWs) {
+	banner := strings.TrimRight(strings.ReplaceAll(conn.InitBanner, "\r\n", "\n"), "\n")
+	if banner == "" {
+		return
+	}
+	ws.Notice(strings.ReplaceAll(banner, "\n", "\r\n") + "\r\n")
+}
+
+// initCmdScript shell 启动后执行的命令,例如 sudo -i
+func initCmdScript(conn *SshConn) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(conn.InitCmd, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 轮换密码时连接和执行改密命令的超时时间
+const credRotateTimeout = 30 * time.Second
+
+// 轮换任务串行执行,防止归还和定时任务同时轮换同一个密码
+var credRotateMu sync.Mutex
+
+// checkCredCheckout 开启密码轮换的配置,需要当前用户持有有效的签出才能连接
+func checkCredCheckout(conn *SshConn) error {
+	if conn.ID == 0 {
+		return nil
+	}
+	var sshConf model.SshConf
+	conf, err := sshConf.FindByID(conn.ID, conn.Uid)
+	if err != nil || conf.RotatePwd != "Y" || conf.AuthType != "pwd" {
+		return nil
+	}
+	var checkout model.CredCheckout
+	active, err := checkout.FindActive(conf.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if active.ID == 0 || active.Uid != conn.Uid {
+		return errors.New("该主机开启了密码轮换,请先签出凭据")
+	}
+	return nil
+}
+
+// CredCheckoutCreate POST 签出主机凭据,返回当前密码,归还或到期后自动轮换
+func CredCheckoutCreate(c *gin.Context) {
+	type Param struct {
+		ConfId  uint   `form:"conf_id" binding:"required" json:"conf_id"`
+		Reason  string `form:"reason" binding:"required,min=1,max=512" json:"reason"`
+		Minutes uint   `form:"minutes" binding:"lte=1440" json:"minutes"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	uid := c.GetUint("uid")
+	var sshConf model.SshConf
+	conf, err := sshConf.FindByID(param.ConfId, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	// 轮换需要无人值守登录,只支持密码认证的主机
+	if conf.RotatePwd != "Y" || conf.AuthType != "pwd" {
+		c.JSON(200, gin.H{"code": 2, "msg": "该主机未开启密码轮换"})
+		return
+	}
+	if isSecretRef(conf.Pwd) {
+		c.JSON(200, gin.H{"code": 2, "msg": "凭据由外部来源管理,不支持签出"})
+		return
+	}
+
+	var checkout model.CredCheckout
+	pending, err := checkout.HasPending(conf.ID)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if pending {
+		c.JSON(200, gin.H{"code": 4, "msg": "凭据已被签出或等待轮换密码"})
+		return
+	}
+
+	var user model.SshUser
+	u, _ := user.FindByID(uid)
+	duration := config.DefaultConfig.Rotation.Checkout
+	if param.Minutes > 0 {
+		duration = time.Duration(param.Minutes) * time.Minute
+	}
+	now := time.Now()
+	checkout = model.CredCheckout{
+		ConfId:     conf.ID,
+		ConfName:   conf.Name,
+		Address:    conf.Address,
+		SshUser:    conf.User,
+		Uid:        uid,
+		UserName:   u.Name,
+		Reason:     param.Reason,
+		ClientIp:   c.RemoteIP(),
+		CheckoutAt: model.DateTime(now),
+		ExpiryAt:   model.DateTime(now.Add(duration)),
+	}
+	if err := checkout.Create(&checkout); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("credential checkout", "id", checkout.ID, "conf_id", conf.ID, "host", conf.Address,
+		"ssh_user", conf.User, "user", u.Name, "reason", param.Reason, "expiry", now.Add(duration))
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"checkout": checkout,
+		"pwd":      conf.Pwd,
+	}})
+}
+
+// CredCheckoutReturn POST 归还凭据并立即轮换密码,只能由签出人或默认租户的管理员归还
+func CredCheckoutReturn(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var checkout model.CredCheckout
+	data, err := checkout.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	uid := c.GetUint("uid")
+	if data.Uid != uid {
+		var user model.SshUser
+		u, err := user.FindByID(uid)
+		if err != nil || !isPlatformAdmin(u) {
+			c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+			return
+		}
+	}
+	n, err := checkout.Return(data.ID, time.Now())
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if n == 0 {
+		c.JSON(200, gin.H{"code": 4, "msg": "凭据已归还"})
+		return
+	}
+	slog.Info("credential returned", "id", data.ID, "conf_id", data.ConfId, "host", data.Address, "uid", uid)
+	go rotateDueCredentials()
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
+
+// CredCheckoutFindAll GET 签出记录,默认租户的管理员查看全部,其他用户只查看自己的
+func CredCheckoutFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	uid := c.GetUint("uid")
+	var user model.SshUser
+	if u, err := user.FindByID(uid); err == nil && isPlatformAdmin(u) {
+		uid = 0
+	}
+	var checkout model.CredCheckout
+	list, total, err := checkout.FindPage(q, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list, "total": total})
+}
+
+// rotateScript 按主机的系统类型生成改密命令,未采集主机信息时按 linux 处理
+func rotateScript(conf model.SshConf, pwd string) (string, error) {
+	family := "linux"
+	var facts HostFacts
+	if conf.Facts != "" && json.Unmarshal([]byte(conf.Facts), &facts) == nil && facts.System != "" {
+		family = strings.ToLower(facts.System)
+	}
+	script, ok := config.DefaultConfig.Rotation.Scripts[family]
+	if !ok || strings.TrimSpace(script) == "" {
+		return "", fmt.Errorf("没有配置 %s 系统的改密命令", family)
+	}
+	script = strings.ReplaceAll(script, "{{user}}", shellQuote(conf.User))
+	return strings.ReplaceAll(script, "{{password}}", shellQuote(pwd)), nil
+}
+
+// changeHostPwd 使用当前密码登录主机执行改密命令
+func changeHostPwd(conf model.SshConf, pwd string) error {
+	script, err := rotateScript(conf, pwd)
+	if err != nil {
+		return err
+	}
+	client, err := dialSshConf(&conf, credRotateTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	out, err := runExec(client, session, script, uint(credRotateTimeout/time.Second))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), utils.TruncateString(strings.TrimSpace(out), 512))
+	}
+	return nil
+}
+
+// canLogin 使用指定密码登录主机
+func canLogin(conf model.SshConf, pwd string) bool {
+	conf.Pwd = pwd
+	client, err := dialSshConf(&conf, credRotateTimeout)
+	if err != nil {
+		return false
+	}
+	_ = client.Close()
+	return true
+}
+
+// rotateCredential 为签出记录轮换主机密码
+// 改密命令执行成功后立即保存新密码,新密码无法登录而旧密码仍可用时恢复旧密码
+func rotateCredential(checkout model.CredCheckout) error {
+	var sshConf model.SshConf
+	conf, err := sshConf.FindByID(checkout.ConfId, checkout.Uid)
+	if err != nil {
+		return err
+	}
+	if conf.RotatePwd != "Y" || conf.AuthType != "pwd" {
+		return errors.New("该主机未开启密码轮换")
+	}
+	if isSecretRef(conf.Pwd) {
+		return errors.New("凭据由外部来源管理,不支持轮换")
+	}
+	length := config.DefaultConfig.Rotation.PwdLength
+	if length < 12 || length > 128 {
+		length = 24
+	}
+	pwd, err := utils.RandPassword(length)
+	if err != nil {
+		return err
+	}
+	if err := changeHostPwd(conf, pwd); err != nil {
+		return err
+	}
+	if err := sshConf.UpdatePwd(conf.ID, pwd); err != nil {
+		slog.Error("save rotated password error:", "conf_id", conf.ID, "err_msg", err.Error())
+		return err
+	}
+	if canLogin(conf, pwd) {
+		return nil
+	}
+	if canLogin(conf, conf.Pwd) {
+		_ = sshConf.UpdatePwd(conf.ID, conf.Pwd)
+		return errors.New("改密命令执行成功,但新密码无法登录,已恢复原密码")
+	}
+	return errors.New("新密码和原密码均无法登录")
+}
+
+// rotateDueCredentials 轮换所有已归还或已过期的签出
+func rotateDueCredentials() {
+	credRotateMu.Lock()
+	defer credRotateMu.Unlock()
+
+	var checkout model.CredCheckout
+	list, err := checkout.FindDue(time.Now())
+	if err != nil {
+		slog.Error("find due checkout error:", "err_msg", err.Error())
+		return
+	}
+	for _, item := range list {
+		status, msg := "ok", ""
+		if err := rotateCredential(item); err != nil {
+			status, msg = "fail", err.Error()
+			slog.Error("credential rotate error:", "id", item.ID, "conf_id", item.ConfId,
+				"host", item.Address, "ssh_user", item.SshUser, "err_msg", msg)
+			notifyEvent(EventRotateFailed, "主机密码轮换失败",
+				fmt.Sprintf("主机: %s@%s\n配置: %s\n签出人: %s\n原因: %s",
+					item.SshUser, item.Address, item.ConfName, item.UserName, msg))
+		} else {
+			slog.Info("credential rotated", "id", item.ID, "conf_id", item.ConfId,
+				"host", item.Address, "ssh_user", item.SshUser)
+		}
+		if err := checkout.UpdateRotate(item.ID, status, utils.TruncateString(msg, 1000), time.Now()); err != nil {
+			slog.Error("update checkout rotate error:", "id", item.ID, "err_msg", err.Error())
+		}
+	}
+}
+
+// credRotateLoop 定时轮换到期签出的密码
+func credRotateLoop() {
+	for {
+		time.Sleep(time.Minute)
+		if backgroundEnabled() {
+			rotateDueCredentials()
+		}
+	}
+}
+
+func init() {
+	go credRotateLoop()
+}
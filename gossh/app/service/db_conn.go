@@ -24,7 +24,7 @@ func DbConnCheck(c *gin.Context) {
 
 	var dbConf DbConnConf
 	if err := c.ShouldBind(&dbConf); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
 	err := DbConnTestCheck(dbConf)
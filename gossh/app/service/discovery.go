@@ -0,0 +1,344 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/crypto/ssh"
+	"gossh/gin"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 单次扫描的最大地址数量和并发数
+const (
+	discoveryMaxAddrs   = 4096
+	discoveryMaxPorts   = 16
+	discoveryWorkers    = 64
+	discoveryBannerSize = 255
+)
+
+var errHostKeyCaptured = errors.New("host key captured")
+
+// DiscoveryHost 扫描发现的 SSH 主机
+type DiscoveryHost struct {
+	Ip          string        `json:"ip"`
+	Port        uint16        `json:"port"`
+	Hostname    string        `json:"hostname"`
+	Banner      string        `json:"banner"`
+	KeyType     string        `json:"key_type"`
+	Fingerprint string        `json:"fingerprint"`
+	Source      string        `json:"source"`
+	Exists      bool          `json:"exists"`
+	Conf        model.SshConf `json:"conf"`
+}
+
+// bannerConn 记录服务端最先发送的数据,用于提取版本标识
+type bannerConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *bannerConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if remain := discoveryBannerSize - c.buf.Len(); remain > 0 && n > 0 {
+		c.buf.Write(p[:min(n, remain)])
+	}
+	return n, err
+}
+
+// banner 返回 SSH- 开头的版本行
+func (c *bannerConn) banner() string {
+	for _, line := range strings.Split(c.buf.String(), "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.HasPrefix(line, "SSH-") {
+			return line
+		}
+	}
+	return ""
+}
+
+// parseScanTargets 解析扫描目标,支持 CIDR、起止范围(a-b)和单个地址,以逗号或空白分隔
+func parseScanTargets(s string) ([]netip.Addr, error) {
+	var list []netip.Addr
+	seen := map[netip.Addr]bool{}
+	add := func(addr netip.Addr) error {
+		addr = addr.Unmap()
+		if seen[addr] {
+			return nil
+		}
+		if len(list) >= discoveryMaxAddrs {
+			return fmt.Errorf("扫描地址数量超过限制 %d", discoveryMaxAddrs)
+		}
+		seen[addr] = true
+		list = append(list, addr)
+		return nil
+	}
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' || r == '\r' }) {
+		switch {
+		case strings.Contains(item, "/"):
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("无效的网段: %s", item)
+			}
+			prefix = prefix.Masked()
+			hostBits := prefix.Addr().BitLen() - prefix.Bits()
+			if hostBits > 12 {
+				return nil, fmt.Errorf("扫描地址数量超过限制 %d", discoveryMaxAddrs)
+			}
+			for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+				// IPv4 跳过网络地址和广播地址
+				if addr.Is4() && hostBits >= 2 && (addr == prefix.Addr() || !prefix.Contains(addr.Next())) {
+					continue
+				}
+				if err := add(addr); err != nil {
+					return nil, err
+				}
+			}
+		case strings.Contains(item, "-"):
+			from, to, _ := strings.Cut(item, "-")
+			start, err := netip.ParseAddr(from)
+			if err != nil {
+				return nil, fmt.Errorf("无效的地址范围: %s", item)
+			}
+			end, err := netip.ParseAddr(to)
+			if err != nil {
+				// 简写形式 192.168.1.10-20
+				n, convErr := strconv.Atoi(to)
+				if convErr != nil || !start.Is4() || n < 0 || n > 255 {
+					return nil, fmt.Errorf("无效的地址范围: %s", item)
+				}
+				b := start.As4()
+				b[3] = byte(n)
+				end = netip.AddrFrom4(b)
+			}
+			if start.BitLen() != end.BitLen() || end.Less(start) {
+				return nil, fmt.Errorf("无效的地址范围: %s", item)
+			}
+			for addr := start; addr.IsValid() && !end.Less(addr); addr = addr.Next() {
+				if err := add(addr); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("无效的地址: %s", item)
+			}
+			if err := add(addr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+// parseScanPorts 解析端口列表
+func parseScanPorts(s string) ([]uint16, error) {
+	var ports []uint16
+	seen := map[uint16]bool{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(item, 10, 16)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("无效的端口: %s", item)
+		}
+		if !seen[uint16(n)] {
+			seen[uint16(n)] = true
+			ports = append(ports, uint16(n))
+		}
+	}
+	if len(ports) == 0 {
+		ports = []uint16{22}
+	}
+	if len(ports) > discoveryMaxPorts {
+		return nil, fmt.Errorf("端口数量超过限制 %d", discoveryMaxPorts)
+	}
+	return ports, nil
+}
+
+// probeSshHost 连接端口,读取版本标识并在密钥交换后获取主机公钥,不进行认证,密钥交换只使用 algos 中的算法
+func probeSshHost(ip string, port uint16, timeout time.Duration, algos sshAlgos) (DiscoveryHost, bool) {
+	host := DiscoveryHost{Ip: ip, Port: port}
+	addr := net.JoinHostPort(ip, strconv.Itoa(int(port)))
+	rawConn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return host, false
+	}
+	conn := &bannerConn{Conn: rawConn}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetDeadline(time.Now().Add(timeout * 3))
+
+	config := &ssh.ClientConfig{
+		User: "discovery",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			host.KeyType = key.Type()
+			host.Fingerprint = ssh.FingerprintSHA256(key)
+			return errHostKeyCaptured
+		},
+		Timeout: timeout,
+	}
+	algos.apply(config)
+	_, _, _, err = ssh.NewClientConn(conn, addr, config)
+	host.Banner = utils.TruncateString(conn.banner(), discoveryBannerSize)
+	if host.Banner == "" && host.Fingerprint == "" {
+		slog.Debug("discovery probe not ssh", "addr", addr, "err_msg", fmt.Sprint(err))
+		return host, false
+	}
+	return host, true
+}
+
+// reverseName 反向解析主机名
+func reverseName(ip string) string {
+	names, err := net.LookupAddr(ip)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// DiscoveryScan 扫描网段中开放的 SSH 端口,返回可一键导入的候选主机
+func DiscoveryScan(c *gin.Context) {
+	type Param struct {
+		Targets string `form:"targets" binding:"max=4096" json:"targets"`
+		Ports   string `form:"ports" binding:"max=256" json:"ports"`
+		Timeout uint   `form:"timeout" binding:"lte=10000" json:"timeout"`
+		Mdns    string `form:"mdns" binding:"omitempty,oneof=Y N" json:"mdns"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	targets, err := parseScanTargets(param.Targets)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	ports, err := parseScanPorts(param.Ports)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if len(targets) == 0 && param.Mdns != "Y" {
+		c.JSON(200, gin.H{"code": 1, "msg": "请指定扫描目标"})
+		return
+	}
+	if len(targets)*len(ports) > discoveryMaxAddrs {
+		c.JSON(200, gin.H{"code": 1, "msg": "扫描数量超过限制"})
+		return
+	}
+	timeout := time.Duration(param.Timeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	// 算法配置错误时不扫描,不使用默认算法连接
+	algos, err := effectiveAlgos(nil)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	slog.Info("discovery scan", "uid", u.ID, "client_ip", c.RemoteIP(), "targets", param.Targets, "ports", param.Ports, "mdns", param.Mdns)
+
+	type probe struct {
+		ip       string
+		port     uint16
+		hostname string
+		source   string
+	}
+	var probes []probe
+	for _, addr := range targets {
+		for _, port := range ports {
+			probes = append(probes, probe{ip: addr.String(), port: port, source: "scan"})
+		}
+	}
+	if param.Mdns == "Y" {
+		services, err := utils.MdnsBrowse("_ssh._tcp.local", timeout*2)
+		if err != nil {
+			slog.Error("mdns browse error:", "err_msg", err.Error())
+		}
+		for _, item := range services {
+			for _, ip := range item.Addrs {
+				probes = append(probes, probe{ip: ip.String(), port: item.Port, hostname: strings.TrimSuffix(item.Host, "."), source: "mdns"})
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	found := map[string]DiscoveryHost{}
+	sem := make(chan struct{}, discoveryWorkers)
+	var wg sync.WaitGroup
+	for _, p := range probes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p probe) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			key := net.JoinHostPort(p.ip, strconv.Itoa(int(p.port)))
+			mu.Lock()
+			_, done := found[key]
+			mu.Unlock()
+			if done {
+				return
+			}
+			host, ok := probeSshHost(p.ip, p.port, timeout, algos)
+			if !ok {
+				return
+			}
+			host.Source = p.source
+			host.Hostname = p.hostname
+			if host.Hostname == "" {
+				host.Hostname = reverseName(p.ip)
+			}
+			mu.Lock()
+			found[key] = host
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	var conf model.SshConf
+	confs, err := conf.FindByUid(u.ID)
+	if err != nil {
+		slog.Error("find conn_conf error:", "err_msg", err.Error())
+	}
+	exists := map[string]bool{}
+	for _, item := range confs {
+		exists[net.JoinHostPort(item.Address, strconv.Itoa(int(item.Port)))] = true
+	}
+
+	list := make([]DiscoveryHost, 0, len(found))
+	for key, host := range found {
+		host.Exists = exists[key] || (host.Hostname != "" && exists[net.JoinHostPort(host.Hostname, strconv.Itoa(int(host.Port)))])
+		host.Conf = newImportConf(host.Hostname, host.Ip, "root", host.Port, "discovery")
+		list = append(list, host)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := netip.ParseAddr(list[i].Ip)
+		b, _ := netip.ParseAddr(list[j].Ip)
+		if a != b {
+			return a.Less(b)
+		}
+		return list[i].Port < list[j].Port
+	})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list, "total": len(list)})
+}
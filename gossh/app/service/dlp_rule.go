@@ -0,0 +1,108 @@
+package service
+
+import (
+	"gossh/app/model"
+	"gossh/gin"
+	"regexp"
+	"strconv"
+)
+
+func DlpRuleCreate(c *gin.Context) {
+	var dlpRule model.DlpRule
+	if err := c.ShouldBind(&dlpRule); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if _, err := regexp.Compile(dlpRule.Pattern); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "正则表达式错误:" + err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	err = dlpRule.Create(&dlpRule)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	DlpRuleFindAll(c)
+}
+
+func DlpRuleFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var dlpRule model.DlpRule
+	data, err := dlpRule.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+func DlpRuleFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+
+	var dlpRule model.DlpRule
+	data, total, err := dlpRule.FindPage(q)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func DlpRuleUpdateById(c *gin.Context) {
+	var dlpRule model.DlpRule
+	if err := c.ShouldBind(&dlpRule); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if _, err := regexp.Compile(dlpRule.Pattern); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "正则表达式错误:" + err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	err = dlpRule.UpdateById(dlpRule.ID, &dlpRule)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	DlpRuleFindAll(c)
+}
+
+func DlpRuleDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var dlpRule model.DlpRule
+	err = dlpRule.DeleteByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	DlpRuleFindAll(c)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"slices"
+)
+
+// 生产环境主机的默认提示内容
+const defaultProdBanner = "生产环境 {{name}} {{host}},请谨慎操作"
+
+// isProdHost 主机是否属于生产环境,主机配置标记为生产环境或主机标签匹配策略中的生产环境标签表达式
+// 已保存的主机以数据库中的配置为准,同时检查地址是否被其他主机配置标记为生产环境,防止客户端绕过
+func isProdHost(conn *SshConn) (bool, error) {
+	var sshConf model.SshConf
+	if conn.ID != 0 {
+		conf, err := sshConf.FindByID(conn.ID, conn.Uid)
+		if err == nil && (conf.Environment == "prod" || policyProdMatch(conn.Uid, conf)) {
+			return true, nil
+		}
+	}
+	if conn.Environment == "prod" {
+		return true, nil
+	}
+	return sshConf.IsProdEndpoint(conn.Address, conn.Port)
+}
+
+// checkProdAccess 连接生产环境主机需要用户具有生产环境权限,与其他授权无关
+// 策略开启二次确认时,confirm 需要与主机地址一致
+func checkProdAccess(conn *SshConn, confirm string) error {
+	prod, err := isProdHost(conn)
+	if err != nil {
+		slog.Error("isProdHost error:", "err_msg", err.Error())
+		return errors.New("检查主机环境错误")
+	}
+	if !prod {
+		return nil
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(conn.Uid)
+	if err != nil {
+		return errors.New("获取用户信息错误")
+	}
+	if u.IsRoot != "Y" && u.ProdAccess != "Y" {
+		slog.Warn("prod access denied", "user", u.Name, "address", conn.Address, "port", conn.Port)
+		return errors.New("没有生产环境主机的访问权限")
+	}
+
+	var policyConf model.PolicyConf
+	policy, err := policyConf.FindByTenant(u.TenantId)
+	if err == nil && policy.ProdConfirm == "Y" && confirm != conn.Address {
+		return errors.New("连接生产环境主机需要输入主机地址进行确认")
+	}
+	slog.Info("prod access", "user", u.Name, "address", conn.Address, "port", conn.Port)
+	return nil
+}
+
+// prodConfirmed 批量操作时用户确认的地址中包含主机地址时返回该地址
+func prodConfirmed(conn *SshConn, confirm []string) string {
+	if slices.Contains(confirm, conn.Address) {
+		return conn.Address
+	}
+	return ""
+}
+
+// policyProdMatch 主机标签是否匹配用户策略中的生产环境标签表达式
+func policyProdMatch(uid uint, conf model.SshConf) bool {
+	policy, err := userPolicy(uid)
+	if err != nil || policy.ProdTags == "" {
+		return false
+	}
+	tagExpr, err := utils.ParseTagExpr(policy.ProdTags)
+	if err != nil {
+		slog.Error("policy prod_tags error:", "err_msg", err.Error())
+		return false
+	}
+	return tagExpr.Match(confTags(conf))
+}
+
+// sendProdBanner 生产环境主机在终端开始前显示红色的提示,由服务端写入终端,客户端无法关闭
+func sendProdBanner(conn *SshConn, ws *termWs) {
+	if prod, err := isProdHost(conn); err != nil || !prod {
+		return
+	}
+	banner := defaultProdBanner
+	if policy, err := userPolicy(conn.Uid); err == nil && policy.ProdBanner != "" {
+		banner = policy.ProdBanner
+	}
+	text, err := renderSnippet(banner, conn, nil)
+	if err != nil {
+		text = banner
+	}
+	text = ansiEscapeRe.ReplaceAllString(text, "")
+	ws.Notice(fmt.Sprintf("\x1b[1;97;41m %s \x1b[0m\r\n", text))
+	if ws.proto != "" {
+		ws.Event(TermEventProd, text)
+	}
+}
+
+// policyProd 生产环境主机设置
+type policyProd struct {
+	Id          uint   `form:"id" binding:"required" json:"id"`
+	ProdTags    string `form:"prod_tags" binding:"max=4096" json:"prod_tags"`
+	ProdBanner  string `form:"prod_banner" binding:"max=255" json:"prod_banner"`
+	ProdConfirm string `form:"prod_confirm" binding:"omitempty,oneof=Y N" json:"prod_confirm"`
+}
+
+// PolicyProdSet PUT 设置标记为生产环境的主机标签表达式、终端提示内容和执行前是否需要确认,提示内容支持 {{name}}、{{host}} 等变量
+func PolicyProdSet(c *gin.Context) {
+	var param policyProd
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if param.ProdTags != "" {
+		if _, err := utils.ParseTagExpr(param.ProdTags); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+			return
+		}
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	old, ok := findTenantPolicy(c, param.Id)
+	if !ok {
+		return
+	}
+	// 未传是否需要确认时保持原值
+	if param.ProdConfirm == "" {
+		param.ProdConfirm = old.ProdConfirm
+	}
+	if submitChange(c, "policy_prod", "update", param.Id, param) {
+		return
+	}
+	var conf model.PolicyConf
+	if err := conf.UpdateProd(param.Id, param.ProdTags, param.ProdBanner, param.ProdConfirm); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	data, err := conf.FindByID(param.Id)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
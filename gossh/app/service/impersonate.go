@@ -0,0 +1,123 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/middleware"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"time"
+)
+
+// ImpersonateStart POST 管理员临时以其他用户身份登录,用于排查权限问题
+// 需要 can_impersonate 权限,不能模拟 Root 用户,也不能在模拟期间再次模拟
+func ImpersonateStart(c *gin.Context) {
+	type Param struct {
+		Uid     uint   `form:"uid" binding:"required" json:"uid"`
+		Reason  string `form:"reason" binding:"required,min=1,max=512" json:"reason"`
+		Minutes uint   `form:"minutes" binding:"omitempty,min=1" json:"minutes"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if c.GetUint("impersonator") != 0 {
+		c.JSON(200, gin.H{"code": 2, "msg": "模拟登录时不能执行该操作"})
+		return
+	}
+
+	var user model.SshUser
+	admin, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || admin.IsAdmin == "N" || (admin.IsRoot != "Y" && admin.CanImpersonate != "Y") {
+		c.JSON(200, gin.H{"code": 2, "msg": "没有模拟登录权限"})
+		return
+	}
+	target, err := user.FindByID(param.Uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": "获取用户信息错误"})
+		return
+	}
+	if target.IsRoot == "Y" || target.ID == admin.ID || (admin.IsRoot != "Y" && target.TenantId != admin.TenantId) {
+		c.JSON(200, gin.H{"code": 3, "msg": "不能模拟该用户"})
+		return
+	}
+
+	duration := config.DefaultConfig.ImpersonateMax
+	if param.Minutes != 0 && time.Duration(param.Minutes)*time.Minute < duration {
+		duration = time.Duration(param.Minutes) * time.Minute
+	}
+	now := time.Now()
+	impersonateLog := model.ImpersonateLog{
+		AdminId:   admin.ID,
+		AdminName: admin.Name,
+		Uid:       target.ID,
+		UserName:  target.Name,
+		Reason:    param.Reason,
+		ClientIp:  c.ClientIP(),
+		RequestId: c.GetString("request_id"),
+		StartAt:   model.DateTime(now),
+		ExpiryAt:  model.DateTime(now.Add(duration)),
+	}
+	if err := impersonateLog.Create(&impersonateLog); err != nil {
+		slog.Error("ImpersonateLog.Create error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	token, err := middleware.GenerateImpersonateToken(target.ID, admin.ID, impersonateLog.ID, now.Add(duration))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 5, "msg": err.Error()})
+		return
+	}
+	slog.Warn("impersonate start", "impersonate_id", impersonateLog.ID, "admin", admin.Name, "user", target.Name,
+		"reason", param.Reason, "client_ip", c.ClientIP(), "expiry_at", impersonateLog.ExpiryAt.String())
+	c.JSON(200, gin.H{
+		"code":         0,
+		"msg":          "ok",
+		"token":        token,
+		"impersonator": admin.Name,
+		"user_name":    target.Name,
+		"is_admin":     target.IsAdmin,
+		"is_root":      target.IsRoot,
+		"expiry_at":    impersonateLog.ExpiryAt,
+	})
+}
+
+// ImpersonateEnd POST 结束模拟登录,当前模拟的Token立即失效
+func ImpersonateEnd(c *gin.Context) {
+	id := c.GetUint("impersonate_id")
+	if id == 0 {
+		c.JSON(200, gin.H{"code": 1, "msg": "当前不是模拟登录"})
+		return
+	}
+	var impersonateLog model.ImpersonateLog
+	if err := impersonateLog.End(id, time.Now()); err != nil {
+		slog.Error("ImpersonateLog.End error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	slog.Warn("impersonate end", "impersonate_id", id, "admin_id", c.GetUint("impersonator"), "uid", c.GetUint("uid"))
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
+
+// ImpersonateLogFindAll GET 模拟登录审计记录
+func ImpersonateLogFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || !isPlatformAdmin(u) {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var impersonateLog model.ImpersonateLog
+	data, total, err := impersonateLog.FindPage(q)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
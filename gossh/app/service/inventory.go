@@ -0,0 +1,461 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 同步任务串行执行,防止定时同步和手动同步同时修改配置
+var inventorySyncMu sync.Mutex
+
+// inventoryCredRule 凭据映射规则,实例标签匹配 Expr 时使用 ConfId 配置的账号和认证信息
+// Expr 为空时匹配所有实例,实例标签额外包含 provider、region 和 platform
+type inventoryCredRule struct {
+	Expr   string `json:"expr"`
+	ConfId uint   `json:"conf_id"`
+}
+
+type inventoryRule struct {
+	expr utils.TagExpr
+	conf model.SshConf
+}
+
+// InventoryResult 一次同步的变更汇总,Unmapped 为没有匹配凭据规则而未创建的实例
+type InventoryResult struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Stale     []string `json:"stale"`
+	Unmapped  []string `json:"unmapped"`
+	Unchanged int      `json:"unchanged"`
+}
+
+func (r InventoryResult) String() string {
+	return fmt.Sprintf("created: %d, updated: %d, stale: %d, unmapped: %d, unchanged: %d",
+		len(r.Created), len(r.Updated), len(r.Stale), len(r.Unmapped), r.Unchanged)
+}
+
+// loadCredRules 解析凭据映射规则并加载模板配置
+func loadCredRules(rules string, uid uint) ([]inventoryRule, error) {
+	if rules == "" {
+		return nil, nil
+	}
+	var list []inventoryCredRule
+	if err := json.Unmarshal([]byte(rules), &list); err != nil {
+		return nil, fmt.Errorf("凭据规则格式错误: %s", err.Error())
+	}
+	var sshConf model.SshConf
+	ret := make([]inventoryRule, 0, len(list))
+	for _, item := range list {
+		var rule inventoryRule
+		if item.Expr != "" {
+			expr, err := utils.ParseTagExpr(item.Expr)
+			if err != nil {
+				return nil, err
+			}
+			rule.expr = expr
+		}
+		conf, err := sshConf.FindByID(item.ConfId, uid)
+		if err != nil {
+			return nil, fmt.Errorf("凭据规则的配置不存在: %d", item.ConfId)
+		}
+		rule.conf = conf
+		ret = append(ret, rule)
+	}
+	return ret, nil
+}
+
+// checkInventorySource 校验过滤条件和凭据规则
+func checkInventorySource(source model.InventorySource) error {
+	if _, err := utils.ParseTagFilter(source.Filter); err != nil {
+		return err
+	}
+	_, err := loadCredRules(source.CredRules, source.Uid)
+	return err
+}
+
+// newCloudProvider 根据来源类型创建云主机清单接口
+func newCloudProvider(source model.InventorySource) (utils.CloudProvider, error) {
+	switch source.Provider {
+	case "aws":
+		return utils.AwsEc2{Region: source.Region, Endpoint: source.Endpoint, AccessKey: source.AccessKey, SecretKey: source.SecretKey}, nil
+	case "aliyun":
+		return utils.AliyunEcs{Region: source.Region, Endpoint: source.Endpoint, AccessKey: source.AccessKey, SecretKey: source.SecretKey}, nil
+	case "tencent":
+		return utils.TencentCvm{Region: source.Region, Endpoint: source.Endpoint, AccessKey: source.AccessKey, SecretKey: source.SecretKey}, nil
+	}
+	return nil, fmt.Errorf("unsupported inventory provider: %s", source.Provider)
+}
+
+// instanceAddr 按来源设置选择内网或公网地址,没有时使用另一个地址
+func instanceAddr(instance utils.CloudInstance, addrType string) string {
+	first, second := instance.PrivateIp, instance.PublicIp
+	if addrType == "public" {
+		first, second = second, first
+	}
+	if first != "" {
+		return first
+	}
+	return second
+}
+
+// inventoryFields 实例对应的配置字段,rule 为空时不更新账号和认证信息
+func inventoryFields(instance utils.CloudInstance, address string, tags utils.Tags, rule *inventoryRule, current model.SshConf) map[string]any {
+	name := instance.Name
+	if name == "" {
+		name = instance.Id
+	}
+	netType := "tcp4"
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		netType = "tcp6"
+	}
+	fields := map[string]any{
+		"name":     utils.TruncateString(name, 63),
+		"address":  address,
+		"net_type": netType,
+		"tags":     tags.String(),
+		"stale":    "N",
+	}
+	if rule != nil {
+		fields["user"] = rule.conf.User
+		fields["auth_type"] = rule.conf.AuthType
+		fields["port"] = rule.conf.Port
+		fields["cert_data"] = rule.conf.CertData
+		fields["cert_pwd"] = rule.conf.CertPwd
+		fields["user_cert"] = rule.conf.UserCert
+		// 开启密码轮换的配置,密码由系统维护
+		if current.RotatePwd != "Y" {
+			fields["pwd"] = rule.conf.Pwd
+		}
+	}
+	return fields
+}
+
+// inventoryChanged 返回与当前配置不同的字段
+func inventoryChanged(conf model.SshConf, fields map[string]any) map[string]any {
+	var current map[string]any
+	data, _ := json.Marshal(conf)
+	_ = json.Unmarshal(data, &current)
+	changed := map[string]any{}
+	for k, v := range fields {
+		if fmt.Sprint(current[k]) != fmt.Sprint(v) {
+			changed[k] = v
+		}
+	}
+	return changed
+}
+
+// syncInventory 拉取云主机实例,新增匹配凭据规则的实例,更新已有配置,将不存在的实例标记为过期
+// 用户已删除的配置不再重新创建
+func syncInventory(source model.InventorySource) (InventoryResult, error) {
+	result := InventoryResult{Created: []string{}, Updated: []string{}, Stale: []string{}, Unmapped: []string{}}
+	filter, err := utils.ParseTagFilter(source.Filter)
+	if err != nil {
+		return result, err
+	}
+	rules, err := loadCredRules(source.CredRules, source.Uid)
+	if err != nil {
+		return result, err
+	}
+	provider, err := newCloudProvider(source)
+	if err != nil {
+		return result, err
+	}
+	instances, err := provider.ListInstances(filter)
+	if err != nil {
+		return result, err
+	}
+
+	var sshConf model.SshConf
+	existing, err := sshConf.FindByInventory(source.ID)
+	if err != nil {
+		return result, err
+	}
+	current := make(map[string]model.SshConf, len(existing))
+	for _, conf := range existing {
+		current[conf.ExternalId] = conf
+	}
+
+	seen := map[string]bool{}
+	for _, instance := range instances {
+		address := instanceAddr(instance, source.AddrType)
+		if instance.Terminated() || address == "" || seen[instance.Id] {
+			continue
+		}
+		seen[instance.Id] = true
+
+		tags := instance.InstanceTags()
+		tags["provider"] = []string{source.Provider}
+		tags["region"] = []string{source.Region}
+		if instance.Platform != "" {
+			tags["platform"] = []string{instance.Platform}
+		}
+		var rule *inventoryRule
+		for i := range rules {
+			if rules[i].expr == nil || rules[i].expr.Match(tags) {
+				rule = &rules[i]
+				break
+			}
+		}
+
+		conf, ok := current[instance.Id]
+		if ok && conf.DeletedAt.Valid {
+			continue
+		}
+		fields := inventoryFields(instance, address, tags, rule, conf)
+		if !ok {
+			if rule == nil {
+				result.Unmapped = append(result.Unmapped, instance.Id)
+				continue
+			}
+			conf = newImportConf(fields["name"].(string), address, rule.conf.User, rule.conf.Port, source.Name)
+			conf.Uid = source.Uid
+			conf.AuthType = rule.conf.AuthType
+			conf.Pwd = rule.conf.Pwd
+			conf.CertData = rule.conf.CertData
+			conf.CertPwd = rule.conf.CertPwd
+			conf.UserCert = rule.conf.UserCert
+			conf.Tags = fields["tags"].(string)
+			conf.ExternalId = instance.Id
+			conf.InventoryId = source.ID
+			conf.Stale = "N"
+			if err := checkHostQuota(conf.Uid, 1); err != nil {
+				return result, err
+			}
+			if err := sshConf.Create(&conf); err != nil {
+				return result, err
+			}
+			result.Created = append(result.Created, instance.Id)
+			continue
+		}
+		changed := inventoryChanged(conf, fields)
+		if len(changed) == 0 {
+			result.Unchanged++
+			continue
+		}
+		if err := sshConf.UpdateInventory(conf.ID, changed); err != nil {
+			return result, err
+		}
+		result.Updated = append(result.Updated, instance.Id)
+	}
+
+	for _, conf := range existing {
+		if seen[conf.ExternalId] || conf.DeletedAt.Valid || conf.Stale == "Y" {
+			continue
+		}
+		if err := sshConf.UpdateInventory(conf.ID, map[string]any{"stale": "Y"}); err != nil {
+			return result, err
+		}
+		result.Stale = append(result.Stale, conf.ExternalId)
+	}
+	return result, nil
+}
+
+// runInventorySync 同步并记录结果
+func runInventorySync(source model.InventorySource) (InventoryResult, error) {
+	inventorySyncMu.Lock()
+	defer inventorySyncMu.Unlock()
+
+	result, err := syncInventory(source)
+	status, msg := "ok", result.String()
+	if err != nil {
+		status, msg = "fail", err.Error()
+		slog.Error("inventory sync error:", "id", source.ID, "name", source.Name, "err_msg", msg)
+	} else {
+		slog.Info("inventory synced", "id", source.ID, "name", source.Name, "result", msg)
+	}
+	var inventory model.InventorySource
+	if e := inventory.UpdateSyncResult(source.ID, status, utils.TruncateString(msg, 4096), time.Now()); e != nil {
+		slog.Error("UpdateSyncResult error:", "err_msg", e.Error())
+	}
+	return result, err
+}
+
+// syncDueInventory 同步到达同步间隔的来源
+func syncDueInventory() {
+	var inventory model.InventorySource
+	list, err := inventory.FindEnabled()
+	if err != nil {
+		slog.Error("find inventory source error:", "err_msg", err.Error())
+		return
+	}
+	now := time.Now()
+	for _, source := range list {
+		next := time.Time(source.LastSyncAt).Add(time.Duration(source.SyncInterval) * time.Minute)
+		if now.Before(next) {
+			continue
+		}
+		_, _ = runInventorySync(source)
+	}
+}
+
+// inventorySyncLoop 定时同步云主机清单
+func inventorySyncLoop() {
+	for {
+		time.Sleep(time.Minute)
+		if backgroundEnabled() {
+			syncDueInventory()
+		}
+	}
+}
+
+func InventoryCreate(c *gin.Context) {
+	var source model.InventorySource
+	if err := c.ShouldBind(&source); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	// 同步的配置属于创建来源的管理员
+	source.Uid = u.ID
+	if err := checkInventorySource(source); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := source.Create(&source); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	InventoryFindAll(c)
+}
+
+func InventoryFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var source model.InventorySource
+	data, err := source.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+func InventoryFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var source model.InventorySource
+	data, total, err := source.FindPage(q)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func InventoryUpdateById(c *gin.Context) {
+	var source model.InventorySource
+	if err := c.ShouldBind(&source); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if source.IsEnable == "" {
+		source.IsEnable = "Y"
+	}
+	if source.AddrType == "" {
+		source.AddrType = "private"
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	old, err := source.FindByID(source.ID)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	source.Uid = old.Uid
+	if err := checkInventorySource(source); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := source.UpdateById(source.ID, &source); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	InventoryFindAll(c)
+}
+
+// InventoryDeleteById DELETE 删除来源,已同步的配置保留
+func InventoryDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var source model.InventorySource
+	if err := source.DeleteByID(uint(id)); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	InventoryFindAll(c)
+}
+
+// InventorySync POST 立即同步,同步返回变更汇总
+func InventorySync(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var inventory model.InventorySource
+	source, err := inventory.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	result, err := runInventorySync(source)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error(), "data": result})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": result})
+}
+
+func init() {
+	go inventorySyncLoop()
+}
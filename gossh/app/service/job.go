@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 后台任务状态
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// 已结束的任务保留时长
+const jobRetention = 24 * time.Hour
+
+// JobState 后台任务的状态和进度
+type JobState struct {
+	Id         string           `json:"id"`
+	Kind       string           `json:"kind"`
+	Target     string           `json:"target"`
+	Uid        uint             `json:"uid"`
+	Status     string           `json:"status"`
+	Total      int              `json:"total"`
+	Done       int              `json:"done"`
+	Step       string           `json:"step"`
+	Deleted    map[string]int64 `json:"deleted"`
+	Errors     []string         `json:"errors"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at"`
+}
+
+// Job 耗时较长的后台任务,如级联删除,通过 /api/jobs/:id 查询进度
+type Job struct {
+	mu sync.Mutex
+	JobState
+}
+
+var jobs sync.Map
+
+// view 任务的快照,避免返回时与执行中的任务产生数据竞争
+func (j *Job) view() JobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	deleted := make(map[string]int64, len(j.Deleted))
+	for k, v := range j.Deleted {
+		deleted[k] = v
+	}
+	return JobState{
+		Id: j.Id, Kind: j.Kind, Target: j.Target, Uid: j.Uid, Status: j.Status,
+		Total: j.Total, Done: j.Done, Step: j.Step, Deleted: deleted,
+		Errors: append([]string{}, j.Errors...), CreatedAt: j.CreatedAt, FinishedAt: j.FinishedAt,
+	}
+}
+
+// AddTotal 增加任务的总步数,执行过程中才能确定数量的步骤使用
+func (j *Job) AddTotal(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Total += n
+}
+
+// Progress 完成一步,记录删除的数量和错误
+func (j *Job) Progress(step string, deleted int64, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Done++
+	j.Step = step
+	j.Deleted[step] += deleted
+	if err != nil {
+		j.Errors = append(j.Errors, step+": "+err.Error())
+	}
+}
+
+// startJob 创建任务并在后台执行,任务中出现的错误通过 Progress 记录
+func startJob(kind, target string, uid uint, total int, run func(job *Job)) *Job {
+	job := &Job{JobState: JobState{
+		Id:        utils.RandString(16),
+		Kind:      kind,
+		Target:    target,
+		Uid:       uid,
+		Status:    JobPending,
+		Total:     total,
+		Deleted:   map[string]int64{},
+		Errors:    []string{},
+		CreatedAt: time.Now(),
+	}}
+	jobs.Store(job.Id, job)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				utils.ReportPanic(err, utils.PanicContext{Source: "job:" + kind})
+				job.Progress("panic", 0, errors.New("任务异常终止"))
+			}
+			now := time.Now()
+			job.mu.Lock()
+			job.Status = JobDone
+			if len(job.Errors) > 0 {
+				job.Status = JobFailed
+			}
+			job.FinishedAt = &now
+			job.mu.Unlock()
+			slog.Info("job finished", "id", job.Id, "kind", kind, "target", target, "status", job.Status, "deleted", job.Deleted)
+		}()
+		job.mu.Lock()
+		job.Status = JobRunning
+		job.mu.Unlock()
+		run(job)
+	}()
+	return job
+}
+
+// cleanJobs 删除超过保留时长的已结束任务
+func cleanJobs() {
+	jobs.Range(func(key, value any) bool {
+		job := value.(*Job)
+		job.mu.Lock()
+		expired := job.FinishedAt != nil && time.Since(*job.FinishedAt) > jobRetention
+		job.mu.Unlock()
+		if expired {
+			jobs.Delete(key)
+			pipelineRuns.Delete(key)
+		}
+		return true
+	})
+}
+
+func jobCleanLoop() {
+	for {
+		time.Sleep(time.Hour)
+		cleanJobs()
+	}
+}
+
+// jobVisible 默认租户的管理员可以查看全部任务,其他用户只能查看自己创建的任务
+func jobVisible(job JobState, u model.SshUser) bool {
+	return isPlatformAdmin(u) || job.Uid == u.ID
+}
+
+// JobFindAll GET 后台任务列表,按创建时间从新到旧
+func JobFindAll(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "获取用户信息错误"})
+		return
+	}
+	list := []JobState{}
+	jobs.Range(func(key, value any) bool {
+		if job := value.(*Job).view(); jobVisible(job, u) {
+			list = append(list, job)
+		}
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list})
+}
+
+// JobFindByID GET 后台任务状态和进度
+func JobFindByID(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "获取用户信息错误"})
+		return
+	}
+	value, ok := jobs.Load(c.Param("id"))
+	if !ok {
+		c.JSON(200, gin.H{"code": 2, "msg": "任务不存在"})
+		return
+	}
+	job := value.(*Job).view()
+	if !jobVisible(job, u) {
+		c.JSON(200, gin.H{"code": 2, "msg": "任务不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": job})
+}
+
+func init() {
+	go jobCleanLoop()
+}
@@ -40,14 +40,14 @@ func LoginAuditSearch(c *gin.Context) {
 	}
 	var p Param
 	if err := c.ShouldBind(&p); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
 	if p.Limit == 0 {
 		p.Limit = 100
 	}
 	var audit model.LoginAudit
-	data, count, err := audit.Search(p.IsSuccess, p.Name, p.ClientIp, p.OccurBegin, p.OccurEnd, p.Offset, p.Limit)
+	data, count, err := audit.Search(tenantScope(c, u), p.IsSuccess, p.Name, p.ClientIp, p.OccurBegin, p.OccurEnd, p.Offset, p.Limit)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
 		return
@@ -1,18 +1,41 @@
 package service
 
 import (
+	"gossh/app/middleware"
 	"gossh/app/model"
+	"gossh/app/utils"
 	"gossh/gin"
 	"strconv"
 )
 
+// checkNetFilter 校验引用的地址组,未设置动作时按规则所属的模式处理
+func checkNetFilter(netFilter *model.NetFilter) string {
+	if netFilter.Action == "" {
+		netFilter.Action = "auto"
+	}
+	if netFilter.GroupId != 0 {
+		var netGroup model.NetGroup
+		if _, err := netGroup.FindByID(netFilter.GroupId); err != nil {
+			return "地址组不存在"
+		}
+	}
+	return ""
+}
+
 func NetFilterCreate(c *gin.Context) {
 	var netFilter model.NetFilter
 	if err := c.ShouldBind(&netFilter); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
+		return
+	}
+	if msg := checkNetFilter(&netFilter); msg != "" {
+		c.JSON(200, gin.H{"code": 1, "msg": msg})
 		return
 	}
 
+	if submitChange(c, "net_filter", "create", 0, netFilter) {
+		return
+	}
 	err := netFilter.Create(&netFilter)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
@@ -37,31 +60,32 @@ func NetFilterFindByID(c *gin.Context) {
 }
 
 func NetFilterFindAll(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10000"))
-	if err != nil {
-		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
-		return
-	}
-
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	q, err := parsePageQuery(c)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
 
 	var netFilter model.NetFilter
-	data, err := netFilter.FindAll(offset, limit)
+	data, total, err := netFilter.FindPage(q)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
 }
 
 func NetFilterUpdateById(c *gin.Context) {
 	var netFilter model.NetFilter
 	if err := c.ShouldBind(&netFilter); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
+		return
+	}
+	if msg := checkNetFilter(&netFilter); msg != "" {
+		c.JSON(200, gin.H{"code": 1, "msg": msg})
+		return
+	}
+	if submitChange(c, "net_filter", "update", netFilter.ID, netFilter) {
 		return
 	}
 	err := netFilter.UpdateById(netFilter.ID, &netFilter)
@@ -79,6 +103,9 @@ func NetFilterDeleteById(c *gin.Context) {
 		return
 	}
 	var netFilter model.NetFilter
+	if submitChange(c, "net_filter", "delete", uint(id), nil) {
+		return
+	}
 	err = netFilter.DeleteByID(uint(id))
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
@@ -86,3 +113,23 @@ func NetFilterDeleteById(c *gin.Context) {
 	}
 	NetFilterFindAll(c)
 }
+
+// NetFilterTest GET 按当前规则判断IP是否允许访问,返回命中的规则,不影响实际访问
+func NetFilterTest(c *gin.Context) {
+	ip := utils.ParseHostIP(c.Query("ip"))
+	if ip == nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "IP地址格式错误"})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"ip":       ip.String(),
+		"geo":      utils.GeoLookup(ip),
+		"decision": middleware.EvaluateNetFilter(ip),
+	}})
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// checkNetGroup 校验地址组中的每一项都是 CIDR 或 IP
+func checkNetGroup(group model.NetGroup) string {
+	entries := group.Entries()
+	if len(entries) == 0 {
+		return "地址组不能为空"
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return "地址格式错误:" + entry
+			}
+		} else if utils.ParseHostIP(entry) == nil {
+			return "地址格式错误:" + entry
+		}
+	}
+	return ""
+}
+
+func NetGroupFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var netGroup model.NetGroup
+	data, total, err := netGroup.FindPage(q)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func NetGroupFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var netGroup model.NetGroup
+	data, err := netGroup.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+func NetGroupCreate(c *gin.Context) {
+	var netGroup model.NetGroup
+	if err := c.ShouldBind(&netGroup); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if msg := checkNetGroup(netGroup); msg != "" {
+		c.JSON(200, gin.H{"code": 1, "msg": msg})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if submitChange(c, "net_group", "create", 0, netGroup) {
+		return
+	}
+	if err := netGroup.Create(&netGroup); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	NetGroupFindAll(c)
+}
+
+func NetGroupUpdateById(c *gin.Context) {
+	var netGroup model.NetGroup
+	if err := c.ShouldBind(&netGroup); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if msg := checkNetGroup(netGroup); msg != "" {
+		c.JSON(200, gin.H{"code": 1, "msg": msg})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if submitChange(c, "net_group", "update", netGroup.ID, netGroup) {
+		return
+	}
+	if err := netGroup.UpdateById(netGroup.ID, &netGroup); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	NetGroupFindAll(c)
+}
+
+func NetGroupDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var netFilter model.NetFilter
+	if count, err := netFilter.CountByGroup(uint(id)); err != nil || count > 0 {
+		c.JSON(200, gin.H{"code": 3, "msg": "地址组被网络过滤规则引用,不能删除"})
+		return
+	}
+	if submitChange(c, "net_group", "delete", uint(id), nil) {
+		return
+	}
+	var netGroup model.NetGroup
+	if err := netGroup.DeleteByID(uint(id)); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	NetGroupFindAll(c)
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 通知事件
+const (
+	EventLoginFailed  = "login_failed"
+	EventNewDevice    = "new_device"
+	EventApproval     = "approval"
+	EventLongSession  = "long_session"
+	EventVirusFound   = "virus_found"
+	EventRotateFailed = "rotate_failed"
+	EventReport       = "report"
+)
+
+var notifyEvents = []string{EventLoginFailed, EventNewDevice, EventApproval, EventLongSession, EventVirusFound, EventRotateFailed, EventReport}
+
+// 同一来源的登录失败通知间隔,防止暴力破解时大量发送
+const loginFailedNotifyInterval = time.Minute * 5
+
+var loginFailedNotified sync.Map
+
+// notifyLoginFailed 登录失败通知,同一客户端IP在间隔内只通知一次
+func notifyLoginFailed(audit model.LoginAudit) {
+	now := time.Now()
+	if last, ok := loginFailedNotified.Load(audit.ClientIp); ok && now.Sub(last.(time.Time)) < loginFailedNotifyInterval {
+		return
+	}
+	loginFailedNotified.Store(audit.ClientIp, now)
+	loginFailedNotified.Range(func(key, value any) bool {
+		if now.Sub(value.(time.Time)) >= loginFailedNotifyInterval {
+			loginFailedNotified.Delete(key)
+		}
+		return true
+	})
+	notifyEvent(EventLoginFailed, "登录失败",
+		fmt.Sprintf("用户名: %s\n客户端IP: %s\n位置: %s %s\nUser-Agent: %s",
+			audit.Name, audit.ClientIp, audit.Country, audit.City, audit.UserAgent))
+}
+
+// newNotifier 根据渠道类型创建通知发送器
+func newNotifier(channel model.NotifyChannel) (utils.Notifier, error) {
+	switch channel.Type {
+	case "email":
+		smtpConf := config.DefaultConfig.Smtp
+		var to []string
+		for _, addr := range strings.Split(channel.Target, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+		return utils.MailNotifier{Server: utils.MailServer{
+			Host: smtpConf.Host,
+			Port: smtpConf.Port,
+			User: smtpConf.User,
+			Pwd:  smtpConf.Pwd,
+			From: smtpConf.From,
+		}, To: to}, nil
+	case "slack":
+		return utils.SlackNotifier{Webhook: channel.Target}, nil
+	case "dingtalk":
+		return utils.DingTalkNotifier{Webhook: channel.Target, Secret: channel.Secret}, nil
+	case "wecom":
+		return utils.WeComNotifier{Webhook: channel.Target}, nil
+	}
+	return nil, fmt.Errorf("unsupported notify type: %s", channel.Type)
+}
+
+// notifyEvent 异步发送事件通知到订阅了该事件的渠道
+func notifyEvent(event, title, content string) {
+	if !config.DefaultConfig.IsInit {
+		return
+	}
+	go func() {
+		var notifyChannel model.NotifyChannel
+		list, err := notifyChannel.FindByEvent(event)
+		if err != nil {
+			slog.Error("FindByEvent error:", "err_msg", err.Error())
+			return
+		}
+		title := fmt.Sprintf("[%s] %s", config.DefaultConfig.AppName, title)
+		for _, channel := range list {
+			notifier, err := newNotifier(channel)
+			if err == nil {
+				err = notifier.Send(title, content)
+			}
+			if err != nil {
+				slog.Error("send notify error:", "event", event, "channel", channel.Name, "err_msg", err.Error())
+			}
+		}
+	}()
+}
+
+// checkNotifyEvents 校验订阅的事件名称
+func checkNotifyEvents(events string) error {
+	for _, e := range strings.Split(events, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		valid := false
+		for _, name := range notifyEvents {
+			if e == name {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown notify event: %s", e)
+		}
+	}
+	return nil
+}
+
+// notifyLongSession 会话时长超过策略配置时通知一次
+func notifyLongSession() {
+	policies := tenantPolicies()
+	OnlineClients.Range(func(key, value any) bool {
+		conn, ok := value.(*SshConn)
+		if !ok || conn == nil || conn.longNotified {
+			return true
+		}
+		policy, ok := policies(conn.tenantId)
+		if !ok || policy.LongSession == 0 || time.Since(conn.StartTime) < time.Duration(policy.LongSession)*time.Minute {
+			return true
+		}
+		conn.longNotified = true
+		var user model.SshUser
+		u, _ := user.FindByID(conn.Uid)
+		notifyEvent(EventLongSession, "长时间会话",
+			fmt.Sprintf("用户: %s\n主机: %s@%s:%d\n客户端IP: %s\n开始时间: %s\n持续时间: %s",
+				u.Name, conn.User, conn.Address, conn.Port, conn.ClientIP,
+				conn.StartTime.Format(model.TimeFormat), time.Since(conn.StartTime).Round(time.Second)))
+		return true
+	})
+}
+
+func NotifyChannelCreate(c *gin.Context) {
+	var channel model.NotifyChannel
+	if err := c.ShouldBind(&channel); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkNotifyEvents(channel.Events); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if err := channel.Create(&channel); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	NotifyChannelFindAll(c)
+}
+
+func NotifyChannelFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var channel model.NotifyChannel
+	data, err := channel.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+func NotifyChannelFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var channel model.NotifyChannel
+	data, total, err := channel.FindPage(q)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total, "events": notifyEvents})
+}
+
+func NotifyChannelUpdateById(c *gin.Context) {
+	var channel model.NotifyChannel
+	if err := c.ShouldBind(&channel); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkNotifyEvents(channel.Events); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if channel.IsEnable == "" {
+		channel.IsEnable = "Y"
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if err := channel.UpdateById(channel.ID, &channel); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	NotifyChannelFindAll(c)
+}
+
+func NotifyChannelDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var channel model.NotifyChannel
+	if err := channel.DeleteByID(uint(id)); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	NotifyChannelFindAll(c)
+}
+
+// NotifyChannelTest POST 向渠道发送测试消息,同步返回发送结果
+func NotifyChannelTest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var notifyChannel model.NotifyChannel
+	channel, err := notifyChannel.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	notifier, err := newNotifier(channel)
+	if err == nil {
+		err = notifier.Send(fmt.Sprintf("[%s] 测试通知", config.DefaultConfig.AppName), "通知渠道配置正确")
+	}
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
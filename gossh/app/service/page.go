@@ -0,0 +1,41 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/model"
+	"gossh/gin"
+	"strconv"
+)
+
+// 每页最大记录数
+const maxPageSize = 1000
+
+// parsePageQuery 解析列表查询参数
+// 支持 page/page_size 分页,未指定时兼容原有的 limit/offset 参数
+// sort 为排序字段,以 - 开头表示倒序,filter[字段]=值 为过滤条件
+func parsePageQuery(c *gin.Context) (model.PageQuery, error) {
+	q := model.PageQuery{Sort: c.Query("sort"), Filters: c.QueryMap("filter")}
+	if c.Query("page") != "" || c.Query("page_size") != "" {
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			return q, errors.New("invalid page")
+		}
+		size, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		if err != nil || size < 1 || size > maxPageSize {
+			return q, errors.New("invalid page_size")
+		}
+		q.Offset, q.Limit = (page-1)*size, size
+		return q, nil
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	if err != nil {
+		return q, errors.New("获取limit错误")
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		return q, errors.New("获取offset错误")
+	}
+	q.Offset, q.Limit = offset, limit
+	return q, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resetTokenSign 重置令牌签名,包含当前密码和更新时间,密码修改后令牌自动失效
+func resetTokenSign(user model.SshUser, expiry int64) string {
+	mac := hmac.New(sha256.New, []byte("password_reset:"+config.DefaultConfig.JwtSecret))
+	mac.Write([]byte(fmt.Sprintf("%d.%d.%s.%d", user.ID, expiry, user.Pwd, user.UpdatedAt.ToTime().Unix())))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newResetToken 生成密码重置令牌,格式为 uid.过期时间.签名
+func newResetToken(user model.SshUser) string {
+	expiry := time.Now().Add(config.DefaultConfig.ResetExpire).Unix()
+	return fmt.Sprintf("%d.%d.%s", user.ID, expiry, resetTokenSign(user, expiry))
+}
+
+// checkResetToken 校验重置令牌,返回令牌对应的用户
+func checkResetToken(token string) (model.SshUser, error) {
+	var user model.SshUser
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return user, errors.New("invalid reset token")
+	}
+	uid, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return user, errors.New("invalid reset token")
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return user, errors.New("invalid reset token")
+	}
+	user, err = user.FindByID(uint(uid))
+	if err != nil {
+		return user, errors.New("invalid reset token")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(resetTokenSign(user, expiry))) {
+		return user, errors.New("invalid reset token")
+	}
+	if time.Now().Unix() > expiry {
+		return user, errors.New("reset token expired")
+	}
+	if user.IsEnable == "N" || user.ExpiryAt.ToTime().Before(time.Now()) {
+		return user, errors.New("账号已禁用或已过期")
+	}
+	return user, nil
+}
+
+// sendResetToken 通过邮件渠道发送重置令牌
+func sendResetToken(user model.SshUser, clientIp string) {
+	notifier, err := newNotifier(model.NotifyChannel{Type: "email", Target: user.Email})
+	if err == nil {
+		err = notifier.Send(
+			fmt.Sprintf("[%s] 密码重置", config.DefaultConfig.AppName),
+			fmt.Sprintf("用户 %s 申请重置密码,申请来源IP: %s\n\n重置令牌(%s 内有效,使用一次后失效):\n%s\n\n如果不是本人操作,请忽略此邮件。",
+				user.Name, clientIp, config.DefaultConfig.ResetExpire, newResetToken(user)))
+	}
+	if err != nil {
+		slog.Error("send reset token error:", "uid", user.ID, "err_msg", err.Error())
+		return
+	}
+	slog.Info("reset token sent", "uid", user.ID, "client_ip", clientIp)
+}
+
+// PasswordResetRequest POST 申请重置密码,无论账号是否存在都返回相同结果
+func PasswordResetRequest(c *gin.Context) {
+	type Param struct {
+		Name string `form:"name" binding:"required,min=1,max=64" json:"name"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	var user model.SshUser
+	u, err := user.FindByName(param.Name)
+	if err == nil && u.ID != 0 && u.Email != "" && u.IsEnable == "Y" && u.ExpiryAt.ToTime().After(time.Now()) {
+		go sendResetToken(u, c.ClientIP())
+	} else {
+		slog.Info("password reset ignored", "name", param.Name, "client_ip", c.ClientIP())
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "如果账号存在并配置了邮箱,重置令牌已发送"})
+}
+
+// PasswordResetConfirm POST 使用重置令牌设置新密码
+func PasswordResetConfirm(c *gin.Context) {
+	type Param struct {
+		Token string `form:"token" binding:"required,min=1,max=256" json:"token"`
+		Pwd   string `form:"pwd" binding:"required,min=1,max=64" json:"pwd"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	user, err := checkResetToken(strings.TrimSpace(param.Token))
+	if err != nil {
+		slog.Warn("password reset rejected", "client_ip", c.ClientIP(), "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": "重置令牌无效或已过期"})
+		return
+	}
+
+	user.Pwd = param.Pwd
+	if err := user.UpdatePassword(user.ID, &user); err != nil {
+		slog.Error("UpdatePassword错误", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": "更新用户密码错误"})
+		return
+	}
+	slog.Info("password reset", "uid", user.ID, "client_ip", c.ClientIP())
+	c.JSON(200, gin.H{"code": 0, "msg": "更新密码成功"})
+}
@@ -0,0 +1,620 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 流水线步骤类型
+const (
+	PipelineStepExec    = "exec"
+	PipelineStepUpload  = "upload"
+	PipelineStepWait    = "wait"
+	PipelineStepConfirm = "confirm"
+)
+
+// 流水线步骤和主机的执行状态
+const (
+	PipelinePending = "pending"
+	PipelineRunning = "running"
+	PipelineWaiting = "waiting"
+	PipelineOk      = "ok"
+	PipelineFailed  = "failed"
+	PipelineSkipped = "skipped"
+)
+
+const (
+	pipelineMaxSteps       = 50
+	pipelineMaxOutput      = 64 * 1024
+	pipelineWaitTimeout    = 300
+	pipelineWaitInterval   = 5
+	pipelineConfirmTimeout = 3600
+)
+
+// PipelineStep 流水线的一个步骤,命令、路径和文件内容支持 {{host}} 等模板变量
+type PipelineStep struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Cmd         string `json:"cmd"`          // exec、wait 执行的命令
+	Path        string `json:"path"`         // upload 的目标路径
+	Content     string `json:"content"`      // upload 的文件内容
+	Encoding    string `json:"encoding"`     // 文件内容编码,为空或 base64
+	Mode        string `json:"mode"`         // upload 的文件权限,八进制,如 0644
+	Pattern     string `json:"pattern"`      // wait 等待命令输出匹配的正则表达式
+	Interval    uint   `json:"interval"`     // wait 重复执行命令的间隔(秒)
+	Timeout     uint   `json:"timeout"`      // exec、wait、confirm 的超时时间(秒)
+	Prompt      string `json:"prompt"`       // confirm 的提示内容
+	IgnoreError bool   `json:"ignore_error"` // 失败后继续执行后面的步骤
+}
+
+// parsePipelineSteps 解析并校验流水线步骤
+func parsePipelineSteps(data string) ([]PipelineStep, error) {
+	var steps []PipelineStep
+	if err := json.Unmarshal([]byte(data), &steps); err != nil {
+		return nil, fmt.Errorf("步骤格式错误: %v", err)
+	}
+	if len(steps) == 0 || len(steps) > pipelineMaxSteps {
+		return nil, fmt.Errorf("步骤数量需要在 1 到 %d 之间", pipelineMaxSteps)
+	}
+	for i := range steps {
+		step := &steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if step.Timeout > 86400 {
+			return nil, fmt.Errorf("%s: 超时时间不能超过 86400 秒", step.Name)
+		}
+		switch step.Kind {
+		case PipelineStepExec:
+			if step.Cmd == "" {
+				return nil, fmt.Errorf("%s: 缺少命令", step.Name)
+			}
+		case PipelineStepUpload:
+			if step.Path == "" || strings.HasSuffix(step.Path, "/") {
+				return nil, fmt.Errorf("%s: 缺少目标文件路径", step.Name)
+			}
+			if step.Encoding != "" && step.Encoding != "base64" {
+				return nil, fmt.Errorf("%s: 不支持的编码 %s", step.Name, step.Encoding)
+			}
+			if step.Encoding == "base64" {
+				if _, err := base64.StdEncoding.DecodeString(step.Content); err != nil {
+					return nil, fmt.Errorf("%s: 文件内容不是有效的 base64", step.Name)
+				}
+			}
+			if _, err := parseFileMode(step.Mode); err != nil {
+				return nil, fmt.Errorf("%s: %v", step.Name, err)
+			}
+		case PipelineStepWait:
+			if step.Cmd == "" || step.Pattern == "" {
+				return nil, fmt.Errorf("%s: 缺少命令或匹配规则", step.Name)
+			}
+			if _, err := regexp.Compile(step.Pattern); err != nil {
+				return nil, fmt.Errorf("%s: 匹配规则错误: %v", step.Name, err)
+			}
+		case PipelineStepConfirm:
+		default:
+			return nil, fmt.Errorf("%s: 不支持的步骤类型 %s", step.Name, step.Kind)
+		}
+	}
+	return steps, nil
+}
+
+// pipelinePromptVars 全部步骤中需要在执行时输入的变量
+func pipelinePromptVars(steps []PipelineStep) []string {
+	var tpl []string
+	for _, step := range steps {
+		tpl = append(tpl, step.Cmd, step.Path)
+		if step.Encoding == "" {
+			tpl = append(tpl, step.Content)
+		}
+	}
+	return snippetPromptVars(strings.Join(tpl, "\n"))
+}
+
+// PipelineStepLog 单台主机上一个步骤的执行记录
+type PipelineStepLog struct {
+	Name    string     `json:"name"`
+	Kind    string     `json:"kind"`
+	Status  string     `json:"status"`
+	Output  string     `json:"output"`
+	Error   string     `json:"error"`
+	StartAt *time.Time `json:"start_at"`
+	EndAt   *time.Time `json:"end_at"`
+}
+
+// PipelineHostLog 单台主机的执行记录
+type PipelineHostLog struct {
+	ConfId  uint              `json:"conf_id"`
+	Name    string            `json:"name"`
+	Address string            `json:"address"`
+	Status  string            `json:"status"`
+	Error   string            `json:"error"`
+	Steps   []PipelineStepLog `json:"steps"`
+}
+
+// pipelineGate 确认步骤,所有主机执行到该步骤时等待同一个确认结果
+type pipelineGate struct {
+	done     chan struct{}
+	approved bool
+	by       string
+}
+
+// pipelineRun 流水线的一次执行,与后台任务使用相同的ID,执行记录随任务一起清理
+type pipelineRun struct {
+	mu         sync.Mutex
+	job        *Job
+	pipelineId uint
+	name       string
+	steps      []PipelineStep
+	hosts      []*PipelineHostLog
+	gates      map[int]*pipelineGate
+}
+
+var pipelineRuns sync.Map
+
+// view 执行记录的快照
+func (r *pipelineRun) view() gin.H {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hosts := make([]PipelineHostLog, len(r.hosts))
+	for i, host := range r.hosts {
+		hosts[i] = *host
+		hosts[i].Steps = append([]PipelineStepLog{}, host.Steps...)
+	}
+	confirms := []gin.H{}
+	for idx, gate := range r.gates {
+		status := PipelineWaiting
+		select {
+		case <-gate.done:
+			status = map[bool]string{true: "approved", false: "rejected"}[gate.approved]
+		default:
+		}
+		confirms = append(confirms, gin.H{"step": idx, "name": r.steps[idx].Name, "prompt": r.steps[idx].Prompt, "status": status, "by": gate.by})
+	}
+	sort.Slice(confirms, func(i, j int) bool { return confirms[i]["step"].(int) < confirms[j]["step"].(int) })
+	return gin.H{"pipeline_id": r.pipelineId, "name": r.name, "job": r.job.view(), "hosts": hosts, "confirms": confirms}
+}
+
+// updateStep 修改主机上一个步骤的执行记录
+func (r *pipelineRun) updateStep(host *PipelineHostLog, idx int, fn func(log *PipelineStepLog)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&host.Steps[idx])
+}
+
+// gate 确认步骤的等待状态,第一台主机执行到该步骤时创建
+func (r *pipelineRun) gate(idx int) *pipelineGate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gates[idx] == nil {
+		r.gates[idx] = &pipelineGate{done: make(chan struct{})}
+	}
+	return r.gates[idx]
+}
+
+// confirm 确认或拒绝等待中的步骤
+func (r *pipelineRun) confirm(idx int, approved bool, by string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	gate := r.gates[idx]
+	if gate == nil {
+		return errors.New("步骤未在等待确认")
+	}
+	select {
+	case <-gate.done:
+		return errors.New("步骤已确认")
+	default:
+	}
+	gate.approved, gate.by = approved, by
+	close(gate.done)
+	return nil
+}
+
+// pipelineTruncate 只保留输出的最后一部分
+func pipelineTruncate(out string) string {
+	if len(out) <= pipelineMaxOutput {
+		return out
+	}
+	return "...\n" + out[len(out)-pipelineMaxOutput:]
+}
+
+// runPipelineHost 在一台主机上按顺序执行全部步骤,步骤失败后跳过后面的步骤
+func runPipelineHost(run *pipelineRun, host *PipelineHostLog, conf model.SshConf, clientIp string, confirm []string, vars map[string]string) {
+	setHost := func(status string, err error) {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		host.Status = status
+		if err != nil {
+			host.Error = err.Error()
+		}
+	}
+
+	setHost(PipelineRunning, nil)
+	conn, err := openBatchConn(conf, "pipeline-"+run.job.Id, clientIp, confirm)
+	if err != nil {
+		// 连接失败时跳过全部步骤,错误记录在第一个步骤的进度中
+		setHost(PipelineFailed, err)
+		for i, step := range run.steps {
+			run.updateStep(host, i, func(log *PipelineStepLog) { log.Status = PipelineSkipped })
+			if i == 0 {
+				run.job.Progress(step.Name, 0, fmt.Errorf("%s: %w", conf.Address, err))
+				continue
+			}
+			run.job.Progress(step.Name, 0, nil)
+		}
+		return
+	}
+	defer closeBatchConn(conn)
+
+	failed := false
+	for i, step := range run.steps {
+		start := time.Now()
+		run.updateStep(host, i, func(log *PipelineStepLog) {
+			log.Status, log.StartAt = PipelineRunning, &start
+		})
+		out, err := runPipelineStep(run, host, i, conn, step, vars)
+		end := time.Now()
+		run.updateStep(host, i, func(log *PipelineStepLog) {
+			log.Status, log.Output, log.EndAt = PipelineOk, pipelineTruncate(out), &end
+			if err != nil {
+				log.Status, log.Error = PipelineFailed, err.Error()
+			}
+		})
+		if err != nil {
+			err = fmt.Errorf("%s: %w", conf.Address, err)
+		}
+		run.job.Progress(step.Name, 0, err)
+		if err != nil && !step.IgnoreError {
+			failed = true
+			for j := i + 1; j < len(run.steps); j++ {
+				run.updateStep(host, j, func(log *PipelineStepLog) { log.Status = PipelineSkipped })
+				run.job.Progress(run.steps[j].Name, 0, nil)
+			}
+			break
+		}
+	}
+	if failed {
+		setHost(PipelineFailed, nil)
+		return
+	}
+	setHost(PipelineOk, nil)
+}
+
+// runPipelineStep 执行一个步骤,返回输出内容
+func runPipelineStep(run *pipelineRun, host *PipelineHostLog, idx int, conn *SshConn, step PipelineStep, vars map[string]string) (string, error) {
+	switch step.Kind {
+	case PipelineStepExec:
+		cmd, err := renderSnippet(step.Cmd, conn, vars)
+		if err != nil {
+			return "", err
+		}
+		return execOnConn(conn, cmd, step.Timeout)
+	case PipelineStepUpload:
+		return pipelineUpload(conn, step, vars)
+	case PipelineStepWait:
+		return pipelineWait(conn, step, vars)
+	case PipelineStepConfirm:
+		run.updateStep(host, idx, func(log *PipelineStepLog) { log.Status = PipelineWaiting })
+		timeout := step.Timeout
+		if timeout == 0 {
+			timeout = pipelineConfirmTimeout
+		}
+		gate := run.gate(idx)
+		timer := time.NewTimer(time.Duration(timeout) * time.Second)
+		defer timer.Stop()
+		select {
+		case <-gate.done:
+		case <-timer.C:
+			return "", errors.New("等待确认超时")
+		}
+		if !gate.approved {
+			return "rejected by " + gate.by, errors.New("已拒绝")
+		}
+		return "approved by " + gate.by, nil
+	}
+	return "", fmt.Errorf("不支持的步骤类型 %s", step.Kind)
+}
+
+// pipelineUpload 通过 sftp 写入文件,与页面上传一样扫描文件内容并校验路径策略
+func pipelineUpload(conn *SshConn, step PipelineStep, vars map[string]string) (string, error) {
+	p, err := renderSnippet(step.Path, conn, vars)
+	if err != nil {
+		return "", err
+	}
+	var data []byte
+	if step.Encoding == "base64" {
+		data, _ = base64.StdEncoding.DecodeString(step.Content)
+	} else {
+		content, err := renderSnippet(step.Content, conn, vars)
+		if err != nil {
+			return "", err
+		}
+		data = []byte(content)
+	}
+	if maxSize := config.DefaultConfig.Limits.MaxUploadSize; maxSize > 0 && int64(len(data)) > maxSize {
+		return "", errors.New("上传文件超过大小限制")
+	}
+	if err := scanUpload(conn, conn.ClientIP, path.Base(p), bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	mode, _ := parseFileMode(step.Mode)
+	size, err := batchWriteFile(conn, p, bytes.NewReader(data), mode)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d bytes", p, size), nil
+}
+
+// pipelineWait 重复执行命令,直到输出匹配规则或超时
+func pipelineWait(conn *SshConn, step PipelineStep, vars map[string]string) (string, error) {
+	cmd, err := renderSnippet(step.Cmd, conn, vars)
+	if err != nil {
+		return "", err
+	}
+	re := regexp.MustCompile(step.Pattern)
+	timeout, interval := step.Timeout, step.Interval
+	if timeout == 0 {
+		timeout = pipelineWaitTimeout
+	}
+	if interval == 0 {
+		interval = pipelineWaitInterval
+	}
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	for {
+		remaining := uint(time.Until(deadline).Seconds()) + 1
+		out, err := execOnConn(conn, cmd, remaining)
+		if re.MatchString(out) {
+			return out, nil
+		}
+		if errors.Is(err, errExecSession) {
+			return out, err
+		}
+		if time.Now().Add(time.Duration(interval) * time.Second).After(deadline) {
+			return out, fmt.Errorf("等待 %d 秒后输出仍未匹配", timeout)
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
+
+func PipelineCreate(c *gin.Context) {
+	var pipeline model.Pipeline
+	if err := c.ShouldBind(&pipeline); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if _, err := parsePipelineSteps(pipeline.Steps); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	pipeline.ID = 0
+	pipeline.Uid = c.GetUint("uid")
+	if err := pipeline.Create(&pipeline); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	PipelineFindAll(c)
+}
+
+func PipelineFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var pipeline model.Pipeline
+	data, err := pipeline.FindByID(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	steps, _ := parsePipelineSteps(data.Steps)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "vars": pipelinePromptVars(steps)})
+}
+
+func PipelineFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var pipeline model.Pipeline
+	data, total, err := pipeline.FindPage(q, c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func PipelineUpdateById(c *gin.Context) {
+	var pipeline model.Pipeline
+	if err := c.ShouldBind(&pipeline); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if _, err := parsePipelineSteps(pipeline.Steps); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if err := pipeline.UpdateById(pipeline.ID, c.GetUint("uid"), &pipeline); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	PipelineFindAll(c)
+}
+
+func PipelineDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var pipeline model.Pipeline
+	if err := pipeline.DeleteByID(uint(id), c.GetUint("uid")); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	PipelineFindAll(c)
+}
+
+// PipelineRun POST 在选中的主机上后台执行流水线,主机并发执行,每台主机上的步骤按顺序执行
+// 返回的任务ID通过 /api/pipeline/run/:id 查询每台主机每个步骤的执行记录
+func PipelineRun(c *gin.Context) {
+	type Param struct {
+		Id          uint              `form:"id" binding:"required" json:"id"`
+		ConfIds     []uint            `form:"conf_ids" binding:"required,min=1" json:"conf_ids"`
+		Vars        map[string]string `form:"vars" json:"vars"`
+		Concurrency int               `form:"concurrency" binding:"gte=0,lte=50" json:"concurrency"`
+		Confirm     []string          `form:"confirm" json:"confirm"` // 已确认的生产环境主机地址
+	}
+	var param Param
+	if err := c.ShouldBindJSON(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if len(param.ConfIds) > snippetRunMaxHosts {
+		c.JSON(200, gin.H{"code": 1, "msg": fmt.Sprintf("最多选择 %d 台主机", snippetRunMaxHosts)})
+		return
+	}
+	uid := c.GetUint("uid")
+	var pipeline model.Pipeline
+	data, err := pipeline.FindByID(param.Id, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	steps, err := parsePipelineSteps(data.Steps)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	var missing []string
+	for _, name := range pipelinePromptVars(steps) {
+		if _, ok := param.Vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(200, gin.H{"code": 3, "msg": "missing variables: " + strings.Join(missing, ","), "vars": missing})
+		return
+	}
+
+	var sshConf model.SshConf
+	confs := make([]model.SshConf, 0, len(param.ConfIds))
+	for _, id := range param.ConfIds {
+		conf, err := sshConf.FindByID(id, uid)
+		if err != nil {
+			c.JSON(200, gin.H{"code": 4, "msg": fmt.Sprintf("主机 %d 不存在", id)})
+			return
+		}
+		confs = append(confs, conf)
+	}
+
+	run := &pipelineRun{pipelineId: data.ID, name: data.Name, steps: steps, gates: map[int]*pipelineGate{}}
+	for _, conf := range confs {
+		host := &PipelineHostLog{ConfId: conf.ID, Name: conf.Name, Address: conf.Address, Status: PipelinePending}
+		for _, step := range steps {
+			host.Steps = append(host.Steps, PipelineStepLog{Name: step.Name, Kind: step.Kind, Status: PipelinePending})
+		}
+		run.hosts = append(run.hosts, host)
+	}
+	concurrency := param.Concurrency
+	if concurrency == 0 {
+		concurrency = snippetRunConcurrency
+	}
+	clientIp := c.RemoteIP()
+
+	// 任务在 startJob 返回前可能已经开始执行,先保存执行记录再启动
+	ready := make(chan struct{})
+	run.job = startJob("pipeline", data.Name, uid, len(confs)*len(steps), func(job *Job) {
+		<-ready
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		for i, conf := range confs {
+			wg.Add(1)
+			go func(host *PipelineHostLog, conf model.SshConf) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				runPipelineHost(run, host, conf, clientIp, param.Confirm, param.Vars)
+			}(run.hosts[i], conf)
+		}
+		wg.Wait()
+	})
+	pipelineRuns.Store(run.job.Id, run)
+	close(ready)
+	slog.Info("pipeline run", "uid", uid, "pipeline_id", data.ID, "job_id", run.job.Id, "hosts", len(confs))
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": run.job.Id})
+}
+
+// findPipelineRun 查询当前用户可以查看的执行记录,管理员可以查看全部
+func findPipelineRun(c *gin.Context, id string) (*pipelineRun, error) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		return nil, errors.New("获取用户信息错误")
+	}
+	value, ok := pipelineRuns.Load(id)
+	if !ok {
+		return nil, errors.New("执行记录不存在")
+	}
+	run := value.(*pipelineRun)
+	if !jobVisible(run.job.view(), u) {
+		return nil, errors.New("执行记录不存在")
+	}
+	return run, nil
+}
+
+// PipelineRunFind GET 流水线的执行进度和每台主机每个步骤的输出
+func PipelineRunFind(c *gin.Context) {
+	run, err := findPipelineRun(c, c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": run.view()})
+}
+
+// PipelineConfirm POST 确认或拒绝等待中的确认步骤,拒绝后等待该步骤的主机停止执行
+func PipelineConfirm(c *gin.Context) {
+	type Param struct {
+		RunId   string `form:"run_id" binding:"required,max=64" json:"run_id"`
+		Step    int    `form:"step" binding:"gte=0" json:"step"`
+		Approve string `form:"approve" binding:"required,oneof=Y N" json:"approve"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	run, err := findPipelineRun(c, param.RunId)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	by := strconv.Itoa(int(c.GetUint("uid")))
+	var user model.SshUser
+	if u, err := user.FindByID(c.GetUint("uid")); err == nil {
+		by = u.Name
+	}
+	if err := run.confirm(param.Step, param.Approve == "Y", by); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("pipeline confirm", "job_id", param.RunId, "step", param.Step, "approve", param.Approve, "by", by)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": run.view()})
+}
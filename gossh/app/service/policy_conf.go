@@ -1,19 +1,69 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"gossh/app/model"
 	"gossh/gin"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// 检查策略中的时区配置
+func checkPolicyTimeZone(conf model.PolicyConf) error {
+	if conf.TimeZone == "" {
+		return nil
+	}
+	_, err := time.LoadLocation(conf.TimeZone)
+	return err
+}
+
+// 检查策略中允许访问的星期,以逗号分隔的 0-6,0 表示星期日
+func checkPolicyWeekdays(conf model.PolicyConf) error {
+	if conf.AccessWeekdays == "" {
+		return nil
+	}
+	for _, day := range strings.Split(conf.AccessWeekdays, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(day))
+		if err != nil || n < 0 || n > 6 {
+			return fmt.Errorf("访问星期格式错误: %s", day)
+		}
+	}
+	return nil
+}
+
 func PolicyConfCreate(c *gin.Context) {
 	var conf model.PolicyConf
 	if err := c.ShouldBind(&conf); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkPolicyTimeZone(conf); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkPolicyWeekdays(conf); err != nil {
 		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
 		return
 	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	tenantId, err := requestTenant(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	conf.TenantId = tenantId
 
-	err := conf.Create(&conf)
+	if submitChange(c, "policy_conf", "create", 0, conf) {
+		return
+	}
+	err = conf.Create(&conf)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
 		return
@@ -27,44 +77,67 @@ func PolicyConfFindByID(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-	var conf model.PolicyConf
-	data, err := conf.FindByID(uint(id))
-	if err != nil {
-		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+	data, ok := findTenantPolicy(c, uint(id))
+	if !ok {
 		return
 	}
 	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
 }
 
 func PolicyConfFindAll(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	q, err := parsePageQuery(c)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
 
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	tenantId, err := requestTenant(c)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-
 	var conf model.PolicyConf
-	data, err := conf.FindAll(offset, limit)
+	data, total, err := conf.FindPage(q, tenantId)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
 }
 
 func PolicyConfUpdateById(c *gin.Context) {
 	var conf model.PolicyConf
 	if err := c.ShouldBind(&conf); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkPolicyTimeZone(conf); err != nil {
 		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
 		return
 	}
-	err := conf.UpdateById(conf.ID, &conf)
+	if err := checkPolicyWeekdays(conf); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	old, ok := findTenantPolicy(c, conf.ID)
+	if !ok {
+		return
+	}
+	conf.TenantId = old.TenantId
+	if submitChange(c, "policy_conf", "update", conf.ID, conf) {
+		return
+	}
+	err = conf.UpdateById(conf.ID, &conf)
+	if errors.Is(err, model.ErrVersionConflict) {
+		c.JSON(409, gin.H{"code": 3, "msg": "数据已被其他人修改,请刷新后重试"})
+		return
+	}
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
@@ -84,7 +157,19 @@ func PolicyConfDeleteById(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
 	var conf model.PolicyConf
+	if _, ok := findTenantPolicy(c, uint(id)); !ok {
+		return
+	}
+	if submitChange(c, "policy_conf", "delete", uint(id), nil) {
+		return
+	}
 	err = conf.DeleteByID(uint(id))
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+)
+
+// QuotaUsage 配额和当前用量
+type QuotaUsage struct {
+	Quota       model.Quota `json:"quota"`
+	Hosts       int64       `json:"hosts"`
+	Sessions    int         `json:"sessions"`
+	RecordCount int64       `json:"record_count"`
+	StorageUsed int64       `json:"storage_used"`
+}
+
+// userQuota 用户生效的配额,未单独设置的会话数量和存储空间使用系统运行限制
+func userQuota(uid uint) (model.Quota, error) {
+	var quota model.Quota
+	data, err := quota.Find(model.QuotaScopeUser, uid)
+	if err != nil {
+		return data, err
+	}
+	limits := config.DefaultConfig.Limits
+	if data.MaxSessions == 0 {
+		data.MaxSessions = limits.MaxUserSessions
+	}
+	if data.StorageQuota == 0 {
+		data.StorageQuota = limits.UserDiskQuota
+	}
+	return data, nil
+}
+
+// tenantQuota 租户的配额,默认租户不限制
+func tenantQuota(tenantId uint) (model.Quota, error) {
+	if tenantId == 0 {
+		return model.Quota{Scope: model.QuotaScopeTenant}, nil
+	}
+	var quota model.Quota
+	return quota.Find(model.QuotaScopeTenant, tenantId)
+}
+
+// onlineSessions 统计用户和租户的在线会话数量,exclude 为重连时使用的原会话ID
+func onlineSessions(uid, tenantId uint, exclude string) (total, user, tenant int) {
+	OnlineClients.Range(func(key, value any) bool {
+		if key == exclude {
+			return true
+		}
+		if conn, ok := value.(*SshConn); ok {
+			total++
+			if conn.Uid == uid {
+				user++
+			}
+			if conn.tenantId == tenantId {
+				tenant++
+			}
+		}
+		return true
+	})
+	return
+}
+
+// checkHostQuota 检查新增 add 个主机配置后是否超过用户和租户的配额
+func checkHostQuota(uid uint, add int) error {
+	if add <= 0 {
+		return nil
+	}
+	var user model.SshUser
+	u, err := user.FindByID(uid)
+	if err != nil {
+		return err
+	}
+	var sshConf model.SshConf
+	quota, err := userQuota(uid)
+	if err != nil {
+		return err
+	}
+	if quota.MaxHosts > 0 {
+		count, err := sshConf.CountByUid(uid)
+		if err != nil {
+			return err
+		}
+		if count+int64(add) > int64(quota.MaxHosts) {
+			return errors.New("主机数量已达到用户配额")
+		}
+	}
+	quota, err = tenantQuota(u.TenantId)
+	if err != nil {
+		return err
+	}
+	if quota.MaxHosts > 0 {
+		count, err := sshConf.CountByTenant(u.TenantId)
+		if err != nil {
+			return err
+		}
+		if count+int64(add) > int64(quota.MaxHosts) {
+			return errors.New("主机数量已达到租户配额")
+		}
+	}
+	return nil
+}
+
+// checkStorageQuota 连接前检查用户和租户的录像存储空间
+func checkStorageQuota(uid, tenantId uint) error {
+	var sessionRecord model.SessionRecord
+	// 系统运行限制中的用户配额由定时清理删除最早的录像,这里只检查单独设置的配额
+	var quota model.Quota
+	quota, err := quota.Find(model.QuotaScopeUser, uid)
+	if err != nil {
+		return err
+	}
+	if quota.StorageQuota > 0 {
+		usage, err := sessionRecord.UsageByUid(uid)
+		if err != nil {
+			return err
+		}
+		if usage.Size >= quota.StorageQuota {
+			return errors.New("录像存储空间已达到用户配额")
+		}
+	}
+	quota, err = tenantQuota(tenantId)
+	if err != nil {
+		return err
+	}
+	if quota.StorageQuota > 0 {
+		usage, err := sessionRecord.UsageByTenant(tenantId)
+		if err != nil {
+			return err
+		}
+		if usage.Size >= quota.StorageQuota {
+			return errors.New("录像存储空间已达到租户配额")
+		}
+	}
+	return nil
+}
+
+// quotaUsage 查询配额对应的当前用量
+func quotaUsage(quota model.Quota) (QuotaUsage, error) {
+	var sshConf model.SshConf
+	var sessionRecord model.SessionRecord
+	usage := QuotaUsage{Quota: quota}
+	var err error
+	var record model.RecordUsage
+	if quota.Scope == model.QuotaScopeUser {
+		if usage.Hosts, err = sshConf.CountByUid(quota.TargetId); err != nil {
+			return usage, err
+		}
+		record, err = sessionRecord.UsageByUid(quota.TargetId)
+		_, usage.Sessions, _ = onlineSessions(quota.TargetId, 0, "")
+	} else {
+		if usage.Hosts, err = sshConf.CountByTenant(quota.TargetId); err != nil {
+			return usage, err
+		}
+		record, err = sessionRecord.UsageByTenant(quota.TargetId)
+		_, _, usage.Sessions = onlineSessions(0, quota.TargetId, "")
+	}
+	usage.RecordCount, usage.StorageUsed = record.Count, record.Size
+	return usage, err
+}
+
+// quotaTarget 校验当前用户可以查看或修改的配额
+// 用户配额由同一租户的管理员管理,租户配额只有 Root 用户可以修改,租户管理员可以查看本租户的配额
+func quotaTarget(c *gin.Context, scope string, targetId uint, write bool) bool {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return false
+	}
+	if scope == model.QuotaScopeTenant {
+		if u.IsRoot != "Y" && (write || targetId != u.TenantId) {
+			c.JSON(200, gin.H{"code": 2, "msg": "非超级管理员拒绝操作"})
+			return false
+		}
+		if targetId == 0 {
+			c.JSON(200, gin.H{"code": 1, "msg": "租户不存在"})
+			return false
+		}
+		var tenant model.Tenant
+		if _, err := tenant.FindByID(targetId); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": "租户不存在"})
+			return false
+		}
+		return true
+	}
+	target, err := user.FindByID(targetId)
+	if err != nil || (u.IsRoot != "Y" && target.TenantId != u.TenantId) {
+		c.JSON(200, gin.H{"code": 3, "msg": "获取用户信息错误"})
+		return false
+	}
+	return true
+}
+
+// QuotaGet GET 查询租户或用户的配额和用量
+func QuotaGet(c *gin.Context) {
+	type Param struct {
+		Scope    string `form:"scope" binding:"required,oneof=tenant user"`
+		TargetId uint   `form:"target_id"`
+	}
+	var param Param
+	if err := c.ShouldBindQuery(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if !quotaTarget(c, param.Scope, param.TargetId, false) {
+		return
+	}
+	renderQuota(c, param.Scope, param.TargetId)
+}
+
+// renderQuota 返回配额和用量
+func renderQuota(c *gin.Context, scope string, targetId uint) {
+	var quota model.Quota
+	data, err := quota.Find(scope, targetId)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	usage, err := quotaUsage(data)
+	if err != nil {
+		slog.Error("quotaUsage error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": usage})
+}
+
+// QuotaSet PUT 设置租户或用户的配额,全部为 0 时表示不限制
+func QuotaSet(c *gin.Context) {
+	var quota model.Quota
+	if err := c.ShouldBind(&quota); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if !quotaTarget(c, quota.Scope, quota.TargetId, true) {
+		return
+	}
+	var err error
+	if quota.MaxHosts == 0 && quota.MaxSessions == 0 && quota.StorageQuota == 0 {
+		err = quota.Delete(quota.Scope, quota.TargetId)
+	} else {
+		err = quota.Save(&quota)
+	}
+	if err != nil {
+		slog.Error("Quota.Save error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	slog.Info("quota updated", "scope", quota.Scope, "target_id", quota.TargetId, "max_hosts", quota.MaxHosts,
+		"max_sessions", quota.MaxSessions, "storage_quota", quota.StorageQuota, "uid", c.GetUint("uid"))
+	renderQuota(c, quota.Scope, quota.TargetId)
+}
+
+// QuotaUsageGet GET 当前用户和所属租户生效的配额与用量
+func QuotaUsageGet(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": "获取用户信息错误"})
+		return
+	}
+	quota, err := userQuota(u.ID)
+	if err == nil {
+		quota.Scope, quota.TargetId = model.QuotaScopeUser, u.ID
+	}
+	var self QuotaUsage
+	if err == nil {
+		self, err = quotaUsage(quota)
+	}
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	data := gin.H{"user": self}
+	if u.TenantId != 0 {
+		quota, err = tenantQuota(u.TenantId)
+		var tenant QuotaUsage
+		if err == nil {
+			tenant, err = quotaUsage(quota)
+		}
+		if err != nil {
+			c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+			return
+		}
+		data["tenant"] = tenant
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+// QuotaReport GET 全部租户的配额和用量,只有 Root 用户可以查看
+func QuotaReport(c *gin.Context) {
+	if !isRootUser(c) {
+		return
+	}
+	var tenant model.Tenant
+	tenants, err := tenant.FindAll()
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	list := make([]gin.H, 0, len(tenants))
+	for _, item := range tenants {
+		quota, err := tenantQuota(item.ID)
+		var usage QuotaUsage
+		if err == nil {
+			usage, err = quotaUsage(quota)
+		}
+		if err != nil {
+			c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+			return
+		}
+		list = append(list, gin.H{"tenant_id": item.ID, "name": item.Name, "usage": usage})
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list})
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"io"
+	"os"
+	"time"
+)
+
+// 加密录像的每一行为 base64(nonce + 密文) + 空格 + hex(HMAC),第一行为 asciicast 头
+// HMAC 为链式计算: mac_i = HMAC(macKey, mac_{i-1} + 本行密文),删除、插入或修改任意一行都会导致校验失败
+// 最后一行的 HMAC 和行数在录像结束时保存到数据库,用于发现末尾被截断
+
+// 录像校验结果
+const (
+	recordVerifyOk         = "ok"         // 校验通过
+	recordVerifyTampered   = "tampered"   // 录像被修改
+	recordVerifyIncomplete = "incomplete" // 录像未正常结束,没有最终校验值
+	recordVerifyUnsigned   = "unsigned"   // 未加密的录像,无法校验
+)
+
+// 数据密钥加密时的附加数据
+const recordKeyAd = "gossh-record-key"
+
+// RecordVerify 录像校验结果,Line 为第一个校验失败的行,从 1 开始
+type RecordVerify struct {
+	Status string `json:"status"`
+	Events int64  `json:"events"`
+	Line   int64  `json:"line"`
+	Msg    string `json:"msg"`
+}
+
+// recordMasterKey 解析录像主密钥,返回派生的 32 字节密钥和密钥标识
+func recordMasterKey() ([]byte, string, error) {
+	conf := config.DefaultConfig.Recording
+	if conf.MasterKey == "" {
+		return nil, "", errors.New("未配置录像主密钥")
+	}
+	secret, err := resolveSecret(conf.MasterKey)
+	if err != nil {
+		return nil, "", err
+	}
+	key := sha256.Sum256([]byte(secret))
+	id := sha256.Sum256(key[:])
+	return key[:], hex.EncodeToString(id[:8]), nil
+}
+
+// recordCipher 加密录像的读写,按行加密并计算链式 HMAC
+type recordCipher struct {
+	gcm    cipher.AEAD
+	macKey []byte
+	mac    []byte
+	events int64
+}
+
+func newRecordCipher(dataKey []byte) (*recordCipher, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, dataKey)
+	h.Write([]byte("gossh-record-mac"))
+	return &recordCipher{gcm: gcm, macKey: h.Sum(nil)}, nil
+}
+
+// newRecordKey 生成新录像的数据密钥,返回由主密钥加密后的数据密钥和主密钥标识
+func newRecordKey() (*recordCipher, string, string, error) {
+	master, keyId, err := recordMasterKey()
+	if err != nil {
+		return nil, "", "", err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, "", "", err
+	}
+	wrapped, err := utils.SealAesGcm(master, dataKey, []byte(recordKeyAd))
+	if err != nil {
+		return nil, "", "", err
+	}
+	rc, err := newRecordCipher(dataKey)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return rc, base64.StdEncoding.EncodeToString(wrapped), keyId, nil
+}
+
+// openRecordKey 使用主密钥解密录像的数据密钥
+func openRecordKey(record model.SessionRecord) (*recordCipher, error) {
+	master, keyId, err := recordMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	if record.KeyId != keyId {
+		return nil, errors.New("录像主密钥与加密时不一致")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(record.DataKey)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := utils.OpenAesGcm(master, wrapped, []byte(recordKeyAd))
+	if err != nil {
+		return nil, err
+	}
+	return newRecordCipher(dataKey)
+}
+
+func (rc *recordCipher) chain(sealed []byte) []byte {
+	h := hmac.New(sha256.New, rc.macKey)
+	h.Write(rc.mac)
+	h.Write(sealed)
+	return h.Sum(nil)
+}
+
+// seal 加密一行录像,返回包含换行符的密文行
+func (rc *recordCipher) seal(line []byte) ([]byte, error) {
+	nonce := make([]byte, rc.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := base64.StdEncoding.AppendEncode(nil, rc.gcm.Seal(nonce, nonce, line, nil))
+	rc.mac = rc.chain(sealed)
+	rc.events++
+	out := append(sealed, ' ')
+	out = hex.AppendEncode(out, rc.mac)
+	return append(out, '\n'), nil
+}
+
+// open 校验并解密一行录像
+func (rc *recordCipher) open(line []byte) ([]byte, error) {
+	idx := bytes.LastIndexByte(line, ' ')
+	if idx < 0 {
+		return nil, errors.New("格式错误")
+	}
+	sealed := line[:idx]
+	mac, err := hex.DecodeString(string(line[idx+1:]))
+	if err != nil || !hmac.Equal(mac, rc.chain(sealed)) {
+		return nil, errors.New("校验值不匹配")
+	}
+	data, err := base64.StdEncoding.DecodeString(string(sealed))
+	if err != nil || len(data) < rc.gcm.NonceSize() {
+		return nil, errors.New("格式错误")
+	}
+	plain, err := rc.gcm.Open(nil, data[:rc.gcm.NonceSize()], data[rc.gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("解密失败")
+	}
+	rc.mac = mac
+	rc.events++
+	return plain, nil
+}
+
+func (rc *recordCipher) digest() string {
+	return hex.EncodeToString(rc.mac)
+}
+
+// decryptRecord 解密并校验录像,返回校验通过部分的 asciicast 内容
+func decryptRecord(record model.SessionRecord, reader io.Reader) ([]byte, RecordVerify, error) {
+	rc, err := openRecordKey(record)
+	if err != nil {
+		return nil, RecordVerify{}, err
+	}
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		plain, err := rc.open(scanner.Bytes())
+		if err != nil {
+			return out.Bytes(), RecordVerify{
+				Status: recordVerifyTampered,
+				Events: rc.events,
+				Line:   rc.events + 1,
+				Msg:    fmt.Sprintf("第 %d 行%s", rc.events+1, err.Error()),
+			}, nil
+		}
+		out.Write(plain)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, RecordVerify{}, err
+	}
+
+	verify := RecordVerify{Status: recordVerifyOk, Events: rc.events}
+	switch {
+	case record.Digest == "":
+		verify.Status = recordVerifyIncomplete
+		if time.Time(record.EndAt).IsZero() {
+			verify.Msg = "录像进行中"
+		} else {
+			verify.Msg = "录像未正常结束"
+		}
+	case rc.events != record.Events || rc.digest() != record.Digest:
+		verify.Status = recordVerifyTampered
+		verify.Line = rc.events + 1
+		verify.Msg = fmt.Sprintf("录像应有 %d 行,实际 %d 行", record.Events, rc.events)
+	}
+	return out.Bytes(), verify, nil
+}
+
+// readRecord 读取录像,加密录像解密并校验
+func readRecord(record model.SessionRecord) ([]byte, RecordVerify, error) {
+	reader, err := artifactStorage(record.Storage).Open(record.FilePath)
+	if err != nil {
+		return nil, RecordVerify{}, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if record.Encrypted != "Y" {
+		data, err := io.ReadAll(reader)
+		return data, RecordVerify{Status: recordVerifyUnsigned}, err
+	}
+	return decryptRecord(record, reader)
+}
+
+// redactEncryptedRecord 解密录像后脱敏,再使用原数据密钥重新加密,返回脱敏的行数和新的校验值
+func redactEncryptedRecord(record model.SessionRecord, localPath string, startSec, endSec float64) (int, *recordCipher, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return 0, nil, err
+	}
+	plain, verify, err := decryptRecord(record, file)
+	_ = file.Close()
+	if err != nil {
+		return 0, nil, err
+	}
+	if verify.Status == recordVerifyTampered {
+		return 0, nil, errors.New("录像校验失败: " + verify.Msg)
+	}
+
+	plainPath := localPath + ".plain"
+	if err := os.WriteFile(plainPath, plain, os.FileMode(0600)); err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		_ = os.Remove(plainPath)
+	}()
+	count, err := redactRecordFile(plainPath, startSec, endSec)
+	if err != nil {
+		return 0, nil, err
+	}
+	plain, err = os.ReadFile(plainPath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rc, err := openRecordKey(record)
+	if err != nil {
+		return 0, nil, err
+	}
+	var out bytes.Buffer
+	for _, line := range bytes.Split(bytes.TrimSuffix(plain, []byte("\n")), []byte("\n")) {
+		sealed, err := rc.seal(line)
+		if err != nil {
+			return 0, nil, err
+		}
+		out.Write(sealed)
+	}
+	tmpPath := localPath + ".tmp"
+	if err := os.WriteFile(tmpPath, out.Bytes(), os.FileMode(0600)); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, nil, err
+	}
+	return count, rc, os.Rename(tmpPath, localPath)
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"time"
+)
+
+// logAccess 异步记录主机连接和文件传输,用于访问报表
+func logAccess(conn *SshConn, kind, p string, size int64) {
+	if !config.DefaultConfig.IsInit {
+		return
+	}
+	log := model.AccessLog{
+		Kind:      kind,
+		Uid:       conn.Uid,
+		TenantId:  conn.tenantId,
+		SessionId: conn.SessionId,
+		ClientIp:  conn.ClientIP,
+		Address:   conn.Address,
+		Port:      conn.Port,
+		SshUser:   conn.User,
+		Path:      utils.TruncateString(p, 1024),
+		Size:      size,
+		OccurAt:   model.DateTime(time.Now()),
+	}
+	go func() {
+		var user model.SshUser
+		if u, err := user.FindByID(log.Uid); err == nil {
+			log.UserName = u.Name
+		}
+		if err := log.Create(&log); err != nil {
+			slog.Error("AccessLog.Create error:", "err_msg", err.Error())
+		}
+	}()
+}
+
+// reportColumns PDF 报表的列宽,总宽度为 A4 横向去掉页边距
+var reportColumns = []utils.PdfColumn{
+	{Name: "类型", Width: 70},
+	{Name: "时间", Width: 100},
+	{Name: "用户", Width: 90},
+	{Name: "客户端IP", Width: 110},
+	{Name: "目标主机", Width: 170},
+	{Name: "详情", Width: 230},
+}
+
+// reportSummary 报表的统计信息,按类型统计行数
+func reportSummary(from, to time.Time, rows []model.ReportRow) []string {
+	count := map[string]int{}
+	for _, row := range rows {
+		count[row.Section]++
+	}
+	return []string{
+		fmt.Sprintf("时间范围: %s - %s", from.Format(model.TimeFormat), to.Format(model.TimeFormat)),
+		fmt.Sprintf("连接: %d  上传: %d  下载: %d  登录失败: %d",
+			count[model.AccessConnect], count[model.AccessUpload], count[model.AccessDownload], count["login_failed"]),
+	}
+}
+
+// renderReport 生成 csv 或 pdf 格式的报表,返回文件名和内容
+func renderReport(from, to time.Time, rows []model.ReportRow, format string) (string, []byte, error) {
+	name := fmt.Sprintf("access_report_%s_%s.%s", from.Format("20060102"), to.Format("20060102"), format)
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		// 写入 BOM,Excel 打开时正确识别 UTF-8
+		buf.WriteString("\xEF\xBB\xBF")
+		w := csv.NewWriter(&buf)
+		_ = w.Write(model.ReportHeader)
+		for _, row := range rows {
+			_ = w.Write(row.Values())
+		}
+		w.Flush()
+		return name, buf.Bytes(), w.Error()
+	case "pdf":
+		table := utils.PdfTable{
+			Title:   config.DefaultConfig.AppName + " 访问报表",
+			Summary: reportSummary(from, to, rows),
+			Columns: reportColumns,
+		}
+		for _, row := range rows {
+			table.Rows = append(table.Rows, row.Values())
+		}
+		return name, table.Render(), nil
+	}
+	return "", nil, fmt.Errorf("unsupported report format: %s", format)
+}
+
+// sendReport 生成所有租户的报表并发送到订阅了 report 事件的渠道
+func sendReport(now time.Time) error {
+	conf := config.DefaultConfig.Report
+	if conf.Period <= 0 {
+		return errors.New("报表时长未配置")
+	}
+	format := conf.Format
+	if format == "" {
+		format = "pdf"
+	}
+	from := now.Add(-conf.Period)
+	rows, err := model.AccessReport(from, now, 0, true)
+	if err != nil {
+		return err
+	}
+	name, data, err := renderReport(from, now, rows, format)
+	if err != nil {
+		return err
+	}
+
+	var notifyChannel model.NotifyChannel
+	list, err := notifyChannel.FindByEvent(EventReport)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("[%s] 访问报表", config.DefaultConfig.AppName)
+	summary := reportSummary(from, now, rows)
+	content := summary[0] + "\n" + summary[1]
+	var errs []error
+	for _, channel := range list {
+		notifier, err := newNotifier(channel)
+		if err == nil {
+			if fn, ok := notifier.(utils.FileNotifier); ok {
+				err = fn.SendFile(title, content, name, data)
+			} else {
+				err = notifier.Send(title, content)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name, err))
+		}
+	}
+	slog.Info("access report sent", "rows", len(rows), "channels", len(list), "format", format)
+	return errors.Join(errs...)
+}
+
+func reportLoop() {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(next))
+		conf := config.DefaultConfig.Report
+		if !backgroundEnabled() || conf.Cron == "" {
+			continue
+		}
+		schedule, err := utils.ParseCron(conf.Cron)
+		if err != nil {
+			slog.Error("report cron error:", "err_msg", err.Error())
+			continue
+		}
+		if schedule.Match(next) {
+			if err := sendReport(next); err != nil {
+				slog.Error("sendReport error:", "err_msg", err.Error())
+			}
+		}
+	}
+}
+
+// ReportAccess GET 导出访问报表,包括主机连接、文件传输和登录失败记录
+// from 和 to 为时间范围,默认最近7天,format 为 csv 或 pdf
+// 平台管理员导出所有租户的记录,租户管理员只能导出本租户的记录
+func ReportAccess(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	if s := c.Query("from"); s != "" {
+		t, err := model.NewDateTime(s)
+		if err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+			return
+		}
+		from = time.Time(t)
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := model.NewDateTime(s)
+		if err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+			return
+		}
+		to = time.Time(t)
+	}
+	if !from.Before(to) {
+		c.JSON(200, gin.H{"code": 1, "msg": "开始时间必须早于结束时间"})
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "pdf" {
+		c.JSON(200, gin.H{"code": 1, "msg": "format 只能为 csv 或 pdf"})
+		return
+	}
+
+	all := isPlatformAdmin(u) && c.Query("tenant_id") == ""
+	rows, err := model.AccessReport(from, to, tenantScope(c, u), all)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	name, data, err := renderReport(from, to, rows, format)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	contentType := "text/csv; charset=utf-8"
+	if format == "pdf" {
+		contentType = "application/pdf"
+	}
+	slog.Info("access report exported", "user", u.Name, "from", from.Format(model.TimeFormat), "to", to.Format(model.TimeFormat), "format", format, "rows", len(rows))
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.Data(200, contentType, data)
+}
+
+// ReportSend POST 立即生成并发送访问报表
+func ReportSend(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if err := sendReport(time.Now()); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
+
+// GetReportConf GET 报表发送配置和下次发送时间
+func GetReportConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	conf := config.DefaultConfig.Report
+	nextRun := ""
+	if schedule, err := utils.ParseCron(conf.Cron); err == nil {
+		if next := schedule.Next(time.Now()); !next.IsZero() {
+			nextRun = next.Format(model.TimeFormat)
+		}
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": conf, "next_run": nextRun})
+}
+
+// SetReportConf PUT 修改报表发送配置,立即生效并写入配置文件
+func SetReportConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var report config.Report
+	if err := c.ShouldBindJSON(&report); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if report.Cron != "" {
+		if _, err := utils.ParseCron(report.Cron); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+			return
+		}
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Report = report
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("report config updated", "user", u.Name, "cron", report.Cron, "period", report.Period, "format", report.Format)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Report})
+}
+
+func init() {
+	go reportLoop()
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 每类数据最多取出的候选数量
+const searchCandidates = 50
+
+// SearchResult 快速切换使用的搜索结果
+type SearchResult struct {
+	Type   string         `json:"type"`
+	Id     uint           `json:"id"`
+	Title  string         `json:"title"`
+	Detail string         `json:"detail"`
+	Score  int            `json:"score"`
+	Time   model.DateTime `json:"time"`
+}
+
+// searchScore 标题完全匹配得分最高,其次是前缀匹配、标题包含、其他字段包含
+func searchScore(keyword, title string, fields ...string) int {
+	keyword = strings.ToLower(keyword)
+	title = strings.ToLower(title)
+	switch {
+	case title == keyword:
+		return 100
+	case strings.HasPrefix(title, keyword):
+		return 80
+	case strings.Contains(title, keyword):
+		return 60
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), keyword) {
+			return 30
+		}
+	}
+	return 10
+}
+
+// Search GET 搜索主机配置、命令收藏和审计日志,审计日志只有管理员可以搜索
+func Search(c *gin.Context) {
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" || len(keyword) > 128 {
+		c.JSON(200, gin.H{"code": 1, "msg": "搜索关键字长度为1-128"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(200, gin.H{"code": 1, "msg": "获取limit错误"})
+		return
+	}
+	uid := c.GetUint("uid")
+	var results []SearchResult
+
+	var sshConf model.SshConf
+	confs, err := sshConf.Search(uid, keyword, searchCandidates)
+	if err != nil {
+		slog.Error("SshConf.Search error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	for _, conf := range confs {
+		endpoint := fmt.Sprintf("%s@%s:%d", conf.User, conf.Address, conf.Port)
+		results = append(results, SearchResult{
+			Type:   "conn_conf",
+			Id:     conf.ID,
+			Title:  conf.Name,
+			Detail: endpoint,
+			Score:  searchScore(keyword, conf.Name, conf.Address, conf.GroupName),
+			Time:   conf.UpdatedAt,
+		})
+	}
+
+	var cmdNote model.CmdNote
+	notes, err := cmdNote.Search(uid, keyword, searchCandidates)
+	if err != nil {
+		slog.Error("CmdNote.Search error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	for _, note := range notes {
+		results = append(results, SearchResult{
+			Type:   "cmd_note",
+			Id:     note.ID,
+			Title:  note.CmdName,
+			Detail: utils.TruncateString(note.CmdData, 200),
+			Score:  searchScore(keyword, note.CmdName, note.CmdData, note.Folder),
+			Time:   note.UpdatedAt,
+		})
+	}
+
+	var user model.SshUser
+	if u, err := user.FindByID(uid); err == nil && u.IsAdmin == "Y" {
+		var loginAudit model.LoginAudit
+		audits, err := loginAudit.SearchText(tenantScope(c, u), keyword, searchCandidates)
+		if err != nil {
+			slog.Error("LoginAudit.SearchText error:", "err_msg", err.Error())
+			c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+			return
+		}
+		for _, audit := range audits {
+			results = append(results, SearchResult{
+				Type:   "login_audit",
+				Id:     audit.ID,
+				Title:  audit.Name,
+				Detail: fmt.Sprintf("%s %s %s", audit.ClientIp, audit.IsSuccess, audit.ErrMsg),
+				// 审计日志排在同等匹配的主机和命令之后
+				Score: searchScore(keyword, audit.Name, audit.ClientIp, audit.UserAgent, audit.ErrMsg) - 5,
+				Time:  audit.OccurAt,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return time.Time(results[i].Time).After(time.Time(results[j].Time))
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": results})
+}
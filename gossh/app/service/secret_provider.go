@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"path"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+var (
+	secretMu        sync.RWMutex
+	secretProviders = map[string]utils.SecretProvider{}
+)
+
+// applySecretConf 按配置启用凭据来源,未配置的来源不解析,避免与普通密码冲突
+func applySecretConf(conf config.Secret) {
+	providers := map[string]utils.SecretProvider{}
+	if conf.Env {
+		providers["env"] = utils.EnvSecret{}
+	}
+	if conf.FileDir != "" {
+		providers["file"] = utils.FileSecret{Dir: conf.FileDir}
+	}
+	if conf.Vault.Addr != "" {
+		providers["vault"] = utils.VaultSecret{
+			Addr:      conf.Vault.Addr,
+			Token:     conf.Vault.Token,
+			Namespace: conf.Vault.Namespace,
+			KvVersion: conf.Vault.KvVersion,
+		}
+	}
+	if conf.Aws.Region != "" {
+		providers["aws"] = utils.AwsSecret{
+			Region:    conf.Aws.Region,
+			AccessKey: conf.Aws.AccessKey,
+			SecretKey: conf.Aws.SecretKey,
+			Endpoint:  conf.Aws.Endpoint,
+		}
+	}
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secretProviders = providers
+}
+
+func init() {
+	applySecretConf(config.DefaultConfig.Secret)
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if !reflect.DeepEqual(old.Secret, conf.Secret) {
+			applySecretConf(conf.Secret)
+		}
+	})
+}
+
+// secretProvider 凭据引用对应的来源,不是已启用来源的引用时返回空
+func secretProvider(value string) (utils.SecretProvider, string, string) {
+	scheme, path, field, ok := utils.ParseSecretRef(value)
+	if !ok {
+		return nil, "", ""
+	}
+	secretMu.RLock()
+	defer secretMu.RUnlock()
+	return secretProviders[scheme], path, field
+}
+
+// isSecretRef 是否为已启用来源的凭据引用
+func isSecretRef(value string) bool {
+	provider, _, _ := secretProvider(value)
+	return provider != nil
+}
+
+// resolveSecret 解析凭据引用,不是引用时原样返回
+func resolveSecret(value string) (string, error) {
+	provider, path, field := secretProvider(value)
+	if provider == nil {
+		return value, nil
+	}
+	secret, err := provider.GetSecret(path, field)
+	if err != nil {
+		return "", fmt.Errorf("resolve secret %s: %w", value, err)
+	}
+	return secret, nil
+}
+
+// checkSecretRefs 用户能否使用这些凭据引用,默认租户的管理员不受限制,其他用户只能使用 UserRefs 中的引用
+// 保存主机配置和连接时都需要检查,避免用户把服务端的凭据发送到自己控制的主机
+func checkSecretRefs(uid uint, values ...string) error {
+	var refs []string
+	for _, value := range values {
+		if isSecretRef(value) {
+			refs = append(refs, value)
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	var user model.SshUser
+	if u, err := user.FindByID(uid); err == nil && isPlatformAdmin(u) {
+		return nil
+	}
+	allow := config.DefaultConfig.Secret.UserRefs
+	for _, ref := range refs {
+		if !slices.ContainsFunc(allow, func(pattern string) bool {
+			ok, _ := path.Match(pattern, ref)
+			return ok
+		}) {
+			slog.Warn("secret ref denied", "uid", uid, "ref", ref)
+			return errors.New("没有使用该凭据引用的权限")
+		}
+	}
+	return nil
+}
+
+// resolveConfSecrets 返回解析了凭据引用的配置副本,原配置中保留引用
+func resolveConfSecrets(conf *model.SshConf) (*model.SshConf, error) {
+	if !isSecretRef(conf.Pwd) && !isSecretRef(conf.CertData) && !isSecretRef(conf.CertPwd) {
+		return conf, nil
+	}
+	if err := checkSecretRefs(conf.Uid, conf.Pwd, conf.CertData, conf.CertPwd); err != nil {
+		return nil, err
+	}
+	resolved := *conf
+	var err error
+	if resolved.Pwd, err = resolveSecret(conf.Pwd); err != nil {
+		return nil, err
+	}
+	if resolved.CertData, err = resolveSecret(conf.CertData); err != nil {
+		return nil, err
+	}
+	if resolved.CertPwd, err = resolveSecret(conf.CertPwd); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// SetSecretConf PUT 修改凭据来源配置,立即生效并写入配置文件
+func SetSecretConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var secret config.Secret
+	if err := c.ShouldBindJSON(&secret); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Secret = secret
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("secret provider config updated", "user", u.Name, "env", secret.Env, "file_dir", secret.FileDir,
+		"vault", secret.Vault.Addr, "aws_region", secret.Aws.Region)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
+
+// SecretTest POST 测试凭据引用能否解析,只返回凭据长度,不返回凭据内容
+func SecretTest(c *gin.Context) {
+	type Param struct {
+		Ref string `json:"ref" binding:"required,max=1024"`
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var param Param
+	if err := c.ShouldBindJSON(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if !isSecretRef(param.Ref) {
+		c.JSON(200, gin.H{"code": 3, "msg": "不是已启用来源的凭据引用"})
+		return
+	}
+	secret, err := resolveSecret(param.Ref)
+	if err == nil && secret == "" {
+		err = errors.New("凭据为空")
+	}
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{"length": len(secret)}})
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// 密钥检测缓冲区大小
+const secretInputBufSize = 1024
+
+type secretPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// 内置的密钥特征
+var secretPatterns = []secretPattern{
+	{kind: "private_key", re: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)},
+	{kind: "aws_access_key", re: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "github_token", re: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`)},
+	{kind: "slack_token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{kind: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+}
+
+// 疑似随机令牌的长字符串
+var secretTokenRe = regexp.MustCompile(`[A-Za-z0-9+/=_-]{40,}`)
+
+// shannonEntropy 每个字符的信息熵
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// isRandomToken 同时包含字母和数字并且足够随机
+func isRandomToken(s string) bool {
+	hasLetter := strings.ContainsAny(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	hasDigit := strings.ContainsAny(s, "0123456789")
+	return hasLetter && hasDigit && shannonEntropy(s) >= 4.0
+}
+
+// detectSecret 检测数据中的密钥,返回类型和匹配内容
+func detectSecret(data []byte) (string, string) {
+	for _, p := range secretPatterns {
+		if m := p.re.Find(data); m != nil {
+			return p.kind, string(m)
+		}
+	}
+	for _, m := range secretTokenRe.FindAll(data, -1) {
+		if isRandomToken(string(m)) {
+			return "random_token", string(m)
+		}
+	}
+	return "", ""
+}
+
+// maskSecret 只保留首尾少量字符
+func maskSecret(kind, s string) string {
+	if kind == "private_key" {
+		return s
+	}
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	masked := s[:4] + strings.Repeat("*", len(s)-8) + s[len(s)-4:]
+	if len(masked) > 128 {
+		masked = masked[:124] + s[len(s)-4:]
+	}
+	return masked
+}
+
+// secretScanHook 检测用户向未信任主机粘贴私钥、令牌等敏感凭据
+type secretScanHook struct {
+	buf []byte
+}
+
+func newSecretScanHook(conn *SshConn) StreamHook {
+	conf, err := userPolicy(conn.Uid)
+	if err != nil || conf.SecretScan != "Y" {
+		return nil
+	}
+	if conn.SshConf == nil || conn.Trusted == "Y" {
+		return nil
+	}
+	return &secretScanHook{}
+}
+
+func (h *secretScanHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	h.buf = append(h.buf, data...)
+	if len(h.buf) > secretInputBufSize {
+		h.buf = h.buf[len(h.buf)-secretInputBufSize:]
+	}
+	kind, secret := detectSecret(h.buf)
+	if kind != "" {
+		h.buf = nil
+		h.report(conn, kind, secret)
+		return data, nil
+	}
+	// 回车后开始新的一行
+	if i := bytes.LastIndexAny(h.buf, "\r\n"); i >= 0 {
+		h.buf = h.buf[i+1:]
+	}
+	return data, nil
+}
+
+func (h *secretScanHook) OnOutput(conn *SshConn, data []byte) []byte {
+	return data
+}
+
+// report 提示用户并记录脱敏后的审计事件
+func (h *secretScanHook) report(conn *SshConn, kind, secret string) {
+	slog.Warn("secret detected in terminal input", "session_id", conn.SessionId, "client_ip", conn.ClientIP, "host", conn.Address, "kind", kind)
+	if conn.ws != nil {
+		conn.ws.Event(TermEventSecret, fmt.Sprintf("\r\n[SECRET] 检测到可能的敏感凭据(%s),请确认目标主机 %s 是否正确\r\n", kind, conn.Address))
+	}
+
+	event := model.SecretEvent{
+		Uid:       conn.Uid,
+		ConfId:    conn.ID,
+		SessionId: conn.SessionId,
+		Host:      conn.Address,
+		ClientIp:  conn.ClientIP,
+		Kind:      kind,
+		Masked:    maskSecret(kind, secret),
+	}
+	var user model.SshUser
+	if u, err := user.FindByID(conn.Uid); err == nil {
+		event.UserName = u.Name
+	}
+	if err := event.Create(&event); err != nil {
+		slog.Error("SecretEvent Create error:", "err_msg", err.Error())
+	}
+}
+
+func SecretEventFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 3, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var event model.SecretEvent
+	data, total, err := event.FindPage(q)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"gossh/app/config"
+	"gossh/app/utils"
 	"log/slog"
 	"sync"
 	"time"
@@ -11,11 +12,7 @@ import (
 var OnlineClients = sync.Map{}
 
 func DeleteOnlineClient(sessionId string) {
-	defer func() {
-		if err := recover(); err != nil {
-			slog.Error("DeleteOnlineClient recover error:", "err_msg", err)
-		}
-	}()
+	defer utils.RecoverPanic("DeleteOnlineClient")
 	cli, ok := OnlineClients.Load(sessionId)
 	if !ok || cli == nil {
 		slog.Info("DeleteOnlineClient Load error")
@@ -31,8 +28,14 @@ func DeleteOnlineClient(sessionId string) {
 	// 从map 中删除会话
 	defer OnlineClients.Delete(sessionId)
 
-	// 关闭 ssh 客户端
+	// 记录会话断开事件
+	defer conn.logDisconnect()
+
+	// 关闭 ssh 客户端,已连接的会话执行断开后的钩子
 	defer func() {
+		if conn.sshClient != nil {
+			runPostDisconnect(conn.SshConf)
+		}
 		err := conn.sshClient.Close()
 		if err != nil {
 			slog.Error("DeleteOnlineClient.Close sftpClient error:", "err_msg", err)
@@ -55,6 +58,26 @@ func DeleteOnlineClient(sessionId string) {
 		}
 	}()
 
+	// 关闭终端数据流钩子,如会话录像文件
+	defer closeStreamHooks(conn)
+
+	// 终端未启动时监看不在钩子列表中,单独结束监看连接
+	defer func() {
+		if conn.supervise != nil {
+			_ = conn.supervise.Close()
+		}
+	}()
+
+	// 停止后台输出合并和输出缓冲区
+	defer func() {
+		if conn.throttle != nil {
+			_ = conn.throttle.Close()
+		}
+		if conn.output != nil {
+			_ = conn.output.Close()
+		}
+	}()
+
 	// 关闭 websocket
 	defer func() {
 		err := conn.ws.Close()
@@ -65,37 +88,89 @@ func DeleteOnlineClient(sessionId string) {
 
 }
 
-// 清理不活跃的会话
-func cleanNoActiveSession() {
-	defer func() {
-		if err := recover(); err != nil {
-			slog.Error("cleanNoActiveSession error:", "err_msg", err)
-		}
-	}()
+var (
+	cleanRunCounter    = newCounter("gossh_session_clean_runs_total", "Number of session cleanup runs.")
+	cleanIdleCounter   = newCounter("gossh_session_clean_idle_total", "Sessions closed because they were idle.")
+	cleanStaleCounter  = newCounter("gossh_session_clean_stale_total", "Sessions closed because no terminal was attached.")
+	cleanWindowCounter = newCounter("gossh_session_clean_window_total", "Sessions closed because they were outside the access window.")
+	cleanLastGauge     = newGauge("gossh_session_clean_last_run_timestamp_seconds", "Unix time of the last session cleanup run.")
+)
+
+// 清理不活跃的会话,返回空闲和未接入终端被清理的会话数量
+func cleanNoActiveSession() (idle, stale int) {
+	defer utils.RecoverPanic("cleanNoActiveSession")
+	idleTimeout := config.DefaultConfig.Limits.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = time.Minute
+	}
+	staleTimeout := config.DefaultConfig.SessionClean.StaleTimeout
+	now := time.Now()
 	OnlineClients.Range(func(key, value any) bool {
 		// 对键进行类型断言
 		if sessionId, ok := key.(string); ok {
 			// 对值进行类型断言
 			if conn, ok := value.(*SshConn); ok {
-				if conn.LastActiveTime.Add(time.Minute).Before(time.Now()) {
+				if staleTimeout > 0 && conn.ws == nil && conn.StartTime.Add(staleTimeout).Before(now) {
+					slog.Info("clean stale session:", "sid", sessionId)
+					conn.setCloseReason("stale")
+					DeleteOnlineClient(sessionId)
+					stale++
+				} else if detachedAt := conn.ws.detachedTime(); !detachedAt.IsZero() {
+					// 已断开的会话按保留时长清理
+					if detachedAt.Add(config.DefaultConfig.SessionClean.DetachTimeout).Before(now) {
+						slog.Info("clean detached session:", "sid", sessionId)
+						conn.setCloseReason("detach timeout")
+						DeleteOnlineClient(sessionId)
+						idle++
+					}
+				} else if conn.LastActiveTime.Add(idleTimeout).Before(now) {
 					slog.Info("clean not active session:", "sid", sessionId)
+					conn.setCloseReason("idle timeout")
 					DeleteOnlineClient(sessionId)
+					idle++
 				}
 			}
 		}
 		return true
 	})
+	return idle, stale
+}
+
+// cleanSession 清理一轮会话,有会话被清理时输出汇总日志并更新指标
+func cleanSession() {
+	start := time.Now()
+	idle, stale := cleanNoActiveSession()
+	var window int
+	if config.DefaultConfig.IsInit {
+		window = cleanOutOfWindowSession()
+		notifyLongSession()
+	}
+	cleanRunCounter.Add(1)
+	cleanIdleCounter.Add(int64(idle))
+	cleanStaleCounter.Add(int64(stale))
+	cleanWindowCounter.Add(int64(window))
+	cleanLastGauge.Set(start.Unix())
+	if idle+stale+window > 0 {
+		slog.Info("session clean summary", "idle", idle, "stale", stale, "window", window, "elapsed", time.Since(start).String())
+	}
+}
+
+// sessionCleanInterval 会话清理间隔,未配置时使用 client_check
+func sessionCleanInterval() time.Duration {
+	if interval := config.DefaultConfig.SessionClean.Interval; interval > 0 {
+		return interval
+	}
+	if interval := config.DefaultConfig.ClientCheck; interval > 0 {
+		return interval
+	}
+	return time.Second * 15
 }
 
 func initApp() {
-	defer func() {
-		if err := recover(); err != nil {
-			slog.Error("service init error")
-		}
-	}()
+	defer utils.RecoverPanic("initApp")
 	for {
-		cleanNoActiveSession()
-		time.Sleep(config.DefaultConfig.ClientCheck)
+		cleanSession()
+		time.Sleep(sessionCleanInterval())
 	}
 }
 
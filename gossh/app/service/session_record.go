@@ -0,0 +1,532 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// RecordDir 会话录像存放目录
+var RecordDir = path.Join(config.WorkDir, "recordings")
+
+// recordHook 以 asciicast v2 格式记录终端输出
+type recordHook struct {
+	mu       sync.Mutex
+	once     sync.Once
+	recordId uint
+	start    time.Time
+	filePath string
+	file     *os.File
+	size     int64
+	line     inputLine
+	priv     []string
+	cipher   *recordCipher
+}
+
+func newRecordHook(conn *SshConn) StreamHook {
+	conf, err := userPolicy(conn.Uid)
+	if err != nil || conf.RecordSession != "Y" || !policyTargets(conf, conn) {
+		return nil
+	}
+
+	if err := os.MkdirAll(RecordDir, os.FileMode(0700)); err != nil {
+		slog.Error("create record dir error:", "err_msg", err.Error())
+		return nil
+	}
+	start := time.Now()
+	filePath := path.Join(RecordDir, fmt.Sprintf("%s_%s.cast", start.Format("20060102150405"), conn.SessionId))
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0600))
+	if err != nil {
+		slog.Error("create record file error:", "err_msg", err.Error())
+		return nil
+	}
+
+	// 加密录像使用随机的数据密钥,主密钥不可用时记录为未加密的录像
+	var rc *recordCipher
+	var dataKey, keyId string
+	if config.DefaultConfig.Recording.Encrypt {
+		if rc, dataKey, keyId, err = newRecordKey(); err != nil {
+			slog.Error("create record key error:", "err_msg", err.Error())
+		}
+	}
+	hook := &recordHook{start: start, filePath: filePath, file: file, cipher: rc}
+
+	cols, rows := conn.size.Get()
+	header, _ := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     cols,
+		"height":    rows,
+		"timestamp": start.Unix(),
+		"env":       map[string]string{"TERM": conn.PtyType, "SHELL": conn.Shell},
+	})
+	n, err := hook.writeLine(header)
+	if err != nil {
+		slog.Error("write record header error:", "err_msg", err.Error())
+		_ = file.Close()
+		return nil
+	}
+
+	var user model.SshUser
+	u, _ := user.FindByID(conn.Uid)
+	record := model.SessionRecord{
+		Uid:       conn.Uid,
+		UserName:  u.Name,
+		SessionId: conn.SessionId,
+		ClientIp:  conn.ClientIP,
+		Address:   conn.Address,
+		SshUser:   conn.User,
+		Port:      conn.Port,
+		FilePath:  filePath,
+		Redacted:  "N",
+		Encrypted: "N",
+		StartAt:   model.DateTime(start),
+	}
+	if rc != nil {
+		record.Encrypted, record.DataKey, record.KeyId = "Y", dataKey, keyId
+	}
+	if err := record.Create(&record); err != nil {
+		slog.Error("record.Create error:", "err_msg", err.Error())
+		_ = file.Close()
+		return nil
+	}
+	hook.recordId, hook.size = record.ID, int64(n)
+	return hook
+}
+
+// writeLine 写入一行录像,加密录像写入密文和校验值
+func (h *recordHook) writeLine(line []byte) (int, error) {
+	if h.cipher == nil {
+		return h.file.Write(append(line, '\n'))
+	}
+	sealed, err := h.cipher.seal(line)
+	if err != nil {
+		return 0, err
+	}
+	return h.file.Write(sealed)
+}
+
+// OnInput 解析用户输入的 sudo/su 命令,在录像中添加标记并记录提权后的用户
+func (h *recordHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	for _, line := range h.line.Write(data) {
+		if user := parseEscalation(line); user != "" {
+			h.markEscalation(conn, user)
+		}
+	}
+	return data, nil
+}
+
+func (h *recordHook) markEscalation(conn *SshConn, user string) {
+	h.mu.Lock()
+	if h.file != nil {
+		event, _ := json.Marshal([]any{time.Since(h.start).Seconds(), "m", "privilege: " + user})
+		n, err := h.writeLine(event)
+		if err != nil {
+			slog.Error("write record marker error:", "err_msg", err.Error())
+		}
+		h.size += int64(n)
+	}
+	for _, name := range h.priv {
+		if name == user {
+			h.mu.Unlock()
+			return
+		}
+	}
+	h.priv = append(h.priv, user)
+	users := strings.Join(h.priv, ",")
+	h.mu.Unlock()
+
+	slog.Info("session privilege escalation", "record_id", h.recordId, "sid", conn.SessionId, "uid", conn.Uid, "host", conn.Address, "user", user)
+	logSessionEvent(conn, model.SessionEvent{Kind: model.SessionEventPrivilege, Detail: user})
+	var record model.SessionRecord
+	if err := record.UpdatePrivUsers(h.recordId, utils.TruncateString(users, 255)); err != nil {
+		slog.Error("record.UpdatePrivUsers error:", "err_msg", err.Error())
+	}
+}
+
+func (h *recordHook) OnOutput(conn *SshConn, data []byte) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return data
+	}
+	event, _ := json.Marshal([]any{time.Since(h.start).Seconds(), "o", string(data)})
+	// 超过录像大小限制后停止记录
+	if maxSize := config.DefaultConfig.Limits.MaxRecordSize; maxSize > 0 && h.size+int64(len(event))+1 > maxSize {
+		slog.Warn("session record size limit reached", "record_id", h.recordId, "size", h.size)
+		_ = h.file.Close()
+		h.file = nil
+		return data
+	}
+	n, err := h.writeLine(event)
+	if err != nil {
+		slog.Error("write record event error:", "err_msg", err.Error())
+	}
+	h.size += int64(n)
+	return data
+}
+
+func (h *recordHook) Close() error {
+	var err error
+	h.once.Do(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.file != nil {
+			err = h.file.Close()
+			h.file = nil
+		}
+		var record model.SessionRecord
+		if e := record.Finish(h.recordId, h.size); e != nil {
+			slog.Error("record.Finish error:", "err_msg", e.Error())
+		}
+		if h.cipher != nil {
+			if e := record.UpdateDigest(h.recordId, h.cipher.digest(), h.cipher.events); e != nil {
+				slog.Error("record.UpdateDigest error:", "err_msg", e.Error())
+			}
+		}
+		go storeRecord(h.recordId, h.filePath)
+	})
+	return err
+}
+
+// storeRecord 会话结束后将录像文件转存到配置的存储
+func storeRecord(recordId uint, localPath string) {
+	kind, filePath, err := storeArtifact("recordings/"+path.Base(localPath), localPath)
+	if err != nil {
+		slog.Error("store record error:", "record_id", recordId, "err_msg", err.Error())
+		return
+	}
+	if kind == "local" {
+		return
+	}
+	var record model.SessionRecord
+	if err := record.UpdateStorage(recordId, kind, filePath); err != nil {
+		slog.Error("record.UpdateStorage error:", "err_msg", err.Error())
+	}
+}
+
+// maskTerminalData 将可见字符替换为 *,保留控制字符和转义序列
+func maskTerminalData(data string) string {
+	var sb strings.Builder
+	runes := []rune(data)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != 0x1b {
+			if unicode.IsControl(r) || unicode.IsSpace(r) {
+				sb.WriteRune(r)
+			} else {
+				sb.WriteRune('*')
+			}
+			continue
+		}
+
+		// 转义序列原样保留
+		sb.WriteRune(r)
+		if i+1 >= len(runes) {
+			break
+		}
+		i++
+		sb.WriteRune(runes[i])
+		switch runes[i] {
+		case '[': // CSI 以 0x40-0x7E 结束
+			for i+1 < len(runes) {
+				i++
+				sb.WriteRune(runes[i])
+				if runes[i] >= 0x40 && runes[i] <= 0x7e {
+					break
+				}
+			}
+		case ']': // OSC 以 BEL 或 ESC \ 结束
+			for i+1 < len(runes) {
+				i++
+				sb.WriteRune(runes[i])
+				if runes[i] == 0x07 || (runes[i] == '\\' && runes[i-1] == 0x1b) {
+					break
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// redactRecordFile 将录像文件中指定时间段的输出替换为掩码
+func redactRecordFile(filePath string, startSec, endSec float64) (int, error) {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	tmpPath := filePath + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	writer := bufio.NewWriter(dst)
+	for lineNo := 0; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if lineNo > 0 {
+			var event []any
+			if err := json.Unmarshal(line, &event); err == nil && len(event) == 3 {
+				at, _ := event[0].(float64)
+				data, ok := event[2].(string)
+				if ok && at >= startSec && at <= endSec {
+					event[2] = maskTerminalData(data)
+					line, _ = json.Marshal(event)
+					count++
+				}
+			}
+		}
+		_, _ = writer.Write(line)
+		_ = writer.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := writer.Flush(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+	return count, os.Rename(tmpPath, filePath)
+}
+
+// 获取当前用户可以访问的录像
+func getSessionRecord(c *gin.Context, id uint) (model.SessionRecord, error) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		return model.SessionRecord{}, err
+	}
+	var record model.SessionRecord
+	data, err := record.FindByID(id)
+	if err != nil {
+		return data, err
+	}
+	if !isPlatformAdmin(u) && data.Uid != u.ID {
+		return data, errors.New("无权访问该录像")
+	}
+	return data, nil
+}
+
+func SessionRecordFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	uid := uint(0)
+	if !isPlatformAdmin(u) {
+		uid = u.ID
+	}
+	var record model.SessionRecord
+	data, total, err := record.FindPage(q, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func SessionRecordFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	data, err := getSessionRecord(c, uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	var redaction model.RecordRedaction
+	redactions, err := redaction.FindByRecordId(data.ID)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "redactions": redactions})
+}
+
+// SessionRecordPlay GET 获取 asciicast 录像文件
+func SessionRecordPlay(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	data, err := getSessionRecord(c, uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+path.Base(data.FilePath))
+	// 加密录像解密后返回,校验结果通过 X-Record-Verified 返回,被篡改时只返回校验通过的部分
+	if data.Encrypted == "Y" {
+		content, verify, err := readRecord(data)
+		if err != nil {
+			slog.Error("read record error:", "record_id", data.ID, "err_msg", err.Error())
+			c.JSON(200, gin.H{"code": 4, "msg": "读取录像文件错误"})
+			return
+		}
+		if verify.Status == recordVerifyTampered {
+			slog.Warn("session record tampered", "record_id", data.ID, "line", verify.Line, "msg", verify.Msg)
+		}
+		c.Header("X-Record-Verified", verify.Status)
+		c.Data(200, "application/octet-stream", content)
+		return
+	}
+	c.Header("X-Record-Verified", recordVerifyUnsigned)
+	if data.Storage == "local" {
+		c.File(data.FilePath)
+		return
+	}
+	reader, err := artifactStorage(data.Storage).Open(data.FilePath)
+	if err != nil {
+		slog.Error("open record error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 4, "msg": "读取录像文件错误"})
+		return
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	c.DataFromReader(200, -1, "application/octet-stream", reader, nil)
+}
+
+// SessionRecordVerify GET 校验录像是否被篡改
+func SessionRecordVerify(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	data, err := getSessionRecord(c, uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if data.Encrypted != "Y" {
+		c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": RecordVerify{Status: recordVerifyUnsigned}})
+		return
+	}
+	_, verify, err := readRecord(data)
+	if err != nil {
+		slog.Error("read record error:", "record_id", data.ID, "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 4, "msg": "读取录像文件错误"})
+		return
+	}
+	if verify.Status == recordVerifyTampered {
+		slog.Warn("session record tampered", "record_id", data.ID, "line", verify.Line, "msg", verify.Msg)
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": verify})
+}
+
+// SessionRecordRedact POST 对录像指定时间段进行脱敏
+func SessionRecordRedact(c *gin.Context) {
+	type Param struct {
+		ID       uint    `form:"id" binding:"required,gte=1" json:"id"`
+		StartSec float64 `form:"start_sec" binding:"min=0" json:"start_sec"`
+		EndSec   float64 `form:"end_sec" binding:"required,gtfield=StartSec" json:"end_sec"`
+		Reason   string  `form:"reason" binding:"required,min=1,max=512" json:"reason"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || !isPlatformAdmin(u) {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+
+	var record model.SessionRecord
+	data, err := record.FindByID(param.ID)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if _, ok := OnlineClients.Load(data.SessionId); ok {
+		c.JSON(200, gin.H{"code": 4, "msg": "会话进行中,不能脱敏"})
+		return
+	}
+
+	// 对象存储中的录像先下载到本地,脱敏后再上传
+	localPath, err := fetchArtifact(data.Storage, data.FilePath)
+	if err != nil {
+		slog.Error("fetchArtifact error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 5, "msg": "读取录像文件错误"})
+		return
+	}
+	if localPath != data.FilePath {
+		defer func() {
+			_ = os.Remove(localPath)
+		}()
+	}
+	var count int
+	var rc *recordCipher
+	if data.Encrypted == "Y" {
+		count, rc, err = redactEncryptedRecord(data, localPath, param.StartSec, param.EndSec)
+	} else {
+		count, err = redactRecordFile(localPath, param.StartSec, param.EndSec)
+	}
+	if err == nil {
+		err = artifactStorage(data.Storage).Put(data.FilePath, localPath)
+	}
+	if err != nil {
+		slog.Error("redactRecordFile error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 5, "msg": "录像脱敏错误"})
+		return
+	}
+
+	redaction := model.RecordRedaction{
+		RecordId:   data.ID,
+		StartSec:   param.StartSec,
+		EndSec:     param.EndSec,
+		Reason:     param.Reason,
+		ApproverId: u.ID,
+		Approver:   u.Name,
+	}
+	if err := redaction.Create(&redaction); err != nil {
+		c.JSON(200, gin.H{"code": 6, "msg": err.Error()})
+		return
+	}
+	_ = record.UpdateById(data.ID, &model.SessionRecord{Redacted: "Y"})
+	if rc != nil {
+		_ = record.UpdateDigest(data.ID, rc.digest(), rc.events)
+	}
+	slog.Info("record redacted", "record_id", data.ID, "start_sec", param.StartSec, "end_sec", param.EndSec, "approver", u.Name, "events", count)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": redaction, "count": count})
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// logSessionEvent 异步记录会话时间线事件,OccurAt 为空时使用当前时间
+func logSessionEvent(conn *SshConn, event model.SessionEvent) {
+	if !config.DefaultConfig.IsInit {
+		return
+	}
+	event.SessionId = conn.SessionId
+	event.Uid = conn.Uid
+	event.Detail = utils.TruncateString(event.Detail, 1024)
+	if time.Time(event.OccurAt).IsZero() {
+		event.OccurAt = model.DateTime(time.Now())
+	}
+	go func() {
+		if err := event.Create(&event); err != nil {
+			slog.Error("SessionEvent.Create error:", "err_msg", err.Error())
+		}
+	}()
+}
+
+// sessionTimeline 会话的关闭原因,只记录第一次设置的原因,断开事件只记录一次
+type sessionTimeline struct {
+	mu     sync.Mutex
+	reason string
+	closed bool
+}
+
+// setCloseReason 记录会话关闭原因,在关闭会话前调用
+func (s *SshConn) setCloseReason(reason string) {
+	if s.timeline == nil {
+		return
+	}
+	s.timeline.mu.Lock()
+	defer s.timeline.mu.Unlock()
+	if s.timeline.reason == "" {
+		s.timeline.reason = reason
+	}
+}
+
+// logConnect 连接成功后记录认证方式和实际连接的地址
+func (s *SshConn) logConnect() {
+	s.timeline = &sessionTimeline{}
+	logSessionEvent(s, model.SessionEvent{
+		Kind:   model.SessionEventConnect,
+		Detail: fmt.Sprintf("auth=%s endpoint=%s client_ip=%s", s.AuthType, s.Endpoint, s.ClientIP),
+	})
+}
+
+// logDisconnect 记录会话断开和关闭原因
+func (s *SshConn) logDisconnect() {
+	if s.timeline == nil {
+		return
+	}
+	s.timeline.mu.Lock()
+	if s.timeline.closed {
+		s.timeline.mu.Unlock()
+		return
+	}
+	s.timeline.closed = true
+	reason := s.timeline.reason
+	s.timeline.mu.Unlock()
+	if reason == "" {
+		reason = "closed"
+	}
+	logSessionEvent(s, model.SessionEvent{Kind: model.SessionEventDisconnect, Detail: reason})
+}
+
+// timelineHook 按分钟统计终端输入输出的字节数,会话需要录像时记录执行的命令
+type timelineHook struct {
+	mu      sync.Mutex
+	conn    *SshConn
+	minute  time.Time
+	in, out int64
+	audit   bool
+	line    inputLine
+}
+
+func newTimelineHook(conn *SshConn) StreamHook {
+	conf, err := userPolicy(conn.Uid)
+	audit := err == nil && conf.RecordSession == "Y" && policyTargets(conf, conn)
+	return &timelineHook{conn: conn, audit: audit}
+}
+
+func (h *timelineHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.countLocked(int64(len(data)), 0)
+	if h.audit {
+		for _, line := range h.line.Write(data) {
+			logSessionEvent(conn, model.SessionEvent{Kind: model.SessionEventCommand, Detail: line})
+		}
+	}
+	return data, nil
+}
+
+func (h *timelineHook) OnOutput(conn *SshConn, data []byte) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.countLocked(0, int64(len(data)))
+	return data
+}
+
+func (h *timelineHook) countLocked(in, out int64) {
+	minute := time.Now().Truncate(time.Minute)
+	if !minute.Equal(h.minute) {
+		h.flushLocked()
+		h.minute = minute
+	}
+	h.in += in
+	h.out += out
+}
+
+func (h *timelineHook) flushLocked() {
+	if h.in == 0 && h.out == 0 {
+		return
+	}
+	logSessionEvent(h.conn, model.SessionEvent{
+		Kind:     model.SessionEventTraffic,
+		BytesIn:  h.in,
+		BytesOut: h.out,
+		OccurAt:  model.DateTime(h.minute),
+	})
+	h.in, h.out = 0, 0
+}
+
+func (h *timelineHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flushLocked()
+	return nil
+}
+
+// SessionTimeline GET 会话时间线:连接、窗口调整、每分钟流量、命令和断开原因
+func SessionTimeline(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var event model.SessionEvent
+	list, err := event.FindBySessionId(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if len(list) > 0 && !isPlatformAdmin(u) && list[0].Uid != u.ID {
+		c.JSON(200, gin.H{"code": 4, "msg": "无权访问该会话"})
+		return
+	}
+
+	summary := gin.H{"start_at": "", "end_at": "", "reason": ""}
+	var bytesIn, bytesOut int64
+	var commands int
+	for _, item := range list {
+		switch item.Kind {
+		case model.SessionEventConnect:
+			summary["start_at"] = item.OccurAt
+		case model.SessionEventTraffic:
+			bytesIn += item.BytesIn
+			bytesOut += item.BytesOut
+		case model.SessionEventCommand:
+			commands++
+		case model.SessionEventDisconnect:
+			summary["end_at"] = item.OccurAt
+			summary["reason"] = item.Detail
+		}
+	}
+	summary["bytes_in"], summary["bytes_out"], summary["commands"] = bytesIn, bytesOut, commands
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list, "summary": summary})
+}
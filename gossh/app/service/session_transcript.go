@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 会话记录最大长度,超出部分不再记录
+const transcriptMaxSize = 512 * 1024
+
+// 终端转义序列: CSI、OSC 和其他两字节序列
+var ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// sanitizeTranscript 去除终端控制字符并对敏感凭据脱敏
+func sanitizeTranscript(data []byte) string {
+	data = ansiEscapeRe.ReplaceAll(data, nil)
+	data = compactOutput(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+
+	out := make([]rune, 0, len(data))
+	for _, r := range string(data) {
+		switch {
+		case r == '\b':
+			// 退格删除前一个字符
+			if len(out) > 0 && out[len(out)-1] != '\n' {
+				out = out[:len(out)-1]
+			}
+		case r == '\n' || r == '\t' || r >= 0x20:
+			out = append(out, r)
+		}
+	}
+	text := string(out)
+
+	for _, p := range secretPatterns {
+		text = p.re.ReplaceAllStringFunc(text, func(s string) string {
+			return maskSecret(p.kind, s)
+		})
+	}
+	return secretTokenRe.ReplaceAllStringFunc(text, func(s string) string {
+		if isRandomToken(s) {
+			return maskSecret("random_token", s)
+		}
+		return s
+	})
+}
+
+// transcriptHook 记录会话输出,会话结束后发送给开启了会话记录通知的用户
+type transcriptHook struct {
+	mu        sync.Mutex
+	once      sync.Once
+	conn      *SshConn
+	email     string
+	start     time.Time
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func newTranscriptHook(conn *SshConn) StreamHook {
+	if config.DefaultConfig.Smtp.Host == "" {
+		return nil
+	}
+	var user model.SshUser
+	u, err := user.FindByID(conn.Uid)
+	if err != nil || u.TranscriptNotify != "Y" || u.Email == "" {
+		return nil
+	}
+	return &transcriptHook{conn: conn, email: u.Email, start: time.Now()}
+}
+
+func (h *transcriptHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (h *transcriptHook) OnOutput(conn *SshConn, data []byte) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.buf.Len()+len(data) > transcriptMaxSize {
+		h.truncated = true
+		return data
+	}
+	h.buf.Write(data)
+	return data
+}
+
+func (h *transcriptHook) Close() error {
+	h.once.Do(func() {
+		h.mu.Lock()
+		data := bytes.Clone(h.buf.Bytes())
+		truncated := h.truncated
+		h.mu.Unlock()
+		go h.send(data, truncated)
+	})
+	return nil
+}
+
+// send 发送会话摘要和脱敏后的会话记录
+func (h *transcriptHook) send(data []byte, truncated bool) {
+	conn := h.conn
+	end := time.Now()
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("主机: %s (%s@%s:%d)\n", conn.Name, conn.User, conn.Address, conn.Port))
+	sb.WriteString(fmt.Sprintf("客户端IP: %s\n", conn.ClientIP))
+	sb.WriteString(fmt.Sprintf("开始时间: %s\n", h.start.Format(model.TimeFormat)))
+	sb.WriteString(fmt.Sprintf("结束时间: %s\n", end.Format(model.TimeFormat)))
+	sb.WriteString(fmt.Sprintf("持续时间: %s\n", end.Sub(h.start).Round(time.Second)))
+	if truncated {
+		sb.WriteString(fmt.Sprintf("会话输出超过 %d 字节,只保留开头部分\n", transcriptMaxSize))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(sanitizeTranscript(data))
+
+	smtpConf := config.DefaultConfig.Smtp
+	subject := fmt.Sprintf("[%s] 会话记录 %s %s", config.DefaultConfig.AppName, conn.Name, h.start.Format(model.TimeFormat))
+	err := utils.SendMail(utils.MailServer{
+		Host: smtpConf.Host,
+		Port: smtpConf.Port,
+		User: smtpConf.User,
+		Pwd:  smtpConf.Pwd,
+		From: smtpConf.From,
+	}, h.email, subject, sb.String())
+	if err != nil {
+		slog.Error("send transcript mail error:", "sid", conn.SessionId, "err_msg", err.Error())
+		return
+	}
+	slog.Info("transcript mail sent", "sid", conn.SessionId, "uid", conn.Uid)
+}
+
+// UserNotifySet PATCH 修改当前用户的会话记录通知设置
+func UserNotifySet(c *gin.Context) {
+	type Param struct {
+		Email            string `form:"email" binding:"omitempty,email,max=128" json:"email"`
+		TranscriptNotify string `form:"transcript_notify" binding:"required,oneof=Y N" json:"transcript_notify"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if param.TranscriptNotify == "Y" && param.Email == "" {
+		c.JSON(200, gin.H{"code": 1, "msg": "开启会话记录通知需要填写邮箱"})
+		return
+	}
+	var user model.SshUser
+	if err := user.UpdateNotify(c.GetUint("uid"), param.Email, param.TranscriptNotify); err != nil {
+		slog.Error("UpdateNotify错误", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": "更新通知设置错误"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
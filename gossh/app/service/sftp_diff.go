@@ -0,0 +1,195 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// 对比的文件大小上限
+	diffMaxFileSize = 1024 * 1024
+	// 计算差异的最大版本数量,超过后只返回每台主机的摘要
+	diffMaxVariants = 20
+)
+
+// DiffHost 单台主机上的文件
+type DiffHost struct {
+	ConfId  uint   `json:"conf_id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Size    int64  `json:"size"`
+	Mode    string `json:"mode"`
+	ModTime string `json:"mod_time"`
+	Sha256  string `json:"sha256"`
+	Variant int    `json:"variant"` // 内容相同的主机属于同一个版本,读取失败时为 -1
+}
+
+// DiffVariant 内容相同的一组主机
+type DiffVariant struct {
+	Sha256  string `json:"sha256"`
+	Binary  bool   `json:"binary"`
+	ConfIds []uint `json:"conf_ids"`
+	content string
+}
+
+// DiffPair 两个版本之间的差异,Diff 为 unified 格式
+type DiffPair struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Diff string `json:"diff"`
+	Msg  string `json:"msg"`
+}
+
+// fetchDiffFile 读取一台主机上的文件,返回码:1 连接失败,2 读取失败
+func fetchDiffFile(conf model.SshConf, sessionId, clientIp string, confirm []string, p string) (DiffHost, []byte) {
+	host := DiffHost{ConfId: conf.ID, Name: conf.Name, Address: conf.Address, Variant: -1}
+	conn, err := openBatchConn(conf, sessionId, clientIp, confirm)
+	if err != nil {
+		host.Code, host.Msg = 1, err.Error()
+		return host, nil
+	}
+	defer closeBatchConn(conn)
+	data, info, err := batchReadFile(conn, p, diffMaxFileSize)
+	if err != nil {
+		host.Code, host.Msg = 2, err.Error()
+		return host, nil
+	}
+	sum := sha256.Sum256(data)
+	host.Msg = "ok"
+	host.Size = int64(len(data))
+	host.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+	host.ModTime = info.ModTime().Format(time.DateTime)
+	host.Sha256 = hex.EncodeToString(sum[:])
+	return host, data
+}
+
+// SftpDiff POST 读取多台主机上的同一个文件,按内容分组后返回各版本之间的差异,用于发现配置漂移
+// 差异矩阵中每一项为版本 from 到版本 to 的 unified diff,版本按主机数量从多到少排列
+func SftpDiff(c *gin.Context) {
+	type Param struct {
+		ConfIds     []uint   `form:"conf_ids" binding:"required,min=2" json:"conf_ids"`
+		Path        string   `form:"path" binding:"required,min=1,max=1024" json:"path"`
+		Context     int      `form:"context" binding:"gte=0,lte=100" json:"context"`
+		Concurrency int      `form:"concurrency" binding:"gte=0,lte=50" json:"concurrency"`
+		Confirm     []string `form:"confirm" json:"confirm"` // 已确认的生产环境主机地址
+	}
+	param := Param{Context: 3}
+	if err := c.ShouldBindJSON(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if len(param.ConfIds) > snippetRunMaxHosts {
+		c.JSON(200, gin.H{"code": 1, "msg": fmt.Sprintf("最多选择 %d 台主机", snippetRunMaxHosts)})
+		return
+	}
+	uid := c.GetUint("uid")
+	var sshConf model.SshConf
+	confs := make([]model.SshConf, 0, len(param.ConfIds))
+	for _, id := range param.ConfIds {
+		conf, err := sshConf.FindByID(id, uid)
+		if err != nil {
+			c.JSON(200, gin.H{"code": 2, "msg": fmt.Sprintf("主机 %d 不存在", id)})
+			return
+		}
+		confs = append(confs, conf)
+	}
+	concurrency := param.Concurrency
+	if concurrency == 0 {
+		concurrency = snippetRunConcurrency
+	}
+
+	sessionId := "diff-" + utils.RandString(8)
+	hosts := make([]DiffHost, len(confs))
+	contents := make([][]byte, len(confs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, conf := range confs {
+		wg.Add(1)
+		go func(i int, conf model.SshConf) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			hosts[i], contents[i] = fetchDiffFile(conf, sessionId, c.RemoteIP(), param.Confirm, param.Path)
+		}(i, conf)
+	}
+	wg.Wait()
+
+	variants := diffVariants(hosts, contents)
+	matrix, err := diffMatrix(variants, param.Path, param.Context)
+	msg := "ok"
+	if err != nil {
+		msg = err.Error()
+	}
+	slog.Info("sftp diff", "uid", uid, "path", param.Path, "hosts", len(confs), "variants", len(variants))
+	c.JSON(200, gin.H{"code": 0, "msg": msg, "data": gin.H{"hosts": hosts, "variants": variants, "matrix": matrix}})
+}
+
+// diffVariants 按内容分组,版本按主机数量从多到少排列,主机最多的版本通常是基准配置
+func diffVariants(hosts []DiffHost, contents [][]byte) []*DiffVariant {
+	var variants []*DiffVariant
+	index := map[string]*DiffVariant{}
+	for i := range hosts {
+		if hosts[i].Code != 0 {
+			continue
+		}
+		variant, ok := index[hosts[i].Sha256]
+		if !ok {
+			variant = &DiffVariant{Sha256: hosts[i].Sha256, Binary: bytes.IndexByte(contents[i], 0) >= 0, content: string(contents[i])}
+			index[hosts[i].Sha256] = variant
+			variants = append(variants, variant)
+		}
+		variant.ConfIds = append(variant.ConfIds, hosts[i].ConfId)
+	}
+	sort.SliceStable(variants, func(i, j int) bool { return len(variants[i].ConfIds) > len(variants[j].ConfIds) })
+	position := map[string]int{}
+	for i, variant := range variants {
+		position[variant.Sha256] = i
+	}
+	for i := range hosts {
+		if hosts[i].Code == 0 {
+			hosts[i].Variant = position[hosts[i].Sha256]
+		}
+	}
+	return variants
+}
+
+// diffMatrix 计算每两个版本之间的差异,j 到 i 的差异与 i 到 j 相反,只计算 i < j
+func diffMatrix(variants []*DiffVariant, p string, context int) ([]DiffPair, error) {
+	matrix := []DiffPair{}
+	if len(variants) > diffMaxVariants {
+		return matrix, fmt.Errorf("文件有 %d 个不同版本,超过 %d 个时不计算差异", len(variants), diffMaxVariants)
+	}
+	for i := 0; i < len(variants); i++ {
+		for j := i + 1; j < len(variants); j++ {
+			pair := DiffPair{From: i, To: j}
+			if variants[i].Binary || variants[j].Binary {
+				pair.Msg = "二进制文件不同"
+				matrix = append(matrix, pair)
+				continue
+			}
+			diff, err := utils.UnifiedDiff(fmt.Sprintf("%s#%d", p, i), fmt.Sprintf("%s#%d", p, j), variants[i].content, variants[j].content, context)
+			switch {
+			case errors.Is(err, utils.ErrDiffTooLarge):
+				pair.Msg = "差异过大"
+			case err != nil:
+				pair.Msg = err.Error()
+			default:
+				pair.Diff = diff
+			}
+			matrix = append(matrix, pair)
+		}
+	}
+	return matrix, nil
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StageDir 暂存待分发文件的目录
+var StageDir = path.Join(config.WorkDir, "staging")
+
+// 暂存文件的保留时长
+const stageRetention = 24 * time.Hour
+
+// StagedFile 暂存的待分发文件,保存在当前配置的文件存储中,可以多次分发
+type StagedFile struct {
+	Id        string    `json:"id"`
+	Uid       uint      `json:"uid"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Sha256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+	kind      string
+	filePath  string
+}
+
+var stagedFiles sync.Map
+
+// saveStageFile 将上传的文件保存到暂存目录,返回本地路径、大小和摘要
+func saveStageFile(file *multipart.FileHeader) (string, int64, string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	if err := os.MkdirAll(StageDir, os.FileMode(0700)); err != nil {
+		return "", 0, "", err
+	}
+	localPath := path.Join(StageDir, utils.RandString(16))
+	dst, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return "", 0, "", err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hash), src)
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(localPath)
+		return "", 0, "", err
+	}
+	return localPath, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// limitUpload 按配置限制上传大小
+func limitUpload(c *gin.Context) bool {
+	if maxSize := config.DefaultConfig.Limits.MaxUploadSize; maxSize > 0 {
+		if c.Request.ContentLength > maxSize {
+			c.JSON(200, gin.H{"code": 5, "msg": "上传文件超过大小限制"})
+			return false
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	}
+	return true
+}
+
+// cleanStagedFiles 删除超过保留时长的暂存文件
+func cleanStagedFiles() {
+	defer utils.RecoverPanic("cleanStagedFiles")
+	stagedFiles.Range(func(key, value any) bool {
+		staged := value.(*StagedFile)
+		if time.Since(staged.CreatedAt) < stageRetention {
+			return true
+		}
+		stagedFiles.Delete(key)
+		if err := artifactStorage(staged.kind).Remove(staged.filePath); err != nil {
+			slog.Error("remove staged file error:", "path", staged.filePath, "err_msg", err.Error())
+		}
+		return true
+	})
+	// 重启后丢失索引的暂存文件
+	entries, err := os.ReadDir(StageDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < stageRetention {
+			continue
+		}
+		if _, ok := stagedFiles.Load(entry.Name()); !ok {
+			removeLocal(path.Join(StageDir, entry.Name()))
+		}
+	}
+}
+
+func stageCleanLoop() {
+	for {
+		time.Sleep(time.Hour)
+		cleanStagedFiles()
+	}
+}
+
+// SftpStage POST 暂存待分发的文件,返回的ID在 /api/sftp/distribute 中使用,保留 24 小时
+func SftpStage(c *gin.Context) {
+	if !limitUpload(c) {
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "获取form数据错误"})
+		return
+	}
+	localPath, size, sum, err := saveStageFile(file)
+	if err != nil {
+		slog.Error("save staged file error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	staged := &StagedFile{
+		Id:        path.Base(localPath),
+		Uid:       c.GetUint("uid"),
+		Name:      path.Base(file.Filename),
+		Size:      size,
+		Sha256:    sum,
+		CreatedAt: time.Now(),
+	}
+	staged.kind, staged.filePath, err = storeArtifact("staging/"+staged.Id, localPath)
+	if err != nil {
+		_ = os.Remove(localPath)
+		slog.Error("store staged file error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	stagedFiles.Store(staged.Id, staged)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": staged})
+}
+
+// SftpStageFindAll GET 当前用户的暂存文件
+func SftpStageFindAll(c *gin.Context) {
+	uid := c.GetUint("uid")
+	list := []*StagedFile{}
+	stagedFiles.Range(func(key, value any) bool {
+		if staged := value.(*StagedFile); staged.Uid == uid {
+			list = append(list, staged)
+		}
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list})
+}
+
+// DistributeResult 单台主机的分发结果
+type DistributeResult struct {
+	ConfId  uint   `json:"conf_id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Cmd     string `json:"cmd"`
+	Output  string `json:"output"`
+}
+
+// distributeParam 文件分发参数,path 以 / 结尾时作为目录,文件名使用原文件名
+type distributeParam struct {
+	ConfIds     string `form:"conf_ids" binding:"required,max=4096"` // 逗号分隔的主机配置ID
+	Path        string `form:"path" binding:"required,min=1,max=1024"`
+	Mode        string `form:"mode" binding:"max=4"`
+	StageId     string `form:"stage_id" binding:"max=64"`
+	PostCmd     string `form:"post_cmd" binding:"max=4096"`
+	Timeout     uint   `form:"timeout" binding:"lte=86400"`
+	Concurrency int    `form:"concurrency" binding:"gte=0,lte=50"`
+	Confirm     string `form:"confirm" binding:"max=4096"` // 逗号分隔的已确认的生产环境主机地址
+}
+
+// SftpDistribute POST 将上传的文件或暂存文件并发写入多台主机的同一路径,可选在写入后执行命令
+// 命令支持 {{host}}、{{path}} 等变量,执行失败不影响文件写入的结果
+func SftpDistribute(c *gin.Context) {
+	if !limitUpload(c) {
+		return
+	}
+	var param distributeParam
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	mode, err := parseFileMode(param.Mode)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	uid := c.GetUint("uid")
+	var sshConf model.SshConf
+	var confs []model.SshConf
+	for _, s := range strings.Split(param.ConfIds, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": "conf_ids 格式错误"})
+			return
+		}
+		conf, err := sshConf.FindByID(uint(id), uid)
+		if err != nil {
+			c.JSON(200, gin.H{"code": 2, "msg": fmt.Sprintf("主机 %d 不存在", id)})
+			return
+		}
+		confs = append(confs, conf)
+	}
+	if len(confs) > snippetRunMaxHosts {
+		c.JSON(200, gin.H{"code": 1, "msg": fmt.Sprintf("最多选择 %d 台主机", snippetRunMaxHosts)})
+		return
+	}
+
+	// 文件先保存到本地,每台主机分别从头读取
+	var localPath, name string
+	if param.StageId != "" {
+		value, ok := stagedFiles.Load(param.StageId)
+		if !ok || value.(*StagedFile).Uid != uid {
+			c.JSON(200, gin.H{"code": 3, "msg": "暂存文件不存在"})
+			return
+		}
+		staged := value.(*StagedFile)
+		if localPath, err = fetchArtifact(staged.kind, staged.filePath); err != nil {
+			c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+			return
+		}
+		if staged.kind == "s3" {
+			defer removeLocal(localPath)
+		}
+		name = staged.Name
+	} else {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(200, gin.H{"code": 3, "msg": "获取form数据错误"})
+			return
+		}
+		if localPath, _, _, err = saveStageFile(file); err != nil {
+			c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+			return
+		}
+		defer removeLocal(localPath)
+		name = path.Base(file.Filename)
+	}
+	dstPath := param.Path
+	if strings.HasSuffix(dstPath, "/") {
+		dstPath = path.Join(dstPath, name)
+	}
+
+	// 文件内容相同,只扫描一次
+	scanFile, err := os.Open(localPath)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	err = scanUpload(&SshConn{SshConf: &confs[0]}, c.ClientIP(), name, scanFile)
+	_ = scanFile.Close()
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+
+	var confirm []string
+	if param.Confirm != "" {
+		confirm = strings.Split(param.Confirm, ",")
+	}
+	concurrency := param.Concurrency
+	if concurrency == 0 {
+		concurrency = snippetRunConcurrency
+	}
+	sessionId := "distribute-" + utils.RandString(8)
+	results := make([]DistributeResult, len(confs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, conf := range confs {
+		wg.Add(1)
+		go func(i int, conf model.SshConf) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = distributeHost(conf, sessionId, c.RemoteIP(), confirm, localPath, dstPath, mode, param.PostCmd, param.Timeout)
+		}(i, conf)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Code != 0 {
+			failed++
+		}
+	}
+	slog.Info("sftp distribute", "uid", uid, "path", dstPath, "hosts", len(confs), "failed", failed)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": results, "failed": failed})
+}
+
+func removeLocal(localPath string) {
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		slog.Error("remove local file error:", "path", localPath, "err_msg", err.Error())
+	}
+}
+
+// distributeHost 将文件写入一台主机,返回码:1 连接失败,2 写入失败,3 命令执行失败
+func distributeHost(conf model.SshConf, sessionId, clientIp string, confirm []string, localPath, dstPath string, mode os.FileMode, postCmd string, timeout uint) DistributeResult {
+	result := DistributeResult{ConfId: conf.ID, Name: conf.Name, Address: conf.Address}
+	conn, err := openBatchConn(conf, sessionId, clientIp, confirm)
+	if err != nil {
+		result.Code, result.Msg = 1, err.Error()
+		return result
+	}
+	defer closeBatchConn(conn)
+
+	p, err := renderSnippet(dstPath, conn, nil)
+	if err != nil {
+		result.Code, result.Msg = 2, err.Error()
+		return result
+	}
+	result.Path = p
+	file, err := os.Open(localPath)
+	if err != nil {
+		result.Code, result.Msg = 2, err.Error()
+		return result
+	}
+	result.Size, err = batchWriteFile(conn, p, file, mode)
+	_ = file.Close()
+	if err != nil {
+		result.Code, result.Msg = 2, err.Error()
+		return result
+	}
+	if postCmd == "" {
+		result.Msg = "ok"
+		return result
+	}
+
+	cmd, err := renderSnippet(postCmd, conn, map[string]string{"path": p})
+	if err != nil {
+		result.Code, result.Msg = 3, err.Error()
+		return result
+	}
+	result.Cmd = cmd
+	out, err := execOnConn(conn, cmd, timeout)
+	result.Output = pipelineTruncate(out)
+	var timeoutErr *ExecTimeoutError
+	switch {
+	case errors.As(err, &timeoutErr):
+		result.Code, result.Msg = 3, "exec cmd timeout"
+	case err != nil:
+		result.Code, result.Msg = 3, err.Error()
+	default:
+		result.Msg = "ok"
+	}
+	return result
+}
+
+func init() {
+	go stageCleanLoop()
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+)
+
+// splitSftpPaths 按行或逗号拆分路径设置
+func splitSftpPaths(paths string) []string {
+	return strings.FieldsFunc(paths, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' })
+}
+
+// sftpPathPrefixes 解析策略中允许访问的路径前缀,%u 替换为 SSH 登录用户
+func sftpPathPrefixes(paths, user string) []string {
+	var list []string
+	for _, item := range splitSftpPaths(paths) {
+		item = strings.ReplaceAll(strings.TrimSpace(item), "%u", user)
+		if path.IsAbs(item) {
+			list = append(list, path.Clean(item))
+		}
+	}
+	return list
+}
+
+// 解析路径时最多展开的符号链接数量
+const maxSftpSymlinks = 40
+
+// sftpResolvePath 解析为远程服务器上的绝对路径,逐级展开符号链接后再处理 ..
+// 不存在的部分按原样拼接,用于新建文件和目录
+func sftpResolvePath(conn *SshConn, p string) (string, error) {
+	if !path.IsAbs(p) {
+		wd, err := conn.sftpClient.Getwd()
+		if err != nil {
+			return "", err
+		}
+		p = path.Join(wd, p)
+	}
+	parts := strings.Split(p, "/")
+	resolved := "/"
+	links := 0
+	for len(parts) > 0 {
+		name := parts[0]
+		parts = parts[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, name)
+		fi, err := conn.sftpClient.Lstat(next)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSftpSymlinks {
+			return "", errors.New("解析路径错误")
+		}
+		target, err := conn.sftpClient.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	return resolved, nil
+}
+
+// checkSftpPath 按策略校验 SFTP 操作的路径,write 为 true 时同时校验只读设置
+func checkSftpPath(conn *SshConn, p string, write bool) error {
+	// 等待审批或交互认证的会话还没有连接
+	if conn.sftpClient == nil {
+		return errors.New("会话未连接")
+	}
+	policy, err := userPolicy(conn.Uid)
+	if err != nil {
+		return err
+	}
+	if !policyTargets(policy, conn) {
+		return nil
+	}
+	if write && policy.SftpReadOnly == "Y" {
+		return errors.New("SFTP为只读模式")
+	}
+	prefixes := sftpPathPrefixes(policy.SftpPaths, conn.User)
+	if len(prefixes) == 0 {
+		return nil
+	}
+	real, err := sftpResolvePath(conn, p)
+	if err != nil {
+		return err
+	}
+	for _, prefix := range prefixes {
+		if prefix == "/" || real == prefix || strings.HasPrefix(real, prefix+"/") {
+			return nil
+		}
+	}
+	slog.Warn("sftp path denied", "uid", conn.Uid, "host", conn.Address, "ssh_user", conn.User, "path", real)
+	return errors.New("路径不在允许访问的范围内")
+}
+
+// policySftp SFTP 路径限制设置
+type policySftp struct {
+	Id           uint   `form:"id" binding:"required" json:"id"`
+	SftpPaths    string `form:"sftp_paths" binding:"max=4096" json:"sftp_paths"`
+	SftpReadOnly string `form:"sftp_read_only" binding:"required,oneof=Y N" json:"sftp_read_only"`
+}
+
+// PolicySftpSet PUT 设置 SFTP 允许访问的路径前缀和只读模式,路径为空表示不限制
+func PolicySftpSet(c *gin.Context) {
+	var param policySftp
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	for _, item := range splitSftpPaths(param.SftpPaths) {
+		if item = strings.TrimSpace(item); item != "" && !path.IsAbs(item) {
+			c.JSON(200, gin.H{"code": 1, "msg": "路径必须以/开头:" + item})
+			return
+		}
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if _, ok := findTenantPolicy(c, param.Id); !ok {
+		return
+	}
+	if submitChange(c, "policy_sftp", "update", param.Id, param) {
+		return
+	}
+	var conf model.PolicyConf
+	if err := conf.UpdateSftp(param.Id, param.SftpPaths, param.SftpReadOnly); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	data, err := conf.FindByID(param.Id)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/utils"
+	"io"
+	"log/slog"
+)
+
+// scanUpload 上传前扫描文件内容,发现病毒或扫描服务不可用时返回错误
+func scanUpload(conn *SshConn, clientIp, fileName string, r io.Reader) error {
+	scanConf := config.DefaultConfig.Scan
+	if scanConf.Clamd == "" {
+		return nil
+	}
+	scanner := utils.ClamdScanner{Address: scanConf.Clamd, Timeout: scanConf.Timeout}
+	virus, err := scanner.Scan(r)
+	if err != nil {
+		slog.Error("upload scan error:", "file", fileName, "err_msg", err.Error())
+		if scanConf.FailOpen {
+			return nil
+		}
+		return errors.New("病毒扫描服务不可用")
+	}
+	if virus == "" {
+		return nil
+	}
+	slog.Warn("upload virus found", "file", fileName, "virus", virus, "uid", conn.Uid,
+		"host", conn.Address, "ssh_user", conn.User, "client_ip", clientIp)
+	notifyEvent(EventVirusFound, "上传文件发现病毒",
+		fmt.Sprintf("文件: %s\n病毒: %s\n主机: %s@%s:%d\n客户端IP: %s",
+			fileName, virus, conn.User, conn.Address, conn.Port, clientIp))
+	return fmt.Errorf("发现病毒: %s", virus)
+}
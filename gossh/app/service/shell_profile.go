@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/model"
+	"gossh/gin"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 环境变量名称和别名名称
+var (
+	envNameRegexp   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	aliasNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+)
+
+// shellQuote 使用单引号转义
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// buildProfileScript 生成登录后注入的脚本,每行以空格开头避免写入 history
+func buildProfileScript(profile model.ShellProfile) string {
+	var sb strings.Builder
+	eachLine := func(text string, re *regexp.Regexp, fn func(name, value string)) {
+		for _, line := range strings.Split(text, "\n") {
+			name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			name = strings.TrimSpace(name)
+			if ok && re.MatchString(name) {
+				fn(name, strings.TrimSpace(value))
+			}
+		}
+	}
+	eachLine(profile.EnvVars, envNameRegexp, func(name, value string) {
+		sb.WriteString(fmt.Sprintf(" export %s=%s\n", name, shellQuote(value)))
+	})
+	eachLine(profile.Aliases, aliasNameRegexp, func(name, value string) {
+		sb.WriteString(fmt.Sprintf(" alias %s=%s\n", name, shellQuote(value)))
+	})
+	if profile.Ps1 != "" {
+		sb.WriteString(fmt.Sprintf(" export PS1=%s\n", shellQuote(profile.Ps1)))
+	}
+	for _, line := range strings.Split(profile.Script, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			sb.WriteString(" " + line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// loadProfileScript 获取连接使用的配置,未指定时使用用户的默认配置
+func loadProfileScript(conn *SshConn) string {
+	var shellProfile model.ShellProfile
+	var profile model.ShellProfile
+	var err error
+	if conn.ShellProfileId != 0 {
+		profile, err = shellProfile.FindByID(conn.ShellProfileId, conn.Uid)
+	} else {
+		profile, err = shellProfile.FindDefault(conn.Uid)
+	}
+	if err != nil {
+		return ""
+	}
+	return buildProfileScript(profile)
+}
+
+func ShellProfileCreate(c *gin.Context) {
+	var profile model.ShellProfile
+	if err := c.ShouldBind(&profile); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	profile.Uid = c.GetUint("uid")
+	if profile.IsDefault == "Y" {
+		_ = profile.ClearDefault(profile.Uid)
+	}
+	err := profile.Create(&profile)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	ShellProfileFindAll(c)
+}
+
+func ShellProfileFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var profile model.ShellProfile
+	data, err := profile.FindByID(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+func ShellProfileFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+
+	var profile model.ShellProfile
+	data, total, err := profile.FindPage(q, c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func ShellProfileUpdateById(c *gin.Context) {
+	var profile model.ShellProfile
+	if err := c.ShouldBind(&profile); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if profile.IsDefault == "Y" {
+		_ = profile.ClearDefault(c.GetUint("uid"))
+	}
+	err := profile.UpdateById(profile.ID, c.GetUint("uid"), &profile)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	ShellProfileFindAll(c)
+}
+
+func ShellProfileDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var profile model.ShellProfile
+	err = profile.DeleteByID(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	ShellProfileFindAll(c)
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/crypto/ssh"
+	"gossh/gin"
+	"path"
+	"slices"
+	"strings"
+)
+
+// sshAlgos 连接主机使用的算法,按优先顺序排列
+type sshAlgos struct {
+	Ciphers      []string `json:"ciphers"`
+	KeyExchanges []string `json:"key_exchanges"`
+	MACs         []string `json:"macs"`
+	HostKeyAlgos []string `json:"host_key_algos"`
+	deny         []string
+}
+
+// supportedAlgos 支持的全部算法,配置中的算法必须在这里
+var supportedAlgos = sshAlgos{
+	Ciphers: []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"arcfour256", "arcfour128", "arcfour", "aes128-cbc", "3des-cbc",
+	},
+	KeyExchanges: []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+	},
+	MACs: []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1", "hmac-sha1-96",
+	},
+	HostKeyAlgos: []string{
+		ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01,
+		ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01, ssh.CertAlgoED25519v01,
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSA, ssh.KeyAlgoDSA, ssh.KeyAlgoED25519,
+	},
+}
+
+// defaultAlgos 配置为空时使用的算法,与 ssh 包的默认值一致
+var defaultAlgos = sshAlgos{
+	Ciphers: []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	},
+	KeyExchanges: []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1",
+	},
+	MACs:         supportedAlgos.MACs,
+	HostKeyAlgos: supportedAlgos.HostKeyAlgos,
+}
+
+// certAlgoKeys 证书算法对应的签名算法,禁用签名算法时同时禁用对应的证书算法
+var certAlgoKeys = map[string]string{
+	ssh.CertAlgoRSASHA256v01: ssh.KeyAlgoRSASHA256,
+	ssh.CertAlgoRSASHA512v01: ssh.KeyAlgoRSASHA512,
+	ssh.CertAlgoRSAv01:       ssh.KeyAlgoRSA,
+	ssh.CertAlgoDSAv01:       ssh.KeyAlgoDSA,
+	ssh.CertAlgoECDSA256v01:  ssh.KeyAlgoECDSA256,
+	ssh.CertAlgoECDSA384v01:  ssh.KeyAlgoECDSA384,
+	ssh.CertAlgoECDSA521v01:  ssh.KeyAlgoECDSA521,
+	ssh.CertAlgoED25519v01:   ssh.KeyAlgoED25519,
+}
+
+// parseAlgoDeny 解析策略中的禁用算法,以逗号、空格或换行分隔
+func parseAlgoDeny(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+}
+
+// checkAlgoDeny 检查禁用算法的通配符格式
+func checkAlgoDeny(list []string) error {
+	for _, pattern := range list {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("禁用算法格式错误: %s", pattern)
+		}
+	}
+	return nil
+}
+
+// algoDenied 算法是否被禁用,证书算法同时按对应的签名算法判断
+func algoDenied(algo string, deny []string) bool {
+	key, isCert := certAlgoKeys[algo]
+	for _, pattern := range deny {
+		if ok, _ := path.Match(pattern, algo); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, key); isCert && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAlgos 按配置、FIPS 模式和禁用列表得到可用的算法,结果为空时返回错误
+func filterAlgos(kind string, allow, defaults, supported, fips, deny []string) ([]string, error) {
+	if len(allow) == 0 {
+		allow = defaults
+	}
+	list := make([]string, 0, len(allow))
+	for _, algo := range allow {
+		if !slices.Contains(supported, algo) {
+			return nil, fmt.Errorf("不支持的算法: %s %s", kind, algo)
+		}
+		if fipsMode && !slices.Contains(fips, algo) {
+			continue
+		}
+		if algoDenied(algo, deny) || slices.Contains(list, algo) {
+			continue
+		}
+		list = append(list, algo)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("没有可用的算法: %s", kind)
+	}
+	return list, nil
+}
+
+// effectiveAlgos 全局配置与策略禁用算法合并后连接使用的算法
+func effectiveAlgos(policyDeny []string) (sshAlgos, error) {
+	var algos sshAlgos
+	conf := config.DefaultConfig.SshAlgo
+	deny := append(slices.Clone(conf.Deny), policyDeny...)
+	if err := checkAlgoDeny(deny); err != nil {
+		return algos, err
+	}
+	var err error
+	if algos.Ciphers, err = filterAlgos("cipher", conf.Ciphers, defaultAlgos.Ciphers, supportedAlgos.Ciphers, fipsAlgos.Ciphers, deny); err != nil {
+		return algos, err
+	}
+	if algos.KeyExchanges, err = filterAlgos("kex", conf.KeyExchanges, defaultAlgos.KeyExchanges, supportedAlgos.KeyExchanges, fipsAlgos.KeyExchanges, deny); err != nil {
+		return algos, err
+	}
+	if algos.MACs, err = filterAlgos("mac", conf.MACs, defaultAlgos.MACs, supportedAlgos.MACs, fipsAlgos.MACs, deny); err != nil {
+		return algos, err
+	}
+	if algos.HostKeyAlgos, err = filterAlgos("hostkey", conf.HostKeyAlgos, defaultAlgos.HostKeyAlgos, supportedAlgos.HostKeyAlgos, fipsAlgos.HostKeyAlgos, deny); err != nil {
+		return algos, err
+	}
+	algos.deny = deny
+	return algos, nil
+}
+
+// userAlgos 用户连接主机使用的算法,用户所在租户的策略可以禁用更多算法
+func userAlgos(uid uint) (sshAlgos, error) {
+	var deny []string
+	if policy, err := userPolicy(uid); err == nil {
+		deny = parseAlgoDeny(policy.AlgoDeny)
+	}
+	return effectiveAlgos(deny)
+}
+
+// apply 设置连接使用的算法
+func (a sshAlgos) apply(config *ssh.ClientConfig) {
+	config.Ciphers = a.Ciphers
+	config.KeyExchanges = a.KeyExchanges
+	config.MACs = a.MACs
+	config.HostKeyAlgorithms = a.HostKeyAlgos
+}
+
+// signer 限制私钥认证使用的签名算法,例如禁用 ssh-rsa 后 RSA 私钥只使用 rsa-sha2-256/512 签名
+func (a sshAlgos) signer(signer ssh.Signer) (ssh.Signer, error) {
+	keyType := signer.PublicKey().Type()
+	candidates := []string{keyType}
+	if keyType == ssh.KeyAlgoRSA {
+		candidates = []string{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSA}
+	}
+	algos := make([]string, 0, len(candidates))
+	for _, algo := range candidates {
+		if fipsMode && !slices.Contains(fipsAlgos.HostKeyAlgos, algo) {
+			continue
+		}
+		if !algoDenied(algo, a.deny) {
+			algos = append(algos, algo)
+		}
+	}
+	if len(algos) == 0 {
+		return nil, fmt.Errorf("私钥类型已被禁用: %s", keyType)
+	}
+	if len(algos) == len(candidates) {
+		return signer, nil
+	}
+	algoSigner, ok := signer.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("私钥类型不支持指定签名算法: %s", keyType)
+	}
+	return ssh.NewSignerWithAlgorithms(algoSigner, algos)
+}
+
+// SshAlgoFind GET 当前用户连接主机使用的算法,包括是否为 FIPS 模式
+func SshAlgoFind(c *gin.Context) {
+	algos, err := userAlgos(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"fips":  fipsMode,
+		"algos": algos,
+		"deny":  algos.deny,
+	}})
+}
+
+// policyAlgo 策略禁用的连接算法
+type policyAlgo struct {
+	Id       uint   `form:"id" binding:"required" json:"id"`
+	AlgoDeny string `form:"algo_deny" binding:"max=4096" json:"algo_deny"`
+}
+
+// PolicyAlgoSet PUT 设置策略禁用的连接算法,与全局配置合并后没有可用算法时拒绝保存
+func PolicyAlgoSet(c *gin.Context) {
+	var param policyAlgo
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	param.AlgoDeny = strings.Join(parseAlgoDeny(param.AlgoDeny), ",")
+	if _, err := effectiveAlgos(parseAlgoDeny(param.AlgoDeny)); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if _, ok := findTenantPolicy(c, param.Id); !ok {
+		return
+	}
+	if submitChange(c, "policy_algo", "update", param.Id, param) {
+		return
+	}
+	var conf model.PolicyConf
+	if err := conf.UpdateAlgoDeny(param.Id, param.AlgoDeny); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	data, err := conf.FindByID(param.Id)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
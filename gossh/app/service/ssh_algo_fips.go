@@ -0,0 +1,31 @@
+//go:build fips
+
+package service
+
+import "gossh/crypto/ssh"
+
+// fipsMode 使用 go build -tags fips 编译时连接主机只使用 FIPS 140 认可的算法
+// 不使用 curve25519、chacha20、ed25519 以及基于 SHA-1 的算法
+const fipsMode = true
+
+// fipsAlgos FIPS 模式下可以使用的算法
+var fipsAlgos = sshAlgos{
+	Ciphers: []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	},
+	KeyExchanges: []string{
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+	},
+	MACs: []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512",
+	},
+	HostKeyAlgos: []string{
+		ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSASHA512v01,
+		ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512,
+	},
+}
@@ -0,0 +1,8 @@
+//go:build !fips
+
+package service
+
+// fipsMode 默认编译不限制为 FIPS 算法
+const fipsMode = false
+
+var fipsAlgos sshAlgos
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"gossh/app/config"
+	"gossh/app/utils"
+	"sync"
+)
+
+// sessionBinding 会话绑定的一次性随机数,防止泄露的会话ID被他人接入终端
+type sessionBinding struct {
+	mu    sync.Mutex
+	nonce string
+}
+
+func newSessionBinding() *sessionBinding {
+	return &sessionBinding{nonce: utils.RandString(32)}
+}
+
+// consume 校验随机数,校验成功后失效
+func (b *sessionBinding) consume(nonce string) bool {
+	if b == nil || nonce == "" {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.nonce == "" || subtle.ConstantTimeCompare([]byte(b.nonce), []byte(nonce)) != 1 {
+		return false
+	}
+	b.nonce = ""
+	return true
+}
+
+// renew 生成新的随机数,用于重新接入保留的会话
+func (b *sessionBinding) renew() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nonce = utils.RandString(32)
+	return b.nonce
+}
+
+// checkSessionOwner 校验会话是否属于当前用户,开启 session_bind_ip 时同时校验客户端IP
+func checkSessionOwner(conn *SshConn, uid uint, clientIp string) error {
+	if conn.Uid != uid {
+		return errors.New("session does not belong to current user")
+	}
+	if config.DefaultConfig.SessionBindIp && conn.ClientIP != clientIp {
+		return errors.New("client ip does not match session")
+	}
+	return nil
+}
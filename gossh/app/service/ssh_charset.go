@@ -0,0 +1,60 @@
+package service
+
+import (
+	"gossh/app/utils"
+	"io"
+	"log/slog"
+)
+
+// charsetWriter 将目标主机的输出转换为 UTF-8
+type charsetWriter struct {
+	writer  io.Writer
+	decoder *utils.CharsetDecoder
+}
+
+func (w *charsetWriter) Write(p []byte) (int, error) {
+	if _, err := w.writer.Write(w.decoder.Decode(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// charsetReader 将终端输入转换为目标主机的字符集
+type charsetReader struct {
+	reader  io.Reader
+	encoder *utils.CharsetEncoder
+	pending []byte
+}
+
+func (r *charsetReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		buf := make([]byte, len(p))
+		n, err := r.reader.Read(buf)
+		if n > 0 {
+			r.pending = r.encoder.Encode(buf[:n])
+		}
+		if err != nil && len(r.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// applyCharset 连接配置了非 UTF-8 字符集时转换终端的输入输出,录像等钩子处理的都是 UTF-8 数据
+func applyCharset(s *SshConn, stdout, stderr io.Writer, stdin io.Reader) (io.Writer, io.Writer, io.Reader) {
+	if utils.IsUtf8Charset(s.Charset) {
+		return stdout, stderr, stdin
+	}
+	outDecoder, err := utils.NewCharsetDecoder(s.Charset)
+	if err != nil {
+		slog.Error("NewCharsetDecoder error:", "charset", s.Charset, "err_msg", err.Error())
+		return stdout, stderr, stdin
+	}
+	errDecoder, _ := utils.NewCharsetDecoder(s.Charset)
+	encoder, _ := utils.NewCharsetEncoder(s.Charset)
+	return &charsetWriter{writer: stdout, decoder: outDecoder},
+		&charsetWriter{writer: stderr, decoder: errDecoder},
+		&charsetReader{reader: stdin, encoder: encoder}
+}
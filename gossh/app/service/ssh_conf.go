@@ -1,19 +1,47 @@
 package service
 
 import (
+	"errors"
 	"gossh/app/model"
 	"gossh/gin"
+	"log/slog"
 	"strconv"
 )
 
 func ConfCreate(c *gin.Context) {
 	var config model.SshConf
 	if err := c.ShouldBind(&config); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
 	config.Uid = c.GetUint("uid")
-	err := config.Create(&config)
+	tags, err := normalizeTags(config.Tags)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	config.Tags = tags
+	if err := checkConnHooks(config); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkWolConf(config); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkConnProxy(config); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkSecretRefs(c.GetUint("uid"), config.Pwd, config.CertData, config.CertPwd); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkHostQuota(config.Uid, 1); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	err = config.Create(&config)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
@@ -37,33 +65,54 @@ func ConfFindByID(c *gin.Context) {
 }
 
 func ConfFindAll(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10000"))
-	if err != nil {
-		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
-		return
-	}
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	q, err := parsePageQuery(c)
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
 
 	var config model.SshConf
-	data, err := config.FindAll(offset, limit, c.GetUint("uid"))
+	data, total, err := config.FindPage(q, c.GetUint("uid"))
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
 }
 
 func ConfUpdateById(c *gin.Context) {
 	var config model.SshConf
 	if err := c.ShouldBind(&config); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	tags, err := normalizeTags(config.Tags)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	config.Tags = tags
+	if err := checkConnHooks(config); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkWolConf(config); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkConnProxy(config); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := checkSecretRefs(c.GetUint("uid"), config.Pwd, config.CertData, config.CertPwd); err != nil {
 		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
 		return
 	}
-	err := config.UpdateById(config.ID, c.GetUint("uid"), &config)
+	err = config.UpdateById(config.ID, c.GetUint("uid"), &config)
+	if errors.Is(err, model.ErrVersionConflict) {
+		c.JSON(409, gin.H{"code": 3, "msg": "数据已被其他人修改,请刷新后重试"})
+		return
+	}
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
@@ -79,11 +128,103 @@ func ConfDeleteById(c *gin.Context) {
 		return
 	}
 	var config model.SshConf
+	conf, err := config.FindByID(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
 	err = config.DeleteByID(uint(id), c.GetUint("uid"))
 	if err != nil {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
+	// 级联删除在后台执行,返回任务ID查询进度
+	if c.Query("cascade") == "Y" {
+		job := startConfCascade([]model.SshConf{conf}, c.GetUint("uid"))
+		c.JSON(200, gin.H{"code": 0, "msg": "ok", "job_id": job.Id})
+		return
+	}
 	// c.JSON(200, gin.H{"code": 0, "msg": "ok"})
 	ConfFindAll(c)
 }
+
+// ConfBulkDelete POST 批量删除主机配置,放入回收站,cascade 为 Y 时在后台删除录像等关联数据
+func ConfBulkDelete(c *gin.Context) {
+	type Param struct {
+		Ids     []uint `json:"ids" binding:"required,min=1,max=10000"`
+		Cascade string `json:"cascade" binding:"omitempty,oneof=Y N"`
+	}
+	var param Param
+	if err := c.ShouldBindJSON(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	uid := c.GetUint("uid")
+	var config model.SshConf
+	list, err := config.FindByIDs(param.Ids, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	count, err := config.DeleteByIDs(param.Ids, uid)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("conn_conf bulk delete", "uid", uid, "count", count, "cascade", param.Cascade)
+	if param.Cascade == "Y" && len(list) > 0 {
+		job := startConfCascade(list, uid)
+		c.JSON(200, gin.H{"code": 0, "msg": "ok", "count": count, "job_id": job.Id})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "count": count})
+}
+
+// ConfBulkUpsert PUT 按 external_id 同步主机配置,用于基础设施即代码工具管理主机清单
+func ConfBulkUpsert(c *gin.Context) {
+	type Param struct {
+		DryRun string          `json:"dry_run" binding:"omitempty,oneof=Y N"`
+		List   []model.SshConf `json:"list" binding:"max=10000,dive"`
+	}
+	var param Param
+	if err := c.ShouldBindJSON(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	for i, item := range param.List {
+		if item.ExternalId == "" {
+			c.JSON(200, gin.H{"code": 1, "msg": "external_id 不能为空:" + item.Name})
+			return
+		}
+		tags, err := normalizeTags(item.Tags)
+		if err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": item.Name + ":" + err.Error()})
+			return
+		}
+		param.List[i].Tags = tags
+		if err := checkSecretRefs(c.GetUint("uid"), item.Pwd, item.CertData, item.CertPwd); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": item.Name + ":" + err.Error()})
+			return
+		}
+	}
+
+	var config model.SshConf
+	if param.DryRun != "Y" {
+		// 按预览结果检查新增后的主机数量
+		preview, err := config.BulkUpsert(c.GetUint("uid"), param.List, true)
+		if err == nil {
+			err = checkHostQuota(c.GetUint("uid"), len(preview.Created)-len(preview.Deleted))
+		}
+		if err != nil {
+			c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+			return
+		}
+	}
+	result, err := config.BulkUpsert(c.GetUint("uid"), param.List, param.DryRun == "Y")
+	if err != nil {
+		slog.Error("ConfBulkUpsert error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": result})
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gossh/app/config"
+	"gossh/app/middleware"
 	"gossh/app/model"
 	"gossh/app/utils"
 	"gossh/crypto/ssh"
@@ -13,6 +17,7 @@ import (
 	"log/slog"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -31,6 +36,9 @@ type SshConn struct {
 	// 客户端IP
 	ClientIP string `json:"client_ip"`
 
+	// 实际连接的地址
+	Endpoint string `json:"endpoint"`
+
 	//ssh客户端
 	sshClient *ssh.Client
 
@@ -41,7 +49,49 @@ type SshConn struct {
 	sshSession *ssh.Session
 
 	// websocket 连接
-	ws *websocket.Conn
+	ws *termWs
+
+	// 终端窗口大小
+	size *termSize
+
+	// 会话时间线,连接成功后创建
+	timeline *sessionTimeline
+
+	// 终端数据流钩子
+	hooks []StreamHook
+
+	// 后台标签页输出合并
+	throttle *outputThrottle
+
+	// 终端输出缓冲区
+	output *outputBuffer
+
+	// 服务端保留的最近输出,创建会话时设置,之后只读
+	scrollback *scrollbackHook
+
+	// 已发送长时间会话通知
+	longNotified bool
+
+	// 首次发现超出访问时间段的时间
+	outOfWindowAt time.Time
+
+	// 管理员监看,创建会话时设置,之后只读
+	supervise *superviseHook
+
+	// 空闲锁屏
+	lock *lockHook
+
+	// X11 转发,主机配置开启时创建
+	x11 *x11Forward
+
+	// 接入终端的一次性随机数
+	binding *sessionBinding
+
+	// 用户所属租户
+	tenantId uint
+
+	// 连接时用户输入的私钥密码,只在内存中使用,连接后清空
+	passphrase string
 }
 
 // MarshalJSON 重写序列化方法
@@ -57,6 +107,7 @@ func (s *SshConn) MarshalJSON() ([]byte, error) {
 		CreatedAt      uint   ` json:"created_at"`
 		UpdatedAt      uint   ` json:"updated_at"`
 		DeletedAt      uint   ` json:"deleted_at"`
+		DroppedBytes   int64  `json:"dropped_bytes"`
 	}{
 		Alias:          (Alias)(*s),
 		LastActiveTime: s.LastActiveTime.Format("2006-01-02 15:04:05"),
@@ -67,66 +118,87 @@ func (s *SshConn) MarshalJSON() ([]byte, error) {
 		CreatedAt:      0,
 		UpdatedAt:      0,
 		DeletedAt:      0,
+		DroppedBytes:   s.droppedBytes(),
 	})
 }
 
+// droppedBytes 终端输出丢弃的字节数
+func (s *SshConn) droppedBytes() int64 {
+	if s.output == nil {
+		return 0
+	}
+	return s.output.Dropped()
+}
+
 // 连接主机
-func (s *SshConn) connect(clientIp string) error {
+func (s *SshConn) connect(ctx context.Context, clientIp string) error {
+	return s.connectWith(ctx, clientIp, nil)
+}
+
+// connectWith 连接主机,challenge 不为空时支持 keyboard-interactive 认证
+func (s *SshConn) connectWith(ctx context.Context, clientIp string, challenge ssh.KeyboardInteractiveChallenge) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			slog.Error("ssh connect error:", "err_msg", err)
+		if e := recover(); e != nil {
+			utils.ReportPanic(e, utils.PanicContext{Source: "connect", ClientIp: clientIp, Uid: s.Uid})
+			err = fmt.Errorf("ssh connect error: %v", e)
 		}
 	}()
 	s.ClientIP = clientIp
 
-	config := ssh.ClientConfig{
-		User: s.User,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(s.Pwd),
-		},
-		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		},
-		Timeout: 30 * time.Second,
+	conf := s.SshConf
+	if s.passphrase != "" {
+		withPassphrase := *conf
+		withPassphrase.CertPwd = s.passphrase
+		conf = &withPassphrase
+		defer func() {
+			s.passphrase = ""
+		}()
 	}
-
-	// 证书认证方式
-	if s.AuthType == "cert" {
-		privateKeyPassword := []byte(s.CertPwd)
-		privateKeyBytes := []byte(s.CertData)
-		if s.CertPwd != "" {
-			// 使用证书空密码登陆
-			signer, err := ssh.ParsePrivateKeyWithPassphrase(privateKeyBytes, privateKeyPassword)
-			if err != nil {
-				slog.Error("ParsePrivateKeyWithPassphrase error:", "err_msg", err.Error())
-				return err
-			}
-			config.Auth = []ssh.AuthMethod{
-				ssh.PublicKeys(signer),
-			}
-		} else {
-			// 使用证书有证书密码登陆
-			signer, err := ssh.ParsePrivateKey(privateKeyBytes)
-			if err != nil {
-				slog.Error("ParsePrivateKey error:", "err_msg", err.Error())
-				return err
-			}
-			config.Auth = []ssh.AuthMethod{
-				ssh.PublicKeys(signer),
-			}
+	config, err := sshClientConfig(conf)
+	if err != nil {
+		return err
+	}
+	dialConf := s.SshConf
+	if challenge != nil {
+		config.Auth = append(config.Auth, ssh.KeyboardInteractive(challenge))
+		config.Timeout = interactiveTimeout
+		// 终端中的认证提示只能逐个回答,不并行连接
+		if dialConf.FailoverMode == "parallel" {
+			sequential := *dialConf
+			sequential.FailoverMode = "order"
+			dialConf = &sequential
 		}
 	}
 
-	addr := fmt.Sprintf("%s:%d", s.Address, s.Port)
-	if s.NetType == "tcp6" {
-		addr = fmt.Sprintf("[%s]:%d", s.Address, s.Port)
+	// 部分主机需要先敲门或调用接口打开防火墙
+	if err := runConnHook(s.SshConf, hookPreConnect); err != nil {
+		return err
 	}
-	sshClient, err := ssh.Dial(s.NetType, addr, &config)
+
+	// 主地址不可用时尝试备用地址,开启自动唤醒时唤醒后重试
+	sshClient, endpoint, err := dialWithWake(ctx, dialConf, config)
 	if err != nil {
 		return err
 	}
+	s.Endpoint = endpoint
+	if s.ID != 0 {
+		var sshConf model.SshConf
+		if err := sshConf.UpdateLastEndpoint(s.ID, s.Uid, endpoint); err != nil {
+			slog.Error("UpdateLastEndpoint error:", "err_msg", err.Error())
+		}
+	}
 
 	s.sshClient = sshClient
+
+	// 后台采集主机信息
+	if s.ID != 0 {
+		go func(id uint) {
+			if _, err := collectFacts(sshClient, id); err != nil {
+				slog.Error("collectFacts error:", "err_msg", err.Error())
+			}
+		}(s.ID)
+	}
+
 	//使用sshClient构建sftpClient
 	var sftpClient *sftp.Client
 	if sftpClient, err = sftp.NewClient(sshClient); err != nil {
@@ -140,35 +212,159 @@ func (s *SshConn) connect(clientIp string) error {
 		return err
 	}
 	s.sshSession = sshSession
+	logAccess(s, model.AccessConnect, "", 0)
+	s.logConnect()
 	return nil
 }
 
+// sshClientConfig 根据主机配置生成ssh客户端配置
+func sshClientConfig(conf *model.SshConf) (*ssh.ClientConfig, error) {
+	// 凭据引用在连接时解析,解析结果不保存
+	conf, err := resolveConfSecrets(conf)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User: conf.User,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(conf.Pwd),
+			// 部分主机只开放 keyboard-interactive 方式的密码认证
+			ssh.KeyboardInteractive(passwordChallenge(conf.Pwd)),
+		},
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		},
+	}
+	applyConnTuning(conf, config)
+	algos, err := userAlgos(conf.Uid)
+	if err != nil {
+		return nil, err
+	}
+	algos.apply(config)
+
+	// 证书认证方式
+	if conf.AuthType == "cert" {
+		signer, err := parsePrivateKey(conf)
+		if err != nil {
+			return nil, err
+		}
+		if signer, err = algos.signer(signer); err != nil {
+			return nil, err
+		}
+		config.Auth = []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		}
+	}
+
+	// CA签发的用户证书认证方式
+	if conf.AuthType == "ssh_cert" {
+		signer, err := parsePrivateKey(conf)
+		if err != nil {
+			return nil, err
+		}
+		if signer, err = algos.signer(signer); err != nil {
+			return nil, err
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(conf.UserCert))
+		if err != nil {
+			slog.Error("ParseAuthorizedKey error:", "err_msg", err.Error())
+			return nil, err
+		}
+		cert, ok := pub.(*ssh.Certificate)
+		if !ok || cert.CertType != ssh.UserCert {
+			return nil, errors.New("user_cert is not a ssh user certificate")
+		}
+		certSigner, err := ssh.NewCertSigner(cert, signer)
+		if err != nil {
+			slog.Error("NewCertSigner error:", "err_msg", err.Error())
+			return nil, err
+		}
+		config.Auth = []ssh.AuthMethod{
+			ssh.PublicKeys(certSigner),
+		}
+	}
+
+	// keyboard-interactive 的提示由用户在终端中回答
+	if conf.AuthType == "interactive" {
+		config.Auth = []ssh.AuthMethod{
+			ssh.Password(conf.Pwd),
+		}
+	}
+	return config, nil
+}
+
+// parsePrivateKey 解析配置中的私钥
+func parsePrivateKey(conf *model.SshConf) (ssh.Signer, error) {
+	privateKeyBytes := []byte(conf.CertData)
+	if conf.CertPwd != "" {
+		// 使用有密码的证书登陆
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(privateKeyBytes, []byte(conf.CertPwd))
+		if err != nil {
+			slog.Error("ParsePrivateKeyWithPassphrase error:", "err_msg", err.Error())
+		}
+		return signer, err
+	}
+	// 使用无密码的证书登陆
+	signer, err := ssh.ParsePrivateKey(privateKeyBytes)
+	if err != nil {
+		slog.Error("ParsePrivateKey error:", "err_msg", err.Error())
+	}
+	return signer, err
+}
+
 // RunTerminal 运行一个终端
-func (s *SshConn) RunTerminal(shell string, stdout, stderr io.Writer, stdin io.Reader, w, h int, ws *websocket.Conn) error {
+func (s *SshConn) RunTerminal(shell string, stdout, stderr io.Writer, stdin io.Reader, w, h int, ws *termWs) error {
 	defer func() {
 		DeleteOnlineClient(s.SessionId)
 		if err := recover(); err != nil {
-			slog.Error("RunTerminal error:", "err_msg", err)
+			utils.ReportPanic(err, utils.PanicContext{Source: "RunTerminal", ClientIp: s.ClientIP, Uid: s.Uid})
 		}
 	}()
 
 	s.ws = ws
+	s.size = newTermSize(s, w, h)
+	defer s.size.Close()
+	limits := config.DefaultConfig.Limits
+	s.output = newOutputBuffer(stdout, limits.OutputBuffer, limits.OutputPolicy == "drop")
+	s.throttle = newOutputThrottle(s.output)
+	stdout, stderr = s.throttle, s.throttle
+	s.hooks = newStreamHooks(s)
+	for _, hook := range s.hooks {
+		if _, ok := hook.(*recordHook); ok {
+			ws.Event(TermEventRecorded, "")
+		}
+	}
+	if len(s.hooks) > 0 {
+		writer := &streamWriter{conn: s, writer: stdout, hooks: s.hooks}
+		stdout, stderr = writer, writer
+		stdin = &streamReader{conn: s, reader: stdin, hooks: s.hooks}
+	}
+	// 登录后注入用户的 shell 配置,再执行连接配置的启动命令
+	if script := loadProfileScript(s) + initCmdScript(s); script != "" {
+		stdin = io.MultiReader(strings.NewReader(script), stdin)
+	}
+	stdout, stderr, stdin = applyCharset(s, stdout, stderr, stdin)
+	applyTermCaps(s)
+	applySetEnv(s)
+	startX11(s)
 	s.sshSession.Stdout = stdout
 	s.sshSession.Stderr = stderr
 	s.sshSession.Stdin = stdin
 	modes := ssh.TerminalModes{}
 	if err := s.sshSession.RequestPty(s.PtyType, h, w, modes); err != nil {
 		slog.Error("sshSession.RequestPty error:", "err_msg", err.Error())
-		_ = websocket.Message.Send(ws, "sshSession.RequestPty error:"+err.Error())
+		ws.Notice("sshSession.RequestPty error:" + err.Error())
 		return err
 	}
 
 	err := s.sshSession.Run(shell)
 	if err != nil {
-		slog.Error("sshSession.Run error:", err.Error())
-		_ = websocket.Message.Send(ws, "sshSession.Run error:"+err.Error())
+		s.setCloseReason("error: " + err.Error())
+		slog.Error("sshSession.Run error:", "err_msg", err.Error())
+		ws.Notice("sshSession.Run error:" + err.Error())
 		return err
 	}
+	s.setCloseReason("shell exited")
 	return nil
 }
 
@@ -176,7 +372,7 @@ func (s *SshConn) RunTerminal(shell string, stdout, stderr io.Writer, stdin io.R
 func (s *SshConn) ResizeWindow(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
-			slog.Error("ResizeWindow recover error:", "err_msg", err)
+			utils.ReportPanic(err, middleware.PanicContext(c, "ResizeWindow"))
 		}
 	}()
 	w, err := strconv.Atoi(c.Query("w"))
@@ -208,11 +404,11 @@ func (s *SshConn) ResizeWindow(c *gin.Context) {
 		return
 	}
 
-	err = conn.sshSession.WindowChange(h, w)
-	if err != nil {
-		DeleteOnlineClient(sessionId)
-		slog.Error("sshSession.WindowChange error:", "err_msg", err.Error())
+	if conn.size == nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "terminal not running"})
+		return
 	}
+	conn.size.Set(w, h)
 	str := fmt.Sprintf("W:%d;H:%d\n", w, h)
 	c.JSON(200, gin.H{"code": 0, "data": str, "msg": "ok"})
 	return
@@ -225,26 +421,12 @@ func ResizeWindow(c *gin.Context) {
 
 func NewSshConn(c *gin.Context) {
 	// WebSock 连接 SSH
-	websocket.Handler(func(ws *websocket.Conn) {
-		sessionId := ws.Request().URL.Query().Get("session_id")
-		defer DeleteOnlineClient(sessionId)
-		w, err := strconv.Atoi(ws.Request().URL.Query().Get("w"))
-		if err != nil || (w < 40 || w > 8192) {
-			_ = websocket.Message.Send(ws, "connect error window width !!!")
-			DeleteOnlineClient(sessionId)
-			return
-		}
-		h, err := strconv.Atoi(ws.Request().URL.Query().Get("h"))
-		if err != nil || (h < 2 || h > 4096) {
-			_ = websocket.Message.Send(ws, "connect error window height !!!")
-			DeleteOnlineClient(sessionId)
-			return
-		}
-
+	websocket.Server{Handshake: termHandshake, Handler: func(ws *websocket.Conn) {
+		query := ws.Request().URL.Query()
+		sessionId := query.Get("session_id")
 		cli, ok := OnlineClients.Load(sessionId)
 		if !ok || cli == nil {
 			_ = websocket.Message.Send(ws, "session_id not exists !!!")
-			DeleteOnlineClient(sessionId)
 			return
 		}
 
@@ -254,19 +436,83 @@ func NewSshConn(c *gin.Context) {
 			DeleteOnlineClient(sessionId)
 			return
 		}
-		err = conn.RunTerminal(conn.Shell, ws, ws, ws, w, h, ws)
+
+		// 校验会话归属和一次性随机数,校验失败不影响原会话
+		err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP())
+		if err == nil && !conn.binding.consume(query.Get("nonce")) {
+			err = errors.New("invalid nonce")
+		}
 		if err != nil {
-			_ = websocket.Message.Send(ws, "connect error:"+err.Error())
+			slog.Warn("ssh conn attach rejected", "sid", sessionId, "client_ip", c.RemoteIP(), "err_msg", err.Error())
+			_ = websocket.Message.Send(ws, "session binding error !!!")
+			return
+		}
+		// 终端已在运行,开启会话保持时重新接入
+		if conn.ws != nil {
+			reattachTerm(conn, ws, query)
+			return
+		}
+		defer DeleteOnlineClient(sessionId)
+		term := newTermWs(ws, conn)
+
+		w, err := strconv.Atoi(query.Get("w"))
+		if err != nil || (w < 40 || w > 8192) {
+			term.Notice("connect error window width !!!")
+			DeleteOnlineClient(sessionId)
+			return
+		}
+		h, err := strconv.Atoi(query.Get("h"))
+		if err != nil || (h < 2 || h > 4096) {
+			term.Notice("connect error window height !!!")
 			DeleteOnlineClient(sessionId)
 			return
 		}
-	}).ServeHTTP(c.Writer, c.Request)
+
+		sendInitBanner(conn, term)
+		sendProdBanner(conn, term)
+
+		// 需要审批的连接,等待管理员审批通过后再启动终端
+		if needApproval(conn) {
+			if err := waitApproval(conn, term); err != nil {
+				term.Notice("approval error:" + err.Error())
+				DeleteOnlineClient(sessionId)
+				return
+			}
+		}
+		if conn.sshClient == nil && needPassphrase(conn.SshConf) {
+			if err := promptPassphrase(conn, term); err != nil {
+				term.Notice("\r\nconnect error:" + err.Error())
+				return
+			}
+		}
+		if conn.sshClient == nil {
+			if err := checkSecretRefs(conn.Uid, conn.Pwd); err != nil {
+				term.Notice("\r\nconnect error:" + err.Error())
+				return
+			}
+			pwd, err := resolveSecret(conn.Pwd)
+			if err != nil {
+				term.Notice("\r\nconnect error:" + err.Error())
+				return
+			}
+			if err := conn.connectWith(c.Request.Context(), conn.ClientIP, wsChallenge(term, pwd)); err != nil {
+				term.Notice("\r\nconnect error:" + err.Error())
+				return
+			}
+		}
+		err = conn.RunTerminal(conn.Shell, term, term, term, w, h, term)
+		if err != nil {
+			term.Notice("connect error:" + err.Error())
+			DeleteOnlineClient(sessionId)
+			return
+		}
+	}}.ServeHTTP(c.Writer, c.Request)
 }
 
 func CreateSessionId(c *gin.Context) {
 	var conn SshConn
 	if err := c.ShouldBind(&conn); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
 
@@ -278,22 +524,74 @@ func CreateSessionId(c *gin.Context) {
 	}
 
 	conn.SessionId = sessionId
+	conn.Uid = c.GetUint("uid")
+	conn.binding = newSessionBinding()
+	var user model.SshUser
+	if u, err := user.FindByID(conn.Uid); err == nil {
+		conn.tenantId = u.TenantId
+	}
+
+	// 不允许覆盖其他用户的会话
+	if cli, ok := OnlineClients.Load(sessionId); ok {
+		if old, ok := cli.(*SshConn); ok && old.Uid != conn.Uid {
+			c.JSON(200, gin.H{"code": 1, "msg": "session_id already exists"})
+			return
+		}
+	}
+
+	if err := checkSessionLimit(conn.Uid, conn.tenantId, sessionId); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+
+	if err := checkStorageQuota(conn.Uid, conn.tenantId); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+
+	if !isAccessAllowed(conn.Uid) {
+		c.JSON(200, gin.H{"code": 1, "msg": "当前时间不在允许访问的时间段内"})
+		return
+	}
+
+	if err := checkProdAccess(&conn, c.Query("confirm")); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+
+	if err := checkCredCheckout(&conn); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
 	conn.LastActiveTime = time.Now()
 	conn.StartTime = time.Now()
+	// 监看和最近输出在保存到在线列表之前创建,其他请求读取时不需要加锁
+	conn.supervise = newSupervise()
+	conn.scrollback = newScrollback(conn.Uid)
+
+	// keyboard-interactive 认证需要用户回答提示,未保存密码的加密私钥需要用户输入私钥密码,在接入终端时再连接
+	// 需要审批的连接在审批通过后再连接,审批前会话不能执行命令、使用 sftp 或隧道
+	if conn.AuthType == "interactive" || needPassphrase(conn.SshConf) || needApproval(&conn) {
+		conn.ClientIP = c.RemoteIP()
+		OnlineClients.Store(sessionId, &conn)
+		c.JSON(200, gin.H{"code": 0, "data": sessionId, "nonce": conn.binding.nonce, "msg": "ok"})
+		return
+	}
 
-	err := conn.connect(c.RemoteIP())
+	err := conn.connect(c.Request.Context(), c.RemoteIP())
 	if err != nil {
 		c.JSON(200, gin.H{"code": 1, "msg": "CreateSessionId error:" + err.Error()})
 		return
 	}
 
 	OnlineClients.Store(sessionId, &conn)
-	c.JSON(200, gin.H{"code": 0, "data": sessionId, "msg": "ok"})
+	c.JSON(200, gin.H{"code": 0, "data": sessionId, "nonce": conn.binding.nonce, "msg": "ok"})
 }
 
 func Disconnect(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
+			utils.ReportPanic(err, middleware.PanicContext(c, "Disconnect"))
 			c.JSON(200, gin.H{
 				"code": 1,
 				"msg":  "delete connect error",
@@ -309,6 +607,9 @@ func Disconnect(c *gin.Context) {
 		})
 		return
 	}
+	if conn, err := getSshConn(sessionId); err == nil {
+		conn.setCloseReason("client disconnect")
+	}
 	DeleteOnlineClient(sessionId)
 	c.JSON(200, gin.H{
 		"code": 0,
@@ -320,10 +621,12 @@ func ExecCommand(c *gin.Context) {
 	type Param struct {
 		SessionId string `form:"session_id" binding:"required,min=10" json:"session_id"`
 		Cmd       string `form:"cmd" binding:"required,min=1" json:"cmd"`
+		Timeout   uint   `form:"timeout" binding:"lte=86400" json:"timeout"`
+		Confirm   string `form:"confirm" json:"confirm"`
 	}
 	var param Param
 	if err := c.ShouldBind(&param); err != nil {
-		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		bindError(c, 2, err)
 		return
 	}
 
@@ -334,31 +637,35 @@ func ExecCommand(c *gin.Context) {
 	}
 
 	conn, ok := cli.(*SshConn)
-	if !ok || conn == nil {
+	if !ok || conn == nil || conn.sshClient == nil {
 		c.JSON(200, gin.H{"code": 4, "msg": "conn not exists"})
 		return
 	}
 
-	//创建ssh-session
-	session, err := conn.sshClient.NewSession()
-	if err != nil {
-		c.JSON(200, gin.H{"code": 5, "msg": "create session error"})
+	// 生产环境主机每次执行命令都需要确认
+	if err := checkProdAccess(conn, param.Confirm); err != nil {
+		c.JSON(200, gin.H{"code": 8, "msg": err.Error()})
 		return
 	}
-	defer func(session *ssh.Session) {
-		_ = session.Close()
-	}(session)
 
-	//执行命令
-	out, err := session.CombinedOutput(param.Cmd)
+	out, err := execOnConn(conn, param.Cmd, param.Timeout)
+	if errors.Is(err, errExecSession) {
+		c.JSON(200, gin.H{"code": 5, "msg": "create session error"})
+		return
+	}
+	if timeoutErr, ok := err.(*ExecTimeoutError); ok {
+		slog.Warn("exec cmd timeout", "sid", param.SessionId, "timeout", timeoutErr.Timeout, "pgid", timeoutErr.Pgid, "killed", timeoutErr.Killed)
+		c.JSON(200, gin.H{"code": 7, "msg": "exec cmd timeout", "data": out, "error": timeoutErr})
+		return
+	}
 	if err != nil {
-		c.JSON(200, gin.H{"code": 6, "msg": "exec cmd error", "data": string(out)})
+		c.JSON(200, gin.H{"code": 6, "msg": "exec cmd error", "data": out})
 		return
 	}
 
 	c.JSON(200, gin.H{
 		"code": 0,
 		"msg":  "ok",
-		"data": string(out),
+		"data": out,
 	})
 }
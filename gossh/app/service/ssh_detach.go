@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/config"
+	"gossh/gin"
+	"gossh/websocket"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var errTermDetached = errors.New("terminal detached")
+
+// detach websocket 断开后保留终端,未开启会话保持时返回 false
+// ws 已被新的连接替换时不做处理
+func (t *termWs) detach(ws *websocket.Conn) bool {
+	if !t.persist {
+		return false
+	}
+	t.attachMu.Lock()
+	defer t.attachMu.Unlock()
+	if t.ws == ws {
+		_ = ws.Close()
+		t.ws = nil
+		t.detachedAt = time.Now()
+		t.attached = make(chan struct{})
+		close(t.done)
+		slog.Info("ssh session detached", "sid", t.conn.SessionId)
+	}
+	return true
+}
+
+// waitAttach 等待重新接入,会话关闭时返回 false
+func (t *termWs) waitAttach() bool {
+	t.attachMu.Lock()
+	if t.ws != nil {
+		t.attachMu.Unlock()
+		return true
+	}
+	attached := t.attached
+	t.attachMu.Unlock()
+	select {
+	case <-attached:
+		return true
+	case <-t.closed:
+		return false
+	}
+}
+
+// attach 接入新的 websocket,已有接入的 websocket 时关闭原连接,先发送 replay 再发送新的输出
+// 返回的通道在该 websocket 断开或被替换时关闭,会话已关闭时返回 nil
+func (t *termWs) attach(ws *websocket.Conn, proto string, replay []byte) <-chan struct{} {
+	t.attachMu.Lock()
+	defer t.attachMu.Unlock()
+	select {
+	case <-t.closed:
+		return nil
+	default:
+	}
+	if t.ws != nil {
+		_ = t.ws.Close()
+		close(t.done)
+	} else {
+		close(t.attached)
+	}
+	t.ws, t.proto = ws, proto
+	t.detachedAt = time.Time{}
+	t.done = make(chan struct{})
+	if proto == "" {
+		_, _ = ws.Write(replay)
+		return t.done
+	}
+	_ = sendTermMsg(ws, termMsg{T: "hello", V: 1, Protocol: proto, SessionId: t.conn.SessionId,
+		Features: []string{"data", "resize", "ping", "event"}})
+	if len(replay) > 0 {
+		_ = sendTermMsg(ws, termMsg{T: "data", D: string(replay)})
+	}
+	return t.done
+}
+
+// detachedTime 断开的时间,接入中或终端未启动时返回零值
+func (t *termWs) detachedTime() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	t.attachMu.Lock()
+	defer t.attachMu.Unlock()
+	return t.detachedAt
+}
+
+// reattachTerm 重新接入运行中的终端,恢复最近的输出后阻塞到该 websocket 断开
+func reattachTerm(conn *SshConn, ws *websocket.Conn, query url.Values) {
+	w, err := strconv.Atoi(query.Get("w"))
+	if err != nil || (w < 40 || w > 8192) {
+		_ = websocket.Message.Send(ws, "connect error window width !!!")
+		return
+	}
+	h, err := strconv.Atoi(query.Get("h"))
+	if err != nil || (h < 2 || h > 4096) {
+		_ = websocket.Message.Send(ws, "connect error window height !!!")
+		return
+	}
+	var replay []byte
+	if conn.scrollback != nil {
+		replay = conn.scrollback.Bytes()
+	}
+	done := conn.ws.attach(ws, termProto(ws), replay)
+	if done == nil {
+		return
+	}
+	conn.LastActiveTime = time.Now()
+	if conn.size != nil {
+		conn.size.Set(w, h)
+	}
+	slog.Info("ssh session reattached", "sid", conn.SessionId, "client_ip", conn.ClientIP)
+	select {
+	case <-done:
+	case <-conn.ws.closed:
+	}
+}
+
+// DetachedSession 用户运行中的终端会话
+type DetachedSession struct {
+	SessionId  string `json:"session_id"`
+	ConfId     uint   `json:"conf_id"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	User       string `json:"user"`
+	StartTime  string `json:"start_time"`
+	Detached   bool   `json:"detached"`
+	DetachedAt string `json:"detached_at"`
+	ExpireAt   string `json:"expire_at"`
+}
+
+// SshSessions GET 当前用户运行中的终端会话,包括已断开等待重新接入的会话
+func SshSessions(c *gin.Context) {
+	uid := c.GetUint("uid")
+	timeout := config.DefaultConfig.SessionClean.DetachTimeout
+	list := make([]DetachedSession, 0)
+	OnlineClients.Range(func(key, value any) bool {
+		conn, ok := value.(*SshConn)
+		if !ok || conn == nil || conn.Uid != uid || conn.ws == nil {
+			return true
+		}
+		item := DetachedSession{
+			SessionId: conn.SessionId,
+			ConfId:    conn.ID,
+			Name:      conn.Name,
+			Address:   conn.Address,
+			User:      conn.User,
+			StartTime: conn.StartTime.Format(time.DateTime),
+		}
+		if at := conn.ws.detachedTime(); !at.IsZero() {
+			item.Detached = true
+			item.DetachedAt = at.Format(time.DateTime)
+			item.ExpireAt = at.Add(timeout).Format(time.DateTime)
+		}
+		list = append(list, item)
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].StartTime > list[j].StartTime })
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list})
+}
+
+// SshSessionAttach POST 重新接入会话,返回一次性随机数,客户端使用随机数连接 /api/ssh/conn
+// 会话已有接入的窗口时,新窗口接入后原窗口断开
+func SshSessionAttach(c *gin.Context) {
+	type Param struct {
+		SessionId string `form:"session_id" binding:"required" json:"session_id"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	conn, err := getSshConn(param.SessionId)
+	if err != nil || conn == nil || conn.ws == nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "the client is disconnected"})
+		return
+	}
+	if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if !conn.ws.persist {
+		c.JSON(200, gin.H{"code": 3, "msg": "未开启会话保持"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": param.SessionId, "nonce": conn.binding.renew()})
+}
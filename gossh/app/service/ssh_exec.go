@@ -0,0 +1,170 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/crypto/ssh"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 命令输出第一行返回远程进程组ID的标记
+const execPidMarker = "__GOSSH_EXEC_PID__="
+
+// ExecTimeoutError 命令执行超时
+type ExecTimeoutError struct {
+	Timeout uint   `json:"timeout"`
+	Pgid    int    `json:"pgid"`
+	Killed  bool   `json:"killed"`
+	Reason  string `json:"reason"`
+}
+
+func (e *ExecTimeoutError) Error() string {
+	return fmt.Sprintf("exec cmd timeout after %ds", e.Timeout)
+}
+
+// execTimeout 计算命令超时时间(秒),0 表示不限制
+// 主机配置优先于策略配置,请求的超时时间不能超过最大值
+func execTimeout(conf *model.SshConf, requested uint) uint {
+	policy, _ := userPolicy(conf.Uid)
+
+	def, maxTimeout := conf.ExecTimeout, conf.ExecMaxTimeout
+	if def == 0 {
+		def = policy.ExecTimeout
+	}
+	if maxTimeout == 0 {
+		maxTimeout = policy.ExecMaxTimeout
+	}
+
+	timeout := requested
+	if timeout == 0 {
+		timeout = def
+	}
+	if maxTimeout > 0 && (timeout == 0 || timeout > maxTimeout) {
+		timeout = maxTimeout
+	}
+	return timeout
+}
+
+// execOutput 合并标准输出和错误输出,并从第一行解析远程进程ID
+type execOutput struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	head    []byte
+	pidDone bool
+	pid     int
+}
+
+func (w *execOutput) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pidDone {
+		return w.buf.Write(p)
+	}
+	w.head = append(w.head, p...)
+	idx := bytes.IndexByte(w.head, '\n')
+	if idx < 0 && len(w.head) < 64 {
+		return len(p), nil
+	}
+	w.pidDone = true
+	if idx >= 0 && bytes.HasPrefix(w.head, []byte(execPidMarker)) {
+		w.pid, _ = strconv.Atoi(string(w.head[len(execPidMarker):idx]))
+		w.buf.Write(w.head[idx+1:])
+	} else {
+		w.buf.Write(w.head)
+	}
+	w.head = nil
+	return len(p), nil
+}
+
+func (w *execOutput) Pid() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.pid
+}
+
+func (w *execOutput) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.pidDone {
+		return string(w.head)
+	}
+	return w.buf.String()
+}
+
+// killProcessGroup 在新的会话中结束远程进程组
+// 非交互式 shell 由 sshd 以会话首进程启动,其 PID 就是进程组ID,子进程默认在同一进程组
+func killProcessGroup(client *ssh.Client, pgid int) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	cmd := fmt.Sprintf("kill -TERM -- -%d 2>/dev/null; sleep 2; kill -KILL -- -%d 2>/dev/null; true", pgid, pgid)
+	return session.Run(cmd)
+}
+
+// 创建执行命令的会话失败
+var errExecSession = errors.New("create session error")
+
+// execOnConn 在已连接的主机上新建会话执行命令
+func execOnConn(conn *SshConn, cmd string, timeout uint) (string, error) {
+	session, err := conn.sshClient.NewSession()
+	if err != nil {
+		slog.Error("exec NewSession error:", "sid", conn.SessionId, "err_msg", err.Error())
+		return "", errExecSession
+	}
+	defer func(session *ssh.Session) {
+		_ = session.Close()
+	}(session)
+	return runExec(conn.sshClient, session, cmd, execTimeout(conn.SshConf, timeout))
+}
+
+// runExec 执行命令,超时后结束远程进程组
+func runExec(client *ssh.Client, session *ssh.Session, cmd string, timeout uint) (string, error) {
+	out := &execOutput{pidDone: timeout == 0}
+	session.Stdout = out
+	session.Stderr = out
+	if timeout == 0 {
+		err := session.Run(cmd)
+		return out.String(), err
+	}
+
+	wrapped := fmt.Sprintf("printf '%s%%d\\n' $$\n%s", execPidMarker, cmd)
+	if err := session.Start(wrapped); err != nil {
+		return "", err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	timer := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return out.String(), err
+	case <-timer.C:
+	}
+
+	timeoutErr := &ExecTimeoutError{Timeout: timeout, Pgid: out.Pid()}
+	if timeoutErr.Pgid > 1 {
+		if err := killProcessGroup(client, timeoutErr.Pgid); err != nil {
+			timeoutErr.Reason = err.Error()
+			slog.Error("kill remote process group error:", "pgid", timeoutErr.Pgid, "err_msg", err.Error())
+		} else {
+			timeoutErr.Killed = true
+		}
+	} else {
+		timeoutErr.Reason = "remote pid unknown"
+	}
+	_ = session.Signal(ssh.SIGKILL)
+	_ = session.Close()
+	return out.String(), timeoutErr
+}
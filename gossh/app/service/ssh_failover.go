@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/crypto/ssh"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 按延迟排序时的探测超时时间
+	failoverProbeTimeout = 3 * time.Second
+	// 并行连接时启动下一个地址的间隔
+	parallelDialDelay = 250 * time.Millisecond
+)
+
+// sshEndpoint 连接目标地址
+type sshEndpoint struct {
+	network string
+	addr    string
+	latency time.Duration
+}
+
+// sshEndpoints 主地址和备用地址列表,备用地址格式 host 或 host:port,逗号或换行分隔
+func sshEndpoints(conf *model.SshConf) []sshEndpoint {
+	list := []sshEndpoint{{
+		network: conf.NetType,
+		addr:    net.JoinHostPort(conf.Address, strconv.Itoa(int(conf.Port))),
+	}}
+	for _, item := range strings.FieldsFunc(conf.FallbackAddrs, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' '
+	}) {
+		host, port, err := net.SplitHostPort(item)
+		if err != nil {
+			host, port = strings.Trim(item, "[]"), strconv.Itoa(int(conf.Port))
+		}
+		list = append(list, sshEndpoint{network: "tcp", addr: net.JoinHostPort(host, port)})
+	}
+	return list
+}
+
+// sortByLatency 并发探测TCP连接延迟,配置了代理时经过代理探测,不可达的地址排在最后
+func sortByLatency(conf *model.SshConf, list []sshEndpoint) {
+	var wg sync.WaitGroup
+	for i := range list {
+		wg.Add(1)
+		go func(e *sshEndpoint) {
+			defer wg.Done()
+			start := time.Now()
+			dialer := net.Dialer{Timeout: failoverProbeTimeout}
+			conn, err := dialConn(context.Background(), conf, &dialer, e.network, e.addr)
+			if err != nil {
+				e.latency = time.Duration(1<<63 - 1)
+				return
+			}
+			e.latency = time.Since(start)
+			_ = conn.Close()
+		}(&list[i])
+	}
+	wg.Wait()
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].latency < list[j].latency
+	})
+}
+
+// dialFailover 依次尝试连接目标地址,parallel 模式下同时尝试,返回客户端和实际使用的地址
+func dialFailover(ctx context.Context, conf *model.SshConf, config *ssh.ClientConfig) (*ssh.Client, string, error) {
+	ctx, span := utils.StartSpan(ctx, "ssh.dial", utils.SpanKindClient)
+	defer span.End()
+	span.SetAttr("ssh.conf_id", conf.ID)
+	span.SetAttr("ssh.user", conf.User)
+	span.SetAttr("ssh.auth_type", conf.AuthType)
+
+	list := sshEndpoints(conf)
+	if len(list) > 1 && conf.FailoverMode == "latency" {
+		sortByLatency(conf, list)
+	}
+	// keyboard-interactive 认证需要用户回答提示,按顺序连接
+	if len(list) > 1 && conf.FailoverMode == "parallel" && conf.AuthType != "interactive" {
+		client, addr, err := dialParallel(ctx, conf, list, config)
+		if err != nil {
+			span.SetError(err)
+			return nil, "", err
+		}
+		span.SetAttr("server.address", addr)
+		return client, addr, nil
+	}
+
+	var errs []error
+	for _, e := range list {
+		client, err := dialEndpoint(ctx, conf, e, config)
+		if err == nil {
+			span.SetAttr("server.address", e.addr)
+			return client, e.addr, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.addr, err))
+	}
+	err := errors.Join(errs...)
+	span.SetError(err)
+	return nil, "", err
+}
+
+// dialEndpoint 连接一个目标地址
+func dialEndpoint(ctx context.Context, conf *model.SshConf, e sshEndpoint, config *ssh.ClientConfig) (*ssh.Client, error) {
+	_, attempt := utils.StartSpan(ctx, "ssh.connect", utils.SpanKindClient)
+	attempt.SetAttr("network.transport", e.network)
+	attempt.SetAttr("server.address", e.addr)
+	client, err := sshDial(ctx, conf, e.network, e.addr, config)
+	attempt.SetError(err)
+	attempt.End()
+	if err != nil {
+		slog.Warn("ssh dial failed", "addr", e.addr, "err_msg", err.Error())
+	}
+	return client, err
+}
+
+// dialResult 并行连接的结果
+type dialResult struct {
+	client *ssh.Client
+	addr   string
+	err    error
+}
+
+// dialParallel 按顺序间隔 parallelDialDelay 启动连接,上一个地址失败时立即尝试下一个,使用最先连接成功的地址
+// 其余连接取消,取消前已经成功的连接直接关闭
+// 每个地址都使用相同的凭据认证,多个地址指向同一主机时会计入该主机的认证失败次数
+// 主机限制认证失败次数(如 fail2ban、MaxAuthTries)时应使用 order 或 latency 模式
+func dialParallel(ctx context.Context, conf *model.SshConf, list []sshEndpoint, config *ssh.ClientConfig) (*ssh.Client, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(list))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var errs []error
+	next, pending := 0, 0
+	for {
+		start := timer.C
+		if next >= len(list) {
+			start = nil
+		}
+		select {
+		case <-start:
+			go func(e sshEndpoint) {
+				client, err := dialEndpoint(ctx, conf, e, config)
+				results <- dialResult{client: client, addr: e.addr, err: err}
+			}(list[next])
+			next++
+			pending++
+			timer.Reset(parallelDialDelay)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go closeDialResults(results, pending)
+				return r.client, r.addr, nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", r.addr, r.err))
+			if next < len(list) {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(0)
+			} else if pending == 0 {
+				return nil, "", errors.Join(errs...)
+			}
+		case <-ctx.Done():
+			go closeDialResults(results, pending)
+			return nil, "", ctx.Err()
+		}
+	}
+}
+
+// closeDialResults 关闭未被使用的连接
+func closeDialResults(results chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if r := <-results; r.err == nil {
+			_ = r.client.Close()
+		}
+	}
+}
+
+// dialSshConf 使用主机配置建立不带终端的ssh连接,用于后台执行命令
+func dialSshConf(conf *model.SshConf, timeout time.Duration) (*ssh.Client, error) {
+	config, err := sshClientConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.ConnectTimeout == 0 {
+		config.Timeout = timeout
+	}
+	if err := runConnHook(conf, hookPreConnect); err != nil {
+		return nil, err
+	}
+	client, _, err := dialWithWake(context.Background(), conf, config)
+	return client, err
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 钩子类型
+const (
+	ConnHookHttp   = "http"
+	ConnHookScript = "script"
+	ConnHookKnock  = "knock"
+)
+
+// 钩子执行的时机
+const (
+	hookPreConnect     = "pre_connect"
+	hookPostDisconnect = "post_disconnect"
+)
+
+// 钩子执行后等待的最长时间(毫秒)
+const connHookMaxWait = 10000
+
+// ConnHook 主机配置中的钩子定义,JSON 格式保存在 pre_connect、post_disconnect 中
+// url、body、args 支持 {{host}}、{{port}}、{{user}}、{{name}} 等变量
+type ConnHook struct {
+	Type    string            `json:"type"`
+	Url     string            `json:"url"`     // http 请求地址
+	Method  string            `json:"method"`  // http 请求方法,默认 POST
+	Headers map[string]string `json:"headers"` // http 请求头
+	Body    string            `json:"body"`    // http 请求内容
+	Script  string            `json:"script"`  // 脚本名称,需要在配置的脚本列表中
+	Args    []string          `json:"args"`    // 脚本参数
+	Ports   []uint16          `json:"ports"`   // 按顺序敲门的端口
+	Proto   string            `json:"proto"`   // 敲门使用 tcp 或 udp,默认 tcp
+	Delay   uint              `json:"delay"`   // 敲门间隔(毫秒),默认 100
+	Wait    uint              `json:"wait"`    // 执行后等待的时间(毫秒),用于等待防火墙规则生效
+}
+
+// parseConnHook 解析并校验钩子定义,为空时返回 nil
+func parseConnHook(data string) (*ConnHook, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var hook ConnHook
+	if err := json.Unmarshal([]byte(data), &hook); err != nil {
+		return nil, fmt.Errorf("钩子格式错误: %v", err)
+	}
+	if hook.Wait > connHookMaxWait || hook.Delay > connHookMaxWait {
+		return nil, fmt.Errorf("等待时间不能超过 %d 毫秒", connHookMaxWait)
+	}
+	conf := config.DefaultConfig.ConnHook
+	switch hook.Type {
+	case ConnHookHttp:
+		u, err := url.Parse(hook.Url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, errors.New("钩子地址错误")
+		}
+		if !connHookHostAllowed(u) {
+			return nil, fmt.Errorf("钩子地址不在允许的主机列表中: %s", u.Host)
+		}
+	case ConnHookScript:
+		if conf.ScriptDir == "" || !slices.Contains(conf.Scripts, hook.Script) || strings.ContainsAny(hook.Script, `/\`) {
+			return nil, fmt.Errorf("脚本不在允许的列表中: %s", hook.Script)
+		}
+	case ConnHookKnock:
+		if len(hook.Ports) == 0 || len(hook.Ports) > 16 {
+			return nil, errors.New("敲门端口数量需要在 1 到 16 之间")
+		}
+		if hook.Proto != "" && hook.Proto != "tcp" && hook.Proto != "udp" {
+			return nil, fmt.Errorf("不支持的协议 %s", hook.Proto)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的钩子类型 %s", hook.Type)
+	}
+	return &hook, nil
+}
+
+// checkConnHooks 保存主机配置前校验钩子定义
+func checkConnHooks(conf model.SshConf) error {
+	if _, err := parseConnHook(conf.PreConnect); err != nil {
+		return fmt.Errorf("pre_connect: %w", err)
+	}
+	if _, err := parseConnHook(conf.PostDisconnect); err != nil {
+		return fmt.Errorf("post_disconnect: %w", err)
+	}
+	return nil
+}
+
+// runConnHook 执行主机配置的钩子,连接前的钩子失败时不连接,断开后的钩子只记录错误
+// 允许列表在执行时重新校验,配置文件中移除的脚本和地址不再执行
+func runConnHook(conf *model.SshConf, stage string) error {
+	data := conf.PreConnect
+	if stage == hookPostDisconnect {
+		data = conf.PostDisconnect
+	}
+	hook, err := parseConnHook(data)
+	if err != nil || hook == nil {
+		return err
+	}
+	timeout := config.DefaultConfig.ConnHook.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn := &SshConn{SshConf: conf}
+	switch hook.Type {
+	case ConnHookHttp:
+		err = runHttpHook(ctx, conn, hook)
+	case ConnHookScript:
+		err = runScriptHook(ctx, conn, hook, stage)
+	case ConnHookKnock:
+		err = runKnockHook(ctx, conn, hook)
+	}
+	if err != nil {
+		slog.Error("conn hook error:", "stage", stage, "type", hook.Type, "conf_id", conf.ID, "address", conf.Address, "err_msg", err.Error())
+		return fmt.Errorf("%s hook: %w", stage, err)
+	}
+	slog.Info("conn hook", "stage", stage, "type", hook.Type, "conf_id", conf.ID, "address", conf.Address, "cost", time.Since(start).String())
+	if hook.Wait > 0 {
+		time.Sleep(time.Duration(hook.Wait) * time.Millisecond)
+	}
+	return nil
+}
+
+// runPostDisconnect 断开后在后台执行钩子
+func runPostDisconnect(conf *model.SshConf) {
+	if conf == nil || conf.PostDisconnect == "" {
+		return
+	}
+	go func() {
+		defer utils.RecoverPanic("runPostDisconnect")
+		_ = runConnHook(conf, hookPostDisconnect)
+	}()
+}
+
+// connHookHostAllowed 钩子地址的主机是否在允许的列表中
+func connHookHostAllowed(u *url.URL) bool {
+	hosts := config.DefaultConfig.ConnHook.HttpHosts
+	return slices.Contains(hosts, u.Host) || slices.Contains(hosts, u.Hostname())
+}
+
+// connHookClient 钩子请求使用的客户端,跳转后的地址同样需要在允许的主机列表中
+var connHookClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !connHookHostAllowed(req.URL) {
+			return fmt.Errorf("钩子跳转地址不在允许的主机列表中: %s", req.URL.Host)
+		}
+		return nil
+	},
+}
+
+func runHttpHook(ctx context.Context, conn *SshConn, hook *ConnHook) error {
+	target, err := renderSnippet(hook.Url, conn, nil)
+	if err != nil {
+		return err
+	}
+	// 替换变量后重新校验地址
+	u, err := url.Parse(target)
+	if err != nil || !connHookHostAllowed(u) {
+		return errors.New("钩子地址不在允许的主机列表中")
+	}
+	body, err := renderSnippet(hook.Body, conn, nil)
+	if err != nil {
+		return err
+	}
+	method := hook.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range hook.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := connHookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runScriptHook 执行本地脚本,主机信息同时通过环境变量传入
+func runScriptHook(ctx context.Context, conn *SshConn, hook *ConnHook, stage string) error {
+	args := make([]string, 0, len(hook.Args))
+	for _, arg := range hook.Args {
+		value, err := renderSnippet(arg, conn, nil)
+		if err != nil {
+			return err
+		}
+		args = append(args, value)
+	}
+	cmd := exec.CommandContext(ctx, path.Join(config.DefaultConfig.ConnHook.ScriptDir, hook.Script), args...)
+	cmd.Dir = config.DefaultConfig.ConnHook.ScriptDir
+	cmd.Env = append(os.Environ(),
+		"GOSSH_HOOK="+stage,
+		"GOSSH_CONF_ID="+strconv.Itoa(int(conn.ID)),
+		"GOSSH_HOST="+conn.Address,
+		"GOSSH_PORT="+strconv.Itoa(int(conn.Port)),
+		"GOSSH_USER="+conn.User,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, utils.TruncateString(strings.TrimSpace(string(out)), 512))
+	}
+	return nil
+}
+
+// runKnockHook 按顺序连接主机的端口,端口关闭导致的连接失败是正常的
+func runKnockHook(ctx context.Context, conn *SshConn, hook *ConnHook) error {
+	proto, delay := hook.Proto, hook.Delay
+	if proto == "" {
+		proto = "tcp"
+	}
+	if delay == 0 {
+		delay = 100
+	}
+	network := proto + strings.TrimPrefix(conn.NetType, "tcp")
+	dialer := net.Dialer{Timeout: time.Duration(delay) * time.Millisecond}
+	for i, port := range hook.Ports {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(delay) * time.Millisecond):
+			}
+		}
+		c, err := dialer.DialContext(ctx, network, net.JoinHostPort(conn.Address, strconv.Itoa(int(port))))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		if proto == "udp" {
+			_, _ = c.Write([]byte{0})
+		}
+		_ = c.Close()
+	}
+	return nil
+}
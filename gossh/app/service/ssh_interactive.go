@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/model"
+	"gossh/crypto/ssh"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 等待用户回答 keyboard-interactive 提示的超时时间
+const interactiveTimeout = 2 * time.Minute
+
+// passwordChallenge 使用配置的密码回答服务端的密码提示
+func passwordChallenge(pwd string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range questions {
+			if echos[i] || !isPasswordPrompt(questions[i]) {
+				return nil, errors.New("keyboard-interactive prompt not supported: " + questions[i])
+			}
+			answers[i] = pwd
+		}
+		return answers, nil
+	}
+}
+
+func isPasswordPrompt(question string) bool {
+	return strings.Contains(strings.ToLower(question), "password")
+}
+
+// wsChallenge 将服务端的提示(如 OTP、Duo)显示在终端中,并读取用户的回答
+// 第一次密码提示使用配置的密码自动回答
+func wsChallenge(ws *termWs, pwd string) ssh.KeyboardInteractiveChallenge {
+	pwdUsed := pwd == ""
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if name != "" {
+			ws.Notice(name + "\r\n")
+		}
+		if instruction != "" {
+			ws.Notice(strings.ReplaceAll(instruction, "\n", "\r\n") + "\r\n")
+		}
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			if !pwdUsed && !echos[i] && isPasswordPrompt(question) {
+				pwdUsed = true
+				answers[i] = pwd
+				continue
+			}
+			ws.Notice(question)
+			answer, err := readTerminalLine(ws, echos[i])
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+}
+
+// 私钥密码输入错误时允许重试的次数
+const passphraseAttempts = 3
+
+// needPassphrase 私钥已加密并且没有保存私钥密码
+func needPassphrase(conf *model.SshConf) bool {
+	if (conf.AuthType != "cert" && conf.AuthType != "ssh_cert") || conf.CertPwd != "" {
+		return false
+	}
+	resolved, err := resolveConfSecrets(conf)
+	if err != nil {
+		return false
+	}
+	_, err = ssh.ParsePrivateKey([]byte(resolved.CertData))
+	var missing *ssh.PassphraseMissingError
+	return errors.As(err, &missing)
+}
+
+// promptPassphrase 在终端中提示用户输入私钥密码,校验通过后保存在连接中用于本次连接
+func promptPassphrase(conn *SshConn, ws *termWs) error {
+	resolved, err := resolveConfSecrets(conn.SshConf)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < passphraseAttempts; i++ {
+		ws.Notice("Enter passphrase for key: ")
+		passphrase, err := readTerminalLine(ws, false)
+		if err != nil {
+			return err
+		}
+		if _, err := ssh.ParsePrivateKeyWithPassphrase([]byte(resolved.CertData), []byte(passphrase)); err == nil {
+			conn.passphrase = passphrase
+			return nil
+		}
+		ws.Notice("Bad passphrase, try again.\r\n")
+	}
+	return errors.New("too many bad passphrase attempts")
+}
+
+// readTerminalLine 从终端读取一行输入,echo 为 false 时不回显
+func readTerminalLine(ws *termWs, echo bool) (string, error) {
+	_ = ws.SetReadDeadline(time.Now().Add(interactiveTimeout))
+	defer func() {
+		_ = ws.SetReadDeadline(time.Time{})
+	}()
+
+	var line []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := ws.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		// 本次读取需要回显的内容
+		var out []byte
+		for _, b := range buf[:n] {
+			switch b {
+			case '\r', '\n':
+				ws.Notice(string(out) + "\r\n")
+				return string(line), nil
+			case 0x03: // Ctrl+C
+				ws.Notice(string(out) + "^C\r\n")
+				return "", errors.New("canceled by user")
+			case 0x7f, 0x08: // 退格
+				if len(line) == 0 {
+					continue
+				}
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+				if echo {
+					out = append(out, "\b \b"...)
+				}
+			default:
+				if b < 0x20 {
+					continue
+				}
+				line = append(line, b)
+				if echo {
+					out = append(out, b)
+				}
+			}
+		}
+		if len(out) > 0 {
+			ws.Notice(string(out))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"gossh/app/model"
+	"gossh/crypto/ssh"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// 主机配置为 0 时使用的默认值
+const (
+	defaultAliveInterval  = 30 * time.Second
+	defaultAliveCountMax  = 3
+	defaultConnectTimeout = 30 * time.Second
+)
+
+// aliveInterval 应用层心跳间隔,-1 时不发送心跳
+func aliveInterval(conf *model.SshConf) time.Duration {
+	switch {
+	case conf.AliveInterval < 0:
+		return 0
+	case conf.AliveInterval == 0:
+		return defaultAliveInterval
+	}
+	return time.Duration(conf.AliveInterval) * time.Second
+}
+
+// tcpKeepalive TCP keepalive 间隔,0 时使用系统默认值,-1 时关闭
+func tcpKeepalive(conf *model.SshConf) time.Duration {
+	if conf.TcpKeepalive < 0 {
+		return -1
+	}
+	return time.Duration(conf.TcpKeepalive) * time.Second
+}
+
+// applyConnTuning 按主机配置设置连接超时和重新协商密钥的数据量
+func applyConnTuning(conf *model.SshConf, config *ssh.ClientConfig) {
+	config.Timeout = defaultConnectTimeout
+	if conf.ConnectTimeout > 0 {
+		config.Timeout = time.Duration(conf.ConnectTimeout) * time.Second
+	}
+	if conf.RekeyLimit > 0 {
+		config.RekeyThreshold = uint64(conf.RekeyLimit) << 20
+	}
+}
+
+// sshDial 建立TCP连接(按配置经过代理)并完成ssh握手,握手同样受连接超时限制,连接成功后按配置发送心跳
+func sshDial(ctx context.Context, conf *model.SshConf, network, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: config.Timeout, KeepAlive: tcpKeepalive(conf)}
+	conn, err := dialConn(ctx, conf, &dialer, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if config.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(config.Timeout))
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(c, chans, reqs)
+	if interval := aliveInterval(conf); interval > 0 {
+		countMax := int(conf.AliveCountMax)
+		if countMax == 0 {
+			countMax = defaultAliveCountMax
+		}
+		go keepAlive(client, addr, interval, countMax)
+	}
+	return client, nil
+}
+
+// keepAlive 定时发送 keepalive@openssh.com 请求,连续 countMax 次没有响应时关闭连接
+// 主机对未知请求回复失败也视为连接正常,连接关闭后退出
+func keepAlive(client *ssh.Client, addr string, interval time.Duration, countMax int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	closed := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(closed)
+	}()
+	reply := make(chan error, 1)
+	waiting, missed := false, 0
+	for {
+		select {
+		case <-closed:
+			return
+		case err := <-reply:
+			if err != nil {
+				return
+			}
+			waiting, missed = false, 0
+		case <-ticker.C:
+			if waiting {
+				missed++
+				if missed >= countMax {
+					slog.Warn("ssh keepalive timeout, closing connection", "addr", addr, "missed", missed)
+					_ = client.Close()
+					return
+				}
+				continue
+			}
+			waiting = true
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				reply <- err
+			}()
+		}
+	}
+}
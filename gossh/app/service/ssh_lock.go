@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 连续解锁失败达到该次数后断开会话
+const maxUnlockFailures = 5
+
+// lockHook 超过策略配置的时间没有键盘输入时锁定终端,SSH 会话保持连接
+// 锁定期间的输入作为登录密码处理,回车后校验,也可以通过 /api/ssh/unlock 解锁
+type lockHook struct {
+	mu       sync.Mutex
+	conn     *SshConn
+	idle     time.Duration
+	timer    *time.Timer
+	locked   bool
+	pwd      []byte
+	failures int
+}
+
+func newLockHook(conn *SshConn) StreamHook {
+	conf, err := userPolicy(conn.Uid)
+	if err != nil || conf.LockIdle == 0 {
+		return nil
+	}
+	hook := &lockHook{conn: conn, idle: time.Duration(conf.LockIdle) * time.Minute}
+	hook.timer = time.AfterFunc(hook.idle, hook.lock)
+	conn.lock = hook
+	return hook
+}
+
+func (h *lockHook) lock() {
+	h.mu.Lock()
+	if h.locked {
+		h.mu.Unlock()
+		return
+	}
+	h.locked, h.pwd = true, nil
+	h.mu.Unlock()
+	slog.Info("terminal locked", "sid", h.conn.SessionId, "uid", h.conn.Uid)
+	logSessionEvent(h.conn, model.SessionEvent{Kind: model.SessionEventLock})
+	if h.conn.ws != nil {
+		h.conn.ws.Event(TermEventLocked, "\r\n\x1b[33m[终端已锁定,请输入登录密码后回车解锁]\x1b[0m\r\n")
+	}
+}
+
+// Unlock 校验用户的登录密码,成功后解锁并重新计时,连续失败过多时断开会话
+func (h *lockHook) Unlock(pwd string) error {
+	var user model.SshUser
+	u, err := user.FindByID(h.conn.Uid)
+	if err == nil {
+		_, err = user.FindByNameAndPwd(u.Name, pwd)
+	}
+
+	h.mu.Lock()
+	if err != nil {
+		h.failures++
+		failures := h.failures
+		h.mu.Unlock()
+		slog.Warn("terminal unlock failed", "sid", h.conn.SessionId, "uid", h.conn.Uid, "failures", failures)
+		if failures >= maxUnlockFailures {
+			h.conn.setCloseReason("unlock failed")
+			go DeleteOnlineClient(h.conn.SessionId)
+		}
+		return errors.New("密码错误")
+	}
+	h.locked, h.pwd, h.failures = false, nil, 0
+	h.timer.Reset(h.idle)
+	h.mu.Unlock()
+	logSessionEvent(h.conn, model.SessionEvent{Kind: model.SessionEventUnlock})
+	if h.conn.ws != nil {
+		h.conn.ws.Event(TermEventUnlocked, "\r\n\x1b[32m[终端已解锁]\x1b[0m\r\n")
+	}
+	return nil
+}
+
+// OnInput 锁定时不发送输入,将输入作为密码收集,未锁定时重新计时
+func (h *lockHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	h.mu.Lock()
+	if !h.locked {
+		h.timer.Reset(h.idle)
+		h.mu.Unlock()
+		return data, nil
+	}
+	var submit []byte
+	for _, b := range data {
+		switch {
+		case b == '\r' || b == '\n':
+			if submit == nil {
+				submit = append([]byte{}, h.pwd...)
+			}
+			h.pwd = nil
+		case b == 0x7f || b == '\b':
+			if len(h.pwd) > 0 {
+				h.pwd = h.pwd[:len(h.pwd)-1]
+			}
+		case b == 0x03 || b == 0x15:
+			h.pwd = nil
+		case b >= 0x20 && len(h.pwd) < 64:
+			h.pwd = append(h.pwd, b)
+		}
+	}
+	h.mu.Unlock()
+	if submit != nil {
+		if err := h.Unlock(string(submit)); err != nil && conn.ws != nil {
+			conn.ws.Event(TermEventLocked, "\r\n\x1b[31m[密码错误]\x1b[0m\r\n")
+		}
+	}
+	return nil, nil
+}
+
+func (h *lockHook) OnOutput(conn *SshConn, data []byte) []byte {
+	return data
+}
+
+func (h *lockHook) Close() error {
+	h.timer.Stop()
+	return nil
+}
+
+// SshUnlock POST 输入登录密码解锁空闲锁定的终端
+func SshUnlock(c *gin.Context) {
+	type Param struct {
+		SessionId string `form:"session_id" binding:"required,min=1,max=128" json:"session_id"`
+		Pwd       string `form:"pwd" binding:"required,min=1,max=64" json:"pwd"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	conn, err := getSshConn(param.SessionId)
+	if err != nil || conn == nil {
+		c.JSON(200, gin.H{"code": 2, "msg": "the client is disconnected"})
+		return
+	}
+	if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if conn.lock == nil {
+		c.JSON(200, gin.H{"code": 3, "msg": "终端未启用空闲锁定"})
+		return
+	}
+	if err := conn.lock.Unlock(param.Pwd); err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
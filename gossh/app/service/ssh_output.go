@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// 默认输出缓冲区大小
+const outputBufferDefaultSize = 1024 * 1024
+
+// 所有会话累计丢弃的输出字节数
+var outputDroppedTotal atomic.Int64
+
+var errOutputClosed = errors.New("output buffer closed")
+
+// outputBuffer SSH 输出和 websocket 之间的有界环形缓冲区,由单独的协程发送
+// block 模式下缓冲区满时阻塞写入,SSH 通道窗口不再增长,远程程序随之暂停输出
+// drop 模式下缓冲区满时丢弃最早的数据,并在终端提示丢弃的字节数
+type outputBuffer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	writer  io.Writer
+	buf     []byte
+	start   int
+	length  int
+	drop    bool
+	dropped int64
+	pending int64
+	closed  bool
+	err     error
+}
+
+func newOutputBuffer(writer io.Writer, size int, drop bool) *outputBuffer {
+	if size <= 0 {
+		size = outputBufferDefaultSize
+	}
+	b := &outputBuffer{writer: writer, buf: make([]byte, size), drop: drop}
+	b.cond = sync.NewCond(&b.mu)
+	go b.loop()
+	return b
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := len(p)
+	for len(p) > 0 {
+		if b.closed {
+			if b.err != nil {
+				return total - len(p), b.err
+			}
+			return total - len(p), errOutputClosed
+		}
+		free := len(b.buf) - b.length
+		if free == 0 {
+			if !b.drop {
+				b.cond.Wait()
+				continue
+			}
+			// 丢弃最早的数据,最多丢弃本次需要的空间
+			n := min(len(p), b.length)
+			b.start = (b.start + n) % len(b.buf)
+			b.length -= n
+			b.dropped += int64(n)
+			b.pending += int64(n)
+			outputDroppedTotal.Add(int64(n))
+			continue
+		}
+		n := min(free, len(p))
+		end := (b.start + b.length) % len(b.buf)
+		copied := copy(b.buf[end:], p[:n])
+		copy(b.buf, p[copied:n])
+		b.length += n
+		p = p[n:]
+		b.cond.Broadcast()
+	}
+	return total, nil
+}
+
+// take 取出缓冲区中的全部数据和未提示的丢弃字节数
+func (b *outputBuffer) take() ([]byte, int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.length == 0 && !b.closed {
+		b.cond.Wait()
+	}
+	if b.length == 0 {
+		return nil, 0, false
+	}
+	data := make([]byte, b.length)
+	n := copy(data, b.buf[b.start:min(b.start+b.length, len(b.buf))])
+	copy(data[n:], b.buf[:b.length-n])
+	b.start, b.length = 0, 0
+	pending := b.pending
+	b.pending = 0
+	b.cond.Broadcast()
+	return data, pending, true
+}
+
+func (b *outputBuffer) loop() {
+	for {
+		data, dropped, ok := b.take()
+		if !ok {
+			return
+		}
+		if dropped > 0 {
+			data = append([]byte(fmt.Sprintf("\r\n\x1b[33m[输出过快,已丢弃 %d 字节]\x1b[0m\r\n", dropped)), data...)
+		}
+		if _, err := b.writer.Write(data); err != nil {
+			b.mu.Lock()
+			b.err, b.closed = err, true
+			b.cond.Broadcast()
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+// Dropped 累计丢弃的字节数
+func (b *outputBuffer) Dropped() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+func (b *outputBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+	if b.dropped > 0 {
+		slog.Warn("output bytes dropped", "dropped", b.dropped)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"path"
+	"strings"
+)
+
+// 记录输入行的最大长度
+const inputLineMaxSize = 4096
+
+// inputLine 根据用户按键还原当前输入的命令行
+// 只处理常见的编辑键,历史命令和补全等由主机完成的输入无法还原
+type inputLine struct {
+	buf    []rune
+	escape bool
+}
+
+// Write 处理一次输入,返回本次输入中回车提交的命令行
+func (l *inputLine) Write(data []byte) []string {
+	var lines []string
+	for _, r := range string(data) {
+		if l.escape {
+			// 方向键等转义序列以字母或 ~ 结束
+			if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || r == '~' {
+				l.escape = false
+			}
+			continue
+		}
+		switch {
+		case r == 0x1b:
+			l.escape = true
+		case r == '\r' || r == '\n':
+			if len(l.buf) > 0 {
+				lines = append(lines, string(l.buf))
+			}
+			l.buf = l.buf[:0]
+		case r == 0x7f || r == '\b':
+			if len(l.buf) > 0 {
+				l.buf = l.buf[:len(l.buf)-1]
+			}
+		case r == 0x03 || r == 0x15:
+			// Ctrl+C 和 Ctrl+U 清空当前行
+			l.buf = l.buf[:0]
+		case r >= 0x20 && len(l.buf) < inputLineMaxSize:
+			l.buf = append(l.buf, r)
+		}
+	}
+	return lines
+}
+
+// 需要参数的 sudo 和 su 选项
+var (
+	sudoArgFlags   = "gCDhpRrTtU"
+	sudoArgOptions = map[string]bool{
+		"--group": true, "--close-from": true, "--chdir": true, "--host": true, "--prompt": true,
+		"--chroot": true, "--role": true, "--type": true, "--command-timeout": true, "--other-user": true,
+	}
+	suArgFlags   = "cgGsw"
+	suArgOptions = map[string]bool{
+		"--command": true, "--session-command": true, "--shell": true, "--group": true,
+		"--supp-group": true, "--whitelist-environment": true,
+	}
+)
+
+// parseEscalation 解析命令行中的 sudo/su 提权,返回提权后的用户,没有提权时返回空字符串
+func parseEscalation(line string) string {
+	segments := strings.FieldsFunc(line, func(r rune) bool { return r == ';' || r == '&' || r == '|' })
+	for _, segment := range segments {
+		args := strings.Fields(segment)
+		if len(args) == 0 {
+			continue
+		}
+		switch path.Base(args[0]) {
+		case "sudo":
+			return parseSudo(args[1:])
+		case "su":
+			return parseSu(args[1:])
+		}
+	}
+	return ""
+}
+
+// parseSudo 解析 sudo 参数,未指定 -u 时为 root,执行 su 时以 su 的目标用户为准
+// 只使用 -l -v -k -K 且没有命令时不是提权
+func parseSudo(args []string) string {
+	user := "root"
+	query := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			i++
+			if i < len(args) && path.Base(args[i]) == "su" {
+				return parseSu(args[i+1:])
+			}
+			return user
+		case strings.HasPrefix(arg, "--user="):
+			user = strings.TrimPrefix(arg, "--user=")
+		case arg == "--user":
+			if i++; i < len(args) {
+				user = args[i]
+			}
+		case strings.HasPrefix(arg, "--"):
+			if sudoArgOptions[arg] {
+				i++
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j, flag := range arg[1:] {
+				rest := arg[j+2:]
+				if flag == 'u' {
+					if rest == "" && i+1 < len(args) {
+						i++
+						rest = args[i]
+					}
+					user = rest
+					break
+				}
+				if strings.ContainsRune(sudoArgFlags, flag) {
+					if rest == "" {
+						i++
+					}
+					break
+				}
+				if strings.ContainsRune("lvkK", flag) {
+					query = true
+				}
+			}
+		default:
+			if path.Base(arg) == "su" {
+				return parseSu(args[i+1:])
+			}
+			return user
+		}
+	}
+	if query {
+		return ""
+	}
+	return user
+}
+
+// parseSu 解析 su 参数,未指定用户时为 root
+func parseSu(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return "root"
+		case arg == "-":
+		case strings.HasPrefix(arg, "--"):
+			if suArgOptions[arg] {
+				i++
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j, flag := range arg[1:] {
+				if strings.ContainsRune(suArgFlags, flag) {
+					if arg[j+2:] == "" {
+						i++
+					}
+					break
+				}
+			}
+		default:
+			return arg
+		}
+	}
+	return "root"
+}
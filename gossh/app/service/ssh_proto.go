@@ -0,0 +1,285 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"gossh/app/config"
+	"gossh/websocket"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 终端 websocket 协议版本,通过 Sec-WebSocket-Protocol 或 proto 查询参数协商
+// 未协商时使用原始文本协议:客户端发送的文本即终端输入,服务端发送的文本即终端输出
+const termProtoV1Json = "webssh.v1.json"
+
+// 服务端支持的协议,按优先级排列
+var termProtocols = []string{termProtoV1Json}
+
+// termMsg 协议消息信封
+// 客户端发送:data(输入)、resize(调整窗口)、ping(心跳)
+// 服务端发送:hello(协商结果)、data(输出)、pong、resize(服务器上的终端大小)、event(带外通知,如会话录像、DLP 告警)
+type termMsg struct {
+	T         string   `json:"t"`
+	D         string   `json:"d,omitempty"`
+	Cols      int      `json:"cols,omitempty"`
+	Rows      int      `json:"rows,omitempty"`
+	Id        string   `json:"id,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	V         int      `json:"v,omitempty"`
+	Protocol  string   `json:"protocol,omitempty"`
+	SessionId string   `json:"session_id,omitempty"`
+	Features  []string `json:"features,omitempty"`
+}
+
+// 终端带外事件
+const (
+	TermEventRecorded   = "recorded"
+	TermEventDlp        = "dlp"
+	TermEventSecret     = "secret"
+	TermEventTerminated = "terminated"
+	TermEventLocked     = "locked"
+	TermEventUnlocked   = "unlocked"
+	TermEventProd       = "prod"
+	TermEventX11        = "x11"
+)
+
+// pickTermProto 按客户端提供的顺序选择第一个服务端支持的协议
+func pickTermProto(offered []string) string {
+	for _, proto := range offered {
+		for _, supported := range termProtocols {
+			if strings.EqualFold(strings.TrimSpace(proto), supported) {
+				return supported
+			}
+		}
+	}
+	return ""
+}
+
+// termHandshake 校验 Origin 并协商子协议,不支持时不返回 Sec-WebSocket-Protocol
+func termHandshake(config *websocket.Config, req *http.Request) (err error) {
+	config.Origin, err = websocket.Origin(config, req)
+	if err == nil && config.Origin == nil {
+		return errors.New("null origin")
+	}
+	if err != nil {
+		return err
+	}
+	config.Protocol = nil
+	if proto := pickTermProto(strings.Split(req.Header.Get("Sec-Websocket-Protocol"), ",")); proto != "" {
+		config.Protocol = []string{proto}
+	}
+	return nil
+}
+
+// termWs 终端 websocket,按协商的协议编解码消息
+// 开启会话保持时 websocket 断开不结束会话,ws 为空表示已断开,重新接入时替换 ws 和 proto
+type termWs struct {
+	ws    *websocket.Conn
+	proto string
+	conn  *SshConn
+
+	mu      sync.Mutex
+	tail    []byte
+	pending []byte
+
+	// 会话保持
+	persist    bool
+	attachMu   sync.Mutex
+	detachedAt time.Time
+	attached   chan struct{}
+	done       chan struct{}
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// newTermWs 根据握手结果或 proto 查询参数确定协议
+func newTermWs(ws *websocket.Conn, conn *SshConn) *termWs {
+	t := &termWs{ws: ws, conn: conn, proto: termProto(ws), closed: make(chan struct{}), done: make(chan struct{}),
+		persist: config.DefaultConfig.SessionClean.DetachTimeout > 0}
+	if t.proto != "" {
+		_ = t.send(termMsg{T: "hello", V: 1, Protocol: t.proto, SessionId: conn.SessionId,
+			Features: []string{"data", "resize", "ping", "event"}})
+	}
+	return t
+}
+
+// termProto websocket 使用的协议
+func termProto(ws *websocket.Conn) string {
+	if len(ws.Config().Protocol) == 1 {
+		return ws.Config().Protocol[0]
+	}
+	return pickTermProto(strings.Split(ws.Request().URL.Query().Get("proto"), ","))
+}
+
+// current 当前接入的 websocket,断开时返回 nil
+func (t *termWs) current() (*websocket.Conn, string) {
+	t.attachMu.Lock()
+	defer t.attachMu.Unlock()
+	return t.ws, t.proto
+}
+
+func (t *termWs) send(msg termMsg) error {
+	ws, _ := t.current()
+	return sendTermMsg(ws, msg)
+}
+
+func sendTermMsg(ws *websocket.Conn, msg termMsg) error {
+	if ws == nil {
+		return errTermDetached
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return websocket.Message.Send(ws, string(data))
+}
+
+// Read 读取终端输入,处理窗口调整和心跳等控制消息,开启会话保持时断开后等待重新接入
+func (t *termWs) Read(p []byte) (int, error) {
+	for {
+		ws, proto := t.current()
+		if ws != nil {
+			n, err := t.read(ws, proto, p)
+			if err == nil || !t.detach(ws) {
+				return n, err
+			}
+		}
+		if !t.waitAttach() {
+			return 0, io.EOF
+		}
+	}
+}
+
+func (t *termWs) read(ws *websocket.Conn, proto string, p []byte) (int, error) {
+	if proto == "" {
+		return ws.Read(p)
+	}
+	for len(t.pending) == 0 {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return 0, err
+		}
+		var msg termMsg
+		if err := json.Unmarshal(data, &msg); err != nil {
+			slog.Warn("term message decode error:", "sid", t.conn.SessionId, "err_msg", err.Error())
+			continue
+		}
+		switch msg.T {
+		case "data":
+			t.pending = []byte(msg.D)
+		case "resize":
+			t.resize(msg.Cols, msg.Rows)
+		case "ping":
+			t.conn.LastActiveTime = time.Now()
+			_ = sendTermMsg(ws, termMsg{T: "pong", Id: msg.Id})
+		}
+	}
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// resize 调整终端大小,范围与连接参数一致,拖动窗口时的多次调整合并发送
+func (t *termWs) resize(cols, rows int) {
+	if cols < 40 || cols > 8192 || rows < 2 || rows > 4096 || t.conn.size == nil {
+		return
+	}
+	t.conn.size.Set(cols, rows)
+}
+
+// Write 发送终端输出,不完整的 UTF-8 字符留到下次发送,已断开时丢弃输出,最近的输出由 scrollback 保留
+func (t *termWs) Write(p []byte) (int, error) {
+	ws, proto := t.current()
+	if ws == nil && t.persist {
+		return len(p), nil
+	}
+	if proto == "" {
+		if _, err := ws.Write(p); err != nil && !t.detach(ws) {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data := append(t.tail, p...)
+	end := len(data)
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < utf8.RuneSelf {
+			break
+		}
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				end = len(data) - i
+			}
+			break
+		}
+	}
+	t.tail = append([]byte(nil), data[end:]...)
+	if end == 0 {
+		return len(p), nil
+	}
+	if err := sendTermMsg(ws, termMsg{T: "data", D: string(data[:end])}); err != nil && !t.detach(ws) {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Notice 在终端中显示提示文本
+func (t *termWs) Notice(text string) {
+	ws, proto := t.current()
+	if ws == nil {
+		return
+	}
+	if proto == "" {
+		_ = websocket.Message.Send(ws, text)
+		return
+	}
+	_ = sendTermMsg(ws, termMsg{T: "data", D: text})
+}
+
+// Event 发送带外事件,原始文本协议下作为提示文本显示,text 为空时不显示
+func (t *termWs) Event(name, text string) {
+	ws, proto := t.current()
+	if ws == nil {
+		return
+	}
+	if proto == "" {
+		if text != "" {
+			_ = websocket.Message.Send(ws, text)
+		}
+		return
+	}
+	msg := strings.TrimSpace(ansiEscapeRe.ReplaceAllString(text, ""))
+	_ = sendTermMsg(ws, termMsg{T: "event", Name: name, D: msg})
+}
+
+func (t *termWs) SetReadDeadline(deadline time.Time) error {
+	ws, _ := t.current()
+	if ws == nil {
+		return errTermDetached
+	}
+	return ws.SetReadDeadline(deadline)
+}
+
+// Close 关闭会话时调用,结束等待重新接入的读取
+func (t *termWs) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+	t.attachMu.Lock()
+	defer t.attachMu.Unlock()
+	if t.ws == nil {
+		return nil
+	}
+	return t.ws.Close()
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"net"
+	"net/url"
+)
+
+// 主机配置中 proxy 为 direct 时不使用全局代理
+const proxyDirect = "direct"
+
+// connProxy 连接主机使用的代理,不使用代理时返回 nil
+// 只有管理员配置的全局代理密码可以是凭据引用,主机配置中的代理由用户填写,不解析凭据引用
+func connProxy(conf *model.SshConf, host string) (*url.URL, error) {
+	if conf.Proxy == proxyDirect {
+		return nil, nil
+	}
+	if conf.Proxy != "" {
+		return utils.ParseProxyUrl(conf.Proxy)
+	}
+	global := config.DefaultConfig.OutboundProxy
+	if global.Url == "" || utils.MatchNoProxy(host, global.NoProxy) {
+		return nil, nil
+	}
+	proxy, err := utils.ParseProxyUrl(global.Url)
+	if err != nil {
+		return nil, err
+	}
+	if proxy.User != nil {
+		if pwd, ok := proxy.User.Password(); ok && isSecretRef(pwd) {
+			resolved, err := resolveSecret(pwd)
+			if err != nil {
+				return nil, err
+			}
+			proxy.User = url.UserPassword(proxy.User.Username(), resolved)
+		}
+	}
+	return proxy, nil
+}
+
+// checkConnProxy 保存主机配置前校验代理地址
+func checkConnProxy(conf model.SshConf) error {
+	if conf.Proxy == "" || conf.Proxy == proxyDirect {
+		return nil
+	}
+	_, err := utils.ParseProxyUrl(conf.Proxy)
+	return err
+}
+
+// dialConn 按代理配置建立到目标地址的TCP连接
+func dialConn(ctx context.Context, conf *model.SshConf, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	proxy, err := connProxy(conf, host)
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return utils.DialProxy(ctx, dialer, proxy, addr)
+}
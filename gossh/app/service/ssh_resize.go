@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 调整窗口大小的合并间隔,拖动窗口时间隔内只向服务器发送最后一次的大小
+const resizeDelay = 100 * time.Millisecond
+
+// termSize 终端窗口大小,cols/rows 为已发送到服务器的大小
+type termSize struct {
+	mu          sync.Mutex
+	conn        *SshConn
+	cols, rows  int
+	pendingCols int
+	pendingRows int
+	timer       *time.Timer
+}
+
+func newTermSize(conn *SshConn, cols, rows int) *termSize {
+	return &termSize{conn: conn, cols: cols, rows: rows, pendingCols: cols, pendingRows: rows}
+}
+
+// Get 当前终端窗口大小
+func (t *termSize) Get() (int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cols, t.rows
+}
+
+// Set 请求调整窗口大小,间隔内的多次请求合并为一次
+func (t *termSize) Set(cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pendingCols, t.pendingRows = cols, rows
+	if t.timer == nil {
+		t.timer = time.AfterFunc(resizeDelay, t.apply)
+	}
+}
+
+// apply 发送最后一次请求的大小,与当前大小相同时不发送
+func (t *termSize) apply() {
+	t.mu.Lock()
+	cols, rows := t.pendingCols, t.pendingRows
+	t.timer = nil
+	if cols == t.cols && rows == t.rows {
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+
+	session := t.conn.sshSession
+	if session == nil {
+		return
+	}
+	if err := session.WindowChange(rows, cols); err != nil {
+		slog.Error("sshSession.WindowChange error:", "err_msg", err.Error())
+		return
+	}
+	t.mu.Lock()
+	t.cols, t.rows = cols, rows
+	t.mu.Unlock()
+	logSessionEvent(t.conn, model.SessionEvent{Kind: model.SessionEventResize, Detail: fmt.Sprintf("%dx%d", cols, rows)})
+	// 通知客户端服务器上的终端大小,其他窗口接入同一会话时据此同步
+	if ws := t.conn.ws; ws != nil && ws.proto != "" {
+		_ = ws.send(termMsg{T: "resize", Cols: cols, Rows: rows})
+	}
+}
+
+func (t *termSize) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// SshSize GET 获取会话当前的终端大小,重新接入的客户端据此同步窗口
+func SshSize(c *gin.Context) {
+	conn, err := getSshConn(c.Query("session_id"))
+	if err != nil || conn == nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "the client is disconnected"})
+		return
+	}
+	if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if conn.size == nil {
+		c.JSON(200, gin.H{"code": 3, "msg": "terminal not running"})
+		return
+	}
+	cols, rows := conn.size.Get()
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{"cols": cols, "rows": rows}})
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"gossh/gin"
+	"sync"
+	"unicode/utf8"
+)
+
+// scrollbackHook 在服务端保留会话最近的终端输出,会话关闭时清零
+type scrollbackHook struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+}
+
+// newScrollback 按用户策略创建保留的最近输出,在会话保存到在线列表之前创建,策略未开启时返回 nil
+func newScrollback(uid uint) *scrollbackHook {
+	conf, err := userPolicy(uid)
+	if err != nil || conf.ScrollbackSize == 0 {
+		return nil
+	}
+	size := int(conf.ScrollbackSize) * 1024
+	return &scrollbackHook{buf: make([]byte, 0, size), size: size}
+}
+
+func newScrollbackHook(conn *SshConn) StreamHook {
+	if conn.scrollback == nil {
+		return nil
+	}
+	return conn.scrollback
+}
+
+func (h *scrollbackHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (h *scrollbackHook) OnOutput(conn *SshConn, data []byte) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.buf == nil {
+		return data
+	}
+	keep := data
+	if len(keep) > h.size {
+		keep = keep[len(keep)-h.size:]
+	}
+	if over := len(h.buf) + len(keep) - h.size; over > 0 {
+		// 超出部分从头部丢弃,丢弃的数据先清零
+		n := copy(h.buf, h.buf[over:])
+		clear(h.buf[n:])
+		h.buf = h.buf[:n]
+	}
+	h.buf = append(h.buf, keep...)
+	return data
+}
+
+// Bytes 返回保留的输出副本,跳过被截断的多字节字符
+func (h *scrollbackHook) Bytes() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	data := h.buf
+	for len(data) > 0 && !utf8.RuneStart(data[0]) {
+		data = data[1:]
+	}
+	return append([]byte(nil), data...)
+}
+
+func (h *scrollbackHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.buf[:cap(h.buf)])
+	h.buf = nil
+	return nil
+}
+
+// SshScrollback GET 获取会话最近的终端输出,用于刷新页面后恢复历史
+func SshScrollback(c *gin.Context) {
+	sessionId := c.Query("session_id")
+	cli, ok := OnlineClients.Load(sessionId)
+	if !ok || cli == nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "the client is disconnected"})
+		return
+	}
+	conn, ok := cli.(*SshConn)
+	if !ok || conn == nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "to type SshConn error"})
+		return
+	}
+	if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if conn.scrollback == nil {
+		c.JSON(200, gin.H{"code": 3, "msg": "scrollback is disabled"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": string(conn.scrollback.Bytes())})
+}
@@ -3,6 +3,9 @@ package service
 import (
 	"errors"
 	"fmt"
+	"gossh/app/middleware"
+	"gossh/app/model"
+	"gossh/app/utils"
 	"gossh/gin"
 	"io"
 	"log/slog"
@@ -13,6 +16,14 @@ import (
 	"strings"
 )
 
+// sftpSpan 创建 sftp 操作的 span
+func sftpSpan(c *gin.Context, conn *SshConn, op, p string) *utils.Span {
+	_, span := utils.StartSpan(c.Request.Context(), "sftp."+op, utils.SpanKindClient)
+	span.SetAttr("sftp.path", p)
+	span.SetAttr("server.address", conn.Endpoint)
+	return span
+}
+
 func getSshConn(sessionId string) (*SshConn, error) {
 	cli, ok := OnlineClients.Load(sessionId)
 	if !ok {
@@ -31,6 +42,7 @@ func getSshConn(sessionId string) (*SshConn, error) {
 func SftpList(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
+			utils.ReportPanic(err, middleware.PanicContext(c, "SftpList"))
 			c.JSON(200, gin.H{"code": 4, "msg": "读取目录错误"})
 			return
 		}
@@ -43,8 +55,15 @@ func SftpList(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
+	if err := checkSftpPath(conn, dirPath, false); err != nil {
+		c.JSON(200, gin.H{"code": 5, "msg": err.Error()})
+		return
+	}
 
+	span := sftpSpan(c, conn, "read_dir", dirPath)
 	files, err := conn.sftpClient.ReadDir(dirPath)
+	span.SetError(err)
+	span.End()
 	if err != nil {
 		slog.Error("sftp客户端ReadDir错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 3, "msg": "sftp客户端读取目录错误"})
@@ -112,6 +131,7 @@ func SftpList(c *gin.Context) {
 func SftpDownLoad(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
+			utils.ReportPanic(err, middleware.PanicContext(c, "SftpDownLoad"))
 			c.JSON(200, gin.H{"code": 1, "msg": "下载错误"})
 			return
 		}
@@ -128,12 +148,19 @@ func SftpDownLoad(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
 		return
 	}
+	if err := checkSftpPath(conn, fullPath, false); err != nil {
+		c.JSON(200, gin.H{"code": 6, "msg": err.Error()})
+		return
+	}
 
+	span := sftpSpan(c, conn, "download", fullPath)
+	defer span.End()
 	file, err := conn.sftpClient.Open(fullPath)
 	defer func() {
 		_ = file.Close()
 	}()
 	if err != nil {
+		span.SetError(err)
 		slog.Error("sftpClient.Openc错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 4, "msg": "sftp打开文件错误"})
 		return
@@ -151,12 +178,15 @@ func SftpDownLoad(c *gin.Context) {
 	c.Header("Content-Type", "application/octet-stream")
 	//c.Header("Content-Type", "application/x-download")
 	c.Header("Content-Length", fmt.Sprintf("%d", stat.Size()))
-	_, err = file.WriteTo(c.Writer)
+	size, err := file.WriteTo(c.Writer)
+	span.SetAttr("sftp.bytes", size)
 	if err != nil {
+		span.SetError(err)
 		slog.Error("file.WriteTo错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 5, "msg": "下载文件错误"})
 		return
 	}
+	logAccess(conn, model.AccessDownload, fullPath, size)
 	c.Writer.Flush()
 }
 
@@ -164,11 +194,17 @@ func SftpDownLoad(c *gin.Context) {
 func SftpUpload(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
+			utils.ReportPanic(err, middleware.PanicContext(c, "SftpUpload"))
 			c.JSON(200, gin.H{"code": 4, "msg": "上传错误"})
 			return
 		}
 	}()
 
+	// 限制上传大小
+	if !limitUpload(c) {
+		return
+	}
+
 	dstPath := c.PostForm("path")
 	//获取上传的文件组
 	form, err := c.MultipartForm()
@@ -186,34 +222,61 @@ func SftpUpload(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
 		return
 	}
+	if err := checkSftpPath(conn, dstPath, true); err != nil {
+		c.JSON(200, gin.H{"code": 6, "msg": err.Error()})
+		return
+	}
 
 	var ret []string
+	rejected := map[string]string{}
 	for _, file := range files {
+		fileName := file.Filename
+		// 文件名中可能包含上级目录
+		if err := checkSftpPath(conn, path.Join(dstPath, fileName), true); err != nil {
+			continue
+		}
 		srcFile, err := file.Open()
 		if err != nil {
 			continue
 		}
-		fileName := file.Filename
+		// 扫描通过后从头读取文件上传
+		if err := scanUpload(conn, c.ClientIP(), fileName, srcFile); err != nil {
+			rejected[fileName] = err.Error()
+			_ = srcFile.Close()
+			continue
+		}
+		if _, err := srcFile.Seek(0, io.SeekStart); err != nil {
+			_ = srcFile.Close()
+			continue
+		}
+		span := sftpSpan(c, conn, "upload", path.Join(dstPath, fileName))
 		dstFile, err := conn.sftpClient.Create(path.Join(dstPath, fileName))
 		if err != nil {
+			span.SetError(err)
+			span.End()
 			continue
 		}
-		_, err = io.Copy(dstFile, srcFile)
+		size, err := io.Copy(dstFile, srcFile)
+		span.SetAttr("sftp.bytes", size)
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			continue
 		}
 		_ = srcFile.Close()
 		_ = dstFile.Close()
+		logAccess(conn, model.AccessUpload, path.Join(dstPath, fileName), size)
 		ret = append(ret, fileName)
 	}
 	msg := strconv.Itoa(len(ret)) + " 个文件上传成功"
-	c.JSON(200, gin.H{"code": 0, "msg": msg, "data": ret})
+	c.JSON(200, gin.H{"code": 0, "msg": msg, "data": ret, "rejected": rejected})
 }
 
 // SftpDelete DELETE sftp 删除文件或目录
 func SftpDelete(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
+			utils.ReportPanic(err, middleware.PanicContext(c, "SftpDelete"))
 			c.JSON(200, gin.H{"code": 4, "msg": "删除错误"})
 			return
 		}
@@ -227,7 +290,7 @@ func SftpDelete(c *gin.Context) {
 	var body Body
 	if err := c.ShouldBind(&body); err != nil {
 		slog.Error("绑定数据错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "输入数据不合法"})
+		bindError(c, 1, err)
 		return
 	}
 	conn, err := getSshConn(body.SessionId)
@@ -236,8 +299,15 @@ func SftpDelete(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
 		return
 	}
+	if err := checkSftpPath(conn, body.Path, true); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
 
+	span := sftpSpan(c, conn, "remove", body.Path)
 	err = conn.sftpClient.RemoveAll(body.Path)
+	span.SetError(err)
+	span.End()
 	if err != nil {
 		slog.Error("sftpClient.Remove错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 2, "msg": "删除文件错误"})
@@ -250,6 +320,7 @@ func SftpDelete(c *gin.Context) {
 func SftpCreateDir(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
+			utils.ReportPanic(err, middleware.PanicContext(c, "SftpCreateDir"))
 			c.JSON(200, gin.H{"code": 4, "msg": "创建目录错误"})
 			return
 		}
@@ -263,7 +334,7 @@ func SftpCreateDir(c *gin.Context) {
 	var body Body
 	if err := c.ShouldBind(&body); err != nil {
 		slog.Error("绑定数据错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "输入数据不合法"})
+		bindError(c, 1, err)
 		return
 	}
 	conn, err := getSshConn(body.SessionId)
@@ -272,8 +343,15 @@ func SftpCreateDir(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
 		return
 	}
+	if err := checkSftpPath(conn, body.Path, true); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
 
+	span := sftpSpan(c, conn, "mkdir", body.Path)
 	err = conn.sftpClient.MkdirAll(body.Path)
+	span.SetError(err)
+	span.End()
 	if err != nil {
 		slog.Error("sftpClient.MkdirAll错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 2, "msg": "创建目录错误"})
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"gossh/websocket"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 分享链接最长有效时间(分钟)
+const shareMaxExpire = 24 * 60
+
+// shareTokenSign 分享令牌签名,包含会话ID和过期时间
+func shareTokenSign(share model.SessionShare) string {
+	mac := hmac.New(sha256.New, []byte("session_share:"+config.DefaultConfig.JwtSecret))
+	mac.Write([]byte(fmt.Sprintf("%d.%d.%s.%d", share.ID, share.ExpiryAt.ToTime().Unix(), share.SessionId, share.Uid)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newShareToken 生成分享令牌,格式为 分享ID.过期时间.签名
+func newShareToken(share model.SessionShare) string {
+	return fmt.Sprintf("%d.%d.%s", share.ID, share.ExpiryAt.ToTime().Unix(), shareTokenSign(share))
+}
+
+// checkShareToken 校验分享令牌,返回令牌对应的分享记录
+func checkShareToken(token string) (model.SessionShare, error) {
+	var share model.SessionShare
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return share, errors.New("invalid share token")
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return share, errors.New("invalid share token")
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return share, errors.New("invalid share token")
+	}
+	share, err = share.FindByID(uint(id))
+	if err != nil || share.ExpiryAt.ToTime().Unix() != expiry {
+		return share, errors.New("invalid share token")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(shareTokenSign(share))) {
+		return share, errors.New("invalid share token")
+	}
+	return share, checkShareValid(share)
+}
+
+// checkShareValid 分享链接是否已撤销或过期
+func checkShareValid(share model.SessionShare) error {
+	if share.IsRevoked == "Y" {
+		return errors.New("分享链接已撤销")
+	}
+	if time.Now().After(share.ExpiryAt.ToTime()) {
+		return errors.New("分享链接已过期")
+	}
+	return nil
+}
+
+// logShareEvent 在会话时间线中记录分享和访问,会话结束后撤销时也能记录
+func logShareEvent(share model.SessionShare, kind, detail string) {
+	event := model.SessionEvent{
+		SessionId: share.SessionId,
+		Uid:       share.Uid,
+		Kind:      kind,
+		Detail:    utils.TruncateString(detail, 1024),
+		OccurAt:   model.DateTime(time.Now()),
+	}
+	if err := event.Create(&event); err != nil {
+		slog.Error("SessionEvent.Create error:", "err_msg", err.Error())
+	}
+}
+
+// SessionShareCreate POST 为运行中的会话生成只读分享链接,expire 为有效时间(分钟)
+func SessionShareCreate(c *gin.Context) {
+	type Param struct {
+		SessionId string `form:"session_id" binding:"required,min=1,max=64" json:"session_id"`
+		Expire    uint   `form:"expire" binding:"gte=0,lte=1440" json:"expire"`
+		Remark    string `form:"remark" binding:"max=255" json:"remark"`
+	}
+	param := Param{Expire: 60}
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if param.Expire == 0 {
+		param.Expire = 60
+	}
+	conn, err := getSshConn(param.SessionId)
+	if err != nil || conn == nil || conn.supervise == nil {
+		c.JSON(200, gin.H{"code": 2, "msg": "terminal not running"})
+		return
+	}
+	if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	share := model.SessionShare{
+		Uid:       conn.Uid,
+		SessionId: conn.SessionId,
+		ConfId:    conn.ID,
+		Address:   conn.Address,
+		Remark:    param.Remark,
+		IsRevoked: "N",
+		ExpiryAt:  model.DateTime(time.Now().Add(time.Duration(param.Expire) * time.Minute).Truncate(time.Second)),
+	}
+	if err := share.Create(&share); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	token := newShareToken(share)
+	logShareEvent(share, model.SessionEventShare, fmt.Sprintf("create share=%d expire=%s", share.ID, share.ExpiryAt.ToTime().Format(time.DateTime)))
+	slog.Warn("session share created", "sid", conn.SessionId, "uid", conn.Uid, "share_id", share.ID, "client_ip", c.RemoteIP())
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"share": share,
+		"token": token,
+		"url":   "/api/share/watch?token=" + url.QueryEscape(token),
+	}})
+}
+
+func SessionShareFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var share model.SessionShare
+	data, total, err := share.FindPage(q, c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+// SessionShareRevoke DELETE 撤销分享链接,正在查看的访客在下次检查时断开
+func SessionShareRevoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var share model.SessionShare
+	rows, err := share.Revoke(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if rows == 0 {
+		c.JSON(200, gin.H{"code": 2, "msg": "分享链接不存在"})
+		return
+	}
+	if share, err = share.FindByID(uint(id)); err == nil {
+		logShareEvent(share, model.SessionEventShare, fmt.Sprintf("revoke share=%d", share.ID))
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
+
+// SessionShareWatch GET 访客通过分享链接只读查看会话,无需登录,每次访问记录到会话时间线
+func SessionShareWatch(c *gin.Context) {
+	share, err := checkShareToken(c.Query("token"))
+	if err != nil {
+		slog.Warn("session share rejected", "client_ip", c.ClientIP(), "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": "分享链接无效或已过期"})
+		return
+	}
+	websocket.Handler(func(ws *websocket.Conn) {
+		conn, err := loadSupervisedConn(share.SessionId)
+		if err == nil && (conn.Uid != share.Uid || conn.supervise == nil) {
+			err = errors.New("terminal not running")
+		}
+		if err != nil {
+			_ = websocket.Message.Send(ws, err.Error())
+			return
+		}
+		ch, err := conn.supervise.watch()
+		if err != nil {
+			_ = websocket.Message.Send(ws, err.Error())
+			return
+		}
+		defer conn.supervise.unwatch(ch)
+
+		if err := share.AddView(share.ID); err != nil {
+			slog.Error("SessionShare.AddView error:", "err_msg", err.Error())
+		}
+		logShareEvent(share, model.SessionEventShareView, fmt.Sprintf("share=%d ip=%s ua=%s", share.ID, c.ClientIP(), c.Request.UserAgent()))
+		start := time.Now()
+		slog.Warn("session share view start", "sid", share.SessionId, "share_id", share.ID, "uid", share.Uid, "client_ip", c.ClientIP())
+		defer func() {
+			slog.Warn("session share view end", "sid", share.SessionId, "share_id", share.ID, "client_ip", c.ClientIP(), "duration", time.Since(start).String())
+		}()
+
+		streamWatch(ws, conn, ch, fmt.Sprintf("\x1b[33m[只读分享] %s@%s\x1b[0m\r\n", conn.User, conn.Address), func() error {
+			current, err := share.FindByID(share.ID)
+			if err != nil {
+				return errors.New("分享链接已撤销")
+			}
+			return checkShareValid(current)
+		})
+	}).ServeHTTP(c.Writer, c.Request)
+}
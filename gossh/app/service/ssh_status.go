@@ -2,6 +2,7 @@ package service
 
 import (
 	"gossh/app/config"
+	"gossh/app/model"
 	"gossh/gin"
 	"gossh/gin/sse"
 	"net/http"
@@ -21,6 +22,13 @@ func GetOnlineClient(c *gin.Context) {
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Content-Type", "text/event-stream")
 
+	// 租户用户只能看到本租户的会话
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		return
+	}
+
 	for {
 		c.Writer.(http.Flusher).Flush()
 		time.Sleep(config.DefaultConfig.StatusRefresh)
@@ -31,7 +39,7 @@ func GetOnlineClient(c *gin.Context) {
 			var data SshConnById
 			OnlineClients.Range(func(key, value any) bool {
 				conn, ok := value.(*SshConn)
-				if ok && conn != nil {
+				if ok && conn != nil && (u.TenantId == 0 || conn.tenantId == u.TenantId) {
 					data = append(data, *conn)
 				}
 				return ok
@@ -43,9 +51,10 @@ func GetOnlineClient(c *gin.Context) {
 				Event: "message",
 				Retry: 10000,
 				Data: map[string]any{
-					"code": 0,
-					"data": data,
-					"msg":  "ok",
+					"code":          0,
+					"data":          data,
+					"msg":           "ok",
+					"dropped_bytes": outputDroppedTotal.Load(),
 				},
 			})
 		}
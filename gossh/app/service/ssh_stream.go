@@ -0,0 +1,472 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"gossh/app/model"
+	"io"
+	"log/slog"
+	"regexp"
+	"regexp/syntax"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// StreamHook 终端数据流钩子,可以对用户输入和终端输出进行检查或改写
+type StreamHook interface {
+	// OnInput 处理用户输入,返回实际发送到主机的数据,返回错误时丢弃本次输入
+	OnInput(conn *SshConn, data []byte) ([]byte, error)
+
+	// OnOutput 处理终端输出,返回实际发送到浏览器的数据
+	OnOutput(conn *SshConn, data []byte) []byte
+}
+
+// StreamHookFactory 为每个会话创建钩子实例,不需要启用时返回nil
+type StreamHookFactory func(conn *SshConn) StreamHook
+
+var streamHookFactories []StreamHookFactory
+
+// RegisterStreamHook 注册终端数据流钩子
+func RegisterStreamHook(factory StreamHookFactory) {
+	streamHookFactories = append(streamHookFactories, factory)
+}
+
+func init() {
+	RegisterStreamHook(newLockHook)
+	RegisterStreamHook(newMacroHook)
+	RegisterStreamHook(newTermCapHook)
+	RegisterStreamHook(newWatermarkHook)
+	RegisterStreamHook(newDlpHook)
+	RegisterStreamHook(newSecretScanHook)
+	RegisterStreamHook(newRecordHook)
+	RegisterStreamHook(newTranscriptHook)
+	RegisterStreamHook(newScrollbackHook)
+	RegisterStreamHook(newSuperviseHook)
+	RegisterStreamHook(newTimelineHook)
+}
+
+// 创建会话的钩子列表
+func newStreamHooks(conn *SshConn) []StreamHook {
+	var hooks []StreamHook
+	for _, factory := range streamHookFactories {
+		if hook := factory(conn); hook != nil {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// 关闭实现了 io.Closer 的钩子
+func closeStreamHooks(conn *SshConn) {
+	for _, hook := range conn.hooks {
+		if closer, ok := hook.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				slog.Error("closeStreamHooks error:", "err_msg", err.Error())
+			}
+		}
+	}
+}
+
+// streamReader 包装用户输入
+type streamReader struct {
+	conn    *SshConn
+	reader  io.Reader
+	hooks   []StreamHook
+	pending []byte
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		n, err := r.reader.Read(p)
+		if n > 0 {
+			data := append([]byte(nil), p[:n]...)
+			data = r.apply(data)
+			r.pending = append(r.pending, data...)
+		}
+		if err != nil {
+			if len(r.pending) > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *streamReader) apply(data []byte) []byte {
+	var err error
+	for _, hook := range r.hooks {
+		data, err = hook.OnInput(r.conn, data)
+		if err != nil {
+			slog.Warn("stream input dropped", "session_id", r.conn.SessionId, "err_msg", err.Error())
+			return nil
+		}
+	}
+	return data
+}
+
+// streamFlusher 会暂时保留输出的钩子,一段时间没有新的输出时通过 Flush 取出保留的内容
+type streamFlusher interface {
+	Flush(conn *SshConn) []byte
+}
+
+// 没有新的输出时发送钩子保留内容的等待时间
+const streamFlushDelay = 200 * time.Millisecond
+
+// streamWriter 包装终端输出,stdout 和 stderr 共用
+type streamWriter struct {
+	mu     sync.Mutex
+	conn   *SshConn
+	writer io.Writer
+	hooks  []StreamHook
+	timer  *time.Timer
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := p
+	for _, hook := range w.hooks {
+		data = hook.OnOutput(w.conn, data)
+	}
+	w.scheduleFlush()
+	if len(data) == 0 {
+		return len(p), nil
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// scheduleFlush 有钩子保留输出时,等待 streamFlushDelay 后发送保留的内容
+func (w *streamWriter) scheduleFlush() {
+	if w.timer != nil {
+		w.timer.Reset(streamFlushDelay)
+		return
+	}
+	for _, hook := range w.hooks {
+		if _, ok := hook.(streamFlusher); ok {
+			w.timer = time.AfterFunc(streamFlushDelay, w.flush)
+			return
+		}
+	}
+}
+
+// flush 取出钩子保留的内容,经过之后的钩子处理后发送
+func (w *streamWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var data []byte
+	for _, hook := range w.hooks {
+		if len(data) > 0 {
+			data = hook.OnOutput(w.conn, data)
+		}
+		if f, ok := hook.(streamFlusher); ok {
+			data = append(data, f.Flush(w.conn)...)
+		}
+	}
+	if len(data) == 0 {
+		return
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		slog.Error("streamWriter flush error:", "err_msg", err.Error())
+	}
+}
+
+// 水印刷新间隔
+const watermarkInterval = time.Minute
+
+// watermarkHook 通过 OSC 0 设置终端标题的方式显示水印(用户名+时间)
+type watermarkHook struct {
+	userName string
+	last     time.Time
+}
+
+func newWatermarkHook(conn *SshConn) StreamHook {
+	conf, err := userPolicy(conn.Uid)
+	if err != nil || conf.Watermark != "Y" {
+		return nil
+	}
+	var user model.SshUser
+	u, err := user.FindByID(conn.Uid)
+	if err != nil {
+		slog.Error("watermark FindByID error:", "err_msg", err.Error())
+		return nil
+	}
+	return &watermarkHook{userName: u.Name}
+}
+
+func (h *watermarkHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (h *watermarkHook) OnOutput(conn *SshConn, data []byte) []byte {
+	now := time.Now()
+	if now.Sub(h.last) < watermarkInterval {
+		return data
+	}
+	h.last = now
+	mark := fmt.Sprintf("\x1b]0;%s@%s %s\x07", h.userName, conn.ClientIP, now.Format(model.TimeFormat))
+	return append([]byte(mark), data...)
+}
+
+// 输入检查缓冲区大小
+const dlpInputBufSize = 256
+
+// 输出跨块匹配时保留的上次输出末尾的大小
+const dlpOutputTailSize = 256
+
+// 输出中可能是拦截规则匹配开头的内容最多保留的大小
+const dlpOutputHoldSize = 256
+
+type dlpMatcher struct {
+	rule model.DlpRule
+	re   *regexp.Regexp
+	// prog 拦截规则用于判断末尾是否可能是匹配的开头
+	prog *syntax.Prog
+}
+
+// dlpHook 根据 DlpRule 对输入输出的敏感内容进行拦截或标记
+type dlpHook struct {
+	input  []dlpMatcher
+	output []dlpMatcher
+	// buf 当前行已输入的内容,末尾 held 个字节还没有发送到主机
+	buf  []byte
+	held int
+	// tail 已经发送的输出末尾,pending 还没有发送的输出,与本次输出一起匹配
+	tail    []byte
+	pending []byte
+}
+
+func newDlpHook(conn *SshConn) StreamHook {
+	var dlpRule model.DlpRule
+	rules, err := dlpRule.FindAllEnable()
+	if err != nil {
+		slog.Error("dlp FindAllEnable error:", "err_msg", err.Error())
+		return nil
+	}
+	hook := &dlpHook{}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			slog.Error("dlp regexp.Compile error:", "name", rule.Name, "err_msg", err.Error())
+			continue
+		}
+		m := dlpMatcher{rule: rule, re: re}
+		if rule.Action == "block" {
+			if parsed, err := syntax.Parse(rule.Pattern, syntax.Perl); err == nil {
+				m.prog, _ = syntax.Compile(parsed.Simplify())
+			}
+		}
+		if rule.Direction == "output" {
+			hook.output = append(hook.output, m)
+			continue
+		}
+		hook.input = append(hook.input, m)
+	}
+	if len(hook.input) == 0 && len(hook.output) == 0 {
+		return nil
+	}
+	return hook
+}
+
+// dlpHoldFrom 输入末尾可能是规则匹配开头的起始位置,没有时返回 len(data)
+// 按正则的 NFA 逐个字符推进,到末尾仍未结束的匹配说明后续输入可能构成匹配
+func dlpHoldFrom(prog *syntax.Prog, data []byte) int {
+	type thread struct {
+		pc    uint32
+		start int
+	}
+	seen := make([]int, len(prog.Inst))
+	gen := 1
+	var add func(list []thread, pc uint32, start int, ctx syntax.EmptyOp, anyCtx bool) []thread
+	add = func(list []thread, pc uint32, start int, ctx syntax.EmptyOp, anyCtx bool) []thread {
+		if seen[pc] == gen {
+			return list
+		}
+		seen[pc] = gen
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			list = add(list, inst.Out, start, ctx, anyCtx)
+			return add(list, inst.Arg, start, ctx, anyCtx)
+		case syntax.InstCapture, syntax.InstNop:
+			return add(list, inst.Out, start, ctx, anyCtx)
+		case syntax.InstEmptyWidth:
+			// 输入末尾之后的内容未知,按可能满足处理
+			if anyCtx || syntax.EmptyOp(inst.Arg)&^ctx == 0 {
+				return add(list, inst.Out, start, ctx, anyCtx)
+			}
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			return append(list, thread{pc: pc, start: start})
+		}
+		return list
+	}
+
+	var list []thread
+	if len(data) > 0 {
+		r, _ := utf8.DecodeRune(data)
+		list = add(nil, uint32(prog.Start), 0, syntax.EmptyOpContext(-1, r), false)
+	}
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		next := i + size
+		end := next >= len(data)
+		var ctx syntax.EmptyOp
+		if !end {
+			rn, _ := utf8.DecodeRune(data[next:])
+			ctx = syntax.EmptyOpContext(r, rn)
+		}
+		gen++
+		var nlist []thread
+		for _, t := range list {
+			if inst := &prog.Inst[t.pc]; inst.MatchRune(r) {
+				nlist = add(nlist, inst.Out, t.start, ctx, end)
+			}
+		}
+		if !end {
+			nlist = add(nlist, uint32(prog.Start), next, ctx, false)
+		}
+		list = nlist
+		i = next
+	}
+	from := len(data)
+	for _, t := range list {
+		from = min(from, t.start)
+	}
+	return from
+}
+
+// dlpControl 回车、方向键、Tab 等控制字符,之后本地缓冲的内容与主机上的行不再一致
+func dlpControl(data []byte) bool {
+	for _, b := range data {
+		if b < 0x20 || b == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// OnInput 在发送到主机之前检查输入,可能构成拦截规则匹配的内容先保留,确定不匹配或遇到控制字符后再发送
+func (h *dlpHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	if len(h.input) == 0 {
+		return data, nil
+	}
+	unsent := len(h.buf) - h.held
+	h.buf = append(h.buf, data...)
+	marked := false
+	for _, m := range h.input {
+		if !m.re.Match(h.buf) {
+			continue
+		}
+		slog.Warn("dlp input matched", "session_id", conn.SessionId, "client_ip", conn.ClientIP, "rule", m.rule.Name, "action", m.rule.Action)
+		if m.rule.Action == "block" {
+			if conn.ws != nil {
+				conn.ws.Event(TermEventDlp, fmt.Sprintf("\r\n[DLP] %s\r\n", m.rule.Name))
+			}
+			// 保留的内容直接丢弃,已经发送的部分发送 Ctrl+U 清除
+			h.buf, h.held = nil, 0
+			return []byte{0x15}, nil
+		}
+		marked = true
+	}
+
+	hold := len(h.buf)
+	if !dlpControl(data) {
+		for _, m := range h.input {
+			if m.prog != nil {
+				hold = min(hold, dlpHoldFrom(m.prog, h.buf))
+			}
+		}
+	}
+	hold = max(hold, unsent)
+	out := append([]byte(nil), h.buf[unsent:hold]...)
+	h.held = len(h.buf) - hold
+	if h.held > dlpInputBufSize {
+		out = append(out, h.buf[hold:hold+h.held-dlpInputBufSize]...)
+		h.held = dlpInputBufSize
+	}
+
+	switch {
+	case marked:
+		h.buf = h.buf[len(h.buf)-h.held:]
+	case h.held == 0:
+		// 回车后开始新的一行
+		if i := bytes.LastIndexAny(h.buf, "\r\n"); i >= 0 {
+			h.buf = h.buf[i+1:]
+		}
+	}
+	if len(h.buf) > dlpInputBufSize {
+		h.buf = h.buf[len(h.buf)-dlpInputBufSize:]
+	}
+	h.buf = append([]byte(nil), h.buf...)
+	return out, nil
+}
+
+// OnOutput 可能构成拦截规则匹配的输出末尾先保留,与之后的输出一起匹配,内容被拆分到多次读取中也能完整屏蔽
+func (h *dlpHook) OnOutput(conn *SshConn, data []byte) []byte {
+	if len(h.output) == 0 {
+		return data
+	}
+	return h.filterOutput(conn, data, false)
+}
+
+// Flush 一段时间没有新的输出时发送保留的内容,之后的输出即使构成匹配也无法屏蔽这部分内容
+func (h *dlpHook) Flush(conn *SshConn) []byte {
+	if len(h.pending) == 0 {
+		return nil
+	}
+	return h.filterOutput(conn, nil, true)
+}
+
+// filterOutput 匹配已发送的末尾、保留的内容和本次输出,返回可以发送的部分,flush 为 true 时不再保留
+func (h *dlpHook) filterOutput(conn *SshConn, data []byte, flush bool) []byte {
+	offset := len(h.tail)
+	joined := make([]byte, 0, offset+len(h.pending)+len(data))
+	joined = append(append(append(joined, h.tail...), h.pending...), data...)
+	// 结束位置在保留内容中的匹配上次已经记录过
+	newFrom := offset + len(h.pending)
+	out := append([]byte(nil), joined[offset:]...)
+	for _, m := range h.output {
+		matched := false
+		for _, loc := range m.re.FindAllIndex(joined, -1) {
+			// 只在已发送内容中的匹配已经处理过
+			if loc[1] <= offset {
+				continue
+			}
+			if loc[1] > newFrom {
+				matched = true
+			}
+			if m.rule.Action != "block" {
+				continue
+			}
+			for i := max(loc[0], offset); i < loc[1]; i++ {
+				out[i-offset] = '*'
+			}
+		}
+		if matched {
+			slog.Warn("dlp output matched", "session_id", conn.SessionId, "client_ip", conn.ClientIP, "rule", m.rule.Name, "action", m.rule.Action)
+		}
+	}
+
+	hold := len(joined)
+	if !flush {
+		from := max(offset, len(joined)-dlpOutputHoldSize)
+		for _, m := range h.output {
+			if m.prog != nil {
+				hold = min(hold, from+dlpHoldFrom(m.prog, joined[from:]))
+			}
+		}
+	}
+	h.pending = append([]byte(nil), joined[hold:]...)
+	sent := joined[:hold]
+	if len(sent) > dlpOutputTailSize {
+		sent = sent[len(sent)-dlpOutputTailSize:]
+	}
+	h.tail = append([]byte(nil), sent...)
+	return out[:hold-offset]
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/gin"
+	"gossh/websocket"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// 监看连接的发送队列长度,队列满时丢弃输出,不影响原会话
+	watcherQueueSize = 256
+	// 只读连接检查是否可以继续查看的间隔
+	watchCheckInterval = 5 * time.Second
+)
+
+// superviseHook 将终端输出复制给管理员的只读监看连接
+type superviseHook struct {
+	mu       sync.Mutex
+	watchers map[chan []byte]struct{}
+	closed   bool
+}
+
+// newSupervise 创建会话的监看,在会话保存到在线列表之前创建
+func newSupervise() *superviseHook {
+	return &superviseHook{watchers: map[chan []byte]struct{}{}}
+}
+
+func newSuperviseHook(conn *SshConn) StreamHook {
+	if conn.supervise == nil {
+		return nil
+	}
+	return conn.supervise
+}
+
+func (h *superviseHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (h *superviseHook) OnOutput(conn *SshConn, data []byte) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.watchers) == 0 {
+		return data
+	}
+	buf := append([]byte(nil), data...)
+	for ch := range h.watchers {
+		select {
+		case ch <- buf:
+		default:
+		}
+	}
+	return data
+}
+
+// watch 注册监看连接,会话已关闭时返回错误
+func (h *superviseHook) watch() (chan []byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, errors.New("会话已关闭")
+	}
+	ch := make(chan []byte, watcherQueueSize)
+	h.watchers[ch] = struct{}{}
+	return ch, nil
+}
+
+func (h *superviseHook) unwatch(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.watchers[ch]; ok {
+		delete(h.watchers, ch)
+		close(ch)
+	}
+}
+
+// Close 会话关闭时结束所有监看连接
+func (h *superviseHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.watchers {
+		delete(h.watchers, ch)
+		close(ch)
+	}
+	return nil
+}
+
+// loadSupervisedConn 查找可以监看的在线会话
+func loadSupervisedConn(sessionId string) (*SshConn, error) {
+	cli, ok := OnlineClients.Load(sessionId)
+	if !ok || cli == nil {
+		return nil, errors.New("session not exists")
+	}
+	conn, ok := cli.(*SshConn)
+	if !ok || conn == nil {
+		return nil, errors.New("to type SshConn error")
+	}
+	return conn, nil
+}
+
+// SshWatch 管理员只读监看在线终端,先发送服务端保留的最近输出,再实时转发
+func SshWatch(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	sessionId := c.Query("session_id")
+	websocket.Handler(func(ws *websocket.Conn) {
+		conn, err := loadSupervisedConn(sessionId)
+		if err == nil && u.TenantId != 0 && conn.tenantId != u.TenantId {
+			err = errors.New("session not exists")
+		}
+		if err == nil && conn.supervise == nil {
+			err = errors.New("terminal not running")
+		}
+		if err != nil {
+			_ = websocket.Message.Send(ws, err.Error())
+			return
+		}
+		ch, err := conn.supervise.watch()
+		if err != nil {
+			_ = websocket.Message.Send(ws, err.Error())
+			return
+		}
+		defer conn.supervise.unwatch(ch)
+		slog.Warn("session watch start", "sid", sessionId, "admin", u.Name, "uid", conn.Uid, "host", conn.Address, "client_ip", c.ClientIP())
+		defer slog.Warn("session watch end", "sid", sessionId, "admin", u.Name)
+
+		streamWatch(ws, conn, ch, fmt.Sprintf("\x1b[33m[只读监看] %s@%s:%d\x1b[0m\r\n", conn.User, conn.Address, conn.Port), nil)
+	}).ServeHTTP(c.Writer, c.Request)
+}
+
+// streamWatch 向只读连接发送服务端保留的最近输出和实时输出,丢弃对端的输入
+// check 不为空时定时检查是否可以继续查看,返回错误时结束
+func streamWatch(ws *websocket.Conn, conn *SshConn, ch chan []byte, banner string, check func() error) {
+	_, _ = ws.Write([]byte(banner))
+	if conn.scrollback != nil {
+		_, _ = ws.Write(conn.scrollback.Bytes())
+	}
+
+	// 只读,丢弃监看端的输入,连接断开时结束
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var msg []byte
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}()
+	ticker := time.NewTicker(watchCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				_, _ = ws.Write([]byte("\r\n\x1b[33m[会话已结束]\x1b[0m\r\n"))
+				return
+			}
+			if _, err := ws.Write(data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if check == nil {
+				continue
+			}
+			if err := check(); err != nil {
+				_, _ = ws.Write([]byte(fmt.Sprintf("\r\n\x1b[33m[%s]\x1b[0m\r\n", err.Error())))
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// SshTerminate POST 管理员终止在线会话,并在用户终端显示提示信息
+func SshTerminate(c *gin.Context) {
+	type Param struct {
+		SessionId string `form:"session_id" binding:"required,min=1,max=64" json:"session_id"`
+		Message   string `form:"message" binding:"max=512" json:"message"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	conn, err := loadSupervisedConn(param.SessionId)
+	if err == nil && u.TenantId != 0 && conn.tenantId != u.TenantId {
+		err = errors.New("session not exists")
+	}
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+
+	notice := "\r\n\x1b[31m[会话已被管理员终止]"
+	if param.Message != "" {
+		notice += " " + param.Message
+	}
+	notice += "\x1b[0m\r\n"
+	if conn.ws != nil {
+		conn.ws.Event(TermEventTerminated, notice)
+	}
+	slog.Warn("session terminated by admin", "sid", param.SessionId, "admin", u.Name, "uid", conn.Uid,
+		"host", conn.Address, "client_ip", conn.ClientIP, "message", param.Message)
+	conn.setCloseReason("terminated by " + u.Name)
+	DeleteOnlineClient(param.SessionId)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bytes"
+	"gossh/app/config"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// 等待 CSI 序列结束的最大长度,超过后原样输出
+const termCapMaxCsi = 256
+
+// applyTermCaps 支持真彩色时通过 COLORTERM 告知主机,主机未允许该环境变量时忽略
+func applyTermCaps(conn *SshConn) {
+	if conn.TrueColor == "N" {
+		return
+	}
+	if err := conn.sshSession.Setenv("COLORTERM", "truecolor"); err != nil {
+		slog.Debug("sshSession.Setenv rejected", "sid", conn.SessionId, "name", "COLORTERM", "err_msg", err.Error())
+	}
+}
+
+// termCapHook 按连接配置过滤终端输出:关闭真彩色时将 24 位颜色转换为 256 色,禁止 OSC 52 时丢弃剪贴板序列
+type termCapHook struct {
+	trueColor  bool
+	allowOsc52 bool
+	pending    []byte // 被截断的转义序列,与下次输出合并处理
+	dropping   bool   // 正在丢弃 OSC 52 序列
+	dropEsc    bool   // 丢弃时上一个字节为 ESC
+}
+
+func newTermCapHook(conn *SshConn) StreamHook {
+	h := &termCapHook{
+		trueColor:  conn.TrueColor != "N",
+		allowOsc52: conn.AllowOsc52 != "N" && !config.DefaultConfig.Security.BlockOsc52,
+	}
+	if h.trueColor && h.allowOsc52 {
+		return nil
+	}
+	return h
+}
+
+func (h *termCapHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (h *termCapHook) OnOutput(conn *SshConn, data []byte) []byte {
+	if len(h.pending) > 0 {
+		data = append(h.pending, data...)
+		h.pending = nil
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if h.dropping {
+			n := h.skipOsc(data[i:])
+			if n < 0 {
+				break
+			}
+			i += n
+			continue
+		}
+		j := bytes.IndexByte(data[i:], 0x1b)
+		if j < 0 {
+			out = append(out, data[i:]...)
+			break
+		}
+		out = append(out, data[i:i+j]...)
+		i += j
+		n, seq, ok := h.escape(data[i:])
+		if !ok {
+			h.pending = append([]byte(nil), data[i:]...)
+			break
+		}
+		out = append(out, seq...)
+		i += n
+	}
+	return out
+}
+
+// escape 处理以 ESC 开头的序列,返回消耗的字节数和输出的内容,序列不完整时返回 false
+func (h *termCapHook) escape(data []byte) (int, []byte, bool) {
+	if len(data) < 2 {
+		return 0, nil, false
+	}
+	switch data[1] {
+	case '[':
+		for k := 2; k < len(data) && k < termCapMaxCsi; k++ {
+			if b := data[k]; b >= 0x40 && b <= 0x7e {
+				if b == 'm' && !h.trueColor {
+					return k + 1, []byte("\x1b[" + downgradeSgr(string(data[2:k])) + "m"), true
+				}
+				return k + 1, data[:k+1], true
+			}
+		}
+		if len(data) < termCapMaxCsi {
+			return 0, nil, false
+		}
+		return len(data), data, true
+	case ']':
+		// 只需要判断 OSC 编号,内容原样输出
+		k := 2
+		for k < len(data) && data[k] >= '0' && data[k] <= '9' {
+			k++
+		}
+		if k == len(data) {
+			if k < 8 {
+				return 0, nil, false
+			}
+			return k, data[:k], true
+		}
+		if !h.allowOsc52 && data[k] == ';' && string(data[2:k]) == "52" {
+			h.dropping = true
+			return k + 1, nil, true
+		}
+		return 2, data[:2], true
+	}
+	return 2, data[:2], true
+}
+
+// skipOsc 丢弃到 OSC 序列结束(BEL 或 ESC \),返回消耗的字节数,未结束时返回 -1
+func (h *termCapHook) skipOsc(data []byte) int {
+	for k, b := range data {
+		if b == 0x07 || (h.dropEsc && b == '\\') {
+			h.dropping, h.dropEsc = false, false
+			return k + 1
+		}
+		h.dropEsc = b == 0x1b
+	}
+	return -1
+}
+
+// downgradeSgr 将 SGR 参数中的 24 位颜色(38;2;r;g;b、48;2;r;g;b 及冒号形式)转换为 256 色
+func downgradeSgr(params string) string {
+	parts := strings.Split(params, ";")
+	out := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		p := parts[i]
+		if strings.HasPrefix(p, "38:2:") || strings.HasPrefix(p, "48:2:") {
+			sub := strings.Split(p, ":")
+			// 38:2:r:g:b 或带颜色空间的 38:2::r:g:b
+			if len(sub) >= 5 {
+				rgb := sub[len(sub)-3:]
+				out = append(out, sub[0]+":5:"+strconv.Itoa(rgbTo256(rgb[0], rgb[1], rgb[2])))
+				continue
+			}
+		}
+		if (p == "38" || p == "48") && i+4 < len(parts) && parts[i+1] == "2" {
+			out = append(out, p, "5", strconv.Itoa(rgbTo256(parts[i+2], parts[i+3], parts[i+4])))
+			i += 4
+			continue
+		}
+		out = append(out, p)
+	}
+	return strings.Join(out, ";")
+}
+
+// rgbTo256 选择 xterm 256 色中最接近的颜色,在 6x6x6 色块和 24 级灰度中比较
+func rgbTo256(rs, gs, bs string) int {
+	channel := func(s string) int {
+		v, _ := strconv.Atoi(s)
+		return min(max(v, 0), 255)
+	}
+	r, g, b := channel(rs), channel(gs), channel(bs)
+	levels := []int{0, 95, 135, 175, 215, 255}
+	cube := func(v int) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+	dist := func(cr, cg, cb int) int {
+		return (r-cr)*(r-cr) + (g-cg)*(g-cg) + (b-cb)*(b-cb)
+	}
+	ri, gi, bi := cube(r), cube(g), cube(b)
+	gray := (r + g + b) / 3
+	grayIdx := 23
+	if gray < 238 {
+		grayIdx = max((gray-3)/10, 0)
+	}
+	grayLevel := 8 + 10*grayIdx
+	if dist(grayLevel, grayLevel, grayLevel) < dist(levels[ri], levels[gi], levels[bi]) {
+		return 232 + grayIdx
+	}
+	return 16 + 36*ri + 6*gi + bi
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"gossh/app/config"
+	"gossh/gin"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 后台标签页缓冲区超过该大小立即发送
+const throttleMaxBuffer = 256 * 1024
+
+// outputThrottle 浏览器标签页在后台时合并终端输出,降低带宽和CPU占用
+type outputThrottle struct {
+	mu         sync.Mutex
+	writer     io.Writer
+	buf        bytes.Buffer
+	background bool
+	stop       chan struct{}
+}
+
+func newOutputThrottle(writer io.Writer) *outputThrottle {
+	return &outputThrottle{writer: writer}
+}
+
+func (t *outputThrottle) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.background {
+		return t.writer.Write(p)
+	}
+	t.buf.Write(p)
+	if t.buf.Len() >= throttleMaxBuffer {
+		if err := t.flushLocked(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// SetBackground 切换前后台状态,回到前台时立即发送缓冲的数据
+func (t *outputThrottle) SetBackground(background bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.background == background {
+		return
+	}
+	t.background = background
+	if background {
+		t.stop = make(chan struct{})
+		go t.loop(t.stop)
+		return
+	}
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	if err := t.flushLocked(); err != nil {
+		slog.Error("outputThrottle flush error:", "err_msg", err.Error())
+	}
+}
+
+func (t *outputThrottle) loop(stop chan struct{}) {
+	interval := config.DefaultConfig.BackgroundFlush
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			err := t.flushLocked()
+			t.mu.Unlock()
+			if err != nil {
+				slog.Error("outputThrottle flush error:", "err_msg", err.Error())
+				return
+			}
+		}
+	}
+}
+
+func (t *outputThrottle) flushLocked() error {
+	if t.buf.Len() == 0 {
+		return nil
+	}
+	data := compactOutput(t.buf.Bytes())
+	t.buf.Reset()
+	_, err := t.writer.Write(data)
+	return err
+}
+
+func (t *outputThrottle) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	return nil
+}
+
+// compactOutput 丢弃同一行中被回车覆盖的内容,如进度条和动画
+func compactOutput(data []byte) []byte {
+	if !bytes.ContainsRune(data, '\r') {
+		return data
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	out := make([]byte, 0, len(data))
+	for _, line := range lines {
+		content := bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if i := bytes.LastIndexByte(content, '\r'); i >= 0 {
+			out = append(out, line[i:]...)
+			continue
+		}
+		out = append(out, line...)
+	}
+	return out
+}
+
+// SetVisibility PATCH 浏览器标签页可见状态,hidden 表示后台
+func SetVisibility(c *gin.Context) {
+	type Param struct {
+		SessionId string `form:"session_id" binding:"required,min=1,max=128" json:"session_id"`
+		State     string `form:"state" binding:"required,oneof=visible hidden" json:"state"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	conn, err := getSshConn(param.SessionId)
+	if err != nil || conn == nil {
+		c.JSON(200, gin.H{"code": 2, "msg": "the client is disconnected"})
+		return
+	}
+	if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	if conn.throttle == nil {
+		c.JSON(200, gin.H{"code": 3, "msg": "terminal not running"})
+		return
+	}
+	conn.throttle.SetBackground(param.State == "hidden")
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": fmt.Sprintf("state:%s", param.State)})
+}
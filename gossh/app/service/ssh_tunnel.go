@@ -0,0 +1,69 @@
+package service
+
+import (
+	"gossh/gin"
+	"gossh/websocket"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// SshTunnel GET 通过 WebSocket 转发 TCP 连接,目标地址由 ssh 主机发起连接
+func SshTunnel(c *gin.Context) {
+	websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		query := ws.Request().URL.Query()
+		conn, err := getSshConn(query.Get("session_id"))
+		if err != nil || conn == nil || conn.sshClient == nil {
+			slog.Error("SshTunnel getSshConn error")
+			return
+		}
+		if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+			slog.Warn("ssh tunnel rejected", "sid", conn.SessionId, "client_ip", c.RemoteIP(), "err_msg", err.Error())
+			return
+		}
+		// 与终端相同的访问检查,需要审批的会话在审批通过前没有 ssh 客户端,不能建立隧道
+		if !isAccessAllowed(conn.Uid) {
+			slog.Warn("ssh tunnel rejected", "sid", conn.SessionId, "client_ip", c.RemoteIP(), "err_msg", "access window")
+			return
+		}
+		if err := checkProdAccess(conn, query.Get("confirm")); err != nil {
+			slog.Warn("ssh tunnel rejected", "sid", conn.SessionId, "client_ip", c.RemoteIP(), "err_msg", err.Error())
+			return
+		}
+
+		target := net.JoinHostPort(query.Get("host"), query.Get("port"))
+		remote, err := conn.sshClient.Dial("tcp", target)
+		if err != nil {
+			slog.Error("SshTunnel dial error:", "target", target, "err_msg", err.Error())
+			return
+		}
+		defer func() {
+			_ = remote.Close()
+		}()
+		slog.Info("ssh tunnel open", "sid", conn.SessionId, "target", target, "client_ip", c.RemoteIP())
+
+		done := make(chan struct{}, 2)
+		go func() {
+			_, _ = io.Copy(activeWriter{w: remote, conn: conn}, ws)
+			done <- struct{}{}
+		}()
+		go func() {
+			_, _ = io.Copy(activeWriter{w: ws, conn: conn}, remote)
+			done <- struct{}{}
+		}()
+		<-done
+	}).ServeHTTP(c.Writer, c.Request)
+}
+
+// activeWriter 转发数据时刷新会话的最后活跃时间,避免长时间转发的会话被当作空闲会话清理
+type activeWriter struct {
+	w    io.Writer
+	conn *SshConn
+}
+
+func (a activeWriter) Write(p []byte) (int, error) {
+	a.conn.LastActiveTime = time.Now()
+	return a.w.Write(p)
+}
@@ -1,11 +1,14 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"gossh/app/middleware"
 	"gossh/app/model"
 	"gossh/app/utils"
 	"gossh/gin"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,7 +18,7 @@ func UserCreate(c *gin.Context) {
 	var user model.SshUser
 	if err := c.ShouldBind(&user); err != nil {
 		slog.Error("UserCreate 绑定数据错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "输入数据不合法"})
+		bindError(c, 1, err)
 		return
 	}
 	u, err := user.FindByID(c.GetUint("uid"))
@@ -26,6 +29,20 @@ func UserCreate(c *gin.Context) {
 	}
 
 	user.IsRoot = "N"
+	// 模拟登录权限只能由 Root 用户授予
+	if u.IsRoot != "Y" {
+		user.CanImpersonate = "N"
+	}
+	// 只有 Root 用户可以在其他租户下创建用户
+	if u.IsRoot != "Y" {
+		user.TenantId = u.TenantId
+	} else if user.TenantId != 0 {
+		var tenant model.Tenant
+		if _, err := tenant.FindByID(user.TenantId); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": "租户不存在"})
+			return
+		}
+	}
 	err = user.Create(&user)
 	if err != nil {
 		slog.Error("创建用户错误", "err_msg", err.Error())
@@ -43,7 +60,12 @@ func ModifyPasswd(c *gin.Context) {
 	var pwd password
 	if err := c.ShouldBind(&pwd); err != nil {
 		slog.Error("绑定数据错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "输入数据不合法"})
+		bindError(c, 1, err)
+		return
+	}
+
+	if c.GetUint("impersonator") != 0 {
+		c.JSON(200, gin.H{"code": 4, "msg": "模拟登录时不能执行该操作"})
 		return
 	}
 
@@ -74,7 +96,7 @@ func CheckUserNameExists(c *gin.Context) {
 	var name Name
 	if err := c.ShouldBind(&name); err != nil {
 		slog.Error("绑定数据错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "输入数据不合法"})
+		bindError(c, 1, err)
 		return
 	}
 	var user model.SshUser
@@ -85,14 +107,15 @@ func CheckUserNameExists(c *gin.Context) {
 		return
 	}
 
-	tmp, err := user.FindByName(name.Name)
+	// 回收站中的用户仍占用用户名
+	exists, err := user.NameExists(name.Name)
 	if err != nil {
-		slog.Error("FindByName错误", "err_msg", err.Error())
+		slog.Error("NameExists错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 3, "msg": "获取用户信息错误"})
 		return
 	}
 
-	if tmp.ID != 0 {
+	if exists {
 		c.JSON(200, gin.H{"code": 4, "msg": "用户名已经存存在"})
 		return
 	}
@@ -116,6 +139,9 @@ func UserFindByID(c *gin.Context) {
 	}
 
 	data, err := user.FindByID(uint(id))
+	if err == nil && u.IsRoot != "Y" && data.TenantId != u.TenantId {
+		err = errors.New("用户不属于当前租户")
+	}
 	if err != nil {
 		slog.Error("FindByID错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 3, "msg": "获取用户信息错误"})
@@ -125,17 +151,10 @@ func UserFindByID(c *gin.Context) {
 }
 
 func UserFindAll(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	q, err := parsePageQuery(c)
 	if err != nil {
-		slog.Error("获取limit错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "获取limit错误"})
-		return
-	}
-
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	if err != nil {
-		slog.Error("获取offset错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 2, "msg": "获取offset错误"})
+		slog.Error("parsePageQuery错误", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
 		return
 	}
 
@@ -146,20 +165,20 @@ func UserFindAll(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 3, "msg": "非管理员拒绝操作"})
 		return
 	}
-	data, err := user.FindAll(limit, offset)
+	data, total, err := user.FindPage(q, tenantScope(c, u))
 	if err != nil {
-		slog.Error("user.FindAl错误", "err_msg", err.Error())
+		slog.Error("user.FindPage错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 4, "msg": "获取用户信息错误"})
 		return
 	}
-	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
 }
 
 func UserUpdateById(c *gin.Context) {
 	var user model.SshUser
 	if err := c.ShouldBind(&user); err != nil {
 		slog.Error("获取ID错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "获取ID错误"})
+		bindError(c, 1, err)
 		return
 	}
 
@@ -171,11 +190,16 @@ func UserUpdateById(c *gin.Context) {
 	}
 
 	tmpUser, err := user.FindByID(user.ID)
+	if err == nil && u.IsRoot != "Y" && tmpUser.TenantId != u.TenantId {
+		err = errors.New("用户不属于当前租户")
+	}
 	if err != nil {
 		slog.Error("FindByID错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 3, "msg": "获取用户信息错误"})
 		return
 	}
+	// 用户创建后不能更换租户
+	user.TenantId = tmpUser.TenantId
 
 	// 防止越权操作
 	if tmpUser.IsRoot == "N" {
@@ -187,7 +211,16 @@ func UserUpdateById(c *gin.Context) {
 		return
 	}
 
+	// 模拟登录权限只能由 Root 用户授予
+	if u.IsRoot != "Y" {
+		user.CanImpersonate = tmpUser.CanImpersonate
+	}
+
 	err = user.UpdateById(user.ID, &user)
+	if errors.Is(err, model.ErrVersionConflict) {
+		c.JSON(409, gin.H{"code": 6, "msg": "数据已被其他人修改,请刷新后重试"})
+		return
+	}
 	if err != nil {
 		slog.Error("UpdateById错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 5, "msg": "更新用户错误"})
@@ -211,6 +244,9 @@ func UserDeleteById(c *gin.Context) {
 		return
 	}
 	tmpUser, err := user.FindByID(uint(id))
+	if err == nil && u.IsRoot != "Y" && tmpUser.TenantId != u.TenantId {
+		err = errors.New("用户不属于当前租户")
+	}
 	if err != nil {
 		slog.Error("user.FindByID错误", "err_msg", err.Error())
 		c.JSON(200, gin.H{"code": 3, "msg": "获取用户信息错误"})
@@ -229,6 +265,13 @@ func UserDeleteById(c *gin.Context) {
 		c.JSON(200, gin.H{"code": 5, "msg": "删除用户错误"})
 		return
 	}
+	// 级联删除在后台执行,返回任务ID查询进度
+	if c.Query("cascade") == "Y" {
+		job := startUserCascade(tmpUser, u.ID)
+		slog.Info("user cascade delete", "id", tmpUser.ID, "name", tmpUser.Name, "operator", u.Name, "job_id", job.Id)
+		c.JSON(200, gin.H{"code": 0, "msg": "ok", "job_id": job.Id})
+		return
+	}
 	UserFindAll(c)
 }
 
@@ -240,19 +283,27 @@ func UserLogin(c *gin.Context) {
 	var loginAudit model.LoginAudit
 	var param Param
 
+	geo := utils.GeoLookup(net.ParseIP(c.ClientIP()))
 	audit := model.LoginAudit{
 		ClientIp:  c.ClientIP(),
 		UserAgent: utils.TruncateString(c.Request.UserAgent(), 500),
 		ErrMsg:    "请求参数错误",
 		IsSuccess: "N",
 		OccurAt:   model.DateTime(time.Now()),
+		Country:   utils.TruncateString(geo.Country, 64),
+		City:      utils.TruncateString(geo.City, 64),
+		Asn:       utils.TruncateString(geo.Asn, 128),
+		RequestId: c.GetString("request_id"),
+	}
+	if id, ok := middleware.HostTenant(c.Request); ok {
+		audit.TenantId = id
 	}
 	if err := c.ShouldBind(&param); err != nil {
 		audit.Name = utils.TruncateString(param.Name, 60)
 		audit.Pwd = utils.TruncateString(param.Pwd, 60)
 		_ = loginAudit.Create(&audit)
 		slog.Error("绑定数据错误", "err_msg", err.Error())
-		c.JSON(200, gin.H{"code": 1, "msg": "输入数据不合法"})
+		bindError(c, 1, err)
 		return
 	}
 	audit.Name = utils.TruncateString(param.Name, 60)
@@ -264,10 +315,20 @@ func UserLogin(c *gin.Context) {
 		audit.ErrMsg = "账号密码错误"
 		_ = loginAudit.Create(&audit)
 		slog.Error("账号密码错误", "err_msg", err.Error())
+		notifyLoginFailed(audit)
 		c.JSON(401, gin.H{"code": 2, "msg": "账号密码错误"})
 		return
 	}
 
+	if err := checkTenantLogin(c, u); err != nil {
+		audit.ErrMsg = err.Error()
+		_ = loginAudit.Create(&audit)
+		slog.Warn("tenant login denied", "user", u.Name, "tenant_id", u.TenantId, "err_msg", err.Error())
+		c.JSON(401, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	audit.TenantId = u.TenantId
+
 	if u.IsEnable == "N" {
 		audit.ErrMsg = "账号已禁用"
 		_ = loginAudit.Create(&audit)
@@ -294,6 +355,12 @@ func UserLogin(c *gin.Context) {
 	audit.Pwd = "*"
 	audit.ErrMsg = "*"
 	audit.IsSuccess = "Y"
+	// 首次使用该客户端登录时通知
+	if known, err := loginAudit.HasSuccess(audit.Name, audit.UserAgent); err == nil && !known {
+		notifyEvent(EventNewDevice, "新设备登录",
+			fmt.Sprintf("用户名: %s\n客户端IP: %s\n位置: %s %s\nUser-Agent: %s",
+				audit.Name, audit.ClientIp, audit.Country, audit.City, audit.UserAgent))
+	}
 	_ = loginAudit.Create(&audit)
 	c.JSON(http.StatusOK, gin.H{
 		"code":           0,
@@ -304,5 +371,6 @@ func UserLogin(c *gin.Context) {
 		"user_name":      u.Name,
 		"user_desc":      u.DescInfo,
 		"user_expiry_at": u.ExpiryAt.String(),
+		"tenant_id":      u.TenantId,
 	})
 }
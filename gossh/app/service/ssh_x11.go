@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"gossh/crypto/ssh"
+	"gossh/gin"
+	"gossh/websocket"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// 等待浏览器接入的 X11 连接数量上限,超出后拒绝主机新建的连接
+	x11MaxPending = 16
+	// 主机发起的 X11 连接等待浏览器接入的时间
+	x11AcceptTimeout = 30 * time.Second
+	x11AuthProto     = "MIT-MAGIC-COOKIE-1"
+)
+
+// x11Forward 会话的 X11 转发状态,主机上的程序连接 DISPLAY 时发起 x11 通道,由浏览器中的 X 服务通过 websocket 接入
+// 发给主机的是随机生成的 cookie,接入时校验后去掉认证信息再转发给浏览器
+type x11Forward struct {
+	cookie  []byte
+	mu      sync.Mutex
+	pending []x11Pending
+	notify  chan struct{}
+}
+
+// x11Pending 等待浏览器接入的连接
+type x11Pending struct {
+	ch ssh.NewChannel
+	at time.Time
+}
+
+// x11Request x11-req 请求内容 RFC 4254 6.3.1
+type x11Request struct {
+	SingleConnection bool
+	AuthProtocol     string
+	AuthCookie       string
+	ScreenNumber     uint32
+}
+
+// startX11 主机配置开启 X11 转发时在会话上请求转发,需要在启动 shell 之前调用
+func startX11(conn *SshConn) {
+	if conn.X11Forward != "Y" {
+		return
+	}
+	chans := conn.sshClient.HandleChannelOpen("x11")
+	if chans == nil {
+		return
+	}
+	cookie := make([]byte, 16)
+	if _, err := rand.Read(cookie); err != nil {
+		go rejectChannels(chans)
+		return
+	}
+	req := x11Request{AuthProtocol: x11AuthProto, AuthCookie: hex.EncodeToString(cookie)}
+	ok, err := conn.sshSession.SendRequest("x11-req", true, ssh.Marshal(&req))
+	if err != nil || !ok {
+		slog.Warn("x11-req rejected", "sid", conn.SessionId, "err", err)
+		conn.ws.Notice("\x1b[33mX11 forwarding request rejected by host\x1b[0m\r\n")
+		go rejectChannels(chans)
+		return
+	}
+	fwd := &x11Forward{cookie: cookie, notify: make(chan struct{}, x11MaxPending)}
+	conn.x11 = fwd
+	go fwd.serve(conn, chans)
+}
+
+// serve 接收主机发起的 x11 通道,通知浏览器接入,定时拒绝超时未接入的连接
+func (f *x11Forward) serve(conn *SshConn, chans <-chan ssh.NewChannel) {
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+	for {
+		select {
+		case ch, ok := <-chans:
+			if !ok {
+				// 连接关闭后拒绝剩余的通道
+				f.prune(time.Time{})
+				return
+			}
+			f.mu.Lock()
+			full := len(f.pending) >= x11MaxPending
+			if !full {
+				f.pending = append(f.pending, x11Pending{ch: ch, at: time.Now()})
+			}
+			f.mu.Unlock()
+			if full {
+				_ = ch.Reject(ssh.ResourceShortage, "too many pending x11 connections")
+				continue
+			}
+			select {
+			case f.notify <- struct{}{}:
+			default:
+			}
+			conn.ws.Event(TermEventX11, "")
+		case <-ticker.C:
+			f.prune(time.Now().Add(-x11AcceptTimeout))
+		}
+	}
+}
+
+// prune 拒绝 before 之前发起的连接,before 为零值时拒绝全部
+func (f *x11Forward) prune(before time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.pending) > 0 && (before.IsZero() || f.pending[0].at.Before(before)) {
+		_ = f.pending[0].ch.Reject(ssh.ConnectionFailed, "x11 display not attached")
+		f.pending = f.pending[1:]
+	}
+}
+
+// take 取出最早的等待中的连接,没有时等待 timeout
+func (f *x11Forward) take(timeout time.Duration) ssh.NewChannel {
+	deadline := time.After(timeout)
+	for {
+		f.mu.Lock()
+		if len(f.pending) > 0 {
+			ch := f.pending[0].ch
+			f.pending = f.pending[1:]
+			f.mu.Unlock()
+			return ch
+		}
+		f.mu.Unlock()
+		select {
+		case <-f.notify:
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+func rejectChannels(chans <-chan ssh.NewChannel) {
+	for ch := range chans {
+		_ = ch.Reject(ssh.Prohibited, "x11 forwarding not requested")
+	}
+}
+
+// x11Setup 读取客户端发送的连接建立请求,校验 cookie 后返回去掉认证信息的请求
+func x11Setup(r io.Reader, cookie []byte) ([]byte, error) {
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch head[0] {
+	case 'B':
+		order = binary.BigEndian
+	case 'l':
+		order = binary.LittleEndian
+	default:
+		return nil, errors.New("invalid x11 byte order")
+	}
+	nameLen, dataLen := int(order.Uint16(head[6:])), int(order.Uint16(head[8:]))
+	auth := make([]byte, pad4(nameLen)+pad4(dataLen))
+	if _, err := io.ReadFull(r, auth); err != nil {
+		return nil, err
+	}
+	name, data := auth[:nameLen], auth[pad4(nameLen):pad4(nameLen)+dataLen]
+	if string(name) != x11AuthProto || !bytes.Equal(data, cookie) {
+		return nil, errors.New("x11 auth cookie mismatch")
+	}
+	order.PutUint16(head[6:], 0)
+	order.PutUint16(head[8:], 0)
+	return head, nil
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// SshX11 GET 浏览器中的 X 服务通过 websocket 接入一个主机发起的 X11 连接,每个连接对应一个 websocket
+func SshX11(c *gin.Context) {
+	websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		conn, err := getSshConn(ws.Request().URL.Query().Get("session_id"))
+		if err != nil || conn == nil || conn.x11 == nil {
+			slog.Error("SshX11 getSshConn error")
+			return
+		}
+		if err := checkSessionOwner(conn, c.GetUint("uid"), c.RemoteIP()); err != nil {
+			slog.Warn("ssh x11 rejected", "sid", conn.SessionId, "client_ip", c.RemoteIP(), "err_msg", err.Error())
+			return
+		}
+
+		newCh := conn.x11.take(x11AcceptTimeout)
+		if newCh == nil {
+			return
+		}
+		ch, reqs, err := newCh.Accept()
+		if err != nil {
+			slog.Error("SshX11 accept error:", "err_msg", err.Error())
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		defer func() {
+			_ = ch.Close()
+		}()
+		setup, err := x11Setup(ch, conn.x11.cookie)
+		if err != nil {
+			slog.Warn("ssh x11 setup error", "sid", conn.SessionId, "err_msg", err.Error())
+			return
+		}
+		if _, err := ws.Write(setup); err != nil {
+			return
+		}
+		slog.Info("ssh x11 open", "sid", conn.SessionId, "client_ip", c.RemoteIP())
+
+		done := make(chan struct{}, 2)
+		go func() {
+			_, _ = io.Copy(ch, ws)
+			done <- struct{}{}
+		}()
+		go func() {
+			_, _ = io.Copy(ws, ch)
+			done <- struct{}{}
+		}()
+		<-done
+		conn.LastActiveTime = time.Now()
+	}).ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gossh/app/config"
+	"gossh/app/middleware"
+	"gossh/app/model"
+	"gossh/gin"
+	"html/template"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// 服务启动时间
+var serviceStartAt = time.Now()
+
+// ServiceStatus 公开的服务状态,不包含用户和主机信息
+type ServiceStatus struct {
+	Status      string              `json:"status"`
+	Database    string              `json:"database"`
+	Nodes       int                 `json:"nodes"`
+	Uptime      int64               `json:"uptime"`
+	StartAt     model.DateTime      `json:"start_at"`
+	CheckedAt   model.DateTime      `json:"checked_at"`
+	Maintenance []model.Maintenance `json:"maintenance"`
+}
+
+// getServiceStatus 检查数据库连接和计划维护
+// 当前为单节点部署,节点数为 1
+func getServiceStatus() ServiceStatus {
+	now := time.Now()
+	status := ServiceStatus{
+		Status:      "ok",
+		Database:    "ok",
+		Nodes:       1,
+		Uptime:      int64(now.Sub(serviceStartAt).Seconds()),
+		StartAt:     model.DateTime(serviceStartAt),
+		CheckedAt:   model.DateTime(now),
+		Maintenance: []model.Maintenance{},
+	}
+	if !config.DefaultConfig.IsInit {
+		status.Status, status.Database = "not_initialized", "unknown"
+		return status
+	}
+
+	// 熔断期间不再探测数据库
+	if ok, _ := model.DbAvailable(); !ok {
+		status.Status, status.Database = "degraded", "error"
+		return status
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	if err := model.DbPing(ctx); err != nil {
+		slog.Error("status DbPing error:", "err_msg", err.Error())
+		status.Status, status.Database = "degraded", "error"
+		return status
+	}
+
+	var maintenance model.Maintenance
+	list, err := maintenance.FindUpcoming(now)
+	if err != nil {
+		slog.Error("FindUpcoming error:", "err_msg", err.Error())
+		return status
+	}
+	status.Maintenance = list
+	for _, item := range list {
+		if !now.Before(time.Time(item.StartAt)) {
+			status.Status = "maintenance"
+		}
+	}
+	return status
+}
+
+// ServiceStatusGet GET 公开的服务状态,无需登录
+func ServiceStatusGet(c *gin.Context) {
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": getServiceStatus()})
+}
+
+var statusPageTpl = template.Must(template.New("status").Funcs(template.FuncMap{
+	"statusText": func(status string) string {
+		switch status {
+		case "ok":
+			return "服务正常"
+		case "maintenance":
+			return "维护中"
+		case "degraded":
+			return "服务异常"
+		default:
+			return "系统未初始化"
+		}
+	},
+	"duration": func(sec int64) string {
+		return (time.Duration(sec) * time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.AppName}} 服务状态</title>
+<style nonce="{{.Nonce}}">
+body{font-family:sans-serif;max-width:640px;margin:40px auto;padding:0 16px;color:#333}
+.status{padding:16px;border-radius:6px;color:#fff;font-size:20px}
+.ok{background:#2e7d32}.maintenance{background:#ef6c00}.degraded{background:#c62828}.not_initialized{background:#757575}
+table{width:100%;margin-top:16px;border-collapse:collapse}td{padding:6px 0;border-bottom:1px solid #eee}
+.notice{margin-top:16px;padding:12px;border-left:4px solid #ef6c00;background:#fff8e1}
+.muted{color:#888;font-size:12px}
+</style>
+</head>
+<body>
+<h2>{{.AppName}} 服务状态</h2>
+<div class="status {{.Status.Status}}">{{statusText .Status.Status}}</div>
+<table>
+<tr><td>数据库</td><td>{{.Status.Database}}</td></tr>
+<tr><td>在线节点</td><td>{{.Status.Nodes}}</td></tr>
+<tr><td>运行时间</td><td>{{duration .Status.Uptime}}</td></tr>
+</table>
+{{range .Status.Maintenance}}
+<div class="notice"><b>{{.Title}}</b><div class="muted">{{.StartAt}} ~ {{.EndAt}}</div><p>{{.Content}}</p></div>
+{{end}}
+<p class="muted">检查时间: {{.Status.CheckedAt}},如果服务正常但无法连接,请检查本地网络</p>
+</body>
+</html>
+`))
+
+// ServiceStatusPage GET 服务状态页面
+func ServiceStatusPage(c *gin.Context) {
+	var buf bytes.Buffer
+	err := statusPageTpl.Execute(&buf, map[string]any{
+		"AppName": loadBranding().ProductName,
+		"Status":  getServiceStatus(),
+		"Nonce":   c.GetString(middleware.CspNonceKey),
+	})
+	if err != nil {
+		slog.Error("status page error:", "err_msg", err.Error())
+		c.String(500, "status page error")
+		return
+	}
+	c.Data(200, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// checkMaintenance 检查维护时间段
+func checkMaintenance(maintenance model.Maintenance) error {
+	if !time.Time(maintenance.EndAt).After(time.Time(maintenance.StartAt)) {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	return nil
+}
+
+func MaintenanceCreate(c *gin.Context) {
+	var maintenance model.Maintenance
+	if err := c.ShouldBind(&maintenance); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkMaintenance(maintenance); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if err := maintenance.Create(&maintenance); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	MaintenanceFindAll(c)
+}
+
+func MaintenanceFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var maintenance model.Maintenance
+	data, err := maintenance.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+func MaintenanceFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var maintenance model.Maintenance
+	data, total, err := maintenance.FindPage(q)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func MaintenanceUpdateById(c *gin.Context) {
+	var maintenance model.Maintenance
+	if err := c.ShouldBind(&maintenance); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkMaintenance(maintenance); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if err := maintenance.UpdateById(maintenance.ID, &maintenance); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	MaintenanceFindAll(c)
+}
+
+func MaintenanceDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var maintenance model.Maintenance
+	if err := maintenance.DeleteByID(uint(id)); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	MaintenanceFindAll(c)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"time"
+)
+
+// 存储空间清理间隔
+const storageCleanInterval = time.Hour
+
+// removeRecord 删除录像文件和记录
+func removeRecord(record model.SessionRecord) error {
+	if err := artifactStorage(record.Storage).Remove(record.FilePath); err != nil {
+		return err
+	}
+	return record.DeleteByID(record.ID)
+}
+
+// cleanExpiredRecord 删除超过保留时间的录像
+func cleanExpiredRecord(retention time.Duration) {
+	var sessionRecord model.SessionRecord
+	list, err := sessionRecord.FindFinishedBefore(time.Now().Add(-retention))
+	if err != nil {
+		slog.Error("FindFinishedBefore error:", "err_msg", err.Error())
+		return
+	}
+	for _, record := range list {
+		if err := removeRecord(record); err != nil {
+			slog.Error("remove expired record error:", "id", record.ID, "err_msg", err.Error())
+			continue
+		}
+		slog.Info("expired record removed", "id", record.ID, "uid", record.Uid)
+	}
+}
+
+// cleanOverQuotaRecord 用户录像超过配额时从最早的录像开始删除
+func cleanOverQuotaRecord(quota int64) {
+	var sessionRecord model.SessionRecord
+	usages, err := sessionRecord.UsageGroupByUid()
+	if err != nil {
+		slog.Error("UsageGroupByUid error:", "err_msg", err.Error())
+		return
+	}
+	for _, usage := range usages {
+		if usage.Size <= quota {
+			continue
+		}
+		list, err := sessionRecord.FindFinishedByUid(usage.Uid)
+		if err != nil {
+			slog.Error("FindFinishedByUid error:", "err_msg", err.Error())
+			continue
+		}
+		size := usage.Size
+		for _, record := range list {
+			if size <= quota {
+				break
+			}
+			if err := removeRecord(record); err != nil {
+				slog.Error("remove over quota record error:", "id", record.ID, "err_msg", err.Error())
+				continue
+			}
+			size -= record.Size
+			slog.Info("over quota record removed", "id", record.ID, "uid", record.Uid)
+		}
+	}
+}
+
+// cleanStorage 按保留时间和用户配额清理服务器上的用户文件
+func cleanStorage() {
+	defer utils.RecoverPanic("cleanStorage")
+	limits := config.DefaultConfig.Limits
+	if limits.RecordRetention > 0 {
+		cleanExpiredRecord(limits.RecordRetention)
+	}
+	if limits.UserDiskQuota > 0 {
+		cleanOverQuotaRecord(limits.UserDiskQuota)
+	}
+}
+
+func storageCleanLoop() {
+	for {
+		time.Sleep(storageCleanInterval)
+		if backgroundEnabled() {
+			cleanStorage()
+		}
+	}
+}
+
+// UserUsage GET 当前用户在服务器上占用的存储空间和配额
+func UserUsage(c *gin.Context) {
+	var sessionRecord model.SessionRecord
+	usage, err := sessionRecord.UsageByUid(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	limits := config.DefaultConfig.Limits
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"record_count":     usage.Count,
+		"record_size":      usage.Size,
+		"used":             usage.Size,
+		"quota":            limits.UserDiskQuota,
+		"record_retention": limits.RecordRetention,
+	}})
+}
+
+func init() {
+	go storageCleanLoop()
+}
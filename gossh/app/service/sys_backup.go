@@ -0,0 +1,413 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"gossh/mysql"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 备份文件名: gossh_20240102150405.sql.gz
+var backupNameRe = regexp.MustCompile(`^gossh_\d{14}\.sql\.gz$`)
+
+// 同一时间只允许一个备份或恢复任务
+var backupMu sync.Mutex
+
+// BackupFile 备份文件信息
+type BackupFile struct {
+	Name      string         `json:"name"`
+	Size      int64          `json:"size"`
+	Storage   string         `json:"storage"`
+	CreatedAt model.DateTime `json:"created_at"`
+}
+
+// backupDir 本地备份目录
+func backupDir() string {
+	if dir := config.DefaultConfig.Backup.Dir; dir != "" {
+		return dir
+	}
+	return path.Join(config.WorkDir, "backups")
+}
+
+func backupS3() *utils.S3Client {
+	if config.DefaultConfig.Backup.S3.Bucket == "" {
+		return nil
+	}
+	return newS3Client(config.DefaultConfig.Backup.S3)
+}
+
+// mysqlArgs 从 DSN 生成 mysql 命令行客户端的连接参数,密码通过环境变量传递
+func mysqlArgs(dsn string) ([]string, []string, string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	args := []string{"-u", cfg.User}
+	if cfg.Net == "unix" {
+		args = append(args, "--socket", cfg.Addr)
+	} else {
+		host, port, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		args = append(args, "-h", host, "-P", port, "--protocol", "TCP")
+	}
+	return args, append(os.Environ(), "MYSQL_PWD="+cfg.Passwd), cfg.DBName, nil
+}
+
+// dumpDatabase 调用 mysqldump 或 pg_dump 导出数据库
+func dumpDatabase(w io.Writer) error {
+	dbType, dsn := config.DefaultConfig.DbType, config.DefaultConfig.DbDsn
+	var cmd *exec.Cmd
+	switch dbType {
+	case "mysql":
+		args, env, dbName, err := mysqlArgs(dsn)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--single-transaction", "--routines", "--triggers", "--no-tablespaces", dbName)
+		cmd = exec.Command("mysqldump", args...)
+		cmd.Env = env
+	case "pgsql":
+		cmd = exec.Command("pg_dump", "--clean", "--if-exists", "--no-owner", "--dbname="+dsn)
+	default:
+		return fmt.Errorf("unsupported db type: %s", dbType)
+	}
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// restoreDatabase 调用 mysql 或 psql 导入备份
+func restoreDatabase(r io.Reader) error {
+	dbType, dsn := config.DefaultConfig.DbType, config.DefaultConfig.DbDsn
+	var cmd *exec.Cmd
+	switch dbType {
+	case "mysql":
+		args, env, dbName, err := mysqlArgs(dsn)
+		if err != nil {
+			return err
+		}
+		cmd = exec.Command("mysql", append(args, dbName)...)
+		cmd.Env = env
+	case "pgsql":
+		cmd = exec.Command("psql", "--quiet", "--set", "ON_ERROR_STOP=1", "--dbname="+dsn)
+	default:
+		return fmt.Errorf("unsupported db type: %s", dbType)
+	}
+	var stderr bytes.Buffer
+	cmd.Stdin = r
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// listBackups 列出所有备份,最新的在前
+func listBackups() ([]BackupFile, error) {
+	list := []BackupFile{}
+	if s3 := backupS3(); s3 != nil {
+		prefix := config.DefaultConfig.Backup.S3.Prefix
+		objects, err := s3.ListObjects(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			if backupNameRe.MatchString(name) {
+				list = append(list, BackupFile{Name: name, Size: obj.Size, Storage: "s3", CreatedAt: model.DateTime(obj.LastModified.Local())})
+			}
+		}
+	} else {
+		entries, err := os.ReadDir(backupDir())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() || !backupNameRe.MatchString(entry.Name()) {
+				continue
+			}
+			list = append(list, BackupFile{Name: entry.Name(), Size: info.Size(), Storage: "local", CreatedAt: model.DateTime(info.ModTime())})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name > list[j].Name
+	})
+	return list, nil
+}
+
+// pruneBackups 按保留数量和保留时长清理旧备份,最新的备份始终保留
+func pruneBackups() {
+	conf := config.DefaultConfig.Backup
+	if conf.Keep <= 0 && conf.Retention <= 0 {
+		return
+	}
+	list, err := listBackups()
+	if err != nil {
+		slog.Error("list backups error:", "err_msg", err.Error())
+		return
+	}
+	s3 := backupS3()
+	for i, file := range list {
+		if i == 0 {
+			continue
+		}
+		expired := conf.Retention > 0 && time.Since(time.Time(file.CreatedAt)) > conf.Retention
+		if !expired && (conf.Keep <= 0 || i < conf.Keep) {
+			continue
+		}
+		if s3 != nil {
+			err = s3.DeleteObject(conf.S3.Prefix + file.Name)
+		} else {
+			err = os.Remove(path.Join(backupDir(), file.Name))
+		}
+		if err != nil {
+			slog.Error("delete backup error:", "name", file.Name, "err_msg", err.Error())
+			continue
+		}
+		slog.Info("backup pruned", "name", file.Name)
+	}
+}
+
+// runBackup 导出数据库并压缩,配置了对象存储时上传后删除本地文件
+func runBackup() (string, error) {
+	if !backupMu.TryLock() {
+		return "", errors.New("备份或恢复任务正在进行中")
+	}
+	defer backupMu.Unlock()
+
+	if err := os.MkdirAll(backupDir(), os.FileMode(0700)); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("gossh_%s.sql.gz", time.Now().Format("20060102150405"))
+	filePath := path.Join(backupDir(), name)
+	file, err := os.OpenFile(filePath+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(file)
+	err = dumpDatabase(gz)
+	if e := gz.Close(); err == nil {
+		err = e
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(filePath+".tmp", filePath)
+	}
+	if err != nil {
+		_ = os.Remove(filePath + ".tmp")
+		return "", err
+	}
+
+	if s3 := backupS3(); s3 != nil {
+		if err := uploadBackup(s3, config.DefaultConfig.Backup.S3.Prefix+name, filePath); err != nil {
+			return "", err
+		}
+		_ = os.Remove(filePath)
+	}
+	slog.Info("database backup finished", "name", name)
+	pruneBackups()
+	return name, nil
+}
+
+func uploadBackup(s3 *utils.S3Client, key, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return s3.PutObject(key, file, info.Size())
+}
+
+// restoreBackup 使用指定的备份恢复数据库
+func restoreBackup(name string) error {
+	if !backupNameRe.MatchString(name) {
+		return errors.New("备份文件名错误")
+	}
+	if !backupMu.TryLock() {
+		return errors.New("备份或恢复任务正在进行中")
+	}
+	defer backupMu.Unlock()
+
+	var reader io.ReadCloser
+	var err error
+	conf := config.DefaultConfig.Backup
+	if s3 := backupS3(); s3 != nil {
+		reader, err = s3.GetObject(conf.S3.Prefix + name)
+	} else {
+		reader, err = os.Open(path.Join(backupDir(), name))
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return err
+	}
+	return restoreDatabase(gz)
+}
+
+func backupLoop() {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(next))
+		conf := config.DefaultConfig.Backup
+		if !backgroundEnabled() || conf.Cron == "" {
+			continue
+		}
+		schedule, err := utils.ParseCron(conf.Cron)
+		if err != nil {
+			slog.Error("backup cron error:", "err_msg", err.Error())
+			continue
+		}
+		if !schedule.Match(next) {
+			continue
+		}
+		if _, err := runBackup(); err != nil {
+			slog.Error("database backup error:", "err_msg", err.Error())
+		}
+	}
+}
+
+// BackupFindAll GET 备份列表
+func BackupFindAll(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	list, err := listBackups()
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": list})
+}
+
+// BackupCreate POST 立即备份
+func BackupCreate(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	name, err := runBackup()
+	if err != nil {
+		slog.Error("database backup error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": name})
+}
+
+// BackupRestore POST 使用备份恢复数据库
+func BackupRestore(c *gin.Context) {
+	type Param struct {
+		Name string `form:"name" binding:"required" json:"name"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	if err := restoreBackup(param.Name); err != nil {
+		slog.Error("database restore error:", "name", param.Name, "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("database restored", "name", param.Name, "operator", u.Name)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
+
+// GetBackupConf GET 备份配置和下次备份时间
+func GetBackupConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	conf := config.DefaultConfig.Backup
+	nextRun := ""
+	if schedule, err := utils.ParseCron(conf.Cron); err == nil {
+		if next := schedule.Next(time.Now()); !next.IsZero() {
+			nextRun = next.Format(model.TimeFormat)
+		}
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": conf, "next_run": nextRun})
+}
+
+// SetBackupConf PUT 修改备份配置,立即生效并写入配置文件
+func SetBackupConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var backup config.Backup
+	if err := c.ShouldBindJSON(&backup); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if backup.Cron != "" {
+		if _, err := utils.ParseCron(backup.Cron); err != nil {
+			c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+			return
+		}
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Backup = backup
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("backup config updated", "user", u.Name, "cron", backup.Cron)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Backup})
+}
+
+func init() {
+	go backupLoop()
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// 导入文件的最大大小
+const bundleMaxSize = 64 * 1024 * 1024
+
+// SysExport POST 导出加密的系统配置包
+func SysExport(c *gin.Context) {
+	type Param struct {
+		Passphrase string `form:"passphrase" binding:"required,min=8,max=128" json:"passphrase"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+
+	bundle, err := model.ExportBundle()
+	if err != nil {
+		slog.Error("ExportBundle error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": "导出配置错误"})
+		return
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	encrypted, err := utils.EncryptWithPassword(param.Passphrase, data)
+	if err != nil {
+		slog.Error("EncryptWithPassword error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 5, "msg": "加密配置错误"})
+		return
+	}
+
+	slog.Info("sys config exported", "operator", u.Name, "users", len(bundle.Users), "conn_conf", len(bundle.SshConfs))
+	fileName := fmt.Sprintf("gossh_%s.bundle", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	c.Data(200, "application/octet-stream", encrypted)
+}
+
+// SysImport POST 导入加密的系统配置包
+func SysImport(c *gin.Context) {
+	type Param struct {
+		Passphrase string `form:"passphrase" binding:"required,min=8,max=128" json:"passphrase"`
+		Conflict   string `form:"conflict" binding:"omitempty,oneof=skip overwrite fail" json:"conflict"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if param.Conflict == "" {
+		param.Conflict = "skip"
+	}
+
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": "获取上传文件错误"})
+		return
+	}
+	if file.Size > bundleMaxSize {
+		c.JSON(200, gin.H{"code": 3, "msg": "上传文件过大"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(f, bundleMaxSize))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+
+	plain, err := utils.DecryptWithPassword(param.Passphrase, data)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": "解密失败,口令错误或文件已损坏"})
+		return
+	}
+	var bundle model.SysBundle
+	if err := json.Unmarshal(plain, &bundle); err != nil {
+		c.JSON(200, gin.H{"code": 5, "msg": "配置包格式错误"})
+		return
+	}
+
+	result, err := model.ImportBundle(bundle, param.Conflict)
+	if err != nil {
+		slog.Error("ImportBundle error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 6, "msg": err.Error()})
+		return
+	}
+	slog.Info("sys config imported", "operator", u.Name, "conflict", param.Conflict, "version", bundle.Version)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": result})
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"net/url"
+)
+
+// applyErrorReport 按配置设置 panic 记录的上报地址
+func applyErrorReport(conf config.ErrorReport) {
+	utils.ConfigureErrorReport(utils.ErrorReportConfig{
+		SentryDsn:   conf.SentryDsn,
+		Environment: conf.Environment,
+		Release:     conf.Release,
+	})
+}
+
+func init() {
+	applyErrorReport(config.DefaultConfig.ErrorReport)
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if old.ErrorReport != conf.ErrorReport {
+			applyErrorReport(conf.ErrorReport)
+		}
+	})
+}
+
+// SysErrorFindAll GET 最近的 panic 记录,包含堆栈、请求信息和发生前的日志
+func SysErrorFindAll(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": utils.PanicReports()})
+}
+
+// SysErrorClear DELETE 清空 panic 记录
+func SysErrorClear(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	utils.ClearPanicReports()
+	slog.Info("panic reports cleared", "user", u.Name)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
+
+// SetErrorReportConf PUT 设置 panic 上报配置,立即生效
+func SetErrorReportConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var report config.ErrorReport
+	if err := c.ShouldBindJSON(&report); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if report.SentryDsn != "" {
+		if dsn, err := url.Parse(report.SentryDsn); err != nil || (dsn.Scheme != "http" && dsn.Scheme != "https") || dsn.User == nil || dsn.Host == "" {
+			c.JSON(200, gin.H{"code": 1, "msg": "Sentry DSN 格式错误"})
+			return
+		}
+	}
+	appConfig := config.DefaultConfig
+	appConfig.ErrorReport = report
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("error report config updated", "user", u.Name, "environment", report.Environment, "release", report.Release)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.ErrorReport})
+}
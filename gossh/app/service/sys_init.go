@@ -4,28 +4,42 @@ import (
 	"gossh/app/config"
 	"gossh/app/model"
 	"gossh/gin"
+	"log/slog"
 )
 
 func GetRunConf(c *gin.Context) {
 	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig})
 }
 
+// SetRunConf 修改运行配置,各模块监听配置变更后立即生效
+// 数据库连接只能在初始化时设置,返回的 restart 为需要重启才能生效的配置
 func SetRunConf(c *gin.Context) {
-	if config.DefaultConfig.IsInit {
-		c.JSON(200, gin.H{"code": 1, "msg": "已经初始化"})
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || !isPlatformAdmin(u) {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
 		return
 	}
 	var appConfig config.AppConfig
 	if err := c.ShouldBind(&appConfig); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
-	err := config.RewriteConfig(appConfig)
-	if err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+	old := config.DefaultConfig
+	appConfig.IsInit, appConfig.DbType, appConfig.DbDsn = old.IsInit, old.DbType, old.DbDsn
+	if appConfig.JwtSecret == "" {
+		appConfig.JwtSecret = old.JwtSecret
+	}
+	if appConfig.SessionSecret == "" {
+		appConfig.SessionSecret = old.SessionSecret
+	}
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig})
+	restart := config.RestartFields(old, appConfig)
+	slog.Info("run config updated", "user", u.Name, "restart", restart)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig, "restart": restart})
 }
 
 func GetIsInit(c *gin.Context) {
@@ -47,7 +61,7 @@ type InitConfig struct {
 func SysInit(c *gin.Context) {
 	var initConf InitConfig
 	if err := c.ShouldBind(&initConf); err != nil {
-		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		bindError(c, 1, err)
 		return
 	}
 
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+)
+
+// checkSessionLimit 检查系统、用户和租户的在线会话数量限制,重连使用原会话ID时不计入
+func checkSessionLimit(uid, tenantId uint, sessionId string) error {
+	limits := config.DefaultConfig.Limits
+	userQuota, err := userQuota(uid)
+	if err != nil {
+		return err
+	}
+	tenantQuota, err := tenantQuota(tenantId)
+	if err != nil {
+		return err
+	}
+	if limits.MaxSessions <= 0 && userQuota.MaxSessions <= 0 && tenantQuota.MaxSessions <= 0 {
+		return nil
+	}
+	total, userTotal, tenantTotal := onlineSessions(uid, tenantId, sessionId)
+	if limits.MaxSessions > 0 && total >= limits.MaxSessions {
+		return errors.New("在线会话数量已达到系统上限")
+	}
+	if userQuota.MaxSessions > 0 && userTotal >= userQuota.MaxSessions {
+		return errors.New("在线会话数量已达到用户上限")
+	}
+	if tenantQuota.MaxSessions > 0 && tenantTotal >= tenantQuota.MaxSessions {
+		return errors.New("在线会话数量已达到租户上限")
+	}
+	return nil
+}
+
+// GetSysLimits GET 运行限制,前端根据限制调整行为
+func GetSysLimits(c *gin.Context) {
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Limits})
+}
+
+// SetSysLimits PUT 修改运行限制,立即生效并写入配置文件
+func SetSysLimits(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var limits config.Limits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Limits = limits
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("sys limits updated", "user", u.Name, "limits", limits)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Limits})
+}
+
+// SetSessionCleanConf PUT 修改会话清理配置,下一轮清理生效
+func SetSessionCleanConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var clean config.SessionClean
+	if err := c.ShouldBindJSON(&clean); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	appConfig := config.DefaultConfig
+	appConfig.SessionClean = clean
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("session clean config updated", "user", u.Name, "interval", clean.Interval, "stale_timeout", clean.StaleTimeout, "window_grace", clean.WindowGrace)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.SessionClean})
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+)
+
+// appliedLog 当前生效的日志配置
+var appliedLog config.Log
+
+// applyLog 按配置设置日志级别、格式和输出文件
+func applyLog(conf config.Log) error {
+	err := utils.ConfigureLogger(utils.LogConfig{
+		Level:      conf.Level,
+		Format:     conf.Format,
+		File:       conf.File,
+		MaxSize:    conf.MaxSize << 20,
+		MaxBackups: conf.MaxBackups,
+	})
+	if err == nil {
+		appliedLog = conf
+	}
+	return err
+}
+
+func init() {
+	if err := applyLog(config.DefaultConfig.Log); err != nil {
+		slog.Error("apply log config error:", "err_msg", err.Error())
+	}
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if conf.Log == appliedLog {
+			return
+		}
+		if err := applyLog(conf.Log); err != nil {
+			slog.Error("apply log config error:", "err_msg", err.Error())
+		}
+	})
+}
+
+// SetLogConf 设置日志配置,立即生效
+func SetLogConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var logConf config.Log
+	if err := c.ShouldBindJSON(&logConf); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := applyLog(logConf); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Log = logConf
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("log config updated", "user", u.Name, "level", logConf.Level, "format", logConf.Format, "file", logConf.File)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Log})
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"gossh/app/model"
+	"gossh/gin"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// metric 运行指标,通过 /api/sys/metrics 以 Prometheus 文本格式输出
+type metric struct {
+	name  string
+	help  string
+	kind  string // counter 或 gauge
+	value atomic.Int64
+}
+
+func (m *metric) Add(delta int64) {
+	m.value.Add(delta)
+}
+
+func (m *metric) Set(value int64) {
+	m.value.Store(value)
+}
+
+var (
+	metricMu sync.Mutex
+	metrics  = map[string]*metric{}
+)
+
+// newMetric 注册指标,同名指标只注册一次
+func newMetric(name, help, kind string) *metric {
+	metricMu.Lock()
+	defer metricMu.Unlock()
+	if m, ok := metrics[name]; ok {
+		return m
+	}
+	m := &metric{name: name, help: help, kind: kind}
+	metrics[name] = m
+	return m
+}
+
+func newCounter(name, help string) *metric {
+	return newMetric(name, help, "counter")
+}
+
+func newGauge(name, help string) *metric {
+	return newMetric(name, help, "gauge")
+}
+
+// 输出指标时统计的在线会话数量和数据库状态
+var (
+	onlineSessionGauge = newGauge("gossh_online_sessions", "Number of online terminal sessions.")
+	dbAvailableGauge   = newGauge("gossh_db_available", "Whether the database circuit breaker is closed (1) or open (0).")
+)
+
+// writeMetrics 按名称排序输出所有指标
+func writeMetrics(sb *strings.Builder) {
+	metricMu.Lock()
+	list := make([]*metric, 0, len(metrics))
+	for _, m := range metrics {
+		list = append(list, m)
+	}
+	metricMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	for _, m := range list {
+		fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value.Load())
+	}
+}
+
+// SysMetrics GET 运行指标,Prometheus 文本格式,只允许管理员访问
+func SysMetrics(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var online int64
+	OnlineClients.Range(func(key, value any) bool {
+		online++
+		return true
+	})
+	onlineSessionGauge.Set(online)
+	if ok, _ := model.DbAvailable(); ok {
+		dbAvailableGauge.Set(1)
+	} else {
+		dbAvailableGauge.Set(0)
+	}
+
+	var sb strings.Builder
+	writeMetrics(&sb)
+	c.Data(200, "text/plain; version=0.0.4; charset=utf-8", []byte(sb.String()))
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"strings"
+)
+
+// SetSecurityConf 设置安全响应头,立即生效
+func SetSecurityConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var security config.Security
+	if err := c.ShouldBindJSON(&security); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if strings.ContainsAny(security.Csp, "\r\n") {
+		c.JSON(200, gin.H{"code": 1, "msg": "CSP 不能包含换行"})
+		return
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Security = security
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("security headers updated", "user", u.Name, "csp", security.Csp, "hsts_max_age", security.HstsMaxAge.String())
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Security})
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/app/utils"
+	"gossh/gin"
+	"log/slog"
+	"net/url"
+	"reflect"
+)
+
+// applyTracing 按配置启用或停用链路追踪
+func applyTracing(conf config.Tracing) {
+	name := conf.ServiceName
+	if name == "" {
+		name = config.DefaultConfig.AppName
+	}
+	utils.ConfigureTracing(utils.TraceConfig{
+		Endpoint:    conf.Endpoint,
+		ServiceName: name,
+		SampleRatio: conf.SampleRatio,
+		Headers:     conf.Headers,
+	})
+}
+
+func init() {
+	applyTracing(config.DefaultConfig.Tracing)
+	config.Subscribe(func(old, conf config.AppConfig) {
+		if old.AppName != conf.AppName || !reflect.DeepEqual(old.Tracing, conf.Tracing) {
+			applyTracing(conf.Tracing)
+		}
+	})
+}
+
+// SetTracingConf 设置链路追踪配置,立即生效
+func SetTracingConf(c *gin.Context) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsAdmin == "N" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+		return
+	}
+	var tracing config.Tracing
+	if err := c.ShouldBindJSON(&tracing); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if tracing.Endpoint != "" {
+		if endpoint, err := url.Parse(tracing.Endpoint); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			c.JSON(200, gin.H{"code": 1, "msg": "追踪接收地址格式错误"})
+			return
+		}
+	}
+	appConfig := config.DefaultConfig
+	appConfig.Tracing = tracing
+	if err := config.RewriteConfig(appConfig); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	slog.Info("tracing config updated", "user", u.Name, "endpoint", tracing.Endpoint, "sample_ratio", tracing.SampleRatio)
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": config.DefaultConfig.Tracing})
+}
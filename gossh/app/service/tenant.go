@@ -0,0 +1,281 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/middleware"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tenantDomainRe  = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	tenantBaseDirRe = regexp.MustCompile(`^/[a-z0-9][a-z0-9_-]{0,62}$`)
+)
+
+// 不能用作租户路径前缀的系统路径
+var tenantReservedDirs = map[string]bool{"/api": true, "/app": true, "/status": true}
+
+// tenantScope 当前请求操作的租户,Root 用户可以通过 tenant_id 参数指定租户
+func tenantScope(c *gin.Context, u model.SshUser) uint {
+	if u.IsRoot == "Y" {
+		if id, err := strconv.ParseUint(c.Query("tenant_id"), 10, 64); err == nil {
+			return uint(id)
+		}
+	}
+	return u.TenantId
+}
+
+// isPlatformAdmin 默认租户的管理员,可以查看和处理所有租户的审批、录像等数据
+func isPlatformAdmin(u model.SshUser) bool {
+	return u.IsAdmin == "Y" && u.TenantId == 0
+}
+
+// requestTenant 查询当前用户并返回请求操作的租户
+func requestTenant(c *gin.Context) (uint, error) {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil {
+		return 0, err
+	}
+	return tenantScope(c, u), nil
+}
+
+// findTenantPolicy 查询当前租户的策略,不存在或属于其他租户时返回错误响应
+func findTenantPolicy(c *gin.Context, id uint) (model.PolicyConf, bool) {
+	var conf model.PolicyConf
+	tenantId, err := requestTenant(c)
+	if err == nil {
+		conf, err = conf.FindByID(id)
+	}
+	if err == nil && conf.TenantId != tenantId {
+		err = errors.New("策略不存在")
+	}
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return conf, false
+	}
+	return conf, true
+}
+
+// userPolicy 查询用户所属租户生效的策略
+func userPolicy(uid uint) (model.PolicyConf, error) {
+	var user model.SshUser
+	var policyConf model.PolicyConf
+	u, err := user.FindByID(uid)
+	if err != nil {
+		return policyConf.FindByID(1)
+	}
+	return policyConf.FindByTenant(u.TenantId)
+}
+
+// tenantPolicies 按租户缓存策略,用于遍历在线会话时避免重复查询
+func tenantPolicies() func(tenantId uint) (model.PolicyConf, bool) {
+	cache := map[uint]model.PolicyConf{}
+	failed := map[uint]bool{}
+	return func(tenantId uint) (model.PolicyConf, bool) {
+		if policy, ok := cache[tenantId]; ok {
+			return policy, true
+		}
+		if failed[tenantId] {
+			return model.PolicyConf{}, false
+		}
+		var policyConf model.PolicyConf
+		policy, err := policyConf.FindByTenant(tenantId)
+		if err != nil {
+			failed[tenantId] = true
+			return policy, false
+		}
+		cache[tenantId] = policy
+		return policy, true
+	}
+}
+
+// checkTenantLogin 校验用户所属租户已启用,通过租户专用入口登录时只允许该租户的用户
+func checkTenantLogin(c *gin.Context, u model.SshUser) error {
+	if id, ok := middleware.HostTenant(c.Request); ok && id != u.TenantId {
+		return errors.New("账号密码错误")
+	}
+	if u.TenantId == 0 {
+		return nil
+	}
+	var tenant model.Tenant
+	data, err := tenant.FindByID(u.TenantId)
+	if err != nil || data.IsEnable != "Y" {
+		return errors.New("租户已禁用")
+	}
+	return nil
+}
+
+// checkTenant 校验租户名称、域名和路径前缀,域名和路径前缀不能与其他租户重复
+func checkTenant(tenant *model.Tenant) error {
+	tenant.Name = strings.TrimSpace(tenant.Name)
+	tenant.Domain = strings.ToLower(strings.TrimSpace(tenant.Domain))
+	tenant.WebBaseDir = strings.TrimSuffix(strings.TrimSpace(tenant.WebBaseDir), "/")
+	if tenant.Domain != "" && !tenantDomainRe.MatchString(tenant.Domain) {
+		return errors.New("无效的租户域名")
+	}
+	if tenant.WebBaseDir != "" && (!tenantBaseDirRe.MatchString(tenant.WebBaseDir) || tenantReservedDirs[tenant.WebBaseDir]) {
+		return errors.New("无效的租户访问路径")
+	}
+	list, err := tenant.FindAll()
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.ID == tenant.ID {
+			continue
+		}
+		if tenant.Domain != "" && item.Domain == tenant.Domain {
+			return errors.New("租户域名已被使用")
+		}
+		if tenant.WebBaseDir != "" && item.WebBaseDir == tenant.WebBaseDir {
+			return errors.New("租户访问路径已被使用")
+		}
+	}
+	return nil
+}
+
+// isRootUser 租户管理只允许 Root 用户操作
+func isRootUser(c *gin.Context) bool {
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err != nil || u.IsRoot != "Y" {
+		c.JSON(200, gin.H{"code": 2, "msg": "非超级管理员拒绝操作"})
+		return false
+	}
+	return true
+}
+
+func TenantFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if !isRootUser(c) {
+		return
+	}
+	var tenant model.Tenant
+	data, total, err := tenant.FindPage(q)
+	if err != nil {
+		slog.Error("Tenant.FindPage error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total})
+}
+
+func TenantFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "获取ID错误"})
+		return
+	}
+	if !isRootUser(c) {
+		return
+	}
+	var tenant model.Tenant
+	data, err := tenant.FindByID(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	count, err := tenant.UserCount(data.ID)
+	if err != nil {
+		slog.Error("Tenant.UserCount error:", "err_msg", err.Error())
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "user_count": count})
+}
+
+func TenantCreate(c *gin.Context) {
+	var tenant model.Tenant
+	if err := c.ShouldBind(&tenant); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if !isRootUser(c) {
+		return
+	}
+	tenant.ID = 0
+	if err := checkTenant(&tenant); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := tenant.Create(&tenant); err != nil {
+		slog.Error("Tenant.Create error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	middleware.ReloadTenants()
+	slog.Info("tenant created", "id", tenant.ID, "name", tenant.Name, "uid", c.GetUint("uid"))
+	TenantFindAll(c)
+}
+
+func TenantUpdateById(c *gin.Context) {
+	var tenant model.Tenant
+	if err := c.ShouldBind(&tenant); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if !isRootUser(c) {
+		return
+	}
+	if _, err := tenant.FindByID(tenant.ID); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if err := checkTenant(&tenant); err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+	if err := tenant.UpdateById(tenant.ID, &tenant); err != nil {
+		slog.Error("Tenant.UpdateById error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	middleware.ReloadTenants()
+	slog.Info("tenant updated", "id", tenant.ID, "name", tenant.Name, "is_enable", tenant.IsEnable, "uid", c.GetUint("uid"))
+	TenantFindAll(c)
+}
+
+// TenantDeleteById 只能删除没有用户的租户,同时删除租户的策略
+func TenantDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": "获取ID错误"})
+		return
+	}
+	if !isRootUser(c) {
+		return
+	}
+	var tenant model.Tenant
+	count, err := tenant.UserCount(uint(id))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(200, gin.H{"code": 4, "msg": "租户下还有用户,不能删除"})
+		return
+	}
+	if err := tenant.DeleteByID(uint(id)); err != nil {
+		slog.Error("Tenant.DeleteByID error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	var policyConf model.PolicyConf
+	if err := policyConf.DeleteByTenant(uint(id)); err != nil {
+		slog.Error("PolicyConf.DeleteByTenant error:", "err_msg", err.Error())
+	}
+	var quota model.Quota
+	if err := quota.Delete(model.QuotaScopeTenant, uint(id)); err != nil {
+		slog.Error("Quota.Delete error:", "err_msg", err.Error())
+	}
+	middleware.ReloadTenants()
+	slog.Info("tenant deleted", "id", id, "uid", c.GetUint("uid"))
+	TenantFindAll(c)
+}
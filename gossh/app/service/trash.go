@@ -0,0 +1,122 @@
+package service
+
+import (
+	"gossh/app/config"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"time"
+)
+
+// purgeTrash 彻底删除超过保留时长的回收站数据
+func purgeTrash(retention time.Duration) {
+	before := time.Now().Add(-retention)
+	var sshConf model.SshConf
+	if n, err := sshConf.PurgeTrash(before); err != nil {
+		slog.Error("SshConf.PurgeTrash error:", "err_msg", err.Error())
+	} else if n > 0 {
+		slog.Info("trash conn_conf purged", "count", n)
+	}
+
+	var sshUser model.SshUser
+	list, err := sshUser.FindTrashBefore(before)
+	if err != nil {
+		slog.Error("SshUser.FindTrashBefore error:", "err_msg", err.Error())
+		return
+	}
+	for _, user := range list {
+		if err := sshUser.Purge(user.ID); err != nil {
+			slog.Error("SshUser.Purge error:", "id", user.ID, "err_msg", err.Error())
+			continue
+		}
+		var pref model.UserPref
+		if err := pref.DeleteByUid(user.ID); err != nil {
+			slog.Error("pref.DeleteByUid错误", "err_msg", err.Error())
+		}
+		var quota model.Quota
+		if err := quota.Delete(model.QuotaScopeUser, user.ID); err != nil {
+			slog.Error("Quota.Delete error:", "err_msg", err.Error())
+		}
+		slog.Info("trash user purged", "id", user.ID, "name", user.Name)
+	}
+}
+
+func trashPurgeLoop() {
+	for {
+		time.Sleep(storageCleanInterval)
+		if retention := config.DefaultConfig.Limits.TrashRetention; backgroundEnabled() && retention > 0 {
+			purgeTrash(retention)
+		}
+	}
+}
+
+// TrashFindAll GET 回收站中的连接配置,管理员同时返回已删除的用户
+func TrashFindAll(c *gin.Context) {
+	var sshConf model.SshConf
+	confs, err := sshConf.FindTrash(c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 1, "msg": err.Error()})
+		return
+	}
+
+	users := []model.SshUser{}
+	var user model.SshUser
+	u, err := user.FindByID(c.GetUint("uid"))
+	if err == nil && u.IsAdmin == "Y" {
+		if users, err = user.FindTrash(u.TenantId); err != nil {
+			c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+			return
+		}
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": gin.H{
+		"conn_conf": confs,
+		"user":      users,
+		"retention": config.DefaultConfig.Limits.TrashRetention,
+	}})
+}
+
+// TrashRestore POST 从回收站恢复连接配置或用户,恢复用户需要管理员权限
+func TrashRestore(c *gin.Context) {
+	type Param struct {
+		Type string `form:"type" binding:"required,oneof=conn_conf user" json:"type"`
+		Id   uint   `form:"id" binding:"required" json:"id"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+
+	var n int64
+	var err error
+	switch param.Type {
+	case "conn_conf":
+		var sshConf model.SshConf
+		if err = checkHostQuota(c.GetUint("uid"), 1); err == nil {
+			n, err = sshConf.Restore(param.Id, c.GetUint("uid"))
+		}
+	case "user":
+		var user model.SshUser
+		u, findErr := user.FindByID(c.GetUint("uid"))
+		if findErr != nil || u.IsAdmin == "N" {
+			c.JSON(200, gin.H{"code": 2, "msg": "非管理员拒绝操作"})
+			return
+		}
+		n, err = user.Restore(param.Id, u.TenantId)
+	}
+	if err != nil {
+		slog.Error("trash restore error:", "type", param.Type, "id", param.Id, "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if n == 0 {
+		c.JSON(200, gin.H{"code": 4, "msg": "回收站中不存在该记录"})
+		return
+	}
+	slog.Info("trash restored", "type", param.Type, "id", param.Id, "uid", c.GetUint("uid"))
+	TrashFindAll(c)
+}
+
+func init() {
+	go trashPurgeLoop()
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 功能键在 xterm 中发送的序列,F1-F4 为 SS3 形式,带修饰键时为 CSI 1;m P 形式,F5-F12 为 CSI n ~ 形式
+var macroFuncKeys = map[string]string{
+	"F1": "P", "F2": "Q", "F3": "R", "F4": "S",
+	"F5": "15", "F6": "17", "F7": "18", "F8": "19",
+	"F9": "20", "F10": "21", "F11": "23", "F12": "24",
+}
+
+// 修饰键在 xterm 序列中的参数
+var macroModifiers = map[string]int{
+	"":            1,
+	"Shift+":      2,
+	"Alt+":        3,
+	"Alt+Shift+":  4,
+	"Ctrl+":       5,
+	"Ctrl+Shift+": 6,
+	"Ctrl+Alt+":   7,
+}
+
+// macroKeySequence 按键名称对应的终端输入序列,如 F2、Shift+F5、Ctrl+Shift+F12
+func macroKeySequence(name string) (string, error) {
+	idx := strings.LastIndex(name, "+")
+	prefix, key := name[:idx+1], name[idx+1:]
+	code, ok := macroFuncKeys[key]
+	mod, modOk := macroModifiers[prefix]
+	if !ok || !modOk {
+		return "", fmt.Errorf("不支持的按键: %s", name)
+	}
+	if len(code) == 1 {
+		if mod == 1 {
+			return "\x1bO" + code, nil
+		}
+		return fmt.Sprintf("\x1b[1;%d%s", mod, code), nil
+	}
+	if mod == 1 {
+		return "\x1b[" + code + "~", nil
+	}
+	return fmt.Sprintf("\x1b[%s;%d~", code, mod), nil
+}
+
+// macroKeys 支持的按键名称
+func macroKeys() []string {
+	var keys []string
+	for prefix := range macroModifiers {
+		for key := range macroFuncKeys {
+			keys = append(keys, prefix+key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// expandMacro 解析宏内容中的转义:\r 回车、\n 换行、\t 制表、\e ESC、\xNN 任意字节、\\ 反斜杠
+func expandMacro(content string) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(content); i++ {
+		if content[i] != '\\' {
+			out = append(out, content[i])
+			continue
+		}
+		if i+1 >= len(content) {
+			return nil, errors.New("宏内容以反斜杠结尾")
+		}
+		i++
+		switch content[i] {
+		case 'r':
+			out = append(out, '\r')
+		case 'n':
+			out = append(out, '\n')
+		case 't':
+			out = append(out, '\t')
+		case 'e':
+			out = append(out, 0x1b)
+		case '\\':
+			out = append(out, '\\')
+		case 'x':
+			if i+2 >= len(content) {
+				return nil, errors.New("宏内容中 \\x 后需要两位十六进制数")
+			}
+			b, err := strconv.ParseUint(content[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, errors.New("宏内容中 \\x 后需要两位十六进制数")
+			}
+			out = append(out, byte(b))
+			i += 2
+		default:
+			return nil, fmt.Errorf("宏内容中不支持的转义: \\%c", content[i])
+		}
+	}
+	return out, nil
+}
+
+// macroHook 将按键序列替换为宏内容,宏在会话开始时加载
+type macroHook struct {
+	keys   [][]byte
+	values [][]byte
+}
+
+func newMacroHook(conn *SshConn) StreamHook {
+	var userMacro model.UserMacro
+	list, err := userMacro.FindEnabled(conn.Uid)
+	if err != nil || len(list) == 0 {
+		return nil
+	}
+	h := &macroHook{}
+	for _, macro := range list {
+		seq, err := macroKeySequence(macro.KeyName)
+		if err != nil {
+			continue
+		}
+		content, err := expandMacro(macro.Content)
+		if err != nil {
+			slog.Warn("expand macro error:", "macro_id", macro.ID, "err_msg", err.Error())
+			continue
+		}
+		h.keys = append(h.keys, []byte(seq))
+		h.values = append(h.values, content)
+	}
+	if len(h.keys) == 0 {
+		return nil
+	}
+	return h
+}
+
+func (h *macroHook) OnInput(conn *SshConn, data []byte) ([]byte, error) {
+	if bytes.IndexByte(data, 0x1b) < 0 {
+		return data, nil
+	}
+	for i, key := range h.keys {
+		data = bytes.ReplaceAll(data, key, h.values[i])
+	}
+	return data, nil
+}
+
+func (h *macroHook) OnOutput(conn *SshConn, data []byte) []byte {
+	return data
+}
+
+// checkMacro 校验按键和内容,同一个用户的按键不能重复
+func checkMacro(macro model.UserMacro, uid uint) error {
+	if _, err := macroKeySequence(macro.KeyName); err != nil {
+		return err
+	}
+	if _, err := expandMacro(macro.Content); err != nil {
+		return err
+	}
+	if exist, err := macro.FindByKey(uid, macro.KeyName); err == nil && exist.ID != macro.ID {
+		return errors.New("按键已被其他宏使用")
+	}
+	return nil
+}
+
+func UserMacroCreate(c *gin.Context) {
+	var macro model.UserMacro
+	if err := c.ShouldBind(&macro); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	macro.ID = 0
+	macro.Uid = c.GetUint("uid")
+	if err := checkMacro(macro, macro.Uid); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if err := macro.Create(&macro); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	UserMacroFindAll(c)
+}
+
+func UserMacroFindByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var macro model.UserMacro
+	data, err := macro.FindByID(uint(id), c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+// UserMacroFindAll GET 用户的按键宏,keys 为支持的按键名称,新会话生效
+func UserMacroFindAll(c *gin.Context) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+
+	var macro model.UserMacro
+	data, total, err := macro.FindPage(q, c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data, "total": total, "keys": macroKeys()})
+}
+
+func UserMacroUpdateById(c *gin.Context) {
+	var macro model.UserMacro
+	if err := c.ShouldBind(&macro); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if err := checkMacro(macro, c.GetUint("uid")); err != nil {
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	if err := macro.UpdateById(macro.ID, c.GetUint("uid"), &macro); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	UserMacroFindAll(c)
+}
+
+func UserMacroDeleteById(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	var macro model.UserMacro
+	if err := macro.DeleteByID(uint(id), c.GetUint("uid")); err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	UserMacroFindAll(c)
+}
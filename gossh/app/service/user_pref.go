@@ -0,0 +1,39 @@
+package service
+
+import (
+	"encoding/json"
+	"gossh/app/model"
+	"gossh/gin"
+	"log/slog"
+)
+
+// UserPrefGet GET 当前用户的终端偏好设置,未保存时返回默认值
+func UserPrefGet(c *gin.Context) {
+	var pref model.UserPref
+	data, err := pref.FindByUid(c.GetUint("uid"))
+	if err != nil {
+		slog.Error("UserPref.FindByUid error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 2, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok", "data": data})
+}
+
+// UserPrefSet PUT 保存当前用户的终端偏好设置
+func UserPrefSet(c *gin.Context) {
+	var pref model.UserPref
+	if err := c.ShouldBind(&pref); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	if pref.Keymap != "" && !json.Valid([]byte(pref.Keymap)) {
+		c.JSON(200, gin.H{"code": 1, "msg": "keymap 必须是 JSON 格式"})
+		return
+	}
+	if err := pref.Save(c.GetUint("uid"), &pref); err != nil {
+		slog.Error("UserPref.Save error:", "err_msg", err.Error())
+		c.JSON(200, gin.H{"code": 3, "msg": err.Error()})
+		return
+	}
+	UserPrefGet(c)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"gossh/app/utils"
+	"gossh/gin"
+	"gossh/gin/binding"
+	"gossh/gin/validator"
+	"reflect"
+	"strings"
+)
+
+// FieldError 参数校验失败的字段,Code 为校验规则,Message 为按请求语言翻译的提示
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validateMessages 校验规则的提示,原文为中文,其他语言的译文在 locales 目录中
+// {field} 替换为字段名,{param} 替换为规则参数
+var validateMessages = map[string]string{
+	"required":        "{field}为必填字段",
+	"required_with":   "{field}为必填字段",
+	"required_if":     "{field}为必填字段",
+	"required_unless": "{field}为必填字段",
+	"oneof":           "{field}必须是[{param}]中的一个",
+	"eq":              "{field}必须等于{param}",
+	"ne":              "{field}不能等于{param}",
+	"email":           "{field}必须是有效的邮箱地址",
+	"url":             "{field}必须是有效的URL",
+	"ip":              "{field}必须是有效的IP地址",
+	"ipv4":            "{field}必须是有效的IPv4地址",
+	"ipv6":            "{field}必须是有效的IPv6地址",
+	"cidr":            "{field}必须是有效的CIDR",
+	"hostname":        "{field}必须是有效的主机名",
+	"numeric":         "{field}必须是数字",
+	"number":          "{field}必须是数字",
+	"alphanum":        "{field}只能包含字母和数字",
+	"startswith":      "{field}必须以{param}开头",
+	"endswith":        "{field}必须以{param}结尾",
+	"contains":        "{field}必须包含{param}",
+	"excludes":        "{field}不能包含{param}",
+	"unique":          "{field}不能包含重复的值",
+	"dive":            "{field}中的数据不合法",
+}
+
+// sizeMessages 长度和大小规则的提示,依次为字符串、数字、列表使用的提示
+var sizeMessages = map[string][3]string{
+	"min": {"{field}长度不能少于{param}个字符", "{field}不能小于{param}", "{field}至少包含{param}项"},
+	"max": {"{field}长度不能超过{param}个字符", "{field}不能大于{param}", "{field}最多包含{param}项"},
+	"len": {"{field}长度必须是{param}个字符", "{field}必须等于{param}", "{field}必须包含{param}项"},
+	"gte": {"{field}长度不能少于{param}个字符", "{field}不能小于{param}", "{field}至少包含{param}项"},
+	"lte": {"{field}长度不能超过{param}个字符", "{field}不能大于{param}", "{field}最多包含{param}项"},
+	"gt":  {"{field}长度必须多于{param}个字符", "{field}必须大于{param}", "{field}必须多于{param}项"},
+	"lt":  {"{field}长度必须少于{param}个字符", "{field}必须小于{param}", "{field}必须少于{param}项"},
+}
+
+// 其他规则的提示
+const defaultValidateMessage = "{field}校验失败: {param}"
+
+func init() {
+	// 校验错误中的字段名使用 json 标签,与请求参数保持一致
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				if name, _, _ := strings.Cut(fld.Tag.Get(tag), ","); name != "" && name != "-" {
+					return name
+				}
+			}
+			return ""
+		})
+	}
+}
+
+// fieldMessage 按字段类型选择提示并翻译
+func fieldMessage(fe validator.FieldError, field, lang string) string {
+	template, ok := validateMessages[fe.Tag()]
+	if size, isSize := sizeMessages[fe.Tag()]; isSize {
+		ok = true
+		switch fe.Kind() {
+		case reflect.String:
+			template = size[0]
+		case reflect.Slice, reflect.Array, reflect.Map:
+			template = size[2]
+		default:
+			template = size[1]
+		}
+	}
+	param := fe.Param()
+	if !ok {
+		template = defaultValidateMessage
+		param = fe.Tag()
+	}
+	return strings.NewReplacer("{field}", field, "{param}", param).Replace(utils.Translate(lang, template))
+}
+
+// validationErrors 将校验错误转换为字段错误列表,不是校验错误时返回空
+func validationErrors(err error, lang string) []FieldError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	list := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		// 命名空间的第一段为结构体名称,嵌套字段保留完整路径,如 list[0].name
+		field := fe.Namespace()
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+		list = append(list, FieldError{Field: field, Code: fe.Tag(), Message: fieldMessage(fe, field, lang)})
+	}
+	return list
+}
+
+// bindError 参数绑定失败的响应,校验错误返回每个字段的错误,msg 为所有字段错误的提示
+func bindError(c *gin.Context, code int, err error) {
+	list := validationErrors(err, c.GetString("lang"))
+	if list == nil {
+		c.JSON(200, gin.H{"code": code, "msg": err.Error(), "errors": []FieldError{}})
+		return
+	}
+	messages := make([]string, 0, len(list))
+	for _, fe := range list {
+		messages = append(messages, fe.Message)
+	}
+	c.JSON(200, gin.H{"code": code, "msg": strings.Join(messages, "; "), "errors": list})
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gossh/app/model"
+	"gossh/crypto/ssh"
+	"gossh/gin"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// 默认的广播地址和端口
+	wolDefaultBroadcast = "255.255.255.255"
+	wolDefaultPort      = "9"
+	// 自动唤醒后等待主机启动的最长时间
+	wolWakeTimeout = 2 * time.Minute
+	// 唤醒后重新连接的间隔
+	wolRetryInterval = 10 * time.Second
+)
+
+// checkWolConf 保存主机配置前校验网络唤醒设置
+func checkWolConf(conf model.SshConf) error {
+	if conf.WolMac == "" {
+		if conf.WolAuto == "Y" {
+			return errors.New("自动唤醒需要填写 MAC 地址")
+		}
+		return nil
+	}
+	if _, err := wolMac(conf.WolMac); err != nil {
+		return err
+	}
+	if _, err := wolAddr(conf.WolBroadcast); err != nil {
+		return err
+	}
+	return nil
+}
+
+// wolMac 解析 MAC 地址,只支持 6 字节的以太网地址
+func wolMac(s string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("MAC 地址错误: %s", s)
+	}
+	return mac, nil
+}
+
+// wolAddr 广播地址格式 host 或 host:port,为空时使用 255.255.255.255:9
+func wolAddr(s string) (string, error) {
+	if s == "" {
+		return net.JoinHostPort(wolDefaultBroadcast, wolDefaultPort), nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = strings.Trim(s, "[]"), wolDefaultPort
+	}
+	if host == "" {
+		return "", fmt.Errorf("广播地址错误: %s", s)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// sendMagicPacket 发送网络唤醒包,内容为 6 个 0xFF 加上重复 16 次的 MAC 地址
+func sendMagicPacket(conf *model.SshConf) error {
+	mac, err := wolMac(conf.WolMac)
+	if err != nil {
+		return err
+	}
+	addr, err := wolAddr(conf.WolBroadcast)
+	if err != nil {
+		return err
+	}
+	packet := append(bytes.Repeat([]byte{0xff}, 6), bytes.Repeat(mac, 16)...)
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	// 使用 ListenUDP 发送,部分系统向广播地址 Dial 时需要 SO_BROADCAST
+	pc, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = pc.Close()
+	}()
+	if _, err := pc.WriteToUDP(packet, udpAddr); err != nil {
+		return err
+	}
+	slog.Info("wake on lan", "conf_id", conf.ID, "mac", mac.String(), "addr", addr)
+	return nil
+}
+
+// dialWithWake 连接主机,失败且开启了自动唤醒时发送唤醒包,在主机启动前定时重试
+func dialWithWake(ctx context.Context, conf *model.SshConf, config *ssh.ClientConfig) (*ssh.Client, string, error) {
+	client, endpoint, err := dialFailover(ctx, conf, config)
+	if err == nil || conf.WolAuto != "Y" || conf.WolMac == "" {
+		return client, endpoint, err
+	}
+	if wakeErr := sendMagicPacket(conf); wakeErr != nil {
+		slog.Error("sendMagicPacket error:", "conf_id", conf.ID, "err_msg", wakeErr.Error())
+		return nil, "", err
+	}
+	deadline := time.Now().Add(wolWakeTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		case <-time.After(wolRetryInterval):
+		}
+		client, endpoint, err = dialFailover(ctx, conf, config)
+		if err == nil {
+			return client, endpoint, nil
+		}
+	}
+	return nil, "", fmt.Errorf("唤醒后主机仍无法连接: %w", err)
+}
+
+// Wol POST 向主机发送网络唤醒包
+func Wol(c *gin.Context) {
+	type Param struct {
+		ConfId uint `form:"conf_id" binding:"required" json:"conf_id"`
+	}
+	var param Param
+	if err := c.ShouldBind(&param); err != nil {
+		bindError(c, 1, err)
+		return
+	}
+	var sshConf model.SshConf
+	conf, err := sshConf.FindByID(param.ConfId, c.GetUint("uid"))
+	if err != nil {
+		c.JSON(200, gin.H{"code": 2, "msg": "主机不存在"})
+		return
+	}
+	if conf.WolMac == "" {
+		c.JSON(200, gin.H{"code": 3, "msg": "主机未配置 MAC 地址"})
+		return
+	}
+	if err := sendMagicPacket(&conf); err != nil {
+		c.JSON(200, gin.H{"code": 4, "msg": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"code": 0, "msg": "ok"})
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"embed"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 双字节字符集的编码表,首字节 0x81-0xFE、次字节 0x40-0xFE 的每个编码对应一个大端序 uint16 码点,0 表示没有对应字符
+// gbk.bin 由 Python 的 gbk 编码生成,big5.bin 由 cp950 编码生成
+//
+//go:embed charsets/*.bin
+var charsetFS embed.FS
+
+const (
+	charsetLeadMin    = 0x81
+	charsetLeadMax    = 0xfe
+	charsetTrailMin   = 0x40
+	charsetTrailMax   = 0xfe
+	charsetTrailCount = charsetTrailMax - charsetTrailMin + 1
+)
+
+// Charsets 支持的终端字符集,utf-8 不需要转换
+var Charsets = []string{"utf-8", "gbk", "big5"}
+
+type charsetTable struct {
+	decode []uint16
+	encode map[rune][2]byte
+}
+
+var (
+	charsetMu     sync.Mutex
+	charsetTables = map[string]*charsetTable{}
+)
+
+// IsUtf8Charset 字符集为空或 utf-8 时不需要转换
+func IsUtf8Charset(name string) bool {
+	name = strings.ToLower(name)
+	return name == "" || name == "utf-8" || name == "utf8"
+}
+
+// loadCharset 首次使用时加载编码表
+func loadCharset(name string) (*charsetTable, error) {
+	name = strings.ToLower(name)
+	charsetMu.Lock()
+	defer charsetMu.Unlock()
+	if table, ok := charsetTables[name]; ok {
+		return table, nil
+	}
+	data, err := charsetFS.ReadFile("charsets/" + name + ".bin")
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %s", name)
+	}
+	table := &charsetTable{decode: make([]uint16, len(data)/2), encode: map[rune][2]byte{}}
+	for i := range table.decode {
+		r := binary.BigEndian.Uint16(data[i*2:])
+		table.decode[i] = r
+		if r == 0 {
+			continue
+		}
+		// 同一个字符有多个编码时使用第一个
+		if _, ok := table.encode[rune(r)]; !ok {
+			table.encode[rune(r)] = [2]byte{byte(charsetLeadMin + i/charsetTrailCount), byte(charsetTrailMin + i%charsetTrailCount)}
+		}
+	}
+	charsetTables[name] = table
+	return table, nil
+}
+
+// CharsetDecoder 将双字节字符集转换为 UTF-8,被截断的双字节字符留到下次转换
+type CharsetDecoder struct {
+	table *charsetTable
+	tail  []byte
+}
+
+func NewCharsetDecoder(name string) (*CharsetDecoder, error) {
+	table, err := loadCharset(name)
+	if err != nil {
+		return nil, err
+	}
+	return &CharsetDecoder{table: table}, nil
+}
+
+// Decode 转换一段数据,无法识别的字节转换为 U+FFFD
+func (d *CharsetDecoder) Decode(p []byte) []byte {
+	data := p
+	if len(d.tail) > 0 {
+		data = append(d.tail, p...)
+		d.tail = nil
+	}
+	out := make([]byte, 0, len(data)*3/2)
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < utf8.RuneSelf {
+			out = append(out, b)
+			i++
+			continue
+		}
+		if b < charsetLeadMin || b > charsetLeadMax {
+			out = utf8.AppendRune(out, utf8.RuneError)
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			d.tail = []byte{b}
+			break
+		}
+		trail := data[i+1]
+		if trail < charsetTrailMin || trail > charsetTrailMax {
+			out = utf8.AppendRune(out, utf8.RuneError)
+			i++
+			continue
+		}
+		if r := d.table.decode[int(b-charsetLeadMin)*charsetTrailCount+int(trail-charsetTrailMin)]; r != 0 {
+			out = utf8.AppendRune(out, rune(r))
+		} else {
+			out = utf8.AppendRune(out, utf8.RuneError)
+		}
+		i += 2
+	}
+	return out
+}
+
+// CharsetEncoder 将 UTF-8 转换为双字节字符集,被截断的 UTF-8 字符留到下次转换
+type CharsetEncoder struct {
+	table *charsetTable
+	tail  []byte
+}
+
+func NewCharsetEncoder(name string) (*CharsetEncoder, error) {
+	table, err := loadCharset(name)
+	if err != nil {
+		return nil, err
+	}
+	return &CharsetEncoder{table: table}, nil
+}
+
+// Encode 转换一段数据,目标字符集中没有的字符转换为 ?
+func (e *CharsetEncoder) Encode(p []byte) []byte {
+	data := p
+	if len(e.tail) > 0 {
+		data = append(e.tail, p...)
+		e.tail = nil
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if data[i] < utf8.RuneSelf {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			e.tail = append([]byte(nil), data[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		i += size
+		if code, ok := e.table.encode[r]; ok {
+			out = append(out, code[0], code[1])
+		} else {
+			out = append(out, '?')
+		}
+	}
+	return out
+}
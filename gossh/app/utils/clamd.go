@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamd INSTREAM 每次发送的数据块大小
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner 通过 clamd 的 INSTREAM 命令扫描文件内容
+// Address 格式为 host:port 或 unix:/path/clamd.sock
+type ClamdScanner struct {
+	Address string
+	Timeout time.Duration
+}
+
+func (s ClamdScanner) dial() (net.Conn, error) {
+	network, address := "tcp", strings.TrimPrefix(s.Address, "tcp://")
+	if strings.HasPrefix(s.Address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(s.Address, "unix:")
+	}
+	return net.DialTimeout(network, address, s.Timeout)
+}
+
+// Scan 扫描数据,发现病毒时返回病毒名称,未发现时返回空字符串
+func (s ClamdScanner) Scan(r io.Reader) (string, error) {
+	conn, err := s.dial()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if s.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return "", err
+	}
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := w.Write(size); err != nil {
+				return "", err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return "", err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	// 长度为0的数据块表示结束
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := w.Write(size); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", err
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(reply, " FOUND"), nil
+	}
+	return "", errors.New("clamd: " + reply)
+}
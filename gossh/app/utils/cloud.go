@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CloudInstance 云主机实例,State 统一为小写
+type CloudInstance struct {
+	Id        string
+	Name      string
+	PrivateIp string
+	PublicIp  string
+	Platform  string
+	State     string
+	Tags      map[string]string
+}
+
+// Terminated 实例是否已释放或正在释放
+func (i CloudInstance) Terminated() bool {
+	switch i.State {
+	case "terminated", "shutting-down", "terminating", "launch_failed", "deleted":
+		return true
+	}
+	return false
+}
+
+// CloudProvider 云主机清单接口,filter 为实例标签过滤条件,同一标签的多个取值为或的关系
+type CloudProvider interface {
+	ListInstances(filter Tags) ([]CloudInstance, error)
+}
+
+var cloudHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+// 单次同步最多拉取的实例数量,防止分页异常时无限请求
+const cloudMaxInstances = 10000
+
+// cloudDo 发送请求并读取响应内容,非 2xx 状态返回错误
+func cloudDo(req *http.Request) ([]byte, error) {
+	return cloudDoClient(cloudHttpClient, req)
+}
+
+func cloudDoClient(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return body, fmt.Errorf("%s %s: %s", req.Method, req.URL.Host, resp.Status)
+	}
+	return body, nil
+}
+
+// cloudEndpoint 未指定地址时使用默认地址,地址可以不带协议
+func cloudEndpoint(endpoint, def string) string {
+	if endpoint == "" {
+		endpoint = def
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return strings.TrimRight(endpoint, "/")
+}
+
+// sanitizeTagWord 将云厂商标签中不支持的字符替换为下划线
+func sanitizeTagWord(s string) string {
+	s = TruncateString(strings.TrimSpace(s), 64)
+	return strings.Map(func(r rune) rune {
+		if validTagChar(r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// InstanceTags 将实例标签转换为主机标签,标签名统一为小写
+func (i CloudInstance) InstanceTags() Tags {
+	tags := Tags{}
+	for key, value := range i.Tags {
+		key = strings.ToLower(sanitizeTagWord(key))
+		if key == "" {
+			continue
+		}
+		value = sanitizeTagWord(value)
+		if !tags.Has(key, value) {
+			tags[key] = append(tags[key], value)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AliyunEcs 阿里云 ECS 实例清单,使用 RPC 风格 API 和 HMAC-SHA1 签名
+type AliyunEcs struct {
+	Region    string
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+}
+
+type aliyunIpList struct {
+	IpAddress []string `json:"IpAddress"`
+}
+
+type aliyunEcsResponse struct {
+	Code      string `json:"Code"`
+	Message   string `json:"Message"`
+	NextToken string `json:"NextToken"`
+	Instances struct {
+		Instance []struct {
+			InstanceId      string       `json:"InstanceId"`
+			InstanceName    string       `json:"InstanceName"`
+			Status          string       `json:"Status"`
+			OSType          string       `json:"OSType"`
+			InnerIpAddress  aliyunIpList `json:"InnerIpAddress"`
+			PublicIpAddress aliyunIpList `json:"PublicIpAddress"`
+			EipAddress      struct {
+				IpAddress string `json:"IpAddress"`
+			} `json:"EipAddress"`
+			VpcAttributes struct {
+				PrivateIpAddress aliyunIpList `json:"PrivateIpAddress"`
+			} `json:"VpcAttributes"`
+			Tags struct {
+				Tag []struct {
+					TagKey   string `json:"TagKey"`
+					TagValue string `json:"TagValue"`
+				} `json:"Tag"`
+			} `json:"Tags"`
+		} `json:"Instance"`
+	} `json:"Instances"`
+}
+
+func firstIp(list ...[]string) string {
+	for _, ips := range list {
+		if len(ips) > 0 {
+			return ips[0]
+		}
+	}
+	return ""
+}
+
+// ListInstances 分页查询 DescribeInstances,同一标签只支持一个取值,多个取值时取第一个
+func (c AliyunEcs) ListInstances(filter Tags) ([]CloudInstance, error) {
+	params := map[string]string{
+		"Action":     "DescribeInstances",
+		"Version":    "2014-05-26",
+		"RegionId":   c.Region,
+		"MaxResults": "100",
+	}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		n := strconv.Itoa(i + 1)
+		params["Tag."+n+".Key"] = key
+		if values := filter[key]; len(values) > 0 && values[0] != "" {
+			params["Tag."+n+".Value"] = values[0]
+		}
+	}
+
+	var list []CloudInstance
+	for len(list) < cloudMaxInstances {
+		var result aliyunEcsResponse
+		if err := c.do(params, &result); err != nil {
+			return nil, err
+		}
+		for _, item := range result.Instances.Instance {
+			instance := CloudInstance{
+				Id:        item.InstanceId,
+				Name:      item.InstanceName,
+				PrivateIp: firstIp(item.VpcAttributes.PrivateIpAddress.IpAddress, item.InnerIpAddress.IpAddress),
+				PublicIp:  firstIp(item.PublicIpAddress.IpAddress, []string{item.EipAddress.IpAddress}),
+				Platform:  strings.ToLower(item.OSType),
+				State:     strings.ToLower(item.Status),
+				Tags:      map[string]string{},
+			}
+			for _, tag := range item.Tags.Tag {
+				instance.Tags[tag.TagKey] = tag.TagValue
+			}
+			list = append(list, instance)
+		}
+		if result.NextToken == "" {
+			return list, nil
+		}
+		params["NextToken"] = result.NextToken
+	}
+	return list, nil
+}
+
+func (c AliyunEcs) do(params map[string]string, result *aliyunEcsResponse) error {
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	query.Set("Format", "JSON")
+	query.Set("AccessKeyId", c.AccessKey)
+	query.Set("SignatureMethod", "HMAC-SHA1")
+	query.Set("SignatureVersion", "1.0")
+	query.Set("SignatureNonce", RandString(32))
+	query.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+
+	canonicalQuery := s3CanonicalQuery(query)
+	stringToSign := http.MethodGet + "&" + s3Escape("/") + "&" + s3Escape(canonicalQuery)
+	mac := hmac.New(sha1.New, []byte(c.SecretKey+"&"))
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	endpoint := cloudEndpoint(c.Endpoint, "ecs."+c.Region+".aliyuncs.com")
+	req, err := http.NewRequest(http.MethodGet, endpoint+"/?"+canonicalQuery+"&Signature="+s3Escape(signature), nil)
+	if err != nil {
+		return err
+	}
+	body, err := cloudDo(req)
+	jsonErr := json.Unmarshal(body, result)
+	if jsonErr == nil && result.Code != "" {
+		return fmt.Errorf("aliyun %s: %s", result.Code, result.Message)
+	}
+	if err != nil {
+		return err
+	}
+	return jsonErr
+}
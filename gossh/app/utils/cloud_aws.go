@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AwsEc2 AWS EC2 实例清单,使用 Query API 和 Signature V4 签名
+type AwsEc2 struct {
+	Region    string
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+}
+
+type awsEc2Response struct {
+	Reservations []struct {
+		Instances []struct {
+			InstanceId       string `xml:"instanceId"`
+			PrivateIpAddress string `xml:"privateIpAddress"`
+			IpAddress        string `xml:"ipAddress"`
+			Platform         string `xml:"platform"`
+			State            string `xml:"instanceState>name"`
+			Tags             []struct {
+				Key   string `xml:"key"`
+				Value string `xml:"value"`
+			} `xml:"tagSet>item"`
+		} `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+type awsError struct {
+	Code    string `xml:"Errors>Error>Code"`
+	Message string `xml:"Errors>Error>Message"`
+}
+
+// ListInstances 分页查询 DescribeInstances
+func (c AwsEc2) ListInstances(filter Tags) ([]CloudInstance, error) {
+	query := url.Values{
+		"Action":     {"DescribeInstances"},
+		"Version":    {"2016-11-15"},
+		"MaxResults": {"1000"},
+	}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		n := strconv.Itoa(i + 1)
+		query.Set("Filter."+n+".Name", "tag:"+key)
+		for j, value := range filter[key] {
+			if value == "" {
+				query.Set("Filter."+n+".Name", "tag-key")
+				value = key
+			}
+			query.Set("Filter."+n+".Value."+strconv.Itoa(j+1), value)
+		}
+	}
+
+	var list []CloudInstance
+	for len(list) < cloudMaxInstances {
+		body, err := c.do(query)
+		if err != nil {
+			return nil, err
+		}
+		var result awsEc2Response
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		for _, reservation := range result.Reservations {
+			for _, item := range reservation.Instances {
+				instance := CloudInstance{
+					Id:        item.InstanceId,
+					PrivateIp: item.PrivateIpAddress,
+					PublicIp:  item.IpAddress,
+					Platform:  "linux",
+					State:     strings.ToLower(item.State),
+					Tags:      map[string]string{},
+				}
+				if item.Platform != "" {
+					instance.Platform = strings.ToLower(item.Platform)
+				}
+				for _, tag := range item.Tags {
+					instance.Tags[tag.Key] = tag.Value
+					if tag.Key == "Name" {
+						instance.Name = tag.Value
+					}
+				}
+				list = append(list, instance)
+			}
+		}
+		if result.NextToken == "" {
+			return list, nil
+		}
+		query.Set("NextToken", result.NextToken)
+	}
+	return list, nil
+}
+
+func (c AwsEc2) do(query url.Values) ([]byte, error) {
+	endpoint, err := url.Parse(cloudEndpoint(c.Endpoint, "ec2."+c.Region+".amazonaws.com"))
+	if err != nil {
+		return nil, err
+	}
+	canonicalQuery := s3CanonicalQuery(query)
+	req, err := http.NewRequest(http.MethodGet, endpoint.Scheme+"://"+endpoint.Host+"/?"+canonicalQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := hex.EncodeToString(sha256.New().Sum(nil))
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "host;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		"/",
+		canonicalQuery,
+		"host:" + endpoint.Host + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.Region + "/ec2/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	signKey := s3Hmac([]byte("AWS4"+c.SecretKey), date)
+	signKey = s3Hmac(signKey, c.Region)
+	signKey = s3Hmac(signKey, "ec2")
+	signKey = s3Hmac(signKey, "aws4_request")
+	signature := hex.EncodeToString(s3Hmac(signKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+
+	body, err := cloudDo(req)
+	if err != nil {
+		var awsErr awsError
+		if xml.Unmarshal(body, &awsErr) == nil && awsErr.Code != "" {
+			return nil, fmt.Errorf("aws %s: %s", awsErr.Code, awsErr.Message)
+		}
+		return nil, err
+	}
+	return body, nil
+}
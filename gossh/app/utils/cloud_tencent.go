@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TencentCvm 腾讯云 CVM 实例清单,使用 API 3.0 和 TC3-HMAC-SHA256 签名
+type TencentCvm struct {
+	Region    string
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+}
+
+type tencentCvmResponse struct {
+	Response struct {
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+		TotalCount  int `json:"TotalCount"`
+		InstanceSet []struct {
+			InstanceId         string   `json:"InstanceId"`
+			InstanceName       string   `json:"InstanceName"`
+			InstanceState      string   `json:"InstanceState"`
+			OsName             string   `json:"OsName"`
+			PrivateIpAddresses []string `json:"PrivateIpAddresses"`
+			PublicIpAddresses  []string `json:"PublicIpAddresses"`
+			Tags               []struct {
+				Key   string `json:"Key"`
+				Value string `json:"Value"`
+			} `json:"Tags"`
+		} `json:"InstanceSet"`
+	} `json:"Response"`
+}
+
+// 腾讯云单页最多返回的实例数量
+const tencentPageSize = 100
+
+// ListInstances 分页查询 DescribeInstances
+func (c TencentCvm) ListInstances(filter Tags) ([]CloudInstance, error) {
+	type Filter struct {
+		Name   string   `json:"Name"`
+		Values []string `json:"Values"`
+	}
+	var filters []Filter
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var values []string
+		for _, value := range filter[key] {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			filters = append(filters, Filter{Name: "tag-key", Values: []string{key}})
+		} else {
+			filters = append(filters, Filter{Name: "tag:" + key, Values: values})
+		}
+	}
+
+	var list []CloudInstance
+	for offset := 0; offset < cloudMaxInstances; offset += tencentPageSize {
+		payload := map[string]any{"Offset": offset, "Limit": tencentPageSize}
+		if len(filters) > 0 {
+			payload["Filters"] = filters
+		}
+		var result tencentCvmResponse
+		if err := c.do("DescribeInstances", payload, &result); err != nil {
+			return nil, err
+		}
+		for _, item := range result.Response.InstanceSet {
+			instance := CloudInstance{
+				Id:        item.InstanceId,
+				Name:      item.InstanceName,
+				PrivateIp: firstIp(item.PrivateIpAddresses),
+				PublicIp:  firstIp(item.PublicIpAddresses),
+				Platform:  "linux",
+				State:     strings.ToLower(item.InstanceState),
+				Tags:      map[string]string{},
+			}
+			if strings.Contains(strings.ToLower(item.OsName), "windows") {
+				instance.Platform = "windows"
+			}
+			for _, tag := range item.Tags {
+				instance.Tags[tag.Key] = tag.Value
+			}
+			list = append(list, instance)
+		}
+		if len(result.Response.InstanceSet) < tencentPageSize || offset+tencentPageSize >= result.Response.TotalCount {
+			break
+		}
+	}
+	return list, nil
+}
+
+func (c TencentCvm) do(action string, payload any, result *tencentCvmResponse) error {
+	const service, version = "cvm", "2017-03-12"
+	endpoint, err := url.Parse(cloudEndpoint(c.Endpoint, "cvm.tencentcloudapi.com"))
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint.Scheme+"://"+endpoint.Host+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	date := now.Format("2006-01-02")
+	contentType := "application/json; charset=utf-8"
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		"content-type:" + contentType + "\nhost:" + endpoint.Host + "\n",
+		"content-type;host",
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + service + "/tc3_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "TC3-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	signKey := s3Hmac([]byte("TC3"+c.SecretKey), date)
+	signKey = s3Hmac(signKey, service)
+	signKey = s3Hmac(signKey, "tc3_request")
+	signature := hex.EncodeToString(s3Hmac(signKey, stringToSign))
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host, Signature=%s",
+		c.AccessKey, scope, signature))
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Timestamp", timestamp)
+	req.Header.Set("X-TC-Version", version)
+	req.Header.Set("X-TC-Region", c.Region)
+
+	data, err := cloudDo(req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return err
+	}
+	if result.Response.Error != nil {
+		return fmt.Errorf("tencent %s: %s", result.Response.Error.Code, result.Response.Error.Message)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// 加密数据的文件头
+	encryptMagic = "GOSSHENC1"
+	// 密钥派生迭代次数
+	pbkdf2Iterations = 200000
+	saltSize         = 16
+)
+
+// Pbkdf2Sha256 使用 PBKDF2-HMAC-SHA256 从口令派生密钥
+func Pbkdf2Sha256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+	key := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u := prf.Sum(nil)
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// EncryptWithPassword 使用口令进行 AES-256-GCM 加密
+// 输出格式: 文件头 + salt + nonce + 密文
+func EncryptWithPassword(password string, plain []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(Pbkdf2Sha256([]byte(password), salt, pbkdf2Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte(encryptMagic), salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, []byte(encryptMagic)), nil
+}
+
+// DecryptWithPassword 解密 EncryptWithPassword 加密的数据
+func DecryptWithPassword(password string, data []byte) ([]byte, error) {
+	if len(data) < len(encryptMagic)+saltSize || string(data[:len(encryptMagic)]) != encryptMagic {
+		return nil, errors.New("invalid encrypted data")
+	}
+	data = data[len(encryptMagic):]
+	salt, data := data[:saltSize], data[saltSize:]
+	block, err := aes.NewCipher(Pbkdf2Sha256([]byte(password), salt, pbkdf2Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted data")
+	}
+	nonce, data := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, data, []byte(encryptMagic))
+	if err != nil {
+		return nil, errors.New("wrong password or corrupted data")
+	}
+	return plain, nil
+}
+
+// SealAesGcm 使用 32 字节密钥进行 AES-256-GCM 加密,输出格式: nonce + 密文
+func SealAesGcm(key, plain, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, ad), nil
+}
+
+// OpenAesGcm 解密 SealAesGcm 加密的数据
+func OpenAesGcm(key, data, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted data")
+	}
+	nonce, data := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, data, ad)
+	if err != nil {
+		return nil, errors.New("wrong key or corrupted data")
+	}
+	return plain, nil
+}
+
+// 随机密码使用的字符,不包含需要在 shell 中转义的字符
+const passwordChars = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_.,+=@%"
+
+// RandPassword 使用安全随机数生成指定长度的密码
+func RandPassword(length int) (string, error) {
+	data := make([]byte, length)
+	max := byte(256 - 256%len(passwordChars))
+	buf := make([]byte, 1)
+	for i := 0; i < length; {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return "", err
+		}
+		// 丢弃超出范围的值,保证每个字符概率相同
+		if buf[0] >= max {
+			continue
+		}
+		data[i] = passwordChars[int(buf[0])%len(passwordChars)]
+		i++
+	}
+	return string(data), nil
+}
@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 比较的最大编辑距离,超过后不再计算差异,回溯路径占用的内存与其平方成正比
+const diffMaxEdits = 2000
+
+// ErrDiffTooLarge 两个文件的差异过大
+var ErrDiffTooLarge = errors.New("diff too large")
+
+// diffOp 行差异:' ' 相同、'-' 删除、'+' 新增
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLines 按行拆分,保留最后一行是否有换行的信息
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines 使用 Myers 算法计算行差异,先去掉相同的开头和结尾
+func diffLines(a, b []string) ([]diffOp, error) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	middle, err := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if err != nil {
+		return nil, err
+	}
+	ops = append(ops, middle...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops, nil
+}
+
+func myers(a, b []string) ([]diffOp, error) {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD > diffMaxEdits {
+		maxD = diffMaxEdits
+	}
+	offset := maxD + 1
+	v := make([]int32, 2*offset+1)
+	// trace[d] 保存第 d 步开始时 k 在 [-d, d] 范围内的结果
+	var trace [][]int32
+	found := -1
+	for d := 0; d <= maxD && found < 0; d++ {
+		trace = append(trace, append([]int32(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = int(v[offset+k+1])
+			} else {
+				x = int(v[offset+k-1]) + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = int32(x)
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return nil, ErrDiffTooLarge
+	}
+
+	// 从终点回溯编辑路径
+	var ops []diffOp
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[d+k-1] < prev[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := int(prev[d+prevK])
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, nil
+}
+
+// UnifiedDiff 生成 unified 格式的差异,context 为每处差异前后保留的相同行数,内容相同时返回空字符串
+func UnifiedDiff(nameA, nameB, a, b string, context int) (string, error) {
+	if a == b {
+		return "", nil
+	}
+	ops, err := diffLines(splitLines(a), splitLines(b))
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	// lineA、lineB 为每个操作之前两个文件已经过的行数
+	lineA := make([]int, len(ops)+1)
+	lineB := make([]int, len(ops)+1)
+	for i, op := range ops {
+		lineA[i+1], lineB[i+1] = lineA[i], lineB[i]
+		if op.kind != '+' {
+			lineA[i+1]++
+		}
+		if op.kind != '-' {
+			lineB[i+1]++
+		}
+	}
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// 合并间隔不超过 2*context 行相同内容的差异
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+				continue
+			}
+			if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops))
+		countA, countB := lineA[end]-lineA[start], lineB[end]-lineB[start]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lineA[start], countA), hunkRange(lineB[start], countB))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String(), nil
+}
+
+// hunkRange 差异块的起始行和行数,行数为 0 时起始行为前一行
+func hunkRange(before, count int) string {
+	start := before + 1
+	if count == 0 {
+		start = before
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GeoInfo IP地址归属信息
+type GeoInfo struct {
+	CountryCode string `json:"country_code"`
+	Country     string `json:"country"`
+	City        string `json:"city"`
+	Asn         string `json:"asn"`
+}
+
+// GeoProvider IP地址归属地查询接口,可以替换为其他实现
+type GeoProvider interface {
+	Lookup(ip net.IP) (GeoInfo, error)
+}
+
+var ErrGeoNotFound = errors.New("geoip not found")
+
+var (
+	geoMu       sync.RWMutex
+	geoProvider GeoProvider
+)
+
+// SetGeoProvider 设置全局的归属地查询实现
+func SetGeoProvider(provider GeoProvider) {
+	geoMu.Lock()
+	defer geoMu.Unlock()
+	geoProvider = provider
+}
+
+// GeoLookup 查询IP归属地,未配置查询实现时返回空信息
+func GeoLookup(ip net.IP) GeoInfo {
+	geoMu.RLock()
+	provider := geoProvider
+	geoMu.RUnlock()
+	if provider == nil || ip == nil {
+		return GeoInfo{}
+	}
+	info, err := provider.Lookup(ip)
+	if err != nil {
+		return GeoInfo{}
+	}
+	return info
+}
+
+type geoRange struct {
+	start net.IP
+	end   net.IP
+	info  GeoInfo
+}
+
+// CsvGeoProvider 基于离线CSV文件的归属地查询
+// 每行格式: start_ip,end_ip,country_code,country,city,asn
+type CsvGeoProvider struct {
+	ranges []geoRange
+}
+
+// NewCsvGeoProvider 加载离线CSV归属地数据库
+func NewCsvGeoProvider(file string) (*CsvGeoProvider, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	var ranges []geoRange
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			continue
+		}
+		start := net.ParseIP(strings.TrimSpace(record[0]))
+		end := net.ParseIP(strings.TrimSpace(record[1]))
+		if start == nil || end == nil {
+			continue
+		}
+		get := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		ranges = append(ranges, geoRange{
+			start: start.To16(),
+			end:   end.To16(),
+			info: GeoInfo{
+				CountryCode: strings.ToUpper(get(2)),
+				Country:     get(3),
+				City:        get(4),
+				Asn:         get(5),
+			},
+		})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+	})
+	return &CsvGeoProvider{ranges: ranges}, nil
+}
+
+func (p *CsvGeoProvider) Lookup(ip net.IP) (GeoInfo, error) {
+	ip = ip.To16()
+	if ip == nil {
+		return GeoInfo{}, ErrGeoNotFound
+	}
+	// 找到最后一个 start <= ip 的区间
+	i := sort.Search(len(p.ranges), func(i int) bool {
+		return bytes.Compare(p.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, p.ranges[i].end) > 0 {
+		return GeoInfo{}, ErrGeoNotFound
+	}
+	return p.ranges[i].info, nil
+}
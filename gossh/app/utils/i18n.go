@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"embed"
+	"encoding/json"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 消息目录,文件名为语言标签,内容为原文到译文的映射
+// 新增语言只需要在 locales 目录添加对应的 JSON 文件
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// catalogs 语言标签到消息目录的映射
+var catalogs = map[string]map[string]string{}
+
+func init() {
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		slog.Error("read locales error:", "err_msg", err.Error())
+		return
+	}
+	for _, entry := range entries {
+		data, err := localeFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			slog.Error("read locale error:", "err_msg", err.Error())
+			continue
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			slog.Error("parse locale error:", "file", entry.Name(), "err_msg", err.Error())
+			continue
+		}
+		catalogs[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+}
+
+// NegotiateLang 根据 Accept-Language 请求头选择支持的语言,没有匹配时返回空字符串
+func NegotiateLang(header string) string {
+	type langQ struct {
+		tag string
+		q   float64
+	}
+	var langs []langQ
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				q = v
+			}
+		}
+		if q > 0 {
+			langs = append(langs, langQ{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	for _, lang := range langs {
+		// 先精确匹配,再按主语言匹配,例如 zh、zh-TW 使用 zh-CN
+		for name := range catalogs {
+			if strings.EqualFold(name, lang.tag) {
+				return name
+			}
+		}
+		primary, _, _ := strings.Cut(lang.tag, "-")
+		for _, name := range sortedLangs() {
+			if p, _, _ := strings.Cut(name, "-"); strings.EqualFold(p, primary) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// sortedLangs 已加载的语言标签,保证匹配结果稳定
+func sortedLangs() []string {
+	names := make([]string, 0, len(catalogs))
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Translate 翻译消息,没有对应译文时原样返回
+// 以冒号结尾的原文作为前缀匹配,用于 "错误:" + err.Error() 形式的消息
+func Translate(lang, msg string) string {
+	catalog, ok := catalogs[lang]
+	if !ok || msg == "" {
+		return msg
+	}
+	if text, ok := catalog[msg]; ok {
+		return text
+	}
+	// 多个前缀匹配时使用最长的
+	var prefix string
+	for key := range catalog {
+		if strings.HasSuffix(key, ":") && strings.HasPrefix(msg, key) && len(key) > len(prefix) {
+			prefix = key
+		}
+	}
+	if prefix == "" {
+		return msg
+	}
+	return catalog[prefix] + msg[len(prefix):]
+}
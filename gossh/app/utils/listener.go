@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemd 传入的第一个套接字的文件描述符
+const sdListenFdsStart = 3
+
+// ParseHostIP 解析IP地址,支持 host:port、[IPv6]、[IPv6]:port 和带区域的 IPv6 地址,IPv4 映射的 IPv6 地址转换为 IPv4
+func ParseHostIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if i := strings.IndexByte(s, '%'); i >= 0 {
+		s = s[:i]
+	}
+	ip := net.ParseIP(s)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// Listen 创建服务监听,优先使用 systemd 套接字激活传入的套接字,其次是 unix 域套接字,最后是 TCP 地址
+// network 为 tcp 时同时监听 IPv4 和 IPv6,tcp4 只监听 IPv4,tcp6 只监听 IPv6
+func Listen(network, address, socket string, mode os.FileMode) (net.Listener, error) {
+	if listener, err := systemdListener(); listener != nil || err != nil {
+		return listener, err
+	}
+	if socket != "" {
+		return unixListener(socket, mode)
+	}
+	if network == "" {
+		network = "tcp"
+	}
+	return net.Listen(network, address)
+}
+
+// systemdListener 按 sd_listen_fds 协议获取 systemd 传入的套接字,不是套接字激活启动时返回 nil
+func systemdListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	// 不传给子进程
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+	file := os.NewFile(sdListenFdsStart, "systemd-socket")
+	defer file.Close()
+	return net.FileListener(file)
+}
+
+// unixListener 监听 unix 域套接字,启动前删除上次运行遗留的套接字文件
+func unixListener(socket string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s already exists and is not a socket", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(socket, mode); err != nil {
+			_ = listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
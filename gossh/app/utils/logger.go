@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogConfig 日志配置,File 为空时输出到标准输出,MaxSize 为单个文件的最大字节数,0 表示不轮转
+type LogConfig struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSize    int64
+	MaxBackups int
+}
+
+var (
+	logMu     sync.Mutex
+	logLevel  = new(slog.LevelVar)
+	logWriter io.Closer
+)
+
+// ParseLogLevel 解析日志级别,空字符串为 info
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
+
+// ConfigureLogger 设置默认 slog 日志,可以在运行时重复调用
+func ConfigureLogger(conf LogConfig) error {
+	level, err := ParseLogLevel(conf.Level)
+	if err != nil {
+		return err
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	var out io.Writer = os.Stdout
+	var closer io.Closer
+	if conf.File != "" {
+		writer, err := NewRotateWriter(conf.File, conf.MaxSize, conf.MaxBackups)
+		if err != nil {
+			return err
+		}
+		out, closer = writer, writer
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler = slog.NewJSONHandler(out, opts)
+	if conf.Format == "text" {
+		handler = slog.NewTextHandler(out, opts)
+	}
+	logLevel.Set(level)
+	slog.SetDefault(slog.New(eventHandler{handler}))
+
+	if logWriter != nil {
+		_ = logWriter.Close()
+	}
+	logWriter = closer
+	return nil
+}
+
+// eventHandler 输出日志的同时保存最近的日志,用于 panic 记录
+type eventHandler struct {
+	slog.Handler
+}
+
+func (h eventHandler) Handle(ctx context.Context, r slog.Record) error {
+	var sb strings.Builder
+	sb.WriteString(r.Time.Format("2006-01-02 15:04:05"))
+	sb.WriteString(" ")
+	sb.WriteString(r.Level.String())
+	sb.WriteString(" ")
+	sb.WriteString(r.Message)
+	r.Attrs(func(attr slog.Attr) bool {
+		sb.WriteString(" ")
+		sb.WriteString(attr.String())
+		return true
+	})
+	recordEvent(TruncateString(sb.String(), 500))
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h eventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return eventHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h eventHandler) WithGroup(name string) slog.Handler {
+	return eventHandler{h.Handler.WithGroup(name)}
+}
+
+// RotateWriter 按大小轮转的日志文件,历史文件命名为 file.1 ~ file.N,序号越大越旧
+type RotateWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func NewRotateWriter(path string, maxSize int64, backups int) (*RotateWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+	w := &RotateWriter{path: path, maxSize: maxSize, backups: backups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0640))
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file, w.size = file, info.Size()
+	return nil
+}
+
+// rotate 关闭当前文件,依次重命名历史文件并删除超出数量的文件
+func (w *RotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", w.path, w.backups))
+	for i := w.backups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if w.backups > 0 {
+		_ = os.Rename(w.path, w.path+".1")
+	} else {
+		_ = os.Remove(w.path)
+	}
+	return w.open()
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MailServer 邮件服务器信息
+type MailServer struct {
+	Host string
+	Port int
+	User string
+	Pwd  string
+	From string
+}
+
+// buildMail 生成纯文本邮件内容
+func buildMail(from, to, subject, body string) []byte {
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + to + "\r\n")
+	sb.WriteString("Subject: =?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(subject)) + "?=\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64(&sb, []byte(body))
+	return []byte(sb.String())
+}
+
+// writeBase64 按每行76个字符写入 base64 编码的内容
+func writeBase64(sb *strings.Builder, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		sb.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	sb.WriteString(encoded + "\r\n")
+}
+
+// buildMailFile 生成带一个附件的邮件内容
+func buildMailFile(from, to, subject, body, name string, data []byte) []byte {
+	boundary := "gossh_" + RandString(24)
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + to + "\r\n")
+	sb.WriteString("Subject: =?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(subject)) + "?=\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: multipart/mixed; boundary=\"" + boundary + "\"\r\n\r\n")
+	sb.WriteString("--" + boundary + "\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64(&sb, []byte(body))
+	sb.WriteString("--" + boundary + "\r\n")
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	sb.WriteString("Content-Type: " + contentType + "\r\n")
+	sb.WriteString("Content-Transfer-Encoding: base64\r\n")
+	sb.WriteString("Content-Disposition: attachment; filename=\"" + mime.QEncoding.Encode("UTF-8", name) + "\"\r\n\r\n")
+	writeBase64(&sb, data)
+	sb.WriteString("--" + boundary + "--\r\n")
+	return []byte(sb.String())
+}
+
+// SendMail 发送纯文本邮件,465端口使用TLS连接,其他端口支持时使用STARTTLS
+func SendMail(server MailServer, to, subject, body string) error {
+	from := server.From
+	if from == "" {
+		from = server.User
+	}
+	return sendMail(server, from, to, buildMail(from, to, subject, body))
+}
+
+// SendMailFile 发送带附件的邮件
+func SendMailFile(server MailServer, to, subject, body, name string, data []byte) error {
+	from := server.From
+	if from == "" {
+		from = server.User
+	}
+	return sendMail(server, from, to, buildMailFile(from, to, subject, body, name, data))
+}
+
+func sendMail(server MailServer, from, to string, msg []byte) error {
+	addr := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	var auth smtp.Auth
+	if server.User != "" {
+		auth = smtp.PlainAuth("", server.User, server.Pwd, server.Host)
+	}
+	if server.Port != 465 {
+		return smtp.SendMail(addr, auth, from, []string{to}, msg)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: server.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, server.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data error: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNS 记录类型
+const (
+	dnsTypeA    = 1
+	dnsTypePTR  = 12
+	dnsTypeAAAA = 28
+	dnsTypeSRV  = 33
+)
+
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// MdnsService 通过 DNS-SD 发现的服务实例
+type MdnsService struct {
+	Instance string
+	Host     string
+	Port     uint16
+	Addrs    []net.IP
+}
+
+// dnsName 编码域名
+func dnsName(name string) []byte {
+	var buf []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0)
+}
+
+// readDnsName 解析域名,支持压缩指针
+func readDnsName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("dns name overflow")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errors.New("dns pointer overflow")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			if jumps++; jumps > 16 {
+				return "", 0, errors.New("dns pointer loop")
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errors.New("dns label overflow")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	data  []byte
+	off   int
+}
+
+// parseDnsRecords 解析应答、授权和附加记录
+func parseDnsRecords(msg []byte) ([]dnsRecord, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("dns message too short")
+	}
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	rr := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	off := 12
+	for i := 0; i < qd; i++ {
+		_, next, err := readDnsName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4
+	}
+	var records []dnsRecord
+	for i := 0; i < rr; i++ {
+		name, next, err := readDnsName(msg, off)
+		if err != nil {
+			return records, err
+		}
+		if next+10 > len(msg) {
+			return records, errors.New("dns record overflow")
+		}
+		rtype := binary.BigEndian.Uint16(msg[next:])
+		size := int(binary.BigEndian.Uint16(msg[next+8:]))
+		off = next + 10
+		if off+size > len(msg) {
+			return records, errors.New("dns record overflow")
+		}
+		records = append(records, dnsRecord{name: strings.ToLower(name), rtype: rtype, data: msg[off : off+size], off: off})
+		off += size
+	}
+	return records, nil
+}
+
+// MdnsBrowse 在本地网络通过 mDNS 查询 DNS-SD 服务,例如 _ssh._tcp.local
+func MdnsBrowse(service string, timeout time.Duration) ([]MdnsService, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	query := make([]byte, 12)
+	binary.BigEndian.PutUint16(query[4:], 1)
+	query = append(query, dnsName(service)...)
+	query = binary.BigEndian.AppendUint16(query, dnsTypePTR)
+	// QU 位要求单播应答
+	query = binary.BigEndian.AppendUint16(query, 0x8001)
+	if _, err := conn.WriteToUDP(query, mdnsAddr); err != nil {
+		return nil, err
+	}
+
+	service = strings.ToLower(strings.TrimSuffix(service, ".") + ".")
+	instances := map[string]*MdnsService{}
+	hosts := map[string][]net.IP{}
+	var order []string
+	buf := make([]byte, 9000)
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		msg := buf[:n]
+		records, _ := parseDnsRecords(msg)
+		for _, record := range records {
+			switch record.rtype {
+			case dnsTypePTR:
+				if record.name != service {
+					continue
+				}
+				instance, _, err := readDnsName(msg, record.off)
+				if err != nil {
+					continue
+				}
+				instance = strings.ToLower(instance)
+				if _, ok := instances[instance]; !ok {
+					instances[instance] = &MdnsService{Instance: strings.TrimSuffix(strings.TrimSuffix(instance, service), ".")}
+					order = append(order, instance)
+				}
+			case dnsTypeSRV:
+				if len(record.data) < 7 || !strings.HasSuffix(record.name, "."+service) {
+					continue
+				}
+				host, _, err := readDnsName(msg, record.off+6)
+				if err != nil {
+					continue
+				}
+				item, ok := instances[record.name]
+				if !ok {
+					item = &MdnsService{Instance: strings.TrimSuffix(strings.TrimSuffix(record.name, service), ".")}
+					instances[record.name] = item
+					order = append(order, record.name)
+				}
+				item.Host = strings.ToLower(host)
+				item.Port = binary.BigEndian.Uint16(record.data[4:])
+			case dnsTypeA, dnsTypeAAAA:
+				if len(record.data) == net.IPv4len || len(record.data) == net.IPv6len {
+					hosts[record.name] = append(hosts[record.name], net.IP(append([]byte(nil), record.data...)))
+				}
+			}
+		}
+	}
+
+	var list []MdnsService
+	for _, name := range order {
+		item := instances[name]
+		if item.Host == "" || item.Port == 0 {
+			continue
+		}
+		item.Addrs = hosts[item.Host]
+		list = append(list, *item)
+	}
+	return list, nil
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier 通知发送接口
+type Notifier interface {
+	Send(title, content string) error
+}
+
+// FileNotifier 支持发送附件的通知渠道,不支持的渠道只发送文本内容
+type FileNotifier interface {
+	SendFile(title, content, name string, data []byte) error
+}
+
+var notifyHttpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON 发送 JSON 请求,返回响应内容
+func postJSON(webhook string, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := notifyHttpClient.Post(webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webhook status %d: %s", resp.StatusCode, TruncateString(string(body), 200))
+	}
+	return body, nil
+}
+
+// checkErrCode 钉钉和企业微信通过 errcode 返回错误
+func checkErrCode(body []byte) error {
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("webhook errcode %d: %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+// MailNotifier 邮件通知,多个收件人分别发送
+type MailNotifier struct {
+	Server MailServer
+	To     []string
+}
+
+func (n MailNotifier) Send(title, content string) error {
+	if n.Server.Host == "" {
+		return errors.New("smtp host is empty")
+	}
+	var errs []error
+	for _, to := range n.To {
+		if err := SendMail(n.Server, to, title, content); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendFile 发送带附件的邮件
+func (n MailNotifier) SendFile(title, content, name string, data []byte) error {
+	if n.Server.Host == "" {
+		return errors.New("smtp host is empty")
+	}
+	var errs []error
+	for _, to := range n.To {
+		if err := SendMailFile(n.Server, to, title, content, name, data); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SlackNotifier Slack Incoming Webhook
+type SlackNotifier struct {
+	Webhook string
+}
+
+func (n SlackNotifier) Send(title, content string) error {
+	_, err := postJSON(n.Webhook, map[string]string{"text": "*" + title + "*\n" + content})
+	return err
+}
+
+// DingTalkNotifier 钉钉群机器人,Secret 不为空时使用加签
+type DingTalkNotifier struct {
+	Webhook string
+	Secret  string
+}
+
+func (n DingTalkNotifier) Send(title, content string) error {
+	webhook := n.Webhook
+	if n.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write([]byte(timestamp + "\n" + n.Secret))
+		sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		sep := "?"
+		if strings.Contains(webhook, "?") {
+			sep = "&"
+		}
+		webhook += sep + "timestamp=" + timestamp + "&sign=" + sign
+	}
+	body, err := postJSON(webhook, map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": title + "\n" + content},
+	})
+	if err != nil {
+		return err
+	}
+	return checkErrCode(body)
+}
+
+// WeComNotifier 企业微信群机器人
+type WeComNotifier struct {
+	Webhook string
+}
+
+func (n WeComNotifier) Send(title, content string) error {
+	body, err := postJSON(n.Webhook, map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": title + "\n" + content},
+	})
+	if err != nil {
+		return err
+	}
+	return checkErrCode(body)
+}
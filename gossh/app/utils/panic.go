@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PanicContext 发生 panic 时的上下文,Source 为发生位置,HTTP 请求中发生时附带请求信息
+type PanicContext struct {
+	Source    string `json:"source"`
+	RequestId string `json:"request_id"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	ClientIp  string `json:"client_ip"`
+	Uid       uint   `json:"uid"`
+}
+
+// PanicReport 一次 panic 的记录,Events 为发生前的最近日志
+type PanicReport struct {
+	Id      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Stack   string    `json:"stack"`
+	Events  []string  `json:"events"`
+	PanicContext
+}
+
+// ErrorReportConfig 错误上报配置,SentryDsn 为空时只在本地保存
+type ErrorReportConfig struct {
+	SentryDsn   string
+	Environment string
+	Release     string
+}
+
+const (
+	maxPanicReports = 100
+	maxRecentEvents = 50
+)
+
+var (
+	panicMu      sync.Mutex
+	panicReports []PanicReport
+	errorReport  ErrorReportConfig
+
+	eventMu      sync.Mutex
+	recentEvents []string
+)
+
+// ConfigureErrorReport 设置错误上报配置,可以在运行时重复调用
+func ConfigureErrorReport(conf ErrorReportConfig) {
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	errorReport = conf
+}
+
+// recordEvent 保存最近的日志,panic 时附带在记录中
+func recordEvent(event string) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	recentEvents = append(recentEvents, event)
+	if len(recentEvents) > maxRecentEvents {
+		recentEvents = recentEvents[len(recentEvents)-maxRecentEvents:]
+	}
+}
+
+// RecentEvents 最近的日志,从旧到新
+func RecentEvents() []string {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	return append([]string(nil), recentEvents...)
+}
+
+// ReportPanic 记录 panic 的堆栈、上下文和最近日志,配置了 Sentry 时异步上报
+// 需要在 recover 所在的 defer 函数中调用,堆栈才包含 panic 的位置
+func ReportPanic(err any, ctx PanicContext) PanicReport {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	report := PanicReport{
+		Id:           hex.EncodeToString(buf),
+		Time:         time.Now(),
+		Message:      fmt.Sprint(err),
+		Stack:        string(debug.Stack()),
+		Events:       RecentEvents(),
+		PanicContext: ctx,
+	}
+	slog.Error("panic recovered", "id", report.Id, "source", ctx.Source, "request_id", ctx.RequestId, "err_msg", report.Message, "stack", report.Stack)
+
+	panicMu.Lock()
+	panicReports = append(panicReports, report)
+	if len(panicReports) > maxPanicReports {
+		panicReports = panicReports[len(panicReports)-maxPanicReports:]
+	}
+	conf := errorReport
+	panicMu.Unlock()
+
+	if conf.SentryDsn != "" {
+		go func() {
+			if err := sendSentry(conf, report); err != nil {
+				slog.Error("sendSentry error:", "err_msg", err.Error())
+			}
+		}()
+	}
+	return report
+}
+
+// RecoverPanic 在 defer 中直接调用,恢复 panic 并记录
+func RecoverPanic(source string) {
+	if err := recover(); err != nil {
+		ReportPanic(err, PanicContext{Source: source})
+	}
+}
+
+// PanicReports 保存的 panic 记录,从新到旧
+func PanicReports() []PanicReport {
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	list := make([]PanicReport, 0, len(panicReports))
+	for i := len(panicReports) - 1; i >= 0; i-- {
+		list = append(list, panicReports[i])
+	}
+	return list
+}
+
+// ClearPanicReports 清空保存的 panic 记录
+func ClearPanicReports() {
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	panicReports = nil
+}
+
+var sentryHttpClient = &http.Client{Timeout: 10 * time.Second}
+
+// sentryStoreUrl 解析 DSN(https://key@host/project_id),返回上报地址和密钥
+func sentryStoreUrl(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", err
+	}
+	if u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid sentry dsn")
+	}
+	idx := strings.LastIndex(u.Path, "/")
+	project := u.Path[idx+1:]
+	if project == "" {
+		return "", "", fmt.Errorf("invalid sentry dsn: missing project id")
+	}
+	store := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path[:idx] + "/api/" + project + "/store/"}
+	return store.String(), u.User.Username(), nil
+}
+
+// sendSentry 使用 Sentry store 接口上报,兼容 Sentry 协议的服务(如 GlitchTip)都可以接收
+func sendSentry(conf ErrorReportConfig, report PanicReport) error {
+	storeUrl, key, err := sentryStoreUrl(conf.SentryDsn)
+	if err != nil {
+		return err
+	}
+	breadcrumbs := make([]map[string]any, 0, len(report.Events))
+	for _, event := range report.Events {
+		breadcrumbs = append(breadcrumbs, map[string]any{"message": event, "category": "log"})
+	}
+	event := map[string]any{
+		"event_id":    report.Id,
+		"timestamp":   report.Time.UTC().Format(time.RFC3339),
+		"level":       "fatal",
+		"platform":    "go",
+		"logger":      report.Source,
+		"environment": conf.Environment,
+		"release":     conf.Release,
+		"message":     report.Message,
+		"exception": map[string]any{"values": []map[string]any{
+			{"type": "panic", "value": report.Message},
+		}},
+		"tags":        map[string]string{"source": report.Source, "request_id": report.RequestId},
+		"extra":       map[string]any{"stack": report.Stack},
+		"breadcrumbs": map[string]any{"values": breadcrumbs},
+	}
+	if report.Method != "" {
+		event["request"] = map[string]any{"method": report.Method, "url": report.Path}
+		event["user"] = map[string]any{"id": fmt.Sprint(report.Uid), "ip_address": report.ClientIp}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, storeUrl, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=gossh/1.0, sentry_key=%s", key))
+	resp, err := sentryHttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sentry status %d", resp.StatusCode)
+	}
+	return nil
+}
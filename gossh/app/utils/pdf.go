@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PdfColumn 表格的列,Width 为列宽,单位为点
+type PdfColumn struct {
+	Name  string
+	Width float64
+}
+
+// PdfTable 生成表格形式的 PDF 报表,A4 横向分页
+// 使用 Adobe 亚洲字体包中的 STSong-Light 字体,不嵌入字体文件,支持中文
+type PdfTable struct {
+	Title   string
+	Summary []string
+	Columns []PdfColumn
+	Rows    [][]string
+}
+
+const (
+	pdfPageWidth  = 842.0
+	pdfPageHeight = 595.0
+	pdfMargin     = 36.0
+	pdfFontSize   = 8.0
+	pdfLineHeight = 12.0
+)
+
+// pdfText 将文本编码为 UCS-2 十六进制字符串,超出基本平面的字符替换为问号
+func pdfText(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('<')
+	for _, r := range s {
+		if r > 0xFFFF || r == utf8.RuneError {
+			r = '?'
+		}
+		fmt.Fprintf(&sb, "%04X", r)
+	}
+	sb.WriteByte('>')
+	return sb.String()
+}
+
+// pdfFit 按列宽截断文本,ASCII 字符按半个字宽计算
+func pdfFit(s string, width, size float64) string {
+	var used float64
+	for i, r := range s {
+		w := size
+		if r < 0x80 {
+			w = size / 2
+		}
+		if used+w > width-size {
+			return s[:i] + ".."
+		}
+		used += w
+	}
+	return s
+}
+
+// pdfPage 一页的内容流
+type pdfPage struct {
+	buf bytes.Buffer
+	y   float64
+}
+
+func (p *pdfPage) text(x, y, size float64, s string) {
+	fmt.Fprintf(&p.buf, "BT /F1 %.1f Tf %.2f %.2f Td %s Tj ET\n", size, x, y, pdfText(s))
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.buf, "%.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// Render 生成 PDF 文件内容
+func (t PdfTable) Render() []byte {
+	var pages []*pdfPage
+	var page *pdfPage
+	newPage := func() {
+		page = &pdfPage{y: pdfPageHeight - pdfMargin}
+		pages = append(pages, page)
+		if len(pages) == 1 {
+			page.y -= 16
+			page.text(pdfMargin, page.y, 14, t.Title)
+			page.y -= 6
+			for _, s := range t.Summary {
+				page.y -= pdfLineHeight
+				page.text(pdfMargin, page.y, pdfFontSize+1, s)
+			}
+			page.y -= pdfLineHeight
+		}
+		page.y -= pdfLineHeight
+		x := pdfMargin
+		for _, col := range t.Columns {
+			page.text(x, page.y, pdfFontSize, col.Name)
+			x += col.Width
+		}
+		page.line(pdfMargin, page.y-3, pdfPageWidth-pdfMargin, page.y-3)
+	}
+	newPage()
+	for _, row := range t.Rows {
+		if page.y-pdfLineHeight < pdfMargin+pdfLineHeight {
+			newPage()
+		}
+		page.y -= pdfLineHeight
+		x := pdfMargin
+		for i, col := range t.Columns {
+			if i < len(row) {
+				page.text(x, page.y, pdfFontSize, pdfFit(row[i], col.Width, pdfFontSize))
+			}
+			x += col.Width
+		}
+	}
+	for i, p := range pages {
+		p.text(pdfPageWidth-pdfMargin-40, pdfMargin/2, pdfFontSize, fmt.Sprintf("%d / %d", i+1, len(pages)))
+	}
+
+	// 对象编号: 1 Catalog, 2 Pages, 3 Type0 字体, 4 CID 字体, 5 字体描述, 之后每页占用页面和内容两个对象
+	var objs []string
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 6+i*2))
+	}
+	objs = append(objs,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	)
+	for i, p := range pages {
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 7+i*2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.buf.Len(), p.buf.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return out.Bytes()
+}
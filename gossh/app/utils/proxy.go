@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseProxyUrl 解析出站代理地址,支持 socks5、socks5h(由代理解析域名)和 http(CONNECT)
+func ParseProxyUrl(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址错误: %v", err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议 %s", u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, errors.New("代理地址需要包含主机和端口")
+	}
+	return u, nil
+}
+
+// DialProxy 通过代理连接目标地址,代理的认证信息从地址中读取
+func DialProxy(ctx context.Context, dialer *net.Dialer, proxy *url.URL, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxy.Host, err)
+	}
+	// 代理握手同样受连接超时限制
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else if dialer.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(dialer.Timeout))
+	}
+	switch proxy.Scheme {
+	case "http":
+		conn, err = httpConnect(conn, proxy, addr)
+	default:
+		err = socks5Connect(ctx, conn, proxy, addr)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", proxy.Host, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// bufferedConn 读取时先返回握手时已缓冲的数据
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// httpConnect 发送 CONNECT 请求,目标主机可能在代理响应后立即发送数据,缓冲区中剩余的数据需要保留
+func httpConnect(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if proxy.User != nil {
+		pwd, _ := proxy.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + pwd))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("connect %s: %s", addr, resp.Status)
+	}
+	if r.Buffered() == 0 {
+		return conn, nil
+	}
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+// socks5 回复码说明 RFC 1928
+var socks5Errors = []string{
+	"",
+	"general failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+// socks5Connect SOCKS5 握手,有用户名时使用用户名密码认证 RFC 1929
+// socks5 在本地解析域名,socks5h 将域名发给代理解析
+func socks5Connect(ctx context.Context, conn net.Conn, proxy *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %s", portStr)
+	}
+
+	methods := []byte{0x00}
+	if proxy.User != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return errors.New("invalid socks version")
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if proxy.User == nil {
+			return errors.New("socks proxy requires authentication")
+		}
+		user := proxy.User.Username()
+		pwd, _ := proxy.User.Password()
+		if len(user) > 255 || len(pwd) > 255 {
+			return errors.New("socks username or password too long")
+		}
+		msg := []byte{0x01, byte(len(user))}
+		msg = append(msg, user...)
+		msg = append(msg, byte(len(pwd)))
+		msg = append(msg, pwd...)
+		if _, err := conn.Write(msg); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("socks authentication failed")
+		}
+	default:
+		return errors.New("no acceptable socks authentication method")
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	ip := net.ParseIP(host)
+	if ip == nil && proxy.Scheme == "socks5" {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return err
+		}
+		if len(ips) == 0 {
+			return fmt.Errorf("no address for %s", host)
+		}
+		ip = ips[0]
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return errors.New("socks host name too long")
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, 0x01)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		if int(head[1]) < len(socks5Errors) {
+			return fmt.Errorf("socks connect %s: %s", addr, socks5Errors[head[1]])
+		}
+		return fmt.Errorf("socks connect %s: unknown error %d", addr, head[1])
+	}
+	// 跳过代理返回的绑定地址
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0]) + 2
+	default:
+		return errors.New("invalid socks address type")
+	}
+	_, err = io.CopyN(io.Discard, conn, int64(skip))
+	return err
+}
+
+// MatchNoProxy 目标主机是否在不使用代理的列表中,列表项可以是主机名、.域名后缀或 CIDR
+func MatchNoProxy(host string, list []string) bool {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	ip := net.ParseIP(host)
+	for _, item := range list {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch {
+		case item == "":
+		case item == "*":
+			return true
+		case strings.Contains(item, "/"):
+			if _, cidr, err := net.ParseCIDR(item); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(item, "."):
+			if strings.HasSuffix(host, item) || host == item[1:] {
+				return true
+			}
+		case host == item:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Client 兼容 S3 协议的对象存储客户端,使用 AWS Signature V4 签名
+type S3Client struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+}
+
+// S3Object 对象信息
+type S3Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+var s3HttpClient = &http.Client{Timeout: 30 * time.Minute}
+
+// PutObject 上传对象,body 需要支持 Seek 以计算签名
+func (c *S3Client) PutObject(key string, body io.ReadSeeker, size int64) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	resp, err := c.do(http.MethodPut, key, nil, body, size, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// GetObject 下载对象,调用方负责关闭返回值
+func (c *S3Client) GetObject(key string) (io.ReadCloser, error) {
+	resp, err := c.do(http.MethodGet, key, nil, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DeleteObject 删除对象
+func (c *S3Client) DeleteObject(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// ListObjects 列出指定前缀的所有对象
+func (c *S3Client) ListObjects(prefix string) ([]S3Object, error) {
+	type listResult struct {
+		Contents              []S3Object `xml:"Contents"`
+		IsTruncated           bool       `xml:"IsTruncated"`
+		NextContinuationToken string     `xml:"NextContinuationToken"`
+	}
+	var list []S3Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(http.MethodGet, "", query, nil, 0, "")
+		if err != nil {
+			return nil, err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return list, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (c *S3Client) do(method, key string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", c.Endpoint)
+	}
+	host := endpoint.Host
+	uriPath := "/" + key
+	if c.PathStyle {
+		uriPath = "/" + c.Bucket + uriPath
+	} else {
+		host = c.Bucket + "." + host
+	}
+	if payloadHash == "" {
+		payloadHash = hex.EncodeToString(sha256.New().Sum(nil))
+	}
+
+	canonicalURI := s3EscapePath(uriPath)
+	canonicalQuery := s3CanonicalQuery(query)
+	reqUrl := fmt.Sprintf("%s://%s%s", endpoint.Scheme, host, canonicalURI)
+	if canonicalQuery != "" {
+		reqUrl += "?" + canonicalQuery
+	}
+	req, err := http.NewRequest(method, reqUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	region := c.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI,
+		canonicalQuery,
+		"host:" + host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	signKey := s3Hmac([]byte("AWS4"+c.SecretKey), date)
+	signKey = s3Hmac(signKey, region)
+	signKey = s3Hmac(signKey, "s3")
+	signKey = s3Hmac(signKey, "aws4_request")
+	signature := hex.EncodeToString(s3Hmac(signKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+
+	resp, err := s3HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func s3Hmac(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape 按 RFC 3986 编码,只保留非保留字符
+func s3Escape(s string) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return sb.String()
+}
+
+func s3EscapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = s3Escape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SecretProvider 外部凭据来源,path 和 field 由 scheme:path#field 形式的引用解析得到
+type SecretProvider interface {
+	GetSecret(path, field string) (string, error)
+}
+
+// ParseSecretRef 解析 scheme:path#field 形式的凭据引用,field 可以省略
+func ParseSecretRef(ref string) (scheme, path, field string, ok bool) {
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok || scheme == "" || rest == "" || strings.ContainsAny(scheme, " /") {
+		return "", "", "", false
+	}
+	path, field, _ = strings.Cut(rest, "#")
+	return scheme, path, field, path != ""
+}
+
+// secretField 从 JSON 对象中取出字段,field 为空时返回原内容
+func secretField(data, field string) (string, error) {
+	if field == "" {
+		return data, nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(data), &obj); err != nil {
+		return "", fmt.Errorf("secret is not a json object: %w", err)
+	}
+	return jsonSecretField(obj, field)
+}
+
+func jsonSecretField(obj map[string]any, field string) (string, error) {
+	value, ok := obj[field]
+	if !ok {
+		return "", fmt.Errorf("secret field %s not found", field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("secret field %s is not a string", field)
+	}
+	return s, nil
+}
+
+// EnvSecret 从环境变量读取凭据,引用格式 env:VAR
+type EnvSecret struct{}
+
+func (EnvSecret) GetSecret(path, field string) (string, error) {
+	value, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("env %s not set", path)
+	}
+	return secretField(value, field)
+}
+
+// FileSecret 从文件读取凭据,引用格式 file:相对路径#字段,只能读取 Dir 目录下的文件
+// 文件内容为 JSON 对象时可以指定字段,否则去掉末尾换行后返回整个文件
+type FileSecret struct {
+	Dir string
+}
+
+func (s FileSecret) GetSecret(path, field string) (string, error) {
+	full := filepath.Join(s.Dir, filepath.Clean("/"+path))
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return "", err
+	}
+	if field == "" {
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return secretField(string(data), field)
+}
+
+var secretHttpClient = &http.Client{Timeout: 10 * time.Second}
+
+// VaultSecret HashiCorp Vault KV 引擎,引用格式 vault:挂载点/路径#字段
+// KvVersion 为 2 时请求 挂载点/data/路径,字段为空时使用 value 字段
+type VaultSecret struct {
+	Addr      string
+	Token     string
+	Namespace string
+	KvVersion int
+}
+
+func (s VaultSecret) GetSecret(path, field string) (string, error) {
+	if s.Addr == "" {
+		return "", errors.New("vault addr is empty")
+	}
+	apiPath := strings.Trim(path, "/")
+	if s.KvVersion != 1 {
+		mount, rest, ok := strings.Cut(apiPath, "/")
+		if !ok {
+			return "", fmt.Errorf("invalid vault path: %s", path)
+		}
+		apiPath = mount + "/data/" + rest
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(s.Addr, "/")+"/v1/"+apiPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.Token)
+	if s.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.Namespace)
+	}
+	body, err := cloudDoClient(secretHttpClient, req)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+	data := result.Data
+	if s.KvVersion != 1 {
+		inner, ok := data["data"].(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("vault secret %s has no data", path)
+		}
+		data = inner
+	}
+	if field == "" {
+		field = "value"
+	}
+	return jsonSecretField(data, field)
+}
+
+// AwsSecret AWS Secrets Manager,引用格式 aws:密钥名称或ARN#字段,字段为空时返回整个 SecretString
+type AwsSecret struct {
+	Region    string
+	AccessKey string
+	SecretKey string
+	Endpoint  string
+}
+
+func (s AwsSecret) GetSecret(path, field string) (string, error) {
+	endpoint, err := url.Parse(cloudEndpoint(s.Endpoint, "secretsmanager."+s.Region+".amazonaws.com"))
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint.Scheme+"://"+endpoint.Host+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256.Sum256(payload)
+	target := "secretsmanager.GetSecretValue"
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Target", target)
+
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		"content-type:application/x-amz-json-1.1\nhost:" + endpoint.Host + "\nx-amz-date:" + amzDate + "\nx-amz-target:" + target + "\n",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + s.Region + "/secretsmanager/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	signKey := s3Hmac([]byte("AWS4"+s.SecretKey), date)
+	signKey = s3Hmac(signKey, s.Region)
+	signKey = s3Hmac(signKey, "secretsmanager")
+	signKey = s3Hmac(signKey, "aws4_request")
+	signature := hex.EncodeToString(s3Hmac(signKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+
+	body, err := cloudDoClient(secretHttpClient, req)
+	if err != nil {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &awsErr) == nil && awsErr.Type != "" {
+			return "", fmt.Errorf("aws %s: %s", awsErr.Type, awsErr.Message)
+		}
+		return "", err
+	}
+	var result struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+	return secretField(result.SecretString, field)
+}
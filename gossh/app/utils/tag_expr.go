@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 标签表达式的最大嵌套层数
+const tagExprMaxDepth = 32
+
+// Tags 主机标签,键为标签名,值为该标签的所有取值,只有标签名时取值为空字符串
+type Tags map[string][]string
+
+// validTagChar 标签名和取值允许的字符
+func validTagChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		strings.ContainsRune("_-.:/@", r)
+}
+
+func validTagWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !validTagChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTags 解析逗号或换行分隔的标签,格式为 key=value 或 key,标签名不区分大小写
+func ParseTags(s string) (Tags, error) {
+	return parseTags(s, true)
+}
+
+// ParseTagFilter 解析云主机的标签过滤条件,格式与 ParseTags 相同,云厂商的标签名区分大小写
+func ParseTagFilter(s string) (Tags, error) {
+	return parseTags(s, false)
+}
+
+func parseTags(s string, lower bool) (Tags, error) {
+	tags := Tags{}
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(item, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if lower {
+			key = strings.ToLower(key)
+		}
+		if !validTagWord(key) || (value != "" && !validTagWord(value)) {
+			return nil, fmt.Errorf("标签格式错误: %s", item)
+		}
+		if !tags.Has(key, value) {
+			tags[key] = append(tags[key], value)
+		}
+	}
+	return tags, nil
+}
+
+// Has 判断是否有指定标签,value 为空时只判断标签名
+func (t Tags) Has(key, value string) bool {
+	values, ok := t[key]
+	if !ok || value == "" {
+		return ok
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// String 按标签名排序输出,用于保存规范化后的标签
+func (t Tags) String() string {
+	var items []string
+	for key, values := range t {
+		for _, value := range values {
+			if value == "" {
+				items = append(items, key)
+			} else {
+				items = append(items, key+"="+value)
+			}
+		}
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// TagExpr 标签表达式,例如 env=prod AND (role=db OR role=cache) AND NOT deprecated
+// 支持 = 和 != 比较,AND/OR/NOT 不区分大小写,只写标签名表示有该标签
+type TagExpr interface {
+	Match(tags Tags) bool
+}
+
+type tagTerm struct {
+	key, value string
+	not        bool
+}
+
+func (e tagTerm) Match(tags Tags) bool {
+	return tags.Has(e.key, e.value) != e.not
+}
+
+type tagAnd []TagExpr
+
+func (e tagAnd) Match(tags Tags) bool {
+	for _, item := range e {
+		if !item.Match(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+type tagOr []TagExpr
+
+func (e tagOr) Match(tags Tags) bool {
+	for _, item := range e {
+		if item.Match(tags) {
+			return true
+		}
+	}
+	return false
+}
+
+type tagNot struct {
+	expr TagExpr
+}
+
+func (e tagNot) Match(tags Tags) bool {
+	return !e.expr.Match(tags)
+}
+
+// tokenizeTagExpr 拆分为标签名、取值、运算符和括号
+func tokenizeTagExpr(s string) ([]string, error) {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '(' || r == ')' || r == '=':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '!' && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, "!=")
+			i += 2
+		case validTagChar(r):
+			j := i
+			for j < len(runes) && validTagChar(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("标签表达式包含无效字符: %c", r)
+		}
+	}
+	return tokens, nil
+}
+
+type tagParser struct {
+	tokens []string
+	pos    int
+	depth  int
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) keyword(word string) bool {
+	if strings.EqualFold(p.peek(), word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *tagParser) parseOr() (TagExpr, error) {
+	if p.depth++; p.depth > tagExprMaxDepth {
+		return nil, errors.New("标签表达式嵌套过深")
+	}
+	defer func() {
+		p.depth--
+	}()
+	var list tagOr
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, expr)
+		if !p.keyword("OR") {
+			break
+		}
+	}
+	if len(list) == 1 {
+		return list[0], nil
+	}
+	return list, nil
+}
+
+func (p *tagParser) parseAnd() (TagExpr, error) {
+	var list tagAnd
+	for {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, expr)
+		if !p.keyword("AND") {
+			break
+		}
+	}
+	if len(list) == 1 {
+		return list[0], nil
+	}
+	return list, nil
+}
+
+func (p *tagParser) parseNot() (TagExpr, error) {
+	if p.keyword("NOT") {
+		if p.depth++; p.depth > tagExprMaxDepth {
+			return nil, errors.New("标签表达式嵌套过深")
+		}
+		expr, err := p.parseNot()
+		p.depth--
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{expr: expr}, nil
+	}
+	if p.peek() == "(" {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("标签表达式缺少右括号")
+		}
+		p.pos++
+		return expr, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *tagParser) parseTerm() (TagExpr, error) {
+	key := p.peek()
+	if !validTagWord(key) || strings.EqualFold(key, "AND") || strings.EqualFold(key, "OR") {
+		if key == "" {
+			return nil, errors.New("标签表达式不完整")
+		}
+		return nil, fmt.Errorf("标签表达式错误: %s", key)
+	}
+	p.pos++
+	term := tagTerm{key: strings.ToLower(key)}
+	op := p.peek()
+	if op != "=" && op != "!=" {
+		return term, nil
+	}
+	p.pos++
+	value := p.peek()
+	if !validTagWord(value) {
+		return nil, fmt.Errorf("标签 %s 缺少取值", key)
+	}
+	p.pos++
+	term.value = value
+	term.not = op == "!="
+	return term, nil
+}
+
+// ParseTagExpr 解析标签表达式
+func ParseTagExpr(s string) (TagExpr, error) {
+	tokens, err := tokenizeTagExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("标签表达式为空")
+	}
+	p := &tagParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("标签表达式错误: %s", p.peek())
+	}
+	return expr, nil
+}
@@ -0,0 +1,371 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceConfig 链路追踪配置,Endpoint 为 OTLP/HTTP 接收地址(如 http://127.0.0.1:4318),为空时不采集
+type TraceConfig struct {
+	Endpoint    string
+	ServiceName string
+	SampleRatio float64
+	Headers     map[string]string
+}
+
+// SpanKind 与 OTLP 的 span kind 取值一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// 导出队列长度、单批数量和导出间隔
+const (
+	traceQueueSize     = 4096
+	traceBatchSize     = 256
+	traceFlushInterval = 5 * time.Second
+)
+
+var (
+	traceMu    sync.RWMutex
+	traceConf  TraceConfig
+	traceOnce  sync.Once
+	traceQueue = make(chan *Span, traceQueueSize)
+)
+
+// Span 一次操作的追踪记录,nil 表示未启用追踪,所有方法都可以安全调用
+type Span struct {
+	traceId  [16]byte
+	spanId   [8]byte
+	parentId [8]byte
+	sampled  bool
+	remote   bool
+
+	name  string
+	kind  SpanKind
+	start time.Time
+	end   time.Time
+
+	mu     sync.Mutex
+	attrs  map[string]any
+	errMsg string
+	ended  bool
+}
+
+type spanCtxKey struct{}
+
+// ConfigureTracing 更新追踪配置,配置了接收地址时启动导出协程
+func ConfigureTracing(conf TraceConfig) {
+	if conf.SampleRatio <= 0 || conf.SampleRatio > 1 {
+		conf.SampleRatio = 1
+	}
+	if conf.ServiceName == "" {
+		conf.ServiceName = "gossh"
+	}
+	traceMu.Lock()
+	traceConf = conf
+	traceMu.Unlock()
+	if conf.Endpoint != "" {
+		traceOnce.Do(func() {
+			go traceExportLoop()
+		})
+	}
+}
+
+func currentTraceConf() TraceConfig {
+	traceMu.RLock()
+	defer traceMu.RUnlock()
+	return traceConf
+}
+
+// TracingEnabled 是否启用了链路追踪
+func TracingEnabled() bool {
+	return currentTraceConf().Endpoint != ""
+}
+
+// SpanFromContext 返回上下文中的 span
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanCtxKey{}).(*Span)
+	return span
+}
+
+// ContextWithTraceparent 解析 W3C traceparent 请求头,作为后续 span 的远程父节点
+func ContextWithTraceparent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+	span := &Span{remote: true, ended: true}
+	if _, err := hex.Decode(span.traceId[:], []byte(parts[1])); err != nil || span.traceId == [16]byte{} {
+		return ctx
+	}
+	if _, err := hex.Decode(span.spanId[:], []byte(parts[2])); err != nil || span.spanId == [8]byte{} {
+		return ctx
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return ctx
+	}
+	span.sampled = flags[0]&0x01 == 1
+	return context.WithValue(ctx, spanCtxKey{}, span)
+}
+
+// StartSpan 创建 span,上下文中有父 span 时继承追踪ID和采样结果,否则按采样率开始新的追踪
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conf := currentTraceConf()
+	if conf.Endpoint == "" {
+		return ctx, nil
+	}
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.traceId = parent.traceId
+		span.parentId = parent.spanId
+		span.sampled = parent.sampled
+	} else {
+		_, _ = rand.Read(span.traceId[:])
+		span.sampled = conf.SampleRatio >= 1 || float64(binary.BigEndian.Uint64(span.traceId[8:])>>11)/(1<<53) < conf.SampleRatio
+	}
+	_, _ = rand.Read(span.spanId[:])
+	return context.WithValue(ctx, spanCtxKey{}, span), span
+}
+
+// TraceId 十六进制追踪ID
+func (s *Span) TraceId() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceId[:])
+}
+
+// Traceparent 用于向下游传递的 W3C traceparent
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(s.traceId[:]) + "-" + hex.EncodeToString(s.spanId[:]) + "-" + flags
+}
+
+// IsRoot 是否为本进程开始的追踪
+func (s *Span) IsRoot() bool {
+	return s != nil && s.parentId == [8]byte{}
+}
+
+// SetAttr 设置属性,支持字符串、整数、浮点数和布尔值
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = map[string]any{}
+	}
+	s.attrs[key] = value
+}
+
+// SetError 标记 span 失败
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errMsg = err.Error()
+}
+
+// End 结束 span,采样的 span 加入导出队列,队列满时丢弃
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	if !s.sampled {
+		return
+	}
+	select {
+	case traceQueue <- s:
+	default:
+	}
+}
+
+// Drop 结束 span 但不导出
+func (s *Span) Drop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+// Duration span 已持续的时间
+func (s *Span) Duration() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return time.Since(s.start)
+}
+
+// otlpAttrs 转换为 OTLP/JSON 属性
+func otlpAttrs(attrs map[string]any) []map[string]any {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]map[string]any, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]any
+		switch v := attrs[key].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case uint:
+			value = map[string]any{"intValue": strconv.FormatUint(uint64(v), 10)}
+		case uint16:
+			value = map[string]any{"intValue": strconv.FormatUint(uint64(v), 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		list = append(list, map[string]any{"key": key, "value": value})
+	}
+	return list
+}
+
+// otlpPayload 生成 OTLP/JSON 格式的导出请求,追踪ID和 span ID 使用十六进制
+func otlpPayload(serviceName string, spans []*Span) ([]byte, error) {
+	list := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		item := map[string]any{
+			"traceId":           hex.EncodeToString(s.traceId[:]),
+			"spanId":            hex.EncodeToString(s.spanId[:]),
+			"name":              s.name,
+			"kind":              int(s.kind),
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttrs(s.attrs),
+		}
+		if s.parentId != [8]byte{} {
+			item["parentSpanId"] = hex.EncodeToString(s.parentId[:])
+		}
+		if s.errMsg != "" {
+			item["status"] = map[string]any{"code": 2, "message": s.errMsg}
+		}
+		s.mu.Unlock()
+		list = append(list, item)
+	}
+	return json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttrs(map[string]any{"service.name": serviceName}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "gossh"},
+				"spans": list,
+			}},
+		}},
+	})
+}
+
+// exportSpans 发送到 OTLP/HTTP 接收地址
+func exportSpans(conf TraceConfig, spans []*Span) error {
+	payload, err := otlpPayload(conf.ServiceName, spans)
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(conf.Endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range conf.Headers {
+		req.Header.Set(k, v)
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("otlp export status: " + resp.Status)
+	}
+	return nil
+}
+
+// traceExportLoop 批量导出 span
+func traceExportLoop() {
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		conf := currentTraceConf()
+		if conf.Endpoint != "" {
+			if err := exportSpans(conf, batch); err != nil {
+				slog.Warn("trace export error:", "err_msg", err.Error(), "spans", len(batch))
+			}
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span := <-traceQueue:
+			batch = append(batch, span)
+			if len(batch) >= traceBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gossh/websocket"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cliConfig 保存在用户目录下的登录信息
+type cliConfig struct {
+	Server   string `json:"server"`
+	Token    string `json:"token"`
+	Insecure bool   `json:"insecure"`
+}
+
+func configPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gossh-cli.json")
+}
+
+func loadConfig() (cliConfig, error) {
+	var conf cliConfig
+	data, err := os.ReadFile(configPath())
+	if err == nil {
+		if err := json.Unmarshal(data, &conf); err != nil {
+			return conf, err
+		}
+	}
+	if server := os.Getenv("GOSSH_SERVER"); server != "" {
+		conf.Server = server
+	}
+	if token := os.Getenv("GOSSH_TOKEN"); token != "" {
+		conf.Token = token
+	}
+	if conf.Server == "" || conf.Token == "" {
+		return conf, errors.New("not logged in, run: gossh-cli login")
+	}
+	return conf, nil
+}
+
+func saveConfig(conf cliConfig) error {
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(), data, os.FileMode(0600))
+}
+
+// apiClient 调用 GoWebSSH 接口
+type apiClient struct {
+	conf cliConfig
+	http *http.Client
+}
+
+// apiResult 接口统一返回格式
+type apiResult struct {
+	Code  int             `json:"code"`
+	Msg   string          `json:"msg"`
+	Data  json.RawMessage `json:"data"`
+	Token string          `json:"token"`
+}
+
+func newClient(conf cliConfig) *apiClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: conf.Insecure}
+	return &apiClient{
+		conf: conf,
+		http: &http.Client{Transport: transport, Timeout: 10 * time.Minute},
+	}
+}
+
+func (c *apiClient) url(path string, query url.Values) string {
+	u := strings.TrimRight(c.conf.Server, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// do 发送请求,服务端续签 token 时更新本地配置
+func (c *apiClient) do(method, path string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.url(path, query), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.conf.Token != "" {
+		req.Header.Set("Authorization", c.conf.Token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if newToken := resp.Header.Get("NewToken"); newToken != "" && os.Getenv("GOSSH_TOKEN") == "" {
+		c.conf.Token = newToken
+		_ = saveConfig(c.conf)
+	}
+	return resp, nil
+}
+
+// call 发送 JSON 请求并解析统一返回格式
+func (c *apiClient) call(method, path string, query url.Values, payload any) (apiResult, error) {
+	var result apiResult
+	var body io.Reader
+	contentType := ""
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return result, err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	resp, err := c.do(method, path, query, contentType, body)
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if result.Code != 0 {
+		return result, fmt.Errorf("code %d: %s", result.Code, result.Msg)
+	}
+	return result, nil
+}
+
+// openSession 使用保存的主机配置创建 ssh 会话
+func (c *apiClient) openSession(confId uint, confirm string) (string, error) {
+	ret, err := c.call(http.MethodGet, fmt.Sprintf("/api/conn_conf/%d", confId), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	var conf map[string]any
+	if err := json.Unmarshal(ret.Data, &conf); err != nil {
+		return "", err
+	}
+	var query url.Values
+	if confirm != "" {
+		query = url.Values{"confirm": {confirm}}
+	}
+	ret, err = c.call(http.MethodPost, "/api/ssh/create_session", query, conf)
+	if err != nil {
+		return "", err
+	}
+	var sessionId string
+	err = json.Unmarshal(ret.Data, &sessionId)
+	return sessionId, err
+}
+
+func (c *apiClient) closeSession(sessionId string) {
+	_, _ = c.call(http.MethodPost, "/api/ssh/disconnect", url.Values{"session_id": {sessionId}}, nil)
+}
+
+// keepAlive 刷新会话活跃时间,防止被服务端清理
+func (c *apiClient) keepAlive(sessionId string) {
+	form := url.Values{"ids": {sessionId}}
+	resp, err := c.do(http.MethodPut, "/api/conn_manage/refresh_conn_time", nil,
+		"application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+}
+
+// dialTunnel 建立到 ssh 主机目标地址的 WebSocket 隧道
+func (c *apiClient) dialTunnel(sessionId, host, port, confirm string) (*websocket.Conn, error) {
+	server, err := url.Parse(c.conf.Server)
+	if err != nil {
+		return nil, err
+	}
+	origin := server.Scheme + "://" + server.Host
+	if server.Scheme == "https" {
+		server.Scheme = "wss"
+	} else {
+		server.Scheme = "ws"
+	}
+	query := url.Values{
+		"session_id":    {sessionId},
+		"host":          {host},
+		"port":          {port},
+		"Authorization": {c.conf.Token},
+	}
+	if confirm != "" {
+		query.Set("confirm", confirm)
+	}
+	wsConf, err := websocket.NewConfig(strings.TrimRight(server.String(), "/")+"/api/ssh/tunnel?"+query.Encode(), origin)
+	if err != nil {
+		return nil, err
+	}
+	wsConf.TlsConfig = &tls.Config{InsecureSkipVerify: c.conf.Insecure}
+	ws, err := websocket.DialConfig(wsConf)
+	if err != nil {
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return ws, nil
+}
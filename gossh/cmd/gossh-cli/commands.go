@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func cmdLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	server := fs.String("server", "", "GoWebSSH server url, e.g. https://127.0.0.1:8899")
+	name := fs.String("name", "", "user name")
+	pwd := fs.String("pwd", "", "password")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *server == "" || *name == "" || *pwd == "" {
+		return errors.New("-server, -name and -pwd are required")
+	}
+
+	client := newClient(cliConfig{Server: *server, Insecure: *insecure})
+	ret, err := client.call(http.MethodPost, "/api/login", nil, map[string]string{"name": *name, "pwd": *pwd})
+	if err != nil {
+		return err
+	}
+	if ret.Token == "" {
+		return errors.New("server returned no token")
+	}
+	client.conf.Token = ret.Token
+	if err := saveConfig(client.conf); err != nil {
+		return err
+	}
+	fmt.Println("login success, config saved to", configPath())
+	return nil
+}
+
+func cmdConf(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: gossh-cli conf list|add")
+	}
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	client := newClient(conf)
+
+	switch args[0] {
+	case "list":
+		ret, err := client.call(http.MethodGet, "/api/conn_conf", nil, nil)
+		if err != nil {
+			return err
+		}
+		var list []struct {
+			ID        uint   `json:"id"`
+			Name      string `json:"name"`
+			Address   string `json:"address"`
+			Port      uint16 `json:"port"`
+			User      string `json:"user"`
+			GroupName string `json:"group_name"`
+		}
+		if err := json.Unmarshal(ret.Data, &list); err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tNAME\tADDRESS\tUSER\tGROUP")
+		for _, item := range list {
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", item.ID, item.Name,
+				net.JoinHostPort(item.Address, fmt.Sprint(item.Port)), item.User, item.GroupName)
+		}
+		return w.Flush()
+	case "add":
+		fs := flag.NewFlagSet("conf add", flag.ContinueOnError)
+		name := fs.String("name", "", "config name")
+		address := fs.String("address", "", "host address")
+		port := fs.Uint("port", 22, "ssh port")
+		user := fs.String("user", "", "login user")
+		pwd := fs.String("pwd", "", "login password")
+		key := fs.String("key", "", "private key file, use cert auth")
+		keyPwd := fs.String("key-pwd", "", "private key passphrase")
+		userCert := fs.String("user-cert", "", "CA signed user certificate file, used with -key")
+		group := fs.String("group", "", "group name")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		payload := map[string]any{
+			"name":         *name,
+			"address":      *address,
+			"port":         *port,
+			"user":         *user,
+			"pwd":          *pwd,
+			"auth_type":    "pwd",
+			"net_type":     "tcp4",
+			"font_size":    14,
+			"background":   "#000000",
+			"foreground":   "#FFFFFF",
+			"cursor_color": "#FFFFFF",
+			"font_family":  "Courier",
+			"cursor_style": "block",
+			"shell":        "bash",
+			"pty_type":     "xterm-256color",
+			"group_name":   *group,
+		}
+		if *key != "" {
+			data, err := os.ReadFile(*key)
+			if err != nil {
+				return err
+			}
+			payload["auth_type"] = "cert"
+			payload["cert_data"] = string(data)
+			payload["cert_pwd"] = *keyPwd
+			if *userCert != "" {
+				data, err := os.ReadFile(*userCert)
+				if err != nil {
+					return err
+				}
+				payload["auth_type"] = "ssh_cert"
+				payload["user_cert"] = string(data)
+			}
+		}
+		ret, err := client.call(http.MethodPost, "/api/conn_conf", nil, payload)
+		if err != nil {
+			return err
+		}
+		fmt.Println(ret.Msg)
+		return nil
+	default:
+		return fmt.Errorf("unknown conf command: %s", args[0])
+	}
+}
+
+// sessionFlags 解析 -id 参数并建立 ssh 会话
+func sessionFlags(name string, args []string) (*apiClient, string, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	id := fs.Uint("id", 0, "connection config id")
+	confirm := fs.String("confirm", "", "host address, confirms connecting to a prod host")
+	if err := fs.Parse(args); err != nil {
+		return nil, "", nil, err
+	}
+	if *id == 0 {
+		return nil, "", nil, errors.New("-id is required")
+	}
+	conf, err := loadConfig()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	client := newClient(conf)
+	sessionId, err := client.openSession(uint(*id), *confirm)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return client, sessionId, fs.Args(), nil
+}
+
+func cmdExec(args []string) error {
+	client, sessionId, rest, err := sessionFlags("exec", args)
+	if err != nil {
+		return err
+	}
+	defer client.closeSession(sessionId)
+	if len(rest) == 0 {
+		return errors.New("usage: gossh-cli exec -id CONF_ID COMMAND")
+	}
+
+	ret, err := client.call(http.MethodPost, "/api/ssh/exec", nil, map[string]string{
+		"session_id": sessionId,
+		"cmd":        strings.Join(rest, " "),
+	})
+	var out string
+	_ = json.Unmarshal(ret.Data, &out)
+	fmt.Print(out)
+	return err
+}
+
+func cmdSftp(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: gossh-cli sftp get|put")
+	}
+	client, sessionId, rest, err := sessionFlags("sftp "+args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	defer client.closeSession(sessionId)
+	if len(rest) != 2 {
+		return errors.New("usage: gossh-cli sftp get|put -id CONF_ID SRC DST")
+	}
+
+	switch args[0] {
+	case "get":
+		return sftpGet(client, sessionId, rest[0], rest[1])
+	case "put":
+		return sftpPut(client, sessionId, rest[0], rest[1])
+	default:
+		return fmt.Errorf("unknown sftp command: %s", args[0])
+	}
+}
+
+func sftpGet(client *apiClient, sessionId, remotePath, localPath string) error {
+	resp, err := client.do(http.MethodGet, "/api/sftp/download",
+		url.Values{"path": {remotePath}, "session_id": {sessionId}}, "", nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	// 下载出错时服务端返回 JSON
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var ret apiResult
+		if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+			return err
+		}
+		return fmt.Errorf("code %d: %s", ret.Code, ret.Msg)
+	}
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, filepath.Base(remotePath))
+	}
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s (%d bytes)\n", remotePath, localPath, n)
+	return nil
+}
+
+func sftpPut(client *apiClient, sessionId, localPath, remoteDir string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// 边读文件边上传,避免大文件占用内存
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		err := writer.WriteField("path", remoteDir)
+		if err == nil {
+			err = writer.WriteField("session_id", sessionId)
+		}
+		if err == nil {
+			var part io.Writer
+			part, err = writer.CreateFormFile("files", filepath.Base(localPath))
+			if err == nil {
+				_, err = io.Copy(part, f)
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	resp, err := client.do(http.MethodPut, "/api/sftp/upload", nil, writer.FormDataContentType(), pr)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var ret apiResult
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if ret.Code != 0 {
+		return fmt.Errorf("code %d: %s", ret.Code, ret.Msg)
+	}
+	fmt.Printf("%s -> %s\n", localPath, remoteDir)
+	return nil
+}
+
+func cmdTunnel(args []string) error {
+	fs := flag.NewFlagSet("tunnel", flag.ContinueOnError)
+	id := fs.Uint("id", 0, "connection config id")
+	local := fs.String("local", "127.0.0.1:0", "local listen address")
+	remote := fs.String("remote", "", "remote address, resolved on the ssh host")
+	confirm := fs.String("confirm", "", "host address, confirms connecting to a prod host")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == 0 || *remote == "" {
+		return errors.New("-id and -remote are required")
+	}
+	host, port, err := net.SplitHostPort(*remote)
+	if err != nil {
+		return err
+	}
+
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	client := newClient(conf)
+	sessionId, err := client.openSession(uint(*id), *confirm)
+	if err != nil {
+		return err
+	}
+	defer client.closeSession(sessionId)
+
+	listener, err := net.Listen("tcp", *local)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	fmt.Printf("forwarding %s -> %s\n", listener.Addr(), *remote)
+
+	go func() {
+		for {
+			time.Sleep(20 * time.Second)
+			client.keepAlive(sessionId)
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := forward(client, conn, sessionId, host, port, *confirm); err != nil {
+				fmt.Fprintln(os.Stderr, "tunnel error:", err)
+			}
+		}()
+	}
+}
+
+// forward 将本地连接通过 WebSocket 转发到 ssh 主机
+func forward(client *apiClient, conn net.Conn, sessionId, host, port, confirm string) error {
+	defer func() {
+		_ = conn.Close()
+	}()
+	ws, err := client.dialTunnel(sessionId, host, port, confirm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ws.Close()
+	}()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(ws, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, ws)
+		done <- struct{}{}
+	}()
+	<-done
+	return nil
+}
@@ -0,0 +1,69 @@
+// gossh-cli GoWebSSH 命令行客户端,通过 HTTP/WebSocket API 使用 GoWebSSH 作为网关
+//
+// 用法:
+//
+//	gossh-cli login -server https://127.0.0.1:8899 -name admin -pwd xxx
+//	gossh-cli conf list
+//	gossh-cli conf add -name web -address 10.0.0.1 -user root -pwd xxx
+//	gossh-cli exec -id 1 "uptime"
+//	gossh-cli sftp get -id 1 /etc/hosts ./hosts
+//	gossh-cli sftp put -id 1 ./app.tar.gz /tmp
+//	gossh-cli tunnel -id 1 -local 127.0.0.1:13306 -remote 127.0.0.1:3306
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `gossh-cli GoWebSSH command line client
+
+Usage:
+  gossh-cli login  -server URL -name NAME -pwd PASSWORD [-insecure]
+  gossh-cli conf   list
+  gossh-cli conf   add -name NAME -address HOST -user USER [-port 22] [-pwd PWD] [-key FILE [-user-cert FILE]]
+  gossh-cli exec   -id CONF_ID COMMAND
+  gossh-cli sftp   get -id CONF_ID REMOTE_PATH LOCAL_PATH
+  gossh-cli sftp   put -id CONF_ID LOCAL_PATH REMOTE_DIR
+  gossh-cli tunnel -id CONF_ID -local ADDR -remote ADDR
+
+exec, sftp and tunnel accept -confirm HOST_ADDRESS when the server requires
+confirmation for prod hosts.
+
+The token is saved in ~/.gossh-cli.json, or use GOSSH_SERVER and GOSSH_TOKEN
+(a personal API token) environment variables.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "login":
+		err = cmdLogin(args)
+	case "conf":
+		err = cmdConf(args)
+	case "exec":
+		err = cmdExec(args)
+	case "sftp":
+		err = cmdSftp(args)
+	case "tunnel":
+		err = cmdTunnel(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		err = fmt.Errorf("unknown command: %s", os.Args[1])
+	}
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
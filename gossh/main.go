@@ -1,20 +1,25 @@
 package main
 
 import (
+	"crypto/tls"
 	"embed"
 	"errors"
-	"fmt"
 	"gossh/app/config"
 	"gossh/app/middleware"
 	"gossh/app/service"
+	"gossh/app/utils"
 	"gossh/gin"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 )
 
 // 使用go 1.16+ 新特性
@@ -29,6 +34,7 @@ type StaticFile struct {
 }
 
 // Open 静态资源被访问的核心逻辑
+// 配置了 WebrootDir 时优先使用该目录中的文件,用于替换品牌资源或增加页面,目录只在嵌入资源中不存在时使用
 func (w StaticFile) Open(name string) (fs.File, error) {
 	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) {
 		return nil, errors.New("http: invalid character in file path")
@@ -37,31 +43,75 @@ func (w StaticFile) Open(name string) (fs.File, error) {
 	fullName := filepath.Join(w.path, filepath.FromSlash(path.Clean("/"+name)))
 	fullName = strings.ReplaceAll(fullName, `\`, `/`)
 	file, err := w.embedFS.Open(fullName)
+
+	if overlay := config.DefaultConfig.WebrootDir; overlay != "" {
+		relName := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+		if relName == "" {
+			relName = "."
+		}
+		if f, overlayErr := os.DirFS(overlay).Open(relName); overlayErr == nil {
+			if info, statErr := f.Stat(); statErr == nil && (!info.IsDir() || err != nil) {
+				if err == nil {
+					_ = file.Close()
+				}
+				return f, nil
+			}
+			_ = f.Close()
+		}
+	}
 	return file, err
 }
 
 func main() {
 	gin.SetMode(gin.ReleaseMode)
-	var engine = gin.Default()
-	engine.Use(middleware.NetFilter())
+	var engine = gin.New()
+	engine.Use(middleware.Recovery(), middleware.ForwardedFor(), middleware.RequestId(), middleware.AccessLog(), middleware.SecurityHeaders(), middleware.Trace(), middleware.I18n(), middleware.NetFilter(), middleware.CsrfGuard(), middleware.RequestLimit())
 
 	engine.NoRoute(func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "/app")
 	})
 
-	engine.POST("/api/login", service.UserLogin)
+	engine.POST("/api/login", middleware.DbHealth(), service.UserLogin)
 	engine.POST("/api/sys/db_conn_check", service.DbConnCheck)
 	engine.GET("/api/sys/is_init", service.GetIsInit)
 	engine.POST("/api/sys/init", service.SysInit)
 
-	var router = engine.Group("", middleware.SysInit(), middleware.JWTAuth())
+	// 服务状态,无需登录,限制访问频率
+	statusLimit := middleware.RateLimit(30)
+	engine.GET("/api/status", statusLimit, service.ServiceStatusGet)
+	engine.GET("/status", statusLimit, service.ServiceStatusPage)
+	engine.GET("/api/sys/branding", statusLimit, service.BrandingGet)
+
+	// 会话只读分享,访客无需登录,限制访问频率
+	engine.GET("/api/share/watch", middleware.RateLimit(30), middleware.SysInit(), middleware.DbHealth(), service.SessionShareWatch)
+
+	// 自助重置密码,无需登录,限制访问频率
+	resetLimit := middleware.RateLimit(10)
+	engine.POST("/api/password_reset/request", resetLimit, middleware.SysInit(), middleware.DbHealth(), service.PasswordResetRequest)
+	engine.POST("/api/password_reset/confirm", resetLimit, middleware.SysInit(), middleware.DbHealth(), service.PasswordResetConfirm)
+
+	// 数据库不可用时直接返回 503,不再进入处理函数
+	var router = engine.Group("", middleware.SysInit(), middleware.DbHealth(), middleware.JWTAuth())
+
+	// 所有租户共用的系统配置,只允许默认租户访问
+	var platform = router.Group("", middleware.DefaultTenant())
 
 	{ // SSH 连接配置
 		router.GET("/api/conn_conf", service.ConfFindAll)
+		router.GET("/api/conn_conf/health", service.ConfHealth)
+		router.GET("/api/conn_conf/tags", service.ConfTagList)
+		router.GET("/api/conn_conf/tag_match", service.ConfTagMatch)
 		router.GET("/api/conn_conf/:id", service.ConfFindByID)
+		router.GET("/api/conn_conf/:id/facts", service.ConfFacts)
 		router.POST("/api/conn_conf", service.ConfCreate)
 		router.PUT("/api/conn_conf", service.ConfUpdateById)
+		router.PUT("/api/conn_conf/bulk", service.ConfBulkUpsert)
+		router.POST("/api/conn_conf/bulk_delete", service.ConfBulkDelete)
+		router.PUT("/api/conn_conf/tags", service.ConfTagsSet)
 		router.DELETE("/api/conn_conf/:id", service.ConfDeleteById)
+		router.POST("/api/conn_conf/import/preview", service.ConfImportPreview)
+		router.POST("/api/conn_conf/import", service.ConfImport)
+		router.POST("/api/wol", service.Wol)
 	}
 
 	{ // 命令收藏
@@ -70,6 +120,34 @@ func main() {
 		router.POST("/api/cmd_note", service.CmdNoteCreate)
 		router.PUT("/api/cmd_note", service.CmdNoteUpdateById)
 		router.DELETE("/api/cmd_note/:id", service.CmdNoteDeleteById)
+		router.POST("/api/cmd_note/run", service.CmdNoteRun)
+	}
+
+	{ // 流水线
+		router.GET("/api/pipeline", service.PipelineFindAll)
+		router.GET("/api/pipeline/:id", service.PipelineFindByID)
+		router.POST("/api/pipeline", service.PipelineCreate)
+		router.PUT("/api/pipeline", service.PipelineUpdateById)
+		router.DELETE("/api/pipeline/:id", service.PipelineDeleteById)
+		router.POST("/api/pipeline/run", service.PipelineRun)
+		router.GET("/api/pipeline/run/:id", service.PipelineRunFind)
+		router.POST("/api/pipeline/confirm", service.PipelineConfirm)
+	}
+
+	{ // Shell 配置
+		router.GET("/api/shell_profile", service.ShellProfileFindAll)
+		router.GET("/api/shell_profile/:id", service.ShellProfileFindByID)
+		router.POST("/api/shell_profile", service.ShellProfileCreate)
+		router.PUT("/api/shell_profile", service.ShellProfileUpdateById)
+		router.DELETE("/api/shell_profile/:id", service.ShellProfileDeleteById)
+	}
+
+	{ // 按键宏
+		router.GET("/api/user_macro", service.UserMacroFindAll)
+		router.GET("/api/user_macro/:id", service.UserMacroFindByID)
+		router.POST("/api/user_macro", service.UserMacroCreate)
+		router.PUT("/api/user_macro", service.UserMacroUpdateById)
+		router.DELETE("/api/user_macro/:id", service.UserMacroDeleteById)
 	}
 
 	{ // 策略配置
@@ -77,15 +155,101 @@ func main() {
 		router.GET("/api/policy_conf/:id", service.PolicyConfFindByID)
 		router.POST("/api/policy_conf", service.PolicyConfCreate)
 		router.PUT("/api/policy_conf", service.PolicyConfUpdateById)
+		router.PUT("/api/policy_conf/sftp", service.PolicySftpSet)
+		router.PUT("/api/policy_conf/target_tags", service.PolicyTargetTagsSet)
+		router.PUT("/api/policy_conf/prod", service.PolicyProdSet)
+		router.PUT("/api/policy_conf/algo", service.PolicyAlgoSet)
 		router.DELETE("/api/policy_conf/:id", service.PolicyConfDeleteById)
 	}
 
 	{ // 访问控制
-		router.GET("/api/net_filter", service.NetFilterFindAll)
-		router.GET("/api/net_filter/:id", service.NetFilterFindByID)
-		router.POST("/api/net_filter", service.NetFilterCreate)
-		router.PUT("/api/net_filter", service.NetFilterUpdateById)
-		router.DELETE("/api/net_filter/:id", service.NetFilterDeleteById)
+		platform.GET("/api/net_filter", service.NetFilterFindAll)
+		platform.GET("/api/net_filter/test", service.NetFilterTest)
+		platform.GET("/api/net_filter/:id", service.NetFilterFindByID)
+		platform.POST("/api/net_filter", service.NetFilterCreate)
+		platform.PUT("/api/net_filter", service.NetFilterUpdateById)
+		platform.DELETE("/api/net_filter/:id", service.NetFilterDeleteById)
+	}
+
+	{ // 地址组
+		platform.GET("/api/net_group", service.NetGroupFindAll)
+		platform.GET("/api/net_group/:id", service.NetGroupFindByID)
+		platform.POST("/api/net_group", service.NetGroupCreate)
+		platform.PUT("/api/net_group", service.NetGroupUpdateById)
+		platform.DELETE("/api/net_group/:id", service.NetGroupDeleteById)
+	}
+
+	{ // 敏感数据规则
+		platform.GET("/api/dlp_rule", service.DlpRuleFindAll)
+		platform.GET("/api/dlp_rule/:id", service.DlpRuleFindByID)
+		platform.POST("/api/dlp_rule", service.DlpRuleCreate)
+		platform.PUT("/api/dlp_rule", service.DlpRuleUpdateById)
+		platform.DELETE("/api/dlp_rule/:id", service.DlpRuleDeleteById)
+	}
+
+	{ // 敏感凭据检测
+		platform.GET("/api/secret_event", service.SecretEventFindAll)
+	}
+
+	{ // 计划维护
+		platform.GET("/api/maintenance", service.MaintenanceFindAll)
+		platform.GET("/api/maintenance/:id", service.MaintenanceFindByID)
+		platform.POST("/api/maintenance", service.MaintenanceCreate)
+		platform.PUT("/api/maintenance", service.MaintenanceUpdateById)
+		platform.DELETE("/api/maintenance/:id", service.MaintenanceDeleteById)
+	}
+
+	{ // 通知渠道
+		platform.GET("/api/notify_channel", service.NotifyChannelFindAll)
+		platform.GET("/api/notify_channel/:id", service.NotifyChannelFindByID)
+		platform.POST("/api/notify_channel", service.NotifyChannelCreate)
+		platform.PUT("/api/notify_channel", service.NotifyChannelUpdateById)
+		platform.DELETE("/api/notify_channel/:id", service.NotifyChannelDeleteById)
+		platform.POST("/api/notify_channel/test/:id", service.NotifyChannelTest)
+	}
+
+	{ // 连接审批
+		router.GET("/api/approval", service.ApprovalFindAll)
+		router.GET("/api/approval/:id", service.ApprovalFindByID)
+		router.PUT("/api/approval/approve", service.ApprovalApprove)
+		router.PUT("/api/approval/reject", service.ApprovalReject)
+	}
+
+	{ // 会话录像
+		router.GET("/api/session_record", service.SessionRecordFindAll)
+		router.GET("/api/session_record/:id", service.SessionRecordFindByID)
+		router.GET("/api/session_record/play/:id", service.SessionRecordPlay)
+		router.GET("/api/session_record/verify/:id", service.SessionRecordVerify)
+		router.POST("/api/session_record/redact", service.SessionRecordRedact)
+		router.GET("/api/session/:id/timeline", service.SessionTimeline)
+	}
+
+	{ // 变更评审
+		router.GET("/api/change_request", service.ChangeRequestFindAll)
+		router.GET("/api/change_request/:id", service.ChangeRequestFindByID)
+		router.PUT("/api/change_request/approve", service.ChangeRequestApprove)
+		router.PUT("/api/change_request/reject", service.ChangeRequestReject)
+	}
+
+	{ // 个人访问令牌
+		router.GET("/api/token", service.ApiTokenFindAll)
+		router.POST("/api/token", service.ApiTokenCreate)
+		router.DELETE("/api/token/:id", service.ApiTokenRevoke)
+	}
+
+	{ // 租户管理
+		router.GET("/api/tenant", service.TenantFindAll)
+		router.GET("/api/tenant/:id", service.TenantFindByID)
+		router.POST("/api/tenant", service.TenantCreate)
+		router.PUT("/api/tenant", service.TenantUpdateById)
+		router.DELETE("/api/tenant/:id", service.TenantDeleteById)
+	}
+
+	{ // 配额
+		router.GET("/api/quota", service.QuotaGet)
+		router.PUT("/api/quota", service.QuotaSet)
+		router.GET("/api/quota/usage", service.QuotaUsageGet)
+		router.GET("/api/quota/report", service.QuotaReport)
 	}
 
 	{ // 用户管理
@@ -94,12 +258,56 @@ func main() {
 		router.POST("/api/user", service.UserCreate)
 		router.PUT("/api/user", service.UserUpdateById)
 		router.DELETE("/api/user/:id", service.UserDeleteById)
+		router.GET("/api/jobs", service.JobFindAll)
+		router.GET("/api/jobs/:id", service.JobFindByID)
 		router.PATCH("/api/user/check_name_exists", service.CheckUserNameExists)
 		router.PATCH("/api/user/pwd", service.ModifyPasswd)
+		router.PATCH("/api/user/notify", service.UserNotifySet)
+		router.GET("/api/user/usage", service.UserUsage)
+		router.GET("/api/user_pref", service.UserPrefGet)
+		router.PUT("/api/user_pref", service.UserPrefSet)
+	}
+
+	{ // 回收站
+		router.GET("/api/trash", service.TrashFindAll)
+		router.POST("/api/trash/restore", service.TrashRestore)
+	}
+
+	{ // 云主机清单
+		platform.GET("/api/inventory", service.InventoryFindAll)
+		platform.GET("/api/inventory/:id", service.InventoryFindByID)
+		platform.POST("/api/inventory", service.InventoryCreate)
+		platform.PUT("/api/inventory", service.InventoryUpdateById)
+		platform.DELETE("/api/inventory/:id", service.InventoryDeleteById)
+		platform.POST("/api/inventory/sync/:id", service.InventorySync)
+	}
+
+	{ // 设备发现
+		platform.POST("/api/discovery/scan", service.DiscoveryScan)
+	}
+
+	{ // 凭据签出
+		router.GET("/api/cred_checkout", service.CredCheckoutFindAll)
+		router.POST("/api/cred_checkout", service.CredCheckoutCreate)
+		router.POST("/api/cred_checkout/return/:id", service.CredCheckoutReturn)
+	}
+
+	{ // 模拟登录
+		router.GET("/api/impersonate", service.ImpersonateLogFindAll)
+		router.POST("/api/impersonate", service.ImpersonateStart)
+		router.POST("/api/impersonate/end", service.ImpersonateEnd)
+	}
+
+	{ // 搜索
+		router.GET("/api/search", service.Search)
 	}
 
 	{ // 审计日志
 		router.POST("/api/login_audit", service.LoginAuditSearch)
+		router.GET("/api/report/access", service.ReportAccess)
+		platform.POST("/api/report/send", service.ReportSend)
+		platform.GET("/api/report/config", service.GetReportConf)
+		platform.PUT("/api/report/config", service.SetReportConf)
 	}
 
 	{ // SSH链接
@@ -110,25 +318,137 @@ func main() {
 		router.GET("/api/sftp/download", service.SftpDownLoad)
 		router.PUT("/api/sftp/upload", service.SftpUpload)
 		router.DELETE("/api/sftp/delete", service.SftpDelete)
+		router.GET("/api/sftp/stage", service.SftpStageFindAll)
+		router.POST("/api/sftp/stage", service.SftpStage)
+		router.POST("/api/sftp/distribute", service.SftpDistribute)
+		router.POST("/api/sftp/diff", service.SftpDiff)
 		router.GET("/api/ssh/conn", service.NewSshConn)
 		router.PATCH("/api/ssh/conn", service.ResizeWindow)
+		router.GET("/api/ssh/size", service.SshSize)
+		router.POST("/api/ssh/unlock", service.SshUnlock)
+		router.PATCH("/api/ssh/visibility", service.SetVisibility)
+		router.GET("/api/ssh/scrollback", service.SshScrollback)
+		router.GET("/api/ssh/sessions", service.SshSessions)
+		router.POST("/api/ssh/sessions/attach", service.SshSessionAttach)
+		router.GET("/api/ssh/algo", service.SshAlgoFind)
+		router.GET("/api/ssh/share", service.SessionShareFindAll)
+		router.POST("/api/ssh/share", service.SessionShareCreate)
+		router.DELETE("/api/ssh/share/:id", service.SessionShareRevoke)
+		router.GET("/api/ssh/watch", service.SshWatch)
+		router.POST("/api/ssh/terminate", service.SshTerminate)
+		router.GET("/api/ssh/tunnel", service.SshTunnel)
+		router.GET("/api/ssh/x11", service.SshX11)
 		router.POST("/api/ssh/exec", service.ExecCommand)
+		router.POST("/api/ssh/batch_exec", service.BatchExec)
 		router.POST("/api/ssh/disconnect", service.Disconnect)
 		router.POST("/api/ssh/create_session", service.CreateSessionId)
 	}
 
 	{ // 系统配置
 		router.GET("/api/sys/config", service.GetRunConf)
-		router.POST("/api/sys/config", service.SetRunConf)
+		platform.POST("/api/sys/config", service.SetRunConf)
+		platform.PUT("/api/sys/config/storage", service.SetStorageConf)
+		platform.PUT("/api/sys/config/tracing", service.SetTracingConf)
+		platform.PUT("/api/sys/config/log", service.SetLogConf)
+		platform.PUT("/api/sys/config/security", service.SetSecurityConf)
+		platform.PUT("/api/sys/config/session_clean", service.SetSessionCleanConf)
+		platform.PUT("/api/sys/config/error_report", service.SetErrorReportConf)
+		platform.PUT("/api/sys/config/secret", service.SetSecretConf)
+		platform.POST("/api/sys/secret/test", service.SecretTest)
+		platform.PUT("/api/sys/branding", service.BrandingSet)
+		router.GET("/api/sys/limits", service.GetSysLimits)
+		platform.PUT("/api/sys/limits", service.SetSysLimits)
+		platform.POST("/api/sys/export", service.SysExport)
+		platform.POST("/api/sys/import", service.SysImport)
+		platform.GET("/api/sys/backup", service.BackupFindAll)
+		platform.POST("/api/sys/backup", service.BackupCreate)
+		platform.POST("/api/sys/backup/restore", service.BackupRestore)
+		platform.GET("/api/sys/backup/config", service.GetBackupConf)
+		platform.PUT("/api/sys/backup/config", service.SetBackupConf)
+		platform.GET("/api/sys/audit/config", service.GetAuditConf)
+		platform.PUT("/api/sys/audit/config", service.SetAuditConf)
+		platform.POST("/api/sys/audit/run", service.AuditRetentionRun)
+		platform.GET("/api/sys/cluster", service.ClusterStatus)
+		platform.GET("/api/sys/metrics", service.SysMetrics)
+		platform.GET("/api/sys/errors", service.SysErrorFindAll)
+		platform.DELETE("/api/sys/errors", service.SysErrorClear)
+	}
+
+	// 处理前端静态文件,首页注入 CSP 随机数
+	webroot := StaticFile{embedFS: dir, path: "webroot"}
+	fileServer := http.StripPrefix("/app", http.FileServer(http.FS(webroot)))
+	serveWebroot := func(c *gin.Context) {
+		name := c.Param("filepath")
+		if name == "/" || name == "/index.html" {
+			data, err := fs.ReadFile(webroot, "index.html")
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			c.Header("Cache-Control", "no-cache")
+			c.Data(http.StatusOK, "text/html; charset=utf-8", middleware.InjectNonce(data, c.GetString(middleware.CspNonceKey)))
+			return
+		}
+		file, err := webroot.Open(name)
+		if err != nil {
+			c.Redirect(http.StatusMovedPermanently, "/app")
+			return
+		}
+		_ = file.Close()
+		fileServer.ServeHTTP(c.Writer, c.Request)
 	}
+	engine.GET("/app/*filepath", serveWebroot)
+	engine.HEAD("/app/*filepath", serveWebroot)
+
+	// 收到 SIGHUP 时重新读取配置文件,退出时释放主节点租约,备用节点立即接管后台任务
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		for s := range sig {
+			if s == syscall.SIGHUP {
+				if err := config.ReloadConfig(); err != nil {
+					slog.Error("reload config error:", "err_msg", err.Error())
+				}
+				continue
+			}
+			service.ReleaseLeader()
+			os.Exit(0)
+		}
+	}()
 
-	// 处理前端静态文件
-	engine.StaticFS("/app", http.FS(StaticFile{
-		embedFS: dir,
-		path:    "webroot",
-	}))
+	address := net.JoinHostPort(strings.Trim(config.DefaultConfig.Address, "[]"), config.DefaultConfig.Port)
+	// 租户专用的访问路径前缀
+	handler := middleware.TenantPrefix(engine)
 
-	address := fmt.Sprintf("%s:%s", config.DefaultConfig.Address, config.DefaultConfig.Port)
+	var socketMode uint64
+	if mode := config.DefaultConfig.SocketMode; mode != "" {
+		var err error
+		if socketMode, err = strconv.ParseUint(mode, 8, 32); err != nil {
+			slog.Error("socket_mode error:", "msg", err.Error())
+			os.Exit(1)
+		}
+	}
+	listener, err := utils.Listen(config.DefaultConfig.Network, address, config.DefaultConfig.Socket, os.FileMode(socketMode))
+	if err != nil {
+		slog.Error("ListenError:", "msg", err.Error())
+		os.Exit(1)
+	}
+	if _, ok := listener.(*net.UnixListener); ok {
+		handler = middleware.LocalRemoteAddr(handler)
+	}
+	slog.Info("server_listen", "addr", listener.Addr().String())
+
+	httpConf := config.DefaultConfig.Http
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: httpConf.ReadHeaderTimeout,
+		IdleTimeout:       httpConf.IdleTimeout,
+		MaxHeaderBytes:    httpConf.MaxHeaderBytes,
+	}
+	if !httpConf.Http2 {
+		// TLSNextProto 不为 nil 时不启用 HTTP/2
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
 
 	_, certErr := os.Open(config.DefaultConfig.CertFile)
 	_, keyErr := os.Open(config.DefaultConfig.KeyFile)
@@ -136,7 +456,7 @@ func main() {
 	// 如果证书和私钥文件存在,就使用https协议,否则使用http协议
 	if certErr == nil && keyErr == nil {
 		slog.Debug("https_server_start")
-		err := engine.RunTLS(address, config.DefaultConfig.CertFile, config.DefaultConfig.KeyFile)
+		err := server.ServeTLS(listener, config.DefaultConfig.CertFile, config.DefaultConfig.KeyFile)
 		if err != nil {
 			slog.Error("RunServeTLSError:", "msg", err.Error())
 			os.Exit(1)
@@ -144,7 +464,7 @@ func main() {
 		}
 	} else {
 		slog.Debug("http_server_start")
-		err := engine.Run(address)
+		err := server.Serve(listener)
 		if err != nil {
 			slog.Error("RunServeError:", "msg", err.Error())
 			os.Exit(1)
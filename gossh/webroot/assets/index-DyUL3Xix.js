@@ -81,5 +81,5 @@ WARNING: This link could potentially be dangerous`)){const v=window.open();if(v)
 `,u.VT="\v",u.FF="\f",u.CR="\r",u.SO="",u.SI="",u.DLE="",u.DC1="",u.DC2="",u.DC3="",u.DC4="",u.NAK="",u.SYN="",u.ETB="",u.CAN="",u.EM="",u.SUB="",u.ESC="\x1B",u.FS="",u.GS="",u.RS="",u.US="",u.SP=" ",u.DEL=""}(l||(i.C0=l={})),function(u){u.PAD="",u.HOP="",u.BPH="",u.NBH="",u.IND="",u.NEL="",u.SSA="",u.ESA="",u.HTS="",u.HTJ="",u.VTS="",u.PLD="",u.PLU="",u.RI="",u.SS2="",u.SS3="",u.DCS="",u.PU1="",u.PU2="",u.STS="",u.CCH="",u.MW="",u.SPA="",u.EPA="",u.SOS="",u.SGCI="",u.SCI="",u.CSI="",u.ST="",u.OSC="",u.PM="",u.APC=""}(d||(i.C1=d={})),function(u){u.ST=`${l.ESC}\\`}(f||(i.C1_ESCAPED=f={}))},7399:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.evaluateKeyboardEvent=void 0;const d=l(2584),f={48:["0",")"],49:["1","!"],50:["2","@"],51:["3","#"],52:["4","$"],53:["5","%"],54:["6","^"],55:["7","&"],56:["8","*"],57:["9","("],186:[";",":"],187:["=","+"],188:[",","<"],189:["-","_"],190:[".",">"],191:["/","?"],192:["`","~"],219:["[","{"],220:["\\","|"],221:["]","}"],222:["'",'"']};i.evaluateKeyboardEvent=function(u,p,_,b){const h={type:0,cancel:!1,key:void 0},g=(u.shiftKey?1:0)|(u.altKey?2:0)|(u.ctrlKey?4:0)|(u.metaKey?8:0);switch(u.keyCode){case 0:u.key==="UIKeyInputUpArrow"?h.key=p?d.C0.ESC+"OA":d.C0.ESC+"[A":u.key==="UIKeyInputLeftArrow"?h.key=p?d.C0.ESC+"OD":d.C0.ESC+"[D":u.key==="UIKeyInputRightArrow"?h.key=p?d.C0.ESC+"OC":d.C0.ESC+"[C":u.key==="UIKeyInputDownArrow"&&(h.key=p?d.C0.ESC+"OB":d.C0.ESC+"[B");break;case 8:h.key=u.ctrlKey?"\b":d.C0.DEL,u.altKey&&(h.key=d.C0.ESC+h.key);break;case 9:if(u.shiftKey){h.key=d.C0.ESC+"[Z";break}h.key=d.C0.HT,h.cancel=!0;break;case 13:h.key=u.altKey?d.C0.ESC+d.C0.CR:d.C0.CR,h.cancel=!0;break;case 27:h.key=d.C0.ESC,u.altKey&&(h.key=d.C0.ESC+d.C0.ESC),h.cancel=!0;break;case 37:if(u.metaKey)break;g?(h.key=d.C0.ESC+"[1;"+(g+1)+"D",h.key===d.C0.ESC+"[1;3D"&&(h.key=d.C0.ESC+(_?"b":"[1;5D"))):h.key=p?d.C0.ESC+"OD":d.C0.ESC+"[D";break;case 39:if(u.metaKey)break;g?(h.key=d.C0.ESC+"[1;"+(g+1)+"C",h.key===d.C0.ESC+"[1;3C"&&(h.key=d.C0.ESC+(_?"f":"[1;5C"))):h.key=p?d.C0.ESC+"OC":d.C0.ESC+"[C";break;case 38:if(u.metaKey)break;g?(h.key=d.C0.ESC+"[1;"+(g+1)+"A",_||h.key!==d.C0.ESC+"[1;3A"||(h.key=d.C0.ESC+"[1;5A")):h.key=p?d.C0.ESC+"OA":d.C0.ESC+"[A";break;case 40:if(u.metaKey)break;g?(h.key=d.C0.ESC+"[1;"+(g+1)+"B",_||h.key!==d.C0.ESC+"[1;3B"||(h.key=d.C0.ESC+"[1;5B")):h.key=p?d.C0.ESC+"OB":d.C0.ESC+"[B";break;case 45:u.shiftKey||u.ctrlKey||(h.key=d.C0.ESC+"[2~");break;case 46:h.key=g?d.C0.ESC+"[3;"+(g+1)+"~":d.C0.ESC+"[3~";break;case 36:h.key=g?d.C0.ESC+"[1;"+(g+1)+"H":p?d.C0.ESC+"OH":d.C0.ESC+"[H";break;case 35:h.key=g?d.C0.ESC+"[1;"+(g+1)+"F":p?d.C0.ESC+"OF":d.C0.ESC+"[F";break;case 33:u.shiftKey?h.type=2:u.ctrlKey?h.key=d.C0.ESC+"[5;"+(g+1)+"~":h.key=d.C0.ESC+"[5~";break;case 34:u.shiftKey?h.type=3:u.ctrlKey?h.key=d.C0.ESC+"[6;"+(g+1)+"~":h.key=d.C0.ESC+"[6~";break;case 112:h.key=g?d.C0.ESC+"[1;"+(g+1)+"P":d.C0.ESC+"OP";break;case 113:h.key=g?d.C0.ESC+"[1;"+(g+1)+"Q":d.C0.ESC+"OQ";break;case 114:h.key=g?d.C0.ESC+"[1;"+(g+1)+"R":d.C0.ESC+"OR";break;case 115:h.key=g?d.C0.ESC+"[1;"+(g+1)+"S":d.C0.ESC+"OS";break;case 116:h.key=g?d.C0.ESC+"[15;"+(g+1)+"~":d.C0.ESC+"[15~";break;case 117:h.key=g?d.C0.ESC+"[17;"+(g+1)+"~":d.C0.ESC+"[17~";break;case 118:h.key=g?d.C0.ESC+"[18;"+(g+1)+"~":d.C0.ESC+"[18~";break;case 119:h.key=g?d.C0.ESC+"[19;"+(g+1)+"~":d.C0.ESC+"[19~";break;case 120:h.key=g?d.C0.ESC+"[20;"+(g+1)+"~":d.C0.ESC+"[20~";break;case 121:h.key=g?d.C0.ESC+"[21;"+(g+1)+"~":d.C0.ESC+"[21~";break;case 122:h.key=g?d.C0.ESC+"[23;"+(g+1)+"~":d.C0.ESC+"[23~";break;case 123:h.key=g?d.C0.ESC+"[24;"+(g+1)+"~":d.C0.ESC+"[24~";break;default:if(!u.ctrlKey||u.shiftKey||u.altKey||u.metaKey)if(_&&!b||!u.altKey||u.metaKey)!_||u.altKey||u.ctrlKey||u.shiftKey||!u.metaKey?u.key&&!u.ctrlKey&&!u.altKey&&!u.metaKey&&u.keyCode>=48&&u.key.length===1?h.key=u.key:u.key&&u.ctrlKey&&(u.key==="_"&&(h.key=d.C0.US),u.key==="@"&&(h.key=d.C0.NUL)):u.keyCode===65&&(h.type=1);else{const v=f[u.keyCode],m=v==null?void 0:v[u.shiftKey?1:0];if(m)h.key=d.C0.ESC+m;else if(u.keyCode>=65&&u.keyCode<=90){const y=u.ctrlKey?u.keyCode-64:u.keyCode+32;let w=String.fromCharCode(y);u.shiftKey&&(w=w.toUpperCase()),h.key=d.C0.ESC+w}else if(u.keyCode===32)h.key=d.C0.ESC+(u.ctrlKey?d.C0.NUL:" ");else if(u.key==="Dead"&&u.code.startsWith("Key")){let y=u.code.slice(3,4);u.shiftKey||(y=y.toLowerCase()),h.key=d.C0.ESC+y,h.cancel=!0}}else u.keyCode>=65&&u.keyCode<=90?h.key=String.fromCharCode(u.keyCode-64):u.keyCode===32?h.key=d.C0.NUL:u.keyCode>=51&&u.keyCode<=55?h.key=String.fromCharCode(u.keyCode-51+27):u.keyCode===56?h.key=d.C0.DEL:u.keyCode===219?h.key=d.C0.ESC:u.keyCode===220?h.key=d.C0.FS:u.keyCode===221&&(h.key=d.C0.GS)}return h}},482:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.Utf8ToUtf32=i.StringToUtf32=i.utf32ToString=i.stringFromCodePoint=void 0,i.stringFromCodePoint=function(l){return l>65535?(l-=65536,String.fromCharCode(55296+(l>>10))+String.fromCharCode(l%1024+56320)):String.fromCharCode(l)},i.utf32ToString=function(l,d=0,f=l.length){let u="";for(let p=d;p<f;++p){let _=l[p];_>65535?(_-=65536,u+=String.fromCharCode(55296+(_>>10))+String.fromCharCode(_%1024+56320)):u+=String.fromCharCode(_)}return u},i.StringToUtf32=class{constructor(){this._interim=0}clear(){this._interim=0}decode(l,d){const f=l.length;if(!f)return 0;let u=0,p=0;if(this._interim){const _=l.charCodeAt(p++);56320<=_&&_<=57343?d[u++]=1024*(this._interim-55296)+_-56320+65536:(d[u++]=this._interim,d[u++]=_),this._interim=0}for(let _=p;_<f;++_){const b=l.charCodeAt(_);if(55296<=b&&b<=56319){if(++_>=f)return this._interim=b,u;const h=l.charCodeAt(_);56320<=h&&h<=57343?d[u++]=1024*(b-55296)+h-56320+65536:(d[u++]=b,d[u++]=h)}else b!==65279&&(d[u++]=b)}return u}},i.Utf8ToUtf32=class{constructor(){this.interim=new Uint8Array(3)}clear(){this.interim.fill(0)}decode(l,d){const f=l.length;if(!f)return 0;let u,p,_,b,h=0,g=0,v=0;if(this.interim[0]){let w=!1,S=this.interim[0];S&=(224&S)==192?31:(240&S)==224?15:7;let C,k=0;for(;(C=63&this.interim[++k])&&k<4;)S<<=6,S|=C;const E=(224&this.interim[0])==192?2:(240&this.interim[0])==224?3:4,R=E-k;for(;v<R;){if(v>=f)return 0;if(C=l[v++],(192&C)!=128){v--,w=!0;break}this.interim[k++]=C,S<<=6,S|=63&C}w||(E===2?S<128?v--:d[h++]=S:E===3?S<2048||S>=55296&&S<=57343||S===65279||(d[h++]=S):S<65536||S>1114111||(d[h++]=S)),this.interim.fill(0)}const m=f-4;let y=v;for(;y<f;){for(;!(!(y<m)||128&(u=l[y])||128&(p=l[y+1])||128&(_=l[y+2])||128&(b=l[y+3]));)d[h++]=u,d[h++]=p,d[h++]=_,d[h++]=b,y+=4;if(u=l[y++],u<128)d[h++]=u;else if((224&u)==192){if(y>=f)return this.interim[0]=u,h;if(p=l[y++],(192&p)!=128){y--;continue}if(g=(31&u)<<6|63&p,g<128){y--;continue}d[h++]=g}else if((240&u)==224){if(y>=f)return this.interim[0]=u,h;if(p=l[y++],(192&p)!=128){y--;continue}if(y>=f)return this.interim[0]=u,this.interim[1]=p,h;if(_=l[y++],(192&_)!=128){y--;continue}if(g=(15&u)<<12|(63&p)<<6|63&_,g<2048||g>=55296&&g<=57343||g===65279)continue;d[h++]=g}else if((248&u)==240){if(y>=f)return this.interim[0]=u,h;if(p=l[y++],(192&p)!=128){y--;continue}if(y>=f)return this.interim[0]=u,this.interim[1]=p,h;if(_=l[y++],(192&_)!=128){y--;continue}if(y>=f)return this.interim[0]=u,this.interim[1]=p,this.interim[2]=_,h;if(b=l[y++],(192&b)!=128){y--;continue}if(g=(7&u)<<18|(63&p)<<12|(63&_)<<6|63&b,g<65536||g>1114111)continue;d[h++]=g}}return h}}},225:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.UnicodeV6=void 0;const d=l(1480),f=[[768,879],[1155,1158],[1160,1161],[1425,1469],[1471,1471],[1473,1474],[1476,1477],[1479,1479],[1536,1539],[1552,1557],[1611,1630],[1648,1648],[1750,1764],[1767,1768],[1770,1773],[1807,1807],[1809,1809],[1840,1866],[1958,1968],[2027,2035],[2305,2306],[2364,2364],[2369,2376],[2381,2381],[2385,2388],[2402,2403],[2433,2433],[2492,2492],[2497,2500],[2509,2509],[2530,2531],[2561,2562],[2620,2620],[2625,2626],[2631,2632],[2635,2637],[2672,2673],[2689,2690],[2748,2748],[2753,2757],[2759,2760],[2765,2765],[2786,2787],[2817,2817],[2876,2876],[2879,2879],[2881,2883],[2893,2893],[2902,2902],[2946,2946],[3008,3008],[3021,3021],[3134,3136],[3142,3144],[3146,3149],[3157,3158],[3260,3260],[3263,3263],[3270,3270],[3276,3277],[3298,3299],[3393,3395],[3405,3405],[3530,3530],[3538,3540],[3542,3542],[3633,3633],[3636,3642],[3655,3662],[3761,3761],[3764,3769],[3771,3772],[3784,3789],[3864,3865],[3893,3893],[3895,3895],[3897,3897],[3953,3966],[3968,3972],[3974,3975],[3984,3991],[3993,4028],[4038,4038],[4141,4144],[4146,4146],[4150,4151],[4153,4153],[4184,4185],[4448,4607],[4959,4959],[5906,5908],[5938,5940],[5970,5971],[6002,6003],[6068,6069],[6071,6077],[6086,6086],[6089,6099],[6109,6109],[6155,6157],[6313,6313],[6432,6434],[6439,6440],[6450,6450],[6457,6459],[6679,6680],[6912,6915],[6964,6964],[6966,6970],[6972,6972],[6978,6978],[7019,7027],[7616,7626],[7678,7679],[8203,8207],[8234,8238],[8288,8291],[8298,8303],[8400,8431],[12330,12335],[12441,12442],[43014,43014],[43019,43019],[43045,43046],[64286,64286],[65024,65039],[65056,65059],[65279,65279],[65529,65531]],u=[[68097,68099],[68101,68102],[68108,68111],[68152,68154],[68159,68159],[119143,119145],[119155,119170],[119173,119179],[119210,119213],[119362,119364],[917505,917505],[917536,917631],[917760,917999]];let p;i.UnicodeV6=class{constructor(){if(this.version="6",!p){p=new Uint8Array(65536),p.fill(1),p[0]=0,p.fill(0,1,32),p.fill(0,127,160),p.fill(2,4352,4448),p[9001]=2,p[9002]=2,p.fill(2,11904,42192),p[12351]=1,p.fill(2,44032,55204),p.fill(2,63744,64256),p.fill(2,65040,65050),p.fill(2,65072,65136),p.fill(2,65280,65377),p.fill(2,65504,65511);for(let _=0;_<f.length;++_)p.fill(0,f[_][0],f[_][1]+1)}}wcwidth(_){return _<32?0:_<127?1:_<65536?p[_]:function(b,h){let g,v=0,m=h.length-1;if(b<h[0][0]||b>h[m][1])return!1;for(;m>=v;)if(g=v+m>>1,b>h[g][1])v=g+1;else{if(!(b<h[g][0]))return!0;m=g-1}return!1}(_,u)?0:_>=131072&&_<=196605||_>=196608&&_<=262141?2:1}charProperties(_,b){let h=this.wcwidth(_),g=h===0&&b!==0;if(g){const v=d.UnicodeService.extractWidth(b);v===0?g=!1:v>h&&(h=v)}return d.UnicodeService.createPropertyValue(0,h,g)}}},5981:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.WriteBuffer=void 0;const d=l(8460),f=l(844);class u extends f.Disposable{constructor(_){super(),this._action=_,this._writeBuffer=[],this._callbacks=[],this._pendingData=0,this._bufferOffset=0,this._isSyncWriting=!1,this._syncCalls=0,this._didUserInput=!1,this._onWriteParsed=this.register(new d.EventEmitter),this.onWriteParsed=this._onWriteParsed.event}handleUserInput(){this._didUserInput=!0}writeSync(_,b){if(b!==void 0&&this._syncCalls>b)return void(this._syncCalls=0);if(this._pendingData+=_.length,this._writeBuffer.push(_),this._callbacks.push(void 0),this._syncCalls++,this._isSyncWriting)return;let h;for(this._isSyncWriting=!0;h=this._writeBuffer.shift();){this._action(h);const g=this._callbacks.shift();g&&g()}this._pendingData=0,this._bufferOffset=2147483647,this._isSyncWriting=!1,this._syncCalls=0}write(_,b){if(this._pendingData>5e7)throw new Error("write data discarded, use flow control to avoid losing data");if(!this._writeBuffer.length){if(this._bufferOffset=0,this._didUserInput)return this._didUserInput=!1,this._pendingData+=_.length,this._writeBuffer.push(_),this._callbacks.push(b),void this._innerWrite();setTimeout(()=>this._innerWrite())}this._pendingData+=_.length,this._writeBuffer.push(_),this._callbacks.push(b)}_innerWrite(_=0,b=!0){const h=_||Date.now();for(;this._writeBuffer.length>this._bufferOffset;){const g=this._writeBuffer[this._bufferOffset],v=this._action(g,b);if(v){const y=w=>Date.now()-h>=12?setTimeout(()=>this._innerWrite(0,w)):this._innerWrite(h,w);return void v.catch(w=>(queueMicrotask(()=>{throw w}),Promise.resolve(!1))).then(y)}const m=this._callbacks[this._bufferOffset];if(m&&m(),this._bufferOffset++,this._pendingData-=g.length,Date.now()-h>=12)break}this._writeBuffer.length>this._bufferOffset?(this._bufferOffset>50&&(this._writeBuffer=this._writeBuffer.slice(this._bufferOffset),this._callbacks=this._callbacks.slice(this._bufferOffset),this._bufferOffset=0),setTimeout(()=>this._innerWrite())):(this._writeBuffer.length=0,this._callbacks.length=0,this._pendingData=0,this._bufferOffset=0),this._onWriteParsed.fire()}}i.WriteBuffer=u},5941:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.toRgbString=i.parseColor=void 0;const l=/^([\da-f])\/([\da-f])\/([\da-f])$|^([\da-f]{2})\/([\da-f]{2})\/([\da-f]{2})$|^([\da-f]{3})\/([\da-f]{3})\/([\da-f]{3})$|^([\da-f]{4})\/([\da-f]{4})\/([\da-f]{4})$/,d=/^[\da-f]+$/;function f(u,p){const _=u.toString(16),b=_.length<2?"0"+_:_;switch(p){case 4:return _[0];case 8:return b;case 12:return(b+b).slice(0,3);default:return b+b}}i.parseColor=function(u){if(!u)return;let p=u.toLowerCase();if(p.indexOf("rgb:")===0){p=p.slice(4);const _=l.exec(p);if(_){const b=_[1]?15:_[4]?255:_[7]?4095:65535;return[Math.round(parseInt(_[1]||_[4]||_[7]||_[10],16)/b*255),Math.round(parseInt(_[2]||_[5]||_[8]||_[11],16)/b*255),Math.round(parseInt(_[3]||_[6]||_[9]||_[12],16)/b*255)]}}else if(p.indexOf("#")===0&&(p=p.slice(1),d.exec(p)&&[3,6,9,12].includes(p.length))){const _=p.length/3,b=[0,0,0];for(let h=0;h<3;++h){const g=parseInt(p.slice(_*h,_*h+_),16);b[h]=_===1?g<<4:_===2?g:_===3?g>>4:g>>8}return b}},i.toRgbString=function(u,p=16){const[_,b,h]=u;return`rgb:${f(_,p)}/${f(b,p)}/${f(h,p)}`}},5770:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.PAYLOAD_LIMIT=void 0,i.PAYLOAD_LIMIT=1e7},6351:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.DcsHandler=i.DcsParser=void 0;const d=l(482),f=l(8742),u=l(5770),p=[];i.DcsParser=class{constructor(){this._handlers=Object.create(null),this._active=p,this._ident=0,this._handlerFb=()=>{},this._stack={paused:!1,loopPosition:0,fallThrough:!1}}dispose(){this._handlers=Object.create(null),this._handlerFb=()=>{},this._active=p}registerHandler(b,h){this._handlers[b]===void 0&&(this._handlers[b]=[]);const g=this._handlers[b];return g.push(h),{dispose:()=>{const v=g.indexOf(h);v!==-1&&g.splice(v,1)}}}clearHandler(b){this._handlers[b]&&delete this._handlers[b]}setHandlerFallback(b){this._handlerFb=b}reset(){if(this._active.length)for(let b=this._stack.paused?this._stack.loopPosition-1:this._active.length-1;b>=0;--b)this._active[b].unhook(!1);this._stack.paused=!1,this._active=p,this._ident=0}hook(b,h){if(this.reset(),this._ident=b,this._active=this._handlers[b]||p,this._active.length)for(let g=this._active.length-1;g>=0;g--)this._active[g].hook(h);else this._handlerFb(this._ident,"HOOK",h)}put(b,h,g){if(this._active.length)for(let v=this._active.length-1;v>=0;v--)this._active[v].put(b,h,g);else this._handlerFb(this._ident,"PUT",(0,d.utf32ToString)(b,h,g))}unhook(b,h=!0){if(this._active.length){let g=!1,v=this._active.length-1,m=!1;if(this._stack.paused&&(v=this._stack.loopPosition-1,g=h,m=this._stack.fallThrough,this._stack.paused=!1),!m&&g===!1){for(;v>=0&&(g=this._active[v].unhook(b),g!==!0);v--)if(g instanceof Promise)return this._stack.paused=!0,this._stack.loopPosition=v,this._stack.fallThrough=!1,g;v--}for(;v>=0;v--)if(g=this._active[v].unhook(!1),g instanceof Promise)return this._stack.paused=!0,this._stack.loopPosition=v,this._stack.fallThrough=!0,g}else this._handlerFb(this._ident,"UNHOOK",b);this._active=p,this._ident=0}};const _=new f.Params;_.addParam(0),i.DcsHandler=class{constructor(b){this._handler=b,this._data="",this._params=_,this._hitLimit=!1}hook(b){this._params=b.length>1||b.params[0]?b.clone():_,this._data="",this._hitLimit=!1}put(b,h,g){this._hitLimit||(this._data+=(0,d.utf32ToString)(b,h,g),this._data.length>u.PAYLOAD_LIMIT&&(this._data="",this._hitLimit=!0))}unhook(b){let h=!1;if(this._hitLimit)h=!1;else if(b&&(h=this._handler(this._data,this._params),h instanceof Promise))return h.then(g=>(this._params=_,this._data="",this._hitLimit=!1,g));return this._params=_,this._data="",this._hitLimit=!1,h}}},2015:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.EscapeSequenceParser=i.VT500_TRANSITION_TABLE=i.TransitionTable=void 0;const d=l(844),f=l(8742),u=l(6242),p=l(6351);class _{constructor(v){this.table=new Uint8Array(v)}setDefault(v,m){this.table.fill(v<<4|m)}add(v,m,y,w){this.table[m<<8|v]=y<<4|w}addMany(v,m,y,w){for(let S=0;S<v.length;S++)this.table[m<<8|v[S]]=y<<4|w}}i.TransitionTable=_;const b=160;i.VT500_TRANSITION_TABLE=function(){const g=new _(4095),v=Array.apply(null,Array(256)).map((k,E)=>E),m=(k,E)=>v.slice(k,E),y=m(32,127),w=m(0,24);w.push(25),w.push.apply(w,m(28,32));const S=m(0,14);let C;for(C in g.setDefault(1,0),g.addMany(y,0,2,0),S)g.addMany([24,26,153,154],C,3,0),g.addMany(m(128,144),C,3,0),g.addMany(m(144,152),C,3,0),g.add(156,C,0,0),g.add(27,C,11,1),g.add(157,C,4,8),g.addMany([152,158,159],C,0,7),g.add(155,C,11,3),g.add(144,C,11,9);return g.addMany(w,0,3,0),g.addMany(w,1,3,1),g.add(127,1,0,1),g.addMany(w,8,0,8),g.addMany(w,3,3,3),g.add(127,3,0,3),g.addMany(w,4,3,4),g.add(127,4,0,4),g.addMany(w,6,3,6),g.addMany(w,5,3,5),g.add(127,5,0,5),g.addMany(w,2,3,2),g.add(127,2,0,2),g.add(93,1,4,8),g.addMany(y,8,5,8),g.add(127,8,5,8),g.addMany([156,27,24,26,7],8,6,0),g.addMany(m(28,32),8,0,8),g.addMany([88,94,95],1,0,7),g.addMany(y,7,0,7),g.addMany(w,7,0,7),g.add(156,7,0,0),g.add(127,7,0,7),g.add(91,1,11,3),g.addMany(m(64,127),3,7,0),g.addMany(m(48,60),3,8,4),g.addMany([60,61,62,63],3,9,4),g.addMany(m(48,60),4,8,4),g.addMany(m(64,127),4,7,0),g.addMany([60,61,62,63],4,0,6),g.addMany(m(32,64),6,0,6),g.add(127,6,0,6),g.addMany(m(64,127),6,0,0),g.addMany(m(32,48),3,9,5),g.addMany(m(32,48),5,9,5),g.addMany(m(48,64),5,0,6),g.addMany(m(64,127),5,7,0),g.addMany(m(32,48),4,9,5),g.addMany(m(32,48),1,9,2),g.addMany(m(32,48),2,9,2),g.addMany(m(48,127),2,10,0),g.addMany(m(48,80),1,10,0),g.addMany(m(81,88),1,10,0),g.addMany([89,90,92],1,10,0),g.addMany(m(96,127),1,10,0),g.add(80,1,11,9),g.addMany(w,9,0,9),g.add(127,9,0,9),g.addMany(m(28,32),9,0,9),g.addMany(m(32,48),9,9,12),g.addMany(m(48,60),9,8,10),g.addMany([60,61,62,63],9,9,10),g.addMany(w,11,0,11),g.addMany(m(32,128),11,0,11),g.addMany(m(28,32),11,0,11),g.addMany(w,10,0,10),g.add(127,10,0,10),g.addMany(m(28,32),10,0,10),g.addMany(m(48,60),10,8,10),g.addMany([60,61,62,63],10,0,11),g.addMany(m(32,48),10,9,12),g.addMany(w,12,0,12),g.add(127,12,0,12),g.addMany(m(28,32),12,0,12),g.addMany(m(32,48),12,9,12),g.addMany(m(48,64),12,0,11),g.addMany(m(64,127),12,12,13),g.addMany(m(64,127),10,12,13),g.addMany(m(64,127),9,12,13),g.addMany(w,13,13,13),g.addMany(y,13,13,13),g.add(127,13,0,13),g.addMany([27,156,24,26],13,14,0),g.add(b,0,2,0),g.add(b,8,5,8),g.add(b,6,0,6),g.add(b,11,0,11),g.add(b,13,13,13),g}();class h extends d.Disposable{constructor(v=i.VT500_TRANSITION_TABLE){super(),this._transitions=v,this._parseStack={state:0,handlers:[],handlerPos:0,transition:0,chunkPos:0},this.initialState=0,this.currentState=this.initialState,this._params=new f.Params,this._params.addParam(0),this._collect=0,this.precedingJoinState=0,this._printHandlerFb=(m,y,w)=>{},this._executeHandlerFb=m=>{},this._csiHandlerFb=(m,y)=>{},this._escHandlerFb=m=>{},this._errorHandlerFb=m=>m,this._printHandler=this._printHandlerFb,this._executeHandlers=Object.create(null),this._csiHandlers=Object.create(null),this._escHandlers=Object.create(null),this.register((0,d.toDisposable)(()=>{this._csiHandlers=Object.create(null),this._executeHandlers=Object.create(null),this._escHandlers=Object.create(null)})),this._oscParser=this.register(new u.OscParser),this._dcsParser=this.register(new p.DcsParser),this._errorHandler=this._errorHandlerFb,this.registerEscHandler({final:"\\"},()=>!0)}_identifier(v,m=[64,126]){let y=0;if(v.prefix){if(v.prefix.length>1)throw new Error("only one byte as prefix supported");if(y=v.prefix.charCodeAt(0),y&&60>y||y>63)throw new Error("prefix must be in range 0x3c .. 0x3f")}if(v.intermediates){if(v.intermediates.length>2)throw new Error("only two bytes as intermediates are supported");for(let S=0;S<v.intermediates.length;++S){const C=v.intermediates.charCodeAt(S);if(32>C||C>47)throw new Error("intermediate must be in range 0x20 .. 0x2f");y<<=8,y|=C}}if(v.final.length!==1)throw new Error("final must be a single byte");const w=v.final.charCodeAt(0);if(m[0]>w||w>m[1])throw new Error(`final must be in range ${m[0]} .. ${m[1]}`);return y<<=8,y|=w,y}identToString(v){const m=[];for(;v;)m.push(String.fromCharCode(255&v)),v>>=8;return m.reverse().join("")}setPrintHandler(v){this._printHandler=v}clearPrintHandler(){this._printHandler=this._printHandlerFb}registerEscHandler(v,m){const y=this._identifier(v,[48,126]);this._escHandlers[y]===void 0&&(this._escHandlers[y]=[]);const w=this._escHandlers[y];return w.push(m),{dispose:()=>{const S=w.indexOf(m);S!==-1&&w.splice(S,1)}}}clearEscHandler(v){this._escHandlers[this._identifier(v,[48,126])]&&delete this._escHandlers[this._identifier(v,[48,126])]}setEscHandlerFallback(v){this._escHandlerFb=v}setExecuteHandler(v,m){this._executeHandlers[v.charCodeAt(0)]=m}clearExecuteHandler(v){this._executeHandlers[v.charCodeAt(0)]&&delete this._executeHandlers[v.charCodeAt(0)]}setExecuteHandlerFallback(v){this._executeHandlerFb=v}registerCsiHandler(v,m){const y=this._identifier(v);this._csiHandlers[y]===void 0&&(this._csiHandlers[y]=[]);const w=this._csiHandlers[y];return w.push(m),{dispose:()=>{const S=w.indexOf(m);S!==-1&&w.splice(S,1)}}}clearCsiHandler(v){this._csiHandlers[this._identifier(v)]&&delete this._csiHandlers[this._identifier(v)]}setCsiHandlerFallback(v){this._csiHandlerFb=v}registerDcsHandler(v,m){return this._dcsParser.registerHandler(this._identifier(v),m)}clearDcsHandler(v){this._dcsParser.clearHandler(this._identifier(v))}setDcsHandlerFallback(v){this._dcsParser.setHandlerFallback(v)}registerOscHandler(v,m){return this._oscParser.registerHandler(v,m)}clearOscHandler(v){this._oscParser.clearHandler(v)}setOscHandlerFallback(v){this._oscParser.setHandlerFallback(v)}setErrorHandler(v){this._errorHandler=v}clearErrorHandler(){this._errorHandler=this._errorHandlerFb}reset(){this.currentState=this.initialState,this._oscParser.reset(),this._dcsParser.reset(),this._params.reset(),this._params.addParam(0),this._collect=0,this.precedingJoinState=0,this._parseStack.state!==0&&(this._parseStack.state=2,this._parseStack.handlers=[])}_preserveStack(v,m,y,w,S){this._parseStack.state=v,this._parseStack.handlers=m,this._parseStack.handlerPos=y,this._parseStack.transition=w,this._parseStack.chunkPos=S}parse(v,m,y){let w,S=0,C=0,k=0;if(this._parseStack.state)if(this._parseStack.state===2)this._parseStack.state=0,k=this._parseStack.chunkPos+1;else{if(y===void 0||this._parseStack.state===1)throw this._parseStack.state=1,new Error("improper continuation due to previous async handler, giving up parsing");const E=this._parseStack.handlers;let R=this._parseStack.handlerPos-1;switch(this._parseStack.state){case 3:if(y===!1&&R>-1){for(;R>=0&&(w=E[R](this._params),w!==!0);R--)if(w instanceof Promise)return this._parseStack.handlerPos=R,w}this._parseStack.handlers=[];break;case 4:if(y===!1&&R>-1){for(;R>=0&&(w=E[R](),w!==!0);R--)if(w instanceof Promise)return this._parseStack.handlerPos=R,w}this._parseStack.handlers=[];break;case 6:if(S=v[this._parseStack.chunkPos],w=this._dcsParser.unhook(S!==24&&S!==26,y),w)return w;S===27&&(this._parseStack.transition|=1),this._params.reset(),this._params.addParam(0),this._collect=0;break;case 5:if(S=v[this._parseStack.chunkPos],w=this._oscParser.end(S!==24&&S!==26,y),w)return w;S===27&&(this._parseStack.transition|=1),this._params.reset(),this._params.addParam(0),this._collect=0}this._parseStack.state=0,k=this._parseStack.chunkPos+1,this.precedingJoinState=0,this.currentState=15&this._parseStack.transition}for(let E=k;E<m;++E){switch(S=v[E],C=this._transitions.table[this.currentState<<8|(S<160?S:b)],C>>4){case 2:for(let D=E+1;;++D){if(D>=m||(S=v[D])<32||S>126&&S<b){this._printHandler(v,E,D),E=D-1;break}if(++D>=m||(S=v[D])<32||S>126&&S<b){this._printHandler(v,E,D),E=D-1;break}if(++D>=m||(S=v[D])<32||S>126&&S<b){this._printHandler(v,E,D),E=D-1;break}if(++D>=m||(S=v[D])<32||S>126&&S<b){this._printHandler(v,E,D),E=D-1;break}}break;case 3:this._executeHandlers[S]?this._executeHandlers[S]():this._executeHandlerFb(S),this.precedingJoinState=0;break;case 0:break;case 1:if(this._errorHandler({position:E,code:S,currentState:this.currentState,collect:this._collect,params:this._params,abort:!1}).abort)return;break;case 7:const R=this._csiHandlers[this._collect<<8|S];let $=R?R.length-1:-1;for(;$>=0&&(w=R[$](this._params),w!==!0);$--)if(w instanceof Promise)return this._preserveStack(3,R,$,C,E),w;$<0&&this._csiHandlerFb(this._collect<<8|S,this._params),this.precedingJoinState=0;break;case 8:do switch(S){case 59:this._params.addParam(0);break;case 58:this._params.addSubParam(-1);break;default:this._params.addDigit(S-48)}while(++E<m&&(S=v[E])>47&&S<60);E--;break;case 9:this._collect<<=8,this._collect|=S;break;case 10:const x=this._escHandlers[this._collect<<8|S];let L=x?x.length-1:-1;for(;L>=0&&(w=x[L](),w!==!0);L--)if(w instanceof Promise)return this._preserveStack(4,x,L,C,E),w;L<0&&this._escHandlerFb(this._collect<<8|S),this.precedingJoinState=0;break;case 11:this._params.reset(),this._params.addParam(0),this._collect=0;break;case 12:this._dcsParser.hook(this._collect<<8|S,this._params);break;case 13:for(let D=E+1;;++D)if(D>=m||(S=v[D])===24||S===26||S===27||S>127&&S<b){this._dcsParser.put(v,E,D),E=D-1;break}break;case 14:if(w=this._dcsParser.unhook(S!==24&&S!==26),w)return this._preserveStack(6,[],0,C,E),w;S===27&&(C|=1),this._params.reset(),this._params.addParam(0),this._collect=0,this.precedingJoinState=0;break;case 4:this._oscParser.start();break;case 5:for(let D=E+1;;D++)if(D>=m||(S=v[D])<32||S>127&&S<b){this._oscParser.put(v,E,D),E=D-1;break}break;case 6:if(w=this._oscParser.end(S!==24&&S!==26),w)return this._preserveStack(5,[],0,C,E),w;S===27&&(C|=1),this._params.reset(),this._params.addParam(0),this._collect=0,this.precedingJoinState=0}this.currentState=15&C}}}i.EscapeSequenceParser=h},6242:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.OscHandler=i.OscParser=void 0;const d=l(5770),f=l(482),u=[];i.OscParser=class{constructor(){this._state=0,this._active=u,this._id=-1,this._handlers=Object.create(null),this._handlerFb=()=>{},this._stack={paused:!1,loopPosition:0,fallThrough:!1}}registerHandler(p,_){this._handlers[p]===void 0&&(this._handlers[p]=[]);const b=this._handlers[p];return b.push(_),{dispose:()=>{const h=b.indexOf(_);h!==-1&&b.splice(h,1)}}}clearHandler(p){this._handlers[p]&&delete this._handlers[p]}setHandlerFallback(p){this._handlerFb=p}dispose(){this._handlers=Object.create(null),this._handlerFb=()=>{},this._active=u}reset(){if(this._state===2)for(let p=this._stack.paused?this._stack.loopPosition-1:this._active.length-1;p>=0;--p)this._active[p].end(!1);this._stack.paused=!1,this._active=u,this._id=-1,this._state=0}_start(){if(this._active=this._handlers[this._id]||u,this._active.length)for(let p=this._active.length-1;p>=0;p--)this._active[p].start();else this._handlerFb(this._id,"START")}_put(p,_,b){if(this._active.length)for(let h=this._active.length-1;h>=0;h--)this._active[h].put(p,_,b);else this._handlerFb(this._id,"PUT",(0,f.utf32ToString)(p,_,b))}start(){this.reset(),this._state=1}put(p,_,b){if(this._state!==3){if(this._state===1)for(;_<b;){const h=p[_++];if(h===59){this._state=2,this._start();break}if(h<48||57<h)return void(this._state=3);this._id===-1&&(this._id=0),this._id=10*this._id+h-48}this._state===2&&b-_>0&&this._put(p,_,b)}}end(p,_=!0){if(this._state!==0){if(this._state!==3)if(this._state===1&&this._start(),this._active.length){let b=!1,h=this._active.length-1,g=!1;if(this._stack.paused&&(h=this._stack.loopPosition-1,b=_,g=this._stack.fallThrough,this._stack.paused=!1),!g&&b===!1){for(;h>=0&&(b=this._active[h].end(p),b!==!0);h--)if(b instanceof Promise)return this._stack.paused=!0,this._stack.loopPosition=h,this._stack.fallThrough=!1,b;h--}for(;h>=0;h--)if(b=this._active[h].end(!1),b instanceof Promise)return this._stack.paused=!0,this._stack.loopPosition=h,this._stack.fallThrough=!0,b}else this._handlerFb(this._id,"END",p);this._active=u,this._id=-1,this._state=0}}},i.OscHandler=class{constructor(p){this._handler=p,this._data="",this._hitLimit=!1}start(){this._data="",this._hitLimit=!1}put(p,_,b){this._hitLimit||(this._data+=(0,f.utf32ToString)(p,_,b),this._data.length>d.PAYLOAD_LIMIT&&(this._data="",this._hitLimit=!0))}end(p){let _=!1;if(this._hitLimit)_=!1;else if(p&&(_=this._handler(this._data),_ instanceof Promise))return _.then(b=>(this._data="",this._hitLimit=!1,b));return this._data="",this._hitLimit=!1,_}}},8742:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.Params=void 0;const l=2147483647;class d{static fromArray(u){const p=new d;if(!u.length)return p;for(let _=Array.isArray(u[0])?1:0;_<u.length;++_){const b=u[_];if(Array.isArray(b))for(let h=0;h<b.length;++h)p.addSubParam(b[h]);else p.addParam(b)}return p}constructor(u=32,p=32){if(this.maxLength=u,this.maxSubParamsLength=p,p>256)throw new Error("maxSubParamsLength must not be greater than 256");this.params=new Int32Array(u),this.length=0,this._subParams=new Int32Array(p),this._subParamsLength=0,this._subParamsIdx=new Uint16Array(u),this._rejectDigits=!1,this._rejectSubDigits=!1,this._digitIsSub=!1}clone(){const u=new d(this.maxLength,this.maxSubParamsLength);return u.params.set(this.params),u.length=this.length,u._subParams.set(this._subParams),u._subParamsLength=this._subParamsLength,u._subParamsIdx.set(this._subParamsIdx),u._rejectDigits=this._rejectDigits,u._rejectSubDigits=this._rejectSubDigits,u._digitIsSub=this._digitIsSub,u}toArray(){const u=[];for(let p=0;p<this.length;++p){u.push(this.params[p]);const _=this._subParamsIdx[p]>>8,b=255&this._subParamsIdx[p];b-_>0&&u.push(Array.prototype.slice.call(this._subParams,_,b))}return u}reset(){this.length=0,this._subParamsLength=0,this._rejectDigits=!1,this._rejectSubDigits=!1,this._digitIsSub=!1}addParam(u){if(this._digitIsSub=!1,this.length>=this.maxLength)this._rejectDigits=!0;else{if(u<-1)throw new Error("values lesser than -1 are not allowed");this._subParamsIdx[this.length]=this._subParamsLength<<8|this._subParamsLength,this.params[this.length++]=u>l?l:u}}addSubParam(u){if(this._digitIsSub=!0,this.length)if(this._rejectDigits||this._subParamsLength>=this.maxSubParamsLength)this._rejectSubDigits=!0;else{if(u<-1)throw new Error("values lesser than -1 are not allowed");this._subParams[this._subParamsLength++]=u>l?l:u,this._subParamsIdx[this.length-1]++}}hasSubParams(u){return(255&this._subParamsIdx[u])-(this._subParamsIdx[u]>>8)>0}getSubParams(u){const p=this._subParamsIdx[u]>>8,_=255&this._subParamsIdx[u];return _-p>0?this._subParams.subarray(p,_):null}getSubParamsAll(){const u={};for(let p=0;p<this.length;++p){const _=this._subParamsIdx[p]>>8,b=255&this._subParamsIdx[p];b-_>0&&(u[p]=this._subParams.slice(_,b))}return u}addDigit(u){let p;if(this._rejectDigits||!(p=this._digitIsSub?this._subParamsLength:this.length)||this._digitIsSub&&this._rejectSubDigits)return;const _=this._digitIsSub?this._subParams:this.params,b=_[p-1];_[p-1]=~b?Math.min(10*b+u,l):u}}i.Params=d},5741:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.AddonManager=void 0,i.AddonManager=class{constructor(){this._addons=[]}dispose(){for(let l=this._addons.length-1;l>=0;l--)this._addons[l].instance.dispose()}loadAddon(l,d){const f={instance:d,dispose:d.dispose,isDisposed:!1};this._addons.push(f),d.dispose=()=>this._wrappedAddonDispose(f),d.activate(l)}_wrappedAddonDispose(l){if(l.isDisposed)return;let d=-1;for(let f=0;f<this._addons.length;f++)if(this._addons[f]===l){d=f;break}if(d===-1)throw new Error("Could not dispose an addon that has not been loaded");l.isDisposed=!0,l.dispose.apply(l.instance),this._addons.splice(d,1)}}},8771:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.BufferApiView=void 0;const d=l(3785),f=l(511);i.BufferApiView=class{constructor(u,p){this._buffer=u,this.type=p}init(u){return this._buffer=u,this}get cursorY(){return this._buffer.y}get cursorX(){return this._buffer.x}get viewportY(){return this._buffer.ydisp}get baseY(){return this._buffer.ybase}get length(){return this._buffer.lines.length}getLine(u){const p=this._buffer.lines.get(u);if(p)return new d.BufferLineApiView(p)}getNullCell(){return new f.CellData}}},3785:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.BufferLineApiView=void 0;const d=l(511);i.BufferLineApiView=class{constructor(f){this._line=f}get isWrapped(){return this._line.isWrapped}get length(){return this._line.length}getCell(f,u){if(!(f<0||f>=this._line.length))return u?(this._line.loadCell(f,u),u):this._line.loadCell(f,new d.CellData)}translateToString(f,u,p){return this._line.translateToString(f,u,p)}}},8285:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.BufferNamespaceApi=void 0;const d=l(8771),f=l(8460),u=l(844);class p extends u.Disposable{constructor(b){super(),this._core=b,this._onBufferChange=this.register(new f.EventEmitter),this.onBufferChange=this._onBufferChange.event,this._normal=new d.BufferApiView(this._core.buffers.normal,"normal"),this._alternate=new d.BufferApiView(this._core.buffers.alt,"alternate"),this._core.buffers.onBufferActivate(()=>this._onBufferChange.fire(this.active))}get active(){if(this._core.buffers.active===this._core.buffers.normal)return this.normal;if(this._core.buffers.active===this._core.buffers.alt)return this.alternate;throw new Error("Active buffer is neither normal nor alternate")}get normal(){return this._normal.init(this._core.buffers.normal)}get alternate(){return this._alternate.init(this._core.buffers.alt)}}i.BufferNamespaceApi=p},7975:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.ParserApi=void 0,i.ParserApi=class{constructor(l){this._core=l}registerCsiHandler(l,d){return this._core.registerCsiHandler(l,f=>d(f.toArray()))}addCsiHandler(l,d){return this.registerCsiHandler(l,d)}registerDcsHandler(l,d){return this._core.registerDcsHandler(l,(f,u)=>d(f,u.toArray()))}addDcsHandler(l,d){return this.registerDcsHandler(l,d)}registerEscHandler(l,d){return this._core.registerEscHandler(l,d)}addEscHandler(l,d){return this.registerEscHandler(l,d)}registerOscHandler(l,d){return this._core.registerOscHandler(l,d)}addOscHandler(l,d){return this.registerOscHandler(l,d)}}},7090:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.UnicodeApi=void 0,i.UnicodeApi=class{constructor(l){this._core=l}register(l){this._core.unicodeService.register(l)}get versions(){return this._core.unicodeService.versions}get activeVersion(){return this._core.unicodeService.activeVersion}set activeVersion(l){this._core.unicodeService.activeVersion=l}}},744:function(a,i,l){var d=this&&this.__decorate||function(g,v,m,y){var w,S=arguments.length,C=S<3?v:y===null?y=Object.getOwnPropertyDescriptor(v,m):y;if(typeof Reflect=="object"&&typeof Reflect.decorate=="function")C=Reflect.decorate(g,v,m,y);else for(var k=g.length-1;k>=0;k--)(w=g[k])&&(C=(S<3?w(C):S>3?w(v,m,C):w(v,m))||C);return S>3&&C&&Object.defineProperty(v,m,C),C},f=this&&this.__param||function(g,v){return function(m,y){v(m,y,g)}};Object.defineProperty(i,"__esModule",{value:!0}),i.BufferService=i.MINIMUM_ROWS=i.MINIMUM_COLS=void 0;const u=l(8460),p=l(844),_=l(5295),b=l(2585);i.MINIMUM_COLS=2,i.MINIMUM_ROWS=1;let h=i.BufferService=class extends p.Disposable{get buffer(){return this.buffers.active}constructor(g){super(),this.isUserScrolling=!1,this._onResize=this.register(new u.EventEmitter),this.onResize=this._onResize.event,this._onScroll=this.register(new u.EventEmitter),this.onScroll=this._onScroll.event,this.cols=Math.max(g.rawOptions.cols||0,i.MINIMUM_COLS),this.rows=Math.max(g.rawOptions.rows||0,i.MINIMUM_ROWS),this.buffers=this.register(new _.BufferSet(g,this))}resize(g,v){this.cols=g,this.rows=v,this.buffers.resize(g,v),this._onResize.fire({cols:g,rows:v})}reset(){this.buffers.reset(),this.isUserScrolling=!1}scroll(g,v=!1){const m=this.buffer;let y;y=this._cachedBlankLine,y&&y.length===this.cols&&y.getFg(0)===g.fg&&y.getBg(0)===g.bg||(y=m.getBlankLine(g,v),this._cachedBlankLine=y),y.isWrapped=v;const w=m.ybase+m.scrollTop,S=m.ybase+m.scrollBottom;if(m.scrollTop===0){const C=m.lines.isFull;S===m.lines.length-1?C?m.lines.recycle().copyFrom(y):m.lines.push(y.clone()):m.lines.splice(S+1,0,y.clone()),C?this.isUserScrolling&&(m.ydisp=Math.max(m.ydisp-1,0)):(m.ybase++,this.isUserScrolling||m.ydisp++)}else{const C=S-w+1;m.lines.shiftElements(w+1,C-1,-1),m.lines.set(S,y.clone())}this.isUserScrolling||(m.ydisp=m.ybase),this._onScroll.fire(m.ydisp)}scrollLines(g,v,m){const y=this.buffer;if(g<0){if(y.ydisp===0)return;this.isUserScrolling=!0}else g+y.ydisp>=y.ybase&&(this.isUserScrolling=!1);const w=y.ydisp;y.ydisp=Math.max(Math.min(y.ydisp+g,y.ybase),0),w!==y.ydisp&&(v||this._onScroll.fire(y.ydisp))}};i.BufferService=h=d([f(0,b.IOptionsService)],h)},7994:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.CharsetService=void 0,i.CharsetService=class{constructor(){this.glevel=0,this._charsets=[]}reset(){this.charset=void 0,this._charsets=[],this.glevel=0}setgLevel(l){this.glevel=l,this.charset=this._charsets[l]}setgCharset(l,d){this._charsets[l]=d,this.glevel===l&&(this.charset=d)}}},1753:function(a,i,l){var d=this&&this.__decorate||function(y,w,S,C){var k,E=arguments.length,R=E<3?w:C===null?C=Object.getOwnPropertyDescriptor(w,S):C;if(typeof Reflect=="object"&&typeof Reflect.decorate=="function")R=Reflect.decorate(y,w,S,C);else for(var $=y.length-1;$>=0;$--)(k=y[$])&&(R=(E<3?k(R):E>3?k(w,S,R):k(w,S))||R);return E>3&&R&&Object.defineProperty(w,S,R),R},f=this&&this.__param||function(y,w){return function(S,C){w(S,C,y)}};Object.defineProperty(i,"__esModule",{value:!0}),i.CoreMouseService=void 0;const u=l(2585),p=l(8460),_=l(844),b={NONE:{events:0,restrict:()=>!1},X10:{events:1,restrict:y=>y.button!==4&&y.action===1&&(y.ctrl=!1,y.alt=!1,y.shift=!1,!0)},VT200:{events:19,restrict:y=>y.action!==32},DRAG:{events:23,restrict:y=>y.action!==32||y.button!==3},ANY:{events:31,restrict:y=>!0}};function h(y,w){let S=(y.ctrl?16:0)|(y.shift?4:0)|(y.alt?8:0);return y.button===4?(S|=64,S|=y.action):(S|=3&y.button,4&y.button&&(S|=64),8&y.button&&(S|=128),y.action===32?S|=32:y.action!==0||w||(S|=3)),S}const g=String.fromCharCode,v={DEFAULT:y=>{const w=[h(y,!1)+32,y.col+32,y.row+32];return w[0]>255||w[1]>255||w[2]>255?"":`\x1B[M${g(w[0])}${g(w[1])}${g(w[2])}`},SGR:y=>{const w=y.action===0&&y.button!==4?"m":"M";return`\x1B[<${h(y,!0)};${y.col};${y.row}${w}`},SGR_PIXELS:y=>{const w=y.action===0&&y.button!==4?"m":"M";return`\x1B[<${h(y,!0)};${y.x};${y.y}${w}`}};let m=i.CoreMouseService=class extends _.Disposable{constructor(y,w){super(),this._bufferService=y,this._coreService=w,this._protocols={},this._encodings={},this._activeProtocol="",this._activeEncoding="",this._lastEvent=null,this._onProtocolChange=this.register(new p.EventEmitter),this.onProtocolChange=this._onProtocolChange.event;for(const S of Object.keys(b))this.addProtocol(S,b[S]);for(const S of Object.keys(v))this.addEncoding(S,v[S]);this.reset()}addProtocol(y,w){this._protocols[y]=w}addEncoding(y,w){this._encodings[y]=w}get activeProtocol(){return this._activeProtocol}get areMouseEventsActive(){return this._protocols[this._activeProtocol].events!==0}set activeProtocol(y){if(!this._protocols[y])throw new Error(`unknown protocol "${y}"`);this._activeProtocol=y,this._onProtocolChange.fire(this._protocols[y].events)}get activeEncoding(){return this._activeEncoding}set activeEncoding(y){if(!this._encodings[y])throw new Error(`unknown encoding "${y}"`);this._activeEncoding=y}reset(){this.activeProtocol="NONE",this.activeEncoding="DEFAULT",this._lastEvent=null}triggerMouseEvent(y){if(y.col<0||y.col>=this._bufferService.cols||y.row<0||y.row>=this._bufferService.rows||y.button===4&&y.action===32||y.button===3&&y.action!==32||y.button!==4&&(y.action===2||y.action===3)||(y.col++,y.row++,y.action===32&&this._lastEvent&&this._equalEvents(this._lastEvent,y,this._activeEncoding==="SGR_PIXELS"))||!this._protocols[this._activeProtocol].restrict(y))return!1;const w=this._encodings[this._activeEncoding](y);return w&&(this._activeEncoding==="DEFAULT"?this._coreService.triggerBinaryEvent(w):this._coreService.triggerDataEvent(w,!0)),this._lastEvent=y,!0}explainEvents(y){return{down:!!(1&y),up:!!(2&y),drag:!!(4&y),move:!!(8&y),wheel:!!(16&y)}}_equalEvents(y,w,S){if(S){if(y.x!==w.x||y.y!==w.y)return!1}else if(y.col!==w.col||y.row!==w.row)return!1;return y.button===w.button&&y.action===w.action&&y.ctrl===w.ctrl&&y.alt===w.alt&&y.shift===w.shift}};i.CoreMouseService=m=d([f(0,u.IBufferService),f(1,u.ICoreService)],m)},6975:function(a,i,l){var d=this&&this.__decorate||function(m,y,w,S){var C,k=arguments.length,E=k<3?y:S===null?S=Object.getOwnPropertyDescriptor(y,w):S;if(typeof Reflect=="object"&&typeof Reflect.decorate=="function")E=Reflect.decorate(m,y,w,S);else for(var R=m.length-1;R>=0;R--)(C=m[R])&&(E=(k<3?C(E):k>3?C(y,w,E):C(y,w))||E);return k>3&&E&&Object.defineProperty(y,w,E),E},f=this&&this.__param||function(m,y){return function(w,S){y(w,S,m)}};Object.defineProperty(i,"__esModule",{value:!0}),i.CoreService=void 0;const u=l(1439),p=l(8460),_=l(844),b=l(2585),h=Object.freeze({insertMode:!1}),g=Object.freeze({applicationCursorKeys:!1,applicationKeypad:!1,bracketedPasteMode:!1,origin:!1,reverseWraparound:!1,sendFocus:!1,wraparound:!0});let v=i.CoreService=class extends _.Disposable{constructor(m,y,w){super(),this._bufferService=m,this._logService=y,this._optionsService=w,this.isCursorInitialized=!1,this.isCursorHidden=!1,this._onData=this.register(new p.EventEmitter),this.onData=this._onData.event,this._onUserInput=this.register(new p.EventEmitter),this.onUserInput=this._onUserInput.event,this._onBinary=this.register(new p.EventEmitter),this.onBinary=this._onBinary.event,this._onRequestScrollToBottom=this.register(new p.EventEmitter),this.onRequestScrollToBottom=this._onRequestScrollToBottom.event,this.modes=(0,u.clone)(h),this.decPrivateModes=(0,u.clone)(g)}reset(){this.modes=(0,u.clone)(h),this.decPrivateModes=(0,u.clone)(g)}triggerDataEvent(m,y=!1){if(this._optionsService.rawOptions.disableStdin)return;const w=this._bufferService.buffer;y&&this._optionsService.rawOptions.scrollOnUserInput&&w.ybase!==w.ydisp&&this._onRequestScrollToBottom.fire(),y&&this._onUserInput.fire(),this._logService.debug(`sending data "${m}"`,()=>m.split("").map(S=>S.charCodeAt(0))),this._onData.fire(m)}triggerBinaryEvent(m){this._optionsService.rawOptions.disableStdin||(this._logService.debug(`sending binary "${m}"`,()=>m.split("").map(y=>y.charCodeAt(0))),this._onBinary.fire(m))}};i.CoreService=v=d([f(0,b.IBufferService),f(1,b.ILogService),f(2,b.IOptionsService)],v)},9074:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.DecorationService=void 0;const d=l(8055),f=l(8460),u=l(844),p=l(6106);let _=0,b=0;class h extends u.Disposable{get decorations(){return this._decorations.values()}constructor(){super(),this._decorations=new p.SortedList(m=>m==null?void 0:m.marker.line),this._onDecorationRegistered=this.register(new f.EventEmitter),this.onDecorationRegistered=this._onDecorationRegistered.event,this._onDecorationRemoved=this.register(new f.EventEmitter),this.onDecorationRemoved=this._onDecorationRemoved.event,this.register((0,u.toDisposable)(()=>this.reset()))}registerDecoration(m){if(m.marker.isDisposed)return;const y=new g(m);if(y){const w=y.marker.onDispose(()=>y.dispose());y.onDispose(()=>{y&&(this._decorations.delete(y)&&this._onDecorationRemoved.fire(y),w.dispose())}),this._decorations.insert(y),this._onDecorationRegistered.fire(y)}return y}reset(){for(const m of this._decorations.values())m.dispose();this._decorations.clear()}*getDecorationsAtCell(m,y,w){let S=0,C=0;for(const k of this._decorations.getKeyIterator(y))S=k.options.x??0,C=S+(k.options.width??1),m>=S&&m<C&&(!w||(k.options.layer??"bottom")===w)&&(yield k)}forEachDecorationAtCell(m,y,w,S){this._decorations.forEachByKey(y,C=>{_=C.options.x??0,b=_+(C.options.width??1),m>=_&&m<b&&(!w||(C.options.layer??"bottom")===w)&&S(C)})}}i.DecorationService=h;class g extends u.Disposable{get isDisposed(){return this._isDisposed}get backgroundColorRGB(){return this._cachedBg===null&&(this.options.backgroundColor?this._cachedBg=d.css.toColor(this.options.backgroundColor):this._cachedBg=void 0),this._cachedBg}get foregroundColorRGB(){return this._cachedFg===null&&(this.options.foregroundColor?this._cachedFg=d.css.toColor(this.options.foregroundColor):this._cachedFg=void 0),this._cachedFg}constructor(m){super(),this.options=m,this.onRenderEmitter=this.register(new f.EventEmitter),this.onRender=this.onRenderEmitter.event,this._onDispose=this.register(new f.EventEmitter),this.onDispose=this._onDispose.event,this._cachedBg=null,this._cachedFg=null,this.marker=m.marker,this.options.overviewRulerOptions&&!this.options.overviewRulerOptions.position&&(this.options.overviewRulerOptions.position="full")}dispose(){this._onDispose.fire(),super.dispose()}}},4348:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.InstantiationService=i.ServiceCollection=void 0;const d=l(2585),f=l(8343);class u{constructor(..._){this._entries=new Map;for(const[b,h]of _)this.set(b,h)}set(_,b){const h=this._entries.get(_);return this._entries.set(_,b),h}forEach(_){for(const[b,h]of this._entries.entries())_(b,h)}has(_){return this._entries.has(_)}get(_){return this._entries.get(_)}}i.ServiceCollection=u,i.InstantiationService=class{constructor(){this._services=new u,this._services.set(d.IInstantiationService,this)}setService(p,_){this._services.set(p,_)}getService(p){return this._services.get(p)}createInstance(p,..._){const b=(0,f.getServiceDependencies)(p).sort((v,m)=>v.index-m.index),h=[];for(const v of b){const m=this._services.get(v.id);if(!m)throw new Error(`[createInstance] ${p.name} depends on UNKNOWN service ${v.id}.`);h.push(m)}const g=b.length>0?b[0].index:_.length;if(_.length!==g)throw new Error(`[createInstance] First service dependency of ${p.name} at position ${g+1} conflicts with ${_.length} static arguments`);return new p(..._,...h)}}},7866:function(a,i,l){var d=this&&this.__decorate||function(g,v,m,y){var w,S=arguments.length,C=S<3?v:y===null?y=Object.getOwnPropertyDescriptor(v,m):y;if(typeof Reflect=="object"&&typeof Reflect.decorate=="function")C=Reflect.decorate(g,v,m,y);else for(var k=g.length-1;k>=0;k--)(w=g[k])&&(C=(S<3?w(C):S>3?w(v,m,C):w(v,m))||C);return S>3&&C&&Object.defineProperty(v,m,C),C},f=this&&this.__param||function(g,v){return function(m,y){v(m,y,g)}};Object.defineProperty(i,"__esModule",{value:!0}),i.traceCall=i.setTraceLogger=i.LogService=void 0;const u=l(844),p=l(2585),_={trace:p.LogLevelEnum.TRACE,debug:p.LogLevelEnum.DEBUG,info:p.LogLevelEnum.INFO,warn:p.LogLevelEnum.WARN,error:p.LogLevelEnum.ERROR,off:p.LogLevelEnum.OFF};let b,h=i.LogService=class extends u.Disposable{get logLevel(){return this._logLevel}constructor(g){super(),this._optionsService=g,this._logLevel=p.LogLevelEnum.OFF,this._updateLogLevel(),this.register(this._optionsService.onSpecificOptionChange("logLevel",()=>this._updateLogLevel())),b=this}_updateLogLevel(){this._logLevel=_[this._optionsService.rawOptions.logLevel]}_evalLazyOptionalParams(g){for(let v=0;v<g.length;v++)typeof g[v]=="function"&&(g[v]=g[v]())}_log(g,v,m){this._evalLazyOptionalParams(m),g.call(console,(this._optionsService.options.logger?"":"xterm.js: ")+v,...m)}trace(g,...v){var m;this._logLevel<=p.LogLevelEnum.TRACE&&this._log(((m=this._optionsService.options.logger)==null?void 0:m.trace.bind(this._optionsService.options.logger))??console.log,g,v)}debug(g,...v){var m;this._logLevel<=p.LogLevelEnum.DEBUG&&this._log(((m=this._optionsService.options.logger)==null?void 0:m.debug.bind(this._optionsService.options.logger))??console.log,g,v)}info(g,...v){var m;this._logLevel<=p.LogLevelEnum.INFO&&this._log(((m=this._optionsService.options.logger)==null?void 0:m.info.bind(this._optionsService.options.logger))??console.info,g,v)}warn(g,...v){var m;this._logLevel<=p.LogLevelEnum.WARN&&this._log(((m=this._optionsService.options.logger)==null?void 0:m.warn.bind(this._optionsService.options.logger))??console.warn,g,v)}error(g,...v){var m;this._logLevel<=p.LogLevelEnum.ERROR&&this._log(((m=this._optionsService.options.logger)==null?void 0:m.error.bind(this._optionsService.options.logger))??console.error,g,v)}};i.LogService=h=d([f(0,p.IOptionsService)],h),i.setTraceLogger=function(g){b=g},i.traceCall=function(g,v,m){if(typeof m.value!="function")throw new Error("not supported");const y=m.value;m.value=function(...w){if(b.logLevel!==p.LogLevelEnum.TRACE)return y.apply(this,w);b.trace(`GlyphRenderer#${y.name}(${w.map(C=>JSON.stringify(C)).join(", ")})`);const S=y.apply(this,w);return b.trace(`GlyphRenderer#${y.name} return`,S),S}}},7302:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.OptionsService=i.DEFAULT_OPTIONS=void 0;const d=l(8460),f=l(844),u=l(6114);i.DEFAULT_OPTIONS={cols:80,rows:24,cursorBlink:!1,cursorStyle:"block",cursorWidth:1,cursorInactiveStyle:"outline",customGlyphs:!0,drawBoldTextInBrightColors:!0,documentOverride:null,fastScrollModifier:"alt",fastScrollSensitivity:5,fontFamily:"courier-new, courier, monospace",fontSize:15,fontWeight:"normal",fontWeightBold:"bold",ignoreBracketedPasteMode:!1,lineHeight:1,letterSpacing:0,linkHandler:null,logLevel:"info",logger:null,scrollback:1e3,scrollOnUserInput:!0,scrollSensitivity:1,screenReaderMode:!1,smoothScrollDuration:0,macOptionIsMeta:!1,macOptionClickForcesSelection:!1,minimumContrastRatio:1,disableStdin:!1,allowProposedApi:!1,allowTransparency:!1,tabStopWidth:8,theme:{},rescaleOverlappingGlyphs:!1,rightClickSelectsWord:u.isMac,windowOptions:{},windowsMode:!1,windowsPty:{},wordSeparator:" ()[]{}',\"`",altClickMovesCursor:!0,convertEol:!1,termName:"xterm",cancelEvents:!1,overviewRulerWidth:0};const p=["normal","bold","100","200","300","400","500","600","700","800","900"];class _ extends f.Disposable{constructor(h){super(),this._onOptionChange=this.register(new d.EventEmitter),this.onOptionChange=this._onOptionChange.event;const g={...i.DEFAULT_OPTIONS};for(const v in h)if(v in g)try{const m=h[v];g[v]=this._sanitizeAndValidateOption(v,m)}catch(m){console.error(m)}this.rawOptions=g,this.options={...g},this._setupOptions(),this.register((0,f.toDisposable)(()=>{this.rawOptions.linkHandler=null,this.rawOptions.documentOverride=null}))}onSpecificOptionChange(h,g){return this.onOptionChange(v=>{v===h&&g(this.rawOptions[h])})}onMultipleOptionChange(h,g){return this.onOptionChange(v=>{h.indexOf(v)!==-1&&g()})}_setupOptions(){const h=v=>{if(!(v in i.DEFAULT_OPTIONS))throw new Error(`No option with key "${v}"`);return this.rawOptions[v]},g=(v,m)=>{if(!(v in i.DEFAULT_OPTIONS))throw new Error(`No option with key "${v}"`);m=this._sanitizeAndValidateOption(v,m),this.rawOptions[v]!==m&&(this.rawOptions[v]=m,this._onOptionChange.fire(v))};for(const v in this.rawOptions){const m={get:h.bind(this,v),set:g.bind(this,v)};Object.defineProperty(this.options,v,m)}}_sanitizeAndValidateOption(h,g){switch(h){case"cursorStyle":if(g||(g=i.DEFAULT_OPTIONS[h]),!function(v){return v==="block"||v==="underline"||v==="bar"}(g))throw new Error(`"${g}" is not a valid value for ${h}`);break;case"wordSeparator":g||(g=i.DEFAULT_OPTIONS[h]);break;case"fontWeight":case"fontWeightBold":if(typeof g=="number"&&1<=g&&g<=1e3)break;g=p.includes(g)?g:i.DEFAULT_OPTIONS[h];break;case"cursorWidth":g=Math.floor(g);case"lineHeight":case"tabStopWidth":if(g<1)throw new Error(`${h} cannot be less than 1, value: ${g}`);break;case"minimumContrastRatio":g=Math.max(1,Math.min(21,Math.round(10*g)/10));break;case"scrollback":if((g=Math.min(g,4294967295))<0)throw new Error(`${h} cannot be less than 0, value: ${g}`);break;case"fastScrollSensitivity":case"scrollSensitivity":if(g<=0)throw new Error(`${h} cannot be less than or equal to 0, value: ${g}`);break;case"rows":case"cols":if(!g&&g!==0)throw new Error(`${h} must be numeric, value: ${g}`);break;case"windowsPty":g=g??{}}return g}}i.OptionsService=_},2660:function(a,i,l){var d=this&&this.__decorate||function(_,b,h,g){var v,m=arguments.length,y=m<3?b:g===null?g=Object.getOwnPropertyDescriptor(b,h):g;if(typeof Reflect=="object"&&typeof Reflect.decorate=="function")y=Reflect.decorate(_,b,h,g);else for(var w=_.length-1;w>=0;w--)(v=_[w])&&(y=(m<3?v(y):m>3?v(b,h,y):v(b,h))||y);return m>3&&y&&Object.defineProperty(b,h,y),y},f=this&&this.__param||function(_,b){return function(h,g){b(h,g,_)}};Object.defineProperty(i,"__esModule",{value:!0}),i.OscLinkService=void 0;const u=l(2585);let p=i.OscLinkService=class{constructor(_){this._bufferService=_,this._nextId=1,this._entriesWithId=new Map,this._dataByLinkId=new Map}registerLink(_){const b=this._bufferService.buffer;if(_.id===void 0){const w=b.addMarker(b.ybase+b.y),S={data:_,id:this._nextId++,lines:[w]};return w.onDispose(()=>this._removeMarkerFromLink(S,w)),this._dataByLinkId.set(S.id,S),S.id}const h=_,g=this._getEntryIdKey(h),v=this._entriesWithId.get(g);if(v)return this.addLineToLink(v.id,b.ybase+b.y),v.id;const m=b.addMarker(b.ybase+b.y),y={id:this._nextId++,key:this._getEntryIdKey(h),data:h,lines:[m]};return m.onDispose(()=>this._removeMarkerFromLink(y,m)),this._entriesWithId.set(y.key,y),this._dataByLinkId.set(y.id,y),y.id}addLineToLink(_,b){const h=this._dataByLinkId.get(_);if(h&&h.lines.every(g=>g.line!==b)){const g=this._bufferService.buffer.addMarker(b);h.lines.push(g),g.onDispose(()=>this._removeMarkerFromLink(h,g))}}getLinkData(_){var b;return(b=this._dataByLinkId.get(_))==null?void 0:b.data}_getEntryIdKey(_){return`${_.id};;${_.uri}`}_removeMarkerFromLink(_,b){const h=_.lines.indexOf(b);h!==-1&&(_.lines.splice(h,1),_.lines.length===0&&(_.data.id!==void 0&&this._entriesWithId.delete(_.key),this._dataByLinkId.delete(_.id)))}};i.OscLinkService=p=d([f(0,u.IBufferService)],p)},8343:(a,i)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.createDecorator=i.getServiceDependencies=i.serviceRegistry=void 0;const l="di$target",d="di$dependencies";i.serviceRegistry=new Map,i.getServiceDependencies=function(f){return f[d]||[]},i.createDecorator=function(f){if(i.serviceRegistry.has(f))return i.serviceRegistry.get(f);const u=function(p,_,b){if(arguments.length!==3)throw new Error("@IServiceName-decorator can only be used to decorate a parameter");(function(h,g,v){g[l]===g?g[d].push({id:h,index:v}):(g[d]=[{id:h,index:v}],g[l]=g)})(u,p,b)};return u.toString=()=>f,i.serviceRegistry.set(f,u),u}},2585:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.IDecorationService=i.IUnicodeService=i.IOscLinkService=i.IOptionsService=i.ILogService=i.LogLevelEnum=i.IInstantiationService=i.ICharsetService=i.ICoreService=i.ICoreMouseService=i.IBufferService=void 0;const d=l(8343);var f;i.IBufferService=(0,d.createDecorator)("BufferService"),i.ICoreMouseService=(0,d.createDecorator)("CoreMouseService"),i.ICoreService=(0,d.createDecorator)("CoreService"),i.ICharsetService=(0,d.createDecorator)("CharsetService"),i.IInstantiationService=(0,d.createDecorator)("InstantiationService"),function(u){u[u.TRACE=0]="TRACE",u[u.DEBUG=1]="DEBUG",u[u.INFO=2]="INFO",u[u.WARN=3]="WARN",u[u.ERROR=4]="ERROR",u[u.OFF=5]="OFF"}(f||(i.LogLevelEnum=f={})),i.ILogService=(0,d.createDecorator)("LogService"),i.IOptionsService=(0,d.createDecorator)("OptionsService"),i.IOscLinkService=(0,d.createDecorator)("OscLinkService"),i.IUnicodeService=(0,d.createDecorator)("UnicodeService"),i.IDecorationService=(0,d.createDecorator)("DecorationService")},1480:(a,i,l)=>{Object.defineProperty(i,"__esModule",{value:!0}),i.UnicodeService=void 0;const d=l(8460),f=l(225);class u{static extractShouldJoin(_){return(1&_)!=0}static extractWidth(_){return _>>1&3}static extractCharKind(_){return _>>3}static createPropertyValue(_,b,h=!1){return(16777215&_)<<3|(3&b)<<1|(h?1:0)}constructor(){this._providers=Object.create(null),this._active="",this._onChange=new d.EventEmitter,this.onChange=this._onChange.event;const _=new f.UnicodeV6;this.register(_),this._active=_.version,this._activeProvider=_}dispose(){this._onChange.dispose()}get versions(){return Object.keys(this._providers)}get activeVersion(){return this._active}set activeVersion(_){if(!this._providers[_])throw new Error(`unknown Unicode version "${_}"`);this._active=_,this._activeProvider=this._providers[_],this._onChange.fire(_)}register(_){this._providers[_.version]=_}wcwidth(_){return this._activeProvider.wcwidth(_)}getStringCellWidth(_){let b=0,h=0;const g=_.length;for(let v=0;v<g;++v){let m=_.charCodeAt(v);if(55296<=m&&m<=56319){if(++v>=g)return b+this.wcwidth(m);const S=_.charCodeAt(v);56320<=S&&S<=57343?m=1024*(m-55296)+S-56320+65536:b+=this.wcwidth(S)}const y=this.charProperties(m,h);let w=u.extractWidth(y);u.extractShouldJoin(y)&&(w-=u.extractWidth(h)),b+=w,h=y}return b}charProperties(_,b){return this._activeProvider.charProperties(_,b)}}i.UnicodeService=u}},r={};function s(a){var i=r[a];if(i!==void 0)return i.exports;var l=r[a]={exports:{}};return n[a].call(l.exports,l,l.exports,s),l.exports}var o={};return(()=>{var a=o;Object.defineProperty(a,"__esModule",{value:!0}),a.Terminal=void 0;const i=s(9042),l=s(3236),d=s(844),f=s(5741),u=s(8285),p=s(7975),_=s(7090),b=["cols","rows"];class h extends d.Disposable{constructor(v){super(),this._core=this.register(new l.Terminal(v)),this._addonManager=this.register(new f.AddonManager),this._publicOptions={...this._core.options};const m=w=>this._core.options[w],y=(w,S)=>{this._checkReadonlyOptions(w),this._core.options[w]=S};for(const w in this._core.options){const S={get:m.bind(this,w),set:y.bind(this,w)};Object.defineProperty(this._publicOptions,w,S)}}_checkReadonlyOptions(v){if(b.includes(v))throw new Error(`Option "${v}" can only be set in the constructor`)}_checkProposedApi(){if(!this._core.optionsService.rawOptions.allowProposedApi)throw new Error("You must set the allowProposedApi option to true to use proposed API")}get onBell(){return this._core.onBell}get onBinary(){return this._core.onBinary}get onCursorMove(){return this._core.onCursorMove}get onData(){return this._core.onData}get onKey(){return this._core.onKey}get onLineFeed(){return this._core.onLineFeed}get onRender(){return this._core.onRender}get onResize(){return this._core.onResize}get onScroll(){return this._core.onScroll}get onSelectionChange(){return this._core.onSelectionChange}get onTitleChange(){return this._core.onTitleChange}get onWriteParsed(){return this._core.onWriteParsed}get element(){return this._core.element}get parser(){return this._parser||(this._parser=new p.ParserApi(this._core)),this._parser}get unicode(){return this._checkProposedApi(),new _.UnicodeApi(this._core)}get textarea(){return this._core.textarea}get rows(){return this._core.rows}get cols(){return this._core.cols}get buffer(){return this._buffer||(this._buffer=this.register(new u.BufferNamespaceApi(this._core))),this._buffer}get markers(){return this._checkProposedApi(),this._core.markers}get modes(){const v=this._core.coreService.decPrivateModes;let m="none";switch(this._core.coreMouseService.activeProtocol){case"X10":m="x10";break;case"VT200":m="vt200";break;case"DRAG":m="drag";break;case"ANY":m="any"}return{applicationCursorKeysMode:v.applicationCursorKeys,applicationKeypadMode:v.applicationKeypad,bracketedPasteMode:v.bracketedPasteMode,insertMode:this._core.coreService.modes.insertMode,mouseTrackingMode:m,originMode:v.origin,reverseWraparoundMode:v.reverseWraparound,sendFocusMode:v.sendFocus,wraparoundMode:v.wraparound}}get options(){return this._publicOptions}set options(v){for(const m in v)this._publicOptions[m]=v[m]}blur(){this._core.blur()}focus(){this._core.focus()}input(v,m=!0){this._core.input(v,m)}resize(v,m){this._verifyIntegers(v,m),this._core.resize(v,m)}open(v){this._core.open(v)}attachCustomKeyEventHandler(v){this._core.attachCustomKeyEventHandler(v)}attachCustomWheelEventHandler(v){this._core.attachCustomWheelEventHandler(v)}registerLinkProvider(v){return this._core.registerLinkProvider(v)}registerCharacterJoiner(v){return this._checkProposedApi(),this._core.registerCharacterJoiner(v)}deregisterCharacterJoiner(v){this._checkProposedApi(),this._core.deregisterCharacterJoiner(v)}registerMarker(v=0){return this._verifyIntegers(v),this._core.registerMarker(v)}registerDecoration(v){return this._checkProposedApi(),this._verifyPositiveIntegers(v.x??0,v.width??0,v.height??0),this._core.registerDecoration(v)}hasSelection(){return this._core.hasSelection()}select(v,m,y){this._verifyIntegers(v,m,y),this._core.select(v,m,y)}getSelection(){return this._core.getSelection()}getSelectionPosition(){return this._core.getSelectionPosition()}clearSelection(){this._core.clearSelection()}selectAll(){this._core.selectAll()}selectLines(v,m){this._verifyIntegers(v,m),this._core.selectLines(v,m)}dispose(){super.dispose()}scrollLines(v){this._verifyIntegers(v),this._core.scrollLines(v)}scrollPages(v){this._verifyIntegers(v),this._core.scrollPages(v)}scrollToTop(){this._core.scrollToTop()}scrollToBottom(){this._core.scrollToBottom()}scrollToLine(v){this._verifyIntegers(v),this._core.scrollToLine(v)}clear(){this._core.clear()}write(v,m){this._core.write(v,m)}writeln(v,m){this._core.write(v),this._core.write(`\r
 `,m)}paste(v){this._core.paste(v)}refresh(v,m){this._verifyIntegers(v,m),this._core.refresh(v,m)}reset(){this._core.reset()}clearTextureAtlas(){this._core.clearTextureAtlas()}loadAddon(v){this._addonManager.loadAddon(this,v)}static get strings(){return i}_verifyIntegers(...v){for(const m of v)if(m===1/0||isNaN(m)||m%1!=0)throw new Error("This API only accepts integers")}_verifyPositiveIntegers(...v){for(const m of v)if(m&&(m===1/0||isNaN(m)||m%1!=0||m<0))throw new Error("This API only accepts positive integers")}}a.Terminal=h})(),o})())})(ME);var Hte=ME.exports,IE={exports:{}};(function(e,t){(function(n,r){e.exports=r()})(self,()=>(()=>{var n={};return(()=>{var r=n;function s(o,a,i){return o.addEventListener(a,i),{dispose:()=>{i&&o.removeEventListener(a,i)}}}Object.defineProperty(r,"__esModule",{value:!0}),r.AttachAddon=void 0,r.AttachAddon=class{constructor(o,a){this._disposables=[],this._socket=o,this._socket.binaryType="arraybuffer",this._bidirectional=!(a&&a.bidirectional===!1)}activate(o){this._disposables.push(s(this._socket,"message",a=>{const i=a.data;o.write(typeof i=="string"?i:new Uint8Array(i))})),this._bidirectional&&(this._disposables.push(o.onData(a=>this._sendData(a))),this._disposables.push(o.onBinary(a=>this._sendBinary(a)))),this._disposables.push(s(this._socket,"close",()=>this.dispose())),this._disposables.push(s(this._socket,"error",()=>this.dispose()))}dispose(){for(const o of this._disposables)o.dispose()}_sendData(o){this._checkOpenSocket()&&this._socket.send(o)}_sendBinary(o){if(!this._checkOpenSocket())return;const a=new Uint8Array(o.length);for(let i=0;i<o.length;++i)a[i]=255&o.charCodeAt(i);this._socket.send(a)}_checkOpenSocket(){switch(this._socket.readyState){case WebSocket.OPEN:return!0;case WebSocket.CONNECTING:throw new Error("Attach addon was loaded before socket was open");case WebSocket.CLOSING:return console.warn("Attach addon socket is closing"),!1;case WebSocket.CLOSED:throw new Error("Attach addon socket is closed");default:throw new Error("Unexpected socket state")}}}})(),n})())})(IE);var Vte=IE.exports,PE={exports:{}};(function(e,t){(function(n,r){e.exports=r()})(self,()=>(()=>{var n={};return(()=>{var r=n;Object.defineProperty(r,"__esModule",{value:!0}),r.FitAddon=void 0,r.FitAddon=class{activate(s){this._terminal=s}dispose(){}fit(){const s=this.proposeDimensions();if(!s||!this._terminal||isNaN(s.cols)||isNaN(s.rows))return;const o=this._terminal._core;this._terminal.rows===s.rows&&this._terminal.cols===s.cols||(o._renderService.clear(),this._terminal.resize(s.cols,s.rows))}proposeDimensions(){if(!this._terminal||!this._terminal.element||!this._terminal.element.parentElement)return;const s=this._terminal._core,o=s._renderService.dimensions;if(o.css.cell.width===0||o.css.cell.height===0)return;const a=this._terminal.options.scrollback===0?0:s.viewport.scrollBarWidth,i=window.getComputedStyle(this._terminal.element.parentElement),l=parseInt(i.getPropertyValue("height")),d=Math.max(0,parseInt(i.getPropertyValue("width"))),f=window.getComputedStyle(this._terminal.element),u=l-(parseInt(f.getPropertyValue("padding-top"))+parseInt(f.getPropertyValue("padding-bottom"))),p=d-(parseInt(f.getPropertyValue("padding-right"))+parseInt(f.getPropertyValue("padding-left")))-a;return{cols:Math.max(2,Math.floor(p/o.css.cell.width)),rows:Math.max(1,Math.floor(u/o.css.cell.height))}}}})(),n})())})(PE);var zte=PE.exports;const Wte=e=>(Ok("data-v-bc9db1e5"),e=e(),xk(),e),Kte=Wte(()=>Z("div",null,"命令详情",-1)),jte={class:"dialog-footer"},Ute={key:0,class:"dialog-footer"},qte={key:1,class:"dialog-footer"},Gte=["innerHTML"],Yte={class:"dialog-footer"},Xte={style:{color:"white"}},Jte={style:{"padding-top":"5px"}},Zte={style:{"padding-top":"5px"}},Qte={style:{margin:"1px"}},ene=["id"],tne=ee({__name:"Home",setup(e){let t=Zc(),n=Fu(),r=Ct({mode:0,id:0,name:"",address:"",user:"",auth_type:"pwd",net_type:"tcp4",cert_data:"",cert_pwd:"",pwd:"",port:22,h:20,w:80,session_id:"",background:"#000000",foreground:"#FFFFFF",cursor_color:"#FFFFFF",font_family:"Courier",font_size:16,cursor_style:"block",shell:"bash",pty_type:"xterm-256color",init_cmd:"",init_banner:"",upload_path:"",download_path:"",host_list:[],host_tabs:[],current_host:{session_id:""},host_config_collapse:["1"],host_dialog_visible:!1,file_dialog_visible:!1,modify_pwd_dialog_visible:!1,dir_info:{},sftp_current_dir:"",sftp_upload_percentage:0,new_pwd_one:"",new_pwd_two:""}),s=Ct({name:"",data:"",node:"current"}),o=z([]);const a=z(""),i=O(()=>r.host_list.filter(j=>!a.value||j.name.toLowerCase().includes(a.value.toLowerCase())||j.address.toLowerCase().includes(a.value.toLowerCase()))),l=z(""),d=O(()=>o.value.filter(j=>!l.value||j.cmd_name.toLowerCase().includes(l.value.toLowerCase())));let f;const u=O(()=>`<span style="color:red;">当前名称:${r.current_host.name} &nbsp;&nbsp;&nbsp;当前主机:${r.current_host.address}</span>`);function p(){if(r.new_pwd_one.length<2){_t.error("密码至少两个字符");return}if(r.new_pwd_two.length<2){_t.error("密码至少两个字符");return}if(r.new_pwd_one!==r.new_pwd_two){_t.error("两次密码输入不一致");return}kt.patch("/api/user/pwd",{pwd:r.new_pwd_one}).then(j=>{j.data.code===0?_t.success("密码修改成功"):_t.error("密码修改失败")}).catch(()=>{_t.error("密码修改错误")}),r.modify_pwd_dialog_visible=!1}function _(){s.node=="current"&&v({id:0,cmd_name:"",cmd_data:s.data}),s.node=="all"&&m({id:0,cmd_name:"",cmd_data:s.data})}function b(){if(s.data.trim().length===0){_t.error("收藏的命令不能为空");return}if(s.name.trim().length===0){_t.error("如果收藏命令,必须输入收藏名称");return}kt.post("/api/cmd_note/",{cmd_name:s.name,cmd_data:s.data}).then(j=>{j.data.code===0?(_t.success("收藏成功"),g()):_t.error("收藏命令出错了")})}function h(j){kt.delete(`/api/cmd_note/${j}`).then(J=>{J.data.code===0?(o.value=J.data.data,_t.success("删除成功")):_t.error("删除命令收藏出错了")})}function g(){kt.get("/api/cmd_note").then(j=>{j.data.code===0?o.value=j.data.data:_t.error("获取主机列表错误")})}function v(j){try{r.current_host.ws.send(j.cmd_data+`
 `)}catch{_t.error("当前会话执行命令失败")}}function m(j){try{if(r.host_tabs.length===0){_t.error("没有连接会话");return}r.host_tabs.forEach(J=>{J.ws.send(j.cmd_data+`
-`)})}catch{_t.error("执行命令失败")}}function y(){const j=document.createElement("input");j.type="file",j.addEventListener("change",J=>{const ne=J.target.files;if(ne&&ne.length>0){let X=ne[0];if(!(X.size/1024/1024<1)){_t.error("上传文件大小不能超过 1MB!");return}const ce=new FileReader;ce.onload=_e=>{r.cert_data=_e.target.result},ce.readAsText(X)}}),j.click()}function w(){let j={host:{},is_success:!1};return r.name.length===0?(_t.error("名称不能为空"),j):r.name.length>30?(_t.error("名称不能大于30个字符"),j):r.address.length===0?(_t.error("主机不能为空"),j):r.address.length>60?(_t.error("主机不能大于60个字符"),j):r.user.length===0?(_t.error("用户名不能为空"),j):r.user.length>60?(_t.error("用户名不能大于60个字符"),j):r.user.length===0?(_t.error("用户名不能为空"),j):r.user.length>60?(_t.error("用户名不能大于60个字符"),j):r.auth_type==="pwd"&&r.pwd.length===0?(_t.error("密码不能为空"),j):r.user.length>60?(_t.error("密码不能大于60个字符"),j):r.port?r.port<1||r.port>65535?(_t.error("端口范围错误,必须是1-65535"),j):r.auth_type==="cert"&&r.cert_data===""?(_t.error("使用证书登陆,证书内容不能为空"),j):{host:{id:r.id,name:r.name,address:r.address,user:r.user,auth_type:r.auth_type,net_type:r.net_type,cert_data:r.cert_data,cert_pwd:r.cert_pwd,pwd:r.pwd,port:r.port,session_id:r.session_id,background:r.background,foreground:r.foreground,cursor_color:r.cursor_color,font_family:r.font_family,font_size:r.font_size,cursor_style:r.cursor_style,shell:r.shell,pty_type:r.pty_type,init_cmd:r.init_cmd,init_banner:r.init_banner},is_success:!0}:(_t.error("端口输入错误,必须是1-65535"),j)}function S(){r.id=0,r.name="",r.address="",r.user="",r.pwd="",r.auth_type="pwd",r.net_type="tcp4",r.cert_data="",r.cert_pwd="",r.port=22,r.session_id="",r.background="#000000",r.foreground="#FFFFFF",r.cursor_color="#FFFFFF",r.font_family="Courier",r.font_size=16,r.cursor_style="block",r.shell="bash",r.pty_type="xterm-256color",r.init_cmd="",r.init_banner="",r.host_config_collapse=["1"]}function C(){let j=w();j.is_success&&A(j.host)}function k(j,J){r.file_dialog_visible=!0,J&&Q(J.session_id);let ne={...r.current_host};if(!ne.hasOwnProperty("session_id"))return;let X=new FormData;X.append("session_id",ne.session_id),X.append("path",j),kt.post("/api/sftp/list",X).then(te=>{te.data.code===0?(r.dir_info=te.data.data,r.sftp_current_dir=j):_t.error("获取文件列表错误")})}function E(j){r.sftp_upload_percentage=0;function J(X){let te=new FormData;te.append("session_id",r.current_host.session_id),te.append("path",j);for(let ce=0;ce<X.length;ce++)te.append("files",X[ce]);kt({url:"/api/sftp/upload",method:"put",data:te,onUploadProgress:ce=>{const{loaded:_e,total:oe}=ce;oe?r.sftp_upload_percentage=_e/oe*100|0:r.sftp_upload_percentage=_e}}).then(ce=>{if(ce.data.code===0){k(r.sftp_current_dir,r.current_host);let _e=ce.data.data;if(_e){let oe="";_e.forEach(Se=>{oe+=`<p>${Se}</p>`}),AE({type:"success",duration:7e3,title:ce.data.msg,dangerouslyUseHTMLString:!0,message:oe})}}else _t.error("上传失败")}).catch(()=>{_t.error("上传异常")})}let ne=document.createElement("input");ne.type="file",ne.multiple=!0,ne.onchange=function(X){let te=ne.files;J(te)},ne.click()}function R(j){let J=`/api/sftp/download?Authorization=${localStorage.getItem("token")}&session_id=${r.current_host.session_id}&path=${encodeURIComponent(j.path).replace(/%/g,"%25")}`,ne=document.createElement("a");ne.style.display="none",ne.href=J,ne.download=j.name,ne.click()}function $(j){let J={session_id:r.current_host.session_id,path:j.path};kt.delete("/api/sftp/delete",{data:J}).then(ne=>{ne.data.code===0?(k(r.sftp_current_dir,r.current_host),_t.success("删除文件成功")):_t.error("删除文件出错了")})}function x(j,J){let ne={session_id:J.session_id,path:j};kt.post("/api/sftp/create_dir",ne).then(X=>{X.data.code===0?(k(r.sftp_current_dir,r.current_host),_t.success("创建目录成功")):_t.error("创建目录出错了")})}function L(){kt.get("/api/conn_conf").then(j=>{j.data.code===0?r.host_list=j.data.data:_t.error("获取主机列表错误")})}function D(j,J){if(J==0){for(let ne=0;ne<r.host_list.length;ne++)if(r.host_list[ne].name==j.name){_t.error("名称已经存在,请修改");return}}r.host_dialog_visible=!1,J==0?kt.post("/api/conn_conf",j).then(ne=>{ne.data.code===0?(r.host_list=ne.data.data,S()):_t.error("新增出错了")}):kt.put("/api/conn_conf",j).then(ne=>{ne.data.code===0?(r.host_list=ne.data.data,S()):_t.error("更新出错了")})}function K(){S(),r.host_dialog_visible=!0,r.mode=0}function W(j=!1){r.mode=0;let J=w();J.is_success&&(D(J.host,0),j&&A(J.host))}function N(j){r.host_dialog_visible=!0,r.mode=1,r.id=j.id,r.address=j.address,r.name=j.name,r.user=j.user,r.auth_type=j.auth_type,r.net_type=j.net_type,r.cert_data=j.cert_data,r.cert_pwd=j.cert_pwd,r.pwd=j.pwd,r.port=j.port,r.background=j.background,r.foreground=j.foreground,r.cursor_color=j.cursor_color,r.font_family=j.font_family,r.font_size=j.font_size,r.cursor_style=j.cursor_style,r.shell=j.shell,r.pty_type=j.pty_type,r.init_cmd=j.init_cmd,r.init_banner=j.init_banner,r.host_config_collapse=["1"]}function I(j=!1){let J=w();J.is_success&&(D(J.host,1),j&&A(J.host))}function V(j){kt.delete(`/api/conn_conf/${j.id}`).then(J=>{J.data.code===0?(r.host_list=J.data.data,S()):_t.error("删除主机出错了")})}function T(j){if(j.term)try{j.fit.dispose(),j.term.dispose(),j.ws.close()}catch(ne){console.log("清理资源错误:"+ne)}let J=document.getElementById(j.session_id);return J&&(J.innerHTML=""),{id:j.id,name:j.name,address:j.address,user:j.user,auth_type:j.auth_type,net_type:j.net_type,cert_data:j.cert_data,cert_pwd:j.cert_pwd,pwd:j.pwd,port:j.port,session_id:j.session_id,background:j.background,foreground:j.foreground,cursor_color:j.cursor_color,font_family:j.font_family,font_size:j.font_size,cursor_style:j.cursor_style,shell:j.shell,pty_type:j.pty_type,init_cmd:j.init_cmd,init_banner:j.init_banner}}function A(j,J=!1){r.host_dialog_visible=!1;let ne="/api/ssh/create_session";J&&(ne+=`?session_id=${j.session_id}`);let X=T(j);kt.post(ne,X).then(te=>{if(te.data.code===0){let ce=te.data.data;if(X.session_id=ce,X.fit=new zte.FitAddon,X.term=new Hte.Terminal({cursorBlink:!0,theme:{background:X.background,foreground:X.foreground,cursor:X.cursor_color},fontSize:X.font_size,fontFamily:X.font_family,cursorStyle:X.cursor_style}),X.term.loadAddon(X.fit),J){for(let[_e,oe]of r.host_tabs.entries())if(oe.session_id===ce){X.is_close=!1,r.host_tabs[_e]=X;break}}else r.host_tabs.push(X);qe(()=>{let _e=document.getElementById(X.session_id);if(_e===null){_t.error("创建连接获取dom为空!");return}_e.style.height=Math.floor(window.innerHeight-52)+"px",X.term.open(_e),X.fit.fit();let oe=`h=${X.term.rows}&w=${X.term.cols}&session_id=${X.session_id}&Authorization=${localStorage.getItem("token")}`,Se=`${location.protocol=="http:"?"ws://":"wss://"}${location.host}/api/ssh/conn?${oe}`,Te=new WebSocket(Se);Te.onopen=function(){try{let be=X.init_banner.trim();be!==""&&X.term.writeln(be),ie();let Re=X.init_cmd.trim();Re!==""&&Te.send(`${Re}
+`)})}catch{_t.error("执行命令失败")}}function y(){const j=document.createElement("input");j.type="file",j.addEventListener("change",J=>{const ne=J.target.files;if(ne&&ne.length>0){let X=ne[0];if(!(X.size/1024/1024<1)){_t.error("上传文件大小不能超过 1MB!");return}const ce=new FileReader;ce.onload=_e=>{r.cert_data=_e.target.result},ce.readAsText(X)}}),j.click()}function w(){let j={host:{},is_success:!1};return r.name.length===0?(_t.error("名称不能为空"),j):r.name.length>30?(_t.error("名称不能大于30个字符"),j):r.address.length===0?(_t.error("主机不能为空"),j):r.address.length>60?(_t.error("主机不能大于60个字符"),j):r.user.length===0?(_t.error("用户名不能为空"),j):r.user.length>60?(_t.error("用户名不能大于60个字符"),j):r.user.length===0?(_t.error("用户名不能为空"),j):r.user.length>60?(_t.error("用户名不能大于60个字符"),j):r.auth_type==="pwd"&&r.pwd.length===0?(_t.error("密码不能为空"),j):r.user.length>60?(_t.error("密码不能大于60个字符"),j):r.port?r.port<1||r.port>65535?(_t.error("端口范围错误,必须是1-65535"),j):r.auth_type==="cert"&&r.cert_data===""?(_t.error("使用证书登陆,证书内容不能为空"),j):{host:{id:r.id,name:r.name,address:r.address,user:r.user,auth_type:r.auth_type,net_type:r.net_type,cert_data:r.cert_data,cert_pwd:r.cert_pwd,pwd:r.pwd,port:r.port,session_id:r.session_id,background:r.background,foreground:r.foreground,cursor_color:r.cursor_color,font_family:r.font_family,font_size:r.font_size,cursor_style:r.cursor_style,shell:r.shell,pty_type:r.pty_type,init_cmd:r.init_cmd,init_banner:r.init_banner},is_success:!0}:(_t.error("端口输入错误,必须是1-65535"),j)}function S(){r.id=0,r.name="",r.address="",r.user="",r.pwd="",r.auth_type="pwd",r.net_type="tcp4",r.cert_data="",r.cert_pwd="",r.port=22,r.session_id="",r.background="#000000",r.foreground="#FFFFFF",r.cursor_color="#FFFFFF",r.font_family="Courier",r.font_size=16,r.cursor_style="block",r.shell="bash",r.pty_type="xterm-256color",r.init_cmd="",r.init_banner="",r.host_config_collapse=["1"]}function C(){let j=w();j.is_success&&A(j.host)}function k(j,J){r.file_dialog_visible=!0,J&&Q(J.session_id);let ne={...r.current_host};if(!ne.hasOwnProperty("session_id"))return;let X=new FormData;X.append("session_id",ne.session_id),X.append("path",j),kt.post("/api/sftp/list",X).then(te=>{te.data.code===0?(r.dir_info=te.data.data,r.sftp_current_dir=j):_t.error("获取文件列表错误")})}function E(j){r.sftp_upload_percentage=0;function J(X){let te=new FormData;te.append("session_id",r.current_host.session_id),te.append("path",j);for(let ce=0;ce<X.length;ce++)te.append("files",X[ce]);kt({url:"/api/sftp/upload",method:"put",data:te,onUploadProgress:ce=>{const{loaded:_e,total:oe}=ce;oe?r.sftp_upload_percentage=_e/oe*100|0:r.sftp_upload_percentage=_e}}).then(ce=>{if(ce.data.code===0){k(r.sftp_current_dir,r.current_host);let _e=ce.data.data;if(_e){let oe="";_e.forEach(Se=>{oe+=`<p>${Se}</p>`}),AE({type:"success",duration:7e3,title:ce.data.msg,dangerouslyUseHTMLString:!0,message:oe})}}else _t.error("上传失败")}).catch(()=>{_t.error("上传异常")})}let ne=document.createElement("input");ne.type="file",ne.multiple=!0,ne.onchange=function(X){let te=ne.files;J(te)},ne.click()}function R(j){let J=`/api/sftp/download?Authorization=${localStorage.getItem("token")}&session_id=${r.current_host.session_id}&path=${encodeURIComponent(j.path).replace(/%/g,"%25")}`,ne=document.createElement("a");ne.style.display="none",ne.href=J,ne.download=j.name,ne.click()}function $(j){let J={session_id:r.current_host.session_id,path:j.path};kt.delete("/api/sftp/delete",{data:J}).then(ne=>{ne.data.code===0?(k(r.sftp_current_dir,r.current_host),_t.success("删除文件成功")):_t.error("删除文件出错了")})}function x(j,J){let ne={session_id:J.session_id,path:j};kt.post("/api/sftp/create_dir",ne).then(X=>{X.data.code===0?(k(r.sftp_current_dir,r.current_host),_t.success("创建目录成功")):_t.error("创建目录出错了")})}function L(){kt.get("/api/conn_conf").then(j=>{j.data.code===0?r.host_list=j.data.data:_t.error("获取主机列表错误")})}function D(j,J){if(J==0){for(let ne=0;ne<r.host_list.length;ne++)if(r.host_list[ne].name==j.name){_t.error("名称已经存在,请修改");return}}r.host_dialog_visible=!1,J==0?kt.post("/api/conn_conf",j).then(ne=>{ne.data.code===0?(r.host_list=ne.data.data,S()):_t.error("新增出错了")}):kt.put("/api/conn_conf",j).then(ne=>{ne.data.code===0?(r.host_list=ne.data.data,S()):_t.error("更新出错了")})}function K(){S(),r.host_dialog_visible=!0,r.mode=0}function W(j=!1){r.mode=0;let J=w();J.is_success&&(D(J.host,0),j&&A(J.host))}function N(j){r.host_dialog_visible=!0,r.mode=1,r.id=j.id,r.address=j.address,r.name=j.name,r.user=j.user,r.auth_type=j.auth_type,r.net_type=j.net_type,r.cert_data=j.cert_data,r.cert_pwd=j.cert_pwd,r.pwd=j.pwd,r.port=j.port,r.background=j.background,r.foreground=j.foreground,r.cursor_color=j.cursor_color,r.font_family=j.font_family,r.font_size=j.font_size,r.cursor_style=j.cursor_style,r.shell=j.shell,r.pty_type=j.pty_type,r.init_cmd=j.init_cmd,r.init_banner=j.init_banner,r.host_config_collapse=["1"]}function I(j=!1){let J=w();J.is_success&&(D(J.host,1),j&&A(J.host))}function V(j){kt.delete(`/api/conn_conf/${j.id}`).then(J=>{J.data.code===0?(r.host_list=J.data.data,S()):_t.error("删除主机出错了")})}function T(j){if(j.term)try{j.fit.dispose(),j.term.dispose(),j.ws.close()}catch(ne){console.log("清理资源错误:"+ne)}let J=document.getElementById(j.session_id);return J&&(J.innerHTML=""),{id:j.id,name:j.name,address:j.address,user:j.user,auth_type:j.auth_type,net_type:j.net_type,cert_data:j.cert_data,cert_pwd:j.cert_pwd,pwd:j.pwd,port:j.port,session_id:j.session_id,background:j.background,foreground:j.foreground,cursor_color:j.cursor_color,font_family:j.font_family,font_size:j.font_size,cursor_style:j.cursor_style,shell:j.shell,pty_type:j.pty_type,init_cmd:j.init_cmd,init_banner:j.init_banner}}function A(j,J=!1){r.host_dialog_visible=!1;let ne="/api/ssh/create_session";J&&(ne+=`?session_id=${j.session_id}`);let X=T(j);kt.post(ne,X).then(te=>{if(te.data.code===0){let ce=te.data.data;if(X.session_id=ce,X.fit=new zte.FitAddon,X.term=new Hte.Terminal({cursorBlink:!0,theme:{background:X.background,foreground:X.foreground,cursor:X.cursor_color},fontSize:X.font_size,fontFamily:X.font_family,cursorStyle:X.cursor_style}),X.term.loadAddon(X.fit),J){for(let[_e,oe]of r.host_tabs.entries())if(oe.session_id===ce){X.is_close=!1,r.host_tabs[_e]=X;break}}else r.host_tabs.push(X);qe(()=>{let _e=document.getElementById(X.session_id);if(_e===null){_t.error("创建连接获取dom为空!");return}_e.style.height=Math.floor(window.innerHeight-52)+"px",X.term.open(_e),X.fit.fit();let oe=`h=${X.term.rows}&w=${X.term.cols}&session_id=${X.session_id}&nonce=${te.data.nonce}&Authorization=${localStorage.getItem("token")}`,Se=`${location.protocol=="http:"?"ws://":"wss://"}${location.host}/api/ssh/conn?${oe}`,Te=new WebSocket(Se);Te.onopen=function(){try{let be=X.init_banner.trim();be!==""&&X.term.writeln(be),ie();let Re=X.init_cmd.trim();Re!==""&&Te.send(`${Re}
 `)}catch(be){console.log(be)}},Te.onerror=function(be){console.log("WebSocket error"),X.term.writeln("##  连接出错,请重连!  ##")},Te.onclose=function(){console.log("WebSocket close:"+X.session_id),X.term.writeln("##  连接关闭,请重连!  ##"),X.is_close=!0,r.current_host.session_id===ce&&(r.current_host.is_close=!0)},X.term.loadAddon(new Vte.AttachAddon(Te)),X.ws=Te,X.is_close=!1,X.term.focus(),S(),r.current_host={...X}})}else _t.error("创建连接出错了")}).catch(te=>{_t.error("创建会话出错了"),console.log(te)})}function H(j){try{kt.post(`/api/ssh/disconnect?session_id=${j}`)}catch(ne){console.log(ne)}let J=0;for(let[ne,X]of r.host_tabs.entries())if(X.session_id===String(j)){J=ne;break}if(r.host_tabs[J].fit.dispose(),r.host_tabs[J].term.dispose(),r.host_tabs[J].ws.close(),r.host_tabs.splice(J,1),r.host_tabs.length!==0){if(r.host_tabs.length===1){let ne={...r.host_tabs[0]};Q(ne.session_id);return}if(r.host_tabs.length>1){let ne={...r.host_tabs[J-1]};Q(ne.session_id)}}}function q(j){let J=j.props.name;r.current_host.session_id!==J&&Q(J)}function Q(j){for(const J of r.host_tabs)if(J.session_id===j){r.current_host={...J};break}ie()}function ie(){let j=r.current_host;j.session_id!==""&&t.currentRoute.value.name==="Home"&&qe(()=>{let J=document.getElementById(j.session_id);if(J===null){console.log("调整窗口大小,没有获取到dom");return}J.style.height=Math.floor(window.innerHeight-58)+"px",j.fit.fit();let ne=`/api/ssh/conn?w=${j.term.cols}&h=${j.term.rows}&session_id=${j.session_id}`;kt.patch(ne),r.h=Math.floor(j.term.rows),r.w=Math.floor(j.term.cols)})}function fe(){f=setInterval(()=>{let j=new FormData;r.host_tabs.forEach(J=>{j.append("ids",J.session_id)}),kt.put("/api/conn_manage/refresh_conn_time",j).then(J=>{J.data.code!==0&&console.log("刷新失败")})},1e4)}function re(){t.push({name:"Manage"})}function U(j,J){let ne=0;return function(X){clearTimeout(ne),ne=setTimeout(()=>{j()},J)}}function B(){r.host_tabs.forEach((j,J)=>{try{kt.post(`/api/ssh/disconnect?session_id=${j.session_id}`)}catch(ne){console.log(ne)}})}function se(){B(),n.logout(),t.push({name:"Login"})}return st(()=>{t=Zc(),fe(),L(),g(),window.addEventListener("resize",U(ie,200)),ie(),window.onbeforeunload=function(){return"关闭吗"}}),Pt(()=>{clearInterval(f),B(),window.onbeforeunload=null}),(j,J)=>{const ne=sn,X=z2,te=yn,ce=l2,_e=V2,oe=gv,Se=Tv,Te=fC,be=xv,Re=Cv,me=Ov,Me=mv,Ne=kn,ge=Vv,Pe=yC,Fe=tl,nt=Qs,it=wC,Ke=_C,ye=WC,$e=jv,ke=nE,he=Av,Ee=tE,He=eE,de=$v;return M(),ue(de,null,{default:G(()=>[F(he,{style:{"text-align":"left",height:"24px","padding-left":"0px","padding-right":"0px"}},{default:G(()=>[F(me,null,{default:G(()=>[F(be,{span:12},{default:G(()=>[F(Re,null,{default:G(()=>[F(c(po),{placement:"bottom",trigger:"click",width:700},{reference:G(()=>[F(ne,{type:"primary",icon:c(zI)},{default:G(()=>[je("打开")]),_:1},8,["icon"])]),default:G(()=>[F(_e,{data:i.value,height:"260","show-overflow-tooltip":!0},{default:G(()=>[F(X,{sortable:"",fixed:"left",width:"150",property:"name",label:"名称"}),F(X,{sortable:"",width:"150",property:"address",label:"主机"}),F(X,{sortable:"",width:"100",property:"user",label:"用户"}),F(X,{sortable:"",width:"70",property:"port",label:"端口"}),F(X,{label:"操作",fixed:"right",width:"190"},{header:G(()=>[F(te,{modelValue:a.value,"onUpdate:modelValue":J[0]||(J[0]=pe=>a.value=pe),size:"small",placeholder:"名称及主机搜索"},null,8,["modelValue"])]),default:G(pe=>[F(ne,{size:"small",onClick:Je=>N(pe.row)},{default:G(()=>[je("编辑")]),_:2},1032,["onClick"]),F(ce,{confirmButtonText:"删除",cancelButtonText:"取消",icon:"el-icon-info",iconColor:"red",title:"确定删除吗",onConfirm:Je=>V(pe.row)},{reference:G(()=>[F(ne,{size:"small",type:"danger"},{default:G(()=>[je("删除")]),_:1})]),_:2},1032,["onConfirm"]),F(ne,{size:"small",type:"primary",onClick:Je=>A(pe.row)},{default:G(()=>[je("连接")]),_:2},1032,["onClick"])]),_:1})]),_:1},8,["data"])]),_:1}),F(ne,{type:"primary",onClick:K,icon:c(_I)},{default:G(()=>[je("新建")]),_:1},8,["icon"]),F(c(po),{placement:"bottom",trigger:"click",width:700},{reference:G(()=>[F(ne,{type:"primary",icon:c($I)},{default:G(()=>[je("执行命令")]),_:1},8,["icon"])]),default:G(()=>[F(Me,{model:c(s)},{default:G(()=>[F(oe,{label:"执行命令"},{default:G(()=>[F(te,{modelValue:c(s).data,"onUpdate:modelValue":J[1]||(J[1]=pe=>c(s).data=pe),type:"textarea",autocomplete:"off",placeholder:"命令或脚本"},null,8,["modelValue"])]),_:1}),F(me,null,{default:G(()=>[F(be,{span:12},{default:G(()=>[F(oe,{label:"会话选择"},{default:G(()=>[F(Te,{modelValue:c(s).node,"onUpdate:modelValue":J[2]||(J[2]=pe=>c(s).node=pe)},{default:G(()=>[F(Se,{value:"current"},{default:G(()=>[je("当前会话")]),_:1}),F(Se,{value:"all"},{default:G(()=>[je("所有会话")]),_:1})]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(be,{span:12},{default:G(()=>[F(oe,null,{default:G(()=>[F(te,{modelValue:c(s).name,"onUpdate:modelValue":J[3]||(J[3]=pe=>c(s).name=pe),maxlength:"32","show-word-limit":"",placeholder:"如果需要收藏命令,请输入名称"},{append:G(()=>[F(Re,{style:{color:"blue"}},{default:G(()=>[F(ne,{onClick:b},{default:G(()=>[je("收藏")]),_:1}),F(ne,{onClick:_},{default:G(()=>[je("执行")]),_:1})]),_:1})]),_:1},8,["modelValue"])]),_:1})]),_:1})]),_:1})]),_:1},8,["model"])]),_:1}),F(c(po),{placement:"bottom",trigger:"click",width:700},{reference:G(()=>[F(ne,{type:"primary",icon:c(Sw)},{default:G(()=>[je("命令收藏")]),_:1},8,["icon"])]),default:G(()=>[F(_e,{data:d.value,height:"260"},{default:G(()=>[F(X,{sortable:"",width:"180","show-overflow-tooltip":!0,property:"cmd_name",label:"名称"}),F(X,{sortable:"",property:"cmd_data",label:"命令"},{default:G(pe=>[F(c(po),{effect:"light",trigger:"hover",placement:"right",width:"auto"},{default:G(()=>[Kte,Z("div",null,[F(te,{modelValue:pe.row.cmd_data,"onUpdate:modelValue":Je=>pe.row.cmd_data=Je,style:{width:"600px"},autosize:{minRows:4,maxRows:20},type:"textarea",disabled:!0},null,8,["modelValue","onUpdate:modelValue"])]),Z("div",null,[F(Re,null,{default:G(()=>[F(Ne,{effect:"dark",content:"执行命令,发送到所有会话",placement:"top-start"},{default:G(()=>[F(ne,{type:"warning",onClick:Je=>m(pe.row)},{default:G(()=>[je("发送所有会话")]),_:2},1032,["onClick"])]),_:2},1024),F(Ne,{effect:"dark",content:"执行命令,发送到当前会话",placement:"top-start"},{default:G(()=>[F(ne,{type:"primary",onClick:Je=>v(pe.row)},{default:G(()=>[je("发送当前会话")]),_:2},1032,["onClick"])]),_:2},1024)]),_:2},1024)])]),reference:G(()=>[je(xe(pe.row.cmd_data.substring(0,15)+"..."),1)]),_:2},1024)]),_:1}),F(X,{label:"操作",fixed:"right",width:"260"},{header:G(()=>[F(te,{modelValue:l.value,"onUpdate:modelValue":J[4]||(J[4]=pe=>l.value=pe),size:"small",placeholder:"名称搜索"},null,8,["modelValue"])]),default:G(pe=>[F(Re,null,{default:G(()=>[F(ce,{confirmButtonText:"删除",cancelButtonText:"取消",icon:"el-icon-info",iconColor:"red",title:"确定删除吗",onConfirm:Je=>h(pe.row.id)},{reference:G(()=>[F(ne,{type:"danger"},{default:G(()=>[je("删除")]),_:1})]),_:2},1032,["onConfirm"]),F(Ne,{effect:"dark",content:"执行命令,发送到所有会话",placement:"top-start"},{default:G(()=>[F(ne,{type:"warning",onClick:Je=>m(pe.row)},{default:G(()=>[je("发送所有会话")]),_:2},1032,["onClick"])]),_:2},1024),F(Ne,{effect:"dark",content:"执行命令,发送到当前会话",placement:"top-start"},{default:G(()=>[F(ne,{type:"primary",onClick:Je=>v(pe.row)},{default:G(()=>[je("发送当前会话")]),_:2},1032,["onClick"])]),_:2},1024)]),_:2},1024)]),_:1})]),_:1},8,["data"])]),_:1}),F(ye,{title:c(r).mode==0?"新增主机":"更新主机",modelValue:c(r).host_dialog_visible,"onUpdate:modelValue":J[30]||(J[30]=pe=>c(r).host_dialog_visible=pe),width:"80%",top:"60px"},{footer:G(()=>[Z("span",jte,[F(ne,{onClick:J[25]||(J[25]=pe=>c(r).host_dialog_visible=!1)},{default:G(()=>[je("取消")]),_:1}),F(ne,{type:"success",onClick:C},{default:G(()=>[je("连接")]),_:1})]),je("      "),c(r).mode==0?(M(),Y("span",Ute,[F(ne,{type:"primary",onClick:J[26]||(J[26]=pe=>W(!1))},{default:G(()=>[je("保存")]),_:1}),F(ne,{type:"primary",onClick:J[27]||(J[27]=pe=>W(!0))},{default:G(()=>[je("连接并保存")]),_:1})])):le("",!0),c(r).mode==1?(M(),Y("span",qte,[F(ne,{type:"primary",onClick:J[28]||(J[28]=pe=>I(!1))},{default:G(()=>[je("更新")]),_:1}),F(ne,{type:"primary",onClick:J[29]||(J[29]=pe=>I(!0))},{default:G(()=>[je("连接并更新")]),_:1})])):le("",!0)]),default:G(()=>[F(Me,{"label-width":"80px",ref:"host_from"},{default:G(()=>[F(Ke,{modelValue:c(r).host_config_collapse,"onUpdate:modelValue":J[24]||(J[24]=pe=>c(r).host_config_collapse=pe)},{default:G(()=>[F(Pe,{title:"基础配置",name:"1"},{default:G(()=>[F(me,null,{default:G(()=>[F(be,{span:16},{default:G(()=>[F(oe,{label:"名称",prop:"name"},{default:G(()=>[F(te,{modelValue:c(r).name,"onUpdate:modelValue":J[5]||(J[5]=pe=>c(r).name=pe),modelModifiers:{trim:!0},minlength:"1",maxlength:"30","show-word-limit":"",placeholder:"请输入名称"},null,8,["modelValue"])]),_:1})]),_:1})]),_:1}),F(me,null,{default:G(()=>[F(be,{span:16},{default:G(()=>[F(oe,{label:"主机",prop:"address"},{default:G(()=>[F(te,{modelValue:c(r).address,"onUpdate:modelValue":J[6]||(J[6]=pe=>c(r).address=pe),modelModifiers:{trim:!0},minlength:"1",maxlength:"60","show-word-limit":"",placeholder:"请输入主机地址"},null,8,["modelValue"])]),_:1})]),_:1}),F(be,{span:8},{default:G(()=>[F(oe,{label:"网络",prop:"net_type"},{default:G(()=>[F(Te,{modelValue:c(r).net_type,"onUpdate:modelValue":J[7]||(J[7]=pe=>c(r).net_type=pe)},{default:G(()=>[F(Se,{value:"tcp4"},{default:G(()=>[je("IPv4")]),_:1}),F(Se,{value:"tcp6"},{default:G(()=>[je("IPv6")]),_:1})]),_:1},8,["modelValue"])]),_:1})]),_:1})]),_:1}),F(me,null,{default:G(()=>[F(be,{span:16},{default:G(()=>[F(oe,{label:"用户",prop:"user"},{default:G(()=>[F(te,{minlength:"1",maxlength:"60",modelValue:c(r).user,"onUpdate:modelValue":J[8]||(J[8]=pe=>c(r).user=pe),modelModifiers:{trim:!0},"show-word-limit":"",placeholder:"请输入用户名"},null,8,["modelValue"])]),_:1})]),_:1}),F(be,{span:8},{default:G(()=>[F(oe,{label:"端口",prop:"port"},{default:G(()=>[F(ge,{modelValue:c(r).port,"onUpdate:modelValue":J[9]||(J[9]=pe=>c(r).port=pe),min:1,max:65535},null,8,["modelValue"])]),_:1})]),_:1})]),_:1}),F(me,null,{default:G(()=>[F(oe,{label:"认证方式"},{default:G(()=>[F(Te,{modelValue:c(r).auth_type,"onUpdate:modelValue":J[10]||(J[10]=pe=>c(r).auth_type=pe)},{default:G(()=>[F(Se,{value:"pwd"},{default:G(()=>[je("密码")]),_:1}),F(Se,{value:"cert"},{default:G(()=>[je("证书")]),_:1})]),_:1},8,["modelValue"])]),_:1})]),_:1}),c(r).auth_type==="cert"?(M(),ue(me,{key:0},{default:G(()=>[F(be,{span:16},{default:G(()=>[F(oe,{label:"证书"},{default:G(()=>[F(te,{modelValue:c(r).cert_data,"onUpdate:modelValue":J[11]||(J[11]=pe=>c(r).cert_data=pe),type:"textarea",placeholder:"请输入证书内容或上传"},null,8,["modelValue"])]),_:1})]),_:1}),F(be,{span:8},{default:G(()=>[F(oe,{label:"上传"},{default:G(()=>[F(ne,{type:"primary",onClick:y},{default:G(()=>[je("上传证书文件")]),_:1})]),_:1})]),_:1})]),_:1})):le("",!0),F(me,null,{default:G(()=>[F(be,{span:16},{default:G(()=>[c(r).auth_type==="cert"?(M(),ue(oe,{key:0,label:"证书密码",prop:"cert_pwd"},{default:G(()=>[F(te,{minlength:"",maxlength:"60",modelValue:c(r).cert_pwd,"onUpdate:modelValue":J[12]||(J[12]=pe=>c(r).cert_pwd=pe),modelModifiers:{trim:!0},type:"passrowd","show-password":"","show-word-limit":"",placeholder:"证书密码"},null,8,["modelValue"])]),_:1})):le("",!0)]),_:1})]),_:1}),F(me,null,{default:G(()=>[F(be,{span:16},{default:G(()=>[c(r).auth_type==="pwd"?(M(),ue(oe,{key:0,label:"SSH密码",prop:"pwd"},{default:G(()=>[F(te,{minlength:"1",maxlength:"60",modelValue:c(r).pwd,"onUpdate:modelValue":J[13]||(J[13]=pe=>c(r).pwd=pe),modelModifiers:{trim:!0},type:"passrowd","show-password":"","show-word-limit":"",placeholder:"SSH密码"},null,8,["modelValue"])]),_:1})):le("",!0)]),_:1})]),_:1})]),_:1}),F(Pe,{title:"高级配置",name:"2"},{default:G(()=>[F(me,null,{default:G(()=>[F(be,{span:9},{default:G(()=>[F(oe,{label:"终端类型",prop:"pty_type"},{default:G(()=>[F(nt,{style:{width:"130px"},modelValue:c(r).pty_type,"onUpdate:modelValue":J[14]||(J[14]=pe=>c(r).pty_type=pe),placeholder:"请选择终端类型"},{default:G(()=>[F(Fe,{label:"xterm-256color",value:"xterm-256color"}),F(Fe,{label:"linux",value:"linux"}),F(Fe,{label:"xtrem",value:"xtrem"})]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(be,{span:5},{default:G(()=>[F(oe,{label:"字体颜色",prop:"foreground"},{default:G(()=>[F(it,{modelValue:c(r).foreground,"onUpdate:modelValue":J[15]||(J[15]=pe=>c(r).foreground=pe)},null,8,["modelValue"])]),_:1})]),_:1}),F(be,{span:5},{default:G(()=>[F(oe,{label:"背景颜色",prop:"background"},{default:G(()=>[F(it,{modelValue:c(r).background,"onUpdate:modelValue":J[16]||(J[16]=pe=>c(r).background=pe)},null,8,["modelValue"])]),_:1})]),_:1}),F(be,{span:5},{default:G(()=>[F(oe,{label:"光标颜色",prop:"cursor_color"},{default:G(()=>[F(it,{modelValue:c(r).cursor_color,"onUpdate:modelValue":J[17]||(J[17]=pe=>c(r).cursor_color=pe)},null,8,["modelValue"])]),_:1})]),_:1})]),_:1}),F(me,null,{default:G(()=>[F(be,{span:9},{default:G(()=>[F(oe,{label:"字体"},{default:G(()=>[F(nt,{style:{width:"130px"},modelValue:c(r).font_family,"onUpdate:modelValue":J[18]||(J[18]=pe=>c(r).font_family=pe),placeholder:"请选择字体"},{default:G(()=>[F(Fe,{label:"Courier",value:"Courier"}),F(Fe,{label:"Courier New",value:"Courier New"}),F(Fe,{label:"Menlo",value:"Menlo"}),F(Fe,{label:"Monaco",value:"Monaco"}),F(Fe,{label:"monospace",value:"monospace"})]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(be,{span:5},{default:G(()=>[F(oe,{label:"字体大小"},{default:G(()=>[F(nt,{modelValue:c(r).font_size,"onUpdate:modelValue":J[19]||(J[19]=pe=>c(r).font_size=pe),modelModifiers:{number:!0},placeholder:"请选择字体大小"},{default:G(()=>[F(Fe,{label:"8",value:"8"}),F(Fe,{label:"12",value:"12"}),F(Fe,{label:"14",value:"14"}),F(Fe,{label:"16",value:"16"}),F(Fe,{label:"18",value:"18"}),F(Fe,{label:"20",value:"20"}),F(Fe,{label:"22",value:"22"}),F(Fe,{label:"24",value:"24"}),F(Fe,{label:"26",value:"26"}),F(Fe,{label:"28",value:"28"}),F(Fe,{label:"30",value:"30"}),F(Fe,{label:"32",value:"32"}),F(Fe,{label:"34",value:"34"})]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(be,{span:5},{default:G(()=>[F(oe,{label:"光标样式"},{default:G(()=>[F(nt,{modelValue:c(r).cursor_style,"onUpdate:modelValue":J[20]||(J[20]=pe=>c(r).cursor_style=pe),placeholder:"请选择光标样式"},{default:G(()=>[F(Fe,{label:"块状",value:"block"}),F(Fe,{label:"下划线",value:"underline"}),F(Fe,{label:"竖线",value:"bar"})]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(be,{span:5},{default:G(()=>[F(oe,{label:"Shell"},{default:G(()=>[F(nt,{modelValue:c(r).shell,"onUpdate:modelValue":J[21]||(J[21]=pe=>c(r).shell=pe),placeholder:"请选择Shell"},{default:G(()=>[F(Fe,{label:"/bin/sh",value:"/bin/sh"}),F(Fe,{label:"bash",value:"bash"}),F(Fe,{label:"csh",value:"csh"}),F(Fe,{label:"zsh",value:"zsh"})]),_:1},8,["modelValue"])]),_:1})]),_:1})]),_:1}),F(me,null,{default:G(()=>[F(be,{span:24},{default:G(()=>[F(oe,{label:"连接命令"},{default:G(()=>[F(te,{modelValue:c(r).init_cmd,"onUpdate:modelValue":J[22]||(J[22]=pe=>c(r).init_cmd=pe),type:"textarea",row:1,placeholder:"请输入连接后执行命令"},null,8,["modelValue"])]),_:1})]),_:1})]),_:1}),F(me,null,{default:G(()=>[F(be,{span:24},{default:G(()=>[F(oe,{label:"连接横幅"},{default:G(()=>[F(te,{modelValue:c(r).init_banner,"onUpdate:modelValue":J[23]||(J[23]=pe=>c(r).init_banner=pe),type:"textarea",row:1,placeholder:"请输入连接后提示横幅"},null,8,["modelValue"])]),_:1})]),_:1})]),_:1})]),_:1})]),_:1},8,["modelValue"])]),_:1},512)]),_:1},8,["title","modelValue"]),F(ye,{modelValue:c(r).file_dialog_visible,"onUpdate:modelValue":J[36]||(J[36]=pe=>c(r).file_dialog_visible=pe),width:"80%","custom-class":"file-dialog",top:"60px"},{header:G(()=>[Z("span",{innerHTML:u.value},null,8,Gte)]),default:G(()=>[F(Re,{style:{width:"auto",display:"flex","flex-wrap":"nowrap","overflow-x":"auto"}},{default:G(()=>[(M(!0),Y(Ue,null,gt(c(r).dir_info.paths,(pe,Je)=>(M(),ue(ne,{key:Je,onClick:Tt=>k(pe.dir,c(r).current_host)},{default:G(()=>[je(xe(pe.name),1)]),_:2},1032,["onClick"]))),128))]),_:1}),F(oe,{style:{"margin-top":"10px"}},{default:G(()=>[F(te,{modelValue:c(r).sftp_current_dir,"onUpdate:modelValue":J[35]||(J[35]=pe=>c(r).sftp_current_dir=pe),style:{width:"100%"},placeholder:"请输入路径",class:"input-with-select"},{append:G(()=>[F(Re,{style:{color:"blue"}},{default:G(()=>[F(ne,{onClick:J[31]||(J[31]=pe=>k(c(r).sftp_current_dir,c(r).current_host))},{default:G(()=>[je("进入")]),_:1}),F(ne,{onClick:J[32]||(J[32]=pe=>E(c(r).sftp_current_dir))},{default:G(()=>[je("上传")]),_:1}),F(ne,{onClick:J[33]||(J[33]=pe=>x(c(r).sftp_current_dir,c(r).current_host))},{default:G(()=>[je("创建目录")]),_:1}),F(ne,{onClick:J[34]||(J[34]=pe=>k(c(r).sftp_current_dir,c(r).current_host))},{default:G(()=>[je("刷新")]),_:1})]),_:1})]),_:1},8,["modelValue"])]),_:1}),F(me,null,{default:G(()=>[F(be,{span:24},{default:G(()=>[F($e,{percentage:c(r).sftp_upload_percentage},null,8,["percentage"])]),_:1})]),_:1}),F(_e,{data:c(r).dir_info.files,height:"400","show-overflow-tooltip":!0},{default:G(()=>[F(X,{prop:"name",label:"文件名",fixed:"left",sortable:""},{default:G(pe=>[pe.row.type==="f"?(M(),ue(ne,{key:0,onClick:Je=>R(pe.row),type:"primary",link:"",size:"small",icon:c(MI),style:{color:"green"}},{default:G(()=>[je(xe(pe.row.name),1)]),_:2},1032,["onClick","icon"])):le("",!0),pe.row.type==="d"?(M(),ue(ne,{key:1,onClick:Je=>k(pe.row.path,c(r).current_host),type:"primary",link:"",size:"small",icon:c(II)},{default:G(()=>[je(xe(pe.row.name),1)]),_:2},1032,["onClick","icon"])):le("",!0)]),_:1}),F(X,{prop:"size",label:"大小",width:"100",sortable:""}),F(X,{prop:"mode",label:"权限",width:"100",sortable:""}),F(X,{prop:"mod_time",label:"修改日期",width:"180",sortable:""}),F(X,{label:"操作",width:"150",fixed:"right"},{default:G(pe=>[F(Re,null,{default:G(()=>[pe.row.type=="f"?(M(),ue(ne,{key:0,onClick:Je=>R(pe.row),type:"success",icon:c(oI)},{default:G(()=>[je("下载")]),_:2},1032,["onClick","icon"])):(M(),ue(ne,{key:1,type:"primary",icon:c(yP),onClick:Je=>E(pe.row.path)},{default:G(()=>[je("上传")]),_:2},1032,["icon","onClick"])),F(ce,{confirmButtonText:"删除",cancelButtonText:"取消",icon:"el-icon-info",iconColor:"red",title:"确定删除吗",onConfirm:Je=>$(pe.row)},{reference:G(()=>[F(ne,{type:"danger"},{default:G(()=>[je("删除")]),_:1})]),_:2},1032,["onConfirm"])]),_:2},1024)]),_:1})]),_:1},8,["data"])]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(be,{span:12,style:{"text-align":"right"}},{default:G(()=>[F(Re,null,{default:G(()=>[F(c(po),{placement:"top-start",title:"详情",width:200,trigger:"hover"},{reference:G(()=>[F(ne,{type:"primary",icon:c(wP)},{default:G(()=>[je(xe(c(n).userName),1)]),_:1},8,["icon"])]),default:G(()=>[Z("p",null,[F(ke,{type:"info"},{default:G(()=>[je("用户名称:  "+xe(c(n).userDesc),1)]),_:1})]),Z("p",null,[F(ke,{type:"info"},{default:G(()=>[je("过期时间:  "+xe(c(n).userExpiryAt),1)]),_:1})])]),_:1}),F(ne,{type:"primary",icon:c(lP),onClick:J[37]||(J[37]=pe=>c(r).modify_pwd_dialog_visible=!0)},{default:G(()=>[je("修改密码")]),_:1},8,["icon"]),c(n).isAdmin==="Y"?(M(),ue(ce,{key:0,confirmButtonText:"确定",cancelButtonText:"取消",icon:"el-icon-info",iconColor:"red",title:"确定离开此页面吗",onConfirm:re},{reference:G(()=>[F(ne,{type:"danger",icon:c(SI)},{default:G(()=>[je("管理")]),_:1},8,["icon"])]),_:1})):le("",!0),F(ce,{confirmButtonText:"退出",cancelButtonText:"取消",icon:"el-icon-info",iconColor:"red",title:"确定退出吗",onConfirm:se},{reference:G(()=>[F(ne,{icon:c(vs),type:"danger"},{default:G(()=>[je("退出")]),_:1},8,["icon"])]),_:1}),Z("div",null,[F(ye,{modelValue:c(r).modify_pwd_dialog_visible,"onUpdate:modelValue":J[41]||(J[41]=pe=>c(r).modify_pwd_dialog_visible=pe),title:"修改密码",width:"500",center:""},{footer:G(()=>[Z("div",Yte,[F(ne,{onClick:J[40]||(J[40]=pe=>c(r).modify_pwd_dialog_visible=!1)},{default:G(()=>[je("取消")]),_:1}),F(ne,{type:"primary",onClick:p},{default:G(()=>[je(" 提交 ")]),_:1})])]),default:G(()=>[F(Me,null,{default:G(()=>[F(oe,null,{default:G(()=>[F(te,{modelValue:c(r).new_pwd_one,"onUpdate:modelValue":J[38]||(J[38]=pe=>c(r).new_pwd_one=pe),trim:"",type:"password",minlength:"3",maxlength:"64","show-word-limit":"","show-password":"",clearable:"",placeholder:"输入新密码"},{prepend:G(()=>[je("输入新密码")]),_:1},8,["modelValue"])]),_:1}),F(oe,null,{default:G(()=>[F(te,{modelValue:c(r).new_pwd_two,"onUpdate:modelValue":J[39]||(J[39]=pe=>c(r).new_pwd_two=pe),trim:"",type:"password",minlength:"3",maxlength:"64","show-word-limit":"","show-password":"",clearable:"",placeholder:"确认新密码"},{prepend:G(()=>[je("确认新密码")]),_:1},8,["modelValue"])]),_:1})]),_:1})]),_:1},8,["modelValue"])])]),_:1})]),_:1})]),_:1})]),_:1}),Z("div",null,[F(He,{modelValue:c(r).current_host.session_id,"onUpdate:modelValue":J[42]||(J[42]=pe=>c(r).current_host.session_id=pe),type:"card",closable:"",onTabRemove:H,onTabClick:q},{default:G(()=>[(M(!0),Y(Ue,null,gt(c(r).host_tabs,pe=>(M(),ue(Ee,{key:pe.session_id,label:pe.name,name:pe.session_id},{label:G(()=>[F(Re,{style:{width:"auto",display:"flex","flex-wrap":"nowrap","overflow-x":"auto"}},{default:G(()=>[F(c(po),{placement:"bottom",width:400,trigger:"hover"},{reference:G(()=>[F(ne,{type:pe.session_id===c(r).current_host.session_id?"primary":"info"},{default:G(()=>[Z("span",Xte,xe(pe.name),1)]),_:2},1032,["type"])]),default:G(()=>[Z("div",null,[Z("div",Jte,[F(Re,null,{default:G(()=>[F(ne,{type:"primary",onClick:Je=>A(pe,!0)},{default:G(()=>[je("重连")]),_:2},1032,["onClick"]),F(ne,{type:"primary",onClick:Je=>pe.term.clear()},{default:G(()=>[je("清空缓冲区")]),_:2},1032,["onClick"])]),_:2},1024)]),Z("div",Zte,[Z("div",null,[F(te,{disabled:"",modelValue:pe.session_id,"onUpdate:modelValue":Je=>pe.session_id=Je},{prepend:G(()=>[je("会话")]),_:2},1032,["modelValue","onUpdate:modelValue"])]),Z("div",null,[F(te,{disabled:"",modelValue:pe.address,"onUpdate:modelValue":Je=>pe.address=Je},{prepend:G(()=>[je("主机")]),_:2},1032,["modelValue","onUpdate:modelValue"])]),Z("div",null,[F(te,{disabled:"",modelValue:pe.user,"onUpdate:modelValue":Je=>pe.user=Je},{prepend:G(()=>[je("用户")]),_:2},1032,["modelValue","onUpdate:modelValue"])]),Z("div",null,[F(te,{disabled:"",modelValue:pe.port,"onUpdate:modelValue":Je=>pe.port=Je},{prepend:G(()=>[je("端口")]),_:2},1032,["modelValue","onUpdate:modelValue"])])])])]),_:2},1024),F(Ne,{class:"item",effect:"dark",content:"文件传输",placement:"top"},{default:G(()=>[F(ne,{type:pe.session_id===c(r).current_host.session_id?"primary":"info",onClick:Je=>k("/",pe),icon:c(pP)},null,8,["type","onClick","icon"])]),_:2},1024)]),_:2},1024)]),default:G(()=>[Z("div",Qte,[Z("div",{id:pe.session_id,style:{width:"100vw",height:"100vh"}},null,8,ene)])]),_:2},1032,["label","name"]))),128))]),_:1},8,["modelValue"])])]),_:1})}}}),nne=(e,t)=>{const n=e.__vccOpts||e;for(const[r,s]of t)n[r]=s;return n},yy=nne(tne,[["__scopeId","data-v-bc9db1e5"]]),rne=Z("h1",{style:{"text-align":"center"}},"欢迎登陆",-1),sne={style:{"text-align":"center"}},one=ee({__name:"Login",setup(e){let t=Zc(),n=Fu(),r=Ct({name:"",pwd:""});function s(){if(r.name.trim().length<2){_t.error("用户名至少两个字符");return}if(r.pwd.trim().length<2){_t.error("密码至少两个字符");return}kt.post("/api/login",r).then(o=>{if(o.data.code===0){_t.success("登陆成功"),localStorage.setItem("token",o.data.token),localStorage.setItem("auth","yes");let a=o.data;n.login(a.is_admin,a.is_root,a.user_name,a.user_desc,a.user_expiry_at),t.push({name:"Home"})}else _t.error("登陆失败")}).catch(()=>{_t.error("登陆失败")})}return(o,a)=>{const i=Av,l=xv,d=Bv,f=yn,u=JS,p=gv,_=sn,b=mv,h=Ov,g=TC,v=$v;return M(),ue(v,null,{default:G(()=>[F(i,null,{default:G(()=>[rne]),_:1}),F(g,null,{default:G(()=>[F(h,null,{default:G(()=>[F(l,{span:8}),F(l,{span:8},{default:G(()=>[Z("div",null,[F(d),F(b,null,{default:G(()=>[Z("div",null,[Z("div",null,[F(p,null,{default:G(()=>[F(u,{style:{width:"100%"}},{default:G(()=>[F(f,{modelValue:c(r).name,"onUpdate:modelValue":a[0]||(a[0]=m=>c(r).name=m),trim:"",minlength:"1",maxlength:"64","show-word-limit":"",clearable:"",placeholder:"请输入用户名"},{prepend:G(()=>[je("账号")]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(p,null,{default:G(()=>[F(u,{style:{width:"100%"}},{default:G(()=>[F(f,{modelValue:c(r).pwd,"onUpdate:modelValue":a[1]||(a[1]=m=>c(r).pwd=m),trim:"",type:"password",minlength:"3",maxlength:"64","show-word-limit":"","show-password":"",clearable:"",placeholder:"请输入密码"},{prepend:G(()=>[je("密码")]),_:1},8,["modelValue"])]),_:1})]),_:1}),F(p,null,{default:G(()=>[F(u,{style:{width:"100%"}},{default:G(()=>[Z("div",sne,[F(_,{onClick:s,type:"primary"},{default:G(()=>[je("登       陆")]),_:1})])]),_:1})]),_:1})])])]),_:1})])]),_:1})]),_:1})]),_:1})]),_:1})}}}),yu=g4({history:U3("./"),routes:[{path:"/",name:"Home",component:yy},{path:"/app",name:"Home",component:yy},{path:"/login",name:"Login",component:one},{path:"/manage",name:"Manage",component:()=>Ul(()=>import("./Manage-wVIhzaVO.js"),__vite__mapDeps([0,1]),import.meta.url)},{path:"/sys-init",name:"SysInit",component:()=>Ul(()=>import("./SysInit-CQ3IduBM.js"),__vite__mapDeps([2,3]),import.meta.url)},{path:"/about",name:"About",component:()=>Ul(()=>import("./About-CBma4ihj.js"),__vite__mapDeps([4,5]),import.meta.url)},{path:"/:all(.*)",name:"NotFound",component:()=>Ul(()=>import("./NotFound-C3fmtd4u.js"),[],import.meta.url)}]});function ine(e){return typeof e=="object"&&e!==null}function by(e,t){return e=ine(e)?e:Object.create(null),new Proxy(e,{get(n,r,s){return r==="key"?Reflect.get(n,r,s):Reflect.get(n,r,s)||Reflect.get(t,r,s)}})}function ane(e,t){return t.reduce((n,r)=>n==null?void 0:n[r],e)}function lne(e,t,n){return t.slice(0,-1).reduce((r,s)=>/^(__proto__)$/.test(s)?{}:r[s]=r[s]||{},e)[t[t.length-1]]=n,e}function cne(e,t){return t.reduce((n,r)=>{const s=r.split(".");return lne(n,s,ane(e,s))},{})}function une(e,t){return n=>{var r;try{const{storage:s=localStorage,beforeRestore:o=void 0,afterRestore:a=void 0,serializer:i={serialize:JSON.stringify,deserialize:JSON.parse},key:l=t.$id,paths:d=null,debug:f=!1}=n;return{storage:s,beforeRestore:o,afterRestore:a,serializer:i,key:((r=e.key)!=null?r:u=>u)(typeof l=="string"?l:l(t.$id)),paths:d,debug:f}}catch(s){return n.debug&&console.error("[pinia-plugin-persistedstate]",s),null}}}function wy(e,{storage:t,serializer:n,key:r,debug:s}){try{const o=t==null?void 0:t.getItem(r);o&&e.$patch(n==null?void 0:n.deserialize(o))}catch(o){s&&console.error("[pinia-plugin-persistedstate]",o)}}function Sy(e,{storage:t,serializer:n,key:r,paths:s,debug:o}){try{const a=Array.isArray(s)?cne(e,s):e;t.setItem(r,n.serialize(a))}catch(a){o&&console.error("[pinia-plugin-persistedstate]",a)}}function dne(e={}){return t=>{const{auto:n=!1}=e,{options:{persist:r=n},store:s,pinia:o}=t;if(!r)return;if(!(s.$id in o.state.value)){const i=o._s.get(s.$id.replace("__hot:",""));i&&Promise.resolve().then(()=>i.$persist());return}const a=(Array.isArray(r)?r.map(i=>by(i,e)):[by(r,e)]).map(une(e,s)).filter(Boolean);s.$persist=()=>{a.forEach(i=>{Sy(s.$state,i)})},s.$hydrate=({runHooks:i=!0}={})=>{a.forEach(l=>{const{beforeRestore:d,afterRestore:f}=l;i&&(d==null||d(t)),wy(s,l),i&&(f==null||f(t))})},a.forEach(i=>{const{beforeRestore:l,afterRestore:d}=i;l==null||l(t),wy(s,i),d==null||d(t),s.$subscribe((f,u)=>{Sy(u,i)},{detached:!0})})}}var fne=dne();const hd=Fb(LR),DE=c3();DE.use(fne);hd.use(DE);hd.use(Fte,{size:"small",zIndex:2e3});hd.use(yu);let Cy=Fu();yu.beforeEach((e,t)=>{if(!Cy.isInit&&e.name==="SysInit"||e.name==="Login")return!0;var n=localStorage.getItem("auth");return n==="yes"&&Cy.isLogin?!0:(yu.push({name:"Login"}),!1)});kt.interceptors.request.use(e=>(e.headers.Time=String(new Date().getTime()),e.headers.Authorization=localStorage.getItem("token"),e),e=>Promise.reject(e));kt.interceptors.response.use(e=>{let t=e.headers.newtoken;return t&&localStorage.setItem("token",t),e},e=>(e.response&&e.response.status===401&&yu.replace({name:"Login"}),Promise.reject(e)));hd.mount("#app");export{Y as A,xv as B,tl as C,Qs as D,_t as E,Ov as F,JS as G,Qa as H,Vv as I,kU as J,Fu as K,Zc as L,Cv as M,Av as N,eE as O,TC as P,$v as Q,fl as R,le as S,xe as T,nY as U,tY as V,Bv as W,iq as X,WK as Y,nne as _,M as a,F as b,ue as c,ee as d,je as e,Z as f,kt as g,z2 as h,sn as i,l2 as j,V2 as k,yn as l,gv as m,Tv as n,st as o,fC as p,rz as q,Ct as r,mv as s,WC as t,c as u,tE as v,G as w,ct as x,z as y,O as z};